@@ -1,50 +1,473 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/context-labs/mongodb-nats-connector/internal/config"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/runtime"
 	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
 )
 
-const defaultConfigFileName = "connector.yaml"
+const (
+	defaultConfigFileName = "connector.yaml"
+	defaultBackfillRate   = 100
+	defaultDevGenRate     = 10
+	defaultDevNatsPort    = 4222
+)
+
+// The exit codes of the process, so that orchestration scripts can tell why it stopped.
+const (
+	exitCodeClean     = 0 // the connector was shut down cleanly
+	exitCodeError     = 1 // any other error
+	exitCodeForced    = 2 // the in-flight change events could not be drained within the shutdown timeout
+	exitCodeConfig    = 3 // the configuration could not be loaded, or is invalid
+	exitCodePreflight = 4 // mongodb or nats could not be connected to, or the collections or streams set up
+	exitCodeMongo     = 5 // a watcher failed with a fatal mongodb error
+	exitCodeNats      = 6 // change events could not be published because of a fatal nats error
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "devgen" {
+		runDevGen(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "redrive" {
+		runRedrive(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "restore-tokens" {
+		runRestoreTokens(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "promote" {
+		runPromote(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tokens" {
+		runTokens(os.Args[2:])
+	}
+
+	dev := flag.Bool("dev", false, "run an embedded nats server with jetstream, and publish to it")
+	devNatsPort := flag.Int("dev-nats-port", defaultDevNatsPort, "port of the embedded nats server run with --dev")
+	flag.Parse()
+
+	if bucket, found := os.LookupEnv("CONFIG_KV_BUCKET"); found && !*dev {
+		runSupervisor(bucket, getEnvOrDefault("CONFIG_KV_KEY", ""))
+	}
+
 	configFileName := getEnvOrDefault("CONFIG_FILE", defaultConfigFileName)
 	cfg, err := config.Load(configFileName)
 	if err != nil {
-		log.Fatalf("error while loading config: %v", err)
+		exitf(exitCodeConfig, "error while loading config: %v", err)
+	}
+	overrideWithEnv(cfg.Connector)
+	if *dev {
+		runEmbeddedNats(cfg.Connector, *devNatsPort)
+	}
+
+	if cfg.Runtime != nil {
+		runRuntime(cfg)
+	}
+
+	runConnector(configFileName, cfg.Connector)
+}
+
+// runConnector runs a single connector with the given configuration, loaded from the given config file. Once the
+// reloadConfig command is broadcast to its control subject, the file is loaded again, and the connector restarted with
+// it if it changed, as the supervisor does once its configuration changes. If the connector cannot be created with
+// the new configuration, it is restarted with the previous one.
+func runConnector(configFileName string, cfg *config.Connector) {
+	var previous *config.Connector
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		reloaded := make(chan *config.Connector, 1)
+		// systemd is only notified when a single connector is run, since the runtime and the supervisor start and stop
+		// connectors while the process keeps running
+		conn, err := connector.New(append(cfg.Options(),
+			connector.WithSystemdNotify(),
+			connector.WithContext(ctx),
+			connector.WithControlCommand(connector.ReloadConfigCommand, reloadConfig(configFileName, cfg, reloaded)),
+		)...)
+		if err != nil {
+			cancel()
+			if previous == nil {
+				exitf(newErrorExitCode(err), "could not create connector: %v", err)
+			}
+			log.Printf("could not create connector, restoring the previous configuration: %v", err)
+			cfg, previous = previous, nil
+			continue
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- conn.Run() // blocking call
+		}()
+		select {
+		case err = <-done:
+			cancel()
+			if err != nil {
+				exitf(runErrorExitCode(err), "exiting: %v", err)
+			}
+			exitf(exitCodeClean, "exiting: connector was shut down cleanly")
+		case next := <-reloaded:
+			cancel()
+			if err = <-done; err != nil {
+				log.Printf("connector was not shut down cleanly: %v", err)
+			}
+			cfg, previous = next, cfg
+		}
+	}
+}
+
+// reloadConfig returns the handler of the reloadConfig command of the connector run with the given configuration,
+// which loads the given config file again, and sends its configuration to the given channel if it changed.
+func reloadConfig(configFileName string, current *config.Connector,
+	reloaded chan<- *config.Connector) connector.ControlHandler {
+	return func(context.Context) error {
+		next, err := config.Load(configFileName)
+		if err != nil {
+			return fmt.Errorf("could not load config: %v", err)
+		}
+		if next.Runtime != nil {
+			return errors.New("could not reload config: a runtime cannot be started by a running connector")
+		}
+		overrideWithEnv(next.Connector)
+		if embeddedNats != nil {
+			next.Connector.Nats = current.Nats
+		}
+		changes := config.Diff(current, next.Connector)
+		if len(changes) == 0 {
+			log.Printf("configuration unchanged, the connector keeps running")
+			return nil
+		}
+		log.Printf("applying configuration changes: %v", strings.Join(changes, ", "))
+		select {
+		case reloaded <- next.Connector:
+		default: // already reloading
+		}
+		return nil
+	}
+}
+
+// embeddedNats is the NATS server run in-process with --dev, if any, which is shut down before exiting, so that its
+// JetStream data is flushed.
+var embeddedNats *nats.EmbeddedServer
+
+// exitf logs the given message, then exits with the given code.
+func exitf(code int, format string, v ...any) {
+	log.Printf(format, v...)
+	if embeddedNats != nil {
+		_ = embeddedNats.Close()
+	}
+	os.Exit(code)
+}
+
+// runEmbeddedNats starts a NATS server with JetStream enabled in-process, listening on the given port of the loopback
+// interface, and points the given connector configuration at it, so that only MongoDB is needed for local development.
+// Its streams are kept across restarts.
+func runEmbeddedNats(cfg *config.Connector, port int) {
+	srv, err := nats.RunEmbeddedServer("127.0.0.1", port, "")
+	if err != nil {
+		exitf(exitCodePreflight, "could not run embedded nats server: %v", err)
+	}
+	embeddedNats = srv
+	log.Printf("embedded nats server listening on %v", srv.ClientURL())
+	cfg.Nats = config.Nats{Url: srv.ClientURL()}
+}
+
+// newErrorExitCode returns the code to exit with once a connector could not be created with the given error, which is
+// a configuration error unless mongodb or nats could not be connected to.
+func newErrorExitCode(err error) int {
+	if errors.Is(err, connector.ErrPreflightFailed) {
+		return exitCodePreflight
+	}
+	return exitCodeConfig
+}
+
+// runErrorExitCode returns the code to exit with once a connector stopped with the given error.
+func runErrorExitCode(err error) int {
+	switch {
+	case errors.Is(err, connector.ErrForcedShutdown):
+		return exitCodeForced
+	case errors.Is(err, connector.ErrPreflightFailed):
+		return exitCodePreflight
+	case errors.Is(err, connector.ErrMongoFailed):
+		return exitCodeMongo
+	case errors.Is(err, connector.ErrNatsFailed):
+		return exitCodeNats
+	default:
+		return exitCodeError
+	}
+}
+
+// runRuntime runs a runtime hosting many connectors, whose settings default to the configured connector ones.
+func runRuntime(cfg *config.Config) {
+	rt, err := runtime.New(cfg.Connector, runtime.WithBucket(cfg.Runtime.Bucket))
+	if err != nil {
+		exitf(exitCodeConfig, "could not create runtime: %v", err)
+	}
+
+	if err = rt.Run(); err != nil {
+		exitf(runErrorExitCode(err), "exiting: %v", err)
+	}
+	exitf(exitCodeClean, "exiting: runtime was shut down cleanly")
+}
+
+// runSupervisor runs a single connector whose configuration is loaded from the given key of a NATS key-value bucket,
+// and watched for changes, instead of a config file. NATS is connected to with the settings of the environment.
+func runSupervisor(bucket, key string) {
+	bootstrap := &config.Connector{}
+	overrideWithEnv(bootstrap)
+	s, err := runtime.NewSupervisor(bootstrap,
+		runtime.WithConfigBucket(bucket),
+		runtime.WithConfigKey(key),
+		runtime.WithOverride(overrideWithEnv),
+	)
+	if err != nil {
+		exitf(exitCodeConfig, "could not create supervisor: %v", err)
+	}
+
+	if err = s.Run(); err != nil {
+		exitf(runErrorExitCode(err), "exiting: %v", err)
+	}
+	exitf(exitCodeClean, "exiting: supervisor was shut down cleanly")
+}
+
+// runBackfill publishes the documents of a configured collection matching a filter as synthetic change events, e.g.
+// connector backfill --coll orders --filter '{"status":"active"}', then exits.
+func runBackfill(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	coll := flags.String("coll", "", "name or namespace of the collection to backfill, e.g. orders or shop.orders")
+	filter := flags.String("filter", "", "query document selecting the documents to backfill, in extended json")
+	rate := flags.Float64("rate", defaultBackfillRate, "maximum number of documents backfilled per second, or 0")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(getEnvOrDefault("CONFIG_FILE", defaultConfigFileName))
+	if err != nil {
+		exitf(exitCodeConfig, "error while loading config: %v", err)
+	}
+	overrideWithEnv(cfg.Connector)
+	namespace, err := cfg.Connector.Namespace(*coll)
+	if err != nil {
+		exitf(exitCodeConfig, "could not backfill: %v", err)
+	}
+
+	conn, err := connector.New(append(cfg.Connector.Options(), connector.WithServerDisabled())...)
+	if err != nil {
+		exitf(newErrorExitCode(err), "could not create connector: %v", err)
 	}
 
-	opts := []connector.Option{
-		connector.WithLogLevel(getEnvOrDefault("LOG_LEVEL", cfg.Connector.Log.Level)),
-		connector.WithMongoUri(getEnvOrDefault("MONGO_URI", cfg.Connector.Mongo.Uri)),
-		connector.WithNatsUrl(getEnvOrDefault("NATS_URL", cfg.Connector.Nats.Url)),
-		connector.WithServerAddr(getEnvOrDefault("SERVER_ADDR", cfg.Connector.Server.Addr)),
+	backfilled, err := conn.Backfill(namespace, *filter, *rate)
+	if err != nil {
+		exitf(runErrorExitCode(err), "exiting: %d documents of %v backfilled: %v", backfilled, namespace, err)
 	}
-	for _, coll := range cfg.Connector.Collections {
-		collOpts := []connector.CollectionOption{
-			connector.WithTokensDbName(coll.TokensDbName),
-			connector.WithTokensCollName(coll.TokensCollName),
-			connector.WithStreamName(coll.StreamName),
+	exitf(exitCodeClean, "exiting: %d documents of %v backfilled", backfilled, namespace)
+}
+
+// runDevGen runs the connector with change events generated for its collections from a document template, instead of
+// watching MongoDB, e.g. connector devgen --rate 5 --ops insert=80,delete=20 --template orders.json, until it is
+// stopped.
+func runDevGen(args []string) {
+	flags := flag.NewFlagSet("devgen", flag.ExitOnError)
+	rate := flags.Float64("rate", defaultDevGenRate, "number of change events generated per second per collection")
+	ops := flags.String("ops", "", "weights of the operation types, e.g. insert=60,update=30,delete=10")
+	templateFile := flags.String("template", "", "file holding the document template, in extended json")
+	dev := flags.Bool("dev", false, "run an embedded nats server with jetstream, and publish to it")
+	devNatsPort := flags.Int("dev-nats-port", defaultDevNatsPort, "port of the embedded nats server run with --dev")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(getEnvOrDefault("CONFIG_FILE", defaultConfigFileName))
+	if err != nil {
+		exitf(exitCodeConfig, "error while loading config: %v", err)
+	}
+	overrideWithEnv(cfg.Connector)
+	if *dev {
+		runEmbeddedNats(cfg.Connector, *devNatsPort)
+	}
+	devGen := &connector.DevGen{Rate: *rate}
+	if devGen.Ops, err = parseOps(*ops); err != nil {
+		exitf(exitCodeConfig, "could not generate change events: %v", err)
+	}
+	if *templateFile != "" {
+		template, err := os.ReadFile(*templateFile)
+		if err != nil {
+			exitf(exitCodeConfig, "could not read document template: %v", err)
 		}
-		// nolint:staticcheck
-		if coll.ChangeStreamPreAndPostImages != nil && *coll.ChangeStreamPreAndPostImages {
-			collOpts = append(collOpts, connector.WithChangeStreamPreAndPostImages())
+		devGen.Template = string(template)
+	}
+
+	conn, err := connector.New(append(cfg.Connector.Options(), connector.WithDevGen(devGen))...)
+	if err != nil {
+		exitf(newErrorExitCode(err), "could not create connector: %v", err)
+	}
+
+	if err = conn.Run(); err != nil {
+		exitf(runErrorExitCode(err), "exiting: %v", err)
+	}
+	exitf(exitCodeClean, "exiting: connector was shut down cleanly")
+}
+
+// parseOps parses the weights of the operation types of generated change events, e.g. insert=60,update=30,delete=10.
+func parseOps(ops string) (map[string]int, error) {
+	if ops == "" {
+		return nil, nil
+	}
+	weights := make(map[string]int)
+	for _, op := range strings.Split(ops, ",") {
+		name, weight, found := strings.Cut(op, "=")
+		n, err := strconv.Atoi(weight)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid operation weight %q, it must be like insert=60", op)
 		}
-		if coll.TokensCollCapped != nil && coll.TokensCollSizeInBytes != nil && *coll.TokensCollCapped {
-			collOpts = append(collOpts, connector.WithTokensCollCapped(*coll.TokensCollSizeInBytes))
+		weights[strings.TrimSpace(name)] = n
+	}
+	return weights, nil
+}
+
+// runRedrive publishes the change events quarantined for a configured collection through its current pipeline, e.g.
+// connector redrive --coll orders, then exits.
+func runRedrive(args []string) {
+	flags := flag.NewFlagSet("redrive", flag.ExitOnError)
+	coll := flags.String("coll", "", "name or namespace of the collection to redrive, e.g. orders or shop.orders")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(getEnvOrDefault("CONFIG_FILE", defaultConfigFileName))
+	if err != nil {
+		exitf(exitCodeConfig, "error while loading config: %v", err)
+	}
+	overrideWithEnv(cfg.Connector)
+	namespace, err := cfg.Connector.Namespace(*coll)
+	if err != nil {
+		exitf(exitCodeConfig, "could not redrive: %v", err)
+	}
+
+	conn, err := connector.New(append(cfg.Connector.Options(), connector.WithServerDisabled())...)
+	if err != nil {
+		exitf(newErrorExitCode(err), "could not create connector: %v", err)
+	}
+
+	redriven, err := conn.Redrive(namespace)
+	if err != nil {
+		exitf(runErrorExitCode(err), "exiting: %d change events of %v redriven: %v", redriven, namespace, err)
+	}
+	exitf(exitCodeClean, "exiting: %d change events of %v redriven", redriven, namespace)
+}
+
+// runRestoreTokens restores the resume tokens of the configured collections from their latest backup, or from the given
+// backup file, e.g. connector restore-tokens --file /var/backups/connector-0.json, then exits.
+func runRestoreTokens(args []string) {
+	flags := flag.NewFlagSet("restore-tokens", flag.ExitOnError)
+	file := flags.String("file", "", "backup file to restore the resume tokens from, instead of the configured backup")
+	force := flags.Bool("force", false, "overwrite the resume tokens of the collections which already have one")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(getEnvOrDefault("CONFIG_FILE", defaultConfigFileName))
+	if err != nil {
+		exitf(exitCodeConfig, "error while loading config: %v", err)
+	}
+	overrideWithEnv(cfg.Connector)
+
+	conn, err := connector.New(append(cfg.Connector.Options(), connector.WithServerDisabled())...)
+	if err != nil {
+		exitf(newErrorExitCode(err), "could not create connector: %v", err)
+	}
+
+	restored, err := conn.RestoreTokens(*file, *force)
+	if err != nil {
+		exitf(runErrorExitCode(err), "exiting: %d resume tokens restored: %v", restored, err)
+	}
+	exitf(exitCodeClean, "exiting: %d resume tokens restored", restored)
+}
+
+// runPromote activates the watchers of the connector of a passive region from the resume tokens replicated from the
+// active one, e.g. connector promote --from connector-0, then runs it like the connector would be.
+func runPromote(args []string) {
+	flags := flag.NewFlagSet("promote", flag.ExitOnError)
+	from := flags.String("from", "", "instance id of the active connector whose replicated resume tokens are restored")
+	file := flags.String("file", "", "backup file to restore the resume tokens from, instead of the configured backup")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(getEnvOrDefault("CONFIG_FILE", defaultConfigFileName))
+	if err != nil {
+		exitf(exitCodeConfig, "error while loading config: %v", err)
+	}
+	overrideWithEnv(cfg.Connector)
+
+	conn, err := connector.New(append(cfg.Connector.Options(), connector.WithSystemdNotify())...)
+	if err != nil {
+		exitf(newErrorExitCode(err), "could not create connector: %v", err)
+	}
+
+	if err = conn.Promote(*file, *from); err != nil {
+		exitf(runErrorExitCode(err), "exiting: %v", err)
+	}
+	exitf(exitCodeClean, "exiting: connector was shut down cleanly")
+}
+
+// runTokens inspects the resume tokens of the configured collections, e.g. connector tokens inspect --coll orders, or
+// repairs the corrupt ones, e.g. connector tokens repair --coll orders --token 8263..., then exits.
+func runTokens(args []string) {
+	if len(args) == 0 || (args[0] != "inspect" && args[0] != "repair") {
+		exitf(exitCodeConfig, "usage: connector tokens inspect|repair [--coll name] [--token token]")
+	}
+	flags := flag.NewFlagSet("tokens "+args[0], flag.ExitOnError)
+	coll := flags.String("coll", "", "name or namespace of the collection, e.g. orders or shop.orders, all by default")
+	token := flags.String("token", "", "resume token to rewrite the corrupt ones with, instead of deleting them")
+	_ = flags.Parse(args[1:])
+
+	cfg, err := config.Load(getEnvOrDefault("CONFIG_FILE", defaultConfigFileName))
+	if err != nil {
+		exitf(exitCodeConfig, "error while loading config: %v", err)
+	}
+	overrideWithEnv(cfg.Connector)
+	namespace := ""
+	if *coll != "" {
+		if namespace, err = cfg.Connector.Namespace(*coll); err != nil {
+			exitf(exitCodeConfig, "could not %v resume tokens: %v", args[0], err)
 		}
-		opt := connector.WithCollection(coll.DbName, coll.CollName, collOpts...)
-		opts = append(opts, opt)
 	}
 
-	if conn, err := connector.New(opts...); err != nil {
-		log.Fatalf("could not create connector: %v", err)
-	} else {
-		log.Fatalf("exiting: %v", conn.Run())
+	conn, err := connector.New(append(cfg.Connector.Options(), connector.WithServerDisabled())...)
+	if err != nil {
+		exitf(newErrorExitCode(err), "could not create connector: %v", err)
+	}
+
+	if args[0] == "repair" {
+		repaired, err := conn.RepairTokens(namespace, *token)
+		if err != nil {
+			exitf(runErrorExitCode(err), "exiting: %d resume tokens repaired: %v", repaired, err)
+		}
+		exitf(exitCodeClean, "exiting: %d resume tokens repaired", repaired)
+	}
+	inspections, err := conn.InspectTokens(namespace)
+	if err != nil {
+		exitf(runErrorExitCode(err), "exiting: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(inspections); err != nil {
+		exitf(exitCodeError, "could not write resume token inspections: %v", err)
 	}
+	exitf(exitCodeClean, "exiting: %d resume tokens inspected", len(inspections))
+}
+
+// overrideWithEnv overrides the given connector configuration with the environment variables which are set.
+func overrideWithEnv(cfg *config.Connector) {
+	cfg.Instance.Id = getEnvOrDefault("INSTANCE_ID", cfg.Instance.Id)
+	cfg.Log.Level = getEnvOrDefault("LOG_LEVEL", cfg.Log.Level)
+	cfg.Mongo.Uri = getEnvOrDefault("MONGO_URI", cfg.Mongo.Uri)
+	cfg.Nats.Url = getEnvOrDefault("NATS_URL", cfg.Nats.Url)
+	cfg.Nats.CredsFile = getEnvOrDefault("NATS_CREDS_FILE", cfg.Nats.CredsFile)
+	cfg.Server.Addr = getEnvOrDefault("SERVER_ADDR", cfg.Server.Addr)
+	cfg.Server.AdminAddr = getEnvOrDefault("SERVER_ADMIN_ADDR", cfg.Server.AdminAddr)
 }
 
 func getEnvOrDefault(env, def string) string {
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,45 +24,385 @@ func Load(configFileName string) (*Config, error) {
 	if err = yaml.NewDecoder(configFile).Decode(config); err != nil {
 		return nil, fmt.Errorf("could not unmarshal config file: %v", err)
 	}
+	if config.Connector != nil {
+		inheritDefaults(config.Connector.Collections, config.Connector.Defaults)
+	}
 	return config, nil
 }
 
+// ParseConnector parses the given connector configuration, i.e. the content of the connector section of a config file,
+// in yaml or json. Unknown fields are rejected.
+func ParseConnector(data []byte) (*Connector, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	connector := &Connector{}
+	if err := decoder.Decode(connector); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("could not unmarshal connector config: empty document")
+		}
+		return nil, fmt.Errorf("could not unmarshal connector config: %v", err)
+	}
+	inheritDefaults(connector.Collections, connector.Defaults)
+	return connector, nil
+}
+
+// Namespace returns the namespace of the configured collection with the given name or namespace, e.g. orders or
+// shop.orders. A name must identify a single collection.
+func (c *Connector) Namespace(coll string) (string, error) {
+	var namespaces []string
+	for _, collection := range c.Collections {
+		if ns := namespace(collection); coll == ns || coll == collection.CollName {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	switch len(namespaces) {
+	case 0:
+		return "", fmt.Errorf("collection %v is not configured", coll)
+	case 1:
+		return namespaces[0], nil
+	}
+	return "", fmt.Errorf("collection %v is ambiguous, use its namespace: %v", coll, strings.Join(namespaces, ", "))
+}
+
 type Config struct {
 	Connector *Connector `yaml:"connector"`
+	// Runtime makes the process host many connectors managed via its api, instead of the configured one.
+	Runtime *Runtime `yaml:"runtime,omitempty"`
+}
+
+type Runtime struct {
+	// Bucket is the NATS key-value bucket where the definitions of the hosted connectors are persisted.
+	Bucket string `yaml:"bucket,omitempty"`
 }
 
 type Connector struct {
-	Log         Log           `yaml:"log"`
-	Mongo       Mongo         `yaml:"mongo"`
-	Nats        Nats          `yaml:"nats"`
-	Server      Server        `yaml:"server"`
+	Instance        Instance      `yaml:"instance"`
+	Log             Log           `yaml:"log"`
+	Mongo           Mongo         `yaml:"mongo"`
+	Nats            Nats          `yaml:"nats"`
+	Server          Server        `yaml:"server"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	MaxRestartTime  time.Duration `yaml:"maxRestartTime"`
+	Pipeline        *Pipeline     `yaml:"pipeline,omitempty"`
+	Tenants         []*Tenant     `yaml:"tenants,omitempty"`
+	// SchemaChangesStream is the stream where the schema changes of all the watched collections are published.
+	SchemaChangesStream string `yaml:"schemaChangesStream,omitempty"`
+	// SchemaVersionsBucket is the NATS key-value bucket where the schema versions of the collections without explicit
+	// schema version are persisted, and bumped once the envelope of their change events changes.
+	SchemaVersionsBucket string `yaml:"schemaVersionsBucket,omitempty"`
+	// WatcherStatesBucket is the NATS key-value bucket where the watchers disabled via the admin api are persisted, so
+	// that they are not started once the connector restarts.
+	WatcherStatesBucket string `yaml:"watcherStatesBucket,omitempty"`
+	// Watermark periodically publishes the cluster time up to which all the change events are processed.
+	Watermark *Watermark `yaml:"watermark,omitempty"`
+	// Events publishes the lifecycle and progress events of the connector, e.g. once a watcher starts, to core NATS.
+	Events *Events `yaml:"events,omitempty"`
+	// Control subscribes the connector to a subject where commands are broadcast to all the connectors at once.
+	Control *Control `yaml:"control,omitempty"`
+	// Metrics selects how the metrics of the connector are exported.
+	Metrics *Metrics `yaml:"metrics,omitempty"`
+	// Status periodically puts the status of the connector into a NATS key-value bucket.
+	Status *Status `yaml:"status,omitempty"`
+	// TokenBackup periodically backs the resume tokens up to a NATS object store bucket or a file.
+	TokenBackup *TokenBackup `yaml:"tokenBackup,omitempty"`
+	// PublisherGuard stops the connector once another one is found publishing to the same streams.
+	PublisherGuard *PublisherGuard `yaml:"publisherGuard,omitempty"`
+	// OplogWarning warns once the stored resume tokens are about to fall off the oplog.
+	OplogWarning *OplogWarning `yaml:"oplogWarning,omitempty"`
+	// Retry tunes how the paused publishes, the resume token saves and the reconnections to MongoDB are retried.
+	Retry *Retry `yaml:"retry,omitempty"`
+	// Webhooks are posted the connector's alerts, e.g. once a watcher fails.
+	Webhooks []*Webhook `yaml:"webhooks,omitempty"`
+	// Defaults holds the collection settings inherited by every collection that does not override them.
+	Defaults    *Collection   `yaml:"defaults,omitempty"`
 	Collections []*Collection `yaml:"collections"`
 }
 
+type Watermark struct {
+	Subject  string        `yaml:"subject,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+type Events struct {
+	// SubjectPrefix prefixes the subjects of the events, $CONNECTOR.events by default.
+	SubjectPrefix string `yaml:"subjectPrefix,omitempty"`
+	// TokenInterval is the interval the resume tokens advanced are published on, 10s by default.
+	TokenInterval time.Duration `yaml:"tokenInterval,omitempty"`
+}
+
+type Control struct {
+	// Subject is the subject the commands are broadcast to, $CONNECTOR.control by default.
+	Subject string `yaml:"subject,omitempty"`
+	// AllowUnauthenticated accepts the commands without authenticating them, which requires the requests to the server
+	// to be authenticated otherwise.
+	AllowUnauthenticated bool `yaml:"allowUnauthenticated,omitempty"`
+}
+
+type Metrics struct {
+	// Exporter is either prometheus, to scrape the metrics via GET /metrics, or statsd, to push them to a StatsD server.
+	Exporter string  `yaml:"exporter,omitempty"`
+	Statsd   *Statsd `yaml:"statsd,omitempty"`
+	// Pushgateway is where the metrics of the short-lived commands, e.g. backfills, are pushed once they complete.
+	Pushgateway *Pushgateway `yaml:"pushgateway,omitempty"`
+}
+
+type Pushgateway struct {
+	Url string `yaml:"url,omitempty"`
+	Job string `yaml:"job,omitempty"`
+}
+
+type Statsd struct {
+	Addr     string        `yaml:"addr,omitempty"`
+	Prefix   string        `yaml:"prefix,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+type Status struct {
+	Bucket   string        `yaml:"bucket,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+type TokenBackup struct {
+	Bucket   string        `yaml:"bucket,omitempty"`
+	Path     string        `yaml:"path,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// ReplicaNatsUrl is the NATS server of the passive region, whose bucket the resume tokens are backed up into too.
+	ReplicaNatsUrl string `yaml:"replicaNatsUrl,omitempty"`
+}
+
+type PublisherGuard struct {
+	LeaseBucket string        `yaml:"leaseBucket,omitempty"`
+	Interval    time.Duration `yaml:"interval,omitempty"`
+}
+
+type OplogWarning struct {
+	Headroom   time.Duration `yaml:"headroom,omitempty"`
+	WebhookUrl string        `yaml:"webhookUrl,omitempty"`
+}
+
+type Retry struct {
+	InitialInterval time.Duration `yaml:"initialInterval,omitempty"`
+	MaxInterval     time.Duration `yaml:"maxInterval,omitempty"`
+	Multiplier      float64       `yaml:"multiplier,omitempty"`
+	MaxElapsedTime  time.Duration `yaml:"maxElapsedTime,omitempty"`
+	MaxAttempts     int           `yaml:"maxAttempts,omitempty"`
+	// Jitter is one of none, full, equal, or decorrelated.
+	Jitter string `yaml:"jitter,omitempty"`
+	// RetryOn are the classes of the errors that are retried. If empty, all of them are.
+	RetryOn []string `yaml:"retryOn,omitempty"`
+}
+
+type Webhook struct {
+	Url    string `yaml:"url"`
+	Format string `yaml:"format,omitempty"`
+	// Alerts are the kinds of alerts posted to the webhook. If empty, all of them are.
+	Alerts []string `yaml:"alerts,omitempty"`
+}
+
+type Tenant struct {
+	Name    string `yaml:"name"`
+	NatsUrl string `yaml:"natsUrl"`
+}
+
+type Instance struct {
+	Id     string            `yaml:"id"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
 type Log struct {
 	Level string `yaml:"level"`
+	// Sampling samples the debug logs, e.g. the ones logged for each change event.
+	Sampling *LogSampling `yaml:"sampling,omitempty"`
+	// MetadataOnly logs only the metadata of the change events and messages at debug level, instead of their content.
+	MetadataOnly *bool `yaml:"metadataOnly,omitempty"`
+}
+
+type LogSampling struct {
+	First      int           `yaml:"first,omitempty"`
+	Thereafter int           `yaml:"thereafter,omitempty"`
+	Interval   time.Duration `yaml:"interval,omitempty"`
 }
 
 type Mongo struct {
-	Uri string `yaml:"uri"`
+	Uri            string          `yaml:"uri"`
+	AutoEncryption *AutoEncryption `yaml:"autoEncryption,omitempty"`
+}
+
+type AutoEncryption struct {
+	KeyVaultNamespace string                       `yaml:"keyVaultNamespace,omitempty"`
+	KmsProviders      map[string]map[string]string `yaml:"kmsProviders,omitempty"`
 }
 
 type Nats struct {
-	Url string `yaml:"url"`
+	Url       string `yaml:"url"`
+	CredsFile string `yaml:"credsFile,omitempty"`
+	CertFile  string `yaml:"certFile,omitempty"`
+	KeyFile   string `yaml:"keyFile,omitempty"`
+	CaFile    string `yaml:"caFile,omitempty"`
 }
 
 type Server struct {
-	Addr string `yaml:"addr"`
+	// Addr, AdminAddr and GrpcAddr are comma-separated lists of TCP addresses or Unix domain sockets, e.g.
+	// unix:/run/c.sock. If AdminAddr is set, only the health checks are served on Addr.
+	Addr        string `yaml:"addr"`
+	AdminAddr   string `yaml:"adminAddr,omitempty"`
+	GrpcAddr    string `yaml:"grpcAddr,omitempty"`
+	JournalSize *int   `yaml:"journalSize,omitempty"`
+	// ReadTimeout, WriteTimeout, IdleTimeout and MaxHeaderBytes limit the requests served, and ShutdownTimeout is the
+	// maximum amount of time the server waits for the requests being served once it is shut down.
+	ReadTimeout     time.Duration `yaml:"readTimeout,omitempty"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout,omitempty"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout,omitempty"`
+	MaxHeaderBytes  int           `yaml:"maxHeaderBytes,omitempty"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout,omitempty"`
+	// Auth authenticates the requests to all the endpoints but the health checks and the metrics, if set.
+	Auth *ServerAuth `yaml:"auth,omitempty"`
+	// Health bounds the pings of the monitored components by kind, i.e. mongo or nats.
+	Health map[string]HealthDeadline `yaml:"health,omitempty"`
+}
+
+type ServerAuth struct {
+	// BearerTokensFile is the path of a file holding the bearer tokens accepted, one per line.
+	BearerTokensFile string `yaml:"bearerTokensFile,omitempty"`
+	Oidc             *Oidc  `yaml:"oidc,omitempty"`
+}
+
+type Oidc struct {
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+type HealthDeadline struct {
+	Timeout         time.Duration `yaml:"timeout,omitempty"`
+	DegradedLatency time.Duration `yaml:"degradedLatency,omitempty"`
 }
 
 type Collection struct {
 	DbName   string `yaml:"dbName,omitempty"`
 	CollName string `yaml:"collName,omitempty"`
 	// Deprecated: will be removed in future versions. Set this configuration directly on MongoDB instead.
-	ChangeStreamPreAndPostImages *bool  `yaml:"changeStreamPreAndPostImages,omitempty"`
-	TokensDbName                 string `yaml:"tokensDbName,omitempty"`
-	TokensCollName               string `yaml:"tokensCollName,omitempty"`
-	TokensCollCapped             *bool  `yaml:"tokensCollCapped,omitempty"`
-	TokensCollSizeInBytes        *int64 `yaml:"tokensCollSizeInBytes,omitempty"`
-	StreamName                   string `yaml:"streamName,omitempty"`
+	ChangeStreamPreAndPostImages *bool             `yaml:"changeStreamPreAndPostImages,omitempty"`
+	TokensDbName                 string            `yaml:"tokensDbName,omitempty"`
+	TokensCollName               string            `yaml:"tokensCollName,omitempty"`
+	TokensCollCapped             *bool             `yaml:"tokensCollCapped,omitempty"`
+	TokensCollSizeInBytes        *int64            `yaml:"tokensCollSizeInBytes,omitempty"`
+	TokensCollTtl                time.Duration     `yaml:"tokensCollTtl,omitempty"`
+	StreamName                   string            `yaml:"streamName,omitempty"`
+	StallTimeout                 time.Duration     `yaml:"stallTimeout,omitempty"`
+	MaxEventAge                  time.Duration     `yaml:"maxEventAge,omitempty"`
+	MsgIdStrategy                string            `yaml:"msgIdStrategy,omitempty"`
+	MsgIdField                   string            `yaml:"msgIdField,omitempty"`
+	OversizedPolicy              string            `yaml:"oversizedPolicy,omitempty"`
+	DlqSubject                   string            `yaml:"dlqSubject,omitempty"`
+	OffloadBucket                string            `yaml:"offloadBucket,omitempty"`
+	QuarantineCollName           string            `yaml:"quarantineCollName,omitempty"`
+	DecodeErrorPolicy            string            `yaml:"decodeErrorPolicy,omitempty"`
+	MissingImages                string            `yaml:"missingImages,omitempty"`
+	NackPolicy                   string            `yaml:"nackPolicy,omitempty"`
+	ErrorPolicies                map[string]string `yaml:"errorPolicies,omitempty"`
+	FailureMode                  string            `yaml:"failureMode,omitempty"`
+	DuplicatesWindow             time.Duration     `yaml:"duplicatesWindow,omitempty"`
+	StreamLimits                 *StreamLimits     `yaml:"streamLimits,omitempty"`
+	PublishMode                  string            `yaml:"publishMode,omitempty"`
+	NamespaceSubjects            *bool             `yaml:"namespaceSubjects,omitempty"`
+	Partitions                   int               `yaml:"partitions,omitempty"`
+	TimeBucket                   string            `yaml:"timeBucket,omitempty"`
+	SubjectTemplate              string            `yaml:"subjectTemplate,omitempty"`
+	HeaderTemplates              map[string]string `yaml:"headerTemplates,omitempty"`
+	Routes                       []Route           `yaml:"routes,omitempty"`
+	Filter                       *Filter           `yaml:"filter,omitempty"`
+	EventTypes                   map[string]string `yaml:"eventTypes,omitempty"`
+	Enrich                       []string          `yaml:"enrich,omitempty"`
+	Patch                        string            `yaml:"patch,omitempty"`
+	Envelope                     *Envelope         `yaml:"envelope,omitempty"`
+	PayloadMode                  string            `yaml:"payloadMode,omitempty"`
+	Reshape                      []string          `yaml:"reshape,omitempty"`
+	Flatten                      *Flatten          `yaml:"flatten,omitempty"`
+	TypeConversions              *TypeConversions  `yaml:"typeConversions,omitempty"`
+	JsonFlavor                   string            `yaml:"jsonFlavor,omitempty"`
+	Canary                       *Canary           `yaml:"canary,omitempty"`
+	Snapshot                     *Snapshot         `yaml:"snapshot,omitempty"`
+	Transactions                 string            `yaml:"transactions,omitempty"`
+	SchemaVersion                int               `yaml:"schemaVersion,omitempty"`
+	TenantField                  string            `yaml:"tenantField,omitempty"`
+	TenantDbName                 *bool             `yaml:"tenantDbName,omitempty"`
+	ExcludeFields                []string          `yaml:"excludeFields,omitempty"`
+	Collation                    *Collation        `yaml:"collation,omitempty"`
+	SplitLargeEvents             *bool             `yaml:"splitLargeEvents,omitempty"`
+	EncryptedPassthrough         *bool             `yaml:"encryptedPassthrough,omitempty"`
+	GridFS                       *bool             `yaml:"gridFs,omitempty"`
+	GridFSObjectBucket           string            `yaml:"gridFsObjectBucket,omitempty"`
+	FollowRenames                *bool             `yaml:"followRenames,omitempty"`
+	ExpectStream                 *bool             `yaml:"expectStream,omitempty"`
+	StrictOrdering               *bool             `yaml:"strictOrdering,omitempty"`
+	IdempotentResume             *bool             `yaml:"idempotentResume,omitempty"`
+	AckTimeout                   time.Duration     `yaml:"ackTimeout,omitempty"`
+	RetryAttempts                int               `yaml:"retryAttempts,omitempty"`
+	RetryWait                    time.Duration     `yaml:"retryWait,omitempty"`
+	MsgTtl                       time.Duration     `yaml:"msgTtl,omitempty"`
+	Pipeline                     *Pipeline         `yaml:"pipeline,omitempty"`
+}
+
+type Route struct {
+	When            map[string]string `yaml:"when,omitempty"`
+	StreamName      string            `yaml:"streamName,omitempty"`
+	SubjectTemplate string            `yaml:"subjectTemplate,omitempty"`
+}
+
+type Filter struct {
+	Field    string   `yaml:"field,omitempty"`
+	Operator string   `yaml:"op,omitempty"`
+	Value    string   `yaml:"value,omitempty"`
+	Values   []string `yaml:"values,omitempty"`
+	And      []Filter `yaml:"and,omitempty"`
+	Or       []Filter `yaml:"or,omitempty"`
+}
+
+type Envelope struct {
+	MissingBefore string `yaml:"missingBefore,omitempty"`
+}
+
+type Flatten struct {
+	Separator string `yaml:"separator,omitempty"`
+	Arrays    string `yaml:"arrays,omitempty"`
+}
+
+type StreamLimits struct {
+	MaxMsgsPerSubject int64         `yaml:"maxMsgsPerSubject,omitempty"`
+	MaxMsgs           int64         `yaml:"maxMsgs,omitempty"`
+	MaxBytes          int64         `yaml:"maxBytes,omitempty"`
+	MaxAge            time.Duration `yaml:"maxAge,omitempty"`
+}
+
+type TypeConversions struct {
+	ObjectId string `yaml:"objectId,omitempty"`
+	Date     string `yaml:"date,omitempty"`
+	Decimal  string `yaml:"decimal,omitempty"`
+	Long     string `yaml:"long,omitempty"`
+}
+
+type Canary struct {
+	Percent       float64  `yaml:"percent,omitempty"`
+	Subject       string   `yaml:"subject,omitempty"`
+	Encoder       string   `yaml:"encoder,omitempty"`
+	ExcludeFields []string `yaml:"excludeFields,omitempty"`
+}
+
+type Snapshot struct {
+	// Schedule is the cron expression the collection is re-snapshotted on, e.g. "0 3 * * *".
+	Schedule string `yaml:"schedule,omitempty"`
+	// Filter selects the snapshotted documents, as a query document in extended json. If empty, all of them are.
+	Filter string `yaml:"filter,omitempty"`
+}
+
+type Collation struct {
+	Locale   string `yaml:"locale,omitempty"`
+	Strength int    `yaml:"strength,omitempty"`
+}
+
+type Pipeline struct {
+	PublishWorkers int     `yaml:"publishWorkers,omitempty"`
+	BatchSize      int32   `yaml:"batchSize,omitempty"`
+	RateLimit      float64 `yaml:"rateLimit,omitempty"`
+	Encoder        string  `yaml:"encoder,omitempty"`
 }
@@ -0,0 +1,117 @@
+package config
+
+import "strings"
+
+// inheritDefaults makes each collection inherit the settings of the given defaults it does not override.
+// The collection name, and the names derived from it by default, i.e. the resume tokens collection name and the stream
+// name, are never inherited, as they must be unique to each collection, unless the resume tokens collection name holds
+// the {coll} placeholder, e.g. {coll}.tokens.
+func inheritDefaults(collections []*Collection, defaults *Collection) {
+	if defaults == nil {
+		return
+	}
+	for _, coll := range collections {
+		coll.inherit(defaults)
+	}
+}
+
+func (c *Collection) inherit(defaults *Collection) {
+	inherit(&c.DbName, defaults.DbName)
+	inherit(&c.ChangeStreamPreAndPostImages, defaults.ChangeStreamPreAndPostImages)
+	inherit(&c.TokensDbName, defaults.TokensDbName)
+	inherit(&c.TokensCollCapped, defaults.TokensCollCapped)
+	inherit(&c.TokensCollSizeInBytes, defaults.TokensCollSizeInBytes)
+	if strings.Contains(defaults.TokensCollName, "{coll}") {
+		inherit(&c.TokensCollName, defaults.TokensCollName)
+	}
+	// the collections whose resume tokens collection is capped do not inherit its expiry
+	if c.TokensCollCapped == nil || !*c.TokensCollCapped {
+		inherit(&c.TokensCollTtl, defaults.TokensCollTtl)
+	}
+	inherit(&c.StallTimeout, defaults.StallTimeout)
+	inherit(&c.MaxEventAge, defaults.MaxEventAge)
+	inherit(&c.MsgIdStrategy, defaults.MsgIdStrategy)
+	inherit(&c.MsgIdField, defaults.MsgIdField)
+	inherit(&c.OversizedPolicy, defaults.OversizedPolicy)
+	inherit(&c.DlqSubject, defaults.DlqSubject)
+	inherit(&c.OffloadBucket, defaults.OffloadBucket)
+	inherit(&c.DecodeErrorPolicy, defaults.DecodeErrorPolicy)
+	inherit(&c.MissingImages, defaults.MissingImages)
+	inherit(&c.NackPolicy, defaults.NackPolicy)
+	if c.ErrorPolicies == nil {
+		c.ErrorPolicies = defaults.ErrorPolicies
+	}
+	inherit(&c.FailureMode, defaults.FailureMode)
+	inherit(&c.DuplicatesWindow, defaults.DuplicatesWindow)
+	inherit(&c.StreamLimits, defaults.StreamLimits)
+	inherit(&c.PublishMode, defaults.PublishMode)
+	inherit(&c.NamespaceSubjects, defaults.NamespaceSubjects)
+	inherit(&c.Partitions, defaults.Partitions)
+	inherit(&c.TimeBucket, defaults.TimeBucket)
+	inherit(&c.SubjectTemplate, defaults.SubjectTemplate)
+	if c.HeaderTemplates == nil {
+		c.HeaderTemplates = defaults.HeaderTemplates
+	}
+	if c.Routes == nil {
+		c.Routes = defaults.Routes
+	}
+	inherit(&c.Filter, defaults.Filter)
+	if c.EventTypes == nil {
+		c.EventTypes = defaults.EventTypes
+	}
+	if c.Enrich == nil {
+		c.Enrich = defaults.Enrich
+	}
+	inherit(&c.Patch, defaults.Patch)
+	inherit(&c.Envelope, defaults.Envelope)
+	inherit(&c.PayloadMode, defaults.PayloadMode)
+	if c.Reshape == nil {
+		c.Reshape = defaults.Reshape
+	}
+	inherit(&c.Flatten, defaults.Flatten)
+	inherit(&c.TypeConversions, defaults.TypeConversions)
+	inherit(&c.JsonFlavor, defaults.JsonFlavor)
+	inherit(&c.Canary, defaults.Canary)
+	inherit(&c.Snapshot, defaults.Snapshot)
+	inherit(&c.Transactions, defaults.Transactions)
+	inherit(&c.SchemaVersion, defaults.SchemaVersion)
+	inherit(&c.TenantField, defaults.TenantField)
+	inherit(&c.TenantDbName, defaults.TenantDbName)
+	inherit(&c.SplitLargeEvents, defaults.SplitLargeEvents)
+	inherit(&c.EncryptedPassthrough, defaults.EncryptedPassthrough)
+	inherit(&c.GridFS, defaults.GridFS)
+	inherit(&c.GridFSObjectBucket, defaults.GridFSObjectBucket)
+	inherit(&c.FollowRenames, defaults.FollowRenames)
+	inherit(&c.Collation, defaults.Collation)
+	if c.ExcludeFields == nil {
+		c.ExcludeFields = defaults.ExcludeFields
+	}
+	inherit(&c.ExpectStream, defaults.ExpectStream)
+	inherit(&c.StrictOrdering, defaults.StrictOrdering)
+	inherit(&c.IdempotentResume, defaults.IdempotentResume)
+	inherit(&c.AckTimeout, defaults.AckTimeout)
+	inherit(&c.RetryAttempts, defaults.RetryAttempts)
+	inherit(&c.RetryWait, defaults.RetryWait)
+	inherit(&c.MsgTtl, defaults.MsgTtl)
+	if defaults.Pipeline != nil {
+		if c.Pipeline == nil {
+			c.Pipeline = &Pipeline{}
+		}
+		c.Pipeline.inherit(defaults.Pipeline)
+	}
+}
+
+func (p *Pipeline) inherit(defaults *Pipeline) {
+	inherit(&p.PublishWorkers, defaults.PublishWorkers)
+	inherit(&p.BatchSize, defaults.BatchSize)
+	inherit(&p.RateLimit, defaults.RateLimit)
+	inherit(&p.Encoder, defaults.Encoder)
+}
+
+// inherit sets the given value to the given default if it is not set, i.e. if it is the zero value.
+func inherit[T comparable](value *T, defaultValue T) {
+	var zero T
+	if *value == zero {
+		*value = defaultValue
+	}
+}
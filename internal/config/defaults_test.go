@@ -0,0 +1,87 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_inheritDefaults(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name     string
+		coll     *Collection
+		defaults *Collection
+		want     *Collection
+	}{
+		{
+			name:     "should not change the collection if there are no defaults",
+			coll:     &Collection{DbName: "db", CollName: "coll1"},
+			defaults: nil,
+			want:     &Collection{DbName: "db", CollName: "coll1"},
+		},
+		{
+			name: "should inherit all the settings the collection does not override",
+			coll: &Collection{CollName: "coll1"},
+			defaults: &Collection{DbName: "db", TokensDbName: "tokens", TokensCollCapped: &enabled,
+				MsgIdStrategy: "eventHash", RetryAttempts: 3, RetryWait: time.Second, ExpectStream: &enabled,
+				PayloadMode: "slim", Reshape: []string{"fullDocument -> ."}, Flatten: &Flatten{Separator: "_"},
+				JsonFlavor: "simplified", IdempotentResume: &enabled, NackPolicy: "dlq",
+				ErrorPolicies: map[string]string{"transform": "skip"}, Pipeline: &Pipeline{Encoder: "bson"}},
+			want: &Collection{DbName: "db", CollName: "coll1", TokensDbName: "tokens", TokensCollCapped: &enabled,
+				MsgIdStrategy: "eventHash", RetryAttempts: 3, RetryWait: time.Second, ExpectStream: &enabled,
+				PayloadMode: "slim", Reshape: []string{"fullDocument -> ."}, Flatten: &Flatten{Separator: "_"},
+				JsonFlavor: "simplified", IdempotentResume: &enabled, NackPolicy: "dlq",
+				ErrorPolicies: map[string]string{"transform": "skip"}, Pipeline: &Pipeline{Encoder: "bson"}},
+		},
+		{
+			name: "should keep the settings the collection overrides",
+			coll: &Collection{DbName: "other", CollName: "coll1", RetryAttempts: 5, ExpectStream: &disabled,
+				ExcludeFields: []string{"secret"}, Pipeline: &Pipeline{PublishWorkers: 4}},
+			defaults: &Collection{DbName: "db", RetryAttempts: 3, RetryWait: time.Second, ExpectStream: &enabled,
+				ExcludeFields: []string{"password"}, Pipeline: &Pipeline{PublishWorkers: 1, Encoder: "bson"}},
+			want: &Collection{DbName: "other", CollName: "coll1", RetryAttempts: 5, RetryWait: time.Second,
+				ExpectStream: &disabled, ExcludeFields: []string{"secret"},
+				Pipeline: &Pipeline{PublishWorkers: 4, Encoder: "bson"}},
+		},
+		{
+			name:     "should never inherit the names that must be unique to each collection",
+			coll:     &Collection{DbName: "db", CollName: "coll1"},
+			defaults: &Collection{CollName: "coll", TokensCollName: "tokens", StreamName: "STREAM"},
+			want:     &Collection{DbName: "db", CollName: "coll1"},
+		},
+		{
+			name:     "should inherit the resume tokens collection name if it is named after the collection",
+			coll:     &Collection{DbName: "db", CollName: "coll1"},
+			defaults: &Collection{TokensCollName: "{db}.{coll}.tokens", TokensCollTtl: time.Hour},
+			want: &Collection{DbName: "db", CollName: "coll1", TokensCollName: "{db}.{coll}.tokens",
+				TokensCollTtl: time.Hour},
+		},
+		{
+			name:     "should not inherit the resume tokens collection expiry if the collection is capped",
+			coll:     &Collection{CollName: "coll1", TokensCollCapped: &enabled},
+			defaults: &Collection{TokensCollTtl: time.Hour},
+			want:     &Collection{CollName: "coll1", TokensCollCapped: &enabled},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inheritDefaults([]*Collection{tt.coll}, tt.defaults)
+
+			require.Equal(t, tt.want, tt.coll)
+		})
+	}
+}
+
+func Test_inheritDefaults_doesNotShareState(t *testing.T) {
+	defaults := &Collection{Pipeline: &Pipeline{Encoder: "bson"}}
+	coll1, coll2 := &Collection{CollName: "coll1"}, &Collection{CollName: "coll2"}
+
+	inheritDefaults([]*Collection{coll1, coll2}, defaults)
+	coll1.Pipeline.PublishWorkers = 4
+
+	require.Equal(t, &Pipeline{Encoder: "bson"}, coll2.Pipeline)
+	require.Equal(t, &Pipeline{Encoder: "bson"}, defaults.Pipeline)
+}
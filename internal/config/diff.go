@@ -0,0 +1,61 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Diff returns the changes between the given connector configurations, in a human-readable form, e.g. the settings
+// changed, and the collections added, removed or changed. It returns no changes if the configurations are equivalent.
+// The defaults are ignored, since they are inherited by the collections, whose changes are reported instead.
+func Diff(old, new *Connector) []string {
+	var changes []string
+	for _, field := range changedFields(*old, *new, "defaults", "collections") {
+		changes = append(changes, field+" changed")
+	}
+
+	oldColls := make(map[string]*Collection, len(old.Collections))
+	for _, coll := range old.Collections {
+		oldColls[namespace(coll)] = coll
+	}
+	newColls := make(map[string]struct{}, len(new.Collections))
+	for _, coll := range new.Collections {
+		ns := namespace(coll)
+		newColls[ns] = struct{}{}
+		oldColl, ok := oldColls[ns]
+		if !ok {
+			changes = append(changes, "collection "+ns+" added")
+			continue
+		}
+		if fields := changedFields(*oldColl, *coll); len(fields) > 0 {
+			changes = append(changes, "collection "+ns+" changed: "+strings.Join(fields, ", "))
+		}
+	}
+	for _, coll := range old.Collections {
+		if _, ok := newColls[namespace(coll)]; !ok {
+			changes = append(changes, "collection "+namespace(coll)+" removed")
+		}
+	}
+	return changes
+}
+
+// changedFields returns the yaml names of the fields of the given structs which differ, except the ignored ones.
+func changedFields[T any](old, new T, ignored ...string) []string {
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(new)
+	var fields []string
+	for i := 0; i < oldValue.NumField(); i++ {
+		name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("yaml"), ",")
+		if slices.Contains(ignored, name) {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func namespace(coll *Collection) string {
+	return coll.DbName + "." + coll.CollName
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	old := &Connector{
+		Log:   Log{Level: "info"},
+		Mongo: Mongo{Uri: "mongodb://localhost:27017"},
+		Collections: []*Collection{
+			{DbName: "test-db", CollName: "orders", StreamName: "ORDERS"},
+			{DbName: "test-db", CollName: "users", StreamName: "USERS"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		new  *Connector
+		want []string
+	}{
+		{
+			name: "should return no changes if the configurations are equivalent",
+			new: &Connector{
+				Log:   Log{Level: "info"},
+				Mongo: Mongo{Uri: "mongodb://localhost:27017"},
+				Collections: []*Collection{
+					{DbName: "test-db", CollName: "users", StreamName: "USERS"},
+					{DbName: "test-db", CollName: "orders", StreamName: "ORDERS"},
+				},
+				Defaults: &Collection{StreamName: "ORDERS"},
+			},
+		},
+		{
+			name: "should return the changed settings and collections",
+			new: &Connector{
+				Log:             Log{Level: "debug"},
+				Mongo:           Mongo{Uri: "mongodb://localhost:27017"},
+				ShutdownTimeout: time.Minute,
+				Collections: []*Collection{
+					{DbName: "test-db", CollName: "orders", StreamName: "ORDERS", MsgTtl: time.Hour, Partitions: 4},
+					{DbName: "test-db", CollName: "invoices", StreamName: "INVOICES"},
+				},
+			},
+			want: []string{
+				"log changed",
+				"shutdownTimeout changed",
+				"collection test-db.orders changed: partitions, msgTtl",
+				"collection test-db.invoices added",
+				"collection test-db.users removed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Diff(old, tt.new))
+		})
+	}
+}
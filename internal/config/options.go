@@ -0,0 +1,275 @@
+package config
+
+import (
+	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
+)
+
+// Options returns the options of the connector configured by this configuration.
+func (c *Connector) Options() []connector.Option {
+	opts := []connector.Option{
+		connector.WithInstanceId(c.Instance.Id),
+		connector.WithLabels(c.Instance.Labels),
+		connector.WithLogLevel(c.Log.Level),
+		connector.WithMongoUri(c.Mongo.Uri),
+		connector.WithNatsUrl(c.Nats.Url),
+		connector.WithNatsCredsFile(c.Nats.CredsFile),
+		connector.WithNatsTlsFiles(c.Nats.CertFile, c.Nats.KeyFile, c.Nats.CaFile),
+		connector.WithServerAddr(c.Server.Addr),
+		connector.WithServerAdminAddr(c.Server.AdminAddr),
+		connector.WithServerGrpcAddr(c.Server.GrpcAddr),
+		connector.WithServerTimeouts(c.Server.ReadTimeout, c.Server.WriteTimeout, c.Server.IdleTimeout),
+		connector.WithServerMaxHeaderBytes(c.Server.MaxHeaderBytes),
+		connector.WithServerShutdownTimeout(c.Server.ShutdownTimeout),
+		connector.WithShutdownTimeout(c.ShutdownTimeout),
+		connector.WithMaxRestartTime(c.MaxRestartTime),
+		connector.WithSchemaChangesStream(c.SchemaChangesStream),
+		connector.WithSchemaVersionsBucket(c.SchemaVersionsBucket),
+		connector.WithWatcherStatesBucket(c.WatcherStatesBucket),
+	}
+	if c.Log.Sampling != nil {
+		opts = append(opts, connector.WithLogSampling(c.Log.Sampling.First, c.Log.Sampling.Thereafter,
+			c.Log.Sampling.Interval))
+	}
+	if c.Log.MetadataOnly != nil && *c.Log.MetadataOnly {
+		opts = append(opts, connector.WithLogMetadataOnly())
+	}
+	if c.Mongo.AutoEncryption != nil {
+		opts = append(opts, connector.WithMongoAutoEncryption(c.Mongo.AutoEncryption.KeyVaultNamespace,
+			c.Mongo.AutoEncryption.KmsProviders))
+	}
+	if c.Watermark != nil {
+		opts = append(opts, connector.WithWatermark(c.Watermark.Subject, c.Watermark.Interval))
+	}
+	if c.Events != nil {
+		opts = append(opts, connector.WithEvents(c.Events.SubjectPrefix, c.Events.TokenInterval))
+	}
+	if c.Control != nil {
+		opts = append(opts, connector.WithControlSubject(c.Control.Subject))
+		if c.Control.AllowUnauthenticated {
+			opts = append(opts, connector.WithControlUnauthenticated())
+		}
+	}
+	if c.Metrics != nil {
+		opts = append(opts, connector.WithMetricsExporter(c.Metrics.Exporter))
+		if c.Metrics.Statsd != nil {
+			opts = append(opts, connector.WithStatsd(c.Metrics.Statsd.Addr, c.Metrics.Statsd.Prefix,
+				c.Metrics.Statsd.Interval))
+		}
+		if c.Metrics.Pushgateway != nil {
+			opts = append(opts, connector.WithPushgateway(c.Metrics.Pushgateway.Url, c.Metrics.Pushgateway.Job))
+		}
+	}
+	if c.Status != nil {
+		opts = append(opts, connector.WithStatusBucket(c.Status.Bucket, c.Status.Interval))
+	}
+	if c.TokenBackup != nil {
+		opts = append(opts, connector.WithTokenBackup(c.TokenBackup.Bucket, c.TokenBackup.Path,
+			c.TokenBackup.Interval), connector.WithTokenBackupReplica(c.TokenBackup.ReplicaNatsUrl))
+	}
+	if c.PublisherGuard != nil {
+		opts = append(opts, connector.WithPublisherGuard(c.PublisherGuard.LeaseBucket, c.PublisherGuard.Interval))
+	}
+	if c.OplogWarning != nil {
+		opts = append(opts, connector.WithOplogWarning(c.OplogWarning.Headroom, c.OplogWarning.WebhookUrl))
+	}
+	if c.Retry != nil {
+		opts = append(opts, connector.WithRetryPolicy(&connector.RetryPolicy{
+			InitialInterval: c.Retry.InitialInterval,
+			MaxInterval:     c.Retry.MaxInterval,
+			Multiplier:      c.Retry.Multiplier,
+			MaxElapsedTime:  c.Retry.MaxElapsedTime,
+			MaxAttempts:     c.Retry.MaxAttempts,
+			Jitter:          c.Retry.Jitter,
+			RetryOn:         c.Retry.RetryOn,
+		}))
+	}
+	if c.Pipeline != nil {
+		opts = append(opts, connector.WithPipeline(c.Pipeline.options()...))
+	}
+	if c.Server.Auth != nil {
+		opts = append(opts, connector.WithServerBearerTokensFile(c.Server.Auth.BearerTokensFile))
+		if c.Server.Auth.Oidc != nil {
+			opts = append(opts, connector.WithServerOidc(c.Server.Auth.Oidc.Issuer, c.Server.Auth.Oidc.Audience))
+		}
+	}
+	for component, deadline := range c.Server.Health {
+		opts = append(opts, connector.WithHealthDeadline(component, deadline.Timeout, deadline.DegradedLatency))
+	}
+	if c.Server.JournalSize != nil {
+		opts = append(opts, connector.WithJournalSize(*c.Server.JournalSize))
+	}
+	for _, webhook := range c.Webhooks {
+		opts = append(opts, connector.WithWebhook(webhook.Url, webhook.Format, webhook.Alerts...))
+	}
+	for _, tenant := range c.Tenants {
+		opts = append(opts, connector.WithTenant(tenant.Name, tenant.NatsUrl))
+	}
+	for _, coll := range c.Collections {
+		opts = append(opts, connector.WithCollection(coll.DbName, coll.CollName, coll.options()...))
+	}
+	return opts
+}
+
+func (c *Collection) options() []connector.CollectionOption {
+	opts := []connector.CollectionOption{
+		connector.WithTokensDbName(c.TokensDbName),
+		connector.WithTokensCollName(c.TokensCollName),
+		connector.WithStreamName(c.StreamName),
+		connector.WithStallTimeout(c.StallTimeout),
+		connector.WithMaxEventAge(c.MaxEventAge),
+		connector.WithMsgIdStrategy(c.MsgIdStrategy),
+		connector.WithMsgIdField(c.MsgIdField),
+		connector.WithOversizedPolicy(c.OversizedPolicy),
+		connector.WithDlqSubject(c.DlqSubject),
+		connector.WithDecodeErrorPolicy(c.DecodeErrorPolicy),
+		connector.WithMissingImages(c.MissingImages),
+		connector.WithNackPolicy(c.NackPolicy),
+		connector.WithErrorPolicies(c.ErrorPolicies),
+		connector.WithFailureMode(c.FailureMode),
+		connector.WithOffloadBucket(c.OffloadBucket),
+		connector.WithQuarantine(c.QuarantineCollName),
+		connector.WithDuplicatesWindow(c.DuplicatesWindow),
+		connector.WithPublishMode(c.PublishMode),
+		connector.WithPatch(c.Patch),
+		connector.WithPayloadMode(c.PayloadMode),
+		connector.WithJsonFlavor(c.JsonFlavor),
+		connector.WithAckTimeout(c.AckTimeout),
+		connector.WithRetries(c.RetryAttempts, c.RetryWait),
+		connector.WithMsgTtl(c.MsgTtl),
+		connector.WithPartitions(c.Partitions),
+		connector.WithTimeBucket(c.TimeBucket),
+		connector.WithSubjectTemplate(c.SubjectTemplate),
+		connector.WithHeaderTemplates(c.HeaderTemplates),
+		connector.WithTenantField(c.TenantField),
+		connector.WithExcludeFields(c.ExcludeFields...),
+		connector.WithTransactions(c.Transactions),
+	}
+	// nolint:staticcheck
+	if c.ChangeStreamPreAndPostImages != nil && *c.ChangeStreamPreAndPostImages {
+		opts = append(opts, connector.WithChangeStreamPreAndPostImages())
+	}
+	if c.NamespaceSubjects != nil && *c.NamespaceSubjects {
+		opts = append(opts, connector.WithNamespaceSubjects())
+	}
+	if c.TenantDbName != nil && *c.TenantDbName {
+		opts = append(opts, connector.WithTenantDbName())
+	}
+	if c.SplitLargeEvents != nil && *c.SplitLargeEvents {
+		opts = append(opts, connector.WithSplitLargeEvents())
+	}
+	if c.GridFS != nil && *c.GridFS {
+		opts = append(opts, connector.WithGridFS(c.GridFSObjectBucket))
+	}
+	if c.EncryptedPassthrough != nil && *c.EncryptedPassthrough {
+		opts = append(opts, connector.WithEncryptedPassthrough())
+	}
+	if c.FollowRenames != nil && *c.FollowRenames {
+		opts = append(opts, connector.WithFollowRenames())
+	}
+	if c.Collation != nil {
+		opts = append(opts, connector.WithCollation(c.Collation.Locale, c.Collation.Strength))
+	}
+	for _, route := range c.Routes {
+		opts = append(opts, connector.WithRoute(route.StreamName, route.When, route.SubjectTemplate))
+	}
+	if c.Filter != nil {
+		opts = append(opts, connector.WithFilter(c.Filter.filter()))
+	}
+	if len(c.EventTypes) > 0 {
+		opts = append(opts, connector.WithEventTypes(c.EventTypes))
+	}
+	if len(c.Enrich) > 0 {
+		opts = append(opts, connector.WithEnrichment(c.Enrich...))
+	}
+	if len(c.Reshape) > 0 {
+		opts = append(opts, connector.WithReshape(c.Reshape...))
+	}
+	if c.StreamLimits != nil {
+		opts = append(opts, connector.WithStreamLimits(&connector.StreamLimits{
+			MaxMsgsPerSubject: c.StreamLimits.MaxMsgsPerSubject,
+			MaxMsgs:           c.StreamLimits.MaxMsgs,
+			MaxBytes:          c.StreamLimits.MaxBytes,
+			MaxAge:            c.StreamLimits.MaxAge,
+		}))
+	}
+	if c.Envelope != nil {
+		opts = append(opts, connector.WithEnvelope(&connector.Envelope{MissingBefore: c.Envelope.MissingBefore}))
+	}
+	if c.Flatten != nil {
+		opts = append(opts, connector.WithFlatten(&connector.Flatten{
+			Separator: c.Flatten.Separator,
+			Arrays:    c.Flatten.Arrays,
+		}))
+	}
+	if c.TypeConversions != nil {
+		opts = append(opts, connector.WithTypeConversions(&connector.TypeConversions{
+			ObjectId: c.TypeConversions.ObjectId,
+			Date:     c.TypeConversions.Date,
+			Decimal:  c.TypeConversions.Decimal,
+			Long:     c.TypeConversions.Long,
+		}))
+	}
+	if c.SchemaVersion != 0 {
+		opts = append(opts, connector.WithSchemaVersion(c.SchemaVersion))
+	}
+	if c.Canary != nil {
+		opts = append(opts, connector.WithCanary(&connector.Canary{
+			Percent:       c.Canary.Percent,
+			Subject:       c.Canary.Subject,
+			Encoder:       c.Canary.Encoder,
+			ExcludeFields: c.Canary.ExcludeFields,
+		}))
+	}
+	if c.Snapshot != nil {
+		opts = append(opts, connector.WithSnapshot(c.Snapshot.Schedule, c.Snapshot.Filter))
+	}
+	if c.ExpectStream != nil && *c.ExpectStream {
+		opts = append(opts, connector.WithExpectStream())
+	}
+	if c.StrictOrdering != nil && *c.StrictOrdering {
+		opts = append(opts, connector.WithStrictOrdering())
+	}
+	if c.IdempotentResume != nil && *c.IdempotentResume {
+		opts = append(opts, connector.WithIdempotentResume())
+	}
+	if c.Pipeline != nil {
+		opts = append(opts, connector.WithCollectionPipeline(c.Pipeline.options()...))
+	}
+	if c.TokensCollCapped != nil && c.TokensCollSizeInBytes != nil && *c.TokensCollCapped {
+		opts = append(opts, connector.WithTokensCollCapped(*c.TokensCollSizeInBytes))
+	}
+	if c.TokensCollTtl != 0 {
+		opts = append(opts, connector.WithTokensCollTtl(c.TokensCollTtl))
+	}
+	return opts
+}
+
+func (f *Filter) filter() *connector.Filter {
+	filter := &connector.Filter{
+		Field:    f.Field,
+		Operator: f.Operator,
+		Value:    f.Value,
+		Values:   f.Values,
+	}
+	for i := range f.And {
+		filter.And = append(filter.And, *f.And[i].filter())
+	}
+	for i := range f.Or {
+		filter.Or = append(filter.Or, *f.Or[i].filter())
+	}
+	return filter
+}
+
+func (p *Pipeline) options() []connector.PipelineOption {
+	opts := []connector.PipelineOption{connector.WithEncoder(p.Encoder)}
+	if p.PublishWorkers != 0 {
+		opts = append(opts, connector.WithPublishWorkers(p.PublishWorkers))
+	}
+	if p.BatchSize != 0 {
+		opts = append(opts, connector.WithBatchSize(p.BatchSize))
+	}
+	if p.RateLimit != 0 {
+		opts = append(opts, connector.WithRateLimit(p.RateLimit))
+	}
+	return opts
+}
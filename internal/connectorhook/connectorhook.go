@@ -0,0 +1,15 @@
+// Package connectorhook lets the connectortest package inject its fakes into the Connector, without exposing the
+// internal client interfaces in the options of the connector package.
+package connectorhook
+
+import (
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+// MongoClient and NatsClient return the connector.Option setting the MongoDB and NATS client implementations of the
+// Connector. They are set by the connector package once it is initialized.
+var (
+	MongoClient func(mongoClient mongo.Client) any
+	NatsClient  func(natsClient nats.Client) any
+)
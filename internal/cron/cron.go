@@ -0,0 +1,169 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid cron expression")
+
+// maxSearch bounds the search of the next activation of a schedule, e.g. of one that never matches, like 0 0 30 2 *.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// macros are the predefined schedules.
+var macros = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// field holds the bounds of a field of cron expressions.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6},
+}
+
+// Schedule is a parsed cron expression, made of the minute, hour, day of month, month and day of week fields, e.g.
+// 0 3 * * 1-5, or of a macro, e.g. @daily. Schedules are evaluated in UTC.
+type Schedule struct {
+	text                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// Parse parses the given cron expression. Each field is either *, a value, a range (1-5), a list (1,15) or a step
+// (*/15, 0-30/10), and the day of week is 0 (Sunday) to 6, or 7 for Sunday as well.
+func Parse(text string) (*Schedule, error) {
+	expr := strings.TrimSpace(text)
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w: %q must have %d fields", ErrInvalidSchedule, text, len(fields))
+	}
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		f := fields[i]
+		if i == 4 {
+			f.max = 7 // Sunday is either 0 or 7
+		}
+		var err error
+		if bits[i], err = parseField(part, f); err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidSchedule, text, err)
+		}
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	return &Schedule{
+		text:          text,
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: parts[2] != "*",
+		dowRestricted: parts[4] != "*",
+	}, nil
+}
+
+// String returns the cron expression of the schedule.
+func (s *Schedule) String() string {
+	return s.text
+}
+
+// Next returns the first activation of the schedule strictly after the given time, in UTC, or the zero time if there
+// is none within the next five years.
+func (s *Schedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(maxSearch)
+	for t.Before(end) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !has(s.hour, t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches returns true if the day of the given time matches the schedule. As in cron, if both the day of month and
+// the day of week are restricted, either one of them must match.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+// parseField returns the values of the given field, as a bit set.
+func parseField(part string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(part, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q of %v", stepExpr, f.name)
+			}
+		}
+		from, to := f.min, f.max
+		if rangeExpr != "*" {
+			low, high, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if from, err = parseValue(low, f); err != nil {
+				return 0, err
+			}
+			to = from
+			if isRange {
+				if to, err = parseValue(high, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				to = f.max
+			}
+			if from > to {
+				return 0, fmt.Errorf("invalid range %q of %v", rangeExpr, f.name)
+			}
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%v must be between %d and %d, got %q", f.name, f.min, f.max, s)
+	}
+	return v, nil
+}
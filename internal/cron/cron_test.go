@@ -0,0 +1,67 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "should parse wildcards", expr: "* * * * *"},
+		{name: "should parse values, ranges, lists and steps", expr: "*/15 0-6/2 1,15 1-12 1-5"},
+		{name: "should parse macros", expr: "@daily"},
+		{name: "should accept 7 as Sunday", expr: "0 0 * * 7"},
+		{name: "should return error if a field is missing", expr: "0 3 * *", wantErr: true},
+		{name: "should return error if a value is out of bounds", expr: "60 * * * *", wantErr: true},
+		{name: "should return error if a range is reversed", expr: "0 6-2 * * *", wantErr: true},
+		{name: "should return error if a step is invalid", expr: "*/0 * * * *", wantErr: true},
+		{name: "should return error if a value is not a number", expr: "0 0 * JAN *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidSchedule)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expr, schedule.String())
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// Wednesday
+	after := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{name: "every minute", expr: "* * * * *", want: time.Date(2024, 5, 1, 12, 31, 0, 0, time.UTC)},
+		{name: "every 15 minutes", expr: "*/15 * * * *", want: time.Date(2024, 5, 1, 12, 45, 0, 0, time.UTC)},
+		{name: "daily at 3am", expr: "0 3 * * *", want: time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC)},
+		{name: "hourly", expr: "@hourly", want: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{name: "on Sundays", expr: "0 0 * * 7", want: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "monthly", expr: "@monthly", want: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "on the 15th or on Mondays", expr: "0 0 15 * 1", want: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+		{name: "on leap days", expr: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "never", expr: "0 0 30 2 *", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.want, schedule.Next(after))
+		})
+	}
+}
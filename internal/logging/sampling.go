@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SamplingHandler is a slog.Handler sampling the debug records, e.g. the ones logged for each change event, so that
+// debug logging remains usable at high throughput. Within each interval, the first records of each message are
+// handled, then only one out of every thereafter records. More severe records are always handled.
+type SamplingHandler struct {
+	handler slog.Handler
+	sampler *sampler
+}
+
+var _ slog.Handler = &SamplingHandler{}
+
+// sampler counts the debug records of each message within the current interval. It is shared by the handlers derived
+// from the same SamplingHandler, so that the records are sampled regardless of their attributes.
+type sampler struct {
+	first      int
+	thereafter int
+	interval   time.Duration
+
+	mu     sync.Mutex
+	counts map[string]*count
+}
+
+type count struct {
+	n       int
+	resetAt time.Time
+}
+
+// NewSamplingHandler creates a new SamplingHandler, handing the sampled records to the given handler. Within each
+// interval, the first records of each message are handled, then one out of every thereafter records, or none if it is
+// zero.
+func NewSamplingHandler(handler slog.Handler, first, thereafter int, interval time.Duration) *SamplingHandler {
+	return &SamplingHandler{
+		handler: handler,
+		sampler: &sampler{
+			first:      first,
+			thereafter: thereafter,
+			interval:   interval,
+			counts:     make(map[string]*count),
+		},
+	}
+}
+
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level <= slog.LevelDebug && !h.sampler.sample(record.Message, record.Time) {
+		return nil
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{handler: h.handler.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{handler: h.handler.WithGroup(name), sampler: h.sampler}
+}
+
+// sample returns true if the record of the given message, logged at the given time, is sampled.
+func (s *sampler) sample(msg string, t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counts[msg]
+	if !ok || !t.Before(c.resetAt) {
+		c = &count{resetAt: t.Add(s.interval)}
+		s.counts[msg] = c
+	}
+	c.n++
+	if c.n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (c.n-s.first)%s.thereafter == 0
+}
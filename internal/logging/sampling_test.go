@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplingHandler_Handle(t *testing.T) {
+	newLogger := func(first, thereafter int, interval time.Duration) (*slog.Logger, *bytes.Buffer) {
+		buf := &bytes.Buffer{}
+		handler := slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+		return slog.New(NewSamplingHandler(handler, first, thereafter, interval)), buf
+	}
+	lines := func(buf *bytes.Buffer, msg string) int {
+		return strings.Count(buf.String(), "msg=\""+msg+"\"")
+	}
+
+	t.Run("should handle the first debug records then one out of every thereafter records", func(t *testing.T) {
+		logger, buf := newLogger(2, 3, time.Hour)
+
+		for i := 0; i < 10; i++ {
+			logger.Debug("received change event", "i", i)
+		}
+
+		require.Equal(t, 4, lines(buf, "received change event")) // the 1st, 2nd, 5th and 8th
+		require.Contains(t, buf.String(), "i=4")
+		require.Contains(t, buf.String(), "i=7")
+	})
+	t.Run("should sample each message separately", func(t *testing.T) {
+		logger, buf := newLogger(1, 0, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			logger.Debug("received change event")
+			logger.With("collName", "orders").Debug("published message")
+		}
+
+		require.Equal(t, 1, lines(buf, "received change event"))
+		require.Equal(t, 1, lines(buf, "published message"))
+	})
+	t.Run("should handle the first debug records again once the interval elapsed", func(t *testing.T) {
+		logger, buf := newLogger(1, 0, 10*time.Millisecond)
+
+		logger.Debug("received change event")
+		logger.Debug("received change event")
+		time.Sleep(20 * time.Millisecond)
+		logger.Debug("received change event")
+
+		require.Equal(t, 2, lines(buf, "received change event"))
+	})
+	t.Run("should always handle the records more severe than debug", func(t *testing.T) {
+		logger, buf := newLogger(0, 0, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			logger.Info("watching mongodb collection")
+			logger.Debug("received change event")
+		}
+
+		require.Equal(t, 3, lines(buf, "watching mongodb collection"))
+		require.Equal(t, 0, lines(buf, "received change event"))
+	})
+}
+
+func TestSamplingHandler_Enabled(t *testing.T) {
+	handler := NewSamplingHandler(slog.NewTextHandler(&bytes.Buffer{}, nil), 1, 1, time.Second)
+
+	require.False(t, handler.Enabled(context.Background(), slog.LevelDebug))
+	require.True(t, handler.Enabled(context.Background(), slog.LevelInfo))
+}
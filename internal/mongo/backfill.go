@@ -0,0 +1,213 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/time/rate"
+)
+
+// backfillOperationType is the operation type of the synthetic change events published by backfills, so that consumers
+// apply them as upserts of the current state of the documents.
+const backfillOperationType = replacOperationType
+
+var ErrInvalidBackfillFilter = errors.New("invalid backfill filter: it must be a query document in extended json")
+
+type BackfillOptions struct {
+	// Id identifies the backfill. It prefixes the message ids of its change events, so that they are not discarded as
+	// duplicates of the change events of previous backfills.
+	Id string
+	// Filter selects the documents to backfill. If nil, all the documents of the collection are backfilled.
+	Filter bson.Raw
+	// RateLimit is the maximum number of documents backfilled per second. If zero, backfilling is not throttled.
+	RateLimit float64
+	// Collection holds the options the synthetic change events are built and published with, i.e. the ones of the
+	// watched collection.
+	Collection *WatchCollectionOptions
+}
+
+// ParseBackfillFilter parses the given filter, a query document in extended JSON, e.g. {"status":"active"}. An empty
+// filter returns nil, which selects all the documents.
+func ParseBackfillFilter(filter string) (bson.Raw, error) {
+	if filter == "" {
+		return nil, nil
+	}
+	var query bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(filter), false, &query); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackfillFilter, err)
+	}
+	return query, nil
+}
+
+// Backfill reads the documents of the watched collection matching the filter, in _id order, and hands each of them to
+// the change event handler of the collection as a synthetic replace change event. It returns the number of documents
+// that were backfilled.
+func (c *DefaultClient) Backfill(ctx context.Context, opts *BackfillOptions) (int, error) {
+	collOpts := opts.Collection
+	coll := c.mongoClient().Database(collOpts.WatchedDbName).Collection(collOpts.WatchedCollName)
+
+	var filter any = bson.D{}
+	if opts.Filter != nil {
+		filter = opts.Filter
+	}
+	cursor, err := findBackfilled(ctx, coll, filter, collOpts)
+	if err != nil {
+		return 0, fmt.Errorf("could not find documents to backfill: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if opts.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), 1)
+	}
+	routeOpts := RouteOptions(collOpts)
+
+	backfilled := 0
+	for cursor.Next(ctx) {
+		event, err := backfillChangeEvent(opts, routeOpts, cursor.Current, time.Now())
+		if err != nil {
+			return backfilled, err
+		}
+		if event == nil {
+			continue
+		}
+		if err = limiter.Wait(ctx); err != nil {
+			return backfilled, err
+		}
+		if err = collOpts.ChangeEventHandler(ctx, event); err != nil {
+			return backfilled, err
+		}
+		backfilled++
+	}
+	if err = cursor.Err(); err != nil {
+		return backfilled, fmt.Errorf("could not read documents to backfill: %v", err)
+	}
+	c.logger.Info("backfill completed", "dbName", collOpts.WatchedDbName, "collName", collOpts.WatchedCollName,
+		"backfill", opts.Id, "backfilled", backfilled)
+	return backfilled, nil
+}
+
+// findBackfilled returns the cursor of the documents of the given collection matching the given filter, in _id order.
+// If the collection has an enrichment, they are enriched by the same aggregation, so that they are enriched in batches.
+func findBackfilled(ctx context.Context, coll *mongo.Collection, filter any,
+	collOpts *WatchCollectionOptions) (*mongo.Cursor, error) {
+	var collation *options.Collation
+	if collOpts.Collation != nil {
+		collation = &options.Collation{Locale: collOpts.Collation.Locale, Strength: collOpts.Collation.Strength}
+	}
+	sort := bson.D{{Key: "_id", Value: 1}}
+	if collOpts.Enrichment != nil {
+		pipeline := append(bson.A{
+			bson.D{{Key: "$match", Value: filter}},
+			bson.D{{Key: "$sort", Value: sort}},
+		}, collOpts.Enrichment.pipeline...)
+		aggregateOpts := options.Aggregate().SetCollation(collation)
+		if collOpts.BatchSize > 0 {
+			aggregateOpts.SetBatchSize(collOpts.BatchSize)
+		}
+		return coll.Aggregate(ctx, pipeline, aggregateOpts)
+	}
+	findOpts := options.Find().SetSort(sort).SetCollation(collation)
+	if collOpts.BatchSize > 0 {
+		findOpts.SetBatchSize(collOpts.BatchSize)
+	}
+	return coll.Find(ctx, filter, findOpts)
+}
+
+// backfillChangeEvent returns the synthetic change event of the given document, built like the change events of its
+// collection at the given time, or nil if it is dropped by the filter or the oversized policy of the collection.
+func backfillChangeEvent(opts *BackfillOptions, routeOpts []*WatchCollectionOptions, doc bson.Raw,
+	now time.Time) (*ChangeEvent, error) {
+	collOpts := opts.Collection
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.D{{Key: "backfill", Value: opts.Id}}},
+		{Key: "operationType", Value: backfillOperationType},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: uint32(now.Unix())}},
+		{Key: "ns", Value: bson.D{
+			{Key: "db", Value: collOpts.WatchedDbName},
+			{Key: "coll", Value: collOpts.WatchedCollName},
+		}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: doc.Lookup("_id")}}},
+		{Key: "fullDocument", Value: doc},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal backfill change event: %v", err)
+	}
+	event, err := buildChangeEvent(collOpts, routeOpts, changeEvent, backfillOperationType, "")
+	if err != nil || event == nil {
+		return nil, err
+	}
+	event.MsgId = opts.Id + "-" + documentId(changeEvent)
+	event.Backfill = opts.Id
+	return event, nil
+}
+
+// buildChangeEvent returns the given change event of a document, with the given operation type and resume token, built
+// like the change events of its collection, but without message id, or nil if it is dropped by the filter or the
+// oversized policy of the collection.
+func buildChangeEvent(collOpts *WatchCollectionOptions, routeOpts []*WatchCollectionOptions, changeEvent bson.Raw,
+	operationType, token string) (*ChangeEvent, error) {
+	var err error
+	if len(collOpts.ExcludeFields) > 0 {
+		if changeEvent, err = excludeFields(changeEvent, collOpts.ExcludeFields); err != nil {
+			return nil, err
+		}
+	}
+	if filtered(collOpts, operationType, changeEvent) {
+		return nil, nil
+	}
+	eventType := eventTypeOf(collOpts, operationType)
+	if eventType != "" {
+		if changeEvent, err = withEventType(changeEvent, eventType); err != nil {
+			return nil, err
+		}
+	}
+
+	eventOpts := route(collOpts, routeOpts, changeEvent)
+	subj, err := subject(eventOpts, operationType, changeEvent)
+	if err != nil {
+		return nil, err
+	}
+	hdrs, err := headers(collOpts, operationType, changeEvent)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeChangeEvent(changeEvent, collOpts)
+	if err != nil {
+		return nil, err
+	}
+	oversized := collOpts.MaxPayload > 0 && int64(len(data)) > collOpts.MaxPayload
+	if oversized {
+		switch collOpts.OversizedPolicy {
+		case DropOversizedPolicy:
+			return nil, nil
+		case TruncateOversizedPolicy:
+			if data, err = encodeTruncated(changeEvent, collOpts); err != nil {
+				return nil, err
+			}
+			oversized = int64(len(data)) > collOpts.MaxPayload
+		}
+	}
+
+	return &ChangeEvent{
+		Subj:          subj,
+		DocumentKey:   documentKey(changeEvent),
+		Bookkeeping:   bookkeepingOf(collOpts, changeEvent, token),
+		Data:          data,
+		OperationType: operationType,
+		EventType:     eventType,
+		Tenant:        tenant(changeEvent, collOpts.TenantField),
+		Time:          eventTime(changeEvent),
+		Oversized:     oversized,
+		Headers:       hdrs,
+		Encrypted:     collOpts.EncryptedPassthrough && hasCiphertext(changeEvent),
+		StreamName:    eventOpts.StreamName,
+		SchemaVersion: collOpts.SchemaVersion.Current(),
+	}, nil
+}
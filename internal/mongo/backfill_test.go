@@ -0,0 +1,95 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseBackfillFilter(t *testing.T) {
+	t.Run("should parse query documents in extended json", func(t *testing.T) {
+		query, err := ParseBackfillFilter(`{"status":"active","total":{"$gt":{"$numberInt":"100"}}}`)
+
+		require.NoError(t, err)
+		require.Equal(t, "active", query.Lookup("status").StringValue())
+		require.Equal(t, int32(100), query.Lookup("total", "$gt").Int32())
+	})
+	t.Run("should return nil if the filter is empty", func(t *testing.T) {
+		query, err := ParseBackfillFilter("")
+
+		require.NoError(t, err)
+		require.Nil(t, query)
+	})
+	t.Run("should return error if the filter is not a document", func(t *testing.T) {
+		_, err := ParseBackfillFilter(`["status"]`)
+
+		require.ErrorIs(t, err, ErrInvalidBackfillFilter)
+	})
+}
+
+func Test_backfillChangeEvent(t *testing.T) {
+	doc, _ := bson.Marshal(bson.D{{Key: "_id", Value: "order-1"}, {Key: "status", Value: "active"},
+		{Key: "email", Value: "jane@example.com"}})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should build a replace change event of the document", func(t *testing.T) {
+		opts := &BackfillOptions{Id: "backfill-20240501T120000Z", Collection: &WatchCollectionOptions{
+			WatchedDbName: "shop", WatchedCollName: "orders", StreamName: "ORDERS", ExcludeFields: []string{"email"},
+		}}
+
+		event, err := backfillChangeEvent(opts, nil, doc, now)
+
+		require.NoError(t, err)
+		require.Equal(t, "ORDERS.replace", event.Subj)
+		require.Equal(t, "backfill-20240501T120000Z-order-1", event.MsgId)
+		require.Equal(t, `{"_id":"order-1"}`, event.DocumentKey)
+		require.Equal(t, "replace", event.OperationType)
+		require.Equal(t, now, event.Time)
+		require.Equal(t, "backfill-20240501T120000Z", event.Backfill)
+		require.JSONEq(t, `{"_id":{"backfill":"backfill-20240501T120000Z"},"operationType":"replace",
+			"clusterTime":{"$timestamp":{"t":1714564800,"i":0}},"ns":{"db":"shop","coll":"orders"},
+			"documentKey":{"_id":"order-1"},"fullDocument":{"_id":"order-1","status":"active"}}`, string(event.Data))
+	})
+	t.Run("should map the operation type to its event type", func(t *testing.T) {
+		opts := &BackfillOptions{Id: "1", Collection: &WatchCollectionOptions{StreamName: "ORDERS",
+			EventTypes: EventTypes{"replace": "order.upserted"}}}
+
+		event, err := backfillChangeEvent(opts, nil, doc, now)
+
+		require.NoError(t, err)
+		require.Equal(t, "ORDERS.order.upserted", event.Subj)
+		require.Equal(t, "order.upserted", event.EventType)
+		require.Contains(t, string(event.Data), `"operationType":"replace","eventType":"order.upserted"`)
+	})
+	t.Run("should route the change event", func(t *testing.T) {
+		collOpts := &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "orders", StreamName: "ORDERS",
+			Routes: []Route{{When: map[string]string{"status": "active"}, StreamName: "ACTIVE_ORDERS"}}}
+		opts := &BackfillOptions{Id: "1", Collection: collOpts}
+
+		event, err := backfillChangeEvent(opts, RouteOptions(collOpts), doc, now)
+
+		require.NoError(t, err)
+		require.Equal(t, "ACTIVE_ORDERS.replace", event.Subj)
+		require.Equal(t, "ACTIVE_ORDERS", event.StreamName)
+	})
+	t.Run("should drop documents not matching the filter of the collection", func(t *testing.T) {
+		opts := &BackfillOptions{Id: "1", Collection: &WatchCollectionOptions{StreamName: "ORDERS",
+			Filter: &Filter{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "cancelled"}}}
+
+		event, err := backfillChangeEvent(opts, nil, doc, now)
+
+		require.NoError(t, err)
+		require.Nil(t, event)
+	})
+	t.Run("should drop oversized documents if the oversized policy is drop", func(t *testing.T) {
+		opts := &BackfillOptions{Id: "1", Collection: &WatchCollectionOptions{StreamName: "ORDERS", MaxPayload: 10,
+			OversizedPolicy: DropOversizedPolicy}}
+
+		event, err := backfillChangeEvent(opts, nil, doc, now)
+
+		require.NoError(t, err)
+		require.Nil(t, event)
+	})
+}
@@ -0,0 +1,98 @@
+package mongo
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Canary publishes, for a percentage of the change events, their encoding with another configuration to a shadow
+// subject, in addition to publishing them as usual.
+type Canary struct {
+	// Percent is the percentage of change events shadowed, from 0 to 100. Change events are sampled by document, so
+	// that all the change events of a shadowed document are shadowed.
+	Percent float64
+	// Subject prefixes the subjects of the shadow change events.
+	Subject string
+	// Encoder encodes the shadow change events. If empty, the encoder of the collection is used.
+	Encoder Encoder
+	// ExcludeFields are removed from the documents of the shadow change events, in addition to the excluded fields of
+	// the collection.
+	ExcludeFields []string
+}
+
+// Shadow holds the encoding of a change event with the canary configuration of its collection.
+type Shadow struct {
+	Subj string
+	Data []byte
+	// ContentType is the media type of the encoding of the shadow change event.
+	ContentType string
+}
+
+// sampled returns true if the given change event is shadowed, based on the hash of its document id, or of its resume
+// token if it has no document key.
+func (c *Canary) sampled(changeEvent bson.Raw, resumeToken string) bool {
+	key := documentId(changeEvent)
+	if key == "" {
+		key = resumeToken
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return float64(h.Sum64()%10000) < c.Percent*100
+}
+
+// shadow returns the shadow of the given change event, published to the given subject, if the change event is sampled.
+func (c *Canary) shadow(opts *WatchCollectionOptions, subj, resumeToken string, changeEvent bson.Raw) (*Shadow, error) {
+	if !c.sampled(changeEvent, resumeToken) {
+		return nil, nil
+	}
+	if len(c.ExcludeFields) > 0 {
+		var err error
+		if changeEvent, err = excludeFields(changeEvent, c.ExcludeFields); err != nil {
+			return nil, err
+		}
+	}
+	encoder := c.Encoder
+	if encoder == "" {
+		encoder = opts.Encoder
+	}
+	data, err := encode(changeEvent, encoder, opts.JsonFlavor)
+	if err != nil {
+		return nil, err
+	}
+	return &Shadow{Subj: c.Subject + "." + subj, Data: data, ContentType: encoder.ContentType()}, nil
+}
+
+// excludeFields returns a copy of the given change event without the given fields in its documents, as removed by the
+// change stream pipeline.
+func excludeFields(changeEvent bson.Raw, fields []string) (bson.Raw, error) {
+	var d bson.D
+	if err := bson.Unmarshal(changeEvent, &d); err != nil {
+		return nil, fmt.Errorf("could not unmarshal change event: %v", err)
+	}
+	for _, parent := range excludedFieldsParents {
+		for _, field := range fields {
+			d = removePath(d, strings.Split(parent+"."+field, "."))
+		}
+	}
+	return bson.Marshal(d)
+}
+
+// removePath removes the field at the given path from the given document, if it exists.
+func removePath(d bson.D, path []string) bson.D {
+	for i := range d {
+		if d[i].Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return append(d[:i], d[i+1:]...)
+		}
+		if nested, ok := d[i].Value.(bson.D); ok {
+			d[i].Value = removePath(nested, path[1:])
+		}
+		return d
+	}
+	return d
+}
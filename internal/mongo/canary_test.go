@@ -0,0 +1,70 @@
+package mongo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCanary_sampled(t *testing.T) {
+	changeEvent := func(id int) bson.Raw {
+		raw, _ := bson.Marshal(bson.M{"documentKey": bson.M{"_id": fmt.Sprint(id)}})
+		return raw
+	}
+
+	none, all, half := &Canary{Percent: 0}, &Canary{Percent: 100}, &Canary{Percent: 50}
+	sampled := 0
+	for id := 0; id < 1000; id++ {
+		require.False(t, none.sampled(changeEvent(id), "token"))
+		require.True(t, all.sampled(changeEvent(id), "token"))
+		if half.sampled(changeEvent(id), "token") {
+			sampled++
+			require.True(t, half.sampled(changeEvent(id), "other-token"), "documents should be sampled consistently")
+		}
+	}
+	require.InDelta(t, 500, sampled, 100)
+}
+
+func TestCanary_shadow(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "1"}}},
+		{Key: "fullDocument", Value: bson.D{
+			{Key: "_id", Value: "1"},
+			{Key: "email", Value: "jane@example.com"},
+			{Key: "address", Value: bson.D{{Key: "city", Value: "Rome"}, {Key: "street", Value: "Via Roma"}}},
+		}},
+	})
+	opts := &WatchCollectionOptions{Encoder: JsonEncoder}
+
+	t.Run("should encode the change event without the excluded fields", func(t *testing.T) {
+		canary := &Canary{Percent: 100, Subject: "SHADOW", ExcludeFields: []string{"email", "address.street"}}
+
+		shadow, err := canary.shadow(opts, "COLL.insert", "token", changeEvent)
+
+		require.NoError(t, err)
+		require.Equal(t, "SHADOW.COLL.insert", shadow.Subj)
+		require.Equal(t, "application/json", shadow.ContentType)
+		require.JSONEq(t, `{"operationType":"insert","documentKey":{"_id":"1"},
+			"fullDocument":{"_id":"1","address":{"city":"Rome"}}}`, string(shadow.Data))
+	})
+	t.Run("should encode the change event with the canary encoder", func(t *testing.T) {
+		canary := &Canary{Percent: 100, Subject: "SHADOW", Encoder: BsonEncoder}
+
+		shadow, err := canary.shadow(opts, "COLL.insert", "token", changeEvent)
+
+		require.NoError(t, err)
+		require.Equal(t, []byte(changeEvent), shadow.Data)
+		require.Equal(t, "application/bson", shadow.ContentType)
+	})
+	t.Run("should not shadow change events which are not sampled", func(t *testing.T) {
+		canary := &Canary{Percent: 0, Subject: "SHADOW"}
+
+		shadow, err := canary.shadow(opts, "COLL.insert", "token", changeEvent)
+
+		require.NoError(t, err)
+		require.Nil(t, shadow)
+	})
+}
@@ -7,14 +7,17 @@ import (
 	"io"
 	"log/slog"
 	"net/url"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
 	"github.com/context-labs/mongodb-nats-connector/internal/server"
 )
 
@@ -22,11 +25,20 @@ const (
 	defaultName = "mongo"
 )
 
+// tokenSavePolicy is the default retry policy of the resume token saves failing with transient errors.
+var tokenSavePolicy = retry.Policy{
+	InitialInterval: 100 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+	Multiplier:      2,
+	MaxAttempts:     5,
+}
+
 const (
 	insertOperationType     = "insert"
 	updateOperationType     = "update"
 	replacOperationType     = "replace"
 	deleteOperationType     = "delete"
+	renameOperationType     = "rename"
 	invalidateOperationType = "invalidate"
 )
 
@@ -35,6 +47,7 @@ var publishableOperationTypes = map[string]struct{}{
 	updateOperationType: {},
 	replacOperationType: {},
 	deleteOperationType: {},
+	renameOperationType: {},
 }
 
 type Client interface {
@@ -43,17 +56,92 @@ type Client interface {
 
 	CreateCollection(ctx context.Context, opts *CreateCollectionOptions) error
 	WatchCollection(ctx context.Context, opts *WatchCollectionOptions) error
+	Backfill(ctx context.Context, opts *BackfillOptions) (int, error)
+	Redrive(ctx context.Context, opts *WatchCollectionOptions) (int, error)
+	LastResumeToken(ctx context.Context, opts *ResumeTokensOptions) (string, error)
+	RestoreResumeToken(ctx context.Context, opts *ResumeTokensOptions, token string) error
+	InspectResumeToken(ctx context.Context, opts *ResumeTokensOptions) (*ResumeTokenInspection, error)
+	RepairResumeTokens(ctx context.Context, opts *ResumeTokensOptions, token string) (int, error)
 }
 
 type CreateCollectionOptions struct {
-	DbName                       string
-	CollName                     string
-	Capped                       bool
-	SizeInBytes                  int64
+	DbName      string
+	CollName    string
+	Capped      bool
+	SizeInBytes int64
+	// Expiring creates a TTL index deleting the documents of the collection once their expiresAt field is past.
+	// If the collection exists and is capped, it is migrated to an uncapped one first.
+	Expiring                     bool
 	ChangeStreamPreAndPostImages bool
 }
 
-type ChangeEventHandler func(ctx context.Context, subj, msgId string, data []byte) error
+// ChangeEvent holds a change event ready to be published.
+type ChangeEvent struct {
+	Subj          string
+	MsgId         string
+	Data          []byte
+	OperationType string
+	// EventType is the domain event type the operation type of the change event is mapped to, if its collection maps
+	// operation types to event types.
+	EventType string
+	// DocumentKey is the document key of the change event, i.e. the _id and the shard key fields of its document, in
+	// relaxed extended JSON, or empty if it has none, e.g. for schema changes.
+	DocumentKey string
+	// Bookkeeping holds the bookkeeping fields removed from the payload of the change event, if its collection publishes
+	// slim payloads, to be published in its headers.
+	Bookkeeping *Bookkeeping
+	// Tenant is the tenant the change event belongs to, if tenant routing is enabled.
+	Tenant string
+	// Time is the cluster time of the change event.
+	Time time.Time
+	// SchemaChange is true if the change event describes a structural change, published to the schema changes stream.
+	SchemaChange bool
+	// Oversized is true if the encoded change event exceeds the maximum payload, and it must be handled according to
+	// the oversized policy.
+	Oversized bool
+	// Partial is true if images of the change event required by the missing images policy are unavailable, and it is
+	// published without them.
+	Partial bool
+	// DecodeError is set if the change event could not be encoded, or its images are unavailable, in which case Data
+	// holds its raw BSON, which must be published to the dead letter subject.
+	DecodeError error
+	// Headers are the headers of the change event, computed by the header templates.
+	Headers map[string]string
+	// GridFSFile is the file of the change event, if it is a file-level event.
+	GridFSFile *GridFSFile
+	// Encrypted is true if the change event holds encrypted values, and the collection passes their ciphertext
+	// through.
+	Encrypted bool
+	// StreamName is the stream the change event is routed to. If empty, it is the stream of its collection.
+	StreamName string
+	// Shadow is the encoding of the change event with the canary configuration of its collection, if it is sampled.
+	Shadow *Shadow
+	// SchemaVersion is the schema version the subject of the change event is prefixed with, or 0 if the collection
+	// has none.
+	SchemaVersion int
+	// Backfill is the id of the backfill the change event is synthesized by, if it does not come from the change
+	// stream.
+	Backfill string
+	// Session is the logical session the change event originates from, if it was made by a transaction or a retryable
+	// write.
+	Session *Session
+	// Transaction identifies the transaction the change event belongs to, if its collection tags or batches the
+	// change events of transactions.
+	Transaction *Transaction
+	// Ack is set by the change event handler once the change event is acked by JetStream, if its collection resumes
+	// idempotently.
+	Ack *Ack
+}
+
+// Collation holds the language-specific rules used to compare strings.
+type Collation struct {
+	Locale   string
+	Strength int
+}
+
+type ChangeEventHandler func(ctx context.Context, event *ChangeEvent) error
+
+type WatermarkHandler func(clusterTime primitive.Timestamp)
 
 type WatchCollectionOptions struct {
 	WatchedDbName          string
@@ -61,8 +149,108 @@ type WatchCollectionOptions struct {
 	ResumeTokensDbName     string
 	ResumeTokensCollName   string
 	ResumeTokensCollCapped bool
+	ResumeTokensCollTtl    time.Duration
 	StreamName             string
-	ChangeEventHandler     ChangeEventHandler
+	NamespaceSubjects      bool
+	Partitions             int
+	TimeBucket             TimeBucket
+	StallTimeout           time.Duration
+	MsgIdStrategy          MsgIdStrategy
+	MsgIdField             string
+	PublishWorkers         int
+	BatchSize              int32
+	RateLimit              float64
+	Encoder                Encoder
+	TenantField            string
+	// JsonFlavor is the flavor of extended JSON of the change events encoded as JSON. If empty, they are encoded as
+	// relaxed extended JSON.
+	JsonFlavor JsonFlavor
+	// Collation is the collation used by the change stream. If nil, the simple binary comparison is used.
+	Collation *Collation
+	// ExcludeFields are the fields of the documents removed from the change events by MongoDB, before they are sent.
+	ExcludeFields []string
+	// SchemaChangesStreamName is the stream the schema changes of the collection are published to. If empty, schema
+	// changes are not published.
+	SchemaChangesStreamName string
+	// GridFS publishes file-level events in place of the change events of the watched collection, which must be the
+	// files collection of a GridFS bucket.
+	GridFS bool
+	// GridFSObjectBucket is the object store bucket the content of the files is copied to. If empty, it is not copied.
+	GridFSObjectBucket string
+	// EncryptedPassthrough marks the change events holding encrypted values the client did not decrypt.
+	EncryptedPassthrough bool
+	// SplitLargeEvents makes MongoDB split the change events exceeding the maximum BSON size into fragments, which are
+	// reassembled before being published.
+	SplitLargeEvents bool
+	// FollowRenames makes the watcher continue watching the collection under its new name once it is renamed.
+	FollowRenames bool
+	// MaxPayload is the maximum size of the encoded change events. If zero, change events are never oversized.
+	MaxPayload        int64
+	OversizedPolicy   OversizedPolicy
+	DecodeErrorPolicy DecodeErrorPolicy
+	// ErrorPolicies maps the transform and token save errors to the actions taken once they occur, while the decode
+	// errors are handled according to DecodeErrorPolicy.
+	ErrorPolicies ErrorPolicies
+	// QuarantineCollName is the collection of the resume tokens database storing the change events which cannot be
+	// published. If empty, they stop the watcher.
+	QuarantineCollName string
+	// StrictOrdering stops the watcher once a change event is observed or published before a change event with a later
+	// cluster time, instead of only reporting it.
+	StrictOrdering bool
+	// MaxEventAge is the maximum age of the change events, based on their cluster time. Older change events are skipped
+	// and a gap marker is published in their place. If zero, change events are never skipped.
+	MaxEventAge time.Duration
+	// SubjectTemplate computes the subjects of the change events, after the stream name. If nil, subjects are built
+	// from the stream name, the namespace, the operation type, the partition and the time bucket.
+	SubjectTemplate *Template
+	// HeaderTemplates compute the headers added to each change event, by header name.
+	HeaderTemplates map[string]*Template
+	// SchemaVersion prefixes the subjects of the change events with their schema version. If nil, they are not prefixed.
+	SchemaVersion *SchemaVersion
+	// Routes route the change events whose document matches their conditions to other streams, evaluated in order.
+	// Change events that match none of them are published to the stream of the collection.
+	Routes []Route
+	// Filter drops the change events of documents which do not match it. If nil, no change events are dropped.
+	Filter *Filter
+	// EventTypes maps the operation types of the change events of documents to the event types used in their subjects,
+	// payload and headers. If empty, the operation types are used.
+	EventTypes EventTypes
+	// Patch replaces the full documents of the updates and replacements by the patch turning their pre-image into their
+	// post-image, in its format. If empty, the full documents are published.
+	Patch PatchFormat
+	// Envelope moves the images of the documents of the updates and replacements to the before and after fields of the
+	// change events. If nil, they are published in the fields of MongoDB.
+	Envelope *Envelope
+	// MissingImages tells what happens to the updates, replacements and deletes whose pre-image or post-image the server
+	// cannot supply. If empty, the images are not required, and the change events are published as is.
+	MissingImages MissingImagesPolicy
+	// PayloadMode tells which fields of the change events are published in their payload. If empty, all of them are.
+	PayloadMode PayloadMode
+	// Reshape renames and moves the fields of the change events before they are encoded. If nil, they are published
+	// with the layout of MongoDB.
+	Reshape *Reshape
+	// Flatten flattens the nested documents of the change events into keys joining the keys of their fields, once they
+	// are reshaped. If nil, they are published with their nested documents.
+	Flatten *Flatten
+	// Conversions tell how the BSON types without JSON equivalent are rendered in the change events encoded as JSON. If
+	// nil, they are rendered as relaxed extended JSON.
+	Conversions *Conversions
+	// Enrichment enriches the full documents of the change events with an aggregation pipeline run by MongoDB, before
+	// they are routed and published. If nil, they are published as is.
+	Enrichment *Enrichment
+	// Canary shadows a percentage of the change events, encoded with another configuration. If nil, no change events
+	// are shadowed.
+	Canary *Canary
+	// TransactionMode tags or batches the change events of multi-document transactions. If empty, they are published
+	// like any other change event.
+	TransactionMode    TransactionMode
+	ChangeEventHandler ChangeEventHandler
+	// PublishedMsgIdsHandler fetches the message ids of the change events published after the last stored resume token,
+	// which are not published again once the watcher resumes. If nil, they are published again.
+	PublishedMsgIdsHandler PublishedMsgIdsHandler
+	// WatermarkHandler is called with the cluster time up to which all the change events of the collection are
+	// processed, once it advances. If nil, it is not reported.
+	WatermarkHandler WatermarkHandler
 }
 
 var _ Client = &DefaultClient{}
@@ -71,12 +259,49 @@ type DefaultClient struct {
 	uri    string
 	name   string
 	logger *slog.Logger
+	// metadataLogging logs the metadata of the change events received, instead of the whole change events.
+	metadataLogging bool
+
+	// retryPolicy overrides the default retry policies of the resume token saves and of the reconnections, if set.
+	retryPolicy *retry.Policy
+
+	// autoEncryptionOpts are set to decrypt the values encrypted with client-side field level encryption.
+	autoEncryptionOpts *options.AutoEncryptionOptions
 
 	onCmdStartedEvent   func(dbName, cmdName string)
 	onCmdSucceededEvent func(dbName, cmdName string, duration time.Duration)
 	onCmdFailedEvent    func(dbName, cmdName string, duration time.Duration)
 
-	client *mongo.Client
+	onTokenSavedEvent       func(dbName, collName string, tokenTime time.Time)
+	onTokenSaveRetriedEvent func(dbName, collName string)
+	onTokenSaveFailedEvent  func(dbName, collName string)
+	onOplogWindowEvent      func(oldest time.Time)
+
+	onChangeEventPublishedEvent func(dbName, collName, operationType string, size int)
+
+	onChangeEventOversizedEvent func(dbName, collName, policy string)
+	onChangeEventMalformedEvent func(dbName, collName, policy string)
+	// onChangeEventQuarantinedEvent is called once a change event which cannot be published is quarantined.
+	onChangeEventQuarantinedEvent func(dbName, collName string)
+	onChangeEventsSkippedEvent    func(dbName, collName string, skipped int)
+	onChangeEventFilteredEvent    func(dbName, collName string)
+	// onChangeEventOutOfOrderEvent is called once a change event is observed or published, according to the given
+	// stage, before a change event with a later cluster time.
+	onChangeEventOutOfOrderEvent func(dbName, collName, stage string)
+
+	onConnOpenedEvent         func()
+	onConnClosedEvent         func()
+	onChangeStreamOpenedEvent func(dbName, collName string)
+	onChangeStreamClosedEvent func(dbName, collName string)
+	onReconnectEvent          func()
+
+	// client is replaced each time it is re-established, clientOpts are used to connect it again.
+	clientMu   sync.RWMutex
+	client     *mongo.Client
+	clientOpts *options.ClientOptions
+
+	oplogMu        sync.Mutex
+	oplogCheckedAt time.Time
 }
 
 func NewDefaultClient(opts ...ClientOption) (*DefaultClient, error) {
@@ -112,13 +337,28 @@ func NewDefaultClient(opts ...ClientOption) (*DefaultClient, error) {
 		},
 	}
 
-	clientOpts := options.Client().ApplyURI(c.uri).SetMonitor(cmdMonitor)
+	poolMonitor := &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch {
+			case e.Type == event.ConnectionCreated && c.onConnOpenedEvent != nil:
+				c.onConnOpenedEvent()
+			case e.Type == event.ConnectionClosed && c.onConnClosedEvent != nil:
+				c.onConnClosedEvent()
+			}
+		},
+	}
+
+	clientOpts := options.Client().ApplyURI(c.uri).SetMonitor(cmdMonitor).SetPoolMonitor(poolMonitor)
+	if c.autoEncryptionOpts != nil {
+		clientOpts.SetAutoEncryptionOptions(c.autoEncryptionOpts)
+	}
 
 	client, err := mongo.Connect(context.Background(), clientOpts)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to mongodb: %v", err)
 	}
 	c.client = client
+	c.clientOpts = clientOpts
 
 	c.logger.Info("connected to mongodb", "uri", parsedUri.Redacted())
 	return c, nil
@@ -129,28 +369,28 @@ func (c *DefaultClient) Name() string {
 }
 
 func (c *DefaultClient) Monitor(ctx context.Context) error {
-	if err := c.client.Ping(ctx, readpref.Primary()); err != nil {
+	if err := c.mongoClient().Ping(ctx, readpref.Primary()); err != nil {
 		return fmt.Errorf("could not reach mongodb: %v", err)
 	}
 	return nil
 }
 
 func (c *DefaultClient) Close() error {
-	if err := c.client.Disconnect(context.Background()); err != nil {
+	if err := c.mongoClient().Disconnect(context.Background()); err != nil {
 		return fmt.Errorf("could not close mongodb client: %v", err)
 	}
 	return nil
 }
 
 func (c *DefaultClient) CreateCollection(ctx context.Context, opts *CreateCollectionOptions) error {
-	db := c.client.Database(opts.DbName)
-	collNames, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: opts.CollName}})
+	db := c.mongoClient().Database(opts.DbName)
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: opts.CollName}})
 	if err != nil {
 		return fmt.Errorf("could not list mongo collection names: %v", err)
 	}
 
 	// creates the collection if it does not exist
-	if len(collNames) == 0 {
+	if len(specs) == 0 {
 		mongoOpt := options.CreateCollection()
 		if opts.Capped {
 			mongoOpt.SetCapped(true).SetSizeInBytes(opts.SizeInBytes)
@@ -159,6 +399,16 @@ func (c *DefaultClient) CreateCollection(ctx context.Context, opts *CreateCollec
 			return fmt.Errorf("could not create mongo collection %v: %v", opts.CollName, err)
 		}
 		c.logger.Debug("created mongodb collection", "collName", opts.CollName, "dbName", opts.DbName)
+	} else if opts.Capped && !isCapped(specs[0]) {
+		// an uncapped collection cannot be converted without losing its documents
+		c.logger.Warn("mongodb collection is not capped, it is kept as is", "collName", opts.CollName,
+			"dbName", opts.DbName)
+	}
+
+	if opts.Expiring {
+		if err = c.provisionExpiry(ctx, db, opts.CollName, len(specs) > 0 && isCapped(specs[0])); err != nil {
+			return err
+		}
 	}
 
 	// enables change stream pre and post images
@@ -175,91 +425,191 @@ func (c *DefaultClient) CreateCollection(ctx context.Context, opts *CreateCollec
 
 func (c *DefaultClient) WatchCollection(ctx context.Context, opts *WatchCollectionOptions) error {
 
-	resumeTokensDb := c.client.Database(opts.ResumeTokensDbName)
-	resumeTokensColl := resumeTokensDb.Collection(opts.ResumeTokensCollName)
+	// startAt is set once the watched collection is renamed, to watch it under its new name right after the rename.
+	var startAt *primitive.Timestamp
+
+	// resumeAfter is set once the cursor is lost while the watcher is idle, to recreate it right after its last batch.
+	var resumeAfter bson.Raw
 
-	watchedDb := c.client.Database(opts.WatchedDbName)
-	watchedColl := watchedDb.Collection(opts.WatchedCollName)
+	// backoff is the time to wait before resuming the watcher once mongodb cannot be reached.
+	backoff := reconnectPolicy.With(c.retryPolicy).NewBackoff()
 
 	resume := true
 	for resume {
-		findOneOpts := options.FindOne()
-		if opts.ResumeTokensCollCapped {
-			// use natural sort for capped collections to get the last inserted resume token
-			findOneOpts.SetSort(bson.D{{Key: "$natural", Value: -1}})
-		} else {
-			// cannot rely on natural sort for uncapped collections, sort by id instead
-			findOneOpts.SetSort(bson.D{{Key: "_id", Value: -1}})
-		}
-
-		lastResumeToken := &resumeToken{}
-		err := resumeTokensColl.FindOne(ctx, bson.D{}, findOneOpts).Decode(lastResumeToken)
-		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		dbName, collName := opts.WatchedDbName, opts.WatchedCollName
+		client := c.mongoClient()
+		resumeTokensColl := client.Database(opts.ResumeTokensDbName).Collection(opts.ResumeTokensCollName)
+		watchedColl := client.Database(dbName).Collection(collName)
+
+		last, err := findLastResumeTokenDoc(ctx, resumeTokensColl, opts.ResumeTokensCollCapped)
+		if err != nil {
+			if c.reconnectAfter(ctx, client, opts, err, backoff) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil // the connector is shutting down
+			}
 			return fmt.Errorf("could not fetch or decode resume token: %v", err)
 		}
 
+		fullDocument, fullDocumentBeforeChange := fullDocumentOptions(opts.MissingImages)
 		changeStreamOpts := options.ChangeStream().
-			SetFullDocument(options.UpdateLookup).
-			SetFullDocumentBeforeChange(options.WhenAvailable)
+			SetFullDocument(fullDocument).
+			SetFullDocumentBeforeChange(fullDocumentBeforeChange)
+
+		if opts.BatchSize > 0 {
+			changeStreamOpts.SetBatchSize(opts.BatchSize)
+		}
+		if opts.SchemaChangesStreamName != "" {
+			changeStreamOpts.SetShowExpandedEvents(true)
+		}
+		if opts.Collation != nil {
+			changeStreamOpts.SetCollation(options.Collation{Locale: opts.Collation.Locale,
+				Strength: opts.Collation.Strength})
+		}
 
-		if lastResumeToken.Value != "" {
-			c.logger.Debug("resuming after token", "token", lastResumeToken.Value)
-			changeStreamOpts.SetResumeAfter(bson.D{{Key: "_data", Value: lastResumeToken.Value}})
+		if startAt != nil {
+			c.logger.Debug("starting at operation time", "operationTime", startAt)
+			changeStreamOpts.SetStartAtOperationTime(startAt)
+		} else if resumeAfter != nil {
+			c.logger.Debug("resuming after post-batch token", "token", resumeAfter.String())
+			changeStreamOpts.SetResumeAfter(resumeAfter)
+		} else if lastResumeToken := last.Value; lastResumeToken != "" {
+			c.logger.Debug("resuming after token", "token", lastResumeToken)
+			c.reportTokenSaved(opts, lastResumeToken)
+			changeStreamOpts.SetResumeAfter(bson.D{{Key: "_data", Value: lastResumeToken}})
 		}
 
-		cs, err := watchedColl.Watch(ctx, mongo.Pipeline{}, changeStreamOpts)
+		cs, err := watchedColl.Watch(ctx, changeStreamPipeline(opts), changeStreamOpts)
 		if err != nil {
-			return fmt.Errorf("could not watch mongo collection %v: %v", watchedColl.Name(), err)
+			if c.reconnectAfter(ctx, client, opts, err, backoff) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil // the connector is shutting down
+			}
+			return fmt.Errorf("could not watch mongo collection %v: %w", watchedColl.Name(), err)
 		}
+		backoff.Reset()
+		resumeAfter = nil
 		c.logger.Info("watching mongodb collection", "collName", watchedColl.Name())
+		if c.onChangeStreamOpenedEvent != nil {
+			c.onChangeStreamOpenedEvent(dbName, collName)
+		}
 
-		for cs.Next(ctx) {
-			currentResumeToken := cs.Current.Lookup("_id", "_data").StringValue()
-			operationType := cs.Current.Lookup("operationType").StringValue()
+		watcher := newChangeStreamWatcher(c, opts, cs, resumeTokensColl, last.Published)
+		resume, err = watcher.watch(ctx)
 
-			json, err := bson.MarshalExtJSON(cs.Current, false, false)
-			if err != nil {
-				return fmt.Errorf("could not marshal mongo change event from bson: %v", err)
+		c.logger.Info("stopped watching mongodb collection", "collName", watchedColl.Name())
+		closeErr := cs.Close(context.Background())
+		if c.onChangeStreamClosedEvent != nil {
+			c.onChangeStreamClosedEvent(dbName, collName)
+		}
+		if closeErr != nil && !isReconnectableError(closeErr) {
+			return fmt.Errorf("could not close change stream: %v", closeErr)
+		}
+		if err != nil {
+			if c.reconnectAfter(ctx, client, opts, err, backoff) {
+				resume = true
+				continue
 			}
-			c.logger.Debug("received change event", "changeEvent", string(json))
-
-			if _, ok := publishableOperationTypes[operationType]; !ok {
-				if operationType == invalidateOperationType {
-					resume = false
-					break
+			if c.recreateAfter(ctx, opts, err, backoff) {
+				if watcher.idle() {
+					resumeAfter = postBatchResumeToken(cs)
 				}
+				resume = true
 				continue
 			}
+			return err
+		}
 
-			subj := fmt.Sprintf("%s.%s", opts.StreamName, operationType)
-			if err = opts.ChangeEventHandler(ctx, subj, currentResumeToken, json); err != nil {
-				// current change event was not published.
-				// current resume token will not be stored.
-				// connector will resume after the previous token.
-				c.logger.Error("could not publish change event", "err", err)
-				break
-			}
+		if renamed := watcher.renamed; !resume && renamed != nil && opts.FollowRenames {
+			c.logger.Info("mongodb collection was renamed, watching it under its new name", "collName", collName,
+				"newDbName", renamed.dbName, "newCollName", renamed.collName)
+			opts.WatchedDbName, opts.WatchedCollName = renamed.dbName, renamed.collName
+			startAt = &renamed.clusterTime
+			resume = true
+			continue
+		}
+		if watcher.tokenSaved {
+			startAt = nil // the change stream can be resumed after the tokens of the renamed collection
+		}
+
+		// the connector is shutting down
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
 
-			if _, err = resumeTokensColl.InsertOne(ctx, &resumeToken{Value: currentResumeToken}); err != nil {
-				// change event has been published but token insertion failed.
-				// connector will resume after the previous token, publishing a duplicate change event.
-				// consumers should be able to detect and discard the duplicate change event by using the msg id.
-				c.logger.Error("could not insert resume token", "err", err)
-				break
+	return nil
+}
+
+// saveResumeToken stores the given resume token.
+// Transient errors are retried, while duplicate key errors are ignored, as the token has already been stored.
+func (c *DefaultClient) saveResumeToken(ctx context.Context, opts *WatchCollectionOptions, coll *mongo.Collection,
+	token string, published []PublishedMsg) error {
+	backoff := tokenSavePolicy.With(c.retryPolicy).NewBackoff()
+	for attempt := 1; ; attempt++ {
+		res, err := coll.InsertOne(ctx, &resumeToken{Value: token, Published: published})
+		switch {
+		case err == nil:
+			c.reportTokenSaved(opts, token)
+			if opts.ResumeTokensCollTtl > 0 {
+				// the superseded resume tokens left unexpired are expired on the next save
+				if err = expireSuperseded(ctx, coll, res.InsertedID, opts.ResumeTokensCollTtl); err != nil {
+					c.logger.Warn("could not expire superseded resume tokens", "err", err)
+				}
 			}
+			return nil
+		case mongo.IsDuplicateKeyError(err):
+			c.logger.Debug("resume token already stored", "token", token)
+			return nil
+		}
+		wait, ok := backoff.Next()
+		if !isTransientError(err) || !backoff.Retries(retry.TransientClass) || !ok ||
+			opts.ErrorPolicies.Action(TokenSaveErrorClass) != RetryErrorAction {
+			if c.onTokenSaveFailedEvent != nil {
+				c.onTokenSaveFailedEvent(opts.WatchedDbName, opts.WatchedCollName)
+			}
+			return err
 		}
 
-		c.logger.Info("stopped watching mongodb collection", "collName", watchedColl.Name())
-		if err = cs.Close(context.Background()); err != nil {
-			return fmt.Errorf("could not close change stream: %v", err)
+		c.logger.Warn("could not insert resume token, retrying", "attempt", attempt, "backoff", wait, "err", err)
+		if c.onTokenSaveRetriedEvent != nil {
+			c.onTokenSaveRetriedEvent(opts.WatchedDbName, opts.WatchedCollName)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
+}
 
-	return nil
+// reportTokenSaved reports the cluster time of the stored resume token of the watched collection.
+func (c *DefaultClient) reportTokenSaved(opts *WatchCollectionOptions, token string) {
+	if c.onTokenSavedEvent == nil {
+		return
+	}
+	if t, ok := tokenTime(token); ok {
+		c.onTokenSavedEvent(opts.WatchedDbName, opts.WatchedCollName, t)
+	}
+}
+
+// tryNext tries to get the next change event, waiting at most for the given timeout.
+// The returned stalled flag is true if the cursor did not respond within the timeout.
+func tryNext(ctx context.Context, cs *mongo.ChangeStream, timeout time.Duration) (hasNext, stalled bool) {
+	tryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	hasNext = cs.TryNext(tryCtx)
+	stalled = !hasNext && ctx.Err() == nil && errors.Is(tryCtx.Err(), context.DeadlineExceeded)
+	return hasNext, stalled
 }
 
 type resumeToken struct {
 	Value string `bson:"value"`
+	// Published are the last messages published to each subject, if the collection resumes idempotently.
+	Published []PublishedMsg `bson:"published,omitempty"`
 }
 
 type ClientOption func(*DefaultClient)
@@ -272,6 +622,27 @@ func WithMongoUri(uri string) ClientOption {
 	}
 }
 
+// WithAutoEncryption makes the client decrypt the values encrypted with client-side field level encryption, or
+// queryable encryption, with the given key vault namespace and KMS providers.
+func WithAutoEncryption(keyVaultNamespace string, kmsProviders map[string]map[string]string) ClientOption {
+	return func(c *DefaultClient) {
+		if keyVaultNamespace == "" {
+			return
+		}
+		providers := make(map[string]map[string]any, len(kmsProviders))
+		for name, provider := range kmsProviders {
+			providers[name] = make(map[string]any, len(provider))
+			for key, value := range provider {
+				providers[name][key] = value
+			}
+		}
+		c.autoEncryptionOpts = options.AutoEncryption().
+			SetKeyVaultNamespace(keyVaultNamespace).
+			SetKmsProviders(providers).
+			SetBypassAutoEncryption(true)
+	}
+}
+
 func WithLogger(logger *slog.Logger) ClientOption {
 	return func(c *DefaultClient) {
 		if logger != nil {
@@ -280,6 +651,21 @@ func WithLogger(logger *slog.Logger) ClientOption {
 	}
 }
 
+// WithMetadataLogging, if set, logs only the operation type, resume token and size of each change event received at
+// debug level, instead of the whole change event.
+func WithMetadataLogging(metadataLogging bool) ClientOption {
+	return func(c *DefaultClient) {
+		c.metadataLogging = metadataLogging
+	}
+}
+
+// WithRetryPolicy overrides the retry policies of the resume token saves and of the reconnections.
+func WithRetryPolicy(policy *retry.Policy) ClientOption {
+	return func(c *DefaultClient) {
+		c.retryPolicy = policy
+	}
+}
+
 func WithEventListeners(listeners ...EventListener) ClientOption {
 	return func(c *DefaultClient) {
 		for _, listener := range listeners {
@@ -313,3 +699,132 @@ func OnCmdFailedEvent(onCmdFailedEvent func(dbName, cmdName string, duration tim
 		}
 	}
 }
+
+func OnTokenSavedEvent(onTokenSavedEvent func(dbName, collName string, tokenTime time.Time)) EventListener {
+	return func(c *DefaultClient) {
+		if onTokenSavedEvent != nil {
+			c.onTokenSavedEvent = onTokenSavedEvent
+		}
+	}
+}
+
+func OnTokenSaveRetriedEvent(onTokenSaveRetriedEvent func(dbName, collName string)) EventListener {
+	return func(c *DefaultClient) {
+		if onTokenSaveRetriedEvent != nil {
+			c.onTokenSaveRetriedEvent = onTokenSaveRetriedEvent
+		}
+	}
+}
+
+func OnTokenSaveFailedEvent(onTokenSaveFailedEvent func(dbName, collName string)) EventListener {
+	return func(c *DefaultClient) {
+		if onTokenSaveFailedEvent != nil {
+			c.onTokenSaveFailedEvent = onTokenSaveFailedEvent
+		}
+	}
+}
+
+func OnOplogWindowEvent(onOplogWindowEvent func(oldest time.Time)) EventListener {
+	return func(c *DefaultClient) {
+		if onOplogWindowEvent != nil {
+			c.onOplogWindowEvent = onOplogWindowEvent
+		}
+	}
+}
+
+func OnChangeEventPublishedEvent(onChangeEventPublishedEvent func(dbName, collName, operationType string,
+	size int)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventPublishedEvent != nil {
+			c.onChangeEventPublishedEvent = onChangeEventPublishedEvent
+		}
+	}
+}
+
+func OnConnOpenedEvent(onConnOpenedEvent func()) EventListener {
+	return func(c *DefaultClient) {
+		if onConnOpenedEvent != nil {
+			c.onConnOpenedEvent = onConnOpenedEvent
+		}
+	}
+}
+
+func OnReconnectEvent(onReconnectEvent func()) EventListener {
+	return func(c *DefaultClient) {
+		if onReconnectEvent != nil {
+			c.onReconnectEvent = onReconnectEvent
+		}
+	}
+}
+
+func OnConnClosedEvent(onConnClosedEvent func()) EventListener {
+	return func(c *DefaultClient) {
+		if onConnClosedEvent != nil {
+			c.onConnClosedEvent = onConnClosedEvent
+		}
+	}
+}
+
+func OnChangeStreamOpenedEvent(onChangeStreamOpenedEvent func(dbName, collName string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeStreamOpenedEvent != nil {
+			c.onChangeStreamOpenedEvent = onChangeStreamOpenedEvent
+		}
+	}
+}
+
+func OnChangeStreamClosedEvent(onChangeStreamClosedEvent func(dbName, collName string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeStreamClosedEvent != nil {
+			c.onChangeStreamClosedEvent = onChangeStreamClosedEvent
+		}
+	}
+}
+
+func OnChangeEventsSkippedEvent(onChangeEventsSkippedEvent func(dbName, collName string, skipped int)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventsSkippedEvent != nil {
+			c.onChangeEventsSkippedEvent = onChangeEventsSkippedEvent
+		}
+	}
+}
+
+func OnChangeEventFilteredEvent(onChangeEventFilteredEvent func(dbName, collName string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventFilteredEvent != nil {
+			c.onChangeEventFilteredEvent = onChangeEventFilteredEvent
+		}
+	}
+}
+
+func OnChangeEventMalformedEvent(onChangeEventMalformedEvent func(dbName, collName, policy string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventMalformedEvent != nil {
+			c.onChangeEventMalformedEvent = onChangeEventMalformedEvent
+		}
+	}
+}
+
+func OnChangeEventOutOfOrderEvent(onChangeEventOutOfOrderEvent func(dbName, collName, stage string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventOutOfOrderEvent != nil {
+			c.onChangeEventOutOfOrderEvent = onChangeEventOutOfOrderEvent
+		}
+	}
+}
+
+func OnChangeEventQuarantinedEvent(onChangeEventQuarantinedEvent func(dbName, collName string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventQuarantinedEvent != nil {
+			c.onChangeEventQuarantinedEvent = onChangeEventQuarantinedEvent
+		}
+	}
+}
+
+func OnChangeEventOversizedEvent(onChangeEventOversizedEvent func(dbName, collName, policy string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventOversizedEvent != nil {
+			c.onChangeEventOversizedEvent = onChangeEventOversizedEvent
+		}
+	}
+}
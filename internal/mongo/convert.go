@@ -0,0 +1,157 @@
+package mongo
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Conversion represents how a BSON type without JSON equivalent is rendered in the change events encoded as JSON.
+type Conversion string
+
+const (
+	// ExtJsonConversion renders the values as relaxed extended JSON, e.g. {"$oid":"664f1c2e9b1e8a3d4c5b6a79"}, which is
+	// the default.
+	ExtJsonConversion Conversion = "extjson"
+
+	// HexConversion renders object ids as their hex string, e.g. "664f1c2e9b1e8a3d4c5b6a79".
+	HexConversion Conversion = "hex"
+
+	// Rfc3339Conversion renders dates as RFC 3339 strings in UTC, e.g. "2024-05-01T12:00:00.123Z".
+	Rfc3339Conversion Conversion = "rfc3339"
+
+	// StringConversion renders numbers as strings, e.g. "12.50", so that their precision is kept by any JSON parser.
+	StringConversion Conversion = "string"
+
+	// NumberConversion renders numbers as JSON numbers, e.g. 12.5. Decimals are converted to doubles, which may lose
+	// precision.
+	NumberConversion Conversion = "number"
+)
+
+var (
+	ObjectIdConversions = []Conversion{ExtJsonConversion, HexConversion}
+	DateConversions     = []Conversion{ExtJsonConversion, Rfc3339Conversion}
+	DecimalConversions  = []Conversion{ExtJsonConversion, StringConversion, NumberConversion}
+	LongConversions     = []Conversion{NumberConversion, StringConversion}
+)
+
+var ErrInvalidConversions = errors.New("a type conversion is not supported by its type")
+
+// Conversions tells how the BSON types without JSON equivalent are rendered in the change events encoded as JSON, so
+// that consumers which are not aware of extended JSON do not have to unwrap them. The types whose conversion is empty
+// are rendered as relaxed extended JSON.
+type Conversions struct {
+	ObjectId Conversion
+	Date     Conversion
+	Decimal  Conversion
+	// Long is the conversion of 64-bit integers, rendered as numbers by relaxed extended JSON.
+	Long Conversion
+}
+
+// Validate returns an error if a conversion is not supported by its type.
+func (c *Conversions) Validate() error {
+	for _, check := range []struct {
+		conversion  Conversion
+		conversions []Conversion
+	}{
+		{c.ObjectId, ObjectIdConversions},
+		{c.Date, DateConversions},
+		{c.Decimal, DecimalConversions},
+		{c.Long, LongConversions},
+	} {
+		if check.conversion != "" && !slices.Contains(check.conversions, check.conversion) {
+			return ErrInvalidConversions
+		}
+	}
+	return nil
+}
+
+// simplifiedConversions are the conversions of SimplifiedJsonFlavor.
+var simplifiedConversions = Conversions{
+	ObjectId: HexConversion,
+	Date:     Rfc3339Conversion,
+	Decimal:  StringConversion,
+	Long:     NumberConversion,
+}
+
+// conversionsOf returns the conversions of the change events encoded with the given options, i.e. their conversions,
+// on top of the ones of SimplifiedJsonFlavor if it is their flavor.
+func conversionsOf(opts *WatchCollectionOptions) *Conversions {
+	if opts.JsonFlavor != SimplifiedJsonFlavor {
+		return opts.Conversions
+	}
+	c := simplifiedConversions
+	if opts.Conversions != nil {
+		for _, override := range []struct {
+			conversion *Conversion
+			value      Conversion
+		}{
+			{&c.ObjectId, opts.Conversions.ObjectId},
+			{&c.Date, opts.Conversions.Date},
+			{&c.Decimal, opts.Conversions.Decimal},
+			{&c.Long, opts.Conversions.Long},
+		} {
+			if override.value != "" {
+				*override.conversion = override.value
+			}
+		}
+	}
+	return &c
+}
+
+// convert returns a copy of the given change event whose values are converted by the given conversions, if any.
+func convert(changeEvent bson.Raw, c *Conversions) (bson.Raw, error) {
+	if c == nil {
+		return changeEvent, nil
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(changeEvent, &doc); err != nil {
+		return nil, err
+	}
+	return bson.Marshal(c.convertDocument(doc))
+}
+
+func (c *Conversions) convertDocument(doc bson.D) bson.D {
+	for i := range doc {
+		doc[i].Value = c.convertValue(doc[i].Value)
+	}
+	return doc
+}
+
+func (c *Conversions) convertValue(value any) any {
+	switch v := value.(type) {
+	case bson.D:
+		return c.convertDocument(v)
+	case bson.A:
+		for i := range v {
+			v[i] = c.convertValue(v[i])
+		}
+		return v
+	case primitive.ObjectID:
+		if c.ObjectId == HexConversion {
+			return v.Hex()
+		}
+	case primitive.DateTime:
+		if c.Date == Rfc3339Conversion {
+			return v.Time().UTC().Format(time.RFC3339Nano)
+		}
+	case primitive.Decimal128:
+		switch c.Decimal {
+		case StringConversion:
+			return v.String()
+		case NumberConversion:
+			if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+				return f
+			}
+		}
+	case int64:
+		if c.Long == StringConversion {
+			return strconv.FormatInt(v, 10)
+		}
+	}
+	return value
+}
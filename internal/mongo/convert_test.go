@@ -0,0 +1,93 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestConversions_Validate(t *testing.T) {
+	t.Run("should accept the conversions supported by their type", func(t *testing.T) {
+		for _, c := range []*Conversions{
+			{},
+			{ObjectId: HexConversion, Date: Rfc3339Conversion, Decimal: NumberConversion, Long: StringConversion},
+			{ObjectId: ExtJsonConversion, Date: ExtJsonConversion, Decimal: StringConversion, Long: NumberConversion},
+		} {
+			require.NoError(t, c.Validate())
+		}
+	})
+	t.Run("should return error if a conversion is not supported by its type", func(t *testing.T) {
+		for _, c := range []*Conversions{
+			{ObjectId: StringConversion},
+			{Date: HexConversion},
+			{Decimal: Rfc3339Conversion},
+			{Long: ExtJsonConversion},
+		} {
+			require.ErrorIs(t, c.Validate(), ErrInvalidConversions)
+		}
+	})
+}
+
+func Test_convert(t *testing.T) {
+	id, err := primitive.ObjectIDFromHex("664f1c2e9b1e8a3d4c5b6a79")
+	require.NoError(t, err)
+	total, err := primitive.ParseDecimal128("12.50")
+	require.NoError(t, err)
+	createdAt := primitive.NewDateTimeFromTime(time.Date(2024, 5, 1, 12, 0, 0, 123e6, time.UTC))
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "fullDocument", Value: bson.D{
+			{Key: "_id", Value: id},
+			{Key: "createdAt", Value: createdAt},
+			{Key: "total", Value: total},
+			{Key: "views", Value: int64(9007199254740993)},
+			{Key: "tags", Value: bson.A{id, bson.D{{Key: "at", Value: createdAt}}}},
+		}},
+	})
+	require.NoError(t, err)
+
+	t.Run("should convert the values in nested documents and arrays", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Conversions: &Conversions{
+			ObjectId: HexConversion, Date: Rfc3339Conversion, Decimal: StringConversion, Long: StringConversion,
+		}})
+
+		require.NoError(t, err)
+		require.Equal(t, `{"operationType":"insert","fullDocument":{"_id":"664f1c2e9b1e8a3d4c5b6a79",`+
+			`"createdAt":"2024-05-01T12:00:00.123Z","total":"12.50","views":"9007199254740993",`+
+			`"tags":["664f1c2e9b1e8a3d4c5b6a79",{"at":"2024-05-01T12:00:00.123Z"}]}}`, string(data))
+	})
+	t.Run("should convert decimals to numbers", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Conversions: &Conversions{
+			Decimal: NumberConversion,
+		}})
+
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"total":12.5,`)
+		require.Contains(t, string(data), `"_id":{"$oid":"664f1c2e9b1e8a3d4c5b6a79"}`)
+	})
+	t.Run("should convert the values of the simplified json flavor, overridden by the conversions", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{JsonFlavor: SimplifiedJsonFlavor,
+			Conversions: &Conversions{Date: ExtJsonConversion}})
+
+		require.NoError(t, err)
+		require.Equal(t, `{"operationType":"insert","fullDocument":{"_id":"664f1c2e9b1e8a3d4c5b6a79",`+
+			`"createdAt":{"$date":"2024-05-01T12:00:00.123Z"},"total":"12.50","views":9007199254740993,`+
+			`"tags":["664f1c2e9b1e8a3d4c5b6a79",{"at":{"$date":"2024-05-01T12:00:00.123Z"}}]}}`, string(data))
+	})
+	t.Run("should not convert the values if there are no conversions", func(t *testing.T) {
+		converted, err := convert(changeEvent, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, []byte(changeEvent), []byte(converted))
+	})
+	t.Run("should not convert the values of the change events encoded as bson", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Encoder: BsonEncoder,
+			Conversions: &Conversions{ObjectId: HexConversion}})
+
+		require.NoError(t, err)
+		require.Equal(t, []byte(changeEvent), data)
+	})
+}
@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
+)
+
+const (
+	// cappedPositionLostErrCode is returned once the position of a cursor in a capped collection was overwritten.
+	cappedPositionLostErrCode = 136
+
+	// cursorKilledErrCode is returned once a cursor was killed.
+	cursorKilledErrCode = 237
+)
+
+// isCursorLostError reports whether the given error means that the cursor of the change stream is gone.
+func isCursorLostError(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) &&
+		(serverErr.HasErrorCode(cursorKilledErrCode) || serverErr.HasErrorCode(cappedPositionLostErrCode))
+}
+
+// recreateAfter waits for the next wait of the given backoff if the cursor of the change stream is gone.
+// It returns false if the watcher must not resume.
+func (c *DefaultClient) recreateAfter(ctx context.Context, opts *WatchCollectionOptions, err error,
+	backoff *retry.Backoff) bool {
+	if !isCursorLostError(err) || !backoff.Retries(retry.TransientClass) || ctx.Err() != nil {
+		return false
+	}
+	wait, ok := backoff.Next()
+	if !ok {
+		c.logger.Error("change stream cursor lost, retry budget exhausted", "collName", opts.WatchedCollName,
+			"err", err)
+		return false
+	}
+	c.logger.Warn("change stream cursor lost, recreating it after backoff", "collName", opts.WatchedCollName,
+		"backoff", wait, "err", err)
+	select {
+	case <-time.After(wait):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// idle reports whether all the change events received by the watcher are published or dropped.
+func (w *changeStreamWatcher) idle() bool {
+	return len(w.pending) == 0 && w.txn == nil && w.gap == nil && len(w.fragments) == 0
+}
+
+// postBatchResumeToken returns the resume token of the last batch of the change stream, or nil if it has none.
+func postBatchResumeToken(cs *mongo.ChangeStream) bson.Raw {
+	if _, ok := cs.ResumeToken().Lookup("_data").StringValueOK(); !ok {
+		return nil
+	}
+	return cs.ResumeToken()
+}
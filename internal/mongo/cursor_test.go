@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
+)
+
+func Test_isCursorLostError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "should return false if there is no error",
+			err:  nil,
+			want: false,
+		},
+		{
+			name: "should return true if the cursor was killed",
+			err:  fmt.Errorf("change stream failed: %w", mongo.CommandError{Code: 237, Name: "CursorKilled"}),
+			want: true,
+		},
+		{
+			name: "should return true if the capped position was lost",
+			err:  mongo.CommandError{Code: 136, Name: "CappedPositionLost"},
+			want: true,
+		},
+		{
+			name: "should return false if the change stream history is lost",
+			err:  mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost"},
+			want: false,
+		},
+		{
+			name: "should return false for generic errors",
+			err:  errors.New("generic error"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isCursorLostError(tt.err))
+		})
+	}
+}
+
+func TestDefaultClient_recreateAfter(t *testing.T) {
+	opts := &WatchCollectionOptions{WatchedDbName: "db", WatchedCollName: "coll1"}
+	c := &DefaultClient{logger: slog.Default()}
+	policy := retry.Policy{InitialInterval: 10 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2}
+	cursorKilled := mongo.CommandError{Code: 237, Name: "CursorKilled"}
+
+	t.Run("should recreate the change stream after backoff if its cursor was killed", func(t *testing.T) {
+		backoff := policy.NewBackoff()
+
+		require.True(t, c.recreateAfter(context.Background(), opts, cursorKilled, backoff))
+		wait, _ := backoff.Next()
+		require.Equal(t, 20*time.Millisecond, wait, "the backoff grows until the change stream is open again")
+	})
+	t.Run("should not recreate the change stream if the error is not recoverable", func(t *testing.T) {
+		require.False(t, c.recreateAfter(context.Background(), opts, errors.New("generic error"), policy.NewBackoff()))
+	})
+	t.Run("should not recreate the change stream if the connector is shutting down", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.False(t, c.recreateAfter(ctx, opts, cursorKilled, policy.NewBackoff()))
+	})
+	t.Run("should not recreate the change stream if its policy does not retry transient errors", func(t *testing.T) {
+		backoff := policy.With(&retry.Policy{RetryOn: []retry.Class{retry.UnreachableClass}}).NewBackoff()
+
+		require.False(t, c.recreateAfter(context.Background(), opts, cursorKilled, backoff))
+	})
+}
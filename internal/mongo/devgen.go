@@ -0,0 +1,540 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/time/rate"
+)
+
+// maxGeneratedDocs caps the documents a generator keeps track of for each collection, i.e. the ones its update,
+// replace and delete change events pick from, the oldest being forgotten first.
+const maxGeneratedDocs = 10000
+
+// DefaultGeneratorTemplate is the document template used by generators without template, e.g. for orders.
+const DefaultGeneratorTemplate = `{
+	"customer": {"name": "{{ name }}", "email": "{{ email }}"},
+	"status": "{{ oneOf \"pending\" \"paid\" \"shipped\" \"delivered\" }}",
+	"amount": "{{ float 5 500 }}",
+	"items": "{{ int 1 10 }}",
+	"createdAt": "{{ now }}"
+}`
+
+// DefaultGeneratorOps is the operation mix of generators without mix.
+var DefaultGeneratorOps = map[string]int{insertOperationType: 60, updateOperationType: 30, deleteOperationType: 10}
+
+var ErrInvalidGenerator = errors.New("invalid generator: it must have a positive rate, an operation mix weighting `insert`, `update`, `replace` and `delete`, and a document template in extended json")
+
+var (
+	firstNames = []string{"Ada", "Alan", "Grace", "Linus", "Margaret", "Dennis", "Barbara", "Ken", "Frances", "Edsger"}
+	lastNames  = []string{"Lovelace", "Turing", "Hopper", "Torvalds", "Hamilton", "Ritchie", "Liskov", "Thompson",
+		"Allen", "Dijkstra"}
+	words = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliett"}
+)
+
+type GeneratorOptions struct {
+	// Rate is the number of change events generated per second, for each collection.
+	Rate float64
+	// Template is the document template, in extended JSON, whose string values can be templates generating values,
+	// e.g. {"amount": "{{ float 5 500 }}"}. If empty, DefaultGeneratorTemplate is used.
+	Template string
+	// Ops weights the operation types of the change events, e.g. {"insert": 60, "update": 30, "delete": 10}. If empty,
+	// DefaultGeneratorOps is used.
+	Ops map[string]int
+}
+
+// Validate returns an error if the rate is not positive, if an operation type is not generated, or if the template
+// cannot generate documents.
+func (o *GeneratorOptions) Validate() error {
+	if o.Rate <= 0 {
+		return ErrInvalidGenerator
+	}
+	total := 0
+	for op, weight := range o.Ops {
+		if !slices.Contains(generatedOperationTypes, op) || weight < 0 {
+			return ErrInvalidGenerator
+		}
+		total += weight
+	}
+	if len(o.Ops) > 0 && total == 0 {
+		return ErrInvalidGenerator
+	}
+	if _, err := newDocTemplate(o.template()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGenerator, err)
+	}
+	return nil
+}
+
+func (o *GeneratorOptions) template() string {
+	if o.Template == "" {
+		return DefaultGeneratorTemplate
+	}
+	return o.Template
+}
+
+func (o *GeneratorOptions) ops() map[string]int {
+	if len(o.Ops) == 0 {
+		return DefaultGeneratorOps
+	}
+	return o.Ops
+}
+
+// generatedOperationTypes are the operation types generators can fabricate, in the order their weights are drawn.
+var generatedOperationTypes = []string{insertOperationType, updateOperationType, replacOperationType,
+	deleteOperationType}
+
+var _ Client = &GeneratorClient{}
+
+// GeneratorClient is a client that fabricates the change events of the watched collections from a document template,
+// instead of watching MongoDB, and keeps their resume tokens in memory.
+type GeneratorClient struct {
+	opts   GeneratorOptions
+	logger *slog.Logger
+
+	mu           sync.Mutex
+	preImages    map[string]bool   // by namespace
+	resumeTokens map[string]string // by resume tokens namespace
+}
+
+// NewGeneratorClient returns a client generating change events with the given options.
+func NewGeneratorClient(opts *GeneratorOptions, logger *slog.Logger) (*GeneratorClient, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &GeneratorClient{
+		opts:         *opts,
+		logger:       logger,
+		preImages:    make(map[string]bool),
+		resumeTokens: make(map[string]string),
+	}, nil
+}
+
+func (c *GeneratorClient) Name() string {
+	return defaultName
+}
+
+func (c *GeneratorClient) Monitor(_ context.Context) error {
+	return nil
+}
+
+func (c *GeneratorClient) Close() error {
+	return nil
+}
+
+// CreateCollection records whether the given collection has pre-images.
+func (c *GeneratorClient) CreateCollection(_ context.Context, opts *CreateCollectionOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if opts.ChangeStreamPreAndPostImages {
+		c.preImages[opts.DbName+"."+opts.CollName] = true
+	}
+	return nil
+}
+
+// WatchCollection generates the change events of the given collection at the rate of the generator, and hands them to
+// its change event handler, until the given context is cancelled or a change event cannot be published.
+func (c *GeneratorClient) WatchCollection(ctx context.Context, opts *WatchCollectionOptions) error {
+	namespace := opts.WatchedDbName + "." + opts.WatchedCollName
+	c.mu.Lock()
+	preImages := c.preImages[namespace]
+	c.mu.Unlock()
+	g, err := newGenerator(&c.opts, opts, preImages)
+	if err != nil {
+		return err
+	}
+	limiter := rate.NewLimiter(rate.Limit(c.opts.Rate), 1)
+	routeOpts := RouteOptions(opts)
+	c.logger.Info("generating change events", "dbName", opts.WatchedDbName, "collName", opts.WatchedCollName,
+		"rate", c.opts.Rate)
+
+	for {
+		if err = limiter.Wait(ctx); err != nil {
+			return nil // stopped
+		}
+		changeEvent, operationType, token, err := g.next(time.Now())
+		if err != nil {
+			return err
+		}
+		event, err := buildChangeEvent(opts, routeOpts, changeEvent, operationType, token)
+		if err != nil {
+			return err
+		}
+		if event == nil {
+			continue
+		}
+		event.MsgId = token
+		if err = opts.ChangeEventHandler(ctx, event); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		c.mu.Lock()
+		c.resumeTokens[opts.ResumeTokensDbName+"."+opts.ResumeTokensCollName] = token
+		c.mu.Unlock()
+	}
+}
+
+func (c *GeneratorClient) Backfill(_ context.Context, _ *BackfillOptions) (int, error) {
+	return 0, errors.New("could not backfill: change events are generated")
+}
+
+func (c *GeneratorClient) Redrive(_ context.Context, _ *WatchCollectionOptions) (int, error) {
+	return 0, errors.New("could not redrive: change events are generated")
+}
+
+func (c *GeneratorClient) LastResumeToken(_ context.Context, opts *ResumeTokensOptions) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resumeTokens[opts.DbName+"."+opts.CollName], nil
+}
+
+func (c *GeneratorClient) RestoreResumeToken(_ context.Context, opts *ResumeTokensOptions, token string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resumeTokens[opts.DbName+"."+opts.CollName] = token
+	return nil
+}
+
+func (c *GeneratorClient) InspectResumeToken(_ context.Context, _ *ResumeTokensOptions) (*ResumeTokenInspection,
+	error) {
+	return nil, errors.New("could not inspect resume token: change events are generated")
+}
+
+func (c *GeneratorClient) RepairResumeTokens(_ context.Context, _ *ResumeTokensOptions, _ string) (int, error) {
+	return 0, errors.New("could not repair resume tokens: change events are generated")
+}
+
+// generator fabricates the change events of a collection, keeping track of the documents it inserted.
+type generator struct {
+	collOpts  *WatchCollectionOptions
+	tmpl      *docTemplate
+	ops       map[string]int
+	preImages bool
+	rand      *rand.Rand
+
+	docs  map[primitive.ObjectID]bson.D
+	ids   []primitive.ObjectID // in insertion order
+	count int64
+}
+
+func newGenerator(opts *GeneratorOptions, collOpts *WatchCollectionOptions, preImages bool) (*generator, error) {
+	tmpl, err := newDocTemplate(opts.template())
+	if err != nil {
+		return nil, err
+	}
+	return &generator{
+		collOpts:  collOpts,
+		tmpl:      tmpl,
+		ops:       opts.ops(),
+		preImages: preImages,
+		rand:      tmpl.rand,
+		docs:      make(map[primitive.ObjectID]bson.D),
+	}, nil
+}
+
+// next returns the next change event, along with its operation type and resume token. Its operation type is drawn
+// from the operation mix, an insert being generated instead of the other ones while no document was inserted.
+func (g *generator) next(now time.Time) (bson.Raw, string, string, error) {
+	g.count++
+	token := fmt.Sprintf("devgen-%d-%08d", now.Unix(), g.count)
+	operationType := g.operationType()
+	if len(g.ids) == 0 {
+		operationType = insertOperationType
+	}
+
+	var id primitive.ObjectID
+	var before bson.D
+	if operationType == insertOperationType {
+		id = primitive.NewObjectIDFromTimestamp(now)
+	} else {
+		id = g.ids[g.rand.IntN(len(g.ids))]
+		before = g.docs[id]
+	}
+	changeEvent := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: token}}},
+		{Key: "operationType", Value: operationType},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: uint32(now.Unix()), I: uint32(g.count)}},
+		{Key: "wallTime", Value: primitive.NewDateTimeFromTime(now)},
+		{Key: "ns", Value: bson.D{
+			{Key: "db", Value: g.collOpts.WatchedDbName},
+			{Key: "coll", Value: g.collOpts.WatchedCollName},
+		}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+	}
+
+	switch operationType {
+	case insertOperationType, replacOperationType:
+		doc, err := g.tmpl.generate()
+		if err != nil {
+			return nil, "", "", err
+		}
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+		g.put(id, doc)
+		changeEvent = append(changeEvent, bson.E{Key: "fullDocument", Value: doc})
+	case updateOperationType:
+		doc, updated, err := g.update(before)
+		if err != nil {
+			return nil, "", "", err
+		}
+		g.put(id, doc)
+		changeEvent = append(changeEvent,
+			bson.E{Key: "updateDescription", Value: bson.D{
+				{Key: "updatedFields", Value: updated},
+				{Key: "removedFields", Value: bson.A{}},
+				{Key: "truncatedArrays", Value: bson.A{}},
+			}},
+			bson.E{Key: "fullDocument", Value: doc})
+	case deleteOperationType:
+		g.remove(id)
+	}
+	if g.preImages && before != nil {
+		changeEvent = append(changeEvent, bson.E{Key: "fullDocumentBeforeChange", Value: before})
+	}
+
+	raw, err := bson.Marshal(changeEvent)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("could not marshal generated change event: %v", err)
+	}
+	return raw, operationType, token, nil
+}
+
+// operationType draws an operation type from the operation mix.
+func (g *generator) operationType() string {
+	total := 0
+	for _, weight := range g.ops {
+		total += weight
+	}
+	n := g.rand.IntN(total)
+	for _, op := range generatedOperationTypes {
+		if n < g.ops[op] {
+			return op
+		}
+		n -= g.ops[op]
+	}
+	return insertOperationType
+}
+
+// update returns the given document with up to 3 of its fields generated again, along with the updated fields.
+func (g *generator) update(doc bson.D) (bson.D, bson.D, error) {
+	generated, err := g.tmpl.generate()
+	if err != nil {
+		return nil, nil, err
+	}
+	updated := slices.Clone(generated)
+	g.rand.Shuffle(len(updated), func(i, j int) { updated[i], updated[j] = updated[j], updated[i] })
+	updated = updated[:min(len(updated), 1+g.rand.IntN(3))]
+
+	doc = slices.Clone(doc)
+	for _, field := range updated {
+		i := slices.IndexFunc(doc, func(e bson.E) bool { return e.Key == field.Key })
+		if i < 0 {
+			doc = append(doc, field)
+		} else {
+			doc[i] = field
+		}
+	}
+	return doc, updated, nil
+}
+
+func (g *generator) put(id primitive.ObjectID, doc bson.D) {
+	if _, ok := g.docs[id]; !ok {
+		g.ids = append(g.ids, id)
+	}
+	g.docs[id] = doc
+	if len(g.ids) > maxGeneratedDocs {
+		g.remove(g.ids[0])
+	}
+}
+
+func (g *generator) remove(id primitive.ObjectID) {
+	delete(g.docs, id)
+	g.ids = slices.DeleteFunc(g.ids, func(i primitive.ObjectID) bool { return i == id })
+}
+
+// docTemplate generates documents from a document in extended JSON whose string values can be templates. A value
+// which is a single action keeps the type of its result, e.g. "{{ int 1 10 }}" generates an integer, while the other
+// templates generate strings, e.g. "order-{{ seq }}".
+type docTemplate struct {
+	doc  bson.D
+	rand *rand.Rand
+	seq  int64
+
+	tmpls map[string]*template.Template // by template text
+	// value is the result of the single action being executed.
+	value any
+}
+
+func newDocTemplate(text string) (*docTemplate, error) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON([]byte(text), false, &doc); err != nil {
+		return nil, fmt.Errorf("could not parse document template: %v", err)
+	}
+	t := &docTemplate{
+		doc:   doc,
+		rand:  rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		tmpls: make(map[string]*template.Template),
+	}
+	if err := t.parse(doc); err != nil {
+		return nil, err
+	}
+	if _, err := t.generate(); err != nil {
+		return nil, fmt.Errorf("could not generate document: %v", err)
+	}
+	return t, nil
+}
+
+func (t *docTemplate) funcs() template.FuncMap {
+	return template.FuncMap{
+		"seq": func() int64 {
+			t.seq++
+			return t.seq
+		},
+		"int": func(lower, upper int) int {
+			return lower + t.rand.IntN(max(upper-lower, 0)+1)
+		},
+		"float": func(lower, upper float64) float64 {
+			return math.Round((lower+t.rand.Float64()*(upper-lower))*100) / 100
+		},
+		"bool": func() bool {
+			return t.rand.IntN(2) == 1
+		},
+		"oneOf": func(values ...any) any {
+			if len(values) == 0 {
+				return nil
+			}
+			return values[t.rand.IntN(len(values))]
+		},
+		"word": func() string {
+			return words[t.rand.IntN(len(words))]
+		},
+		"name": func() string {
+			return firstNames[t.rand.IntN(len(firstNames))] + " " + lastNames[t.rand.IntN(len(lastNames))]
+		},
+		"email": func() string {
+			return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(firstNames[t.rand.IntN(len(firstNames))]),
+				strings.ToLower(lastNames[t.rand.IntN(len(lastNames))]), t.rand.IntN(100))
+		},
+		"uuid": func() string {
+			b := make([]byte, 16)
+			for i := range b {
+				b[i] = byte(t.rand.IntN(256))
+			}
+			b[6], b[8] = b[6]&0x0f|0x40, b[8]&0x3f|0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+		},
+		"now": func() time.Time {
+			return time.Now().UTC()
+		},
+		"objectId": primitive.NewObjectID,
+		"capture": func(v any) string {
+			t.value = v
+			return ""
+		},
+	}
+}
+
+// parse parses the templates of the string values of the given document, recursively.
+func (t *docTemplate) parse(v any) error {
+	switch v := v.(type) {
+	case bson.D:
+		for _, e := range v {
+			if err := t.parse(e.Value); err != nil {
+				return err
+			}
+		}
+	case bson.A:
+		for _, e := range v {
+			if err := t.parse(e); err != nil {
+				return err
+			}
+		}
+	case string:
+		if !strings.Contains(v, "{{") {
+			return nil
+		}
+		text := v
+		if action, ok := singleAction(v); ok {
+			text = "{{ capture (" + action + ") }}"
+		}
+		tmpl, err := template.New("").Funcs(t.funcs()).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("could not parse template %q: %v", v, err)
+		}
+		t.tmpls[v] = tmpl
+	}
+	return nil
+}
+
+// singleAction returns the action of the given template if it is made of a single one, e.g. int 1 10 for
+// "{{ int 1 10 }}".
+func singleAction(text string) (string, bool) {
+	if !strings.HasPrefix(text, "{{") || !strings.HasSuffix(text, "}}") || strings.Count(text, "{{") != 1 {
+		return "", false
+	}
+	return strings.TrimSpace(text[2 : len(text)-2]), true
+}
+
+// generate returns a document generated from the template.
+func (t *docTemplate) generate() (bson.D, error) {
+	v, err := t.generateValue(t.doc)
+	if err != nil {
+		return nil, err
+	}
+	return v.(bson.D), nil
+}
+
+func (t *docTemplate) generateValue(v any) (any, error) {
+	switch v := v.(type) {
+	case bson.D:
+		doc := make(bson.D, 0, len(v))
+		for _, e := range v {
+			value, err := t.generateValue(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.E{Key: e.Key, Value: value})
+		}
+		return doc, nil
+	case bson.A:
+		arr := make(bson.A, 0, len(v))
+		for _, e := range v {
+			value, err := t.generateValue(e)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+	case string:
+		tmpl, ok := t.tmpls[v]
+		if !ok {
+			return v, nil
+		}
+		var sb strings.Builder
+		t.value = nil
+		if err := tmpl.Execute(&sb, nil); err != nil {
+			return nil, err
+		}
+		if _, single := singleAction(v); single {
+			return t.value, nil
+		}
+		return sb.String(), nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,118 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGeneratorOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GeneratorOptions
+		wantErr bool
+	}{
+		{name: "should accept the defaults", opts: GeneratorOptions{Rate: 10}},
+		{name: "should accept an operation mix and a template", opts: GeneratorOptions{Rate: 1,
+			Ops: map[string]int{"insert": 1, "replace": 1}, Template: `{"sku": "sku-{{ seq }}"}`}},
+		{name: "should reject a rate which is not positive", opts: GeneratorOptions{}, wantErr: true},
+		{name: "should reject an operation type which is not generated", opts: GeneratorOptions{Rate: 1,
+			Ops: map[string]int{"rename": 1}}, wantErr: true},
+		{name: "should reject an operation mix without weight", opts: GeneratorOptions{Rate: 1,
+			Ops: map[string]int{"insert": 0}}, wantErr: true},
+		{name: "should reject a template which is not extended json", opts: GeneratorOptions{Rate: 1,
+			Template: `{"sku": `}, wantErr: true},
+		{name: "should reject a template calling an unknown function", opts: GeneratorOptions{Rate: 1,
+			Template: `{"sku": "{{ sku }}"}`}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidGenerator)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_docTemplate_generate(t *testing.T) {
+	tmpl, err := newDocTemplate(`{"sku": "sku-{{ seq }}", "qty": "{{ int 1 3 }}", "price": "{{ float 1 2 }}",
+		"tags": ["{{ word }}", "static"], "createdAt": "{{ now }}", "active": "{{ bool }}",
+		"size": "{{ oneOf \"S\" \"M\" }}", "nested": {"id": "{{ uuid }}"}}`)
+	require.NoError(t, err)
+
+	doc, err := tmpl.generate()
+
+	require.NoError(t, err)
+	m := doc.Map()
+	require.Equal(t, "sku-2", m["sku"], "the sequence is incremented by the validation of the template")
+	require.IsType(t, 0, m["qty"])
+	require.GreaterOrEqual(t, m["qty"], 1)
+	require.LessOrEqual(t, m["qty"], 3)
+	require.IsType(t, 0.0, m["price"])
+	require.IsType(t, time.Time{}, m["createdAt"])
+	require.IsType(t, true, m["active"])
+	require.Contains(t, []any{"S", "M"}, m["size"])
+	require.Equal(t, "static", m["tags"].(bson.A)[1])
+	require.Len(t, m["nested"].(bson.D).Map()["id"], 36)
+}
+
+func TestGeneratorClient_WatchCollection(t *testing.T) {
+	t.Run("should publish the generated change events through the pipeline of the collection", func(t *testing.T) {
+		client, err := NewGeneratorClient(&GeneratorOptions{Rate: 1000}, nil)
+		require.NoError(t, err)
+		require.NoError(t, client.CreateCollection(context.Background(),
+			&CreateCollectionOptions{DbName: "shop", CollName: "orders", ChangeStreamPreAndPostImages: true}))
+		ctx, cancel := context.WithCancel(context.Background())
+		var events []*ChangeEvent
+		opts := &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "orders", StreamName: "ORDERS",
+			ResumeTokensDbName: "resume-tokens", ResumeTokensCollName: "orders",
+			EventTypes: EventTypes{"insert": "order.created"},
+			ChangeEventHandler: func(_ context.Context, event *ChangeEvent) error {
+				events = append(events, event)
+				if len(events) == 50 {
+					cancel()
+				}
+				return nil
+			}}
+
+		require.NoError(t, client.WatchCollection(ctx, opts))
+
+		require.Equal(t, "ORDERS.order.created", events[0].Subj, "the first change event is always an insert")
+		ops := make(map[string]int)
+		for _, event := range events {
+			ops[event.OperationType]++
+			require.NotEmpty(t, event.MsgId)
+			var payload map[string]any
+			require.NoError(t, json.Unmarshal(event.Data, &payload))
+			if event.OperationType == "update" {
+				require.Contains(t, payload, "updateDescription")
+				require.Contains(t, payload, "fullDocumentBeforeChange")
+			}
+		}
+		require.Greater(t, ops["insert"], 0)
+		require.Greater(t, ops["update"], 0)
+		token, err := client.LastResumeToken(context.Background(),
+			&ResumeTokensOptions{DbName: "resume-tokens", CollName: "orders"})
+		require.NoError(t, err)
+		require.Equal(t, events[len(events)-1].MsgId, token)
+	})
+	t.Run("should stop once a change event cannot be published", func(t *testing.T) {
+		client, err := NewGeneratorClient(&GeneratorOptions{Rate: 1000}, nil)
+		require.NoError(t, err)
+		publishErr := errors.New("could not publish")
+
+		err = client.WatchCollection(context.Background(), &WatchCollectionOptions{StreamName: "ORDERS",
+			ChangeEventHandler: func(context.Context, *ChangeEvent) error { return publishErr }})
+
+		require.ErrorIs(t, err, publishErr)
+	})
+}
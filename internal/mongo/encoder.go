@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Encoder represents the format used to encode change events before they are published.
+type Encoder string
+
+const (
+	// JsonEncoder encodes change events as relaxed extended JSON.
+	JsonEncoder Encoder = "json"
+
+	// BsonEncoder publishes change events as raw BSON documents.
+	BsonEncoder Encoder = "bson"
+)
+
+var Encoders = []Encoder{
+	JsonEncoder,
+	BsonEncoder,
+}
+
+// JsonFlavor represents the flavor of extended JSON the change events are encoded with by JsonEncoder.
+type JsonFlavor string
+
+const (
+	// RelaxedJsonFlavor encodes change events as relaxed extended JSON, which renders numbers and dates natively when
+	// JSON can represent them without loss, e.g. {"count":1}. This is the default.
+	RelaxedJsonFlavor JsonFlavor = "relaxed"
+
+	// CanonicalJsonFlavor encodes change events as canonical extended JSON, which preserves the type of every value,
+	// e.g. {"count":{"$numberLong":"1"}}, so that they can be decoded back to the exact same BSON documents.
+	CanonicalJsonFlavor JsonFlavor = "canonical"
+
+	// SimplifiedJsonFlavor encodes change events as relaxed extended JSON whose ObjectIds, dates, decimals and 64-bit
+	// integers are converted to plain JSON values, e.g. {"_id":"664f1c2e9b1e8a3d4c5b6a79"}.
+	SimplifiedJsonFlavor JsonFlavor = "simplified"
+)
+
+var JsonFlavors = []JsonFlavor{
+	RelaxedJsonFlavor,
+	CanonicalJsonFlavor,
+	SimplifiedJsonFlavor,
+}
+
+// ContentType returns the media type of the change events encoded with the encoder, published in their Content-Type
+// header.
+func (e Encoder) ContentType() string {
+	switch e {
+	case BsonEncoder:
+		return "application/bson"
+	default:
+		return "application/json"
+	}
+}
+
+// encode encodes the given change event with the given encoder, and with the given flavor if it is encoded as JSON.
+func encode(changeEvent bson.Raw, encoder Encoder, flavor JsonFlavor) ([]byte, error) {
+	switch encoder {
+	case BsonEncoder:
+		data := make([]byte, len(changeEvent))
+		copy(data, changeEvent)
+		return data, nil
+	default:
+		data, err := bson.MarshalExtJSON(changeEvent, flavor == CanonicalJsonFlavor, false)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal mongo change event from bson: %v", err)
+		}
+		return data, nil
+	}
+}
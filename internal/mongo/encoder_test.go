@@ -0,0 +1,43 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_encode(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "fullDocument", Value: bson.D{{Key: "count", Value: int64(1)}}},
+	})
+	require.NoError(t, err)
+
+	t.Run("should encode change event as relaxed extended json by default", func(t *testing.T) {
+		for _, encoder := range []Encoder{"", JsonEncoder} {
+			data, err := encode(changeEvent, encoder, "")
+
+			require.NoError(t, err)
+			require.JSONEq(t, `{"operationType":"insert","fullDocument":{"count":1}}`, string(data))
+		}
+	})
+	t.Run("should encode change event as canonical extended json", func(t *testing.T) {
+		data, err := encode(changeEvent, JsonEncoder, CanonicalJsonFlavor)
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"operationType":"insert","fullDocument":{"count":{"$numberLong":"1"}}}`, string(data))
+	})
+	t.Run("should encode change event as bson", func(t *testing.T) {
+		data, err := encode(changeEvent, BsonEncoder, CanonicalJsonFlavor)
+
+		require.NoError(t, err)
+		require.Equal(t, []byte(changeEvent), data)
+	})
+}
+
+func TestEncoder_ContentType(t *testing.T) {
+	require.Equal(t, "application/json", Encoder("").ContentType())
+	require.Equal(t, "application/json", JsonEncoder.ContentType())
+	require.Equal(t, "application/bson", BsonEncoder.ContentType())
+}
@@ -0,0 +1,36 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// encryptedSubtype is the binary subtype of the values encrypted with client-side field level encryption, or
+// queryable encryption.
+const encryptedSubtype = 6
+
+// hasCiphertext returns true if the given document, or any of its nested documents and arrays, holds encrypted values,
+// i.e. values the client did not decrypt.
+func hasCiphertext(doc bson.Raw) bool {
+	values, err := doc.Values()
+	if err != nil {
+		return false
+	}
+	for _, value := range values {
+		switch value.Type {
+		case bsontype.Binary:
+			if subtype, _ := value.Binary(); subtype == encryptedSubtype {
+				return true
+			}
+		case bsontype.EmbeddedDocument:
+			if hasCiphertext(value.Document()) {
+				return true
+			}
+		case bsontype.Array:
+			if hasCiphertext(value.Array()) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_hasCiphertext(t *testing.T) {
+	ciphertext := primitive.Binary{Subtype: encryptedSubtype, Data: []byte{1, 2, 3}}
+	tests := []struct {
+		name        string
+		changeEvent bson.M
+		want        bool
+	}{
+		{
+			name:        "should find encrypted values of nested documents",
+			changeEvent: bson.M{"fullDocument": bson.M{"patient": bson.M{"ssn": ciphertext}}},
+			want:        true,
+		},
+		{
+			name:        "should find encrypted values of arrays",
+			changeEvent: bson.M{"updateDescription": bson.M{"updatedFields": bson.M{"cards": bson.A{ciphertext}}}},
+			want:        true,
+		},
+		{
+			name: "should ignore binaries of other subtypes",
+			changeEvent: bson.M{"fullDocument": bson.M{
+				"avatar": primitive.Binary{Subtype: 0, Data: []byte{1, 2, 3}},
+				"name":   "Ada",
+			}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changeEvent, _ := bson.Marshal(tt.changeEvent)
+
+			require.Equal(t, tt.want, hasCiphertext(changeEvent))
+		})
+	}
+}
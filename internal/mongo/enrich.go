@@ -0,0 +1,89 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidEnrichment = errors.New("an enrichment stage must be a single `$lookup`, `$addFields`, `$set`, `$unset` or `$project` stage in extended json")
+
+// EnrichmentStages are the aggregation stages an enrichment can be made of, i.e. the ones which add, replace or remove
+// fields of a document, without dropping nor duplicating it.
+var EnrichmentStages = []string{"$lookup", "$addFields", "$set", "$unset", "$project"}
+
+// Enrichment is an aggregation pipeline MongoDB runs on the full documents of the change events before they are
+// published.
+type Enrichment struct {
+	// Stages are the stages of the pipeline, in extended JSON.
+	Stages   []string
+	pipeline bson.A
+}
+
+// ParseEnrichment parses the given stages, each an aggregation stage in extended JSON, e.g.
+// {"$lookup":{"from":"customers","localField":"customerId","foreignField":"_id","as":"customer"}}. No stages returns
+// nil, which enriches nothing.
+func ParseEnrichment(stages []string) (*Enrichment, error) {
+	if len(stages) == 0 {
+		return nil, nil
+	}
+	e := &Enrichment{Stages: stages, pipeline: make(bson.A, 0, len(stages))}
+	for _, stage := range stages {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON([]byte(stage), false, &doc); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEnrichment, err)
+		}
+		if len(doc) != 1 || !slices.Contains(EnrichmentStages, doc[0].Key) {
+			return nil, ErrInvalidEnrichment
+		}
+		e.pipeline = append(e.pipeline, doc)
+	}
+	return e, nil
+}
+
+// enrich returns the given change event with its full document replaced by the one output by the enrichment pipeline,
+// run by MongoDB on the given database with the $documents stage. The change events without full document are returned
+// as is.
+func (e *Enrichment) enrich(ctx context.Context, db *mongo.Database, changeEvent bson.Raw) (bson.Raw, error) {
+	if e == nil {
+		return changeEvent, nil
+	}
+	doc, ok := changeEvent.Lookup("fullDocument").DocumentOK()
+	if !ok {
+		return changeEvent, nil
+	}
+	pipeline := append(bson.A{bson.D{{Key: "$documents", Value: bson.A{doc}}}}, e.pipeline...)
+	cursor, err := db.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("could not enrich change event: %w", err)
+	}
+	defer cursor.Close(ctx)
+	if !cursor.Next(ctx) {
+		if err = cursor.Err(); err != nil {
+			return nil, fmt.Errorf("could not enrich change event: %w", err)
+		}
+		return nil, errors.New("could not enrich change event: no document returned by the enrichment pipeline")
+	}
+	return withFullDocument(changeEvent, cursor.Current)
+}
+
+// withFullDocument returns a copy of the given change event whose full document is replaced by the given one.
+func withFullDocument(changeEvent, fullDocument bson.Raw) (bson.Raw, error) {
+	elems, err := changeEvent.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(bson.D, 0, len(elems))
+	for _, elem := range elems {
+		if elem.Key() == "fullDocument" {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: fullDocument})
+		} else {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		}
+	}
+	return bson.Marshal(doc)
+}
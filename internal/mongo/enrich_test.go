@@ -0,0 +1,62 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseEnrichment(t *testing.T) {
+	t.Run("should parse the stages", func(t *testing.T) {
+		enrichment, err := ParseEnrichment([]string{
+			`{"$lookup":{"from":"customers","localField":"customerId","foreignField":"_id","as":"customer"}}`,
+			`{"$set":{"customer":{"$first":"$customer.name"}}}`,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, enrichment.pipeline, 2)
+		require.Equal(t, "$lookup", enrichment.pipeline[0].(bson.D)[0].Key)
+	})
+	t.Run("should return nil if there are no stages", func(t *testing.T) {
+		enrichment, err := ParseEnrichment(nil)
+
+		require.NoError(t, err)
+		require.Nil(t, enrichment)
+	})
+	t.Run("should return error if a stage is invalid", func(t *testing.T) {
+		for _, stage := range []string{
+			`{"$lookup"`,
+			`{"$match":{"status":"paid"}}`,
+			`{"$set":{"a":1},"$unset":"b"}`,
+			`{}`,
+		} {
+			_, err := ParseEnrichment([]string{stage})
+
+			require.ErrorIs(t, err, ErrInvalidEnrichment, stage)
+		}
+	})
+}
+
+func Test_withFullDocument(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}, {Key: "customerId", Value: "c-1"}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+	})
+	require.NoError(t, err)
+	enriched, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: "order-1"},
+		{Key: "customerId", Value: "c-1"},
+		{Key: "customer", Value: "Ada"},
+	})
+	require.NoError(t, err)
+
+	changeEvent, err = withFullDocument(changeEvent, enriched)
+
+	require.NoError(t, err)
+	data, err := encode(changeEvent, JsonEncoder, "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"operationType":"insert","fullDocument":{"_id":"order-1","customerId":"c-1","customer":"Ada"},`+
+		`"documentKey":{"_id":"order-1"}}`, string(data))
+}
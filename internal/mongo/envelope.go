@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"errors"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MissingBeforePolicy represents what happens to the update and replace change events published in an envelope whose
+// pre-image is unavailable, e.g. since it expired or the collection did not record it yet.
+type MissingBeforePolicy string
+
+const (
+	// SkipMissingBeforePolicy skips the change events, which are not published.
+	SkipMissingBeforePolicy MissingBeforePolicy = "skip"
+
+	// PublishWithoutMissingBeforePolicy publishes the change events without their `before` field.
+	PublishWithoutMissingBeforePolicy MissingBeforePolicy = "publishWithout"
+
+	// FailMissingBeforePolicy fails the watcher, so that the change events are not published in an incomplete form.
+	FailMissingBeforePolicy MissingBeforePolicy = "fail"
+)
+
+var MissingBeforePolicies = []MissingBeforePolicy{
+	SkipMissingBeforePolicy,
+	PublishWithoutMissingBeforePolicy,
+	FailMissingBeforePolicy,
+}
+
+var (
+	ErrInvalidEnvelope = errors.New("an envelope missing before policy must be one of `skip`, `publishWithout`, `fail`")
+	ErrMissingBefore   = errors.New("pre-image of the change event is unavailable")
+)
+
+// The fields of the change events published in an envelope, holding their pre-image and post-image.
+const (
+	beforeField = "before"
+	afterField  = "after"
+)
+
+// Envelope publishes both the pre-image and the post-image of the documents of the update and replace change events in
+// a single message, in their `before` and `after` fields, in place of their `fullDocumentBeforeChange` and
+// `fullDocument` fields.
+type Envelope struct {
+	// MissingBefore is what happens to the change events whose pre-image is unavailable. If empty, they are published
+	// without it.
+	MissingBefore MissingBeforePolicy
+}
+
+// Validate returns an error if the missing before policy of the envelope is not supported.
+func (e *Envelope) Validate() error {
+	if e.MissingBefore != "" && !slices.Contains(MissingBeforePolicies, e.MissingBefore) {
+		return ErrInvalidEnvelope
+	}
+	return nil
+}
+
+// missesBefore reports whether the given change event, of the given operation type, is published in the given
+// envelope, if any, but its pre-image is unavailable.
+func (e *Envelope) missesBefore(operationType string, changeEvent bson.Raw) bool {
+	if e == nil || (operationType != updateOperationType && operationType != replacOperationType) {
+		return false
+	}
+	_, ok := changeEvent.Lookup("fullDocumentBeforeChange").DocumentOK()
+	return !ok
+}
+
+// envelop returns a copy of the given change event whose images are moved to the `before` and `after` fields of the
+// given envelope, if any, at the position of its full document. The change events which are not updates or
+// replacements are returned as is, and the images which are unavailable are left out.
+func envelop(changeEvent bson.Raw, e *Envelope) (bson.Raw, error) {
+	if e == nil {
+		return changeEvent, nil
+	}
+	operationType, _ := changeEvent.Lookup("operationType").StringValueOK()
+	if operationType != updateOperationType && operationType != replacOperationType {
+		return changeEvent, nil
+	}
+
+	elems, err := changeEvent.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(bson.D, 0, len(elems)+1)
+	for _, elem := range elems {
+		switch elem.Key() {
+		case "fullDocument":
+			if before, ok := changeEvent.Lookup("fullDocumentBeforeChange").DocumentOK(); ok {
+				doc = append(doc, bson.E{Key: beforeField, Value: before})
+			}
+			if after, ok := elem.Value().DocumentOK(); ok {
+				doc = append(doc, bson.E{Key: afterField, Value: after})
+			}
+		case "fullDocumentBeforeChange":
+			// moved along with the full document
+		default:
+			doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		}
+	}
+	return bson.Marshal(doc)
+}
@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEnvelope_Validate(t *testing.T) {
+	t.Run("should accept the supported policies", func(t *testing.T) {
+		require.NoError(t, (&Envelope{}).Validate())
+		for _, policy := range MissingBeforePolicies {
+			require.NoError(t, (&Envelope{MissingBefore: policy}).Validate())
+		}
+	})
+	t.Run("should reject an unsupported policy", func(t *testing.T) {
+		require.ErrorIs(t, (&Envelope{MissingBefore: "dlq"}).Validate(), ErrInvalidEnvelope)
+	})
+}
+
+func Test_envelop(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "update"},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+		{Key: "updateDescription", Value: bson.D{{Key: "updatedFields", Value: bson.D{{Key: "status", Value: "paid"}}}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}, {Key: "status", Value: "paid"}}},
+		{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "_id", Value: "order-1"}, {Key: "status", Value: "new"}}},
+	})
+	require.NoError(t, err)
+
+	t.Run("should move the images to the before and after fields", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Envelope: &Envelope{}})
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"operationType":"update","documentKey":{"_id":"order-1"},
+			"updateDescription":{"updatedFields":{"status":"paid"}},
+			"before":{"_id":"order-1","status":"new"},"after":{"_id":"order-1","status":"paid"}}`, string(data))
+	})
+	t.Run("should leave out the missing pre-image", func(t *testing.T) {
+		changeEvent, err := bson.Marshal(bson.D{{Key: "operationType", Value: "replace"},
+			{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+		require.NoError(t, err)
+
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Envelope: &Envelope{}})
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"operationType":"replace","after":{"_id":"order-1"}}`, string(data))
+	})
+	t.Run("should not envelop other change events", func(t *testing.T) {
+		changeEvent, err := bson.Marshal(bson.D{{Key: "operationType", Value: "insert"},
+			{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+		require.NoError(t, err)
+
+		enveloped, err := envelop(changeEvent, &Envelope{})
+
+		require.NoError(t, err)
+		require.Equal(t, bson.Raw(changeEvent), enveloped)
+	})
+	t.Run("should not envelop without envelope", func(t *testing.T) {
+		enveloped, err := envelop(changeEvent, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, bson.Raw(changeEvent), enveloped)
+	})
+}
+
+func TestEnvelope_missesBefore(t *testing.T) {
+	withoutBefore, err := bson.Marshal(bson.D{{Key: "operationType", Value: "update"},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+	require.NoError(t, err)
+	withBefore, err := bson.Marshal(bson.D{{Key: "operationType", Value: "update"},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+		{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+	require.NoError(t, err)
+	e := &Envelope{MissingBefore: SkipMissingBeforePolicy}
+
+	require.True(t, e.missesBefore("update", withoutBefore))
+	require.True(t, e.missesBefore("replace", withoutBefore))
+	require.False(t, e.missesBefore("update", withBefore))
+	require.False(t, e.missesBefore("insert", withoutBefore))
+	require.False(t, (*Envelope)(nil).missesBefore("update", withoutBefore))
+}
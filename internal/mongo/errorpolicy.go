@@ -0,0 +1,112 @@
+package mongo
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrorClass represents a class of errors the change events of a collection can fail with.
+type ErrorClass string
+
+const (
+	// DecodeErrorClass is the class of the change events that cannot be encoded, e.g. because they hold values that
+	// cannot be represented in JSON.
+	DecodeErrorClass ErrorClass = "decode"
+
+	// TransformErrorClass is the class of the change events that cannot be shaped before being encoded, i.e. whose
+	// subject or header templates cannot be executed, or which cannot be slimmed, reshaped, flattened or converted.
+	TransformErrorClass ErrorClass = "transform"
+
+	// PublishTimeoutErrorClass is the class of the change events the stream did not acknowledge in time.
+	PublishTimeoutErrorClass ErrorClass = "publishTimeout"
+
+	// TokenSaveErrorClass is the class of the resume tokens that cannot be persisted.
+	TokenSaveErrorClass ErrorClass = "tokenSave"
+
+	// UnknownTenantErrorClass is the class of the change events of tenants with no NATS account.
+	UnknownTenantErrorClass ErrorClass = "unknownTenant"
+)
+
+// ErrorAction represents what is done once a change event, or its resume token, fails with an error of a class.
+type ErrorAction string
+
+const (
+	// RetryErrorAction retries with the retry policy of the connector, then halts once its budget is exhausted.
+	RetryErrorAction ErrorAction = "retry"
+
+	// SkipErrorAction logs the error and moves on to the next change event, or resume token.
+	SkipErrorAction ErrorAction = "skip"
+
+	// DlqErrorAction publishes the change event to a dead letter subject.
+	DlqErrorAction ErrorAction = "dlq"
+
+	// HaltErrorAction stops the watcher, without retrying.
+	HaltErrorAction ErrorAction = "halt"
+)
+
+// errorActions are the actions supported by each error class, the first one being its default.
+var errorActions = map[ErrorClass][]ErrorAction{
+	DecodeErrorClass:         {HaltErrorAction, SkipErrorAction, DlqErrorAction},
+	TransformErrorClass:      {HaltErrorAction, SkipErrorAction, DlqErrorAction},
+	PublishTimeoutErrorClass: {RetryErrorAction, SkipErrorAction, DlqErrorAction, HaltErrorAction},
+	TokenSaveErrorClass:      {RetryErrorAction, SkipErrorAction, HaltErrorAction},
+	UnknownTenantErrorClass:  {SkipErrorAction, DlqErrorAction, HaltErrorAction},
+}
+
+var ErrInvalidErrorPolicies = errors.New("error policies must map `decode` and `transform` to `halt`, `skip` or `dlq`, `publishTimeout` to `retry`, `skip`, `dlq` or `halt`, `tokenSave` to `retry`, `skip` or `halt`, and `unknownTenant` to `skip`, `dlq` or `halt`")
+
+// ErrorPolicies maps the classes of errors to the actions taken once they occur.
+type ErrorPolicies map[ErrorClass]ErrorAction
+
+// Validate returns an error if an error class is unknown, or if its action is not supported by it.
+func (p ErrorPolicies) Validate() error {
+	for class, action := range p {
+		actions, ok := errorActions[class]
+		if !ok || !slices.Contains(actions, action) {
+			return ErrInvalidErrorPolicies
+		}
+	}
+	return nil
+}
+
+// Action returns the action taken once an error of the given class occurs, which is the default one of the class if
+// it is not mapped.
+func (p ErrorPolicies) Action(class ErrorClass) ErrorAction {
+	if action, ok := p[class]; ok {
+		return action
+	}
+	return errorActions[class][0]
+}
+
+// Dlq returns true if an error class is mapped to the dlq action.
+func (p ErrorPolicies) Dlq() bool {
+	for _, action := range p {
+		if action == DlqErrorAction {
+			return true
+		}
+	}
+	return false
+}
+
+// transformError is the error of a change event that cannot be shaped before being encoded.
+type transformError struct {
+	err error
+}
+
+func (e *transformError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transformError) Unwrap() error {
+	return e.err
+}
+
+// errorClassOf returns the class of the given error of a change event that cannot be published, which is either a
+// transform or a decode error.
+func errorClassOf(err error) ErrorClass {
+	var transformErr *transformError
+	if errors.As(err, &transformErr) {
+		return TransformErrorClass
+	}
+	return DecodeErrorClass
+}
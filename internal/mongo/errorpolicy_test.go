@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestErrorPolicies_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies ErrorPolicies
+		wantErr  bool
+	}{
+		{name: "should accept no policies", policies: nil},
+		{name: "should accept the actions supported by each class", policies: ErrorPolicies{
+			DecodeErrorClass: DlqErrorAction, TransformErrorClass: SkipErrorAction,
+			PublishTimeoutErrorClass: HaltErrorAction, TokenSaveErrorClass: RetryErrorAction,
+			UnknownTenantErrorClass: DlqErrorAction}},
+		{name: "should reject an unknown class", policies: ErrorPolicies{"encode": SkipErrorAction}, wantErr: true},
+		{name: "should reject an unknown action", policies: ErrorPolicies{DecodeErrorClass: "ignore"}, wantErr: true},
+		{name: "should reject retrying decode errors", policies: ErrorPolicies{DecodeErrorClass: RetryErrorAction},
+			wantErr: true},
+		{name: "should reject publishing resume tokens to the dead letter subject",
+			policies: ErrorPolicies{TokenSaveErrorClass: DlqErrorAction}, wantErr: true},
+		{name: "should reject retrying the change events of unknown tenants",
+			policies: ErrorPolicies{UnknownTenantErrorClass: RetryErrorAction}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policies.Validate()
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidErrorPolicies)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestErrorPolicies_Action(t *testing.T) {
+	policies := ErrorPolicies{PublishTimeoutErrorClass: SkipErrorAction}
+
+	require.Equal(t, SkipErrorAction, policies.Action(PublishTimeoutErrorClass))
+	require.Equal(t, RetryErrorAction, policies.Action(TokenSaveErrorClass), "token saves are retried by default")
+	require.Equal(t, HaltErrorAction, policies.Action(TransformErrorClass))
+	require.Equal(t, SkipErrorAction, policies.Action(UnknownTenantErrorClass),
+		"the change events of unknown tenants are skipped by default")
+	require.False(t, policies.Dlq())
+	require.True(t, ErrorPolicies{TransformErrorClass: DlqErrorAction}.Dlq())
+}
+
+func TestChangeStreamWatcher_errorAction(t *testing.T) {
+	t.Run("should handle the transform errors which are not mapped as decode errors", func(t *testing.T) {
+		w := &changeStreamWatcher{opts: &WatchCollectionOptions{DecodeErrorPolicy: SkipDecodeErrorPolicy}}
+
+		require.Equal(t, SkipErrorAction, w.errorAction(DecodeErrorClass))
+		require.Equal(t, SkipErrorAction, w.errorAction(TransformErrorClass))
+	})
+	t.Run("should handle the transform errors which are mapped with their action", func(t *testing.T) {
+		w := &changeStreamWatcher{opts: &WatchCollectionOptions{DecodeErrorPolicy: HaltDecodeErrorPolicy,
+			ErrorPolicies: ErrorPolicies{TransformErrorClass: DlqErrorAction}}}
+
+		require.Equal(t, HaltErrorAction, w.errorAction(DecodeErrorClass))
+		require.Equal(t, DlqErrorAction, w.errorAction(TransformErrorClass))
+	})
+}
+
+func Test_errorClassOf(t *testing.T) {
+	t.Run("should classify the errors of the steps before the encoding as transform errors", func(t *testing.T) {
+		_, err := encodeChangeEvent(bson.Raw{0x05}, &WatchCollectionOptions{Reshape: &Reshape{}})
+
+		require.Error(t, err)
+		require.Equal(t, TransformErrorClass, errorClassOf(err))
+	})
+	t.Run("should classify the other errors as decode errors", func(t *testing.T) {
+		require.Equal(t, DecodeErrorClass, errorClassOf(errors.New("could not encode")))
+	})
+}
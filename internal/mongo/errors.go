@@ -0,0 +1,66 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// ErrUnpublishable is returned by the change event handlers for the change events which can never be published.
+var ErrUnpublishable = errors.New("change event cannot be published")
+
+var transientErrorLabels = []string{
+	"NetworkError",
+	"RetryableWriteError",
+	"TransientTransactionError",
+}
+
+// isTransientError reports whether the given error is expected to go away by simply retrying the operation.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) {
+		for _, label := range transientErrorLabels {
+			if labeled.HasErrorLabel(label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authenticationFailedErrCode is returned once the credentials are rejected.
+const authenticationFailedErrCode = 18
+
+// isReconnectableError reports whether the given error means that mongodb could not be reached, or could not
+// authenticate the connector.
+func isReconnectableError(err error) bool {
+	if isTransientError(err) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return true
+	}
+	var selectionErr topology.ServerSelectionError
+	var connErr topology.ConnectionError
+	if errors.As(err, &selectionErr) || errors.As(err, &connErr) {
+		return true
+	}
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(authenticationFailedErrCode)
+}
+
+// changeStreamHistoryLostErrCode is the error code returned by mongodb when a change stream is resumed from a resume
+// token that is no longer in the oplog.
+const changeStreamHistoryLostErrCode = 286
+
+// IsTokenExpiredError reports whether the given error means that the change stream cannot be resumed, since its resume
+// token fell off the oplog.
+func IsTokenExpiredError(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(changeStreamHistoryLostErrCode)
+}
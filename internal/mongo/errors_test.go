@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func Test_isTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "should return false if there is no error",
+			err:  nil,
+			want: false,
+		},
+		{
+			name: "should return true for network errors",
+			err:  mongo.CommandError{Labels: []string{"NetworkError"}},
+			want: true,
+		},
+		{
+			name: "should return true for retryable write errors",
+			err:  fmt.Errorf("wrapped: %w", mongo.CommandError{Labels: []string{"RetryableWriteError"}}),
+			want: true,
+		},
+		{
+			name: "should return true for timeouts",
+			err:  context.DeadlineExceeded,
+			want: true,
+		},
+		{
+			name: "should return false if context was cancelled",
+			err:  context.Canceled,
+			want: false,
+		},
+		{
+			name: "should return false for duplicate key errors",
+			err:  mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}},
+			want: false,
+		},
+		{
+			name: "should return false for generic errors",
+			err:  errors.New("generic error"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isTransientError(tt.err))
+		})
+	}
+}
+
+func Test_isReconnectableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "should return false if there is no error",
+			err:  nil,
+			want: false,
+		},
+		{
+			name: "should return true for transient errors",
+			err:  mongo.CommandError{Labels: []string{"NetworkError"}},
+			want: true,
+		},
+		{
+			name: "should return true if the client is disconnected",
+			err:  fmt.Errorf("change stream failed: %w", mongo.ErrClientDisconnected),
+			want: true,
+		},
+		{
+			name: "should return true if no server could be selected",
+			err:  topology.ServerSelectionError{Wrapped: errors.New("server selection timeout")},
+			want: true,
+		},
+		{
+			name: "should return true if the connection could not be established",
+			err:  topology.ConnectionError{Wrapped: errors.New("auth error")},
+			want: true,
+		},
+		{
+			name: "should return true if authentication failed",
+			err:  mongo.CommandError{Code: 18, Name: "AuthenticationFailed"},
+			want: true,
+		},
+		{
+			name: "should return false if context was cancelled",
+			err:  context.Canceled,
+			want: false,
+		},
+		{
+			name: "should return false for generic errors",
+			err:  errors.New("generic error"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isReconnectableError(tt.err))
+		})
+	}
+}
+
+func TestIsTokenExpiredError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "should return false if there is no error",
+			err:  nil,
+			want: false,
+		},
+		{
+			name: "should return true if the change stream history is lost",
+			err: fmt.Errorf("could not watch mongo collection orders: %w",
+				mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost"}),
+			want: true,
+		},
+		{
+			name: "should return false for other server errors",
+			err:  mongo.CommandError{Code: 18, Name: "AuthenticationFailed"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, IsTokenExpiredError(tt.err))
+		})
+	}
+}
+
+func TestChangeStreamWatcher_handleFlushError(t *testing.T) {
+	w := &changeStreamWatcher{client: &DefaultClient{logger: slog.Default()}, opts: &WatchCollectionOptions{}}
+
+	resume, err := w.handleFlushError(&publishError{err: errors.New("nats: timeout")})
+	require.True(t, resume)
+	require.NoError(t, err)
+
+	resume, err = w.handleFlushError(&publishError{err: fmt.Errorf("%w: unknown tenant", ErrUnpublishable)})
+	require.False(t, resume, "the change events which can never be published are not retried")
+	require.ErrorIs(t, err, ErrUnpublishable)
+}
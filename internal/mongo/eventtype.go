@@ -0,0 +1,75 @@
+package mongo
+
+import (
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrInvalidEventTypes = errors.New("event types must map the `insert`, `update`, `replace` or `delete` operation types to valid subjects")
+
+// EventTypes maps the operation types of the change events of documents to domain event types, e.g. delete to
+// order.removed, used in place of the operation types in subjects, and published in the eventType field of the change
+// events and in their headers.
+type EventTypes map[string]string
+
+// Validate returns an error if an operation type is not the one of the change events of documents, or if an event type
+// is not a valid subject.
+func (e EventTypes) Validate() error {
+	for operationType, eventType := range e {
+		if _, ok := filteredOperationTypes[operationType]; !ok {
+			return ErrInvalidEventTypes
+		}
+		if ValidSubject(eventType) != nil {
+			return ErrInvalidEventTypes
+		}
+	}
+	return nil
+}
+
+// of returns the event type of the given operation type, which is the operation type itself if it is not mapped.
+func (e EventTypes) of(operationType string) string {
+	if eventType, ok := e[operationType]; ok {
+		return eventType
+	}
+	return operationType
+}
+
+// multiToken returns true if an event type is made of several subject tokens, e.g. order.removed.
+func (e EventTypes) multiToken() bool {
+	for _, eventType := range e {
+		if strings.Contains(eventType, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTypeOf returns the event type of the given change event, or an empty string if the given options map no
+// operation types, or if it is not the change event of a document.
+func eventTypeOf(opts *WatchCollectionOptions, operationType string) string {
+	if len(opts.EventTypes) == 0 {
+		return ""
+	}
+	if _, ok := filteredOperationTypes[operationType]; !ok {
+		return ""
+	}
+	return opts.EventTypes.of(operationType)
+}
+
+// withEventType returns a copy of the given change event with the given event type in its eventType field.
+func withEventType(changeEvent bson.Raw, eventType string) (bson.Raw, error) {
+	elems, err := changeEvent.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(bson.D, 0, len(elems)+1)
+	for _, elem := range elems {
+		doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		if elem.Key() == "operationType" {
+			doc = append(doc, bson.E{Key: "eventType", Value: eventType})
+		}
+	}
+	return bson.Marshal(doc)
+}
@@ -0,0 +1,66 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEventTypes_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventTypes EventTypes
+		wantErr    error
+	}{
+		{
+			name:       "should accept the operation types of documents mapped to subjects",
+			eventTypes: EventTypes{"insert": "order.created", "delete": "order.removed"},
+		},
+		{
+			name:       "should return error if the operation type is not the one of documents",
+			eventTypes: EventTypes{"rename": "order.renamed"},
+			wantErr:    ErrInvalidEventTypes,
+		},
+		{
+			name:       "should return error if the event type is not a valid subject",
+			eventTypes: EventTypes{"insert": "order.*"},
+			wantErr:    ErrInvalidEventTypes,
+		},
+		{
+			name:       "should return error if the event type is empty",
+			eventTypes: EventTypes{"insert": ""},
+			wantErr:    ErrInvalidEventTypes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, tt.eventTypes.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func Test_eventTypeOf(t *testing.T) {
+	opts := &WatchCollectionOptions{EventTypes: EventTypes{"delete": "order.removed"}}
+
+	require.Equal(t, "order.removed", eventTypeOf(opts, "delete"))
+	require.Equal(t, "insert", eventTypeOf(opts, "insert"))
+	require.Empty(t, eventTypeOf(opts, "rename"))
+	require.Empty(t, eventTypeOf(&WatchCollectionOptions{}, "delete"))
+}
+
+func Test_withEventType(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "delete"},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+	})
+	require.NoError(t, err)
+
+	changeEvent, err = withEventType(changeEvent, "order.removed")
+
+	require.NoError(t, err)
+	data, err := encode(changeEvent, JsonEncoder, "")
+	require.NoError(t, err)
+	require.Equal(t, `{"operationType":"delete","eventType":"order.removed","documentKey":{"_id":"order-1"}}`,
+		string(data))
+}
@@ -0,0 +1,99 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// expiresAtField is the field of the documents of the expiring collections holding the time they are deleted at
+	// by their TTL index. The documents without it are never deleted.
+	expiresAtField = "expiresAt"
+
+	expiresAtIndexName = "expiresAt_ttl"
+
+	// uncappingSuffix suffixes the name of the temporary collection a capped collection is migrated to.
+	uncappingSuffix = ".uncapping"
+)
+
+// isCapped returns true if the given collection specification is the one of a capped collection.
+func isCapped(spec *mongo.CollectionSpecification) bool {
+	if spec.Options == nil {
+		return false
+	}
+	capped, _ := spec.Options.Lookup("capped").BooleanOK()
+	return capped
+}
+
+// provisionExpiry creates the TTL index deleting the documents of the given collection once their expiresAt field is
+// past. If the collection is capped, which cannot hold a TTL index, it is uncapped first.
+func (c *DefaultClient) provisionExpiry(ctx context.Context, db *mongo.Database, collName string, capped bool) error {
+	if capped {
+		if err := c.uncap(ctx, db, collName); err != nil {
+			return err
+		}
+	}
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: expiresAtField, Value: 1}},
+		Options: options.Index().SetName(expiresAtIndexName).SetExpireAfterSeconds(0),
+	}
+	if _, err := db.Collection(collName).Indexes().CreateOne(ctx, index); err != nil {
+		return fmt.Errorf("could not create ttl index of mongo collection %v: %v", collName, err)
+	}
+	return nil
+}
+
+// uncap replaces the given capped collection by an uncapped one holding its last inserted document.
+// The document is copied into a temporary collection, which is then renamed over the capped one.
+func (c *DefaultClient) uncap(ctx context.Context, db *mongo.Database, collName string) error {
+	tmpName := collName + uncappingSuffix
+	tmp := db.Collection(tmpName)
+	// the temporary collection may be left over by an interrupted migration, the capped one being still in place
+	if err := tmp.Drop(ctx); err != nil {
+		return fmt.Errorf("could not drop mongo collection %v: %v", tmpName, err)
+	}
+	if err := db.CreateCollection(ctx, tmpName); err != nil {
+		return fmt.Errorf("could not create mongo collection %v: %v", tmpName, err)
+	}
+	last, err := db.Collection(collName).FindOne(ctx, bson.D{},
+		options.FindOne().SetSort(bson.D{{Key: "$natural", Value: -1}})).Raw()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("could not find last document of mongo collection %v: %v", collName, err)
+	}
+	if last != nil {
+		if _, err = tmp.InsertOne(ctx, last); err != nil {
+			return fmt.Errorf("could not copy last document of mongo collection %v: %v", collName, err)
+		}
+	}
+	rename := bson.D{
+		{Key: "renameCollection", Value: db.Name() + "." + tmpName},
+		{Key: "to", Value: db.Name() + "." + collName},
+		{Key: "dropTarget", Value: true},
+	}
+	if err = db.Client().Database("admin").RunCommand(ctx, rename).Err(); err != nil {
+		return fmt.Errorf("could not rename mongo collection %v to %v: %v", tmpName, collName, err)
+	}
+	c.logger.Info("migrated capped mongodb collection to an expiring one", "dbName", db.Name(), "collName", collName,
+		"lastDocument", last != nil)
+	return nil
+}
+
+// expireSuperseded sets the expiry of the resume tokens of the given collection superseded by the given one, which
+// are deleted once the given ttl elapsed. The last resume token never expires, however long it is not superseded.
+func expireSuperseded(ctx context.Context, coll *mongo.Collection, lastId any, ttl time.Duration) error {
+	filter := bson.D{
+		{Key: expiresAtField, Value: nil}, // supported by the ttl index
+		{Key: "_id", Value: bson.D{{Key: "$ne", Value: lastId}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: expiresAtField, Value: time.Now().Add(ttl)}}}}
+	if _, err := coll.UpdateMany(ctx, filter, update); err != nil {
+		return fmt.Errorf("could not expire superseded resume tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_isCapped(t *testing.T) {
+	options := func(doc bson.D) bson.Raw {
+		raw, err := bson.Marshal(doc)
+		require.NoError(t, err)
+		return raw
+	}
+
+	require.True(t, isCapped(&mongo.CollectionSpecification{
+		Options: options(bson.D{{Key: "capped", Value: true}, {Key: "size", Value: 4096}})}))
+	require.False(t, isCapped(&mongo.CollectionSpecification{Options: options(bson.D{{Key: "capped", Value: false}})}))
+	require.False(t, isCapped(&mongo.CollectionSpecification{Options: options(bson.D{})}))
+	require.False(t, isCapped(&mongo.CollectionSpecification{}))
+}
@@ -0,0 +1,209 @@
+package mongo
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// FilterOperator represents how the field of a change event is compared by a filter.
+type FilterOperator string
+
+const (
+	EqFilterOperator  FilterOperator = "eq"
+	NeFilterOperator  FilterOperator = "ne"
+	GtFilterOperator  FilterOperator = "gt"
+	GteFilterOperator FilterOperator = "gte"
+	LtFilterOperator  FilterOperator = "lt"
+	LteFilterOperator FilterOperator = "lte"
+	InFilterOperator  FilterOperator = "in"
+	NinFilterOperator FilterOperator = "nin"
+
+	// ExistsFilterOperator checks whether the field exists, and is not null, or does not exist if the value is false.
+	ExistsFilterOperator FilterOperator = "exists"
+)
+
+var FilterOperators = []FilterOperator{
+	EqFilterOperator,
+	NeFilterOperator,
+	GtFilterOperator,
+	GteFilterOperator,
+	LtFilterOperator,
+	LteFilterOperator,
+	InFilterOperator,
+	NinFilterOperator,
+	ExistsFilterOperator,
+}
+
+var (
+	ErrInvalidFilter         = errors.New("a filter must have either a field, or and / or groups")
+	ErrInvalidFilterOperator = errors.New("unknown filter operator")
+	ErrInvalidFilterValue    = errors.New("the value of an exists filter must be true or false")
+)
+
+// filteredOperationTypes are the operation types of the change events that filters apply to, i.e. the ones holding
+// documents.
+var filteredOperationTypes = map[string]struct{}{
+	insertOperationType: {},
+	updateOperationType: {},
+	replacOperationType: {},
+	deleteOperationType: {},
+}
+
+// Filter is a predicate on the fields of change events, which is either a condition on a single field, or a group of
+// filters which must all match (And), or at least one of them (Or).
+type Filter struct {
+	// Field is the path of the field in the change event, using the dot notation, e.g. fullDocumentBeforeChange.status.
+	Field    string
+	Operator FilterOperator
+	Value    string
+	// Values are the values compared by the in and nin operators.
+	Values []string
+	And    []Filter
+	Or     []Filter
+}
+
+// Validate returns an error if the filter, or any of its groups, is invalid.
+func (f *Filter) Validate() error {
+	set := 0
+	for _, isSet := range []bool{f.Field != "", len(f.And) > 0, len(f.Or) > 0} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return ErrInvalidFilter
+	}
+	if f.Field != "" {
+		if !slices.Contains(FilterOperators, f.Operator) {
+			return ErrInvalidFilterOperator
+		}
+		if f.Operator == ExistsFilterOperator && f.Value != "" && f.Value != "true" && f.Value != "false" {
+			return ErrInvalidFilterValue
+		}
+	}
+	for _, group := range [][]Filter{f.And, f.Or} {
+		for i := range group {
+			if err := group[i].Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matches returns true if the given change event matches the filter.
+func (f *Filter) matches(changeEvent bson.Raw) bool {
+	switch {
+	case len(f.And) > 0:
+		return !slices.ContainsFunc(f.And, func(g Filter) bool { return !g.matches(changeEvent) })
+	case len(f.Or) > 0:
+		return slices.ContainsFunc(f.Or, func(g Filter) bool { return g.matches(changeEvent) })
+	}
+	value, err := changeEvent.LookupErr(strings.Split(f.Field, ".")...)
+	exists := err == nil && value.Type != bsontype.Null && value.Type != bsontype.Undefined
+	switch f.Operator {
+	case ExistsFilterOperator:
+		return exists == (f.Value != "false")
+	case EqFilterOperator:
+		return exists && stringOf(value) == f.Value
+	case NeFilterOperator:
+		return !exists || stringOf(value) != f.Value
+	case InFilterOperator:
+		return exists && slices.Contains(f.Values, stringOf(value))
+	case NinFilterOperator:
+		return !exists || !slices.Contains(f.Values, stringOf(value))
+	}
+	if !exists {
+		return false
+	}
+	cmp, ok := compare(value, f.Value)
+	if !ok {
+		return false
+	}
+	switch f.Operator {
+	case GtFilterOperator:
+		return cmp > 0
+	case GteFilterOperator:
+		return cmp >= 0
+	case LtFilterOperator:
+		return cmp < 0
+	case LteFilterOperator:
+		return cmp <= 0
+	}
+	return false
+}
+
+// filtered returns true if the given change event is dropped by the filter of the given options, if any.
+func filtered(opts *WatchCollectionOptions, operationType string, changeEvent bson.Raw) bool {
+	if opts.Filter == nil {
+		return false
+	}
+	if _, ok := filteredOperationTypes[operationType]; !ok {
+		return false
+	}
+	return !opts.Filter.matches(changeEvent)
+}
+
+// stringOf returns the string representation of the given value, as compared with the values of filters.
+func stringOf(value bson.RawValue) string {
+	switch value.Type {
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex()
+	case bsontype.Int32, bsontype.Int64, bsontype.Double:
+		n, _ := number(value)
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	case bsontype.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	default:
+		return value.String()
+	}
+}
+
+// compare compares the given value with the given filter value, numerically for numbers, chronologically for dates,
+// given in RFC 3339, and lexicographically for strings. It returns false if they cannot be compared.
+func compare(value bson.RawValue, filterValue string) (int, bool) {
+	switch value.Type {
+	case bsontype.Int32, bsontype.Int64, bsontype.Double:
+		want, err := strconv.ParseFloat(filterValue, 64)
+		if err != nil {
+			return 0, false
+		}
+		n, _ := number(value)
+		switch {
+		case n < want:
+			return -1, true
+		case n > want:
+			return 1, true
+		}
+		return 0, true
+	case bsontype.DateTime:
+		want, err := time.Parse(time.RFC3339, filterValue)
+		if err != nil {
+			return 0, false
+		}
+		return value.Time().Compare(want), true
+	case bsontype.String:
+		return strings.Compare(value.StringValue(), filterValue), true
+	}
+	return 0, false
+}
+
+func number(value bson.RawValue) (float64, bool) {
+	switch value.Type {
+	case bsontype.Int32:
+		return float64(value.Int32()), true
+	case bsontype.Int64:
+		return float64(value.Int64()), true
+	case bsontype.Double:
+		return value.Double(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,199 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFilter_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr error
+	}{
+		{
+			name:   "should accept a field condition",
+			filter: Filter{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "paid"},
+		},
+		{
+			name: "should accept nested groups",
+			filter: Filter{Or: []Filter{
+				{Field: "fullDocument.status", Operator: ExistsFilterOperator, Value: "false"},
+				{And: []Filter{{Field: "fullDocument.total", Operator: GtFilterOperator, Value: "100"}}},
+			}},
+		},
+		{
+			name:    "should return error if the filter is empty",
+			filter:  Filter{},
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name: "should return error if the filter has both a field and groups",
+			filter: Filter{Field: "fullDocument.status", Operator: EqFilterOperator,
+				And: []Filter{{Field: "fullDocument.total", Operator: GtFilterOperator, Value: "100"}}},
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "should return error if the operator is unknown",
+			filter:  Filter{Field: "fullDocument.status", Operator: "regex"},
+			wantErr: ErrInvalidFilterOperator,
+		},
+		{
+			name:    "should return error if the value of an exists filter is not a boolean",
+			filter:  Filter{Field: "fullDocument.status", Operator: ExistsFilterOperator, Value: "yes"},
+			wantErr: ErrInvalidFilterValue,
+		},
+		{
+			name:    "should return error if a group is invalid",
+			filter:  Filter{And: []Filter{{Field: "fullDocument.status"}}},
+			wantErr: ErrInvalidFilterOperator,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, tt.filter.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestFilter_matches(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.M{
+		"operationType": "update",
+		"fullDocument":  bson.M{"status": "paid", "total": int32(150), "rate": 0.5, "note": nil},
+		"fullDocumentBeforeChange": bson.M{
+			"status":    "pending",
+			"updatedAt": time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+	})
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{
+			name:   "eq should match equal strings",
+			filter: Filter{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "paid"},
+			want:   true,
+		},
+		{
+			name:   "eq should match equal numbers",
+			filter: Filter{Field: "fullDocument.total", Operator: EqFilterOperator, Value: "150"},
+			want:   true,
+		},
+		{
+			name:   "eq should not match missing fields",
+			filter: Filter{Field: "fullDocument.region", Operator: EqFilterOperator, Value: ""},
+			want:   false,
+		},
+		{
+			name:   "ne should match other values",
+			filter: Filter{Field: "fullDocumentBeforeChange.status", Operator: NeFilterOperator, Value: "paid"},
+			want:   true,
+		},
+		{
+			name:   "ne should match missing fields",
+			filter: Filter{Field: "fullDocument.region", Operator: NeFilterOperator, Value: "eu"},
+			want:   true,
+		},
+		{
+			name:   "gt should compare numbers numerically",
+			filter: Filter{Field: "fullDocument.total", Operator: GtFilterOperator, Value: "99"},
+			want:   true,
+		},
+		{
+			name:   "lte should compare doubles",
+			filter: Filter{Field: "fullDocument.rate", Operator: LteFilterOperator, Value: "0.5"},
+			want:   true,
+		},
+		{
+			name:   "lt should compare strings lexicographically",
+			filter: Filter{Field: "fullDocument.status", Operator: LtFilterOperator, Value: "pending"},
+			want:   true,
+		},
+		{
+			name: "gte should compare dates chronologically",
+			filter: Filter{Field: "fullDocumentBeforeChange.updatedAt", Operator: GteFilterOperator,
+				Value: "2024-05-01T12:00:00Z"},
+			want: true,
+		},
+		{
+			name:   "gt should not match values which cannot be compared",
+			filter: Filter{Field: "fullDocument.total", Operator: GtFilterOperator, Value: "many"},
+			want:   false,
+		},
+		{
+			name:   "gt should not match missing fields",
+			filter: Filter{Field: "fullDocument.region", Operator: GtFilterOperator, Value: "a"},
+			want:   false,
+		},
+		{
+			name:   "in should match one of the values",
+			filter: Filter{Field: "fullDocument.status", Operator: InFilterOperator, Values: []string{"paid", "shipped"}},
+			want:   true,
+		},
+		{
+			name:   "nin should not match one of the values",
+			filter: Filter{Field: "fullDocument.status", Operator: NinFilterOperator, Values: []string{"paid", "shipped"}},
+			want:   false,
+		},
+		{
+			name:   "exists should match present fields",
+			filter: Filter{Field: "fullDocumentBeforeChange.status", Operator: ExistsFilterOperator},
+			want:   true,
+		},
+		{
+			name:   "exists should not match null fields",
+			filter: Filter{Field: "fullDocument.note", Operator: ExistsFilterOperator, Value: "true"},
+			want:   false,
+		},
+		{
+			name:   "exists false should match missing fields",
+			filter: Filter{Field: "fullDocument.region", Operator: ExistsFilterOperator, Value: "false"},
+			want:   true,
+		},
+		{
+			name: "and should match if all the filters match",
+			filter: Filter{And: []Filter{
+				{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "paid"},
+				{Field: "fullDocumentBeforeChange.status", Operator: EqFilterOperator, Value: "pending"},
+			}},
+			want: true,
+		},
+		{
+			name: "and should not match if a filter does not match",
+			filter: Filter{And: []Filter{
+				{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "paid"},
+				{Field: "fullDocument.total", Operator: LtFilterOperator, Value: "100"},
+			}},
+			want: false,
+		},
+		{
+			name: "or should match if a filter matches",
+			filter: Filter{Or: []Filter{
+				{Field: "fullDocument.total", Operator: LtFilterOperator, Value: "100"},
+				{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "paid"},
+			}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.filter.Validate())
+			require.Equal(t, tt.want, tt.filter.matches(changeEvent))
+		})
+	}
+}
+
+func Test_filtered(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.M{"fullDocument": bson.M{"status": "draft"}})
+	filter := &Filter{Field: "fullDocument.status", Operator: NeFilterOperator, Value: "draft"}
+
+	require.False(t, filtered(&WatchCollectionOptions{}, insertOperationType, changeEvent))
+	require.True(t, filtered(&WatchCollectionOptions{Filter: filter}, insertOperationType, changeEvent))
+	require.False(t, filtered(&WatchCollectionOptions{Filter: filter}, renameOperationType, changeEvent),
+		"change events without documents should never be filtered")
+}
@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ArrayFlattening represents how the arrays of the change events are flattened.
+type ArrayFlattening string
+
+const (
+	// KeepArrayFlattening keeps the arrays as values of their flattened key, e.g. {"tags":["a","b"]}, whose documents
+	// are flattened as well.
+	KeepArrayFlattening ArrayFlattening = "keep"
+
+	// IndexArrayFlattening flattens the arrays into one key per element, suffixed by its index, e.g.
+	// {"tags.0":"a","tags.1":"b"}.
+	IndexArrayFlattening ArrayFlattening = "index"
+)
+
+var ArrayFlattenings = []ArrayFlattening{
+	KeepArrayFlattening,
+	IndexArrayFlattening,
+}
+
+// defaultFlattenSeparator joins the keys of the flattened fields, as in the dot notation.
+const defaultFlattenSeparator = "."
+
+var ErrInvalidFlatten = errors.New("a flatten separator must not contain `$` nor whitespaces, and its arrays must be one of `keep`, `index`")
+
+// Flatten flattens the nested documents of the published change events into keys joining the keys of their fields,
+// e.g. {"fullDocument":{"customer":{"name":"Ada"}}} into {"fullDocument.customer.name":"Ada"}, for the sinks loading
+// the change events into tables, which expect a single level of columns.
+type Flatten struct {
+	// Separator joins the keys of the flattened fields. If empty, they are joined with dots.
+	Separator string
+	// Arrays is how the arrays are flattened. If empty, they are kept.
+	Arrays ArrayFlattening
+}
+
+// Validate returns an error if the separator or the array flattening of the flatten is not supported.
+func (f *Flatten) Validate() error {
+	if strings.ContainsAny(f.Separator, " \t\r\n$") {
+		return ErrInvalidFlatten
+	}
+	if f.Arrays != "" && !slices.Contains(ArrayFlattenings, f.Arrays) {
+		return ErrInvalidFlatten
+	}
+	return nil
+}
+
+// flatten returns a copy of the given change event whose nested documents are flattened by the given flatten, if any.
+// The empty documents and arrays are kept as values of their flattened key, so that no field is lost.
+func flatten(changeEvent bson.Raw, f *Flatten) (bson.Raw, error) {
+	if f == nil {
+		return changeEvent, nil
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(changeEvent, &doc); err != nil {
+		return nil, err
+	}
+	separator := f.Separator
+	if separator == "" {
+		separator = defaultFlattenSeparator
+	}
+	return bson.Marshal(f.flattenDocument(make(bson.D, 0, len(doc)), "", separator, doc))
+}
+
+// flattenDocument appends the flattened fields of the given document to the given flattened document, with their keys
+// prefixed by the given prefix.
+func (f *Flatten) flattenDocument(flattened bson.D, prefix, separator string, doc bson.D) bson.D {
+	for _, elem := range doc {
+		flattened = f.flattenValue(flattened, prefix+elem.Key, separator, elem.Value)
+	}
+	return flattened
+}
+
+func (f *Flatten) flattenValue(flattened bson.D, key, separator string, value any) bson.D {
+	switch v := value.(type) {
+	case bson.D:
+		if len(v) > 0 {
+			return f.flattenDocument(flattened, key+separator, separator, v)
+		}
+	case bson.A:
+		if len(v) > 0 && f.Arrays == IndexArrayFlattening {
+			for i, elem := range v {
+				flattened = f.flattenValue(flattened, key+separator+strconv.Itoa(i), separator, elem)
+			}
+			return flattened
+		}
+		for i, elem := range v {
+			if nested, ok := elem.(bson.D); ok && len(nested) > 0 {
+				v[i] = f.flattenDocument(make(bson.D, 0, len(nested)), "", separator, nested)
+			}
+		}
+	}
+	return append(flattened, bson.E{Key: key, Value: value})
+}
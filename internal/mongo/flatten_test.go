@@ -0,0 +1,74 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFlatten_Validate(t *testing.T) {
+	t.Run("should accept the supported separators and array flattenings", func(t *testing.T) {
+		for _, f := range []*Flatten{{}, {Separator: "_", Arrays: IndexArrayFlattening}, {Arrays: KeepArrayFlattening}} {
+			require.NoError(t, f.Validate())
+		}
+	})
+	t.Run("should return error if the separator or the array flattening is not supported", func(t *testing.T) {
+		for _, f := range []*Flatten{{Separator: "$"}, {Separator: " "}, {Arrays: "explode"}} {
+			require.ErrorIs(t, f.Validate(), ErrInvalidFlatten)
+		}
+	})
+}
+
+func Test_flatten(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "fullDocument", Value: bson.D{
+			{Key: "_id", Value: "order-1"},
+			{Key: "customer", Value: bson.D{{Key: "name", Value: "Ada"}, {Key: "address", Value: bson.D{
+				{Key: "city", Value: "London"},
+			}}}},
+			{Key: "items", Value: bson.A{bson.D{{Key: "sku", Value: "A1"}, {Key: "price", Value: bson.D{
+				{Key: "amount", Value: int32(42)},
+			}}}, "gift"}},
+			{Key: "metadata", Value: bson.D{}},
+			{Key: "tags", Value: bson.A{}},
+		}},
+	})
+	require.NoError(t, err)
+
+	t.Run("should flatten the nested documents and keep the arrays", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Flatten: &Flatten{}})
+
+		require.NoError(t, err)
+		require.Equal(t, `{"operationType":"insert","fullDocument._id":"order-1","fullDocument.customer.name":"Ada",`+
+			`"fullDocument.customer.address.city":"London","fullDocument.items":[{"sku":"A1","price.amount":42},"gift"],`+
+			`"fullDocument.metadata":{},"fullDocument.tags":[]}`, string(data))
+	})
+	t.Run("should flatten the arrays by index with the given separator", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Flatten: &Flatten{
+			Separator: "_", Arrays: IndexArrayFlattening,
+		}})
+
+		require.NoError(t, err)
+		require.Equal(t, `{"operationType":"insert","fullDocument__id":"order-1","fullDocument_customer_name":"Ada",`+
+			`"fullDocument_customer_address_city":"London","fullDocument_items_0_sku":"A1",`+
+			`"fullDocument_items_0_price_amount":42,"fullDocument_items_1":"gift","fullDocument_metadata":{},`+
+			`"fullDocument_tags":[]}`, string(data))
+	})
+	t.Run("should flatten the change event once reshaped", func(t *testing.T) {
+		r, err := ParseReshape([]string{"fullDocument -> ."})
+		require.NoError(t, err)
+
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Reshape: r, Flatten: &Flatten{}})
+
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"customer.address.city":"London"`)
+	})
+	t.Run("should not flatten the change event if there is no flatten", func(t *testing.T) {
+		flattened, err := flatten(changeEvent, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, []byte(changeEvent), []byte(flattened))
+	})
+}
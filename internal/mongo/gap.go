@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gapOperationType is the operation type of the gap markers published in place of the change events older than the
+// maximum event age.
+const gapOperationType = "gap"
+
+// staleOperationTypes are the operation types of the change events skipped once they are older than the maximum event
+// age. Renames are never skipped, so that they can still be followed.
+var staleOperationTypes = map[string]struct{}{
+	insertOperationType: {},
+	updateOperationType: {},
+	replacOperationType: {},
+	deleteOperationType: {},
+}
+
+// gap holds the change events skipped because they are older than the maximum event age.
+type gap struct {
+	from    time.Time
+	to      time.Time
+	skipped int
+
+	// last is the last skipped change event, and token its resume token.
+	last  bson.Raw
+	token string
+}
+
+// isStale reports whether the given change event must be skipped because it is older than the given maximum age.
+func isStale(changeEvent bson.Raw, operationType string, maxAge time.Duration) bool {
+	if maxAge <= 0 {
+		return false
+	}
+	if _, ok := staleOperationTypes[operationType]; !ok {
+		return false
+	}
+	return time.Since(eventTime(changeEvent)) > maxAge
+}
+
+// add records the given skipped change event, along with its resume token.
+func (g *gap) add(changeEvent bson.Raw, token string) {
+	t := eventTime(changeEvent)
+	if g.skipped == 0 {
+		g.from = t
+	}
+	g.to = t
+	g.skipped++
+	g.last = changeEvent
+	g.token = token
+}
+
+// encodeGap encodes the marker of the given gap with the given encoder, which tells the consumers when the skipped
+// change events occurred, and how many of them were skipped.
+func encodeGap(g *gap, opts *WatchCollectionOptions) ([]byte, error) {
+	doc, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: gapOperationType},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: opts.WatchedDbName}, {Key: "coll", Value: opts.WatchedCollName}}},
+		{Key: "from", Value: primitive.NewDateTimeFromTime(g.from)},
+		{Key: "to", Value: primitive.NewDateTimeFromTime(g.to)},
+		{Key: "skipped", Value: g.skipped},
+		{Key: "maxEventAge", Value: opts.MaxEventAge.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return encode(doc, opts.Encoder, opts.JsonFlavor)
+}
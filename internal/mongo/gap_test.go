@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func changeEventAt(t *testing.T, clusterTime time.Time) bson.Raw {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: uint32(clusterTime.Unix())}},
+	})
+	require.NoError(t, err)
+	return changeEvent
+}
+
+func Test_isStale(t *testing.T) {
+	old := changeEventAt(t, time.Now().Add(-2*time.Hour))
+	fresh := changeEventAt(t, time.Now())
+
+	tests := []struct {
+		name          string
+		changeEvent   bson.Raw
+		operationType string
+		maxAge        time.Duration
+		want          bool
+	}{
+		{
+			name:          "should be stale if older than the maximum age",
+			changeEvent:   old,
+			operationType: "insert",
+			maxAge:        time.Hour,
+			want:          true,
+		},
+		{
+			name:          "should not be stale if newer than the maximum age",
+			changeEvent:   fresh,
+			operationType: "insert",
+			maxAge:        time.Hour,
+		},
+		{
+			name:          "should not be stale if the maximum age is not set",
+			changeEvent:   old,
+			operationType: "insert",
+		},
+		{
+			name:          "should not be stale if it is a rename",
+			changeEvent:   old,
+			operationType: "rename",
+			maxAge:        time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isStale(tt.changeEvent, tt.operationType, tt.maxAge))
+		})
+	}
+}
+
+func Test_encodeGap(t *testing.T) {
+	from := time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Minute)
+	g := &gap{}
+	g.add(changeEventAt(t, from), "token-1")
+	g.add(changeEventAt(t, to), "token-2")
+
+	require.Equal(t, "token-2", g.token)
+
+	data, err := encodeGap(g, &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1",
+		MaxEventAge: time.Hour, Encoder: JsonEncoder})
+
+	require.NoError(t, err)
+	require.JSONEq(t, `{"operationType":"gap","ns":{"db":"test-db","coll":"coll1"},
+		"from":{"$date":"2024-06-30T10:00:00Z"},"to":{"$date":"2024-06-30T10:01:00Z"},"skipped":2,
+		"maxEventAge":"1h0m0s"}`, string(data))
+}
@@ -0,0 +1,113 @@
+package mongo
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	gridFSFilesSuffix  = ".files"
+	gridFSChunksSuffix = ".chunks"
+)
+
+// The file-level events published in place of the change events of the files collection of a GridFS bucket.
+const (
+	CreatedGridFSEvent = "created"
+	UpdatedGridFSEvent = "updated"
+	DeletedGridFSEvent = "deleted"
+)
+
+// ErrGridFSFileNotFound is returned when opening the content of a file that was deleted in the meantime.
+var ErrGridFSFileNotFound = gridfs.ErrFileNotFound
+
+var gridFSEvents = map[string]string{
+	insertOperationType: CreatedGridFSEvent,
+	updateOperationType: UpdatedGridFSEvent,
+	replacOperationType: UpdatedGridFSEvent,
+	deleteOperationType: DeletedGridFSEvent,
+}
+
+// GridFSFile is the file of a file-level event, whose content can be copied.
+type GridFSFile struct {
+	Event string
+	// Name is the name of the object holding the content of the file, i.e. its id.
+	Name string
+	// Open opens the content of the file, which is read from the chunks collection of its bucket. It is only set for
+	// created files.
+	Open func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// GridFSBucket returns the name of the GridFS bucket of the given collection, or false if it is not the files
+// collection of a bucket, e.g. fs for fs.files.
+func GridFSBucket(collName string) (string, bool) {
+	bucket, ok := strings.CutSuffix(collName, gridFSFilesSuffix)
+	return bucket, ok && bucket != ""
+}
+
+// IsGridFSChunks returns true if the given collection is the chunks collection of a GridFS bucket.
+func IsGridFSChunks(collName string) bool {
+	bucket, ok := strings.CutSuffix(collName, gridFSChunksSuffix)
+	return ok && bucket != ""
+}
+
+// encodeGridFSEvent encodes the file-level event of the given change event of the files collection of a GridFS bucket
+// with the given encoder. It holds the file document, i.e. its filename, length, upload date and metadata, and, if
+// its content is copied, the object holding it.
+func encodeGridFSEvent(changeEvent bson.Raw, event string, opts *WatchCollectionOptions) ([]byte, error) {
+	bucket, _ := GridFSBucket(opts.WatchedCollName)
+	doc := bson.D{
+		{Key: "event", Value: event},
+		{Key: "database", Value: opts.WatchedDbName},
+		{Key: "bucket", Value: bucket},
+		{Key: "fileId", Value: changeEvent.Lookup("documentKey", "_id")},
+		{Key: "clusterTime", Value: changeEvent.Lookup("clusterTime")},
+	}
+	if file, err := changeEvent.LookupErr("fullDocument"); err == nil && file.Type == bson.TypeEmbeddedDocument {
+		doc = append(doc, bson.E{Key: "file", Value: file})
+	} else if file, err = changeEvent.LookupErr("fullDocumentBeforeChange"); err == nil &&
+		file.Type == bson.TypeEmbeddedDocument {
+		doc = append(doc, bson.E{Key: "file", Value: file})
+	}
+	if opts.GridFSObjectBucket != "" && event != DeletedGridFSEvent {
+		doc = append(doc, bson.E{Key: "object", Value: bson.D{
+			{Key: "bucket", Value: opts.GridFSObjectBucket},
+			{Key: "name", Value: documentId(changeEvent)},
+		}})
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return encode(raw, opts.Encoder, opts.JsonFlavor)
+}
+
+// gridFSFile returns the file of the given file-level event, whose content is read from the given database.
+func gridFSFile(db *mongo.Database, opts *WatchCollectionOptions, event string, changeEvent bson.Raw) *GridFSFile {
+	file := &GridFSFile{Event: event, Name: documentId(changeEvent)}
+	if event != CreatedGridFSEvent {
+		return file
+	}
+	bucketName, _ := GridFSBucket(opts.WatchedCollName)
+	id := changeEvent.Lookup("documentKey", "_id")
+	file.Open = func(ctx context.Context) (io.ReadCloser, error) {
+		bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+		if err != nil {
+			return nil, err
+		}
+		stream, err := bucket.OpenDownloadStream(id)
+		if err != nil {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = stream.SetReadDeadline(deadline)
+		}
+		return stream, nil
+	}
+	return file
+}
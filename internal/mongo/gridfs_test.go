@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGridFSBucket(t *testing.T) {
+	bucket, ok := GridFSBucket("fs.files")
+	require.True(t, ok)
+	require.Equal(t, "fs", bucket)
+
+	_, ok = GridFSBucket("fs.chunks")
+	require.False(t, ok)
+	_, ok = GridFSBucket(".files")
+	require.False(t, ok)
+
+	require.True(t, IsGridFSChunks("images.chunks"))
+	require.False(t, IsGridFSChunks("images.files"))
+}
+
+func Test_encodeGridFSEvent(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("665f1f77bcf86cd799439011")
+	file := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "length", Value: int64(1024)},
+		{Key: "filename", Value: "invoice.pdf"},
+		{Key: "uploadDate", Value: primitive.NewDateTimeFromTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))},
+	}
+	opts := &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "invoices.files", GridFSObjectBucket: "INVOICES"}
+
+	t.Run("should encode created files with their object", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.D{
+			{Key: "operationType", Value: "insert"},
+			{Key: "clusterTime", Value: primitive.Timestamp{T: 1714564800, I: 1}},
+			{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+			{Key: "fullDocument", Value: file},
+		})
+
+		data, err := encodeGridFSEvent(changeEvent, CreatedGridFSEvent, opts)
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"event":"created","database":"shop","bucket":"invoices",
+			"fileId":{"$oid":"665f1f77bcf86cd799439011"},"clusterTime":{"$timestamp":{"t":1714564800,"i":1}},
+			"file":{"_id":{"$oid":"665f1f77bcf86cd799439011"},"length":1024,"filename":"invoice.pdf",
+			"uploadDate":{"$date":"2024-05-01T12:00:00Z"}},
+			"object":{"bucket":"INVOICES","name":"665f1f77bcf86cd799439011"}}`, string(data))
+	})
+	t.Run("should encode deleted files with their document before the change, if any", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.D{
+			{Key: "operationType", Value: "delete"},
+			{Key: "clusterTime", Value: primitive.Timestamp{T: 1714564800, I: 2}},
+			{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+			{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "filename", Value: "invoice.pdf"}}},
+		})
+
+		data, err := encodeGridFSEvent(changeEvent, DeletedGridFSEvent, opts)
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"event":"deleted","database":"shop","bucket":"invoices",
+			"fileId":{"$oid":"665f1f77bcf86cd799439011"},"clusterTime":{"$timestamp":{"t":1714564800,"i":2}},
+			"file":{"filename":"invoice.pdf"}}`, string(data))
+	})
+}
+
+func Test_gridFSFile(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.D{{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "file-1"}}}})
+	opts := &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "fs.files"}
+
+	file := gridFSFile(nil, opts, CreatedGridFSEvent, changeEvent)
+	require.Equal(t, CreatedGridFSEvent, file.Event)
+	require.Equal(t, "file-1", file.Name)
+	require.NotNil(t, file.Open)
+
+	file = gridFSFile(nil, opts, DeletedGridFSEvent, changeEvent)
+	require.Equal(t, "file-1", file.Name)
+	require.Nil(t, file.Open, "only the content of created files can be opened")
+}
@@ -0,0 +1,182 @@
+package mongo
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// maxPublishedMsgs is the maximum number of subjects whose last published message is persisted.
+const maxPublishedMsgs = 256
+
+// Ack identifies the message a change event is stored as by JetStream.
+type Ack struct {
+	Stream string
+	Seq    uint64
+	// Duplicate is true if JetStream already stored the message.
+	Duplicate bool
+}
+
+// PublishedMsg is the last message published to a subject, persisted along with the resume tokens.
+type PublishedMsg struct {
+	Subj   string `bson:"subj"`
+	Stream string `bson:"stream"`
+	MsgId  string `bson:"msgId"`
+	Seq    uint64 `bson:"seq"`
+}
+
+// PublishedMsgIdsHandler returns the message ids of the messages of the given stream stored after the given sequence,
+// on the subjects matching the given subject patterns, and the last sequence of the stream.
+type PublishedMsgIdsHandler func(ctx context.Context, stream string, subjects []string, afterSeq uint64) (
+	msgIds []string, lastSeq uint64, err error)
+
+// publishedBySubj returns the given messages by subject.
+func publishedBySubj(msgs []PublishedMsg) map[string]PublishedMsg {
+	bySubj := make(map[string]PublishedMsg, len(msgs))
+	for _, msg := range msgs {
+		bySubj[msg.Subj] = msg
+	}
+	return bySubj
+}
+
+// recordPublished records the last acked message of each subject of the given change events.
+func recordPublished(msgs map[string]PublishedMsg, events []*changeEvent) {
+	for _, event := range events {
+		if event.Ack == nil {
+			continue
+		}
+		if msg, ok := msgs[event.Subj]; ok && msg.Stream == event.Ack.Stream && msg.Seq > event.Ack.Seq {
+			continue
+		}
+		msgs[event.Subj] = PublishedMsg{
+			Subj:   event.Subj,
+			Stream: event.Ack.Stream,
+			MsgId:  event.MsgId,
+			Seq:    event.Ack.Seq,
+		}
+	}
+	if len(msgs) <= maxPublishedMsgs {
+		return
+	}
+	oldest := publishedMsgs(msgs)
+	slices.SortFunc(oldest, func(a, b PublishedMsg) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	for _, msg := range oldest[:len(msgs)-maxPublishedMsgs] {
+		delete(msgs, msg.Subj)
+	}
+}
+
+// publishedMsgs returns the given messages, by subject, sorted by subject.
+func publishedMsgs(msgs map[string]PublishedMsg) []PublishedMsg {
+	if len(msgs) == 0 {
+		return nil
+	}
+	sorted := make([]PublishedMsg, 0, len(msgs))
+	for _, msg := range msgs {
+		sorted = append(sorted, msg)
+	}
+	slices.SortFunc(sorted, func(a, b PublishedMsg) int {
+		return cmp.Compare(a.Subj, b.Subj)
+	})
+	return sorted
+}
+
+// subjectPatternsByStream returns the subject patterns of the change events of the watched collection and its routes,
+// by stream name.
+func subjectPatternsByStream(opts *WatchCollectionOptions) map[string][]string {
+	patterns := make(map[string][]string)
+	for _, o := range append([]*WatchCollectionOptions{opts}, RouteOptions(opts)...) {
+		for _, pattern := range SubjectPatterns(o) {
+			if !slices.Contains(patterns[o.StreamName], pattern) {
+				patterns[o.StreamName] = append(patterns[o.StreamName], pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+// lastSeqs returns the sequence of the last of the given messages of each stream.
+func lastSeqs(msgs map[string]PublishedMsg) map[string]uint64 {
+	seqs := make(map[string]uint64)
+	for _, msg := range msgs {
+		seqs[msg.Stream] = max(seqs[msg.Stream], msg.Seq)
+	}
+	return seqs
+}
+
+// alreadyPublished holds the message ids of the change events published after the last stored resume token.
+// Each of them is skipped once.
+type alreadyPublished struct {
+	mu     sync.Mutex
+	msgIds map[string]struct{}
+	// lastSeqs are the last sequences of the streams when the watcher resumed, by stream name.
+	lastSeqs map[string]uint64
+}
+
+// skip returns true if the change event with the given message id was already published, and forgets it.
+func (p *alreadyPublished) skip(msgId string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.msgIds[msgId]; !ok {
+		return false
+	}
+	delete(p.msgIds, msgId)
+	return true
+}
+
+// passed forgets the message ids left once any of the given change events is newly stored after the last message of
+// its stream when the watcher resumed.
+func (p *alreadyPublished) passed(events []*changeEvent) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.msgIds) == 0 {
+		return
+	}
+	for _, event := range events {
+		if event.Ack == nil || event.Ack.Duplicate {
+			continue
+		}
+		if lastSeq, ok := p.lastSeqs[event.Ack.Stream]; ok && event.Ack.Seq > lastSeq {
+			p.msgIds = nil
+			return
+		}
+	}
+}
+
+// findAlreadyPublished returns the message ids of the change events published after the last stored resume token.
+// The streams whose messages cannot be fetched are skipped.
+func (w *changeStreamWatcher) findAlreadyPublished(ctx context.Context) *alreadyPublished {
+	if w.opts.PublishedMsgIdsHandler == nil || len(w.published) == 0 {
+		return nil
+	}
+	p := &alreadyPublished{msgIds: make(map[string]struct{}), lastSeqs: make(map[string]uint64)}
+	patterns := subjectPatternsByStream(w.opts)
+	for stream, seq := range lastSeqs(w.published) {
+		if len(patterns[stream]) == 0 { // the collection does not publish to the stream anymore
+			continue
+		}
+		ids, lastSeq, err := w.opts.PublishedMsgIdsHandler(ctx, stream, patterns[stream], seq)
+		if err != nil {
+			w.client.logger.Warn("could not fetch the messages published after the last resume token",
+				"collName", w.opts.WatchedCollName, "stream", stream, "seq", seq, "err", err)
+			continue
+		}
+		p.lastSeqs[stream] = lastSeq
+		for _, id := range ids {
+			p.msgIds[id] = struct{}{}
+		}
+	}
+	if len(p.msgIds) > 0 {
+		w.client.logger.Info("skipping change events already published after the last resume token",
+			"collName", w.opts.WatchedCollName, "published", len(p.msgIds))
+	}
+	return p
+}
@@ -0,0 +1,165 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func publishedEvent(subj, msgId string, ack *Ack) *changeEvent {
+	return &changeEvent{ChangeEvent: ChangeEvent{Subj: subj, MsgId: msgId, Ack: ack}}
+}
+
+func Test_recordPublished(t *testing.T) {
+	t.Run("should record the last acked message of each subject", func(t *testing.T) {
+		msgs := publishedBySubj([]PublishedMsg{{Subj: "ORDERS.insert", Stream: "ORDERS", MsgId: "msg-1", Seq: 1}})
+
+		recordPublished(msgs, []*changeEvent{
+			publishedEvent("ORDERS.insert", "msg-3", &Ack{Stream: "ORDERS", Seq: 3}),
+			publishedEvent("ORDERS.insert", "msg-2", &Ack{Stream: "ORDERS", Seq: 2}),
+			publishedEvent("ORDERS.update", "msg-4", &Ack{Stream: "ORDERS", Seq: 4}),
+			publishedEvent("ORDERS.delete", "msg-5", nil),
+		})
+
+		require.Equal(t, []PublishedMsg{
+			{Subj: "ORDERS.insert", Stream: "ORDERS", MsgId: "msg-3", Seq: 3},
+			{Subj: "ORDERS.update", Stream: "ORDERS", MsgId: "msg-4", Seq: 4},
+		}, publishedMsgs(msgs))
+		require.Equal(t, map[string]uint64{"ORDERS": 4}, lastSeqs(msgs))
+	})
+	t.Run("should drop the subjects whose last message is the oldest", func(t *testing.T) {
+		msgs := make(map[string]PublishedMsg)
+		events := make([]*changeEvent, 0, maxPublishedMsgs+2)
+		for i := range maxPublishedMsgs + 2 {
+			events = append(events, publishedEvent(fmt.Sprintf("ORDERS.%03d", i), fmt.Sprintf("msg-%d", i),
+				&Ack{Stream: "ORDERS", Seq: uint64(i + 1)}))
+		}
+
+		recordPublished(msgs, events)
+
+		require.Len(t, msgs, maxPublishedMsgs)
+		require.NotContains(t, msgs, "ORDERS.000")
+		require.NotContains(t, msgs, "ORDERS.001")
+		require.Contains(t, msgs, "ORDERS.002")
+	})
+}
+
+func TestChangeStreamWatcher_findAlreadyPublished(t *testing.T) {
+	published := []PublishedMsg{
+		{Subj: "ORDERS.insert", Stream: "ORDERS", MsgId: "msg-1", Seq: 7},
+		{Subj: "ORDERS.update", Stream: "ORDERS", MsgId: "msg-2", Seq: 9},
+		{Subj: "USERS.insert", Stream: "USERS", MsgId: "msg-3", Seq: 2},
+		{Subj: "LEGACY.insert", Stream: "LEGACY", MsgId: "msg-4", Seq: 5},
+	}
+	opts := &WatchCollectionOptions{WatchedCollName: "orders", StreamName: "ORDERS",
+		Routes: []Route{{When: map[string]string{"kind": "user"}, StreamName: "USERS"}}}
+	newWatcher := func(handler PublishedMsgIdsHandler) *changeStreamWatcher {
+		o := *opts
+		o.PublishedMsgIdsHandler = handler
+		return &changeStreamWatcher{
+			client:    &DefaultClient{logger: slog.Default()},
+			opts:      &o,
+			published: publishedBySubj(published),
+		}
+	}
+
+	t.Run("should return the message ids stored after the last published message of each stream", func(t *testing.T) {
+		afterSeqs := make(map[string]uint64)
+		subjects := make(map[string][]string)
+		w := newWatcher(func(_ context.Context, stream string, subjs []string, afterSeq uint64) ([]string, uint64,
+			error) {
+			afterSeqs[stream] = afterSeq
+			subjects[stream] = subjs
+			return []string{stream + "-next"}, afterSeq + 1, nil
+		})
+
+		got := w.findAlreadyPublished(context.Background())
+
+		require.Equal(t, map[string]uint64{"ORDERS": 9, "USERS": 2}, afterSeqs,
+			"the streams the collection does not publish to anymore are not fetched")
+		require.Equal(t, map[string][]string{"ORDERS": SubjectPatterns(opts),
+			"USERS": SubjectPatterns(RouteOptions(opts)[0])}, subjects)
+		require.Equal(t, map[string]struct{}{"ORDERS-next": {}, "USERS-next": {}}, got.msgIds)
+		require.Equal(t, map[string]uint64{"ORDERS": 10, "USERS": 3}, got.lastSeqs)
+	})
+	t.Run("should return the message ids of the subjects without a published message", func(t *testing.T) {
+		stored := []PublishedMsg{
+			{Subj: "ORDERS.insert", Stream: "ORDERS", MsgId: "msg-10", Seq: 10},
+			{Subj: "ORDERS.delete", Stream: "ORDERS", MsgId: "msg-11", Seq: 11}, // first delete after the token
+			{Subj: "ORDERS.other", Stream: "ORDERS", MsgId: "msg-12", Seq: 12},  // e.g. of another collection
+		}
+		w := newWatcher(func(_ context.Context, stream string, subjs []string, afterSeq uint64) ([]string, uint64,
+			error) {
+			var msgIds []string
+			for _, msg := range stored {
+				if msg.Stream == stream && msg.Seq > afterSeq && slices.Contains(subjs, msg.Subj) {
+					msgIds = append(msgIds, msg.MsgId)
+				}
+			}
+			return msgIds, 12, nil
+		})
+
+		got := w.findAlreadyPublished(context.Background())
+
+		require.Equal(t, map[string]struct{}{"msg-10": {}, "msg-11": {}}, got.msgIds)
+	})
+	t.Run("should skip the streams whose messages cannot be fetched", func(t *testing.T) {
+		w := newWatcher(func(_ context.Context, stream string, _ []string, _ uint64) ([]string, uint64, error) {
+			if stream == "USERS" {
+				return nil, 0, errors.New("stream not found")
+			}
+			return []string{"msg-10"}, 10, nil
+		})
+
+		got := w.findAlreadyPublished(context.Background())
+
+		require.Equal(t, map[string]struct{}{"msg-10": {}}, got.msgIds)
+		require.Equal(t, map[string]uint64{"ORDERS": 10}, got.lastSeqs)
+	})
+	t.Run("should return nothing without handler", func(t *testing.T) {
+		require.Nil(t, newWatcher(nil).findAlreadyPublished(context.Background()))
+	})
+}
+
+func TestChangeStreamWatcher_publishOne(t *testing.T) {
+	var handled []string
+	w := &changeStreamWatcher{
+		client: &DefaultClient{logger: slog.Default()},
+		opts: &WatchCollectionOptions{ChangeEventHandler: func(_ context.Context, event *ChangeEvent) error {
+			handled = append(handled, event.MsgId)
+			return nil
+		}},
+		alreadyPublished: &alreadyPublished{msgIds: map[string]struct{}{"msg-1": {}}},
+	}
+
+	for _, msgId := range []string{"msg-1", "msg-2", "msg-1"} {
+		require.NoError(t, w.publishOne(context.Background(), publishedEvent("ORDERS.insert", msgId, nil)))
+	}
+
+	require.Equal(t, []string{"msg-2", "msg-1"}, handled,
+		"the change events already published are skipped once, the later changes with the same id are published")
+}
+
+func TestAlreadyPublished_passed(t *testing.T) {
+	p := &alreadyPublished{msgIds: map[string]struct{}{"msg-1": {}, "msg-2": {}, "msg-3": {}},
+		lastSeqs: map[string]uint64{"ORDERS": 12}}
+
+	p.passed([]*changeEvent{publishedEvent("ORDERS.insert", "msg-4", nil)})
+	require.Len(t, p.msgIds, 3, "the message ids are kept until a new message is published")
+
+	p.passed([]*changeEvent{publishedEvent("ORDERS.insert", "msg-1", &Ack{Stream: "ORDERS", Seq: 11})})
+	require.Len(t, p.msgIds, 3, "the message ids are kept until the last message of the stream is passed")
+
+	p.passed([]*changeEvent{publishedEvent("ORDERS.insert", "msg-2", &Ack{Stream: "ORDERS", Seq: 12,
+		Duplicate: true})})
+	p.passed([]*changeEvent{publishedEvent("USERS.insert", "msg-5", &Ack{Stream: "USERS", Seq: 20})})
+	require.Len(t, p.msgIds, 3, "duplicates and the messages of other streams do not pass the last message")
+
+	p.passed([]*changeEvent{publishedEvent("ORDERS.insert", "msg-6", &Ack{Stream: "ORDERS", Seq: 13})})
+	require.False(t, p.skip("msg-3"), "the message ids are forgotten once a new message is published")
+}
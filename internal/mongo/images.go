@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MissingImagesPolicy represents what happens to the change events whose images are required, but which the server
+// cannot supply, e.g. since their pre-image expired, or their document was deleted before its post-image was looked up.
+type MissingImagesPolicy string
+
+const (
+	// FailMissingImagesPolicy requires the images from the server, which fails the change stream, and the watcher,
+	// once it cannot supply them.
+	FailMissingImagesPolicy MissingImagesPolicy = "fail"
+
+	// PartialMissingImagesPolicy publishes the change events without the images which are missing, flagged as
+	// partial.
+	PartialMissingImagesPolicy MissingImagesPolicy = "partial"
+
+	// DlqMissingImagesPolicy publishes the raw BSON of the change events to a dead letter subject.
+	DlqMissingImagesPolicy MissingImagesPolicy = "dlq"
+)
+
+var MissingImagesPolicies = []MissingImagesPolicy{
+	FailMissingImagesPolicy,
+	PartialMissingImagesPolicy,
+	DlqMissingImagesPolicy,
+}
+
+var ErrMissingImages = errors.New("images of the change event are unavailable")
+
+// The fields of the change events holding the images of their document.
+const (
+	postImageField = "fullDocument"
+	preImageField  = "fullDocumentBeforeChange"
+)
+
+// requiredImages are the fields holding the images of the change events required by a missing images policy, by
+// operation type.
+var requiredImages = map[string][]string{
+	updateOperationType: {preImageField, postImageField},
+	replacOperationType: {preImageField, postImageField},
+	deleteOperationType: {preImageField},
+}
+
+// fullDocumentOptions returns the options of the full documents of the change stream of a collection with the given
+// missing images policy, where the images are required from the server if the policy fails the watcher, or looked up
+// when available otherwise.
+func fullDocumentOptions(policy MissingImagesPolicy) (options.FullDocument, options.FullDocument) {
+	if policy == FailMissingImagesPolicy {
+		return options.Required, options.Required
+	}
+	return options.UpdateLookup, options.WhenAvailable
+}
+
+// missingImages returns the fields of the images of the given change event, of the given operation type, which are
+// required by the given missing images policy, if any, but are missing or null.
+func missingImages(policy MissingImagesPolicy, operationType string, changeEvent bson.Raw) []string {
+	if policy == "" {
+		return nil
+	}
+	var missing []string
+	for _, field := range requiredImages[operationType] {
+		if value, err := changeEvent.LookupErr(field); err != nil || value.Type == bsontype.Null {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// newMissingImagesError returns the error of a change event whose given images are missing.
+func newMissingImagesError(missing []string) error {
+	return fmt.Errorf("%w: %v", ErrMissingImages, strings.Join(missing, ", "))
+}
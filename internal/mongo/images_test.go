@@ -0,0 +1,48 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Test_missingImages(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{{Key: "operationType", Value: "update"},
+		{Key: "fullDocument", Value: nil}})
+	require.NoError(t, err)
+	complete, err := bson.Marshal(bson.D{{Key: "operationType", Value: "update"},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+		{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+	require.NoError(t, err)
+
+	t.Run("should return the missing or null images", func(t *testing.T) {
+		require.Equal(t, []string{"fullDocumentBeforeChange", "fullDocument"},
+			missingImages(PartialMissingImagesPolicy, "update", changeEvent))
+		require.Equal(t, []string{"fullDocumentBeforeChange"},
+			missingImages(DlqMissingImagesPolicy, "delete", changeEvent))
+	})
+	t.Run("should return no images", func(t *testing.T) {
+		require.Empty(t, missingImages(PartialMissingImagesPolicy, "update", complete))
+		require.Empty(t, missingImages(PartialMissingImagesPolicy, "insert", changeEvent))
+		require.Empty(t, missingImages("", "update", changeEvent))
+	})
+}
+
+func Test_fullDocumentOptions(t *testing.T) {
+	t.Run("should require the images", func(t *testing.T) {
+		fullDocument, fullDocumentBeforeChange := fullDocumentOptions(FailMissingImagesPolicy)
+
+		require.Equal(t, options.Required, fullDocument)
+		require.Equal(t, options.Required, fullDocumentBeforeChange)
+	})
+	t.Run("should look up the images when available", func(t *testing.T) {
+		for _, policy := range []MissingImagesPolicy{"", PartialMissingImagesPolicy, DlqMissingImagesPolicy} {
+			fullDocument, fullDocumentBeforeChange := fullDocumentOptions(policy)
+
+			require.Equal(t, options.UpdateLookup, fullDocument)
+			require.Equal(t, options.WhenAvailable, fullDocumentBeforeChange)
+		}
+	})
+}
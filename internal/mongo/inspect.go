@@ -0,0 +1,175 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The statuses of the last resume token stored for a watched collection.
+const (
+	MissingTokenStatus = "missing" // no resume token is stored, the change stream starts from the current time
+	ValidTokenStatus   = "valid"   // the change stream can be resumed after the resume token
+	ExpiredTokenStatus = "expired" // the change event of the resume token fell off the oplog window
+	CorruptTokenStatus = "corrupt" // the data of the resume token cannot be decoded
+)
+
+// ErrInvalidResumeToken is returned when repairing resume tokens with a replacement which cannot be decoded.
+var ErrInvalidResumeToken = errors.New("invalid resume token")
+
+// ResumeTokenInspection describes the last resume token stored for a watched collection.
+type ResumeTokenInspection struct {
+	Token  string `json:"token,omitempty"`
+	Status string `json:"status"`
+	// Time and Increment are the cluster time of the change event the resume token refers to, if it can be decoded.
+	Time      *time.Time `json:"time,omitempty"`
+	Increment uint32     `json:"increment,omitempty"`
+	// OplogStart is the time of the oldest oplog entry, if the oplog can be read.
+	OplogStart *time.Time `json:"oplogStart,omitempty"`
+	// Corrupt is the number of stored resume tokens, the last one included, whose data cannot be decoded.
+	Corrupt int `json:"corrupt"`
+}
+
+// storedResumeToken is a resume token document along with its id, so that it can be rewritten or deleted.
+type storedResumeToken struct {
+	Id    bson.RawValue
+	Value string
+}
+
+// newStoredResumeToken returns the resume token document of the given raw document, whose value is empty if it is not
+// a string.
+func newStoredResumeToken(raw bson.Raw) storedResumeToken {
+	value, _ := raw.Lookup("value").StringValueOK()
+	return storedResumeToken{Id: raw.Lookup("_id"), Value: value}
+}
+
+// InspectToken decodes the given resume token, stored last for a watched collection, and checks it against the oplog
+// window starting at the given time, which is unknown if zero.
+func InspectToken(token string, oplogStart time.Time) *ResumeTokenInspection {
+	inspection := &ResumeTokenInspection{Token: token, Status: ValidTokenStatus}
+	if !oplogStart.IsZero() {
+		inspection.OplogStart = &oplogStart
+	}
+	ts, ok := tokenTimestamp(token)
+	if !ok {
+		inspection.Status = CorruptTokenStatus
+		return inspection
+	}
+	tokenTime := time.Unix(int64(ts.T), 0).UTC()
+	inspection.Time, inspection.Increment = &tokenTime, ts.I
+	if tokenTime.Before(oplogStart) {
+		inspection.Status = ExpiredTokenStatus
+	}
+	return inspection
+}
+
+// InspectResumeToken decodes the last resume token stored in the given resume tokens collection, reports the cluster
+// time it refers to, checks it against the current oplog window, and counts the stored resume tokens which cannot be
+// decoded.
+func (c *DefaultClient) InspectResumeToken(ctx context.Context, opts *ResumeTokensOptions) (*ResumeTokenInspection,
+	error) {
+	coll := c.mongoClient().Database(opts.DbName).Collection(opts.CollName)
+	last, err := findLastStoredResumeToken(ctx, coll, opts.Capped)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch or decode resume token: %v", err)
+	}
+	if last == nil {
+		return &ResumeTokenInspection{Status: MissingTokenStatus}, nil
+	}
+	oplogStart, err := c.oldestOplogTime(ctx)
+	if err != nil {
+		// e.g. the user is not allowed to read the oplog, the resume token is not checked against it
+		c.logger.Debug("could not fetch oldest oplog entry", "err", err)
+	}
+	inspection := InspectToken(last.Value, oplogStart)
+
+	corrupt, err := findCorruptResumeTokens(ctx, coll)
+	if err != nil {
+		return nil, err
+	}
+	inspection.Corrupt = len(corrupt)
+	return inspection, nil
+}
+
+// RepairResumeTokens rewrites the resume tokens stored in the given resume tokens collection whose data cannot be
+// decoded with the given token, or deletes them if it is empty, in which case the change stream is resumed after the
+// last valid one. It returns the number of resume tokens that were repaired.
+// The resume tokens of capped collections cannot be deleted, they can only be rewritten.
+func (c *DefaultClient) RepairResumeTokens(ctx context.Context, opts *ResumeTokensOptions, token string) (int,
+	error) {
+	if _, ok := tokenTimestamp(token); token != "" && !ok {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidResumeToken, token)
+	}
+	coll := c.mongoClient().Database(opts.DbName).Collection(opts.CollName)
+	corrupt, err := findCorruptResumeTokens(ctx, coll)
+	if err != nil {
+		return 0, err
+	}
+	if len(corrupt) > 0 && token == "" && opts.Capped {
+		return 0, fmt.Errorf("could not delete corrupt resume tokens of capped collection %v, a replacement token "+
+			"must be given", opts.CollName)
+	}
+
+	repaired := 0
+	for _, stored := range corrupt {
+		filter := bson.D{{Key: "_id", Value: stored.Id}}
+		if token == "" {
+			_, err = coll.DeleteOne(ctx, filter)
+		} else {
+			_, err = coll.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: "value", Value: token}}}})
+		}
+		if err != nil {
+			return repaired, fmt.Errorf("could not repair resume token %v: %v", stored.Id, err)
+		}
+		repaired++
+	}
+	c.logger.Info("resume tokens repaired", "dbName", opts.DbName, "collName", opts.CollName, "repaired", repaired,
+		"rewritten", token != "")
+	return repaired, nil
+}
+
+// findLastStoredResumeToken returns the last resume token document stored in the given collection, or nil if none is.
+func findLastStoredResumeToken(ctx context.Context, coll *mongo.Collection, capped bool) (*storedResumeToken,
+	error) {
+	findOneOpts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if capped {
+		findOneOpts.SetSort(bson.D{{Key: "$natural", Value: -1}})
+	}
+	raw, err := coll.FindOne(ctx, bson.D{}, findOneOpts).Raw()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := newStoredResumeToken(raw)
+	return &stored, nil
+}
+
+// findCorruptResumeTokens returns the resume token documents stored in the given collection whose data cannot be
+// decoded.
+func findCorruptResumeTokens(ctx context.Context, coll *mongo.Collection) ([]storedResumeToken, error) {
+	cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{{Key: "value", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("could not find resume tokens: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	corrupt := make([]storedResumeToken, 0)
+	for cursor.Next(ctx) {
+		// a document whose value is not a string is corrupt as well
+		stored := newStoredResumeToken(cursor.Current)
+		if _, ok := tokenTimestamp(stored.Value); !ok {
+			corrupt = append(corrupt, stored)
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, fmt.Errorf("could not read resume tokens: %v", err)
+	}
+	return corrupt, nil
+}
@@ -0,0 +1,39 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectToken(t *testing.T) {
+	const token = "82645A43BA000000012B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004"
+	tokenTime := time.Unix(1683637178, 0).UTC()
+
+	t.Run("should decode the cluster time of a resume token in the oplog window", func(t *testing.T) {
+		oplogStart := tokenTime.Add(-time.Hour)
+
+		inspection := InspectToken(token, oplogStart)
+
+		require.Equal(t, &ResumeTokenInspection{Token: token, Status: ValidTokenStatus, Time: &tokenTime, Increment: 1,
+			OplogStart: &oplogStart}, inspection)
+	})
+	t.Run("should report a resume token which fell off the oplog window as expired", func(t *testing.T) {
+		inspection := InspectToken(token, tokenTime.Add(time.Second))
+
+		require.Equal(t, ExpiredTokenStatus, inspection.Status)
+		require.Equal(t, &tokenTime, inspection.Time)
+	})
+	t.Run("should not check the resume token if the oplog window is unknown", func(t *testing.T) {
+		inspection := InspectToken(token, time.Time{})
+
+		require.Equal(t, ValidTokenStatus, inspection.Status)
+		require.Nil(t, inspection.OplogStart)
+	})
+	t.Run("should report a resume token which cannot be decoded as corrupt", func(t *testing.T) {
+		inspection := InspectToken("not-a-token", time.Time{})
+
+		require.Equal(t, &ResumeTokenInspection{Token: "not-a-token", Status: CorruptTokenStatus}, inspection)
+	})
+}
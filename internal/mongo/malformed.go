@@ -0,0 +1,22 @@
+package mongo
+
+// DecodeErrorPolicy represents what is done with change events that cannot be encoded, e.g. because they hold values
+// that cannot be represented in JSON.
+type DecodeErrorPolicy string
+
+const (
+	// HaltDecodeErrorPolicy stops the watcher.
+	HaltDecodeErrorPolicy DecodeErrorPolicy = "halt"
+
+	// SkipDecodeErrorPolicy logs the error and skips the change event.
+	SkipDecodeErrorPolicy DecodeErrorPolicy = "skip"
+
+	// DlqDecodeErrorPolicy publishes the raw BSON of the change event to a dead letter subject.
+	DlqDecodeErrorPolicy DecodeErrorPolicy = "dlq"
+)
+
+var DecodeErrorPolicies = []DecodeErrorPolicy{
+	HaltDecodeErrorPolicy,
+	SkipDecodeErrorPolicy,
+	DlqDecodeErrorPolicy,
+}
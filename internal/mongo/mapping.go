@@ -0,0 +1,90 @@
+package mongo
+
+import "strings"
+
+// mappedOperationTypes are the operation types of the change events of documents, whose subjects are listed by the
+// mappings of the watched collections, in order.
+var mappedOperationTypes = []string{insertOperationType, updateOperationType, replacOperationType,
+	deleteOperationType}
+
+// Mapping tells how the change events of a watched collection, or the ones routed by one of its routes, are published,
+// so that operators can check their routing without reading the configuration.
+type Mapping struct {
+	StreamName string
+	// SubjectFilter is the subject filter the stream is bound to.
+	SubjectFilter string
+	// Subjects hold the layout of the subjects of the change events of documents, by operation type, see SubjectLayout.
+	Subjects map[string]string
+}
+
+// MappingOf returns the mapping of the change events published with the given options.
+func MappingOf(opts *WatchCollectionOptions) *Mapping {
+	mapping := &Mapping{
+		StreamName:    opts.StreamName,
+		SubjectFilter: SubjectFilter(opts),
+		Subjects:      make(map[string]string, len(mappedOperationTypes)),
+	}
+	for _, operationType := range mappedOperationTypes {
+		mapping.Subjects[operationType] = SubjectLayout(opts, operationType)
+	}
+	return mapping
+}
+
+// SubjectLayout returns the layout of the subjects of the change events with the given operation type published with
+// the given options, where the tokens computed from each change event are placeholders, e.g.
+// ORDERS.insert.<partition>.<day>, or the stream name followed by the text of the subject template if it is set.
+func SubjectLayout(opts *WatchCollectionOptions, operationType string) string {
+	if opts.SubjectTemplate != nil {
+		return versioned(opts.SchemaVersion.Current(), opts.StreamName+"."+opts.SubjectTemplate.String())
+	}
+	tokens := []string{opts.StreamName}
+	if opts.NamespaceSubjects {
+		tokens = append(tokens, subjectTokenReplacer.Replace(opts.WatchedDbName),
+			subjectTokenReplacer.Replace(opts.WatchedCollName))
+	}
+	tokens = append(tokens, opts.EventTypes.of(operationType))
+	if opts.Partitions > 0 {
+		tokens = append(tokens, "<partition>")
+	}
+	if _, ok := timeBucketLayouts[opts.TimeBucket]; ok {
+		tokens = append(tokens, "<"+string(opts.TimeBucket)+">")
+	}
+	return versioned(opts.SchemaVersion.Current(), strings.Join(tokens, "."))
+}
+
+// Transforms returns the names of the transforms applied to the change events published with the given options, in
+// the order they are applied, before they are encoded.
+func Transforms(opts *WatchCollectionOptions) []string {
+	transforms := make([]string, 0)
+	if len(opts.ExcludeFields) > 0 {
+		transforms = append(transforms, "excludeFields")
+	}
+	if opts.Filter != nil {
+		transforms = append(transforms, "filter")
+	}
+	if opts.Enrichment != nil {
+		transforms = append(transforms, "enrich")
+	}
+	if len(opts.EventTypes) > 0 {
+		transforms = append(transforms, "eventTypes")
+	}
+	if opts.Patch != "" {
+		transforms = append(transforms, "patch")
+	}
+	if opts.Envelope != nil {
+		transforms = append(transforms, "envelope")
+	}
+	if opts.PayloadMode == SlimPayloadMode {
+		transforms = append(transforms, "slim")
+	}
+	if opts.Reshape != nil {
+		transforms = append(transforms, "reshape")
+	}
+	if opts.Flatten != nil {
+		transforms = append(transforms, "flatten")
+	}
+	if opts.Encoder != BsonEncoder && conversionsOf(opts) != nil {
+		transforms = append(transforms, "typeConversions")
+	}
+	return transforms
+}
@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectLayout(t *testing.T) {
+	tmpl, _ := NewTemplate("{{ .DocumentId }}")
+	tests := []struct {
+		name string
+		opts *WatchCollectionOptions
+		want string
+	}{
+		{
+			name: "should return the subject of the operation type",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS"},
+			want: "ORDERS.insert",
+		},
+		{
+			name: "should return the subject with the namespace, event type and placeholders",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", WatchedDbName: "shop", WatchedCollName: "orders",
+				NamespaceSubjects: true, EventTypes: EventTypes{"insert": "order.created"}, Partitions: 8,
+				TimeBucket: DayTimeBucket},
+			want: "ORDERS.shop.orders.order.created.<partition>.<day>",
+		},
+		{
+			name: "should return the subject template prefixed with the schema version",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", SubjectTemplate: tmpl,
+				SchemaVersion: NewSchemaVersion(2)},
+			want: "v2.ORDERS.{{ .DocumentId }}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, SubjectLayout(tt.opts, "insert"))
+		})
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	t.Run("should return no transforms", func(t *testing.T) {
+		require.Empty(t, Transforms(&WatchCollectionOptions{}))
+	})
+	t.Run("should return the transforms in the order they are applied", func(t *testing.T) {
+		opts := &WatchCollectionOptions{
+			ExcludeFields: []string{"email"},
+			Filter:        &Filter{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "active"},
+			EventTypes:    EventTypes{"insert": "order.created"},
+			Patch:         MergePatchFormat,
+			PayloadMode:   SlimPayloadMode,
+			Flatten:       &Flatten{},
+			JsonFlavor:    SimplifiedJsonFlavor,
+		}
+
+		require.Equal(t, []string{"excludeFields", "filter", "eventTypes", "patch", "slim", "flatten",
+			"typeConversions"}, Transforms(opts))
+	})
+}
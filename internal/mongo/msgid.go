@@ -0,0 +1,99 @@
+package mongo
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// MsgIdStrategy represents the strategy used to compute the NATS message id of a change event, used by NATS to discard
+// duplicates.
+type MsgIdStrategy string
+
+const (
+	// ResumeTokenMsgIdStrategy uses the resume token of the change event as message id.
+	ResumeTokenMsgIdStrategy MsgIdStrategy = "resumeToken"
+
+	// EventHashMsgIdStrategy uses the hash of the namespace, document key and cluster time of the change event as
+	// message id, so that replayed change events with regenerated resume tokens are still detected as duplicates.
+	// The hash of the change events of a transaction, which share its cluster time, also covers their content and the
+	// logical session and number of the transaction.
+	EventHashMsgIdStrategy MsgIdStrategy = "eventHash"
+
+	// DocumentFieldMsgIdStrategy uses the value of a field of the full document as message id, which must be unique
+	// to each change of the document, e.g. a version or an event id, otherwise its later changes are discarded as
+	// duplicates. It falls back to the resume token if the field is not available, e.g. for deletions.
+	DocumentFieldMsgIdStrategy MsgIdStrategy = "documentField"
+)
+
+var MsgIdStrategies = []MsgIdStrategy{
+	ResumeTokenMsgIdStrategy,
+	EventHashMsgIdStrategy,
+	DocumentFieldMsgIdStrategy,
+}
+
+// msgId computes the message id of the given change event, according to the given strategy.
+func msgId(changeEvent bson.Raw, strategy MsgIdStrategy, field string) string {
+	resumeToken := changeEvent.Lookup("_id", "_data").StringValue()
+	switch strategy {
+	case EventHashMsgIdStrategy:
+		return eventHash(changeEvent)
+	case DocumentFieldMsgIdStrategy:
+		path := append([]string{"fullDocument"}, strings.Split(field, ".")...)
+		if value, ok := lookupString(changeEvent, path...); ok {
+			return value
+		}
+		return resumeToken
+	default:
+		return resumeToken
+	}
+}
+
+// eventHash computes the hash of the fields identifying the given change event.
+func eventHash(changeEvent bson.Raw) string {
+	hash := sha256.New()
+	if _, err := changeEvent.LookupErr("txnNumber"); err != nil {
+		for _, key := range []string{"ns", "documentKey", "clusterTime"} {
+			_, _ = hash.Write(changeEvent.Lookup(key).Value)
+		}
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+	// each value is prefixed with its type and length, so that different values cannot be concatenated into the same
+	// bytes
+	for _, key := range []string{"ns", "documentKey", "clusterTime", "lsid", "txnNumber", "operationType",
+		"updateDescription", "fullDocument", "fullDocumentBeforeChange"} {
+		value := changeEvent.Lookup(key)
+		_, _ = hash.Write([]byte{byte(value.Type)})
+		_ = binary.Write(hash, binary.BigEndian, uint32(len(value.Value)))
+		_, _ = hash.Write(value.Value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// lookupString looks up the value at the given path of the given document, and returns its string representation.
+// It returns false if the value is missing, null or undefined.
+func lookupString(doc bson.Raw, path ...string) (string, bool) {
+	value, err := doc.LookupErr(path...)
+	if err != nil {
+		return "", false
+	}
+	switch value.Type {
+	case bsontype.String:
+		return value.StringValue(), true
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex(), true
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10), true
+	case bsontype.Int64:
+		return strconv.FormatInt(value.Int64(), 10), true
+	case bsontype.Null, bsontype.Undefined:
+		return "", false
+	default:
+		return value.String(), true
+	}
+}
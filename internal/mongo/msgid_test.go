@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_msgId(t *testing.T) {
+	objectId := primitive.NewObjectID()
+	changeEvent := func(token string, extra ...bson.E) bson.Raw {
+		doc := bson.D{
+			{Key: "_id", Value: bson.D{{Key: "_data", Value: token}}},
+			{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+			{Key: "documentKey", Value: bson.D{{Key: "_id", Value: objectId}}},
+			{Key: "clusterTime", Value: primitive.Timestamp{T: 1683637178, I: 1}},
+		}
+		raw, err := bson.Marshal(append(doc, extra...))
+		require.NoError(t, err)
+		return raw
+	}
+	fullDocument := bson.E{Key: "fullDocument", Value: bson.D{
+		{Key: "_id", Value: objectId},
+		{Key: "code", Value: "abc"},
+		{Key: "nested", Value: bson.D{{Key: "version", Value: int32(3)}}},
+	}}
+
+	t.Run("should use resume token by default", func(t *testing.T) {
+		require.Equal(t, "token1", msgId(changeEvent("token1"), "", ""))
+		require.Equal(t, "token1", msgId(changeEvent("token1"), ResumeTokenMsgIdStrategy, ""))
+	})
+	t.Run("should use the same event hash even if resume tokens differ", func(t *testing.T) {
+		id1 := msgId(changeEvent("token1"), EventHashMsgIdStrategy, "")
+		id2 := msgId(changeEvent("token2"), EventHashMsgIdStrategy, "")
+
+		require.Len(t, id1, 64)
+		require.Equal(t, id1, id2)
+	})
+	t.Run("should keep the event hashes of the change events outside transactions", func(t *testing.T) {
+		event := changeEvent("token1")
+		hash := sha256.New()
+		for _, key := range []string{"ns", "documentKey", "clusterTime"} {
+			hash.Write(event.Lookup(key).Value)
+		}
+
+		require.Equal(t, hex.EncodeToString(hash.Sum(nil)), msgId(event, EventHashMsgIdStrategy, ""),
+			"the event hashes of the change events published by previous versions are the same")
+	})
+	t.Run("should use different event hashes for the changes of a document in the same transaction", func(t *testing.T) {
+		txn := []bson.E{
+			{Key: "lsid", Value: bson.D{{Key: "id", Value: primitive.Binary{Subtype: 4, Data: make([]byte, 16)}}}},
+			{Key: "txnNumber", Value: int64(1)},
+		}
+		update := func(code string) bson.E {
+			return bson.E{Key: "updateDescription", Value: bson.D{
+				{Key: "updatedFields", Value: bson.D{{Key: "code", Value: code}}},
+			}}
+		}
+		id1 := msgId(changeEvent("token1", append(txn, update("abc"))...), EventHashMsgIdStrategy, "")
+		id2 := msgId(changeEvent("token2", append(txn, update("def"))...), EventHashMsgIdStrategy, "")
+
+		require.NotEqual(t, id1, id2)
+		require.Equal(t, id1, msgId(changeEvent("token3", append(txn, update("abc"))...), EventHashMsgIdStrategy, ""),
+			"the event hash of a replayed change event does not depend on its resume token")
+		require.NotEqual(t, msgId(changeEvent("token1", update("abc")), EventHashMsgIdStrategy, ""), id1)
+	})
+	t.Run("should use the given document field", func(t *testing.T) {
+		event := changeEvent("token1", fullDocument)
+
+		require.Equal(t, "abc", msgId(event, DocumentFieldMsgIdStrategy, "code"))
+		require.Equal(t, objectId.Hex(), msgId(event, DocumentFieldMsgIdStrategy, "_id"))
+		require.Equal(t, "3", msgId(event, DocumentFieldMsgIdStrategy, "nested.version"))
+	})
+	t.Run("should fall back to resume token if the document field is missing", func(t *testing.T) {
+		require.Equal(t, "token1", msgId(changeEvent("token1"), DocumentFieldMsgIdStrategy, "code"))
+		require.Equal(t, "token1", msgId(changeEvent("token1", fullDocument), DocumentFieldMsgIdStrategy, "missing"))
+	})
+}
@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrOutOfOrder is returned by the watchers with strict ordering once a change event is observed or published before
+// a change event with a later cluster time.
+var ErrOutOfOrder = errors.New("change event out of order")
+
+const (
+	// observedOrderStage is the stage of the change events received from the change stream.
+	observedOrderStage = "observed"
+
+	// publishedOrderStage is the stage of the change events once published, e.g. concurrently by publish workers.
+	publishedOrderStage = "published"
+)
+
+// ordering checks that the cluster times of the change events of a change stream never go backwards, once they are
+// observed and once they are published.
+type ordering struct {
+	mu        sync.Mutex
+	observed  primitive.Timestamp
+	published primitive.Timestamp
+}
+
+// check records the given cluster time of a change event at the given stage, and returns false if a change event with
+// a later cluster time was recorded at that stage before. The change events of a transaction share their cluster time,
+// and change events without cluster time are not checked.
+func (o *ordering) check(stage string, clusterTime primitive.Timestamp) bool {
+	if clusterTime.IsZero() {
+		return true
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	last := &o.observed
+	if stage == publishedOrderStage {
+		last = &o.published
+	}
+	if primitive.CompareTimestamp(clusterTime, *last) < 0 {
+		return false
+	}
+	*last = clusterTime
+	return true
+}
+
+// clusterTimeOf returns the cluster time of the given change event, or the zero timestamp if it has none.
+func clusterTimeOf(changeEvent bson.Raw) primitive.Timestamp {
+	t, i, _ := changeEvent.Lookup("clusterTime").TimestampOK()
+	return primitive.Timestamp{T: t, I: i}
+}
+
+// checkOrder checks the order of the given change event at the given stage. If it is out of order, it is logged and
+// reported, and ErrOutOfOrder is returned if the collection is strictly ordered.
+func (w *changeStreamWatcher) checkOrder(stage string, clusterTime primitive.Timestamp, token string) error {
+	if w.ordering.check(stage, clusterTime) {
+		return nil
+	}
+	w.client.logger.Warn("change event out of order", "collName", w.opts.WatchedCollName, "stage", stage,
+		"clusterTime", clusterTime, "resumeToken", token, "strict", w.opts.StrictOrdering)
+	if w.client.onChangeEventOutOfOrderEvent != nil {
+		w.client.onChangeEventOutOfOrderEvent(w.opts.WatchedDbName, w.opts.WatchedCollName, stage)
+	}
+	if w.opts.StrictOrdering {
+		return fmt.Errorf("%w: change event %v %s before a later one", ErrOutOfOrder, token, stage)
+	}
+	return nil
+}
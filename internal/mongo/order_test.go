@@ -0,0 +1,64 @@
+package mongo
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestOrdering_check(t *testing.T) {
+	t.Run("should accept cluster times that do not go backwards", func(t *testing.T) {
+		o := &ordering{}
+
+		require.True(t, o.check(observedOrderStage, primitive.Timestamp{T: 10, I: 1}))
+		require.True(t, o.check(observedOrderStage, primitive.Timestamp{T: 10, I: 1}))
+		require.True(t, o.check(observedOrderStage, primitive.Timestamp{T: 10, I: 2}))
+		require.True(t, o.check(observedOrderStage, primitive.Timestamp{T: 11, I: 1}))
+		require.True(t, o.check(observedOrderStage, primitive.Timestamp{}))
+	})
+	t.Run("should reject cluster times going backwards within a stage", func(t *testing.T) {
+		o := &ordering{}
+		require.True(t, o.check(publishedOrderStage, primitive.Timestamp{T: 10, I: 2}))
+
+		require.False(t, o.check(publishedOrderStage, primitive.Timestamp{T: 10, I: 1}))
+		require.True(t, o.check(observedOrderStage, primitive.Timestamp{T: 10, I: 1}))
+		require.True(t, o.check(publishedOrderStage, primitive.Timestamp{T: 10, I: 3}))
+	})
+}
+
+func TestChangeStreamWatcher_checkOrder(t *testing.T) {
+	newWatcher := func(strict bool, stages *[]string) *changeStreamWatcher {
+		client := &DefaultClient{logger: slog.Default(), onChangeEventOutOfOrderEvent: func(_, _, stage string) {
+			*stages = append(*stages, stage)
+		}}
+		return &changeStreamWatcher{client: client, opts: &WatchCollectionOptions{StrictOrdering: strict}}
+	}
+
+	t.Run("should report the change events out of order", func(t *testing.T) {
+		var stages []string
+		w := newWatcher(false, &stages)
+		require.NoError(t, w.checkOrder(publishedOrderStage, primitive.Timestamp{T: 10}, "8263"))
+
+		require.NoError(t, w.checkOrder(publishedOrderStage, primitive.Timestamp{T: 9}, "8262"))
+		require.Equal(t, []string{"published"}, stages)
+	})
+	t.Run("should fail once a change event is out of order with strict ordering", func(t *testing.T) {
+		var stages []string
+		w := newWatcher(true, &stages)
+		require.NoError(t, w.checkOrder(observedOrderStage, primitive.Timestamp{T: 10}, "8263"))
+
+		err := w.checkOrder(observedOrderStage, primitive.Timestamp{T: 9}, "8262")
+		require.ErrorIs(t, err, ErrOutOfOrder)
+		require.Equal(t, []string{"observed"}, stages)
+	})
+}
+
+func Test_clusterTimeOf(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.D{{Key: "clusterTime", Value: primitive.Timestamp{T: 1700000000, I: 3}}})
+
+	require.Equal(t, primitive.Timestamp{T: 1700000000, I: 3}, clusterTimeOf(changeEvent))
+	require.True(t, clusterTimeOf(bson.Raw{}).IsZero())
+}
@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OversizedPolicy represents what is done with change events whose encoded payload exceeds the maximum payload
+// accepted by NATS.
+type OversizedPolicy string
+
+const (
+	// FailOversizedPolicy fails publishing the change event, stopping the watcher.
+	FailOversizedPolicy OversizedPolicy = "fail"
+
+	// TruncateOversizedPolicy removes the document fields from the change event, i.e. the full document, the document
+	// before the change, and the update description, and flags it as truncated.
+	TruncateOversizedPolicy OversizedPolicy = "truncate"
+
+	// DropOversizedPolicy skips the change event.
+	DropOversizedPolicy OversizedPolicy = "drop"
+
+	// DlqOversizedPolicy publishes a reference to the change event to a dead letter subject.
+	DlqOversizedPolicy OversizedPolicy = "dlq"
+
+	// OffloadOversizedPolicy stores the change event in a NATS object store bucket, and publishes a reference to it.
+	OffloadOversizedPolicy OversizedPolicy = "offload"
+)
+
+var OversizedPolicies = []OversizedPolicy{
+	FailOversizedPolicy,
+	TruncateOversizedPolicy,
+	DropOversizedPolicy,
+	DlqOversizedPolicy,
+	OffloadOversizedPolicy,
+}
+
+// truncatedFields are the fields of a change event that hold documents, removed by TruncateOversizedPolicy.
+var truncatedFields = []string{"fullDocument", "fullDocumentBeforeChange", "updateDescription"}
+
+// truncate returns a copy of the given change event without its document fields, flagged as truncated.
+func truncate(changeEvent bson.Raw) (bson.Raw, error) {
+	elems, err := changeEvent.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(bson.D, 0, len(elems)+1)
+	for _, elem := range elems {
+		if !slices.Contains(truncatedFields, elem.Key()) {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		}
+	}
+	doc = append(doc, bson.E{Key: "truncated", Value: true})
+	return bson.Marshal(doc)
+}
+
+// encodeTruncated truncates the given change event, then encodes it like the change events of the given options.
+func encodeTruncated(changeEvent bson.Raw, opts *WatchCollectionOptions) ([]byte, error) {
+	truncated, err := truncate(changeEvent)
+	if err != nil {
+		return nil, err
+	}
+	return encodeChangeEvent(truncated, opts)
+}
@@ -0,0 +1,24 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_encodeTruncated(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "update"},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "items", Value: bson.A{"a", "b"}}}},
+		{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "items", Value: bson.A{"a"}}}},
+		{Key: "updateDescription", Value: bson.D{{Key: "updatedFields", Value: bson.D{}}}},
+	})
+	require.NoError(t, err)
+
+	data, err := encodeTruncated(changeEvent, &WatchCollectionOptions{Encoder: JsonEncoder})
+
+	require.NoError(t, err)
+	require.JSONEq(t, `{"operationType":"update","documentKey":{"_id":"order-1"},"truncated":true}`, string(data))
+}
@@ -0,0 +1,160 @@
+package mongo
+
+import (
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// PatchFormat represents how the changes of the documents updated or replaced are published, instead of their full
+// documents.
+type PatchFormat string
+
+const (
+	// JsonPatchFormat publishes an RFC 6902 JSON Patch, i.e. the list of add, remove and replace operations turning the
+	// pre-image of the document into its post-image.
+	JsonPatchFormat PatchFormat = "json"
+
+	// MergePatchFormat publishes an RFC 7386 JSON Merge Patch, i.e. the document holding the fields changed, with null
+	// as the value of the removed ones.
+	MergePatchFormat PatchFormat = "merge"
+)
+
+var PatchFormats = []PatchFormat{
+	JsonPatchFormat,
+	MergePatchFormat,
+}
+
+// patchField is the field of the change events holding their patch.
+const patchField = "patch"
+
+// patchedFields are the fields of the change events replaced by their patch.
+var patchedFields = []string{"fullDocument", "fullDocumentBeforeChange", "updateDescription"}
+
+// patch returns a copy of the given change event whose full documents are replaced by the patch turning its pre-image
+// into its post-image, in the given format, if any. The change events which are not updates or replacements, or which
+// lack either image, e.g. since the collection does not record pre-images, are returned as is.
+func patch(changeEvent bson.Raw, format PatchFormat) (bson.Raw, error) {
+	if format == "" {
+		return changeEvent, nil
+	}
+	operationType, _ := changeEvent.Lookup("operationType").StringValueOK()
+	before, beforeOk := changeEvent.Lookup("fullDocumentBeforeChange").DocumentOK()
+	after, afterOk := changeEvent.Lookup("fullDocument").DocumentOK()
+	if (operationType != updateOperationType && operationType != replacOperationType) || !beforeOk || !afterOk {
+		return changeEvent, nil
+	}
+
+	var value any
+	var err error
+	if format == MergePatchFormat {
+		value, err = mergePatch(before, after)
+	} else {
+		ops := make(bson.A, 0)
+		err = jsonPatch(before, after, "", &ops)
+		value = ops
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	elems, err := changeEvent.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(bson.D, 0, len(elems))
+	for _, elem := range elems {
+		switch key := elem.Key(); {
+		case key == "fullDocument":
+			// the patch takes the place of the full document
+			doc = append(doc, bson.E{Key: patchField, Value: value})
+		case !slices.Contains(patchedFields, key):
+			doc = append(doc, bson.E{Key: key, Value: elem.Value()})
+		}
+	}
+	return bson.Marshal(doc)
+}
+
+// jsonPatch appends the RFC 6902 operations turning the given document into the other one to the given operations,
+// with the given JSON Pointer as the path of the document. Nested documents are patched field by field, while arrays
+// and other values are replaced as a whole.
+func jsonPatch(before, after bson.Raw, path string, ops *bson.A) error {
+	beforeElems, err := before.Elements()
+	if err != nil {
+		return err
+	}
+	afterElems, err := after.Elements()
+	if err != nil {
+		return err
+	}
+	for _, elem := range beforeElems {
+		if _, err = after.LookupErr(elem.Key()); err != nil {
+			*ops = append(*ops, bson.D{{Key: "op", Value: "remove"}, {Key: "path", Value: pointer(path, elem.Key())}})
+		}
+	}
+	for _, elem := range afterElems {
+		fieldPath := pointer(path, elem.Key())
+		value, err := before.LookupErr(elem.Key())
+		switch {
+		case err != nil:
+			*ops = append(*ops, bson.D{{Key: "op", Value: "add"}, {Key: "path", Value: fieldPath},
+				{Key: "value", Value: elem.Value()}})
+		case value.Type == bsontype.EmbeddedDocument && elem.Value().Type == bsontype.EmbeddedDocument:
+			if err = jsonPatch(value.Document(), elem.Value().Document(), fieldPath, ops); err != nil {
+				return err
+			}
+		case !value.Equal(elem.Value()):
+			*ops = append(*ops, bson.D{{Key: "op", Value: "replace"}, {Key: "path", Value: fieldPath},
+				{Key: "value", Value: elem.Value()}})
+		}
+	}
+	return nil
+}
+
+// pointerEscaper escapes the characters of the keys which have a meaning in a JSON Pointer.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// pointer returns the JSON Pointer of the given key of the document with the given JSON Pointer.
+func pointer(path, key string) string {
+	return path + "/" + pointerEscaper.Replace(key)
+}
+
+// mergePatch returns the RFC 7386 merge patch turning the given document into the other one. Nested documents are
+// patched field by field, while arrays and other values are replaced as a whole. Since null removes a field, a field
+// set to null is removed.
+func mergePatch(before, after bson.Raw) (bson.D, error) {
+	beforeElems, err := before.Elements()
+	if err != nil {
+		return nil, err
+	}
+	afterElems, err := after.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(bson.D, 0)
+	for _, elem := range beforeElems {
+		if _, err = after.LookupErr(elem.Key()); err != nil {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: nil})
+		}
+	}
+	for _, elem := range afterElems {
+		value, err := before.LookupErr(elem.Key())
+		switch {
+		case err != nil:
+			doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		case value.Type == bsontype.EmbeddedDocument && elem.Value().Type == bsontype.EmbeddedDocument:
+			nested, err := mergePatch(value.Document(), elem.Value().Document())
+			if err != nil {
+				return nil, err
+			}
+			if len(nested) > 0 {
+				doc = append(doc, bson.E{Key: elem.Key(), Value: nested})
+			}
+		case !value.Equal(elem.Value()):
+			doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		}
+	}
+	return doc, nil
+}
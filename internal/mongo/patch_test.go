@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_patch(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "update"},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+		{Key: "updateDescription", Value: bson.D{{Key: "updatedFields", Value: bson.D{{Key: "status", Value: "paid"}}}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}, {Key: "status", Value: "paid"},
+			{Key: "customer", Value: bson.D{{Key: "name", Value: "Ada"}, {Key: "tier/level", Value: 2}}},
+			{Key: "tags", Value: bson.A{"a", "b"}}, {Key: "paidAt", Value: "2024-05-01"}}},
+		{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "_id", Value: "order-1"}, {Key: "status", Value: "new"},
+			{Key: "customer", Value: bson.D{{Key: "name", Value: "Ada"}, {Key: "tier/level", Value: 1}}},
+			{Key: "tags", Value: bson.A{"a"}}, {Key: "note", Value: "gift"}}},
+	})
+	require.NoError(t, err)
+
+	t.Run("should replace the full documents by a json patch", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Patch: JsonPatchFormat})
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"operationType":"update","documentKey":{"_id":"order-1"},"patch":[
+			{"op":"remove","path":"/note"},
+			{"op":"replace","path":"/status","value":"paid"},
+			{"op":"replace","path":"/customer/tier~1level","value":2},
+			{"op":"replace","path":"/tags","value":["a","b"]},
+			{"op":"add","path":"/paidAt","value":"2024-05-01"}]}`, string(data))
+	})
+	t.Run("should replace the full documents by a merge patch", func(t *testing.T) {
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Patch: MergePatchFormat})
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"operationType":"update","documentKey":{"_id":"order-1"},"patch":{"note":null,
+			"status":"paid","customer":{"tier/level":2},"tags":["a","b"],"paidAt":"2024-05-01"}}`, string(data))
+	})
+	t.Run("should publish the full documents if the pre-image is missing", func(t *testing.T) {
+		changeEvent, err := bson.Marshal(bson.D{{Key: "operationType", Value: "update"},
+			{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+		require.NoError(t, err)
+
+		patched, err := patch(changeEvent, JsonPatchFormat)
+
+		require.NoError(t, err)
+		require.Equal(t, bson.Raw(changeEvent), patched)
+	})
+	t.Run("should not patch other change events", func(t *testing.T) {
+		changeEvent, err := bson.Marshal(bson.D{{Key: "operationType", Value: "delete"},
+			{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+		require.NoError(t, err)
+
+		patched, err := patch(changeEvent, MergePatchFormat)
+
+		require.NoError(t, err)
+		require.Equal(t, bson.Raw(changeEvent), patched)
+	})
+	t.Run("should publish an empty patch if the document is unchanged", func(t *testing.T) {
+		changeEvent, err := bson.Marshal(bson.D{{Key: "operationType", Value: "replace"},
+			{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+			{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "_id", Value: "order-1"}}}})
+		require.NoError(t, err)
+
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Patch: JsonPatchFormat})
+
+		require.NoError(t, err)
+		require.JSONEq(t, `{"operationType":"replace","patch":[]}`, string(data))
+	})
+}
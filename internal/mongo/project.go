@@ -0,0 +1,29 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// excludedFieldsParents are the fields of a change event that hold documents, from which excluded fields are removed.
+var excludedFieldsParents = []string{"fullDocument", "fullDocumentBeforeChange", "updateDescription.updatedFields"}
+
+// changeStreamPipeline returns the aggregation pipeline of the change stream of the watched collection.
+// Excluded fields are removed by an $unset stage, so that MongoDB never sends them to the connector, and large change
+// events are split last, once their fields are removed.
+func changeStreamPipeline(opts *WatchCollectionOptions) mongo.Pipeline {
+	pipeline := mongo.Pipeline{}
+	if len(opts.ExcludeFields) > 0 {
+		unset := make(bson.A, 0, len(opts.ExcludeFields)*len(excludedFieldsParents))
+		for _, parent := range excludedFieldsParents {
+			for _, field := range opts.ExcludeFields {
+				unset = append(unset, parent+"."+field)
+			}
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: unset}})
+	}
+	if opts.SplitLargeEvents {
+		pipeline = append(pipeline, splitLargeEventStage)
+	}
+	return pipeline
+}
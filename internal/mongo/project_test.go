@@ -0,0 +1,52 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_changeStreamPipeline(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *WatchCollectionOptions
+		want mongo.Pipeline
+	}{
+		{
+			name: "should return an empty pipeline if no field is excluded",
+			opts: &WatchCollectionOptions{},
+			want: mongo.Pipeline{},
+		},
+		{
+			name: "should unset the excluded fields from the documents of the change event",
+			opts: &WatchCollectionOptions{ExcludeFields: []string{"password", "profile.avatar"}},
+			want: mongo.Pipeline{{{Key: "$unset", Value: bson.A{
+				"fullDocument.password",
+				"fullDocument.profile.avatar",
+				"fullDocumentBeforeChange.password",
+				"fullDocumentBeforeChange.profile.avatar",
+				"updateDescription.updatedFields.password",
+				"updateDescription.updatedFields.profile.avatar",
+			}}}},
+		},
+		{
+			name: "should split large events last",
+			opts: &WatchCollectionOptions{ExcludeFields: []string{"password"}, SplitLargeEvents: true},
+			want: mongo.Pipeline{
+				{{Key: "$unset", Value: bson.A{
+					"fullDocument.password",
+					"fullDocumentBeforeChange.password",
+					"updateDescription.updatedFields.password",
+				}}},
+				{{Key: "$changeStreamSplitLargeEvent", Value: bson.D{}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, changeStreamPipeline(tt.opts))
+		})
+	}
+}
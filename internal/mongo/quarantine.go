@@ -0,0 +1,112 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// quarantinedEvent is a change event which could not be published, even after being retried, stored in the quarantine
+// collection of its collection along with its error and position, so that it can be redriven once the underlying issue
+// is fixed, instead of stopping the watcher.
+type quarantinedEvent struct {
+	Id primitive.ObjectID `bson:"_id,omitempty"`
+	// ChangeEvent is the raw BSON of the change event, before it was encoded, so that it is redriven through the
+	// current pipeline of its collection.
+	ChangeEvent bson.Raw `bson:"changeEvent"`
+	Subj        string   `bson:"subj"`
+	MsgId       string   `bson:"msgId"`
+	Error       string   `bson:"error"`
+	// ResumeToken and Time are the position of the change event in the change stream.
+	ResumeToken   string    `bson:"resumeToken"`
+	Time          time.Time `bson:"time"`
+	QuarantinedAt time.Time `bson:"quarantinedAt"`
+}
+
+// quarantine stores the given change event, which could not be published with the given error, into the quarantine
+// collection of the watched collection, if it has one. It returns true if the change event is quarantined, in which
+// case the watcher goes on with the next change events.
+func (w *changeStreamWatcher) quarantine(ctx context.Context, event *changeEvent, err error) bool {
+	if w.opts.QuarantineCollName == "" || event.raw == nil || ctx.Err() != nil {
+		return false
+	}
+	coll := w.client.mongoClient().Database(w.opts.ResumeTokensDbName).Collection(w.opts.QuarantineCollName)
+	if _, insertErr := coll.InsertOne(ctx, &quarantinedEvent{
+		ChangeEvent:   event.raw,
+		Subj:          event.Subj,
+		MsgId:         event.MsgId,
+		Error:         err.Error(),
+		ResumeToken:   event.token,
+		Time:          event.Time,
+		QuarantinedAt: time.Now(),
+	}); insertErr != nil {
+		w.client.logger.Error("could not quarantine change event", "collName", w.opts.WatchedCollName,
+			"msgId", event.MsgId, "err", insertErr)
+		return false
+	}
+	w.client.logger.Warn("could not publish change event, quarantined it", "collName", w.opts.WatchedCollName,
+		"quarantine", w.opts.QuarantineCollName, "subj", event.Subj, "msgId", event.MsgId, "err", err)
+	if w.client.onChangeEventQuarantinedEvent != nil {
+		w.client.onChangeEventQuarantinedEvent(w.opts.WatchedDbName, w.opts.WatchedCollName)
+	}
+	return true
+}
+
+// Redrive re-processes the change events quarantined for the watched collection, in the order they were quarantined,
+// through the current pipeline of the collection, then hands each of them to its change event handler, and removes it
+// from the quarantine once published. It stops at the first change event that fails again, which stays quarantined,
+// and returns the number of change events that were redriven.
+func (c *DefaultClient) Redrive(ctx context.Context, opts *WatchCollectionOptions) (int, error) {
+	coll := c.mongoClient().Database(opts.ResumeTokensDbName).Collection(opts.QuarantineCollName)
+	cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, fmt.Errorf("could not find quarantined change events: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	routeOpts := RouteOptions(opts)
+	redriven := 0
+	for cursor.Next(ctx) {
+		var quarantined quarantinedEvent
+		if err = cursor.Decode(&quarantined); err != nil {
+			return redriven, fmt.Errorf("could not decode quarantined change event: %v", err)
+		}
+		event, err := redriveChangeEvent(opts, routeOpts, &quarantined)
+		if err != nil {
+			return redriven, fmt.Errorf("could not redrive change event %v: %w", quarantined.MsgId, err)
+		}
+		if event != nil {
+			if err = opts.ChangeEventHandler(ctx, event); err != nil {
+				return redriven, fmt.Errorf("could not redrive change event %v: %w", quarantined.MsgId, err)
+			}
+		}
+		if _, err = coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: quarantined.Id}}); err != nil {
+			return redriven, fmt.Errorf("could not remove redriven change event %v: %v", quarantined.MsgId, err)
+		}
+		redriven++
+	}
+	if err = cursor.Err(); err != nil {
+		return redriven, fmt.Errorf("could not read quarantined change events: %v", err)
+	}
+	c.logger.Info("redrive completed", "dbName", opts.WatchedDbName, "collName", opts.WatchedCollName,
+		"quarantine", opts.QuarantineCollName, "redriven", redriven)
+	return redriven, nil
+}
+
+// redriveChangeEvent returns the given quarantined change event built with the current pipeline of its collection,
+// with its original message id, so that it is discarded as a duplicate if it was stored nonetheless, or nil if it is
+// now dropped by the filter or the oversized policy of the collection.
+func redriveChangeEvent(opts *WatchCollectionOptions, routeOpts []*WatchCollectionOptions,
+	quarantined *quarantinedEvent) (*ChangeEvent, error) {
+	operationType, _ := quarantined.ChangeEvent.Lookup("operationType").StringValueOK()
+	event, err := buildChangeEvent(opts, routeOpts, quarantined.ChangeEvent, operationType, quarantined.ResumeToken)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	event.MsgId = quarantined.MsgId
+	return event, nil
+}
@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_redriveChangeEvent(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.D{{Key: "_id", Value: bson.D{{Key: "_data", Value: "8263"}}},
+		{Key: "operationType", Value: "insert"}, {Key: "documentKey", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}, {Key: "status", Value: "active"}}}})
+	quarantined := &quarantinedEvent{ChangeEvent: changeEvent, Subj: "ORDERS.insert", MsgId: "msg-1",
+		ResumeToken: "8263", Error: "could not publish to nats"}
+
+	t.Run("should build the change event with the current pipeline and its original message id", func(t *testing.T) {
+		opts := &WatchCollectionOptions{StreamName: "ORDERS", PayloadMode: SlimPayloadMode,
+			EventTypes: EventTypes{"insert": "order.created"}}
+
+		event, err := redriveChangeEvent(opts, nil, quarantined)
+
+		require.NoError(t, err)
+		require.Equal(t, "ORDERS.order.created", event.Subj)
+		require.Equal(t, "msg-1", event.MsgId)
+		require.Equal(t, "insert", event.OperationType)
+		require.Equal(t, "8263", event.Bookkeeping.ResumeToken)
+		require.JSONEq(t, `{"operationType":"insert","eventType":"order.created",
+			"fullDocument":{"_id":"order-1","status":"active"}}`, string(event.Data))
+	})
+	t.Run("should return nothing if the change event is now filtered out", func(t *testing.T) {
+		event, err := redriveChangeEvent(&WatchCollectionOptions{StreamName: "ORDERS",
+			Filter: &Filter{Field: "fullDocument.status", Operator: EqFilterOperator, Value: "archived"}}, nil,
+			quarantined)
+
+		require.NoError(t, err)
+		require.Nil(t, event)
+	})
+}
+
+func TestChangeStreamWatcher_quarantine(t *testing.T) {
+	event := &changeEvent{ChangeEvent: ChangeEvent{Subj: "ORDERS.insert", MsgId: "msg-1"}, raw: bson.Raw{}}
+
+	t.Run("should not quarantine without quarantine collection", func(t *testing.T) {
+		w := &changeStreamWatcher{client: &DefaultClient{logger: slog.Default()}, opts: &WatchCollectionOptions{}}
+
+		require.False(t, w.quarantine(context.Background(), event, errors.New("could not publish")))
+	})
+	t.Run("should not quarantine once the watcher is stopping", func(t *testing.T) {
+		w := &changeStreamWatcher{client: &DefaultClient{logger: slog.Default()},
+			opts: &WatchCollectionOptions{QuarantineCollName: "orders-quarantine"}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.False(t, w.quarantine(ctx, event, context.Canceled))
+	})
+}
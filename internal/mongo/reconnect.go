@@ -0,0 +1,81 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
+)
+
+const reconnectPingTimeout = 5 * time.Second
+
+// reconnectPolicy is the default retry policy of the watchers resuming once mongodb cannot be reached.
+var reconnectPolicy = retry.Policy{
+	InitialInterval: 1 * time.Second,
+	MaxInterval:     1 * time.Minute,
+	Multiplier:      2,
+}
+
+// mongoClient returns the current mongodb client.
+func (c *DefaultClient) mongoClient() *mongo.Client {
+	c.clientMu.RLock()
+	defer c.clientMu.RUnlock()
+	return c.client
+}
+
+// reconnectAfter waits for the next wait of the given backoff, and re-establishes the given client if mongodb could
+// not be reached. It returns false if the watcher must not resume.
+func (c *DefaultClient) reconnectAfter(ctx context.Context, failed *mongo.Client, opts *WatchCollectionOptions,
+	err error, backoff *retry.Backoff) bool {
+	if !isReconnectableError(err) || !backoff.Retries(retry.UnreachableClass) || ctx.Err() != nil {
+		return false
+	}
+	wait, ok := backoff.Next()
+	if !ok {
+		c.logger.Error("lost connection to mongodb, retry budget exhausted", "collName", opts.WatchedCollName,
+			"err", err)
+		return false
+	}
+	c.logger.Warn("lost connection to mongodb, resuming watcher after backoff", "collName", opts.WatchedCollName,
+		"backoff", wait, "err", err)
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		return false
+	}
+	c.reconnect(ctx, failed)
+	return true
+}
+
+// reconnect replaces the given failed client with a new one, connected with the same options, unless another watcher
+// already replaced it, or it is reachable again.
+func (c *DefaultClient) reconnect(ctx context.Context, failed *mongo.Client) {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+	if c.client != failed {
+		return
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, reconnectPingTimeout)
+	defer cancel()
+	if err := failed.Ping(pingCtx, readpref.Primary()); err == nil {
+		return
+	}
+
+	client, err := mongo.Connect(ctx, c.clientOpts)
+	if err != nil {
+		c.logger.Error("could not reconnect to mongodb", "err", err)
+		return
+	}
+	c.client = client
+	go func() {
+		// the change streams of the failed client are closed, their watchers resume with the new client
+		_ = failed.Disconnect(context.Background())
+	}()
+	c.logger.Info("reconnected to mongodb")
+	if c.onReconnectEvent != nil {
+		c.onReconnectEvent()
+	}
+}
@@ -0,0 +1,64 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
+)
+
+func TestDefaultClient_reconnectAfter(t *testing.T) {
+	opts := &WatchCollectionOptions{WatchedDbName: "db", WatchedCollName: "coll1"}
+	c := &DefaultClient{logger: slog.Default()}
+	policy := retry.Policy{InitialInterval: 10 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2}
+
+	t.Run("should not resume the watcher if the error is not recoverable", func(t *testing.T) {
+		backoff := policy.NewBackoff()
+
+		resume := c.reconnectAfter(context.Background(), nil, opts, errors.New("generic error"), backoff)
+
+		require.False(t, resume)
+		wait, _ := backoff.Next()
+		require.Equal(t, 10*time.Millisecond, wait, "the backoff is left untouched")
+	})
+	t.Run("should not resume the watcher if the connector is shutting down", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		resume := c.reconnectAfter(ctx, nil, opts, mongo.ErrClientDisconnected, policy.NewBackoff())
+
+		require.False(t, resume)
+	})
+	t.Run("should not resume the watcher if its retry policy does not retry unreachable errors", func(t *testing.T) {
+		backoff := policy.With(&retry.Policy{RetryOn: []retry.Class{retry.TransientClass}}).NewBackoff()
+
+		resume := c.reconnectAfter(context.Background(), nil, opts, mongo.ErrClientDisconnected, backoff)
+
+		require.False(t, resume)
+	})
+	t.Run("should not resume the watcher once its retry budget is exhausted", func(t *testing.T) {
+		backoff := policy.With(&retry.Policy{MaxAttempts: 1}).NewBackoff()
+
+		resume := c.reconnectAfter(context.Background(), nil, opts, mongo.ErrClientDisconnected, backoff)
+
+		require.False(t, resume)
+	})
+	t.Run("should resume the watcher after the backoff, which is doubled", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default(), client: &mongo.Client{}}
+		backoff := policy.NewBackoff()
+
+		start := time.Now()
+		resume := c.reconnectAfter(context.Background(), nil, opts, mongo.ErrClientDisconnected, backoff)
+
+		require.True(t, resume)
+		require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+		wait, _ := backoff.Next()
+		require.Equal(t, 20*time.Millisecond, wait)
+	})
+}
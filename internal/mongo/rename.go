@@ -0,0 +1,24 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// rename holds where a watched collection was renamed to, and when.
+type rename struct {
+	dbName      string
+	collName    string
+	clusterTime primitive.Timestamp
+}
+
+// renameOf returns the rename described by the given rename change event.
+func renameOf(changeEvent bson.Raw) (*rename, bool) {
+	dbName, dbOk := changeEvent.Lookup("to", "db").StringValueOK()
+	collName, collOk := changeEvent.Lookup("to", "coll").StringValueOK()
+	t, i, timeOk := changeEvent.Lookup("clusterTime").TimestampOK()
+	if !dbOk || !collOk || !timeOk {
+		return nil, false
+	}
+	return &rename{dbName: dbName, collName: collName, clusterTime: primitive.Timestamp{T: t, I: i}}, true
+}
@@ -0,0 +1,49 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_renameOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		changeEvent bson.M
+		want        *rename
+		wantOk      bool
+	}{
+		{
+			name: "should return the namespace the collection was renamed to",
+			changeEvent: bson.M{
+				"operationType": "rename",
+				"clusterTime":   primitive.Timestamp{T: 1700000000, I: 3},
+				"ns":            bson.M{"db": "shop", "coll": "orders"},
+				"to":            bson.M{"db": "shop", "coll": "orders_v2"},
+			},
+			want:   &rename{dbName: "shop", collName: "orders_v2", clusterTime: primitive.Timestamp{T: 1700000000, I: 3}},
+			wantOk: true,
+		},
+		{
+			name: "should not return a rename if the target namespace is missing",
+			changeEvent: bson.M{
+				"operationType": "rename",
+				"clusterTime":   primitive.Timestamp{T: 1700000000, I: 3},
+			},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := bson.Marshal(tt.changeEvent)
+			require.NoError(t, err)
+
+			got, ok := renameOf(raw)
+
+			require.Equal(t, tt.wantOk, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package mongo
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrInvalidReshape = errors.New("a reshape rule must be of the form `<field> -> <field>`, using the dot notation, or `<field> -> .` to lift a document to the root")
+
+// rootPath is the destination of the reshape rules lifting the fields of a document to the root of the change events.
+const rootPath = "."
+
+// Reshape renames and moves the fields of the published change events, e.g. to lift the full document to their root,
+// so that consumers migrating from another tool get the layout they expect. The change events are reshaped once their
+// subject and headers are computed, so only their payload is affected.
+type Reshape struct {
+	// Rules are the rules of the reshape, applied in order.
+	Rules []string
+	rules []reshapeRule
+}
+
+// reshapeRule moves the field at the from path to the to path, or merges its fields into the root if to is nil.
+type reshapeRule struct {
+	from []string
+	to   []string
+}
+
+// ParseReshape parses the given rules, each of the form `<field> -> <field>`, e.g. `operationType -> op`, or
+// `<field> -> .` to lift the fields of a document to the root, e.g. `fullDocument -> .`. No rules returns nil, which
+// reshapes nothing.
+func ParseReshape(rules []string) (*Reshape, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	r := &Reshape{Rules: rules, rules: make([]reshapeRule, 0, len(rules))}
+	for _, rule := range rules {
+		from, to, ok := strings.Cut(rule, "->")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || !validPath(from) || (to != rootPath && !validPath(to)) {
+			return nil, ErrInvalidReshape
+		}
+		parsed := reshapeRule{from: strings.Split(from, ".")}
+		if to != rootPath {
+			parsed.to = strings.Split(to, ".")
+		}
+		r.rules = append(r.rules, parsed)
+	}
+	return r, nil
+}
+
+func validPath(path string) bool {
+	return path != "" && !slices.Contains(strings.Split(path, "."), "") && !strings.ContainsAny(path, " \t\r\n$")
+}
+
+// reshape returns the given change event reshaped by the rules of the given reshape, applied in order. The rules whose
+// field is missing, e.g. once the change event is truncated, are skipped, and the rules lifting a field which is not a
+// document are skipped as well. The lifted fields replace the root fields with the same name.
+func reshape(changeEvent bson.Raw, r *Reshape) (bson.Raw, error) {
+	if r == nil {
+		return changeEvent, nil
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(changeEvent, &doc); err != nil {
+		return nil, err
+	}
+	for _, rule := range r.rules {
+		value, ok := lookupPath(doc, rule.from)
+		if !ok {
+			continue
+		}
+		if rule.to == nil {
+			fields, ok := value.(bson.D)
+			if !ok {
+				continue
+			}
+			doc = removePath(doc, rule.from)
+			for _, field := range fields {
+				doc = setPath(doc, []string{field.Key}, field.Value)
+			}
+			continue
+		}
+		doc = setPath(removePath(doc, rule.from), rule.to, value)
+	}
+	return bson.Marshal(doc)
+}
+
+// lookupPath returns the value at the given path of the given document.
+func lookupPath(doc bson.D, path []string) (any, bool) {
+	for _, elem := range doc {
+		if elem.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return elem.Value, true
+		}
+		nested, ok := elem.Value.(bson.D)
+		if !ok {
+			return nil, false
+		}
+		return lookupPath(nested, path[1:])
+	}
+	return nil, false
+}
+
+// setPath returns the given document with the given value at the given path, replacing the field already there, and
+// creating the missing intermediate documents.
+func setPath(doc bson.D, path []string, value any) bson.D {
+	for i, elem := range doc {
+		if elem.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			doc[i].Value = value
+			return doc
+		}
+		nested, _ := elem.Value.(bson.D)
+		doc[i].Value = setPath(nested, path[1:], value)
+		return doc
+	}
+	if len(path) == 1 {
+		return append(doc, bson.E{Key: path[0], Value: value})
+	}
+	return append(doc, bson.E{Key: path[0], Value: setPath(nil, path[1:], value)})
+}
+
+// encodeChangeEvent replaces the full documents of the given change event by their patch if the given options publish
+// patches, or moves them to their envelope, if any, removes its bookkeeping fields if they publish slim payloads,
+// reshapes and flattens it with their reshape and flatten, if any, converts its values with their conversions if it is
+// encoded as JSON, then encodes it with their encoder and JSON flavor. The errors of the steps before the encoding are
+// transform errors.
+func encodeChangeEvent(changeEvent bson.Raw, opts *WatchCollectionOptions) ([]byte, error) {
+	changeEvent, err := patch(changeEvent, opts.Patch)
+	if err != nil {
+		return nil, &transformError{err: err}
+	}
+	if changeEvent, err = envelop(changeEvent, opts.Envelope); err != nil {
+		return nil, &transformError{err: err}
+	}
+	if opts.PayloadMode == SlimPayloadMode {
+		if changeEvent, err = slim(changeEvent); err != nil {
+			return nil, &transformError{err: err}
+		}
+	}
+	reshaped, err := reshape(changeEvent, opts.Reshape)
+	if err != nil {
+		return nil, &transformError{err: err}
+	}
+	if reshaped, err = flatten(reshaped, opts.Flatten); err != nil {
+		return nil, &transformError{err: err}
+	}
+	if opts.Encoder != BsonEncoder {
+		if reshaped, err = convert(reshaped, conversionsOf(opts)); err != nil {
+			return nil, &transformError{err: err}
+		}
+	}
+	return encode(reshaped, opts.Encoder, opts.JsonFlavor)
+}
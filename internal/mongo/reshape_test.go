@@ -0,0 +1,66 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseReshape(t *testing.T) {
+	t.Run("should parse the rules", func(t *testing.T) {
+		r, err := ParseReshape([]string{"operationType -> op", "fullDocument->.", "ns.coll -> source.collection"})
+
+		require.NoError(t, err)
+		require.Equal(t, []reshapeRule{
+			{from: []string{"operationType"}, to: []string{"op"}},
+			{from: []string{"fullDocument"}},
+			{from: []string{"ns", "coll"}, to: []string{"source", "collection"}},
+		}, r.rules)
+	})
+	t.Run("should return nil if there are no rules", func(t *testing.T) {
+		r, err := ParseReshape(nil)
+
+		require.NoError(t, err)
+		require.Nil(t, r)
+	})
+	t.Run("should return error if a rule is invalid", func(t *testing.T) {
+		for _, rule := range []string{"operationType", "-> op", "operationType ->", ". -> op", "ns..coll -> coll",
+			"operationType -> $op"} {
+			_, err := ParseReshape([]string{rule})
+
+			require.ErrorIs(t, err, ErrInvalidReshape, rule)
+		}
+	})
+}
+
+func Test_reshape(t *testing.T) {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: "8264"}}},
+		{Key: "operationType", Value: "insert"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "shop"}, {Key: "coll", Value: "orders"}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}, {Key: "total", Value: int32(42)}}},
+	})
+	require.NoError(t, err)
+
+	t.Run("should move and lift the fields in order", func(t *testing.T) {
+		r, err := ParseReshape([]string{"_id._data -> token", "operationType -> op", "ns.coll -> source.collection",
+			"fullDocument -> ."})
+		require.NoError(t, err)
+
+		data, err := encodeChangeEvent(changeEvent, &WatchCollectionOptions{Reshape: r})
+
+		require.NoError(t, err)
+		require.Equal(t, `{"_id":"order-1","ns":{"db":"shop"},"token":"8264","op":"insert",`+
+			`"source":{"collection":"orders"},"total":42}`, string(data))
+	})
+	t.Run("should skip the rules of missing fields and of lifted values which are not documents", func(t *testing.T) {
+		r, err := ParseReshape([]string{"fullDocumentBeforeChange -> before", "operationType -> ."})
+		require.NoError(t, err)
+
+		reshaped, err := reshape(changeEvent, r)
+
+		require.NoError(t, err)
+		require.Equal(t, changeEvent, []byte(reshaped))
+	})
+}
@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Route routes the change events whose document matches all its conditions to another stream than the one of their
+// collection.
+type Route struct {
+	// When holds the values the fields of the document must have, by field. Nested fields are specified by using the
+	// dot notation.
+	When       map[string]string
+	StreamName string
+	// SubjectTemplate computes the subjects of the routed change events, after the stream name. If nil, the subject
+	// template of the collection is used, if any.
+	SubjectTemplate *Template
+}
+
+// matches returns true if the fields of the full document, or of the document before the change if the full document
+// is not available, have the values of all the conditions of the route.
+func (r *Route) matches(changeEvent bson.Raw) bool {
+	for field, want := range r.When {
+		path := strings.Split(field, ".")
+		value, ok := lookupString(changeEvent, append([]string{"fullDocument"}, path...)...)
+		if !ok {
+			value, ok = lookupString(changeEvent, append([]string{"fullDocumentBeforeChange"}, path...)...)
+		}
+		if !ok || value != want {
+			return false
+		}
+	}
+	return true
+}
+
+// RouteOptions returns the options of the change events routed by each route of the given options, in order, i.e.
+// their stream name and subject template applied to the options of the collection.
+func RouteOptions(opts *WatchCollectionOptions) []*WatchCollectionOptions {
+	routeOpts := make([]*WatchCollectionOptions, 0, len(opts.Routes))
+	for _, route := range opts.Routes {
+		o := *opts
+		o.StreamName = route.StreamName
+		if route.SubjectTemplate != nil {
+			o.SubjectTemplate = route.SubjectTemplate
+		}
+		o.Routes = nil
+		routeOpts = append(routeOpts, &o)
+	}
+	return routeOpts
+}
+
+// route returns the options of the given change event, i.e. the ones of the first route it matches, in order, or the
+// given options of its collection, which is the default route. Change events without documents, such as renames, are
+// never routed.
+func route(opts *WatchCollectionOptions, routeOpts []*WatchCollectionOptions, changeEvent bson.Raw) *WatchCollectionOptions {
+	for i := range opts.Routes {
+		if opts.Routes[i].matches(changeEvent) {
+			return routeOpts[i]
+		}
+	}
+	return opts
+}
@@ -0,0 +1,95 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRoute_matches(t *testing.T) {
+	tests := []struct {
+		name        string
+		when        map[string]string
+		changeEvent bson.M
+		want        bool
+	}{
+		{
+			name:        "should match if all the fields of the full document have the values",
+			when:        map[string]string{"region": "eu", "customer.tier": "gold"},
+			changeEvent: bson.M{"fullDocument": bson.M{"region": "eu", "customer": bson.M{"tier": "gold"}}},
+			want:        true,
+		},
+		{
+			name:        "should not match if a field has another value",
+			when:        map[string]string{"region": "eu", "customer.tier": "gold"},
+			changeEvent: bson.M{"fullDocument": bson.M{"region": "eu", "customer": bson.M{"tier": "silver"}}},
+			want:        false,
+		},
+		{
+			name:        "should not match if a field is missing",
+			when:        map[string]string{"region": "eu"},
+			changeEvent: bson.M{"fullDocument": bson.M{"country": "fr"}},
+			want:        false,
+		},
+		{
+			name:        "should match the document before the change if the full document is missing",
+			when:        map[string]string{"region": "eu"},
+			changeEvent: bson.M{"fullDocumentBeforeChange": bson.M{"region": "eu"}},
+			want:        true,
+		},
+		{
+			name:        "should match values of other types than strings",
+			when:        map[string]string{"priority": "1", "express": "true"},
+			changeEvent: bson.M{"fullDocument": bson.M{"priority": int32(1), "express": true}},
+			want:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changeEvent, _ := bson.Marshal(tt.changeEvent)
+			r := &Route{When: tt.when, StreamName: "ORDERS_EU"}
+
+			require.Equal(t, tt.want, r.matches(changeEvent))
+		})
+	}
+}
+
+func Test_route(t *testing.T) {
+	tmpl := mustTemplate(t, `{{ .OperationType }}`)
+	opts := &WatchCollectionOptions{
+		WatchedDbName:   "shop",
+		WatchedCollName: "orders",
+		StreamName:      "ORDERS",
+		Routes: []Route{
+			{When: map[string]string{"region": "eu"}, StreamName: "ORDERS_EU", SubjectTemplate: tmpl},
+			{When: map[string]string{"country": "fr"}, StreamName: "ORDERS_FR"},
+		},
+	}
+	routeOpts := RouteOptions(opts)
+
+	t.Run("should apply the stream name and subject template of each route", func(t *testing.T) {
+		require.Len(t, routeOpts, 2)
+		require.Equal(t, "ORDERS_EU", routeOpts[0].StreamName)
+		require.Same(t, tmpl, routeOpts[0].SubjectTemplate)
+		require.Equal(t, "ORDERS_FR", routeOpts[1].StreamName)
+		require.Nil(t, routeOpts[1].SubjectTemplate)
+		require.Nil(t, routeOpts[1].Routes)
+		require.Equal(t, "orders", routeOpts[1].WatchedCollName)
+	})
+	t.Run("should route to the first matching route", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.M{"fullDocument": bson.M{"region": "eu", "country": "fr"}})
+
+		got := route(opts, routeOpts, changeEvent)
+
+		require.Same(t, routeOpts[0], got)
+		subj, err := subject(got, "insert", changeEvent)
+		require.NoError(t, err)
+		require.Equal(t, "ORDERS_EU.insert", subj)
+	})
+	t.Run("should route to the default route if no route matches", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.M{"fullDocument": bson.M{"region": "us"}})
+
+		require.Same(t, opts, route(opts, routeOpts, changeEvent))
+	})
+}
@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// schemaChangeOperationTypes are the operation types of the change events describing structural changes, most of
+// which are only returned by change streams showing expanded events.
+var schemaChangeOperationTypes = map[string]struct{}{
+	"create":                   {},
+	"createIndexes":            {},
+	"dropIndexes":              {},
+	"modify":                   {},
+	"shardCollection":          {},
+	"reshardCollection":        {},
+	"refineCollectionShardKey": {},
+	"drop":                     {},
+	"dropDatabase":             {},
+}
+
+// isSchemaChange reports whether the given operation type describes a structural change.
+func isSchemaChange(operationType string) bool {
+	_, ok := schemaChangeOperationTypes[operationType]
+	return ok
+}
+
+// schemaChangeSubject returns the subject of the given schema change, i.e. <stream>.<db>.<coll>.<op>.
+func schemaChangeSubject(opts *WatchCollectionOptions, operationType string) string {
+	return strings.Join([]string{
+		opts.SchemaChangesStreamName,
+		subjectTokenReplacer.Replace(opts.WatchedDbName),
+		subjectTokenReplacer.Replace(opts.WatchedCollName),
+		operationType,
+	}, ".")
+}
+
+// SchemaChangesSubjectFilter returns the nats subject filter matching the subjects of all the schema changes
+// published to the given stream.
+func SchemaChangesSubjectFilter(streamName string) string {
+	return streamName + ".*.*.*"
+}
+
+// encodeSchemaChange normalizes the given schema change, then encodes it with the given encoder.
+// The normalized schema change holds its operation type, the namespace it applies to, its cluster time, and the
+// description of the operation, if any, whatever the operation type.
+func encodeSchemaChange(changeEvent bson.Raw, opts *WatchCollectionOptions) ([]byte, error) {
+	doc := bson.D{
+		{Key: "operationType", Value: changeEvent.Lookup("operationType").StringValue()},
+		{Key: "database", Value: opts.WatchedDbName},
+		{Key: "collection", Value: opts.WatchedCollName},
+	}
+	if clusterTime, err := changeEvent.LookupErr("clusterTime"); err == nil {
+		doc = append(doc, bson.E{Key: "clusterTime", Value: clusterTime})
+	}
+	if description, err := changeEvent.LookupErr("operationDescription"); err == nil {
+		doc = append(doc, bson.E{Key: "operationDescription", Value: description})
+	}
+	normalized, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return encode(normalized, opts.Encoder, opts.JsonFlavor)
+}
@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_schemaChangeSubject(t *testing.T) {
+	opts := &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "order.items",
+		SchemaChangesStreamName: "SCHEMA_CHANGES"}
+
+	require.Equal(t, "SCHEMA_CHANGES.shop.order_items.createIndexes", schemaChangeSubject(opts, "createIndexes"))
+	require.Equal(t, "SCHEMA_CHANGES.*.*.*", SchemaChangesSubjectFilter("SCHEMA_CHANGES"))
+}
+
+func Test_encodeSchemaChange(t *testing.T) {
+	opts := &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "orders", Encoder: JsonEncoder}
+
+	tests := []struct {
+		name        string
+		changeEvent bson.D
+		want        string
+	}{
+		{
+			name: "should normalize schema changes with an operation description",
+			changeEvent: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "_data", Value: "8263"}}},
+				{Key: "operationType", Value: "createIndexes"},
+				{Key: "clusterTime", Value: primitive.Timestamp{T: 1700000000, I: 1}},
+				{Key: "ns", Value: bson.D{{Key: "db", Value: "shop"}, {Key: "coll", Value: "orders"}}},
+				{Key: "operationDescription", Value: bson.D{{Key: "indexes", Value: bson.A{
+					bson.D{{Key: "name", Value: "code_1"}},
+				}}}},
+			},
+			want: `{"operationType":"createIndexes","database":"shop","collection":"orders",
+				"clusterTime":{"$timestamp":{"t":1700000000,"i":1}},
+				"operationDescription":{"indexes":[{"name":"code_1"}]}}`,
+		},
+		{
+			name: "should normalize schema changes without an operation description",
+			changeEvent: bson.D{
+				{Key: "operationType", Value: "drop"},
+				{Key: "clusterTime", Value: primitive.Timestamp{T: 1700000000, I: 2}},
+			},
+			want: `{"operationType":"drop","database":"shop","collection":"orders",
+				"clusterTime":{"$timestamp":{"t":1700000000,"i":2}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := bson.Marshal(tt.changeEvent)
+			require.NoError(t, err)
+
+			data, err := encodeSchemaChange(raw, opts)
+
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, string(data))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package mongo
+
+import (
+	"encoding/hex"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session holds the metadata of the logical session a change event originates from, i.e. of the transaction or the
+// retryable write which made the change.
+type Session struct {
+	// Lsid is the id of the logical session, hex-encoded if it is a UUID, otherwise in extended json.
+	Lsid string
+	// TxnNumber is the number of the transaction or of the retryable write within the logical session, or nil if the
+	// change event has none.
+	TxnNumber *int64
+	// ClusterTime is the cluster time of the change event, i.e. the time its transaction was committed at.
+	ClusterTime primitive.Timestamp
+}
+
+// sessionOf returns the logical session the given change event originates from, or nil if it has none.
+func sessionOf(changeEvent bson.Raw) *Session {
+	id, err := changeEvent.LookupErr("lsid", "id")
+	if err != nil {
+		return nil
+	}
+	s := &Session{Lsid: id.String()}
+	if _, data, ok := id.BinaryOK(); ok {
+		s.Lsid = hex.EncodeToString(data)
+	}
+	if txnNumber, ok := changeEvent.Lookup("txnNumber").AsInt64OK(); ok {
+		s.TxnNumber = &txnNumber
+	}
+	s.ClusterTime.T, s.ClusterTime.I, _ = changeEvent.Lookup("clusterTime").TimestampOK()
+	return s
+}
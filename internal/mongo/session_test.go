@@ -0,0 +1,21 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_sessionOf(t *testing.T) {
+	t.Run("should return the logical session of the change event", func(t *testing.T) {
+		txnNumber := int64(7)
+
+		require.Equal(t, &Session{Lsid: "abcd", TxnNumber: &txnNumber,
+			ClusterTime: primitive.Timestamp{T: 1714564800, I: 1}}, sessionOf(txnChangeEvent(t, "order-1")))
+	})
+	t.Run("should return nil if the change event has no logical session", func(t *testing.T) {
+		require.Nil(t, sessionOf(changeEventAt(t, time.Now())))
+	})
+}
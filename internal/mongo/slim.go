@@ -0,0 +1,77 @@
+package mongo
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PayloadMode represents which fields of the change events are published in their payload.
+type PayloadMode string
+
+const (
+	// FullPayloadMode publishes the change events with all their fields.
+	FullPayloadMode PayloadMode = "full"
+
+	// SlimPayloadMode removes the bookkeeping fields of the change stream from the change events, i.e. their resume
+	// token, namespace, cluster time, wall time and document key, which are published in headers instead.
+	SlimPayloadMode PayloadMode = "slim"
+)
+
+var PayloadModes = []PayloadMode{
+	FullPayloadMode,
+	SlimPayloadMode,
+}
+
+// bookkeepingFields are the fields of the change events removed by SlimPayloadMode.
+var bookkeepingFields = []string{"_id", "ns", "clusterTime", "wallTime", "documentKey"}
+
+// Bookkeeping holds the bookkeeping fields removed from the payload of a slim change event, to be published in its
+// headers. The document key is published in its headers in any case.
+type Bookkeeping struct {
+	// Namespace is the namespace of the change event, i.e. <db>.<coll>, if any.
+	Namespace   string
+	ClusterTime primitive.Timestamp
+	// WallTime is the server time of the change event, if any.
+	WallTime    time.Time
+	ResumeToken string
+}
+
+// bookkeepingOf returns the bookkeeping fields of the given change event, with the given resume token, if the given
+// options publish slim payloads, or nil otherwise.
+func bookkeepingOf(opts *WatchCollectionOptions, changeEvent bson.Raw, resumeToken string) *Bookkeeping {
+	if opts.PayloadMode != SlimPayloadMode {
+		return nil
+	}
+	b := &Bookkeeping{ResumeToken: resumeToken}
+	db, dbOk := changeEvent.Lookup("ns", "db").StringValueOK()
+	coll, collOk := changeEvent.Lookup("ns", "coll").StringValueOK()
+	switch {
+	case dbOk && collOk:
+		b.Namespace = db + "." + coll
+	case dbOk:
+		b.Namespace = db
+	}
+	b.ClusterTime.T, b.ClusterTime.I, _ = changeEvent.Lookup("clusterTime").TimestampOK()
+	if wallTime, ok := changeEvent.Lookup("wallTime").TimeOK(); ok {
+		b.WallTime = wallTime.UTC()
+	}
+	return b
+}
+
+// slim returns a copy of the given change event without its bookkeeping fields.
+func slim(changeEvent bson.Raw) (bson.Raw, error) {
+	elems, err := changeEvent.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(bson.D, 0, len(elems))
+	for _, elem := range elems {
+		if !slices.Contains(bookkeepingFields, elem.Key()) {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: elem.Value()})
+		}
+	}
+	return bson.Marshal(doc)
+}
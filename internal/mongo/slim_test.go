@@ -0,0 +1,44 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_slim(t *testing.T) {
+	wallTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: "8264"}}},
+		{Key: "operationType", Value: "insert"},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: 1714564800, I: 3}},
+		{Key: "wallTime", Value: primitive.NewDateTimeFromTime(wallTime)},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "shop"}, {Key: "coll", Value: "orders"}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "order-1"}}},
+	})
+	require.NoError(t, err)
+
+	t.Run("should remove the bookkeeping fields from slim payloads", func(t *testing.T) {
+		opts := &WatchCollectionOptions{PayloadMode: SlimPayloadMode}
+
+		data, err := encodeChangeEvent(changeEvent, opts)
+
+		require.NoError(t, err)
+		require.Equal(t, `{"operationType":"insert","fullDocument":{"_id":"order-1"}}`, string(data))
+		require.Equal(t, &Bookkeeping{Namespace: "shop.orders", ClusterTime: primitive.Timestamp{T: 1714564800, I: 3},
+			WallTime: wallTime, ResumeToken: "8264"}, bookkeepingOf(opts, changeEvent, "8264"))
+	})
+	t.Run("should keep the bookkeeping fields of full payloads", func(t *testing.T) {
+		opts := &WatchCollectionOptions{PayloadMode: FullPayloadMode}
+
+		data, err := encodeChangeEvent(changeEvent, opts)
+
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"documentKey":{"_id":"order-1"}`)
+		require.Nil(t, bookkeepingOf(opts, changeEvent, "8264"))
+	})
+}
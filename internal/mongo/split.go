@@ -0,0 +1,56 @@
+package mongo
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// splitLargeEventStage splits the change events exceeding the maximum size of BSON documents into fragments, instead
+// of failing the change stream. It must be the last stage of the pipeline.
+var splitLargeEventStage = bson.D{{Key: "$changeStreamSplitLargeEvent", Value: bson.D{}}}
+
+// fragmentOf returns the number of the given fragment of a split change event, and the total number of fragments, or
+// false if the change event is not split.
+func fragmentOf(changeEvent bson.Raw) (fragment, of int32, ok bool) {
+	split, err := changeEvent.LookupErr("splitEvent")
+	if err != nil {
+		return 0, 0, false
+	}
+	fragment, ok1 := split.Document().Lookup("fragment").Int32OK()
+	of, ok2 := split.Document().Lookup("of").Int32OK()
+	return fragment, of, ok1 && ok2
+}
+
+// reassemble returns the change event split into the given fragments, in order, whose resume token is the one of the
+// last fragment, so that the change stream resumes after all of them.
+func reassemble(fragments []bson.Raw) (bson.Raw, error) {
+	var changeEvent bson.D
+	for _, fragment := range fragments {
+		elems, err := fragment.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("could not read fragment of split change event: %v", err)
+		}
+		for _, elem := range elems {
+			switch elem.Key() {
+			case "splitEvent":
+			case "_id":
+				changeEvent = setElem(changeEvent, elem.Key(), elem.Value())
+			default:
+				changeEvent = append(changeEvent, bson.E{Key: elem.Key(), Value: elem.Value()})
+			}
+		}
+	}
+	return bson.Marshal(changeEvent)
+}
+
+// setElem sets the value of the given key, in place if the key is already set.
+func setElem(d bson.D, key string, value bson.RawValue) bson.D {
+	for i := range d {
+		if d[i].Key == key {
+			d[i].Value = value
+			return d
+		}
+	}
+	return append(d, bson.E{Key: key, Value: value})
+}
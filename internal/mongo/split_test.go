@@ -0,0 +1,46 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_fragmentOf(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.M{"splitEvent": bson.M{"fragment": int32(2), "of": int32(3)}})
+	fragment, of, ok := fragmentOf(changeEvent)
+	require.True(t, ok)
+	require.Equal(t, int32(2), fragment)
+	require.Equal(t, int32(3), of)
+
+	changeEvent, _ = bson.Marshal(bson.M{"operationType": "update"})
+	_, _, ok = fragmentOf(changeEvent)
+	require.False(t, ok)
+}
+
+func Test_reassemble(t *testing.T) {
+	first, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.M{"_data": "token-1"}},
+		{Key: "operationType", Value: "update"},
+		{Key: "fullDocumentBeforeChange", Value: bson.M{"status": "pending"}},
+		{Key: "splitEvent", Value: bson.M{"fragment": int32(1), "of": int32(2)}},
+	})
+	last, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.M{"_data": "token-2"}},
+		{Key: "fullDocument", Value: bson.M{"status": "paid"}},
+		{Key: "splitEvent", Value: bson.M{"fragment": int32(2), "of": int32(2)}},
+	})
+
+	changeEvent, err := reassemble([]bson.Raw{first, last})
+	require.NoError(t, err)
+
+	var got bson.D
+	require.NoError(t, bson.Unmarshal(changeEvent, &got))
+	require.Equal(t, bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: "token-2"}}},
+		{Key: "operationType", Value: "update"},
+		{Key: "fullDocumentBeforeChange", Value: bson.D{{Key: "status", Value: "pending"}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "status", Value: "paid"}}},
+	}, got)
+}
@@ -0,0 +1,148 @@
+package mongo
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TimeBucket represents the granularity of the time bucket appended to the subjects of change events.
+type TimeBucket string
+
+const (
+	// YearTimeBucket appends the year of the change event, e.g. 2024.
+	YearTimeBucket TimeBucket = "year"
+
+	// MonthTimeBucket appends the year and month of the change event, e.g. 2024-06.
+	MonthTimeBucket TimeBucket = "month"
+
+	// DayTimeBucket appends the date of the change event, e.g. 2024-06-30.
+	DayTimeBucket TimeBucket = "day"
+)
+
+var TimeBuckets = []TimeBucket{
+	YearTimeBucket,
+	MonthTimeBucket,
+	DayTimeBucket,
+}
+
+var timeBucketLayouts = map[TimeBucket]string{
+	YearTimeBucket:  "2006",
+	MonthTimeBucket: "2006-01",
+	DayTimeBucket:   "2006-01-02",
+}
+
+// subjectTokenReplacer replaces the characters that are not allowed within a single nats subject token.
+var subjectTokenReplacer = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_")
+
+// subject returns the nats subject of a change event, i.e. <stream>[.<db>.<coll>].<op>[.<partition>][.<bucket>], where
+// <op> is the event type of its operation type, or <stream>.<subject template> if the subject template is set, prefixed
+// with v<schema version> if it is set.
+func subject(opts *WatchCollectionOptions, operationType string, changeEvent bson.Raw) (string, error) {
+	subj, err := unversionedSubject(opts, operationType, changeEvent)
+	if err != nil {
+		return "", err
+	}
+	return versioned(opts.SchemaVersion.Current(), subj), nil
+}
+
+func unversionedSubject(opts *WatchCollectionOptions, operationType string, changeEvent bson.Raw) (string, error) {
+	if opts.SubjectTemplate != nil {
+		suffix, err := opts.SubjectTemplate.execute(newTemplateData(opts, operationType, changeEvent))
+		if err != nil {
+			return "", fmt.Errorf("could not execute subject template: %v", err)
+		}
+		subj := opts.StreamName + "." + suffix
+		return subj, ValidSubject(subj)
+	}
+	tokens := []string{opts.StreamName}
+	if opts.NamespaceSubjects {
+		tokens = append(tokens, subjectTokenReplacer.Replace(opts.WatchedDbName),
+			subjectTokenReplacer.Replace(opts.WatchedCollName))
+	}
+	tokens = append(tokens, opts.EventTypes.of(operationType))
+	if opts.Partitions > 0 {
+		tokens = append(tokens, strconv.Itoa(partition(changeEvent, opts.Partitions)))
+	}
+	if layout, ok := timeBucketLayouts[opts.TimeBucket]; ok {
+		tokens = append(tokens, eventTime(changeEvent).Format(layout))
+	}
+	return strings.Join(tokens, "."), nil
+}
+
+// SubjectFilter returns the nats subject filter matching the subjects of all the change events of the watched
+// collection, with its current schema version.
+func SubjectFilter(opts *WatchCollectionOptions) string {
+	return VersionSubjectFilter(opts, opts.SchemaVersion.Current())
+}
+
+// VersionSubjectFilter returns the nats subject filter matching the subjects of all the change events of the watched
+// collection, with the given schema version.
+func VersionSubjectFilter(opts *WatchCollectionOptions, version int) string {
+	if opts.SubjectTemplate != nil || opts.EventTypes.multiToken() {
+		return versioned(version, opts.StreamName+".>")
+	}
+	tokens := []string{opts.StreamName}
+	if opts.NamespaceSubjects {
+		tokens = append(tokens, "*", "*")
+	}
+	tokens = append(tokens, "*")
+	if opts.Partitions > 0 {
+		tokens = append(tokens, "*")
+	}
+	if _, ok := timeBucketLayouts[opts.TimeBucket]; ok {
+		tokens = append(tokens, "*")
+	}
+	return versioned(version, strings.Join(tokens, "."))
+}
+
+// SubjectPatterns returns the nats subject patterns matching the subjects of the change events of the watched
+// collection with its current schema version, one per operation type, in order, where the tokens computed from each
+// change event are wildcards, e.g. ORDERS.insert.*, or the stream name followed by > if the subject template is set.
+func SubjectPatterns(opts *WatchCollectionOptions) []string {
+	if opts.SubjectTemplate != nil {
+		return []string{versioned(opts.SchemaVersion.Current(), opts.StreamName+".>")}
+	}
+	patterns := make([]string, 0, len(mappedOperationTypes)+1)
+	for _, operationType := range append(slices.Clone(mappedOperationTypes), renameOperationType) {
+		tokens := []string{opts.StreamName}
+		if opts.NamespaceSubjects {
+			tokens = append(tokens, subjectTokenReplacer.Replace(opts.WatchedDbName),
+				subjectTokenReplacer.Replace(opts.WatchedCollName))
+		}
+		tokens = append(tokens, opts.EventTypes.of(operationType))
+		if opts.Partitions > 0 {
+			tokens = append(tokens, "*")
+		}
+		if _, ok := timeBucketLayouts[opts.TimeBucket]; ok {
+			tokens = append(tokens, "*")
+		}
+		pattern := versioned(opts.SchemaVersion.Current(), strings.Join(tokens, "."))
+		if !slices.Contains(patterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// partition computes the partition of the given change event from the hash of its document key, so that all the
+// change events of the same document are published to the same partition.
+func partition(changeEvent bson.Raw, partitions int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write(changeEvent.Lookup("documentKey").Value)
+	return int(hash.Sum32() % uint32(partitions))
+}
+
+// eventTime returns the UTC time of the given change event, taken from its cluster time, or the current time if the
+// cluster time is not available.
+func eventTime(changeEvent bson.Raw) time.Time {
+	if seconds, _, ok := changeEvent.Lookup("clusterTime").TimestampOK(); ok {
+		return time.Unix(int64(seconds), 0).UTC()
+	}
+	return time.Now().UTC()
+}
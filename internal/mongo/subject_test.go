@@ -0,0 +1,212 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_subject(t *testing.T) {
+	clusterTime := primitive.Timestamp{T: uint32(time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC).Unix())}
+	changeEvent, _ := bson.Marshal(bson.M{"operationType": "insert", "documentKey": bson.M{"_id": "order-1"},
+		"clusterTime": clusterTime})
+
+	tests := []struct {
+		name string
+		opts *WatchCollectionOptions
+		want string
+	}{
+		{
+			name: "should use stream name and operation type by default",
+			opts: &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "orders", StreamName: "ORDERS"},
+			want: "ORDERS.insert",
+		},
+		{
+			name: "should add namespace tokens if namespaced",
+			opts: &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "order_items", StreamName: "ORDERS",
+				NamespaceSubjects: true},
+			want: "ORDERS.shop.order_items.insert",
+		},
+		{
+			name: "should replace characters not allowed in subject tokens",
+			opts: &WatchCollectionOptions{WatchedDbName: "shop eu", WatchedCollName: "orders.v2*>", StreamName: "ORDERS",
+				NamespaceSubjects: true},
+			want: "ORDERS.shop_eu.orders_v2__.insert",
+		},
+		{
+			name: "should add partition token if partitioned",
+			opts: &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "orders", StreamName: "ORDERS",
+				NamespaceSubjects: true, Partitions: 8},
+			want: "ORDERS.shop.orders.insert.3",
+		},
+		{
+			name: "should add time bucket token if bucketed",
+			opts: &WatchCollectionOptions{StreamName: "AUDIT", TimeBucket: MonthTimeBucket},
+			want: "AUDIT.insert.2024-06",
+		},
+		{
+			name: "should add time bucket token after partition token",
+			opts: &WatchCollectionOptions{StreamName: "AUDIT", Partitions: 8, TimeBucket: DayTimeBucket},
+			want: "AUDIT.insert.3.2024-06-30",
+		},
+		{
+			name: "should append the subject template to the stream name",
+			opts: &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "Orders", StreamName: "ORDERS",
+				SubjectTemplate: mustTemplate(t,
+					`{{ .Collection | lower }}.{{ .OperationType }}.{{ timeFormat "2006" .ClusterTime }}`)},
+			want: "ORDERS.orders.insert.2024",
+		},
+		{
+			name: "should prefix the subject with the schema version",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", SchemaVersion: NewSchemaVersion(2)},
+			want: "v2.ORDERS.insert",
+		},
+		{
+			name: "should use the event type of the operation type",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", Partitions: 8,
+				EventTypes: EventTypes{"insert": "order.created"}},
+			want: "ORDERS.order.created.3",
+		},
+		{
+			name: "should execute the subject template with the event type",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", EventTypes: EventTypes{"insert": "created"},
+				SubjectTemplate: mustTemplate(t, `{{ .EventType }}.{{ .OperationType }}`)},
+			want: "ORDERS.created.insert",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subj, err := subject(tt.opts, "insert", changeEvent)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, subj)
+		})
+	}
+
+	t.Run("should return error if the subject template renders an invalid subject", func(t *testing.T) {
+		opts := &WatchCollectionOptions{StreamName: "ORDERS", SubjectTemplate: mustTemplate(t, `{{ .DocumentId }}.`)}
+
+		_, err := subject(opts, "insert", changeEvent)
+
+		require.ErrorIs(t, err, ErrInvalidSubject)
+	})
+}
+
+func TestSubjectFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *WatchCollectionOptions
+		want string
+	}{
+		{
+			name: "should match operation types by default",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS"},
+			want: "ORDERS.*",
+		},
+		{
+			name: "should match namespace and partition tokens",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", NamespaceSubjects: true, Partitions: 8,
+				TimeBucket: YearTimeBucket},
+			want: "ORDERS.*.*.*.*.*",
+		},
+		{
+			name: "should match all subjects of the stream if templated",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", SubjectTemplate: mustTemplate(t, `{{ .Collection }}`)},
+			want: "ORDERS.>",
+		},
+		{
+			name: "should match operation types mapped to single token event types",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", EventTypes: EventTypes{"delete": "removed"}},
+			want: "ORDERS.*",
+		},
+		{
+			name: "should match all subjects of the stream if event types have several tokens",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", Partitions: 8,
+				EventTypes: EventTypes{"delete": "order.removed"}},
+			want: "ORDERS.>",
+		},
+		{
+			name: "should match the subjects of the schema version",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", SchemaVersion: NewSchemaVersion(1),
+				SubjectTemplate: mustTemplate(t, `{{ .Collection }}`)},
+			want: "v1.ORDERS.>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, SubjectFilter(tt.opts))
+		})
+	}
+
+	t.Run("should match the subjects of another schema version", func(t *testing.T) {
+		opts := &WatchCollectionOptions{StreamName: "ORDERS", SchemaVersion: NewSchemaVersion(1)}
+
+		require.Equal(t, "v2.ORDERS.*", VersionSubjectFilter(opts, 2))
+	})
+}
+
+func TestSubjectPatterns(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *WatchCollectionOptions
+		want []string
+	}{
+		{
+			name: "should match the subjects of each operation type",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS"},
+			want: []string{"ORDERS.insert", "ORDERS.update", "ORDERS.replace", "ORDERS.delete", "ORDERS.rename"},
+		},
+		{
+			name: "should match namespace, partition and time bucket tokens with their schema version",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", WatchedDbName: "shop", WatchedCollName: "orders",
+				NamespaceSubjects: true, Partitions: 8, TimeBucket: DayTimeBucket, SchemaVersion: NewSchemaVersion(2),
+				EventTypes: EventTypes{"insert": "order.created", "update": "changed", "replace": "changed"}},
+			want: []string{"v2.ORDERS.shop.orders.order.created.*.*", "v2.ORDERS.shop.orders.changed.*.*",
+				"v2.ORDERS.shop.orders.delete.*.*", "v2.ORDERS.shop.orders.rename.*.*"},
+		},
+		{
+			name: "should match all subjects of the stream if templated",
+			opts: &WatchCollectionOptions{StreamName: "ORDERS", SubjectTemplate: mustTemplate(t, `{{ .Collection }}`)},
+			want: []string{"ORDERS.>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, SubjectPatterns(tt.opts))
+		})
+	}
+}
+
+func Test_partition(t *testing.T) {
+	t.Run("should compute the same partition for the same document key", func(t *testing.T) {
+		insert, _ := bson.Marshal(bson.M{"operationType": "insert", "documentKey": bson.M{"_id": "order-1"}})
+		update, _ := bson.Marshal(bson.M{"operationType": "update", "documentKey": bson.M{"_id": "order-1"}})
+
+		require.Equal(t, partition(insert, 8), partition(update, 8))
+	})
+	t.Run("should compute partitions within the partition count", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			changeEvent, _ := bson.Marshal(bson.M{"documentKey": bson.M{"_id": i}})
+
+			p := partition(changeEvent, 3)
+			require.GreaterOrEqual(t, p, 0)
+			require.Less(t, p, 3)
+		}
+	})
+}
+
+func Test_eventTime(t *testing.T) {
+	t.Run("should use the cluster time of the change event", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.M{"clusterTime": primitive.Timestamp{T: 1719788400, I: 1}})
+
+		require.Equal(t, time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC), eventTime(changeEvent))
+	})
+	t.Run("should fall back to the current time if the cluster time is missing", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.M{})
+
+		require.WithinDuration(t, time.Now(), eventTime(changeEvent), time.Second)
+	})
+}
@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+var ErrInvalidSubject = errors.New("invalid subject: subject tokens must not be empty, nor contain whitespaces or wildcards")
+
+// templateFuncs are the functions available in the subject and header templates.
+var templateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"sha256": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"base64":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"timeFormat": func(layout string, t time.Time) string { return t.UTC().Format(layout) },
+}
+
+// sampleTemplateData is the data templates are executed with once parsed, to detect unknown fields, or functions
+// called with arguments of the wrong type, before watching.
+var sampleTemplateData = &templateData{
+	Database:      "db",
+	Collection:    "coll",
+	OperationType: "insert",
+	EventType:     "insert",
+	ClusterTime:   time.Unix(0, 0).UTC(),
+	DocumentId:    "id",
+}
+
+// Template is a subject or header template, executed with the fields of each change event, e.g.
+// {{ .Collection | lower }}.{{ timeFormat "2006-01" .ClusterTime }}.
+type Template struct {
+	text string
+	tmpl *template.Template
+}
+
+// templateData is the data subject and header templates are executed with.
+type templateData struct {
+	Database      string
+	Collection    string
+	OperationType string
+	// EventType is the event type the operation type is mapped to, or the operation type if it is not mapped.
+	EventType string
+	// ClusterTime is the UTC cluster time of the change event.
+	ClusterTime time.Time
+	// DocumentId is the _id of the document of the change event, if any, i.e. hex-encoded for object ids, and in
+	// canonical extended json for other types than strings.
+	DocumentId string
+}
+
+// NewTemplate parses the given template, and checks that it can be executed.
+func NewTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err = tmpl.Execute(io.Discard, sampleTemplateData); err != nil {
+		return nil, err
+	}
+	return &Template{text: text, tmpl: tmpl}, nil
+}
+
+// String returns the text of the template.
+func (t *Template) String() string {
+	return t.text
+}
+
+func (t *Template) execute(data *templateData) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func newTemplateData(opts *WatchCollectionOptions, operationType string, changeEvent bson.Raw) *templateData {
+	return &templateData{
+		Database:      opts.WatchedDbName,
+		Collection:    opts.WatchedCollName,
+		OperationType: operationType,
+		EventType:     opts.EventTypes.of(operationType),
+		ClusterTime:   eventTime(changeEvent),
+		DocumentId:    documentId(changeEvent),
+	}
+}
+
+// documentId returns the _id of the document of the given change event as a string, or an empty string if it has no
+// document key, e.g. for rename change events.
+func documentId(changeEvent bson.Raw) string {
+	id, err := changeEvent.LookupErr("documentKey", "_id")
+	if err != nil {
+		return ""
+	}
+	switch id.Type {
+	case bsontype.ObjectID:
+		return id.ObjectID().Hex()
+	case bsontype.String:
+		return id.StringValue()
+	default:
+		return id.String()
+	}
+}
+
+// documentKey returns the document key of the given change event in relaxed extended JSON, e.g. {"_id":"order-1"}, or
+// an empty string if it has none.
+func documentKey(changeEvent bson.Raw) string {
+	key, ok := changeEvent.Lookup("documentKey").DocumentOK()
+	if !ok {
+		return ""
+	}
+	data, err := bson.MarshalExtJSON(key, false, false)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// headers returns the headers of the given change event, by executing the header templates.
+func headers(opts *WatchCollectionOptions, operationType string, changeEvent bson.Raw) (map[string]string, error) {
+	if len(opts.HeaderTemplates) == 0 {
+		return nil, nil
+	}
+	data := newTemplateData(opts, operationType, changeEvent)
+	headers := make(map[string]string, len(opts.HeaderTemplates))
+	for name, tmpl := range opts.HeaderTemplates {
+		value, err := tmpl.execute(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not execute template of header %v: %v", name, err)
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
+
+// ValidSubject returns an error if the given subject is not a valid nats subject to publish to.
+func ValidSubject(subj string) error {
+	for _, token := range strings.Split(subj, ".") {
+		if token == "" || strings.ContainsAny(token, " \t\r\n*>") {
+			return fmt.Errorf("%w: %q", ErrInvalidSubject, subj)
+		}
+	}
+	return nil
+}
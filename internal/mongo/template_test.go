@@ -0,0 +1,180 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func mustTemplate(t *testing.T, text string) *Template {
+	t.Helper()
+	tmpl, err := NewTemplate(text)
+	require.NoError(t, err)
+	return tmpl
+}
+
+func TestNewTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{
+			name:    "should return error if the template cannot be parsed",
+			text:    `{{ .Collection `,
+			wantErr: "unclosed action",
+		},
+		{
+			name:    "should return error if the template calls an unknown function",
+			text:    `{{ .Collection | title }}`,
+			wantErr: `function "title" not defined`,
+		},
+		{
+			name:    "should return error if the template uses an unknown field",
+			text:    `{{ .Namespace }}`,
+			wantErr: "can't evaluate field Namespace",
+		},
+		{
+			name:    "should return error if a function is called with arguments of the wrong type",
+			text:    `{{ timeFormat "2006" .Collection }}`,
+			wantErr: "wrong type for value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := NewTemplate(tt.text)
+
+			require.Nil(t, tmpl)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestTemplate_execute(t *testing.T) {
+	data := &templateData{
+		Database:      "shop",
+		Collection:    "Order_Items",
+		OperationType: "insert",
+		ClusterTime:   time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC),
+		DocumentId:    "order-1",
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "should lower and upper case",
+			text: `{{ .Collection | lower }} {{ upper .Database }}`,
+			want: "order_items SHOP",
+		},
+		{
+			name: "should replace",
+			text: `{{ .Collection | replace "_" "-" }}`,
+			want: "Order-Items",
+		},
+		{
+			name: "should trim prefix",
+			text: `{{ .Collection | trimPrefix "Order_" }}`,
+			want: "Items",
+		},
+		{
+			name: "should hash with sha256",
+			text: `{{ sha256 .DocumentId }}`,
+			want: "0bafe22156d2698c143b86040446d366ead863ba600d5c924f3d15c786ef4057",
+		},
+		{
+			name: "should encode with base64",
+			text: `{{ base64 .DocumentId }}`,
+			want: "b3JkZXItMQ==",
+		},
+		{
+			name: "should format the cluster time",
+			text: `{{ .ClusterTime | timeFormat "2006-01-02" }}`,
+			want: "2024-06-30",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mustTemplate(t, tt.text).execute(data)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_documentId(t *testing.T) {
+	oid := primitive.NewObjectID()
+	tests := []struct {
+		name        string
+		documentKey any
+		want        string
+	}{
+		{
+			name:        "should use the hex of object ids",
+			documentKey: bson.M{"_id": oid},
+			want:        oid.Hex(),
+		},
+		{
+			name:        "should use strings as is",
+			documentKey: bson.M{"_id": "order-1"},
+			want:        "order-1",
+		},
+		{
+			name:        "should use the extended json of other types",
+			documentKey: bson.M{"_id": 42},
+			want:        `{"$numberInt":"42"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changeEvent, _ := bson.Marshal(bson.D{{Key: "documentKey", Value: tt.documentKey}})
+
+			require.Equal(t, tt.want, documentId(changeEvent))
+		})
+	}
+
+	t.Run("should be empty without document key", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.M{"operationType": "rename"})
+
+		require.Empty(t, documentId(changeEvent))
+	})
+}
+
+func Test_documentKey(t *testing.T) {
+	t.Run("should use the relaxed extended json of the document key", func(t *testing.T) {
+		oid, _ := primitive.ObjectIDFromHex("664f1c2e9b1e8a3d4c5b6a79")
+		changeEvent, _ := bson.Marshal(bson.D{{Key: "documentKey", Value: bson.D{{Key: "region", Value: "eu"},
+			{Key: "_id", Value: oid}}}})
+
+		require.Equal(t, `{"region":"eu","_id":{"$oid":"664f1c2e9b1e8a3d4c5b6a79"}}`, documentKey(changeEvent))
+	})
+	t.Run("should be empty without document key", func(t *testing.T) {
+		changeEvent, _ := bson.Marshal(bson.M{"operationType": "drop"})
+
+		require.Empty(t, documentKey(changeEvent))
+	})
+}
+
+func Test_headers(t *testing.T) {
+	changeEvent, _ := bson.Marshal(bson.M{"documentKey": bson.M{"_id": "order-1"},
+		"clusterTime": primitive.Timestamp{T: 1719788400}})
+	opts := &WatchCollectionOptions{
+		WatchedDbName:   "shop",
+		WatchedCollName: "orders",
+		HeaderTemplates: map[string]*Template{
+			"Document-Id": mustTemplate(t, `{{ .DocumentId }}`),
+			"Namespace":   mustTemplate(t, `{{ .Database }}.{{ .Collection }}`),
+		},
+	}
+
+	got, err := headers(opts, "insert", changeEvent)
+
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"Document-Id": "order-1", "Namespace": "shop.orders"}, got)
+}
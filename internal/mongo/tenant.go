@@ -0,0 +1,23 @@
+package mongo
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// tenant returns the tenant of the given change event, taken from the given field of the full document, or of the
+// document before the change if the full document is not available, e.g. for deletions with pre-images enabled.
+// It returns an empty string if no field is given, or if the field is missing.
+func tenant(changeEvent bson.Raw, field string) string {
+	if field == "" {
+		return ""
+	}
+	path := strings.Split(field, ".")
+	for _, doc := range []string{"fullDocument", "fullDocumentBeforeChange"} {
+		if value, ok := lookupString(changeEvent, append([]string{doc}, path...)...); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_tenant(t *testing.T) {
+	tests := []struct {
+		name        string
+		changeEvent bson.M
+		field       string
+		want        string
+	}{
+		{
+			name:        "should return no tenant if no field is given",
+			changeEvent: bson.M{"fullDocument": bson.M{"tenant": "acme"}},
+			want:        "",
+		},
+		{
+			name:        "should use the field of the full document",
+			changeEvent: bson.M{"fullDocument": bson.M{"org": bson.M{"tenant": "acme"}}},
+			field:       "org.tenant",
+			want:        "acme",
+		},
+		{
+			name:        "should fall back to the field of the document before the change",
+			changeEvent: bson.M{"fullDocumentBeforeChange": bson.M{"tenant": "acme"}},
+			field:       "tenant",
+			want:        "acme",
+		},
+		{
+			name:        "should return no tenant if the field is missing",
+			changeEvent: bson.M{"documentKey": bson.M{"_id": 1}},
+			field:       "tenant",
+			want:        "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changeEvent, err := bson.Marshal(tt.changeEvent)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.want, tenant(changeEvent, tt.field))
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package mongo
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const oplogWindowCheckInterval = 1 * time.Minute
+
+// keyStringTimestampType is the type byte of the cluster time, encoded as the first field of the resume token data.
+const keyStringTimestampType = 0x82
+
+// tokenTime decodes the cluster time of the change event the given resume token refers to.
+// It returns false if the token is not in the expected format.
+func tokenTime(token string) (time.Time, bool) {
+	ts, ok := tokenTimestamp(token)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(ts.T), 0).UTC(), true
+}
+
+// tokenTimestamp decodes the cluster time of the change event the given resume token refers to, along with its
+// increment. It returns false if the token is not in the expected format.
+func tokenTimestamp(token string) (primitive.Timestamp, bool) {
+	data, err := hex.DecodeString(token)
+	if err != nil || len(data) < 9 || data[0] != keyStringTimestampType {
+		return primitive.Timestamp{}, false
+	}
+	return primitive.Timestamp{T: binary.BigEndian.Uint32(data[1:5]), I: binary.BigEndian.Uint32(data[5:9])}, true
+}
+
+// previous returns the cluster time right before the given one.
+func previous(ts primitive.Timestamp) primitive.Timestamp {
+	if ts.I > 0 {
+		return primitive.Timestamp{T: ts.T, I: ts.I - 1}
+	}
+	return primitive.Timestamp{T: ts.T - 1, I: math.MaxUint32}
+}
+
+// ResumeTokenAfter returns true if the first resume token refers to a change event with a later cluster time than the
+// second one. It returns false if either of them is not in the expected format.
+func ResumeTokenAfter(token, other string) bool {
+	ts, ok := tokenTimestamp(token)
+	if !ok {
+		return false
+	}
+	otherTs, ok := tokenTimestamp(other)
+	return ok && otherTs.Before(ts)
+}
+
+// checkOplogWindow reports the time of the oldest oplog entry, at most once per check interval, so that resume tokens
+// can be compared against it before they fall off the oplog and become unresumable.
+func (c *DefaultClient) checkOplogWindow(ctx context.Context) {
+	if c.onOplogWindowEvent == nil {
+		return
+	}
+	c.oplogMu.Lock()
+	if time.Since(c.oplogCheckedAt) < oplogWindowCheckInterval {
+		c.oplogMu.Unlock()
+		return
+	}
+	c.oplogCheckedAt = time.Now()
+	c.oplogMu.Unlock()
+
+	oldest, err := c.oldestOplogTime(ctx)
+	if err != nil {
+		// e.g. the user is not allowed to read the oplog
+		c.logger.Debug("could not fetch oldest oplog entry", "err", err)
+		return
+	}
+	c.onOplogWindowEvent(oldest)
+}
+
+// oldestOplogTime returns the time of the oldest oplog entry, i.e. the start of the oplog window.
+func (c *DefaultClient) oldestOplogTime(ctx context.Context) (time.Time, error) {
+	oldest := struct {
+		Ts primitive.Timestamp `bson:"ts"`
+	}{}
+	findOneOpts := options.FindOne().
+		SetSort(bson.D{{Key: "$natural", Value: 1}}).
+		SetProjection(bson.D{{Key: "ts", Value: 1}})
+	err := c.mongoClient().Database("local").Collection("oplog.rs").FindOne(ctx, bson.D{}, findOneOpts).Decode(&oldest)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(oldest.Ts.T), 0).UTC(), nil
+}
+
+// ResumeTokensOptions identifies the collection where the resume tokens of a watched collection are stored.
+type ResumeTokensOptions struct {
+	DbName   string
+	CollName string
+	Capped   bool
+}
+
+// LastResumeToken returns the last stored resume token of the given resume tokens collection, or an empty string if
+// none is stored.
+func (c *DefaultClient) LastResumeToken(ctx context.Context, opts *ResumeTokensOptions) (string, error) {
+	coll := c.mongoClient().Database(opts.DbName).Collection(opts.CollName)
+	token, err := findLastResumeToken(ctx, coll, opts.Capped)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch or decode resume token: %v", err)
+	}
+	return token, nil
+}
+
+// RestoreResumeToken stores the given resume token as the last one of the given resume tokens collection, e.g. from a
+// backup, so that the change stream of the watched collection is resumed after it.
+func (c *DefaultClient) RestoreResumeToken(ctx context.Context, opts *ResumeTokensOptions, token string) error {
+	coll := c.mongoClient().Database(opts.DbName).Collection(opts.CollName)
+	if _, err := coll.InsertOne(ctx, &resumeToken{Value: token}); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("could not insert resume token: %v", err)
+	}
+	return nil
+}
+
+// findLastResumeToken returns the last resume token stored in the given collection, or an empty string if none is.
+func findLastResumeToken(ctx context.Context, coll *mongo.Collection, capped bool) (string, error) {
+	token, err := findLastResumeTokenDoc(ctx, coll, capped)
+	if err != nil {
+		return "", err
+	}
+	return token.Value, nil
+}
+
+// findLastResumeTokenDoc returns the last resume token document stored in the given collection, which is empty if none
+// is.
+func findLastResumeTokenDoc(ctx context.Context, coll *mongo.Collection, capped bool) (*resumeToken, error) {
+	findOneOpts := options.FindOne()
+	if capped {
+		// use natural sort for capped collections to get the last inserted resume token
+		findOneOpts.SetSort(bson.D{{Key: "$natural", Value: -1}})
+	} else {
+		// cannot rely on natural sort for uncapped collections, sort by id instead
+		findOneOpts.SetSort(bson.D{{Key: "_id", Value: -1}})
+	}
+	token := &resumeToken{}
+	err := coll.FindOne(ctx, bson.D{}, findOneOpts).Decode(token)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_tokenTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		want   time.Time
+		wantOk bool
+	}{
+		{
+			name:   "should decode the cluster time of the resume token",
+			token:  "82645A43BA000000012B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004",
+			want:   time.Unix(1683637178, 0).UTC(),
+			wantOk: true,
+		},
+		{
+			name:  "should not decode a resume token that is not hex encoded",
+			token: "not-a-token",
+		},
+		{
+			name:  "should not decode a resume token that does not start with a cluster time",
+			token: "01645A43BA00000001",
+		},
+		{
+			name:  "should not decode a resume token that is too short",
+			token: "82645A",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tokenTime(tt.token)
+
+			require.Equal(t, tt.wantOk, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_tokenTimestamp(t *testing.T) {
+	ts, ok := tokenTimestamp("82645A43BA000000012B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004")
+
+	require.True(t, ok)
+	require.Equal(t, primitive.Timestamp{T: 1683637178, I: 1}, ts)
+}
+
+func TestResumeTokenAfter(t *testing.T) {
+	const (
+		token = "82645A43BA000000012B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004"
+		later = "82645A43BA000000022B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004"
+	)
+
+	require.True(t, ResumeTokenAfter(later, token))
+	require.False(t, ResumeTokenAfter(token, later))
+	require.False(t, ResumeTokenAfter(token, token))
+	require.False(t, ResumeTokenAfter(later, "not-a-token"))
+	require.False(t, ResumeTokenAfter("not-a-token", token))
+}
+
+func Test_previous(t *testing.T) {
+	require.Equal(t, primitive.Timestamp{T: 1683637178, I: 1}, previous(primitive.Timestamp{T: 1683637178, I: 2}))
+	require.Equal(t, primitive.Timestamp{T: 1683637177, I: math.MaxUint32},
+		previous(primitive.Timestamp{T: 1683637178, I: 0}))
+}
@@ -0,0 +1,177 @@
+package mongo
+
+import (
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TransactionMode represents how the change events of multi-document transactions are published.
+type TransactionMode string
+
+const (
+	// TagTransactionMode publishes the change events of a transaction one by one, each tagged with the id of the
+	// transaction, its index within the transaction, and the number of change events of the transaction.
+	TagTransactionMode TransactionMode = "tag"
+
+	// BatchTransactionMode publishes all the change events of a transaction as a single message, so that consumers can
+	// apply them atomically.
+	BatchTransactionMode TransactionMode = "batch"
+)
+
+var TransactionModes = []TransactionMode{
+	TagTransactionMode,
+	BatchTransactionMode,
+}
+
+// transactionOperationType is the operation type of the messages published in place of the change events of a
+// transaction, if they are batched.
+const transactionOperationType = "transaction"
+
+// Transaction identifies the multi-document transaction a change event belongs to.
+type Transaction struct {
+	// Id is the id of the transaction, made of the id of its logical session and of its transaction number.
+	Id string
+	// Index is the position of the change event within the transaction, starting at 1, or 0 if the change event is
+	// the batch of all the change events of the transaction.
+	Index int
+	// Total is the number of change events of the transaction.
+	Total int
+}
+
+// txn holds the change events of the current transaction, until all of them are received.
+type txn struct {
+	id     string
+	events []*changeEvent
+
+	// raws are the change events as received from the change stream, batched if the transaction mode is batch.
+	raws []bson.Raw
+}
+
+// transactionId returns the id of the transaction the given change event belongs to, i.e. <lsid>:<txnNumber>, or an
+// empty string if it does not belong to a transaction.
+func transactionId(changeEvent bson.Raw) string {
+	s := sessionOf(changeEvent)
+	if s == nil || s.TxnNumber == nil {
+		return ""
+	}
+	return s.Lsid + ":" + strconv.FormatInt(*s.TxnNumber, 10)
+}
+
+// add records the given change event of the transaction, along with the raw change event it is built from.
+func (t *txn) add(event *changeEvent, raw bson.Raw) {
+	t.events = append(t.events, event)
+	t.raws = append(t.raws, raw)
+}
+
+// tag tags the change events of the transaction with their position within it.
+func (t *txn) tag() {
+	for i, event := range t.events {
+		event.Transaction = &Transaction{Id: t.id, Index: i + 1, Total: len(t.events)}
+	}
+}
+
+// batch returns the change events published in place of the ones of the transaction, i.e. the batch holding all of
+// them, unless it is dropped by the oversized policy. The change events that could not be encoded are returned before
+// it, so that they are still published to the dead letter subject.
+func (t *txn) batch(opts *WatchCollectionOptions) ([]*changeEvent, error) {
+	var events []*changeEvent
+	raws := make([]bson.Raw, 0, len(t.raws))
+	encrypted := false
+	for i, event := range t.events {
+		if event.DecodeError != nil {
+			events = append(events, event)
+			continue
+		}
+		raws = append(raws, t.raws[i])
+		encrypted = encrypted || event.Encrypted
+	}
+	if len(raws) == 0 {
+		return events, nil
+	}
+
+	first, last := raws[0], t.events[len(t.events)-1]
+	subj, err := subject(opts, transactionOperationType, first)
+	if err != nil {
+		return nil, err
+	}
+	hdrs, err := headers(opts, transactionOperationType, first)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeTransaction(opts, first, raws, false)
+	if err != nil {
+		return nil, err
+	}
+	oversized := opts.MaxPayload > 0 && int64(len(data)) > opts.MaxPayload
+	if oversized {
+		switch opts.OversizedPolicy {
+		case DropOversizedPolicy:
+			return events, nil
+		case TruncateOversizedPolicy:
+			if data, err = encodeTransaction(opts, first, raws, true); err != nil {
+				return nil, err
+			}
+			oversized = int64(len(data)) > opts.MaxPayload
+		}
+	}
+
+	return append(events, &changeEvent{
+		ChangeEvent: ChangeEvent{
+			Subj:          subj,
+			Headers:       hdrs,
+			Encrypted:     encrypted,
+			SchemaVersion: opts.SchemaVersion.Current(),
+			MsgId:         transactionOperationType + "-" + t.id,
+			Data:          data,
+			OperationType: transactionOperationType,
+			Tenant:        tenant(first, opts.TenantField),
+			Session:       sessionOf(first),
+			Time:          last.Time,
+			Oversized:     oversized,
+			Transaction:   &Transaction{Id: t.id, Total: len(raws)},
+		},
+		token: last.token,
+	}), nil
+}
+
+// encodeTransaction encodes the batch of the given change events of a transaction with the given encoder, holding the
+// session and number of the transaction, taken from its first change event, and its change events, truncated if
+// requested, and reshaped, flattened and converted like the change events of the given options.
+func encodeTransaction(opts *WatchCollectionOptions, first bson.Raw, raws []bson.Raw, truncated bool) ([]byte, error) {
+	changeEvents := make(bson.A, 0, len(raws))
+	for _, raw := range raws {
+		var err error
+		if truncated {
+			if raw, err = truncate(raw); err != nil {
+				return nil, err
+			}
+		}
+		if raw, err = reshape(raw, opts.Reshape); err != nil {
+			return nil, err
+		}
+		if raw, err = flatten(raw, opts.Flatten); err != nil {
+			return nil, err
+		}
+		changeEvents = append(changeEvents, raw)
+	}
+	t, i, _ := first.Lookup("clusterTime").TimestampOK()
+	doc, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: transactionOperationType},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: opts.WatchedDbName}, {Key: "coll", Value: opts.WatchedCollName}}},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: t, I: i}},
+		{Key: "lsid", Value: first.Lookup("lsid")},
+		{Key: "txnNumber", Value: first.Lookup("txnNumber")},
+		{Key: "changeEvents", Value: changeEvents},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Encoder != BsonEncoder {
+		if doc, err = convert(doc, conversionsOf(opts)); err != nil {
+			return nil, err
+		}
+	}
+	return encode(doc, opts.Encoder, opts.JsonFlavor)
+}
@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func txnChangeEvent(t *testing.T, id string) bson.Raw {
+	changeEvent, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: 1714564800, I: 1}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: id}}},
+		{Key: "lsid", Value: bson.D{{Key: "id", Value: primitive.Binary{Subtype: 4, Data: []byte{0xab, 0xcd}}}}},
+		{Key: "txnNumber", Value: int64(7)},
+	})
+	require.NoError(t, err)
+	return changeEvent
+}
+
+func Test_transactionId(t *testing.T) {
+	t.Run("should return the logical session id and the transaction number", func(t *testing.T) {
+		require.Equal(t, "abcd:7", transactionId(txnChangeEvent(t, "order-1")))
+	})
+	t.Run("should return an empty string if the change event does not belong to a transaction", func(t *testing.T) {
+		require.Equal(t, "", transactionId(changeEventAt(t, time.Now())))
+	})
+}
+
+func Test_txn(t *testing.T) {
+	opts := &WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "orders", StreamName: "ORDERS"}
+	newTxn := func() *txn {
+		tx := &txn{id: "abcd:7"}
+		tx.add(&changeEvent{ChangeEvent: ChangeEvent{MsgId: "1"}, token: "token-1"}, txnChangeEvent(t, "order-1"))
+		tx.add(&changeEvent{ChangeEvent: ChangeEvent{MsgId: "2"}, token: "token-2"}, txnChangeEvent(t, "order-2"))
+		return tx
+	}
+
+	t.Run("should tag the change events with their position within the transaction", func(t *testing.T) {
+		tx := newTxn()
+
+		tx.tag()
+
+		require.Equal(t, &Transaction{Id: "abcd:7", Index: 1, Total: 2}, tx.events[0].Transaction)
+		require.Equal(t, &Transaction{Id: "abcd:7", Index: 2, Total: 2}, tx.events[1].Transaction)
+	})
+	t.Run("should batch the change events of the transaction", func(t *testing.T) {
+		events, err := newTxn().batch(opts)
+
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "ORDERS.transaction", events[0].Subj)
+		require.Equal(t, "transaction-abcd:7", events[0].MsgId)
+		require.Equal(t, "token-2", events[0].token)
+		require.Equal(t, &Transaction{Id: "abcd:7", Total: 2}, events[0].Transaction)
+		require.JSONEq(t, `{"operationType":"transaction","ns":{"db":"shop","coll":"orders"},
+			"clusterTime":{"$timestamp":{"t":1714564800,"i":1}},
+			"lsid":{"id":{"$binary":{"base64":"q80=","subType":"04"}}},"txnNumber":7,
+			"changeEvents":[{"operationType":"insert","clusterTime":{"$timestamp":{"t":1714564800,"i":1}},
+				"documentKey":{"_id":"order-1"},"fullDocument":{"_id":"order-1"},
+				"lsid":{"id":{"$binary":{"base64":"q80=","subType":"04"}}},"txnNumber":7},
+			{"operationType":"insert","clusterTime":{"$timestamp":{"t":1714564800,"i":1}},
+				"documentKey":{"_id":"order-2"},"fullDocument":{"_id":"order-2"},
+				"lsid":{"id":{"$binary":{"base64":"q80=","subType":"04"}}},"txnNumber":7}]}`,
+			string(events[0].Data))
+	})
+	t.Run("should publish the change events that could not be encoded before the batch", func(t *testing.T) {
+		tx := newTxn()
+		tx.events[0].DecodeError = errors.New("invalid utf-8")
+
+		events, err := tx.batch(opts)
+
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, "1", events[0].MsgId)
+		require.Equal(t, 1, events[1].Transaction.Total)
+	})
+	t.Run("should drop the batch if it is oversized and the oversized policy is drop", func(t *testing.T) {
+		dropOpts := *opts
+		dropOpts.MaxPayload, dropOpts.OversizedPolicy = 10, DropOversizedPolicy
+
+		events, err := newTxn().batch(&dropOpts)
+
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+}
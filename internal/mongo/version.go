@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+)
+
+var ErrCutoverInProgress = server.ErrCutoverInProgress
+
+// SchemaVersion holds the schema version of a collection, prefixing the subjects of its change events, e.g.
+// v2.ORDERS.insert, which can be switched at runtime once its pending change events are published, so that consumers
+// can migrate from a version to another in a blue/green fashion.
+type SchemaVersion struct {
+	mu      sync.Mutex
+	current int
+	next    *cutover
+}
+
+// cutover is a pending switch to another schema version.
+type cutover struct {
+	version int
+	applied chan struct{}
+}
+
+// NewSchemaVersion returns the given schema version. If zero, subjects are not prefixed.
+func NewSchemaVersion(version int) *SchemaVersion {
+	return &SchemaVersion{current: version}
+}
+
+// Current returns the schema version the change events are published with.
+func (v *SchemaVersion) Current() int {
+	if v == nil {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.current
+}
+
+// Cutover switches the schema version to the given one, and waits until the watcher of the collection applies it,
+// i.e. once all the change events received before are published with the current version, so that no change event
+// is published with the new version before them. If the given context is cancelled before, the cutover is cancelled.
+func (v *SchemaVersion) Cutover(ctx context.Context, version int) error {
+	v.mu.Lock()
+	if v.current == version {
+		v.mu.Unlock()
+		return nil
+	}
+	if v.next != nil {
+		v.mu.Unlock()
+		return ErrCutoverInProgress
+	}
+	c := &cutover{version: version, applied: make(chan struct{})}
+	v.next = c
+	v.mu.Unlock()
+
+	select {
+	case <-c.applied:
+		return nil
+	case <-ctx.Done():
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if v.next != c {
+			return nil // applied meanwhile
+		}
+		v.next = nil
+		return ctx.Err()
+	}
+}
+
+// pending returns true if a cutover is waiting to be applied.
+func (v *SchemaVersion) pending() bool {
+	if v == nil {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.next != nil
+}
+
+// apply applies the pending cutover, if any, returning the previous and the new schema versions.
+func (v *SchemaVersion) apply() (from, to int, ok bool) {
+	if v == nil {
+		return 0, 0, false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.next == nil {
+		return v.current, v.current, false
+	}
+	from, v.current = v.current, v.next.version
+	close(v.next.applied)
+	v.next = nil
+	return from, v.current, true
+}
+
+// versioned prefixes the given subject with the given schema version, unless it is zero.
+func versioned(version int, subj string) string {
+	if version <= 0 {
+		return subj
+	}
+	return "v" + strconv.Itoa(version) + "." + subj
+}
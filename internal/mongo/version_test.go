@@ -0,0 +1,63 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaVersion_Cutover(t *testing.T) {
+	t.Run("should switch the schema version once applied", func(t *testing.T) {
+		v := NewSchemaVersion(1)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- v.Cutover(context.Background(), 2)
+		}()
+		require.Eventually(t, v.pending, time.Second, 10*time.Millisecond)
+		require.Equal(t, 1, v.Current(), "the schema version should not switch before being applied")
+
+		from, to, ok := v.apply()
+		require.True(t, ok)
+		require.Equal(t, 1, from)
+		require.Equal(t, 2, to)
+		require.NoError(t, <-done)
+		require.Equal(t, 2, v.Current())
+		require.False(t, v.pending())
+	})
+	t.Run("should do nothing if the schema version is the current one", func(t *testing.T) {
+		v := NewSchemaVersion(1)
+
+		require.NoError(t, v.Cutover(context.Background(), 1))
+		require.False(t, v.pending())
+	})
+	t.Run("should return error if another cutover is in progress", func(t *testing.T) {
+		v := NewSchemaVersion(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			_ = v.Cutover(ctx, 2)
+		}()
+		require.Eventually(t, v.pending, time.Second, 10*time.Millisecond)
+
+		require.ErrorIs(t, v.Cutover(context.Background(), 3), ErrCutoverInProgress)
+	})
+	t.Run("should cancel the cutover if the context is done before it is applied", func(t *testing.T) {
+		v := NewSchemaVersion(1)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		require.ErrorIs(t, v.Cutover(ctx, 2), context.DeadlineExceeded)
+		require.False(t, v.pending())
+		_, _, ok := v.apply()
+		require.False(t, ok)
+		require.Equal(t, 1, v.Current())
+	})
+}
+
+func Test_versioned(t *testing.T) {
+	require.Equal(t, "ORDERS.insert", versioned(0, "ORDERS.insert"))
+	require.Equal(t, "v3.ORDERS.insert", versioned(3, "ORDERS.insert"))
+}
@@ -0,0 +1,595 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+)
+
+// changeStreamWatcher processes the change events of a single change stream, publishing them and persisting their
+// resume tokens.
+type changeStreamWatcher struct {
+	client           *DefaultClient
+	opts             *WatchCollectionOptions
+	routeOpts        []*WatchCollectionOptions
+	cs               *mongo.ChangeStream
+	resumeTokensColl *mongo.Collection
+	limiter          *rate.Limiter
+
+	// pending holds the change events waiting to be published concurrently.
+	pending []*changeEvent
+
+	// renamed is set once the watched collection is renamed.
+	renamed *rename
+
+	// gap holds the change events skipped since they are older than the maximum event age.
+	gap *gap
+
+	// txn holds the change events of the current transaction, if transactions are tagged or batched.
+	txn *txn
+
+	// fragments holds the fragments of the current split change event, until all of them are received.
+	fragments []bson.Raw
+
+	// tokenSaved is true once the resume token of a change event of this change stream is persisted.
+	tokenSaved bool
+
+	// published holds the last message published to each subject, if the collection resumes idempotently.
+	published map[string]PublishedMsg
+
+	// alreadyPublished holds the message ids of the change events published after the last stored resume token.
+	alreadyPublished *alreadyPublished
+
+	// ordering checks that the change events are observed and published in the order of their cluster time.
+	ordering ordering
+}
+
+type changeEvent struct {
+	ChangeEvent
+	token string
+	// raw is the raw BSON of the change event, kept to be quarantined if it cannot be published.
+	raw bson.Raw
+	// clusterTime is the cluster time of the change event, used to check that change events are published in order.
+	clusterTime primitive.Timestamp
+}
+
+func newChangeStreamWatcher(client *DefaultClient, opts *WatchCollectionOptions, cs *mongo.ChangeStream,
+	resumeTokensColl *mongo.Collection, published []PublishedMsg) *changeStreamWatcher {
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if opts.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), max(1, int(opts.RateLimit)))
+	}
+	return &changeStreamWatcher{
+		client:           client,
+		opts:             opts,
+		routeOpts:        RouteOptions(opts),
+		cs:               cs,
+		resumeTokensColl: resumeTokensColl,
+		limiter:          limiter,
+		pending:          make([]*changeEvent, 0, max(1, opts.PublishWorkers)),
+		published:        publishedBySubj(published),
+	}
+}
+
+// watch processes the change events until the change stream fails, stalls, or the context is cancelled.
+// The returned resume flag is false if the change stream was invalidated, and cannot be resumed.
+func (w *changeStreamWatcher) watch(ctx context.Context) (resume bool, err error) {
+	logger := w.client.logger
+	collName := w.opts.WatchedCollName
+
+	// in-flight change events are published and their resume tokens persisted even if the context is cancelled
+	drainCtx := context.WithoutCancel(ctx)
+
+	w.alreadyPublished = w.findAlreadyPublished(ctx)
+
+	lastHeartbeat := time.Now()
+	for {
+		hasNext, stalled := tryNext(ctx, w.cs, w.opts.StallTimeout)
+		if stalled {
+			// the cursor did not return anything, not even an empty batch, within the heartbeat window.
+			// recreate the change stream, resuming after the last persisted token.
+			logger.Warn("change stream stalled, recreating cursor", "collName", collName,
+				"stallTimeout", w.opts.StallTimeout, "sinceLastHeartbeat", time.Since(lastHeartbeat),
+				"cursorId", w.cs.ID(), "resumeToken", w.cs.ResumeToken().String())
+			return true, nil
+		}
+		if !hasNext {
+			if ctx.Err() != nil {
+				return true, nil
+			}
+			if err := w.cs.Err(); err != nil {
+				return true, fmt.Errorf("change stream failed: %w", err)
+			}
+			lastHeartbeat = time.Now() // empty batch
+			w.client.checkOplogWindow(ctx)
+			// the change stream caught up, pending change events, the gap marker and the transaction must not wait
+			if err := w.closeTxn(drainCtx); err != nil {
+				return w.handleFlushError(err)
+			}
+			if err := w.closeGap(drainCtx); err != nil {
+				return w.handleFlushError(err)
+			}
+			if err := w.flush(drainCtx); err != nil {
+				return w.handleFlushError(err)
+			}
+			w.applyCutover()
+			// all the change events up to the resume token of the batch are processed
+			if token, ok := w.cs.ResumeToken().Lookup("_data").StringValueOK(); ok {
+				w.advanceWatermark(token, false)
+			}
+			continue
+		}
+		lastHeartbeat = time.Now()
+
+		current := w.cs.Current
+		if fragment, of, ok := fragmentOf(current); ok {
+			// the change event exceeds the maximum BSON size, and is reassembled from its fragments
+			if int(fragment) != len(w.fragments)+1 {
+				return false, fmt.Errorf("unexpected fragment %d of %d of split change event", fragment, of)
+			}
+			w.fragments = append(w.fragments, current)
+			if fragment < of {
+				continue
+			}
+			var err error
+			if current, err = reassemble(w.fragments); err != nil {
+				return false, err
+			}
+			w.fragments = nil
+		}
+
+		currentResumeToken := current.Lookup("_id", "_data").StringValue()
+		operationType := current.Lookup("operationType").StringValue()
+		clusterTime := clusterTimeOf(current)
+		if err := w.checkOrder(observedOrderStage, clusterTime, currentResumeToken); err != nil {
+			return false, err
+		}
+
+		// the change event is only converted to extended json if it is logged, since it is costly
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			if w.client.metadataLogging {
+				logger.Debug("received change event", "collName", collName, "operationType", operationType,
+					"resumeToken", currentResumeToken, "size", len(current))
+			} else {
+				logger.Debug("received change event", "changeEvent", current.String())
+			}
+		}
+
+		// the current transaction is complete once a change event which does not belong to it is received
+		var txnId string
+		if w.opts.TransactionMode != "" {
+			txnId = transactionId(current)
+		}
+		if w.txn != nil && w.txn.id != txnId {
+			if err := w.closeTxn(drainCtx); err != nil {
+				return w.handleFlushError(err)
+			}
+		}
+
+		if operationType == renameOperationType {
+			w.renamed, _ = renameOf(current)
+		}
+
+		if isStale(current, operationType, w.opts.MaxEventAge) {
+			if w.gap == nil {
+				logger.Warn("skipping change events older than the maximum event age", "collName", collName,
+					"maxEventAge", w.opts.MaxEventAge, "resumeToken", currentResumeToken)
+				w.gap = &gap{}
+			}
+			w.gap.add(current, currentResumeToken)
+			continue
+		}
+		if err := w.closeGap(drainCtx); err != nil {
+			return w.handleFlushError(err)
+		}
+
+		if filtered(w.opts, operationType, current) {
+			logger.Debug("dropping change event not matching the filter", "collName", collName,
+				"resumeToken", currentResumeToken)
+			if w.client.onChangeEventFilteredEvent != nil {
+				w.client.onChangeEventFilteredEvent(w.opts.WatchedDbName, collName)
+			}
+			continue
+		}
+
+		if w.opts.Envelope.missesBefore(operationType, current) {
+			switch w.opts.Envelope.MissingBefore {
+			case SkipMissingBeforePolicy:
+				logger.Warn("skipping change event whose pre-image is unavailable", "collName", collName,
+					"resumeToken", currentResumeToken)
+				continue
+			case FailMissingBeforePolicy:
+				return false, fmt.Errorf("%w: %v", ErrMissingBefore, currentResumeToken)
+			}
+		}
+
+		if w.opts.SchemaVersion.pending() && w.txn == nil {
+			// the change events received before the cutover are published with the previous schema version
+			if err := w.flush(drainCtx); err != nil {
+				return w.handleFlushError(err)
+			}
+			w.applyCutover()
+		}
+
+		if w.opts.Enrichment != nil {
+			enriched, err := w.opts.Enrichment.enrich(ctx, w.client.mongoClient().Database(w.opts.WatchedDbName),
+				current)
+			if err != nil {
+				if ctx.Err() != nil {
+					return true, nil // the change event is enriched again once resumed
+				}
+				return true, err
+			}
+			current = enriched
+		}
+		untyped := current
+		eventType := eventTypeOf(w.opts, operationType)
+		if eventType != "" {
+			typed, err := withEventType(current, eventType)
+			if err != nil {
+				return false, err
+			}
+			current = typed
+		}
+
+		routeOpts := route(w.opts, w.routeOpts, current)
+		subj, err := subject(routeOpts, operationType, current)
+		var hdrs map[string]string
+		if err == nil {
+			hdrs, err = headers(w.opts, operationType, current)
+		}
+		if err != nil {
+			err = &transformError{err: err}
+		}
+		schemaChange := w.opts.SchemaChangesStreamName != "" && isSchemaChange(operationType)
+		gridFSEvent, isGridFSEvent := gridFSEvents[operationType]
+		isGridFSEvent = isGridFSEvent && w.opts.GridFS
+		var data []byte
+		var file *GridFSFile
+		if err == nil {
+			if schemaChange {
+				data, err = encodeSchemaChange(current, w.opts)
+				subj = schemaChangeSubject(w.opts, operationType)
+			} else if isGridFSEvent {
+				data, err = encodeGridFSEvent(current, gridFSEvent, w.opts)
+				file = gridFSFile(w.client.mongoClient().Database(w.opts.WatchedDbName), w.opts, gridFSEvent, current)
+			} else {
+				data, err = encodeChangeEvent(current, w.opts)
+			}
+		}
+
+		// decodeErr is set if the change event cannot be transformed or encoded
+		var decodeErr error
+		if err != nil {
+			class := errorClassOf(err)
+			action := w.errorAction(class)
+			if action != SkipErrorAction && action != DlqErrorAction {
+				return false, err
+			}
+			logger.Error("could not encode change event", "collName", collName, "resumeToken", currentResumeToken,
+				"class", class, "policy", action, "err", err)
+			if w.client.onChangeEventMalformedEvent != nil {
+				w.client.onChangeEventMalformedEvent(w.opts.WatchedDbName, collName, string(action))
+			}
+			if action == SkipErrorAction {
+				continue
+			}
+			decodeErr, data = err, slices.Clone(current)
+		}
+
+		var partial bool
+		if missing := missingImages(w.opts.MissingImages, operationType, current); len(missing) > 0 &&
+			decodeErr == nil {
+			logger.Warn("images of change event are unavailable", "collName", collName,
+				"resumeToken", currentResumeToken, "missing", missing, "policy", w.opts.MissingImages)
+			switch w.opts.MissingImages {
+			case FailMissingImagesPolicy:
+				return false, newMissingImagesError(missing)
+			case DlqMissingImagesPolicy:
+				decodeErr, data = newMissingImagesError(missing), slices.Clone(current)
+			default:
+				partial = true
+			}
+		}
+
+		if _, ok := publishableOperationTypes[operationType]; !ok && !schemaChange {
+			// pending change events must be published before moving on
+			if err = w.flush(drainCtx); err != nil {
+				return w.handleFlushError(err)
+			}
+			if operationType == invalidateOperationType {
+				return false, nil
+			}
+			continue
+		}
+
+		oversized := decodeErr == nil && w.opts.MaxPayload > 0 && int64(len(data)) > w.opts.MaxPayload
+		if oversized {
+			logger.Warn("change event exceeds the maximum payload", "collName", collName, "size", len(data),
+				"maxPayload", w.opts.MaxPayload, "policy", w.opts.OversizedPolicy)
+			if w.client.onChangeEventOversizedEvent != nil {
+				w.client.onChangeEventOversizedEvent(w.opts.WatchedDbName, collName, string(w.opts.OversizedPolicy))
+			}
+			switch w.opts.OversizedPolicy {
+			case DropOversizedPolicy:
+				continue
+			case TruncateOversizedPolicy:
+				if schemaChange {
+					break // schema changes hold no documents
+				}
+				if data, err = encodeTruncated(current, w.opts); err != nil {
+					return false, err
+				}
+				oversized = int64(len(data)) > w.opts.MaxPayload
+			}
+		}
+
+		var shadow *Shadow
+		if w.opts.Canary != nil && decodeErr == nil && !schemaChange && !isGridFSEvent {
+			if shadow, err = w.opts.Canary.shadow(w.opts, subj, currentResumeToken, current); err != nil {
+				logger.Warn("could not encode shadow change event", "collName", collName,
+					"resumeToken", currentResumeToken, "err", err)
+			}
+		}
+
+		if err = w.limiter.Wait(ctx); err != nil {
+			return true, nil // the connector is shutting down
+		}
+
+		event := &changeEvent{
+			ChangeEvent: ChangeEvent{
+				Subj:          subj,
+				Headers:       hdrs,
+				GridFSFile:    file,
+				Encrypted:     w.opts.EncryptedPassthrough && hasCiphertext(current),
+				StreamName:    routeOpts.StreamName,
+				Shadow:        shadow,
+				SchemaVersion: w.opts.SchemaVersion.Current(),
+				MsgId:         msgId(current, w.opts.MsgIdStrategy, w.opts.MsgIdField),
+				DocumentKey:   documentKey(current),
+				Bookkeeping:   bookkeepingOf(w.opts, current, currentResumeToken),
+				Data:          data,
+				OperationType: operationType,
+				EventType:     eventType,
+				Tenant:        tenant(current, w.opts.TenantField),
+				Session:       sessionOf(current),
+				Time:          eventTime(current),
+				SchemaChange:  schemaChange,
+				Oversized:     oversized,
+				Partial:       partial,
+				DecodeError:   decodeErr,
+			},
+			token:       currentResumeToken,
+			clusterTime: clusterTime,
+		}
+		if w.opts.QuarantineCollName != "" && !schemaChange && !isGridFSEvent {
+			event.raw = untyped
+		}
+		if txnId != "" {
+			if w.txn == nil {
+				w.txn = &txn{id: txnId}
+			}
+			w.txn.add(event, current)
+			continue
+		}
+		w.pending = append(w.pending, event)
+		// wait for more change events, until there are enough of them to keep all publish workers busy
+		if len(w.pending) < cap(w.pending) {
+			continue
+		}
+		if err = w.flush(drainCtx); err != nil {
+			return w.handleFlushError(err)
+		}
+	}
+}
+
+// applyCutover applies the pending cutover of the schema version, if any, once all the pending change events are
+// published.
+func (w *changeStreamWatcher) applyCutover() {
+	if from, to, ok := w.opts.SchemaVersion.apply(); ok {
+		w.client.logger.Info("schema version switched", "collName", w.opts.WatchedCollName, "from", from, "to", to)
+	}
+}
+
+// closeGap queues the marker of the current gap, if any, in place of the skipped change events.
+func (w *changeStreamWatcher) closeGap(ctx context.Context) error {
+	if w.gap == nil {
+		return nil
+	}
+	g := w.gap
+	data, err := encodeGap(g, w.opts)
+	if err != nil {
+		return err
+	}
+	subj, err := subject(w.opts, gapOperationType, g.last)
+	if err != nil {
+		return err
+	}
+	hdrs, err := headers(w.opts, gapOperationType, g.last)
+	if err != nil {
+		return err
+	}
+	w.client.logger.Info("publishing gap marker in place of the skipped change events",
+		"collName", w.opts.WatchedCollName, "skipped", g.skipped, "from", g.from, "to", g.to)
+	if w.client.onChangeEventsSkippedEvent != nil {
+		w.client.onChangeEventsSkippedEvent(w.opts.WatchedDbName, w.opts.WatchedCollName, g.skipped)
+	}
+	w.gap = nil
+	w.pending = append(w.pending, &changeEvent{
+		ChangeEvent: ChangeEvent{
+			Subj:          subj,
+			Headers:       hdrs,
+			MsgId:         gapOperationType + "-" + g.token,
+			Data:          data,
+			OperationType: gapOperationType,
+			Time:          g.to,
+			SchemaVersion: w.opts.SchemaVersion.Current(),
+		},
+		token: g.token,
+	})
+	if len(w.pending) < cap(w.pending) {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+// closeTxn queues the change events of the current transaction, if any, tagged or batched according to the
+// transaction mode.
+func (w *changeStreamWatcher) closeTxn(ctx context.Context) error {
+	if w.txn == nil {
+		return nil
+	}
+	t := w.txn
+	w.txn = nil
+	events := t.events
+	if w.opts.TransactionMode == BatchTransactionMode {
+		var err error
+		if events, err = t.batch(w.opts); err != nil {
+			return err
+		}
+	} else {
+		t.tag()
+	}
+	w.pending = append(w.pending, events...)
+	if len(w.pending) < cap(w.pending) {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+// flush publishes the pending change events concurrently, then persists the resume token of the last one.
+func (w *changeStreamWatcher) flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	defer func() {
+		w.pending = w.pending[:0]
+	}()
+
+	if err := w.publish(ctx); err != nil {
+		// pending change events might not have been published.
+		// their resume tokens will not be stored.
+		// connector will resume after the previous token.
+		return &publishError{err: err}
+	}
+	w.alreadyPublished.passed(w.pending)
+
+	var published []PublishedMsg
+	if w.opts.PublishedMsgIdsHandler != nil {
+		recordPublished(w.published, w.pending)
+		published = publishedMsgs(w.published)
+	}
+	lastResumeToken := w.pending[len(w.pending)-1].token
+	if err := w.client.saveResumeToken(ctx, w.opts, w.resumeTokensColl, lastResumeToken, published); err != nil {
+		if w.opts.ErrorPolicies.Action(TokenSaveErrorClass) != SkipErrorAction || ctx.Err() != nil {
+			return err
+		}
+		// the change events are published, and their resume token is persisted along with the next one
+		w.client.logger.Warn("could not insert resume token, skipping it", "collName", w.opts.WatchedCollName,
+			"token", lastResumeToken, "err", err)
+		return nil
+	}
+	w.tokenSaved = true
+	// the oplog window is checked while catching up as well
+	w.client.checkOplogWindow(ctx)
+	// the change events of the same transaction as the last one might not all be processed yet
+	w.advanceWatermark(lastResumeToken, true)
+	return nil
+}
+
+// advanceWatermark reports the cluster time of the given resume token, or the one right before it if exclusive, as
+// the watermark of the change stream.
+func (w *changeStreamWatcher) advanceWatermark(token string, exclusive bool) {
+	if w.opts.WatermarkHandler == nil {
+		return
+	}
+	ts, ok := tokenTimestamp(token)
+	if !ok {
+		return
+	}
+	if exclusive {
+		ts = previous(ts)
+	}
+	w.opts.WatermarkHandler(ts)
+}
+
+func (w *changeStreamWatcher) publish(ctx context.Context) error {
+	if len(w.pending) == 1 {
+		return w.publishOne(ctx, w.pending[0])
+	}
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(1, w.opts.PublishWorkers))
+	for _, event := range w.pending {
+		group.Go(func() error {
+			return w.publishOne(groupCtx, event)
+		})
+	}
+	return group.Wait()
+}
+
+func (w *changeStreamWatcher) publishOne(ctx context.Context, event *changeEvent) error {
+	if w.alreadyPublished.skip(event.MsgId) {
+		w.client.logger.Debug("skipping change event already published", "collName", w.opts.WatchedCollName,
+			"msgId", event.MsgId)
+		return nil
+	}
+	if err := w.opts.ChangeEventHandler(ctx, &event.ChangeEvent); err != nil {
+		if w.quarantine(ctx, event, err) {
+			return nil
+		}
+		return err
+	}
+	if w.client.onChangeEventPublishedEvent != nil {
+		w.client.onChangeEventPublishedEvent(w.opts.WatchedDbName, w.opts.WatchedCollName, event.OperationType,
+			len(event.Data))
+	}
+	return w.checkOrder(publishedOrderStage, event.clusterTime, event.token)
+}
+
+func (w *changeStreamWatcher) handleFlushError(err error) (resume bool, _ error) {
+	logger := w.client.logger
+	if pubErr, ok := err.(*publishError); ok {
+		if errors.Is(pubErr.err, ErrOutOfOrder) || errors.Is(pubErr.err, ErrUnpublishable) {
+			return false, pubErr.err
+		}
+		logger.Error("could not publish change event", "err", pubErr.err)
+		return true, nil
+	}
+	if !isTransientError(err) || w.opts.ErrorPolicies.Action(TokenSaveErrorClass) == HaltErrorAction {
+		return false, fmt.Errorf("could not insert resume token: %w", err)
+	}
+	// change events have been published but token insertion failed.
+	// connector will resume after the previous token, publishing duplicate change events.
+	// consumers should be able to detect and discard the duplicate change events by using the msg id.
+	logger.Error("could not insert resume token", "err", err)
+	return true, nil
+}
+
+// errorAction returns the action taken once a change event fails with an error of the given class.
+// Transform errors which are not mapped are handled as decode errors.
+func (w *changeStreamWatcher) errorAction(class ErrorClass) ErrorAction {
+	if _, ok := w.opts.ErrorPolicies[class]; class == TransformErrorClass && !ok {
+		class = DecodeErrorClass
+	}
+	if class == DecodeErrorClass {
+		return ErrorAction(w.opts.DecodeErrorPolicy)
+	}
+	return w.opts.ErrorPolicies.Action(class)
+}
+
+type publishError struct {
+	err error
+}
+
+func (e *publishError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,94 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SubjectBinding tells which streams store the messages published to the subjects matching a subject pattern.
+type SubjectBinding struct {
+	Subject string
+	// Streams are the names of the streams bound to subjects overlapping the subject pattern, in order.
+	Streams []string
+	// Covered is true if one of the streams is bound to all the subjects matching the subject pattern.
+	Covered bool
+}
+
+// NewSubjectBinding returns the binding of the given subject pattern to the given streams, holding the subjects each
+// of them is bound to, by stream name.
+func NewSubjectBinding(subject string, streamSubjects map[string][]string) *SubjectBinding {
+	binding := &SubjectBinding{Subject: subject, Streams: make([]string, 0)}
+	for stream, subjects := range streamSubjects {
+		for _, streamSubject := range subjects {
+			if !subjectsOverlap(streamSubject, subject) {
+				continue
+			}
+			if !slices.Contains(binding.Streams, stream) {
+				binding.Streams = append(binding.Streams, stream)
+			}
+			binding.Covered = binding.Covered || subjectCovers(streamSubject, subject)
+		}
+	}
+	slices.Sort(binding.Streams)
+	return binding
+}
+
+// SubjectBinding returns the binding of the given subject pattern to the streams of JetStream.
+func (c *DefaultClient) SubjectBinding(ctx context.Context, subject string) (*SubjectBinding, error) {
+	infos := c.js.Streams(nats.StreamListFilter(subject), nats.Context(ctx))
+	if infos == nil {
+		return nil, fmt.Errorf("could not list nats streams bound to %v", subject)
+	}
+	streamSubjects := make(map[string][]string)
+	for info := range infos {
+		streamSubjects[info.Config.Name] = info.Config.Subjects
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("could not list nats streams bound to %v: %v", subject, err)
+	}
+	if len(streamSubjects) == 0 {
+		// the listing does not report its errors, e.g. if the connector is not allowed to list the streams, tell them
+		// apart from the subject being bound to no stream
+		if _, err := c.js.StreamNameBySubject(subject, nats.Context(ctx)); err != nil &&
+			!errors.Is(err, nats.ErrNoMatchingStream) {
+			return nil, fmt.Errorf("could not list nats streams bound to %v: %v", subject, err)
+		}
+	}
+	return NewSubjectBinding(subject, streamSubjects), nil
+}
+
+// subjectCovers returns true if all the subjects matching the given subject pattern match the given filter.
+func subjectCovers(filter, subject string) bool {
+	filterTokens, subjectTokens := strings.Split(filter, "."), strings.Split(subject, ".")
+	for i, filterToken := range filterTokens {
+		if filterToken == ">" {
+			return i < len(subjectTokens)
+		}
+		if i >= len(subjectTokens) || subjectTokens[i] == ">" {
+			return false
+		}
+		if filterToken != "*" && filterToken != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(filterTokens) == len(subjectTokens)
+}
+
+// subjectsOverlap returns true if a subject can match both the given subject patterns.
+func subjectsOverlap(subject, other string) bool {
+	tokens, otherTokens := strings.Split(subject, "."), strings.Split(other, ".")
+	for i := 0; i < len(tokens) && i < len(otherTokens); i++ {
+		if tokens[i] == ">" || otherTokens[i] == ">" {
+			return true
+		}
+		if tokens[i] != "*" && otherTokens[i] != "*" && tokens[i] != otherTokens[i] {
+			return false
+		}
+	}
+	return len(tokens) == len(otherTokens)
+}
@@ -0,0 +1,76 @@
+package nats
+
+import (
+	"context"
+	"testing"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSubjectBinding(t *testing.T) {
+	tests := []struct {
+		name           string
+		subject        string
+		streamSubjects map[string][]string
+		want           *SubjectBinding
+	}{
+		{
+			name:           "should bind the subject to the stream covering it",
+			subject:        "ORDERS.insert.*",
+			streamSubjects: map[string][]string{"ORDERS": {"ORDERS.*.*"}, "INVOICES": {"INVOICES.*"}},
+			want:           &SubjectBinding{Subject: "ORDERS.insert.*", Streams: []string{"ORDERS"}, Covered: true},
+		},
+		{
+			name:           "should bind the subject to no stream if none overlaps it",
+			subject:        "ORDERS.insert",
+			streamSubjects: map[string][]string{"ORDERS": {"ORDERS.*.*"}},
+			want:           &SubjectBinding{Subject: "ORDERS.insert", Streams: []string{}},
+		},
+		{
+			name:           "should not cover the subject with a stream bound to a part of it",
+			subject:        "ORDERS.>",
+			streamSubjects: map[string][]string{"ORDERS": {"ORDERS.insert"}},
+			want:           &SubjectBinding{Subject: "ORDERS.>", Streams: []string{"ORDERS"}},
+		},
+		{
+			name:    "should bind the subject to all the streams overlapping it",
+			subject: "ORDERS.>",
+			streamSubjects: map[string][]string{"ORDERS": {"ORDERS.*"}, "ARCHIVE": {"*.archived"},
+				"INVOICES": {"INVOICES.>"}},
+			want: &SubjectBinding{Subject: "ORDERS.>", Streams: []string{"ARCHIVE", "ORDERS"}},
+		},
+		{
+			name:           "should cover the subject with a stream bound to all subjects",
+			subject:        "v2.ORDERS.insert",
+			streamSubjects: map[string][]string{"ALL": {">"}},
+			want:           &SubjectBinding{Subject: "v2.ORDERS.insert", Streams: []string{"ALL"}, Covered: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, NewSubjectBinding(tt.subject, tt.streamSubjects))
+		})
+	}
+}
+
+func TestClient_SubjectBinding(t *testing.T) {
+	t.Run("should bind the subject to the streams of jetstream", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "ORDERS"}))
+		require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "INVOICES"}))
+
+		binding, err := client.SubjectBinding(context.Background(), "ORDERS.insert")
+		require.NoError(t, err)
+		require.Equal(t, &SubjectBinding{Subject: "ORDERS.insert", Streams: []string{"ORDERS"}, Covered: true},
+			binding)
+
+		binding, err = client.SubjectBinding(context.Background(), "CUSTOMERS.insert")
+		require.NoError(t, err)
+		require.Empty(t, binding.Streams)
+	})
+}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -15,65 +18,228 @@ import (
 
 const (
 	defaultName = "nats"
+
+	// msgIdsIdleTimeout is the maximum amount of time to wait for the next message ids of a stream, which may have been
+	// deleted since they were counted.
+	msgIdsIdleTimeout = 5 * time.Second
 )
 
 var (
 	ErrClientDisconnected = errors.New("could not reach nats: connection closed")
+	ErrClientReconnecting = errors.New("could not reach nats: reconnecting")
+	ErrBackpressure       = errors.New("could not publish to nats: the stream rejected the message")
+	ErrNack               = errors.New("could not publish to nats: the stream rejected the message with a negative ack")
+	ErrAckTimeout         = errors.New("could not publish to nats: the stream did not acknowledge the message in time")
+	ErrNoStream           = fmt.Errorf("%w: no stream responded", ErrBackpressure)
+	ErrObjectNotFound     = errors.New("nats object not found")
 )
 
+// jsStreamStoreFailedErrCode is the jetstream error code returned when the stream could not store a message, e.g.
+// because its maximum messages or bytes are exceeded and it discards new messages.
+const jsStreamStoreFailedErrCode nats.ErrorCode = 10077
+
 type Client interface {
 	server.NamedMonitor
 	io.Closer
 
 	AddStream(ctx context.Context, opts *AddStreamOptions) error
 	Publish(ctx context.Context, opts *PublishOptions) error
+	WaitConnected(ctx context.Context) error
+	Reconnecting() bool
+	MaxPayload() int64
+	PutObject(ctx context.Context, bucket, name string, data []byte) error
+	PutObjectStream(ctx context.Context, bucket, name string, r io.Reader) error
+	GetObject(ctx context.Context, bucket, name string) ([]byte, error)
+	DeleteObject(ctx context.Context, bucket, name string) error
+	KeyValue(bucket string) (KeyValue, error)
+	LastMsgHeader(ctx context.Context, stream, header string) (uint64, string, error)
+	MsgIdsAfter(ctx context.Context, stream string, subjects []string, seq uint64, limit int) ([]string, uint64, error)
+	SubjectBinding(ctx context.Context, subject string) (*SubjectBinding, error)
+	Subscribe(ctx context.Context, subj string) (<-chan *Msg, error)
 }
 
 type AddStreamOptions struct {
 	StreamName string
+	// Subject is the subject filter the stream is bound to. If empty, <stream>.* is used.
+	Subject string
+	// Duplicates is the window used by the stream to discard duplicate messages. If zero, the server default is used.
+	Duplicates time.Duration
+	// ReplaySpan is the worst-case time span of change events that could be published again after a restart.
+	// A warning is logged if the duplicates window of the stream cannot cover it.
+	ReplaySpan time.Duration
+	// Limits bound the messages kept by the stream. If nil, the server defaults are used.
+	Limits *StreamLimits
+	// AllowMsgTtl allows the messages of the stream to set their own time to live.
+	AllowMsgTtl bool
+}
+
+// StreamLimits bound the messages kept by a stream, the oldest ones being discarded once a limit is reached. Limits
+// left to zero are not set.
+type StreamLimits struct {
+	// MaxMsgsPerSubject is the number of messages kept per subject.
+	MaxMsgsPerSubject int64
+	MaxMsgs           int64
+	MaxBytes          int64
+	MaxAge            time.Duration
+}
+
+// apply sets the limits on the given stream configuration, and returns true if it changed.
+func (l *StreamLimits) apply(cfg *nats.StreamConfig) bool {
+	if l == nil {
+		return false
+	}
+	changed := false
+	set := func(limit *int64, value int64) {
+		if value != 0 && *limit != value {
+			*limit = value
+			changed = true
+		}
+	}
+	set(&cfg.MaxMsgsPerSubject, l.MaxMsgsPerSubject)
+	set(&cfg.MaxMsgs, l.MaxMsgs)
+	set(&cfg.MaxBytes, l.MaxBytes)
+	if l.MaxAge != 0 && cfg.MaxAge != l.MaxAge {
+		cfg.MaxAge = l.MaxAge
+		changed = true
+	}
+	return changed
+}
+
+// PublishMode represents how messages are published to nats.
+type PublishMode string
+
+const (
+	// JetStreamPublishMode publishes messages to a JetStream stream, waiting for the ack.
+	JetStreamPublishMode PublishMode = "jetstream"
+
+	// CorePublishMode publishes messages to plain nats subjects, in a fire-and-forget fashion.
+	// Messages may be lost, but latency is lower.
+	CorePublishMode PublishMode = "core"
+)
+
+var PublishModes = []PublishMode{
+	JetStreamPublishMode,
+	CorePublishMode,
 }
 
+// NackPolicy represents what is done with the messages rejected by JetStream with a negative ack.
+type NackPolicy string
+
+const (
+	// FailNackPolicy fails the publish, so that the message is published again once the watcher resumes.
+	FailNackPolicy NackPolicy = "fail"
+
+	// DlqNackPolicy publishes the message to a dead letter subject instead.
+	DlqNackPolicy NackPolicy = "dlq"
+)
+
+var NackPolicies = []NackPolicy{
+	FailNackPolicy,
+	DlqNackPolicy,
+}
+
+const msgTtlHdr = "Nats-TTL"
+
 type PublishOptions struct {
 	Subj  string
 	MsgId string
 	Data  []byte
+	Mode  PublishMode
+	// Headers are added to the message, e.g. to identify the connector instance that published it.
+	Headers map[string]string
+
+	// the following options only apply to JetStream publishing.
+
+	// ExpectedStream, if set, makes the publish fail if the subject is not bound to the given stream.
+	ExpectedStream string
+	// AckTimeout is the maximum amount of time to wait for the ack. If zero, it waits until the context is done.
+	AckTimeout time.Duration
+	// RetryAttempts is the number of retries when no stream is available. If zero, the client default is used.
+	RetryAttempts int
+	// RetryWait is the amount of time between retries. If zero, the client default is used.
+	RetryWait time.Duration
+	// MsgTtl is the per-message time to live, supported by streams that allow message TTLs.
+	MsgTtl time.Duration
+
+	// Ack is set once the message is acked by JetStream, with the stream and sequence it is stored at.
+	Ack *nats.PubAck
 }
 
 var _ Client = &DefaultClient{}
+var _ server.DetailedMonitor = &DefaultClient{}
 
 type DefaultClient struct {
 	url    string
 	name   string
 	logger *slog.Logger
+	// metadataLogging logs the metadata of the messages published, instead of their whole data.
+	metadataLogging bool
+
+	credsFile          string
+	certFile           string
+	keyFile            string
+	caFile             string
+	credsWatchInterval time.Duration
 
 	onMsgPublishedEvent func(subj string, duration time.Duration)
 	onMsgFailedEvent    func(subj string, duration time.Duration)
+	onMsgAckedEvent     func(stream string, seq uint64)
+	onMsgDuplicateEvent func(subj string)
+	onDisconnectEvent   func(name string)
+	onReconnectEvent    func(name string)
+	onBackpressureEvent func(subj string)
+	onNackEvent         func(subj string)
+	onAckTimeoutEvent   func(subj string)
+	onConnErrorEvent    func(subj string)
+	onCredsRotatedEvent func(name string)
 
 	conn *nats.Conn
 	js   nats.JetStreamContext
+
+	// inflight is read-locked by each publish, so that the credentials rotation can wait for in-flight publishes.
+	inflight sync.RWMutex
+	// closed is closed once the client is closed, to stop watching the credentials files.
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// reconnected is not nil while the client is disconnected, and it is closed once the client reconnects.
+	mu          sync.Mutex
+	reconnected chan struct{}
 }
 
 func NewDefaultClient(opts ...ClientOption) (*DefaultClient, error) {
 	c := &DefaultClient{
-		name:   defaultName,
-		logger: slog.Default(),
+		name:               defaultName,
+		logger:             slog.Default(),
+		credsWatchInterval: defaultCredsWatchInterval,
+		closed:             make(chan struct{}),
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
 
-	conn, err := nats.Connect(c.url,
-		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
-			c.logger.Error("disconnected from nats", "err", err)
-		}),
-		nats.ReconnectHandler(func(conn *nats.Conn) {
-			c.logger.Info("reconnected to nats", "url", conn.ConnectedUrlRedacted())
-		}),
-		nats.ClosedHandler(func(conn *nats.Conn) {
-			c.logger.Info("nats connection closed")
-		}),
-	)
+	natsOpts := []nats.Option{
+		nats.DisconnectErrHandler(c.onDisconnect),
+		nats.ReconnectHandler(c.onReconnect),
+		nats.ClosedHandler(c.onClose),
+	}
+	// credentials and certificates are read again from their files on each reconnect
+	var watchedFiles []string
+	if c.credsFile != "" {
+		natsOpts = append(natsOpts, nats.UserCredentials(c.credsFile))
+		watchedFiles = append(watchedFiles, c.credsFile)
+	}
+	if c.certFile != "" {
+		natsOpts = append(natsOpts, nats.ClientCert(c.certFile, c.keyFile))
+		watchedFiles = append(watchedFiles, c.certFile, c.keyFile)
+	}
+	if c.caFile != "" {
+		natsOpts = append(natsOpts, nats.RootCAs(c.caFile))
+		watchedFiles = append(watchedFiles, c.caFile)
+	}
+
+	conn, err := nats.Connect(c.url, natsOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to nats: %v", err)
 	}
@@ -82,63 +248,516 @@ func NewDefaultClient(opts ...ClientOption) (*DefaultClient, error) {
 	js, _ := conn.JetStream()
 	c.js = js
 
+	if len(watchedFiles) > 0 {
+		go c.watchCredentials(newFileWatcher(watchedFiles...))
+	}
+
 	c.logger.Info("connected to nats", "url", conn.ConnectedUrlRedacted())
 	return c, nil
 }
 
+func (c *DefaultClient) onDisconnect(_ *nats.Conn, err error) {
+	c.logger.Error("disconnected from nats", "err", err)
+	if c.onDisconnectEvent != nil {
+		c.onDisconnectEvent(c.name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reconnected == nil {
+		c.reconnected = make(chan struct{})
+	}
+}
+
+func (c *DefaultClient) onReconnect(conn *nats.Conn) {
+	c.logger.Info("reconnected to nats", "url", conn.ConnectedUrlRedacted())
+	if c.onReconnectEvent != nil {
+		c.onReconnectEvent(c.name)
+	}
+	c.wakeUpWaiters()
+}
+
+func (c *DefaultClient) onClose(_ *nats.Conn) {
+	c.logger.Info("nats connection closed")
+	c.wakeUpWaiters()
+}
+
+func (c *DefaultClient) wakeUpWaiters() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reconnected != nil {
+		close(c.reconnected)
+		c.reconnected = nil
+	}
+}
+
 func (c *DefaultClient) Name() string {
 	return c.name
 }
 
-func (c *DefaultClient) Monitor(_ context.Context) error {
+// Monitor checks that the client is connected, and that the server responds to a ping, before the deadline of the
+// given context if any.
+func (c *DefaultClient) Monitor(ctx context.Context) error {
 	if closed := c.conn.IsClosed(); closed {
 		return ErrClientDisconnected
 	}
+	if c.conn.IsReconnecting() {
+		return ErrClientReconnecting
+	}
+	if _, ok := ctx.Deadline(); ok {
+		if err := c.conn.FlushWithContext(ctx); err != nil {
+			return fmt.Errorf("could not reach nats: %v", err)
+		}
+		return nil
+	}
+	if _, err := c.conn.RTT(); err != nil {
+		return fmt.Errorf("could not reach nats: %v", err)
+	}
 	return nil
 }
 
+// Details reports the state of the connection, its round-trip time, and the number of times it was re-established.
+// Frequent reconnections reveal flapping connections, even if the client looks connected when monitored.
+func (c *DefaultClient) Details() map[string]any {
+	details := map[string]any{
+		"state":      c.conn.Status().String(),
+		"reconnects": c.conn.Stats().Reconnects,
+	}
+	if c.conn.IsConnected() {
+		if rtt, err := c.conn.RTT(); err == nil {
+			details["rtt"] = rtt.String()
+		}
+	}
+	return details
+}
+
 func (c *DefaultClient) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
 	c.conn.Close()
 	return nil
 }
 
 func (c *DefaultClient) AddStream(ctx context.Context, opts *AddStreamOptions) error {
 	addStreamCfg := &nats.StreamConfig{
-		Name:     opts.StreamName,
-		Subjects: []string{fmt.Sprintf("%s.*", opts.StreamName)},
-		Storage:  nats.FileStorage,
+		Name:       opts.StreamName,
+		Subjects:   []string{streamSubject(opts)},
+		Storage:    nats.FileStorage,
+		Duplicates: opts.Duplicates,
+	}
+	opts.Limits.apply(addStreamCfg)
+	info, err := c.js.AddStream(addStreamCfg, nats.Context(ctx))
+	if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		info, err = c.updateStream(ctx, opts)
 	}
-	_, err := c.js.AddStream(addStreamCfg, nats.Context(ctx))
 	if err != nil {
 		return fmt.Errorf("could not add nats stream %v: %v", opts.StreamName, err)
 	}
+	if opts.AllowMsgTtl {
+		if err = c.allowMsgTtl(ctx, opts.StreamName); err != nil {
+			return fmt.Errorf("could not add nats stream %v: %w", opts.StreamName, err)
+		}
+	}
+
+	if info.Config.Duplicates < opts.ReplaySpan {
+		c.logger.Warn("duplicates window of nats stream cannot cover a restart, duplicates may not be discarded",
+			"streamName", opts.StreamName, "duplicates", info.Config.Duplicates, "replaySpan", opts.ReplaySpan)
+	}
 
 	c.logger.Debug("added nats stream", "streamName", opts.StreamName)
 	return nil
 }
 
+// streamSubject returns the subject the stream must be bound to.
+func streamSubject(opts *AddStreamOptions) string {
+	if opts.Subject != "" {
+		return opts.Subject
+	}
+	return fmt.Sprintf("%s.*", opts.StreamName)
+}
+
+// updateStream updates an existing stream, possibly shared by several collections, if its duplicates window or its
+// limits differ from the configured ones or if it is not bound to the configured subject.
+func (c *DefaultClient) updateStream(ctx context.Context, opts *AddStreamOptions) (*nats.StreamInfo, error) {
+	info, err := c.js.StreamInfo(opts.StreamName, nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	updateDuplicates := opts.Duplicates != 0 && info.Config.Duplicates != opts.Duplicates
+	updateSubjects := !slices.Contains(info.Config.Subjects, streamSubject(opts))
+	cfg := info.Config
+	updateLimits := opts.Limits.apply(&cfg)
+	if !updateDuplicates && !updateSubjects && !updateLimits {
+		return info, nil
+	}
+
+	if updateDuplicates {
+		cfg.Duplicates = opts.Duplicates
+	}
+	if updateSubjects {
+		cfg.Subjects = append(slices.Clone(cfg.Subjects), streamSubject(opts))
+	}
+	if info, err = c.js.UpdateStream(&cfg, nats.Context(ctx)); err != nil {
+		return nil, err
+	}
+	c.logger.Info("updated nats stream", "streamName", opts.StreamName, "duplicates", cfg.Duplicates,
+		"subjects", cfg.Subjects, "maxMsgsPerSubject", cfg.MaxMsgsPerSubject, "maxMsgs", cfg.MaxMsgs,
+		"maxBytes", cfg.MaxBytes, "maxAge", cfg.MaxAge)
+	return info, nil
+}
+
 func (c *DefaultClient) Publish(ctx context.Context, opts *PublishOptions) error {
+	c.inflight.RLock()
+	defer c.inflight.RUnlock()
+
 	start := time.Now()
-	_, err := c.js.Publish(opts.Subj, opts.Data,
-		nats.Context(ctx),
-		nats.MsgId(opts.MsgId),
-	)
+	var err error
+	if opts.Mode == CorePublishMode {
+		msg := newMsg(opts)
+		msg.Header.Set(nats.MsgIdHdr, opts.MsgId)
+		err = c.conn.PublishMsg(msg)
+	} else {
+		err = c.publishToJetStream(ctx, opts)
+	}
 
 	duration := time.Since(start)
 	if err != nil {
 		if c.onMsgFailedEvent != nil {
 			c.onMsgFailedEvent(opts.Subj, duration)
 		}
+		if c.conn.IsReconnecting() {
+			c.notifyPublishError(c.onConnErrorEvent, opts.Subj)
+			return fmt.Errorf("%w: could not publish message to nats stream %v: %v", ErrClientReconnecting,
+				opts.Subj, err)
+		}
+		if c.conn.IsClosed() || isConnError(err) {
+			c.notifyPublishError(c.onConnErrorEvent, opts.Subj)
+			return fmt.Errorf("%w: could not publish message to nats stream %v: %v", ErrClientDisconnected,
+				opts.Subj, err)
+		}
+		if isNoStream(err) {
+			c.notifyPublishError(c.onBackpressureEvent, opts.Subj)
+			return fmt.Errorf("%w: subject %v: %v", ErrNoStream, opts.Subj, err)
+		}
+		if isBackpressure(err) {
+			c.notifyPublishError(c.onBackpressureEvent, opts.Subj)
+			return fmt.Errorf("%w: subject %v: %v", ErrBackpressure, opts.Subj, err)
+		}
+		if opts.Mode != CorePublishMode && isAckTimeout(err) {
+			c.notifyPublishError(c.onAckTimeoutEvent, opts.Subj)
+			return fmt.Errorf("%w: subject %v: %v", ErrAckTimeout, opts.Subj, err)
+		}
+		if isNack(err) {
+			c.notifyPublishError(c.onNackEvent, opts.Subj)
+			return fmt.Errorf("%w: subject %v: %v", ErrNack, opts.Subj, err)
+		}
 		return fmt.Errorf("could not publish message %v to nats stream %v: %v", opts.Data, opts.Subj, err)
 	}
 
-	c.logger.Debug("published message", "subj", opts.Subj, "data", string(opts.Data))
+	if c.metadataLogging {
+		c.logger.Debug("published message", "subj", opts.Subj, "size", len(opts.Data), "msgId", opts.MsgId)
+	} else if c.logger.Enabled(ctx, slog.LevelDebug) {
+		c.logger.Debug("published message", "subj", opts.Subj, "data", string(opts.Data))
+	}
 	if c.onMsgPublishedEvent != nil {
 		c.onMsgPublishedEvent(opts.Subj, duration)
 	}
 	return nil
 }
 
+func (c *DefaultClient) publishToJetStream(ctx context.Context, opts *PublishOptions) error {
+	if opts.AckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.AckTimeout)
+		defer cancel()
+	}
+
+	msg := newMsg(opts)
+	if opts.MsgTtl > 0 {
+		msg.Header.Set(msgTtlHdr, opts.MsgTtl.String())
+	}
+
+	pubOpts := []nats.PubOpt{nats.Context(ctx), nats.MsgId(opts.MsgId)}
+	if opts.ExpectedStream != "" {
+		pubOpts = append(pubOpts, nats.ExpectStream(opts.ExpectedStream))
+	}
+	if opts.RetryAttempts > 0 {
+		pubOpts = append(pubOpts, nats.RetryAttempts(opts.RetryAttempts))
+	}
+	if opts.RetryWait > 0 {
+		pubOpts = append(pubOpts, nats.RetryWait(opts.RetryWait))
+	}
+
+	ack, err := c.js.PublishMsg(msg, pubOpts...)
+	if err != nil {
+		return err
+	}
+	if c.onMsgAckedEvent != nil {
+		c.onMsgAckedEvent(ack.Stream, ack.Sequence)
+	}
+	// the stream already stored a message with the same id within its duplicates window, e.g. once change events are
+	// replayed after a restart
+	if ack.Duplicate && c.onMsgDuplicateEvent != nil {
+		c.onMsgDuplicateEvent(opts.Subj)
+	}
+	opts.Ack = ack
+	return nil
+}
+
+// isBackpressure reports whether the given publish error means that the limits of the stream are exceeded.
+func isBackpressure(err error) bool {
+	var apiErr *nats.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == jsStreamStoreFailedErrCode &&
+		strings.HasPrefix(apiErr.Description, "maximum")
+}
+
+// isNoStream reports whether the given publish error means that no stream responded, e.g. because it is not available
+// yet, or the stream was deleted.
+func isNoStream(err error) bool {
+	return errors.Is(err, nats.ErrNoStreamResponse) || errors.Is(err, nats.ErrNoResponders)
+}
+
+// isConnError reports whether the given publish error means that the connection to nats is lost.
+func isConnError(err error) bool {
+	return errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrConnectionDraining) ||
+		errors.Is(err, nats.ErrDisconnected)
+}
+
+// isAckTimeout reports whether the given publish error means that the stream did not acknowledge the message in time,
+// which may have been stored nonetheless.
+func isAckTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout)
+}
+
+// isNack reports whether the given publish error is a negative ack of the stream.
+func isNack(err error) bool {
+	var apiErr *nats.APIError
+	return errors.As(err, &apiErr)
+}
+
+func (c *DefaultClient) notifyPublishError(onPublishErrorEvent func(subj string), subj string) {
+	if onPublishErrorEvent != nil {
+		onPublishErrorEvent(subj)
+	}
+}
+
+func newMsg(opts *PublishOptions) *nats.Msg {
+	msg := nats.NewMsg(opts.Subj)
+	msg.Data = opts.Data
+	for key, value := range opts.Headers {
+		msg.Header.Set(key, value)
+	}
+	return msg
+}
+
+// WaitConnected blocks while the client is reconnecting to nats.
+// It returns ErrClientDisconnected if the connection is closed, as it will never be re-established.
+func (c *DefaultClient) WaitConnected(ctx context.Context) error {
+	if c.conn.IsClosed() {
+		return ErrClientDisconnected
+	}
+	c.mu.Lock()
+	reconnected := c.reconnected
+	c.mu.Unlock()
+	if reconnected == nil {
+		return nil
+	}
+
+	c.logger.Warn("waiting for nats to reconnect, publishing is paused")
+	select {
+	case <-reconnected:
+		if c.conn.IsClosed() {
+			return ErrClientDisconnected
+		}
+		c.logger.Info("nats reconnected, publishing is resumed")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reconnecting reports whether the client is waiting for the connection to be re-established.
+func (c *DefaultClient) Reconnecting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnected != nil
+}
+
+// MaxPayload returns the maximum size of the messages accepted by the nats server.
+func (c *DefaultClient) MaxPayload() int64 {
+	return c.conn.MaxPayload()
+}
+
+// PutObject stores the given data as an object with the given name in the given object store bucket, creating the
+// bucket if it does not exist.
+func (c *DefaultClient) PutObject(ctx context.Context, bucket, name string, data []byte) error {
+	obs, err := c.objectStore(bucket)
+	if err != nil {
+		return err
+	}
+	if _, err = obs.PutBytes(name, data, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("could not put object %v in nats object store %v: %v", name, bucket, err)
+	}
+	return nil
+}
+
+// PutObjectStream stores the content read from the given reader as an object with the given name in the given object
+// store bucket, creating the bucket if it does not exist, without holding the whole content in memory.
+func (c *DefaultClient) PutObjectStream(ctx context.Context, bucket, name string, r io.Reader) error {
+	obs, err := c.objectStore(bucket)
+	if err != nil {
+		return err
+	}
+	if _, err = obs.Put(&nats.ObjectMeta{Name: name}, r, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("could not put object %v in nats object store %v: %v", name, bucket, err)
+	}
+	return nil
+}
+
+// GetObject returns the content of the object with the given name in the given object store bucket, or
+// ErrObjectNotFound if it does not exist.
+func (c *DefaultClient) GetObject(ctx context.Context, bucket, name string) ([]byte, error) {
+	obs, err := c.objectStore(bucket)
+	if err != nil {
+		return nil, err
+	}
+	data, err := obs.GetBytes(name, nats.Context(ctx))
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil, fmt.Errorf("%w: %v in nats object store %v", ErrObjectNotFound, name, bucket)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get object %v from nats object store %v: %v", name, bucket, err)
+	}
+	return data, nil
+}
+
+// LastMsgHeader returns the sequence of the last message of the given stream along with the value of its given header,
+// or a zero sequence if the stream is empty.
+func (c *DefaultClient) LastMsgHeader(ctx context.Context, stream, header string) (uint64, string, error) {
+	info, err := c.js.StreamInfo(stream, nats.Context(ctx))
+	if err != nil {
+		return 0, "", fmt.Errorf("could not get nats stream %v: %v", stream, err)
+	}
+	seq := info.State.LastSeq
+	if seq == 0 {
+		return 0, "", nil
+	}
+	msg, err := c.js.GetMsg(stream, seq, nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) { // e.g. deleted since
+		return seq, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("could not get message %d of nats stream %v: %v", seq, stream, err)
+	}
+	return seq, msg.Header.Get(header), nil
+}
+
+// MsgIdsAfter returns the message ids of the messages of the given stream stored after the given sequence on the
+// subjects matching the given subject patterns, or on all of them if empty, at most the given limit of them, i.e. the
+// oldest ones if it is reached, and the last sequence of the stream. They are fetched by an ordered consumer
+// delivering their headers only.
+func (c *DefaultClient) MsgIdsAfter(ctx context.Context, stream string, subjects []string, seq uint64,
+	limit int) ([]string, uint64, error) {
+	info, err := c.js.StreamInfo(stream, nats.Context(ctx))
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not get nats stream %v: %v", stream, err)
+	}
+	lastSeq := info.State.LastSeq
+	if lastSeq <= seq || limit <= 0 {
+		return nil, lastSeq, nil
+	}
+	opts := []nats.SubOpt{nats.BindStream(stream), nats.OrderedConsumer(), nats.HeadersOnly(),
+		nats.StartSequence(max(seq+1, info.State.FirstSeq))}
+	if filters := filterSubjects(subjects); len(filters) > 0 {
+		opts = append(opts, nats.ConsumerFilterSubjects(filters...))
+	}
+	sub, err := c.js.SubscribeSync("", opts...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not consume nats stream %v: %v", stream, err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+	consumer, err := sub.ConsumerInfo()
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not get consumer of nats stream %v: %v", stream, err)
+	}
+
+	// the messages may be delivered already, and not pending anymore
+	total := consumer.NumPending + consumer.Delivered.Consumer
+	var msgIds []string
+	for pending := total; pending > 0 && len(msgIds) < limit; {
+		msg, err := nextMsg(ctx, sub)
+		if errors.Is(err, nats.ErrTimeout) { // the pending messages were deleted since
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not get messages of nats stream %v: %v", stream, err)
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not get messages of nats stream %v: %v", stream, err)
+		}
+		pending = meta.NumPending
+		if msgId := msg.Header.Get(nats.MsgIdHdr); msgId != "" {
+			msgIds = append(msgIds, msgId)
+		}
+	}
+	if len(msgIds) == limit && total > uint64(limit) {
+		c.logger.Warn("too many messages stored after sequence, only the oldest ones are fetched", "stream", stream,
+			"seq", seq, "pending", total, "limit", limit)
+	}
+	return msgIds, lastSeq, nil
+}
+
+// filterSubjects returns the given subject patterns but the ones matched by others, since the filters of a consumer
+// must not overlap.
+func filterSubjects(subjects []string) []string {
+	var filters []string
+	for i, subject := range subjects {
+		covered := slices.ContainsFunc(subjects[:i], func(other string) bool {
+			return subjectCovers(other, subject)
+		}) || slices.ContainsFunc(subjects[i+1:], func(other string) bool {
+			return subjectCovers(other, subject) && !subjectCovers(subject, other)
+		})
+		if !covered {
+			filters = append(filters, subject)
+		}
+	}
+	return filters
+}
+
+// nextMsg returns the next message of the given subscription, or nats.ErrTimeout if none is delivered within
+// msgIdsIdleTimeout.
+func nextMsg(ctx context.Context, sub *nats.Subscription) (*nats.Msg, error) {
+	idleCtx, cancel := context.WithTimeout(ctx, msgIdsIdleTimeout)
+	defer cancel()
+	msg, err := sub.NextMsgWithContext(idleCtx)
+	if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, nats.ErrTimeout
+	}
+	return msg, err
+}
+
+// DeleteObject deletes the object with the given name from the given object store bucket, if it exists.
+func (c *DefaultClient) DeleteObject(_ context.Context, bucket, name string) error {
+	obs, err := c.objectStore(bucket)
+	if err != nil {
+		return err
+	}
+	if err = obs.Delete(name); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return fmt.Errorf("could not delete object %v from nats object store %v: %v", name, bucket, err)
+	}
+	return nil
+}
+
+func (c *DefaultClient) objectStore(bucket string) (nats.ObjectStore, error) {
+	obs, err := c.js.ObjectStore(bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		obs, err = c.js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: bucket})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get nats object store %v: %v", bucket, err)
+	}
+	return obs, nil
+}
+
 type ClientOption func(*DefaultClient)
 
 func WithNatsUrl(url string) ClientOption {
@@ -149,6 +768,46 @@ func WithNatsUrl(url string) ClientOption {
 	}
 }
 
+func WithName(name string) ClientOption {
+	return func(c *DefaultClient) {
+		if name != "" {
+			c.name = name
+		}
+	}
+}
+
+// WithCredsFile sets the file holding the user JWT and the nkey seed used to authenticate, e.g. a '.creds' file.
+// The client reconnects each time the file changes.
+func WithCredsFile(credsFile string) ClientOption {
+	return func(c *DefaultClient) {
+		if credsFile != "" {
+			c.credsFile = credsFile
+		}
+	}
+}
+
+// WithTlsFiles sets the files holding the client certificate and its key, and the certificate authorities trusted to
+// verify the server. The client reconnects each time any of the files change.
+func WithTlsFiles(certFile, keyFile, caFile string) ClientOption {
+	return func(c *DefaultClient) {
+		if certFile != "" && keyFile != "" {
+			c.certFile, c.keyFile = certFile, keyFile
+		}
+		if caFile != "" {
+			c.caFile = caFile
+		}
+	}
+}
+
+// WithCredsWatchInterval sets how often the credentials and certificates files are checked for changes.
+func WithCredsWatchInterval(credsWatchInterval time.Duration) ClientOption {
+	return func(c *DefaultClient) {
+		if credsWatchInterval > 0 {
+			c.credsWatchInterval = credsWatchInterval
+		}
+	}
+}
+
 func WithLogger(logger *slog.Logger) ClientOption {
 	return func(c *DefaultClient) {
 		if logger != nil {
@@ -157,6 +816,14 @@ func WithLogger(logger *slog.Logger) ClientOption {
 	}
 }
 
+// WithMetadataLogging, if set, logs only the metadata of each message published at debug level, i.e. its subject, its
+// size and its message id, instead of its whole data, which is costly and verbose at high throughput.
+func WithMetadataLogging(metadataLogging bool) ClientOption {
+	return func(c *DefaultClient) {
+		c.metadataLogging = metadataLogging
+	}
+}
+
 func WithEventListeners(listeners ...EventListener) ClientOption {
 	return func(c *DefaultClient) {
 		for _, listener := range listeners {
@@ -167,6 +834,22 @@ func WithEventListeners(listeners ...EventListener) ClientOption {
 
 type EventListener func(*DefaultClient)
 
+func OnDisconnectEvent(onDisconnectEvent func(name string)) EventListener {
+	return func(c *DefaultClient) {
+		if onDisconnectEvent != nil {
+			c.onDisconnectEvent = onDisconnectEvent
+		}
+	}
+}
+
+func OnReconnectEvent(onReconnectEvent func(name string)) EventListener {
+	return func(c *DefaultClient) {
+		if onReconnectEvent != nil {
+			c.onReconnectEvent = onReconnectEvent
+		}
+	}
+}
+
 func OnMsgPublishedEvent(onMsgPublishedEvent func(subj string, duration time.Duration)) EventListener {
 	return func(c *DefaultClient) {
 		if onMsgPublishedEvent != nil {
@@ -175,6 +858,25 @@ func OnMsgPublishedEvent(onMsgPublishedEvent func(subj string, duration time.Dur
 	}
 }
 
+// OnMsgAckedEvent reports the stream and sequence of each message acknowledged by JetStream.
+func OnMsgAckedEvent(onMsgAckedEvent func(stream string, seq uint64)) EventListener {
+	return func(c *DefaultClient) {
+		if onMsgAckedEvent != nil {
+			c.onMsgAckedEvent = onMsgAckedEvent
+		}
+	}
+}
+
+// OnMsgDuplicateEvent reports the subject of each message acknowledged by JetStream as a duplicate of a message already
+// stored by the stream, which discarded it.
+func OnMsgDuplicateEvent(onMsgDuplicateEvent func(subj string)) EventListener {
+	return func(c *DefaultClient) {
+		if onMsgDuplicateEvent != nil {
+			c.onMsgDuplicateEvent = onMsgDuplicateEvent
+		}
+	}
+}
+
 func OnMsgFailedEvent(onMsgFailedEvent func(subj string, duration time.Duration)) EventListener {
 	return func(c *DefaultClient) {
 		if onMsgFailedEvent != nil {
@@ -182,3 +884,46 @@ func OnMsgFailedEvent(onMsgFailedEvent func(subj string, duration time.Duration)
 		}
 	}
 }
+
+func OnBackpressureEvent(onBackpressureEvent func(subj string)) EventListener {
+	return func(c *DefaultClient) {
+		if onBackpressureEvent != nil {
+			c.onBackpressureEvent = onBackpressureEvent
+		}
+	}
+}
+
+// OnNackEvent reports the subject of each message rejected by JetStream with a negative ack.
+func OnNackEvent(onNackEvent func(subj string)) EventListener {
+	return func(c *DefaultClient) {
+		if onNackEvent != nil {
+			c.onNackEvent = onNackEvent
+		}
+	}
+}
+
+// OnAckTimeoutEvent reports the subject of each message not acknowledged by JetStream in time.
+func OnAckTimeoutEvent(onAckTimeoutEvent func(subj string)) EventListener {
+	return func(c *DefaultClient) {
+		if onAckTimeoutEvent != nil {
+			c.onAckTimeoutEvent = onAckTimeoutEvent
+		}
+	}
+}
+
+// OnConnErrorEvent reports the subject of each message not published because the connection to nats is lost.
+func OnConnErrorEvent(onConnErrorEvent func(subj string)) EventListener {
+	return func(c *DefaultClient) {
+		if onConnErrorEvent != nil {
+			c.onConnErrorEvent = onConnErrorEvent
+		}
+	}
+}
+
+func OnCredsRotatedEvent(onCredsRotatedEvent func(name string)) EventListener {
+	return func(c *DefaultClient) {
+		if onCredsRotatedEvent != nil {
+			c.onCredsRotatedEvent = onCredsRotatedEvent
+		}
+	}
+}
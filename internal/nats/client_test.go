@@ -1,9 +1,12 @@
 package nats
 
 import (
+	"bytes"
 	"context"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,6 +54,15 @@ func TestNewDefaultClient(t *testing.T) {
 	t.Run("should return error cause nats is not available", func(t *testing.T) {
 		client, err := NewDefaultClient()
 
+		require.Nil(t, client)
+		require.Error(t, err)
+	})
+	t.Run("should return error cause credentials file cannot be read", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+
+		client, err := NewDefaultClient(WithCredsFile(filepath.Join(t.TempDir(), "missing.creds")))
+
 		require.Nil(t, client)
 		require.Error(t, err)
 	})
@@ -80,6 +92,18 @@ func TestClient_Monitor(t *testing.T) {
 
 		require.NoError(t, err)
 	})
+	t.Run("should return nil when the server responds before the deadline", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		err := client.Monitor(ctx)
+
+		require.NoError(t, err)
+	})
 	t.Run("should return error when client is disconnected", func(t *testing.T) {
 		s := natstest.RunDefaultServer()
 		defer s.Shutdown()
@@ -91,6 +115,45 @@ func TestClient_Monitor(t *testing.T) {
 
 		require.EqualError(t, err, ErrClientDisconnected.Error())
 	})
+	t.Run("should return error when client is reconnecting", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		defer client.conn.Close()
+		s.Shutdown()
+		require.Eventually(t, client.conn.IsReconnecting, 5*time.Second, 10*time.Millisecond)
+
+		err := client.Monitor(context.Background())
+
+		require.EqualError(t, err, ErrClientReconnecting.Error())
+	})
+}
+
+func TestClient_Details(t *testing.T) {
+	t.Run("should report state and round-trip time when client is connected", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+
+		details := client.Details()
+
+		require.Equal(t, "CONNECTED", details["state"])
+		require.Equal(t, uint64(0), details["reconnects"])
+		require.NotEmpty(t, details["rtt"])
+	})
+	t.Run("should report state without round-trip time when client is closed", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		client.conn.Close()
+
+		details := client.Details()
+
+		require.Equal(t, "CLOSED", details["state"])
+		require.NotContains(t, details, "rtt")
+	})
 }
 
 func TestClient_Close(t *testing.T) {
@@ -123,6 +186,106 @@ func TestClient_AddStream(t *testing.T) {
 		require.Contains(t, stream.Config.Subjects, "TEST.*")
 		require.Equal(t, nats.FileStorage, stream.Config.Storage)
 	})
+	t.Run("should add stream with the given duplicates window", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST", Duplicates: time.Minute})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, time.Minute, stream.Config.Duplicates)
+	})
+	t.Run("should update duplicates window of existing stream", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		_ = client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"})
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST", Duplicates: time.Hour})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, time.Hour, stream.Config.Duplicates)
+	})
+	t.Run("should add stream with the given limits", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST",
+			Limits: &StreamLimits{MaxMsgsPerSubject: 1, MaxBytes: 1 << 20, MaxAge: time.Hour}})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), stream.Config.MaxMsgsPerSubject)
+		require.Equal(t, int64(-1), stream.Config.MaxMsgs)
+		require.Equal(t, int64(1<<20), stream.Config.MaxBytes)
+		require.Equal(t, time.Hour, stream.Config.MaxAge)
+	})
+	t.Run("should update limits of existing stream", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		_ = client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"})
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST",
+			Limits: &StreamLimits{MaxMsgsPerSubject: 5, MaxMsgs: 1000}})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, int64(5), stream.Config.MaxMsgsPerSubject)
+		require.Equal(t, int64(1000), stream.Config.MaxMsgs)
+	})
+	t.Run("should add stream bound to the given subject", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "SHARED", Subject: "SHARED.*.*.*"})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("SHARED")
+		require.NoError(t, err)
+		require.Equal(t, []string{"SHARED.*.*.*"}, stream.Config.Subjects)
+	})
+	t.Run("should bind existing stream to missing subjects", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		_ = client.AddStream(context.Background(), &AddStreamOptions{StreamName: "MIXED"})
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "MIXED", Subject: "MIXED.*.*.*"})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("MIXED")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"MIXED.*", "MIXED.*.*.*"}, stream.Config.Subjects)
+	})
+	t.Run("should warn if duplicates window cannot cover the replay span", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		logs := &bytes.Buffer{}
+		client, _ := NewDefaultClient(WithLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST", Duplicates: time.Minute,
+			ReplaySpan: time.Hour})
+
+		require.NoError(t, err)
+		require.Contains(t, logs.String(), "duplicates window of nats stream cannot cover a restart")
+	})
 	t.Run("should return error cause nats is not available", func(t *testing.T) {
 		s := natstest.RunDefaultServer()
 		defer s.Shutdown()
@@ -134,6 +297,17 @@ func TestClient_AddStream(t *testing.T) {
 
 		require.Error(t, err)
 	})
+	t.Run("should return error cause nats does not support per-message ttls", func(t *testing.T) {
+		s := natstest.RunDefaultServer() // nats-server 2.10 ignores per-message ttls
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		defer func() { _ = client.js.DeleteStream("TTL") }()
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TTL", AllowMsgTtl: true})
+
+		require.ErrorIs(t, err, ErrMsgTtlUnsupported)
+	})
 }
 
 func TestClient_Publish(t *testing.T) {
@@ -142,6 +316,7 @@ func TestClient_Publish(t *testing.T) {
 		defer s.Shutdown()
 		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
 		client, _ := NewDefaultClient()
+		_ = client.js.DeleteStream("TEST") // the messages published by the other tests are persisted
 		_, _ = client.js.AddStream(&nats.StreamConfig{
 			Name:     "TEST",
 			Subjects: []string{"TEST.*"},
@@ -163,6 +338,146 @@ func TestClient_Publish(t *testing.T) {
 		require.Contains(t, msg.Header[nats.MsgIdHdr], "123")
 		require.Equal(t, []byte("test"), msg.Data)
 	})
+	t.Run("should publish message with the given jetstream options", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		_, _ = client.js.AddStream(&nats.StreamConfig{
+			Name:     "TEST",
+			Subjects: []string{"TEST.*"},
+			Storage:  nats.FileStorage,
+		})
+
+		err := client.Publish(context.Background(), &PublishOptions{
+			Subj:           "TEST.insert",
+			MsgId:          nats.NewInbox(), // avoid deduplication of messages stored by previous tests
+			Data:           []byte("test"),
+			ExpectedStream: "TEST",
+			AckTimeout:     5 * time.Second,
+			RetryAttempts:  1,
+			RetryWait:      100 * time.Millisecond,
+			MsgTtl:         time.Hour,
+			Headers:        map[string]string{"Connector-Instance-Id": "connector-0"},
+		})
+
+		require.NoError(t, err)
+		sub, err := client.js.SubscribeSync("TEST.insert", nats.OrderedConsumer(), nats.DeliverLast())
+		require.NoError(t, err)
+		msg, err := sub.NextMsg(5 * time.Second)
+		require.NoError(t, err)
+		require.Equal(t, "1h0m0s", msg.Header.Get(msgTtlHdr))
+		require.Equal(t, "TEST", msg.Header.Get(nats.ExpectedStreamHdr))
+		require.Equal(t, "connector-0", msg.Header.Get("Connector-Instance-Id"))
+	})
+	t.Run("should return error cause subject is not bound to the expected stream", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		var nacked []string
+		client, _ := NewDefaultClient(WithEventListeners(OnNackEvent(func(subj string) {
+			nacked = append(nacked, subj)
+		})))
+		_, _ = client.js.AddStream(&nats.StreamConfig{
+			Name:     "TEST",
+			Subjects: []string{"TEST.*"},
+			Storage:  nats.FileStorage,
+		})
+
+		err := client.Publish(context.Background(), &PublishOptions{
+			Subj:           "TEST.insert",
+			MsgId:          "123",
+			Data:           []byte("test"),
+			ExpectedStream: "OTHER",
+		})
+
+		require.ErrorIs(t, err, ErrNack)
+		require.Equal(t, []string{"TEST.insert"}, nacked)
+	})
+	t.Run("should return ack timeout error cause the stream does not acknowledge in time", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		var timedOut []string
+		client, _ := NewDefaultClient(WithEventListeners(OnAckTimeoutEvent(func(subj string) {
+			timedOut = append(timedOut, subj)
+		})))
+		// a responder which never acknowledges the messages
+		sub, err := client.conn.SubscribeSync("SILENT.insert")
+		require.NoError(t, err)
+		defer func() { _ = sub.Unsubscribe() }()
+
+		err = client.Publish(context.Background(), &PublishOptions{
+			Subj:       "SILENT.insert",
+			MsgId:      "123",
+			Data:       []byte("test"),
+			AckTimeout: 100 * time.Millisecond,
+		})
+
+		require.ErrorIs(t, err, ErrAckTimeout)
+		require.Equal(t, []string{"SILENT.insert"}, timedOut)
+	})
+	t.Run("should return error cause ack is not received in time", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+
+		err := client.Publish(context.Background(), &PublishOptions{
+			Subj:          "NOSTREAM.insert",
+			MsgId:         "123",
+			Data:          []byte("test"),
+			AckTimeout:    100 * time.Millisecond,
+			RetryAttempts: 1,
+			RetryWait:     50 * time.Millisecond,
+		})
+
+		require.Error(t, err)
+	})
+	t.Run("should publish message to core nats if publish mode is core", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		sub, err := client.conn.SubscribeSync("TEST.insert")
+		require.NoError(t, err)
+
+		err = client.Publish(context.Background(), &PublishOptions{
+			Subj:    "TEST.insert",
+			MsgId:   "123",
+			Data:    []byte("test"),
+			Mode:    CorePublishMode,
+			Headers: map[string]string{"Connector-Instance-Id": "connector-0"},
+		})
+
+		require.NoError(t, err)
+		msg, err := sub.NextMsg(5 * time.Second)
+		require.NoError(t, err)
+		require.Equal(t, "TEST.insert", msg.Subject)
+		require.Contains(t, msg.Header[nats.MsgIdHdr], "123")
+		require.Equal(t, "connector-0", msg.Header.Get("Connector-Instance-Id"))
+		require.Equal(t, []byte("test"), msg.Data)
+	})
+	t.Run("should run hooks after disconnecting and reconnecting", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+
+		var disconnects, reconnects []string
+		client, _ := NewDefaultClient(
+			WithName("nats-acme"),
+			WithEventListeners(
+				OnDisconnectEvent(func(name string) { disconnects = append(disconnects, name) }),
+				OnReconnectEvent(func(name string) { reconnects = append(reconnects, name) }),
+			),
+		)
+
+		client.onDisconnect(client.conn, nil)
+		client.onReconnect(client.conn)
+
+		require.Equal(t, []string{"nats-acme"}, disconnects)
+		require.Equal(t, []string{"nats-acme"}, reconnects)
+	})
 	t.Run("should run hook after publishing the message", func(t *testing.T) {
 		s := natstest.RunDefaultServer()
 		defer s.Shutdown()
@@ -192,6 +507,84 @@ func TestClient_Publish(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, 1, count)
 	})
+	t.Run("should report the stream sequence of the acknowledged message", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+
+		var stream string
+		var seq uint64
+		client, _ := NewDefaultClient(
+			WithEventListeners(OnMsgAckedEvent(func(ackStream string, ackSeq uint64) {
+				stream, seq = ackStream, ackSeq
+			})),
+		)
+		_ = client.js.DeleteStream("ACKED")
+		_, _ = client.js.AddStream(&nats.StreamConfig{
+			Name:     "ACKED",
+			Subjects: []string{"ACKED.*"},
+			Storage:  nats.FileStorage,
+		})
+		defer func() { _ = client.js.DeleteStream("ACKED") }()
+
+		for _, msgId := range []string{"1", "2"} {
+			err := client.Publish(context.Background(), &PublishOptions{Subj: "ACKED.insert", MsgId: msgId,
+				Data: []byte("test")})
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, "ACKED", stream)
+		require.Equal(t, uint64(2), seq)
+	})
+	t.Run("should report the subject of the messages acknowledged as duplicates", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+
+		var duplicates []string
+		client, _ := NewDefaultClient(
+			WithEventListeners(OnMsgDuplicateEvent(func(subj string) {
+				duplicates = append(duplicates, subj)
+			})),
+		)
+		_ = client.js.DeleteStream("DUPS")
+		_, _ = client.js.AddStream(&nats.StreamConfig{
+			Name:     "DUPS",
+			Subjects: []string{"DUPS.*"},
+			Storage:  nats.FileStorage,
+		})
+		defer func() { _ = client.js.DeleteStream("DUPS") }()
+
+		for _, msgId := range []string{"1", "2", "1"} {
+			opts := &PublishOptions{Subj: "DUPS.insert", MsgId: msgId, Data: []byte("test")}
+			require.NoError(t, client.Publish(context.Background(), opts))
+		}
+
+		require.Equal(t, []string{"DUPS.insert"}, duplicates)
+	})
+	t.Run("should log only the metadata of the published message", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		logs := &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		client, _ := NewDefaultClient(WithLogger(logger), WithMetadataLogging(true))
+		_, _ = client.js.AddStream(&nats.StreamConfig{
+			Name:     "TEST",
+			Subjects: []string{"TEST.*"},
+			Storage:  nats.FileStorage,
+		})
+
+		err := client.Publish(context.Background(), &PublishOptions{
+			Subj:  "TEST.insert",
+			MsgId: "123",
+			Data:  []byte("secret"),
+		})
+
+		require.NoError(t, err)
+		require.Contains(t, logs.String(), `"msg":"published message","subj":"TEST.insert","size":6,"msgId":"123"`)
+		require.NotContains(t, logs.String(), "secret")
+	})
 	t.Run("should return error cause nats is not available", func(t *testing.T) {
 		s := natstest.RunDefaultServer()
 		defer s.Shutdown()
@@ -231,4 +624,233 @@ func TestClient_Publish(t *testing.T) {
 		require.Error(t, err)
 		require.Equal(t, 1, count)
 	})
+	t.Run("should return backpressure error when the stream limits are exceeded", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		var backpressured []string
+		client, _ := NewDefaultClient(WithEventListeners(OnBackpressureEvent(func(subj string) {
+			backpressured = append(backpressured, subj)
+		})))
+		_ = client.js.DeleteStream("LIMITED")
+		_, err := client.js.AddStream(&nats.StreamConfig{
+			Name:     "LIMITED",
+			Subjects: []string{"LIMITED.*"},
+			MaxMsgs:  1,
+			Discard:  nats.DiscardNew,
+		})
+		require.NoError(t, err)
+
+		err = client.Publish(context.Background(), &PublishOptions{Subj: "LIMITED.insert", MsgId: "1", Data: []byte("1")})
+		require.NoError(t, err)
+		err = client.Publish(context.Background(), &PublishOptions{Subj: "LIMITED.insert", MsgId: "2", Data: []byte("2")})
+
+		require.ErrorIs(t, err, ErrBackpressure)
+		require.Equal(t, []string{"LIMITED.insert"}, backpressured)
+	})
+	t.Run("should return backpressure error when no stream responds", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+
+		err := client.Publish(context.Background(), &PublishOptions{Subj: "NOSTREAM.insert", MsgId: "1",
+			Data: []byte("1")})
+
+		require.ErrorIs(t, err, ErrBackpressure)
+		require.ErrorIs(t, err, ErrNoStream)
+	})
+}
+
+func TestClient_MaxPayload(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	client, _ := NewDefaultClient()
+
+	require.Equal(t, int64(natsserver.MAX_PAYLOAD_SIZE), client.MaxPayload())
+}
+
+func TestClient_PutObject(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+	client, _ := NewDefaultClient()
+	_ = client.js.DeleteObjectStore("OFFLOAD")
+
+	err := client.PutObject(context.Background(), "OFFLOAD", "msg-1", []byte("large payload"))
+
+	require.NoError(t, err)
+	obs, err := client.js.ObjectStore("OFFLOAD")
+	require.NoError(t, err)
+	data, err := obs.GetBytes("msg-1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("large payload"), data)
+}
+
+func TestClient_PutObjectStream(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+	client, _ := NewDefaultClient()
+	_ = client.js.DeleteObjectStore("FS")
+
+	err := client.PutObjectStream(context.Background(), "FS", "file-1", strings.NewReader("file content"))
+
+	require.NoError(t, err)
+	obs, err := client.js.ObjectStore("FS")
+	require.NoError(t, err)
+	data, err := obs.GetBytes("file-1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("file content"), data)
+
+	require.NoError(t, client.DeleteObject(context.Background(), "FS", "file-1"))
+	_, err = obs.GetBytes("file-1")
+	require.ErrorIs(t, err, nats.ErrObjectNotFound)
+	require.NoError(t, client.DeleteObject(context.Background(), "FS", "file-1"), "deleting a missing object is a no-op")
+}
+
+func TestClient_GetObject(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+	client, _ := NewDefaultClient()
+	_ = client.js.DeleteObjectStore("BACKUPS")
+	require.NoError(t, client.PutObject(context.Background(), "BACKUPS", "tokens.json", []byte("tokens")))
+
+	data, err := client.GetObject(context.Background(), "BACKUPS", "tokens.json")
+
+	require.NoError(t, err)
+	require.Equal(t, []byte("tokens"), data)
+	_, err = client.GetObject(context.Background(), "BACKUPS", "missing.json")
+	require.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestClient_LastMsgHeader(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+	client, _ := NewDefaultClient()
+	_ = client.js.DeleteStream("GUARDED")
+	require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "GUARDED"}))
+	defer func() { _ = client.js.DeleteStream("GUARDED") }()
+
+	seq, value, err := client.LastMsgHeader(context.Background(), "GUARDED", "Connector-Instance-Id")
+	require.NoError(t, err)
+	require.Zero(t, seq)
+	require.Empty(t, value)
+
+	for _, instanceId := range []string{"connector-0", "connector-1"} {
+		require.NoError(t, client.Publish(context.Background(), &PublishOptions{Subj: "GUARDED.insert",
+			MsgId: instanceId, Data: []byte("{}"), Headers: map[string]string{"Connector-Instance-Id": instanceId}}))
+	}
+	seq, value, err = client.LastMsgHeader(context.Background(), "GUARDED", "Connector-Instance-Id")
+
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), seq)
+	require.Equal(t, "connector-1", value)
+	_, _, err = client.LastMsgHeader(context.Background(), "MISSING", "Connector-Instance-Id")
+	require.Error(t, err)
+}
+
+func TestClient_MsgIdsAfter(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+	client, _ := NewDefaultClient()
+	_ = client.js.DeleteStream("IDEMPOTENT")
+	require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "IDEMPOTENT"}))
+	defer func() { _ = client.js.DeleteStream("IDEMPOTENT") }()
+
+	for i, msgId := range []string{"token-1", "token-2", "token-3", "token-4", "other-5", "token-6"} {
+		subj := "IDEMPOTENT.insert"
+		if strings.HasPrefix(msgId, "other") {
+			subj = "IDEMPOTENT.other" // e.g. published by another collection sharing the stream
+		}
+		opts := &PublishOptions{Subj: subj, MsgId: msgId, Data: []byte("{}")}
+		require.NoError(t, client.Publish(context.Background(), opts))
+		require.Equal(t, "IDEMPOTENT", opts.Ack.Stream)
+		require.Equal(t, uint64(i+1), opts.Ack.Sequence)
+	}
+	require.NoError(t, client.js.DeleteMsg("IDEMPOTENT", 3))
+	subjects := []string{"IDEMPOTENT.insert", "IDEMPOTENT.update"}
+
+	msgIds, lastSeq, err := client.MsgIdsAfter(context.Background(), "IDEMPOTENT", subjects, 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"token-2", "token-4", "token-6"}, msgIds)
+	require.Equal(t, uint64(6), lastSeq)
+
+	msgIds, _, err = client.MsgIdsAfter(context.Background(), "IDEMPOTENT", nil, 3, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"token-4", "other-5", "token-6"}, msgIds)
+
+	msgIds, _, err = client.MsgIdsAfter(context.Background(), "IDEMPOTENT", []string{"IDEMPOTENT.*",
+		"IDEMPOTENT.insert"}, 3, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"token-4", "other-5", "token-6"}, msgIds, "the overlapping patterns are merged")
+
+	msgIds, _, err = client.MsgIdsAfter(context.Background(), "IDEMPOTENT", subjects, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"token-1"}, msgIds)
+
+	msgIds, lastSeq, err = client.MsgIdsAfter(context.Background(), "IDEMPOTENT", subjects, 6, 10)
+	require.NoError(t, err)
+	require.Empty(t, msgIds)
+	require.Equal(t, uint64(6), lastSeq)
+
+	msgIds, _, err = client.MsgIdsAfter(context.Background(), "IDEMPOTENT", []string{"IDEMPOTENT.delete"}, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, msgIds)
+
+	_, _, err = client.MsgIdsAfter(context.Background(), "MISSING", subjects, 0, 10)
+	require.Error(t, err)
+}
+
+func TestClient_Reconnecting(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	client, _ := NewDefaultClient()
+
+	require.False(t, client.Reconnecting())
+	client.onDisconnect(client.conn, nil)
+	require.True(t, client.Reconnecting())
+	client.onReconnect(client.conn)
+	require.False(t, client.Reconnecting())
+}
+
+func TestClient_WaitConnected(t *testing.T) {
+	t.Run("should return nil when client is connected", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+
+		err := client.WaitConnected(context.Background())
+
+		require.NoError(t, err)
+	})
+	t.Run("should block until client is reconnected", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		client.onDisconnect(client.conn, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, client.WaitConnected(ctx), context.DeadlineExceeded)
+
+		go client.onReconnect(client.conn)
+		require.NoError(t, client.WaitConnected(context.Background()))
+	})
+	t.Run("should return error when client is disconnected", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		client.conn.Close()
+
+		err := client.WaitConnected(context.Background())
+
+		require.EqualError(t, err, ErrClientDisconnected.Error())
+	})
 }
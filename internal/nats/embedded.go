@@ -0,0 +1,55 @@
+package nats
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+)
+
+const embeddedServerReadyTimeout = 10 * time.Second
+
+// EmbeddedServer is a NATS server with JetStream enabled, running in-process.
+type EmbeddedServer struct {
+	server *natsserver.Server
+}
+
+// RunEmbeddedServer starts an embedded NATS server listening on the given host and port, whose JetStream data is
+// stored in the given directory. If the directory is empty, the data is stored in the temporary directory of the
+// system.
+func RunEmbeddedServer(host string, port int, storeDir string) (*EmbeddedServer, error) {
+	if storeDir == "" {
+		storeDir = filepath.Join(os.TempDir(), "mongodb-nats-connector")
+	}
+	server, err := natsserver.NewServer(&natsserver.Options{
+		ServerName: "connector-dev",
+		Host:       host,
+		Port:       port,
+		JetStream:  true,
+		StoreDir:   storeDir,
+		NoSigs:     true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create embedded nats server: %v", err)
+	}
+	go server.Start()
+	if !server.ReadyForConnections(embeddedServerReadyTimeout) {
+		server.Shutdown()
+		return nil, fmt.Errorf("could not start embedded nats server on %s:%d", host, port)
+	}
+	return &EmbeddedServer{server: server}, nil
+}
+
+// ClientURL returns the URL clients connect to the server with.
+func (s *EmbeddedServer) ClientURL() string {
+	return s.server.ClientURL()
+}
+
+// Close shuts the server down, once its JetStream data is flushed.
+func (s *EmbeddedServer) Close() error {
+	s.server.Shutdown()
+	s.server.WaitForShutdown()
+	return nil
+}
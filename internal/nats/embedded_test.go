@@ -0,0 +1,26 @@
+package nats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunEmbeddedServer(t *testing.T) {
+	s, err := RunEmbeddedServer("127.0.0.1", -1, t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+	c, err := NewDefaultClient(WithNatsUrl(s.ClientURL()))
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	require.NoError(t, c.AddStream(context.Background(), &AddStreamOptions{StreamName: "ORDERS"}))
+	opts := &PublishOptions{Subj: "ORDERS.insert", MsgId: "1", Data: []byte(`{}`)}
+	require.NoError(t, c.Publish(context.Background(), opts))
+	require.Equal(t, "ORDERS", opts.Ack.Stream)
+}
@@ -0,0 +1,149 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrKeyNotFound      = errors.New("nats key not found")
+	ErrRevisionMismatch = errors.New("nats key revision mismatch")
+)
+
+// KeyValue is a nats key-value bucket.
+type KeyValue interface {
+	// Get returns the value of the given key, or ErrKeyNotFound if it does not exist.
+	Get(key string) ([]byte, error)
+	// GetRevision returns the value of the given key along with its revision, or ErrKeyNotFound if it does not exist.
+	GetRevision(key string) ([]byte, uint64, error)
+	Put(key string, value []byte) error
+	// Update puts the given key only if its last revision is the given one, or if it does not exist when it is zero,
+	// and returns its new revision. Otherwise, it returns ErrRevisionMismatch.
+	Update(key string, value []byte, revision uint64) (uint64, error)
+	Delete(key string) error
+	// Keys returns the keys of the bucket, except the deleted ones.
+	Keys() ([]string, error)
+	// Watch returns the values of the given key, starting with its current value, if any, then each time it changes,
+	// until the given context is cancelled. Deletions are returned as nil values.
+	Watch(ctx context.Context, key string) (<-chan []byte, error)
+}
+
+type keyValue struct {
+	bucket string
+	kv     nats.KeyValue
+}
+
+// KeyValue returns the given key-value bucket, creating it if it does not exist.
+func (c *DefaultClient) KeyValue(bucket string) (KeyValue, error) {
+	kv, err := c.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = c.js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket, Storage: nats.FileStorage})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get nats key-value bucket %v: %v", bucket, err)
+	}
+	return &keyValue{bucket: bucket, kv: kv}, nil
+}
+
+func (b *keyValue) Get(key string) ([]byte, error) {
+	entry, err := b.kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: %v in bucket %v", ErrKeyNotFound, key, b.bucket)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get key %v from nats key-value bucket %v: %v", key, b.bucket, err)
+	}
+	return entry.Value(), nil
+}
+
+func (b *keyValue) GetRevision(key string) ([]byte, uint64, error) {
+	entry, err := b.kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, 0, fmt.Errorf("%w: %v in bucket %v", ErrKeyNotFound, key, b.bucket)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not get key %v from nats key-value bucket %v: %v", key, b.bucket, err)
+	}
+	return entry.Value(), entry.Revision(), nil
+}
+
+func (b *keyValue) Update(key string, value []byte, revision uint64) (uint64, error) {
+	var err error
+	if revision == 0 {
+		revision, err = b.kv.Create(key, value)
+	} else {
+		revision, err = b.kv.Update(key, value, revision)
+	}
+	if errors.Is(err, nats.ErrKeyExists) { // also returned once the last revision is another one
+		return 0, fmt.Errorf("%w: %v in bucket %v", ErrRevisionMismatch, key, b.bucket)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not update key %v in nats key-value bucket %v: %v", key, b.bucket, err)
+	}
+	return revision, nil
+}
+
+func (b *keyValue) Put(key string, value []byte) error {
+	if _, err := b.kv.Put(key, value); err != nil {
+		return fmt.Errorf("could not put key %v in nats key-value bucket %v: %v", key, b.bucket, err)
+	}
+	return nil
+}
+
+func (b *keyValue) Delete(key string) error {
+	if err := b.kv.Delete(key); err != nil {
+		return fmt.Errorf("could not delete key %v from nats key-value bucket %v: %v", key, b.bucket, err)
+	}
+	return nil
+}
+
+func (b *keyValue) Keys() ([]string, error) {
+	keys, err := b.kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list keys of nats key-value bucket %v: %v", b.bucket, err)
+	}
+	return keys, nil
+}
+
+func (b *keyValue) Watch(ctx context.Context, key string) (<-chan []byte, error) {
+	watcher, err := b.kv.Watch(key, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("could not watch key %v of nats key-value bucket %v: %v", key, b.bucket, err)
+	}
+	values := make(chan []byte)
+	go func() {
+		defer close(values)
+		defer func() { _ = watcher.Stop() }()
+		for {
+			var entry nats.KeyValueEntry
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+				entry = e
+			}
+			if entry == nil {
+				continue // marks the end of the initial values
+			}
+			var value []byte
+			if entry.Operation() == nats.KeyValuePut {
+				value = entry.Value()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case values <- value:
+			}
+		}
+	}()
+	return values, nil
+}
@@ -0,0 +1,116 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_KeyValue(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+	client, _ := NewDefaultClient()
+	_ = client.js.DeleteKeyValue("KV_TEST")
+
+	kv, err := client.KeyValue("KV_TEST")
+	require.NoError(t, err)
+
+	t.Run("should return no keys if the bucket is empty", func(t *testing.T) {
+		keys, err := kv.Keys()
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+	t.Run("should put and get the given key", func(t *testing.T) {
+		require.NoError(t, kv.Put("orders", []byte("collections: []")))
+
+		value, err := kv.Get("orders")
+
+		require.NoError(t, err)
+		require.Equal(t, []byte("collections: []"), value)
+		keys, err := kv.Keys()
+		require.NoError(t, err)
+		require.Equal(t, []string{"orders"}, keys)
+	})
+	t.Run("should return error if the key does not exist", func(t *testing.T) {
+		value, err := kv.Get("unknown")
+
+		require.Nil(t, value)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+	t.Run("should delete the given key", func(t *testing.T) {
+		require.NoError(t, kv.Delete("orders"))
+
+		_, err := kv.Get("orders")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		keys, err := kv.Keys()
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+	t.Run("should update the given key only if its revision did not change", func(t *testing.T) {
+		revision, err := kv.Update("lease", []byte("connector-0"), 0)
+		require.NoError(t, err)
+
+		_, err = kv.Update("lease", []byte("connector-1"), 0)
+		require.ErrorIs(t, err, ErrRevisionMismatch)
+		updated, err := kv.Update("lease", []byte("connector-0"), revision)
+		require.NoError(t, err)
+		_, err = kv.Update("lease", []byte("connector-1"), revision)
+		require.ErrorIs(t, err, ErrRevisionMismatch)
+
+		value, got, err := kv.GetRevision("lease")
+		require.NoError(t, err)
+		require.Equal(t, []byte("connector-0"), value)
+		require.Equal(t, updated, got)
+		require.NoError(t, kv.Delete("lease"))
+		_, _, err = kv.GetRevision("lease")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		_, err = kv.Update("lease", []byte("connector-1"), 0)
+		require.NoError(t, err, "a deleted key can be created again")
+		require.NoError(t, kv.Delete("lease"))
+	})
+	t.Run("should watch the given key", func(t *testing.T) {
+		require.NoError(t, kv.Put("settings", []byte("v1")))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		values, err := kv.Watch(ctx, "settings")
+		require.NoError(t, err)
+
+		next := func() []byte {
+			select {
+			case value := <-values:
+				return value
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "no value watched")
+				return nil
+			}
+		}
+		require.Equal(t, []byte("v1"), next())
+		require.NoError(t, kv.Put("settings", []byte("v2")))
+		require.Equal(t, []byte("v2"), next())
+		require.NoError(t, kv.Delete("settings"))
+		require.Nil(t, next())
+
+		cancel()
+		require.Eventually(t, func() bool {
+			_, ok := <-values
+			return !ok
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+	t.Run("should get the existing bucket", func(t *testing.T) {
+		require.NoError(t, kv.Put("users", []byte("collections: []")))
+
+		other, err := client.KeyValue("KV_TEST")
+
+		require.NoError(t, err)
+		value, err := other.Get("users")
+		require.NoError(t, err)
+		require.Equal(t, []byte("collections: []"), value)
+	})
+}
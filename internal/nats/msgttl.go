@@ -0,0 +1,69 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrMsgTtlUnsupported is returned when a stream cannot allow per-message TTLs, which are ignored by older servers.
+var ErrMsgTtlUnsupported = errors.New("nats server does not support per-message TTLs, nats-server 2.11 or later is required")
+
+// allowMsgTtlField is the field of the stream configuration allowing per-message TTLs, unknown to this version of the
+// nats client, hence set through the jetstream api directly.
+const allowMsgTtlField = "allow_msg_ttl"
+
+// streamApiResponse is the response of the jetstream api to the info and update requests of a stream.
+type streamApiResponse struct {
+	Config map[string]any `json:"config"`
+	Error  *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// allowMsgTtl allows per-message TTLs on the given stream, if they are not already. It returns ErrMsgTtlUnsupported if
+// the server ignores them.
+func (c *DefaultClient) allowMsgTtl(ctx context.Context, stream string) error {
+	info, err := c.streamApiRequest(ctx, "$JS.API.STREAM.INFO."+stream, nil)
+	if err != nil {
+		return err
+	}
+	if allowed, _ := info.Config[allowMsgTtlField].(bool); allowed {
+		return nil
+	}
+	info.Config[allowMsgTtlField] = true
+	cfg, err := json.Marshal(info.Config)
+	if err != nil {
+		return err
+	}
+	updated, err := c.streamApiRequest(ctx, "$JS.API.STREAM.UPDATE."+stream, cfg)
+	if err != nil {
+		return err
+	}
+	if allowed, _ := updated.Config[allowMsgTtlField].(bool); !allowed {
+		return fmt.Errorf("%w: stream %v", ErrMsgTtlUnsupported, stream)
+	}
+	c.logger.Info("allowed per-message TTLs on nats stream", "streamName", stream)
+	return nil
+}
+
+// streamApiRequest sends the given request to the given subject of the jetstream api, and returns its response.
+func (c *DefaultClient) streamApiRequest(ctx context.Context, subj string, data []byte) (*streamApiResponse, error) {
+	msg, err := c.conn.RequestWithContext(ctx, subj, data)
+	if err != nil {
+		return nil, err
+	}
+	var resp streamApiResponse
+	if err = json.Unmarshal(msg.Data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s (%d)", resp.Error.Description, resp.Error.Code)
+	}
+	if resp.Config == nil {
+		return nil, errors.New("no stream configuration returned")
+	}
+	return &resp, nil
+}
@@ -0,0 +1,91 @@
+package nats
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	defaultCredsWatchInterval = 10 * time.Second
+	credsDrainTimeout         = 10 * time.Second
+)
+
+// fileWatcher detects changes of the given files by comparing their modification time and size, which also detects
+// files replaced by atomically swapping symlinks, e.g. secrets mounted by Kubernetes.
+type fileWatcher struct {
+	files  []string
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newFileWatcher(files ...string) *fileWatcher {
+	w := &fileWatcher{files: files, stamps: make(map[string]fileStamp, len(files))}
+	w.changed()
+	return w
+}
+
+// changed reports whether any of the files changed since the last call. Files that cannot be read, e.g. while they
+// are being replaced, are not considered changed.
+func (w *fileWatcher) changed() bool {
+	changed := false
+	for _, file := range w.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+		if last, ok := w.stamps[file]; ok && last != stamp {
+			changed = true
+		}
+		w.stamps[file] = stamp
+	}
+	return changed
+}
+
+// watchCredentials reconnects the client each time its credentials or certificates files change, until the client is
+// closed. The nats connection reads them again on each reconnect.
+func (c *DefaultClient) watchCredentials(watcher *fileWatcher) {
+	ticker := time.NewTicker(c.credsWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if watcher.changed() {
+				c.rotateCredentials()
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// rotateCredentials waits for the in-flight publishes to complete, while holding back new ones, then reconnects with
+// the new credentials. If the in-flight publishes do not complete in time, it reconnects anyway.
+func (c *DefaultClient) rotateCredentials() {
+	c.logger.Info("nats credentials changed, draining in-flight publishes before reconnecting")
+	drained := make(chan struct{})
+	go func() {
+		c.inflight.Lock()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(credsDrainTimeout):
+		c.logger.Warn("in-flight publishes could not be drained in time, reconnecting anyway",
+			"timeout", credsDrainTimeout)
+	}
+	if err := c.conn.ForceReconnect(); err != nil {
+		c.logger.Error("could not reconnect to nats with the new credentials", "err", err)
+	}
+	if c.onCredsRotatedEvent != nil {
+		c.onCredsRotatedEvent(c.name)
+	}
+	go func() {
+		<-drained
+		c.inflight.Unlock()
+	}()
+}
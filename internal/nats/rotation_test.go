@@ -0,0 +1,92 @@
+package nats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fileWatcher_changed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "user.creds")
+	require.NoError(t, os.WriteFile(file, []byte("old"), 0600))
+	watcher := newFileWatcher(file, filepath.Join(t.TempDir(), "missing.pem"))
+
+	require.False(t, watcher.changed())
+
+	require.NoError(t, os.WriteFile(file, []byte("rotated"), 0600))
+	require.True(t, watcher.changed())
+	require.False(t, watcher.changed())
+
+	require.NoError(t, os.Remove(file))
+	require.False(t, watcher.changed())
+}
+
+func TestClient_rotateCredentials(t *testing.T) {
+	t.Run("should reconnect once the in-flight publishes complete", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+
+		var rotations atomic.Int32
+		client, err := NewDefaultClient(WithName("nats-acme"), WithEventListeners(
+			OnCredsRotatedEvent(func(name string) {
+				require.Equal(t, "nats-acme", name)
+				rotations.Add(1)
+			}),
+		))
+		require.NoError(t, err)
+		defer func() { _ = client.Close() }()
+
+		client.inflight.RLock() // simulate an in-flight publish
+		go client.rotateCredentials()
+		time.Sleep(100 * time.Millisecond)
+		require.Zero(t, client.conn.Stats().Reconnects)
+
+		client.inflight.RUnlock()
+		require.Eventually(t, func() bool {
+			return client.conn.Stats().Reconnects == 1 && client.conn.IsConnected()
+		}, 5*time.Second, 10*time.Millisecond)
+		require.EqualValues(t, 1, rotations.Load())
+
+		_, _ = client.js.AddStream(&nats.StreamConfig{Name: "ROTATED", Subjects: []string{"ROTATED.*"}})
+		err = client.Publish(context.Background(), &PublishOptions{Subj: "ROTATED.insert", MsgId: "1",
+			Data: []byte("test")})
+		require.NoError(t, err)
+	})
+}
+
+func TestClient_watchCredentials(t *testing.T) {
+	t.Run("should rotate credentials once the watched files change", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		file := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(file, []byte("old"), 0600))
+
+		var rotations atomic.Int32
+		client, err := NewDefaultClient(
+			WithCredsWatchInterval(10*time.Millisecond),
+			WithEventListeners(OnCredsRotatedEvent(func(string) { rotations.Add(1) })),
+		)
+		require.NoError(t, err)
+		go client.watchCredentials(newFileWatcher(file))
+
+		time.Sleep(50 * time.Millisecond)
+		require.Zero(t, rotations.Load())
+
+		require.NoError(t, os.WriteFile(file, []byte("rotated"), 0600))
+		require.Eventually(t, func() bool {
+			return rotations.Load() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, client.Close())
+	})
+}
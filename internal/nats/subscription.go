@@ -0,0 +1,56 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// subscriptionBuffer is the number of messages buffered by a subscription before the slow ones are dropped.
+const subscriptionBuffer = 64
+
+// Msg is a message received from a plain nats subject.
+type Msg struct {
+	Subj    string
+	Data    []byte
+	Headers map[string]string
+	// Reply is the subject a reply is expected on, if any, e.g. when published with a request.
+	Reply string
+}
+
+// Subscribe returns the messages published to the given subject, which may hold wildcards, until the given context is
+// cancelled. The subscription survives the reconnects of the client, including the ones of the credentials rotation.
+func (c *DefaultClient) Subscribe(ctx context.Context, subj string) (<-chan *Msg, error) {
+	received := make(chan *nats.Msg, subscriptionBuffer)
+	sub, err := c.conn.ChanSubscribe(subj, received)
+	if err != nil {
+		return nil, fmt.Errorf("could not subscribe to nats subject %v: %v", subj, err)
+	}
+	msgs := make(chan *Msg)
+	go func() {
+		defer close(msgs)
+		defer func() { _ = sub.Unsubscribe() }()
+		for {
+			var m *nats.Msg
+			select {
+			case <-ctx.Done():
+				return
+			case m = <-received:
+			}
+			msg := &Msg{Subj: m.Subject, Data: m.Data, Reply: m.Reply}
+			if len(m.Header) > 0 {
+				msg.Headers = make(map[string]string, len(m.Header))
+				for k := range m.Header {
+					msg.Headers[k] = m.Header.Get(k)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case msgs <- msg:
+			}
+		}
+	}()
+	return msgs, nil
+}
@@ -0,0 +1,58 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Subscribe(t *testing.T) {
+	s := natstest.RunDefaultServer()
+	defer s.Shutdown()
+	client, _ := NewDefaultClient()
+	defer func() { _ = client.Close() }()
+
+	t.Run("should return the messages published to the given subject", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		msgs, err := client.Subscribe(ctx, "$CONNECTOR.control.>")
+		require.NoError(t, err)
+
+		require.NoError(t, client.conn.PublishMsg(&nats.Msg{
+			Subject: "$CONNECTOR.control.all",
+			Reply:   "_INBOX.reply",
+			Data:    []byte(`{"command":"pause"}`),
+			Header:  nats.Header{"Authorization": []string{"Bearer s3cr3t"}},
+		}))
+
+		select {
+		case msg := <-msgs:
+			require.Equal(t, &Msg{
+				Subj:    "$CONNECTOR.control.all",
+				Data:    []byte(`{"command":"pause"}`),
+				Headers: map[string]string{"Authorization": "Bearer s3cr3t"},
+				Reply:   "_INBOX.reply",
+			}, msg)
+		case <-time.After(1 * time.Second):
+			require.Fail(t, "no message received")
+		}
+	})
+	t.Run("should stop the subscription once the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		msgs, err := client.Subscribe(ctx, "$CONNECTOR.control")
+		require.NoError(t, err)
+
+		cancel()
+
+		select {
+		case _, ok := <-msgs:
+			require.False(t, ok)
+		case <-time.After(1 * time.Second):
+			require.Fail(t, "the subscription was not stopped")
+		}
+	})
+}
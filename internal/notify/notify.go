@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// postTimeout bounds the time a webhook is given to accept an alert.
+const postTimeout = 10 * time.Second
+
+// Kind represents the kind of condition an alert reports.
+type Kind string
+
+const (
+	// WatcherFailedAlert reports that the watcher of a collection stopped with an error.
+	WatcherFailedAlert Kind = "watcherFailed"
+
+	// TokenExpiredAlert reports that the change stream of a collection cannot be resumed, since its resume token fell
+	// off the oplog.
+	TokenExpiredAlert Kind = "tokenExpired"
+
+	// CircuitOpenAlert reports that publishing the change events of a collection is paused, since the stream rejects
+	// them.
+	CircuitOpenAlert Kind = "circuitOpen"
+
+	// OplogHeadroomLowAlert reports that the resume token of a collection is about to fall off the oplog.
+	OplogHeadroomLowAlert Kind = "oplogHeadroomLow"
+
+	// DualPublisherAlert reports that another connector is publishing to a stream of the connector, which stops.
+	DualPublisherAlert Kind = "dualPublisher"
+)
+
+var Kinds = []Kind{
+	WatcherFailedAlert,
+	TokenExpiredAlert,
+	CircuitOpenAlert,
+	OplogHeadroomLowAlert,
+	DualPublisherAlert,
+}
+
+// Format represents the payload alerts are posted with.
+type Format string
+
+const (
+	// JsonFormat posts the alerts as they are, encoded as json.
+	JsonFormat Format = "json"
+
+	// SlackFormat posts the alerts as a text message, as expected by Slack incoming webhooks and compatible services.
+	SlackFormat Format = "slack"
+)
+
+var Formats = []Format{
+	JsonFormat,
+	SlackFormat,
+}
+
+// Alert reports a condition requiring attention.
+type Alert struct {
+	Kind       Kind      `json:"alert"`
+	InstanceId string    `json:"instanceId"`
+	Database   string    `json:"database,omitempty"`
+	Collection string    `json:"collection,omitempty"`
+	Message    string    `json:"message"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// text returns the alert as a single line of text.
+func (a *Alert) text() string {
+	text := fmt.Sprintf("[%v] %v", a.InstanceId, a.Kind)
+	if a.Collection != "" {
+		text += fmt.Sprintf(" on %v.%v", a.Database, a.Collection)
+	}
+	text += ": " + a.Message
+	if a.Error != "" {
+		text += ": " + a.Error
+	}
+	return text
+}
+
+// Webhook is an url alerts are posted to.
+type Webhook struct {
+	Url    string
+	Format Format
+	// Alerts are the kinds of alerts posted to the webhook. If empty, all of them are.
+	Alerts []Kind
+}
+
+// ValidUrl reports whether the given webhook url is an absolute http or https url.
+func ValidUrl(webhookUrl string) bool {
+	u, err := url.Parse(webhookUrl)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (w *Webhook) accepts(kind Kind) bool {
+	if len(w.Alerts) == 0 {
+		return true
+	}
+	for _, k := range w.Alerts {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Webhook) payload(alert *Alert) any {
+	if w.Format == SlackFormat {
+		return map[string]string{"text": alert.text()}
+	}
+	return alert
+}
+
+// Notifier posts alerts to webhooks. Alerts are best-effort: they are posted asynchronously, and the ones which cannot
+// be posted are logged and not retried.
+type Notifier struct {
+	webhooks []*Webhook
+	logger   *slog.Logger
+	client   *http.Client
+	wg       sync.WaitGroup
+}
+
+// New creates a new Notifier, posting alerts to the given webhooks.
+func New(logger *slog.Logger, webhooks ...*Webhook) *Notifier {
+	return &Notifier{
+		webhooks: webhooks,
+		logger:   logger,
+		client:   &http.Client{Timeout: postTimeout},
+	}
+}
+
+// Notify posts the given alert to the webhooks accepting its kind.
+func (n *Notifier) Notify(alert *Alert) {
+	for _, w := range n.webhooks {
+		if w.accepts(alert.Kind) {
+			n.Post(w.Url, w.payload(alert))
+		}
+	}
+}
+
+// Post posts the given payload, encoded as json, to the given webhook url.
+func (n *Notifier) Post(webhookUrl string, payload any) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.post(webhookUrl, payload); err != nil {
+			n.logger.Warn("could not post alert", "err", err)
+		}
+	}()
+}
+
+// Wait waits for the alerts being posted, e.g. before the process exits.
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+func (n *Notifier) post(webhookUrl string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("could not encode alert: %v", err)
+	}
+	resp, err := n.client.Post(webhookUrl, "application/json", bytes.NewReader(data))
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) { // webhook urls often embed secrets, e.g. the ones of slack
+		return urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidUrl(t *testing.T) {
+	require.True(t, ValidUrl("https://hooks.slack.com/services/T000/B000/XXX"))
+	require.True(t, ValidUrl("http://alerts:8080/connector"))
+	require.False(t, ValidUrl(""))
+	require.False(t, ValidUrl("alerts.example.com/connector"))
+	require.False(t, ValidUrl("ftp://alerts.example.com/connector"))
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	alert := &Alert{Kind: WatcherFailedAlert, InstanceId: "connector-0", Database: "shop", Collection: "orders",
+		Message: "collection watcher failed", Error: "collection dropped",
+		Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	newWebhook := func(t *testing.T, status int) (*httptest.Server, chan string) {
+		bodies := make(chan string, 10)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			bodies <- string(body)
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(server.Close)
+		return server, bodies
+	}
+
+	t.Run("should post the alert as json", func(t *testing.T) {
+		server, bodies := newWebhook(t, http.StatusOK)
+		n := New(slog.Default(), &Webhook{Url: server.URL, Format: JsonFormat})
+
+		n.Notify(alert)
+		n.Wait()
+
+		require.JSONEq(t, `{"alert":"watcherFailed","instanceId":"connector-0","database":"shop","collection":"orders",
+			"message":"collection watcher failed","error":"collection dropped","time":"2024-05-01T12:00:00Z"}`, <-bodies)
+	})
+	t.Run("should post the alert as a slack message", func(t *testing.T) {
+		server, bodies := newWebhook(t, http.StatusOK)
+		n := New(slog.Default(), &Webhook{Url: server.URL, Format: SlackFormat})
+
+		n.Notify(alert)
+		n.Wait()
+
+		var msg map[string]string
+		require.NoError(t, json.Unmarshal([]byte(<-bodies), &msg))
+		require.Equal(t, map[string]string{
+			"text": "[connector-0] watcherFailed on shop.orders: collection watcher failed: collection dropped",
+		}, msg)
+	})
+	t.Run("should only post the alerts accepted by the webhook", func(t *testing.T) {
+		server, bodies := newWebhook(t, http.StatusOK)
+		n := New(slog.Default(), &Webhook{Url: server.URL, Alerts: []Kind{TokenExpiredAlert}})
+
+		n.Notify(alert)
+		n.Notify(&Alert{Kind: TokenExpiredAlert, InstanceId: "connector-0"})
+		n.Wait()
+
+		require.Len(t, bodies, 1)
+		require.Contains(t, <-bodies, `"alert":"tokenExpired"`)
+	})
+	t.Run("should not fail if the webhook responds with an error", func(t *testing.T) {
+		server, bodies := newWebhook(t, http.StatusInternalServerError)
+		n := New(slog.Default(), &Webhook{Url: server.URL})
+
+		n.Notify(alert)
+		n.Wait()
+
+		require.Len(t, bodies, 1)
+	})
+}
+
+func TestNotifier_post(t *testing.T) {
+	t.Run("should not leak the webhook url if it cannot be reached", func(t *testing.T) {
+		n := New(slog.Default())
+
+		err := n.post("http://127.0.0.1:1/services/secret", map[string]string{"text": "hello"})
+
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), "secret")
+	})
+	t.Run("should return error if the webhook responds with an error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+		n := New(slog.Default())
+
+		require.EqualError(t, n.post(server.URL, map[string]string{}), "webhook responded with 403 Forbidden")
+	})
+}
@@ -2,6 +2,8 @@ package prometheus
 
 import (
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -10,10 +12,48 @@ import (
 )
 
 type MongoRegisterer struct {
-	mongoCommandsStarted   *prometheus.CounterVec
-	mongoCommandsSucceeded *prometheus.CounterVec
-	mongoCommandsFailed    *prometheus.CounterVec
-	mongoCommandDuration   *prometheus.HistogramVec
+	mongoCommandsStarted         *prometheus.CounterVec
+	mongoCommandsSucceeded       *prometheus.CounterVec
+	mongoCommandsFailed          *prometheus.CounterVec
+	mongoCommandDuration         *prometheus.HistogramVec
+	mongoTokenTimestamp          *prometheus.GaugeVec
+	mongoTokenOplogHeadroom      *prometheus.GaugeVec
+	mongoOplogHeadroomWarnings   *prometheus.CounterVec
+	mongoTokenSaveRetries        *prometheus.CounterVec
+	mongoTokenSaveFailures       *prometheus.CounterVec
+	mongoOplogOldestTimestamp    prometheus.Gauge
+	mongoChangeEvents            *prometheus.CounterVec
+	mongoChangeEventBytes        *prometheus.CounterVec
+	mongoChangeEventsOversize    *prometheus.CounterVec
+	mongoChangeEventsMalformed   *prometheus.CounterVec
+	mongoChangeEventsQuarantined *prometheus.CounterVec
+	mongoChangeEventsSkipped     *prometheus.CounterVec
+	mongoChangeEventsFiltered    *prometheus.CounterVec
+	mongoChangeEventsOutOfOrder  *prometheus.CounterVec
+	mongoConnsOpen               prometheus.Gauge
+	mongoReconnects              prometheus.Counter
+	mongoChangeStreamsOpen       *prometheus.GaugeVec
+
+	// tokenTimes and oplogOldest are used to compute the oplog headroom of the resume token of each collection,
+	// whenever either of them changes.
+	mu          sync.Mutex
+	tokenTimes  map[collection]time.Time
+	oplogOldest time.Time
+}
+
+// operations are the operation types reported by change event metrics, any other operation type is reported as other.
+var operations = map[string]struct{}{
+	"insert":  {},
+	"update":  {},
+	"delete":  {},
+	"replace": {},
+}
+
+const otherOperation = "other"
+
+type collection struct {
+	dbName   string
+	collName string
 }
 
 func NewMongoRegisterer(registerer prometheus.Registerer) *MongoRegisterer {
@@ -47,6 +87,125 @@ func NewMongoRegisterer(registerer prometheus.Registerer) *MongoRegisterer {
 			},
 			[]string{"database", "command"},
 		),
+		mongoTokenTimestamp: promauto.With(registerer).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mongodb_resume_token_timestamp_seconds",
+				Help: "Cluster time of the last stored resume token, in seconds since the epoch.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoTokenOplogHeadroom: promauto.With(registerer).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mongodb_resume_token_oplog_headroom_seconds",
+				Help: "Time between the oldest oplog entry and the last stored resume token, in seconds. " +
+					"The resume token cannot be resumed once it is negative.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoOplogHeadroomWarnings: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_resume_token_oplog_headroom_warnings_total",
+				Help: "Total number of times the oplog headroom of the last stored resume token was found below the " +
+					"warning threshold.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoTokenSaveRetries: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_resume_token_save_retries_total",
+				Help: "Total number of retried resume token saves.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoTokenSaveFailures: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_resume_token_save_failures_total",
+				Help: "Total number of failed resume token saves.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoOplogOldestTimestamp: promauto.With(registerer).NewGauge(
+			prometheus.GaugeOpts{
+				Name: "mongodb_oplog_oldest_entry_timestamp_seconds",
+				Help: "Time of the oldest oplog entry, in seconds since the epoch.",
+			},
+		),
+		mongoChangeEvents: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_events_published_total",
+				Help: "Total number of published change events.",
+			},
+			[]string{"database", "collection", "operation"},
+		),
+		mongoChangeEventBytes: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_event_bytes_published_total",
+				Help: "Total number of payload bytes of published change events.",
+			},
+			[]string{"database", "collection", "operation"},
+		),
+		mongoChangeEventsOversize: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_events_oversized_total",
+				Help: "Total number of change events exceeding the maximum payload, by the policy applied to them.",
+			},
+			[]string{"database", "collection", "policy"},
+		),
+		mongoChangeEventsMalformed: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_events_malformed_total",
+				Help: "Total number of change events that could not be encoded, by the policy applied to them.",
+			},
+			[]string{"database", "collection", "policy"},
+		),
+		mongoChangeEventsQuarantined: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_events_quarantined_total",
+				Help: "Total number of change events that could not be published, stored in the quarantine collection.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoChangeEventsSkipped: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_events_skipped_total",
+				Help: "Total number of change events skipped because they were older than the maximum event age.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoChangeEventsFiltered: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_events_filtered_total",
+				Help: "Total number of change events dropped because they did not match the filter of their collection.",
+			},
+			[]string{"database", "collection"},
+		),
+		mongoChangeEventsOutOfOrder: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mongodb_change_events_out_of_order_total",
+				Help: "Total number of change events observed or published before a change event with a later cluster time.",
+			},
+			[]string{"database", "collection", "stage"},
+		),
+		mongoConnsOpen: promauto.With(registerer).NewGauge(
+			prometheus.GaugeOpts{
+				Name: "mongodb_connections_open",
+				Help: "Number of open connections to mongodb.",
+			},
+		),
+		mongoReconnects: promauto.With(registerer).NewCounter(
+			prometheus.CounterOpts{
+				Name: "mongodb_reconnects_total",
+				Help: "Total number of times the mongodb client was re-established.",
+			},
+		),
+		mongoChangeStreamsOpen: promauto.With(registerer).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mongodb_change_streams_open",
+				Help: "Number of open change stream cursors.",
+			},
+			[]string{"database", "collection"},
+		),
+		tokenTimes: make(map[collection]time.Time),
 	}
 }
 
@@ -64,10 +223,110 @@ func (r *MongoRegisterer) ObserveMongoCmdFailed(dbName, cmdName string, duration
 	r.mongoCommandDuration.WithLabelValues(dbName, cmdName).Observe(duration.Seconds())
 }
 
+func (r *MongoRegisterer) ObserveMongoChangeEventPublished(dbName, collName, operationType string, size int) {
+	if _, ok := operations[operationType]; !ok {
+		operationType = otherOperation
+	}
+	r.mongoChangeEvents.WithLabelValues(dbName, collName, operationType).Inc()
+	r.mongoChangeEventBytes.WithLabelValues(dbName, collName, operationType).Add(float64(size))
+}
+
+func (r *MongoRegisterer) IncMongoChangeEventsOversized(dbName, collName, policy string) {
+	r.mongoChangeEventsOversize.WithLabelValues(dbName, collName, policy).Inc()
+}
+
+func (r *MongoRegisterer) IncMongoChangeEventsMalformed(dbName, collName, policy string) {
+	r.mongoChangeEventsMalformed.WithLabelValues(dbName, collName, policy).Inc()
+}
+
+func (r *MongoRegisterer) IncMongoChangeEventsQuarantined(dbName, collName string) {
+	r.mongoChangeEventsQuarantined.WithLabelValues(dbName, collName).Inc()
+}
+
+func (r *MongoRegisterer) AddMongoChangeEventsSkipped(dbName, collName string, skipped int) {
+	r.mongoChangeEventsSkipped.WithLabelValues(dbName, collName).Add(float64(skipped))
+}
+
+func (r *MongoRegisterer) IncMongoChangeEventsFiltered(dbName, collName string) {
+	r.mongoChangeEventsFiltered.WithLabelValues(dbName, collName).Inc()
+}
+
+func (r *MongoRegisterer) IncMongoChangeEventsOutOfOrder(dbName, collName, stage string) {
+	r.mongoChangeEventsOutOfOrder.WithLabelValues(dbName, collName, stage).Inc()
+}
+
+func (r *MongoRegisterer) IncMongoConnsOpen() {
+	r.mongoConnsOpen.Inc()
+}
+
+func (r *MongoRegisterer) DecMongoConnsOpen() {
+	r.mongoConnsOpen.Dec()
+}
+
+func (r *MongoRegisterer) IncMongoReconnects() {
+	r.mongoReconnects.Inc()
+}
+
+func (r *MongoRegisterer) IncMongoChangeStreamsOpen(dbName, collName string) {
+	r.mongoChangeStreamsOpen.WithLabelValues(dbName, collName).Inc()
+}
+
+func (r *MongoRegisterer) DecMongoChangeStreamsOpen(dbName, collName string) {
+	r.mongoChangeStreamsOpen.WithLabelValues(dbName, collName).Dec()
+}
+
+func (r *MongoRegisterer) ObserveMongoTokenSaved(dbName, collName string, tokenTime time.Time) {
+	r.mongoTokenTimestamp.WithLabelValues(dbName, collName).Set(float64(tokenTime.Unix()))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	coll := collection{dbName: dbName, collName: collName}
+	r.tokenTimes[coll] = tokenTime
+	r.setOplogHeadroom(coll)
+}
+
+func (r *MongoRegisterer) IncMongoOplogHeadroomWarnings(dbName, collName string) {
+	r.mongoOplogHeadroomWarnings.WithLabelValues(dbName, collName).Inc()
+}
+
+func (r *MongoRegisterer) IncMongoTokenSaveRetries(dbName, collName string) {
+	r.mongoTokenSaveRetries.WithLabelValues(dbName, collName).Inc()
+}
+
+func (r *MongoRegisterer) IncMongoTokenSaveFailures(dbName, collName string) {
+	r.mongoTokenSaveFailures.WithLabelValues(dbName, collName).Inc()
+}
+
+func (r *MongoRegisterer) ObserveMongoOplogWindow(oldest time.Time) {
+	r.mongoOplogOldestTimestamp.Set(float64(oldest.Unix()))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.oplogOldest = oldest
+	for coll := range r.tokenTimes {
+		r.setOplogHeadroom(coll)
+	}
+}
+
+// setOplogHeadroom must be called while holding the lock.
+func (r *MongoRegisterer) setOplogHeadroom(coll collection) {
+	if r.oplogOldest.IsZero() {
+		return
+	}
+	headroom := r.tokenTimes[coll].Sub(r.oplogOldest)
+	r.mongoTokenOplogHeadroom.WithLabelValues(coll.dbName, coll.collName).Set(headroom.Seconds())
+}
+
 type NatsRegisterer struct {
 	natsMessagesPublished *prometheus.CounterVec
 	natsMessagesFailed    *prometheus.CounterVec
 	natsMessageDuration   *prometheus.HistogramVec
+	natsDisconnects       *prometheus.CounterVec
+	natsReconnects        *prometheus.CounterVec
+	natsBackpressure      *prometheus.CounterVec
+	natsNacks             *prometheus.CounterVec
+	natsAckTimeouts       *prometheus.CounterVec
+	natsConnErrors        *prometheus.CounterVec
+	natsDuplicates        *prometheus.CounterVec
+	natsCredsRotations    *prometheus.CounterVec
 }
 
 func NewNatsRegisterer(registerer prometheus.Registerer) *NatsRegisterer {
@@ -94,6 +353,62 @@ func NewNatsRegisterer(registerer prometheus.Registerer) *NatsRegisterer {
 			},
 			[]string{"subject"},
 		),
+		natsDisconnects: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_disconnects_total",
+				Help: "Total number of disconnections from nats.",
+			},
+			[]string{"connection"},
+		),
+		natsReconnects: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_reconnects_total",
+				Help: "Total number of reconnections to nats.",
+			},
+			[]string{"connection"},
+		),
+		natsBackpressure: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_publish_backpressure_total",
+				Help: "Total number of messages rejected by a stream because of its limits, or by a stream not responding.",
+			},
+			[]string{"subject"},
+		),
+		natsNacks: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_publish_nacks_total",
+				Help: "Total number of messages rejected by a stream with a negative ack.",
+			},
+			[]string{"subject"},
+		),
+		natsAckTimeouts: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_publish_ack_timeouts_total",
+				Help: "Total number of messages not acknowledged by a stream in time.",
+			},
+			[]string{"subject"},
+		),
+		natsConnErrors: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_publish_connection_errors_total",
+				Help: "Total number of messages not published because the connection to nats was lost.",
+			},
+			[]string{"subject"},
+		),
+		natsDuplicates: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_publish_duplicates_total",
+				Help: "Total number of messages acknowledged as duplicates of messages already stored by a stream.",
+			},
+			[]string{"subject"},
+		),
+		natsCredsRotations: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nats_creds_rotations_total",
+				Help: "Total number of reconnections to nats caused by rotated credentials or certificates.",
+			},
+			[]string{"connection"},
+		),
 	}
 }
 
@@ -107,10 +422,100 @@ func (r *NatsRegisterer) ObserveNatsMsgFailed(subj string, duration time.Duratio
 	r.natsMessageDuration.WithLabelValues(subj).Observe(duration.Seconds())
 }
 
+func (r *NatsRegisterer) IncNatsDisconnects(connName string) {
+	r.natsDisconnects.WithLabelValues(connName).Inc()
+}
+
+func (r *NatsRegisterer) IncNatsReconnects(connName string) {
+	r.natsReconnects.WithLabelValues(connName).Inc()
+}
+
+func (r *NatsRegisterer) IncNatsBackpressure(subj string) {
+	r.natsBackpressure.WithLabelValues(subj).Inc()
+}
+
+func (r *NatsRegisterer) IncNatsNacks(subj string) {
+	r.natsNacks.WithLabelValues(subj).Inc()
+}
+
+func (r *NatsRegisterer) IncNatsAckTimeouts(subj string) {
+	r.natsAckTimeouts.WithLabelValues(subj).Inc()
+}
+
+func (r *NatsRegisterer) IncNatsConnErrors(subj string) {
+	r.natsConnErrors.WithLabelValues(subj).Inc()
+}
+
+func (r *NatsRegisterer) IncNatsDuplicates(subj string) {
+	r.natsDuplicates.WithLabelValues(subj).Inc()
+}
+
+func (r *NatsRegisterer) IncNatsCredsRotations(connName string) {
+	r.natsCredsRotations.WithLabelValues(connName).Inc()
+}
+
 func DefaultRegisterer() prometheus.Registerer {
 	return prometheus.DefaultRegisterer
 }
 
+// WithConstLabels returns a registerer that adds the given labels to all the metrics registered with it, e.g. to
+// identify the connector instance exposing them.
+func WithConstLabels(registerer prometheus.Registerer, labels map[string]string) prometheus.Registerer {
+	return prometheus.WrapRegistererWith(labels, registerer)
+}
+
+// TrackingRegisterer is a registerer that keeps track of the collectors registered with it, so that they can all be
+// unregistered at once, e.g. once the connector that registered them is closed and another one can take its place.
+type TrackingRegisterer struct {
+	registerer prometheus.Registerer
+
+	mu         sync.Mutex
+	collectors []prometheus.Collector
+}
+
+var _ prometheus.Registerer = &TrackingRegisterer{}
+
+func NewTrackingRegisterer(registerer prometheus.Registerer) *TrackingRegisterer {
+	return &TrackingRegisterer{registerer: registerer}
+}
+
+func (r *TrackingRegisterer) Register(collector prometheus.Collector) error {
+	if err := r.registerer.Register(collector); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collector)
+	return nil
+}
+
+func (r *TrackingRegisterer) MustRegister(collectors ...prometheus.Collector) {
+	for _, collector := range collectors {
+		if err := r.Register(collector); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *TrackingRegisterer) Unregister(collector prometheus.Collector) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = slices.DeleteFunc(r.collectors, func(c prometheus.Collector) bool {
+		return c == collector
+	})
+	return r.registerer.Unregister(collector)
+}
+
+// UnregisterAll unregisters all the collectors registered with this registerer.
+func (r *TrackingRegisterer) UnregisterAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, collector := range r.collectors {
+		r.registerer.Unregister(collector)
+	}
+	r.collectors = nil
+}
+
 func HTTPHandler() http.Handler {
 	return promhttp.Handler()
 }
@@ -77,6 +77,246 @@ func TestMongoRegisterer_ObserveMongoCmdFailed(t *testing.T) {
 	requireMetricHasLabel(t, duration, "command", expectedCmd)
 }
 
+func TestMongoRegisterer_ObserveMongoChangeEventPublished(t *testing.T) {
+	tests := []struct {
+		name              string
+		operationType     string
+		expectedOperation string
+	}{
+		{name: "should count insert events", operationType: "insert", expectedOperation: "insert"},
+		{name: "should count update events", operationType: "update", expectedOperation: "update"},
+		{name: "should count delete events", operationType: "delete", expectedOperation: "delete"},
+		{name: "should count replace events", operationType: "replace", expectedOperation: "replace"},
+		{name: "should count other events as other", operationType: "drop", expectedOperation: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registerer := prometheus.NewPedanticRegistry()
+
+			mr := NewMongoRegisterer(registerer)
+			mr.ObserveMongoChangeEventPublished("test-db", "coll1", tt.operationType, 100)
+			mr.ObserveMongoChangeEventPublished("test-db", "coll1", tt.operationType, 50)
+
+			eventsTotal := getMetric(t, registerer, "mongodb_change_events_published_total")
+			require.NotNil(t, eventsTotal)
+			require.Equal(t, 2.0, eventsTotal.Counter.GetValue())
+			requireMetricHasLabel(t, eventsTotal, "database", "test-db")
+			requireMetricHasLabel(t, eventsTotal, "collection", "coll1")
+			requireMetricHasLabel(t, eventsTotal, "operation", tt.expectedOperation)
+
+			bytesTotal := getMetric(t, registerer, "mongodb_change_event_bytes_published_total")
+			require.NotNil(t, bytesTotal)
+			require.Equal(t, 150.0, bytesTotal.Counter.GetValue())
+			requireMetricHasLabel(t, bytesTotal, "operation", tt.expectedOperation)
+		})
+	}
+}
+
+func TestMongoRegisterer_IncMongoChangeEventsOversized(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoChangeEventsOversized("test-db", "coll1", "drop")
+
+	oversizedTotal := getMetric(t, registerer, "mongodb_change_events_oversized_total")
+	require.NotNil(t, oversizedTotal)
+	require.Equal(t, 1.0, oversizedTotal.Counter.GetValue())
+	requireMetricHasLabel(t, oversizedTotal, "database", "test-db")
+	requireMetricHasLabel(t, oversizedTotal, "collection", "coll1")
+	requireMetricHasLabel(t, oversizedTotal, "policy", "drop")
+}
+
+func TestMongoRegisterer_IncMongoChangeEventsMalformed(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoChangeEventsMalformed("test-db", "coll1", "skip")
+
+	malformedTotal := getMetric(t, registerer, "mongodb_change_events_malformed_total")
+	require.NotNil(t, malformedTotal)
+	require.Equal(t, 1.0, malformedTotal.Counter.GetValue())
+	requireMetricHasLabel(t, malformedTotal, "database", "test-db")
+	requireMetricHasLabel(t, malformedTotal, "collection", "coll1")
+	requireMetricHasLabel(t, malformedTotal, "policy", "skip")
+}
+
+func TestMongoRegisterer_IncMongoChangeEventsQuarantined(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoChangeEventsQuarantined("test-db", "coll1")
+
+	quarantinedTotal := getMetric(t, registerer, "mongodb_change_events_quarantined_total")
+	require.NotNil(t, quarantinedTotal)
+	require.Equal(t, 1.0, quarantinedTotal.Counter.GetValue())
+	requireMetricHasLabel(t, quarantinedTotal, "database", "test-db")
+	requireMetricHasLabel(t, quarantinedTotal, "collection", "coll1")
+}
+
+func TestMongoRegisterer_IncMongoChangeEventsOutOfOrder(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoChangeEventsOutOfOrder("test-db", "coll1", "published")
+
+	outOfOrderTotal := getMetric(t, registerer, "mongodb_change_events_out_of_order_total")
+	require.NotNil(t, outOfOrderTotal)
+	require.Equal(t, 1.0, outOfOrderTotal.Counter.GetValue())
+	requireMetricHasLabel(t, outOfOrderTotal, "database", "test-db")
+	requireMetricHasLabel(t, outOfOrderTotal, "collection", "coll1")
+	requireMetricHasLabel(t, outOfOrderTotal, "stage", "published")
+}
+
+func TestMongoRegisterer_AddMongoChangeEventsSkipped(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.AddMongoChangeEventsSkipped("test-db", "coll1", 3)
+	mr.AddMongoChangeEventsSkipped("test-db", "coll1", 2)
+
+	skippedTotal := getMetric(t, registerer, "mongodb_change_events_skipped_total")
+	require.NotNil(t, skippedTotal)
+	require.Equal(t, 5.0, skippedTotal.Counter.GetValue())
+	requireMetricHasLabel(t, skippedTotal, "database", "test-db")
+	requireMetricHasLabel(t, skippedTotal, "collection", "coll1")
+}
+
+func TestMongoRegisterer_IncMongoChangeEventsFiltered(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoChangeEventsFiltered("test-db", "coll1")
+	mr.IncMongoChangeEventsFiltered("test-db", "coll1")
+
+	filteredTotal := getMetric(t, registerer, "mongodb_change_events_filtered_total")
+	require.NotNil(t, filteredTotal)
+	require.Equal(t, 2.0, filteredTotal.Counter.GetValue())
+	requireMetricHasLabel(t, filteredTotal, "database", "test-db")
+	requireMetricHasLabel(t, filteredTotal, "collection", "coll1")
+}
+
+func TestMongoRegisterer_MongoConnsOpen(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoConnsOpen()
+	mr.IncMongoConnsOpen()
+	mr.DecMongoConnsOpen()
+
+	connsOpen := getMetric(t, registerer, "mongodb_connections_open")
+	require.NotNil(t, connsOpen)
+	require.Equal(t, 1.0, connsOpen.Gauge.GetValue())
+}
+
+func TestMongoRegisterer_IncMongoReconnects(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoReconnects()
+
+	reconnectsTotal := getMetric(t, registerer, "mongodb_reconnects_total")
+	require.NotNil(t, reconnectsTotal)
+	require.Equal(t, 1.0, reconnectsTotal.Counter.GetValue())
+}
+
+func TestMongoRegisterer_MongoChangeStreamsOpen(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoChangeStreamsOpen("test-db", "coll1")
+	mr.DecMongoChangeStreamsOpen("test-db", "coll1")
+	mr.IncMongoChangeStreamsOpen("test-db", "coll1")
+
+	changeStreamsOpen := getMetric(t, registerer, "mongodb_change_streams_open")
+	require.NotNil(t, changeStreamsOpen)
+	require.Equal(t, 1.0, changeStreamsOpen.Gauge.GetValue())
+	requireMetricHasLabel(t, changeStreamsOpen, "database", "test-db")
+	requireMetricHasLabel(t, changeStreamsOpen, "collection", "coll1")
+}
+
+func TestMongoRegisterer_ObserveMongoTokenSaved(t *testing.T) {
+	var (
+		registerer = prometheus.NewPedanticRegistry()
+		tokenTime  = time.Unix(1683637178, 0)
+	)
+
+	mr := NewMongoRegisterer(registerer)
+	mr.ObserveMongoTokenSaved("test-db", "coll1", tokenTime)
+
+	timestamp := getMetric(t, registerer, "mongodb_resume_token_timestamp_seconds")
+	require.NotNil(t, timestamp)
+	require.Equal(t, 1683637178.0, timestamp.Gauge.GetValue())
+	requireMetricHasLabel(t, timestamp, "database", "test-db")
+	requireMetricHasLabel(t, timestamp, "collection", "coll1")
+	require.Nil(t, getMetric(t, registerer, "mongodb_resume_token_oplog_headroom_seconds"))
+}
+
+func TestMongoRegisterer_ObserveMongoOplogWindow(t *testing.T) {
+	var (
+		registerer  = prometheus.NewPedanticRegistry()
+		tokenTime   = time.Unix(1683637178, 0)
+		oplogOldest = tokenTime.Add(-1 * time.Hour)
+	)
+
+	mr := NewMongoRegisterer(registerer)
+	mr.ObserveMongoTokenSaved("test-db", "coll1", tokenTime)
+	mr.ObserveMongoOplogWindow(oplogOldest)
+
+	oldest := getMetric(t, registerer, "mongodb_oplog_oldest_entry_timestamp_seconds")
+	require.NotNil(t, oldest)
+	require.Equal(t, float64(oplogOldest.Unix()), oldest.Gauge.GetValue())
+
+	headroom := getMetric(t, registerer, "mongodb_resume_token_oplog_headroom_seconds")
+	require.NotNil(t, headroom)
+	require.Equal(t, 3600.0, headroom.Gauge.GetValue())
+	requireMetricHasLabel(t, headroom, "database", "test-db")
+	requireMetricHasLabel(t, headroom, "collection", "coll1")
+
+	mr.ObserveMongoTokenSaved("test-db", "coll1", tokenTime.Add(time.Minute))
+
+	headroom = getMetric(t, registerer, "mongodb_resume_token_oplog_headroom_seconds")
+	require.Equal(t, 3660.0, headroom.Gauge.GetValue())
+}
+
+func TestMongoRegisterer_IncMongoTokenSaveRetries(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoTokenSaveRetries("test-db", "coll1")
+
+	retriesTotal := getMetric(t, registerer, "mongodb_resume_token_save_retries_total")
+	require.NotNil(t, retriesTotal)
+	require.Equal(t, 1.0, retriesTotal.Counter.GetValue())
+	requireMetricHasLabel(t, retriesTotal, "database", "test-db")
+	requireMetricHasLabel(t, retriesTotal, "collection", "coll1")
+}
+
+func TestMongoRegisterer_IncMongoTokenSaveFailures(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoTokenSaveFailures("test-db", "coll1")
+
+	failuresTotal := getMetric(t, registerer, "mongodb_resume_token_save_failures_total")
+	require.NotNil(t, failuresTotal)
+	require.Equal(t, 1.0, failuresTotal.Counter.GetValue())
+	requireMetricHasLabel(t, failuresTotal, "database", "test-db")
+	requireMetricHasLabel(t, failuresTotal, "collection", "coll1")
+}
+
+func TestMongoRegisterer_IncMongoOplogHeadroomWarnings(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoOplogHeadroomWarnings("test-db", "coll1")
+
+	warningsTotal := getMetric(t, registerer, "mongodb_resume_token_oplog_headroom_warnings_total")
+	require.NotNil(t, warningsTotal)
+	require.Equal(t, 1.0, warningsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, warningsTotal, "database", "test-db")
+	requireMetricHasLabel(t, warningsTotal, "collection", "coll1")
+}
+
 func TestNatsRegisterer_ObserveNatsMsgPublished(t *testing.T) {
 	var (
 		registerer       = prometheus.NewPedanticRegistry()
@@ -119,12 +359,102 @@ func TestNatsRegisterer_ObserveNatsMsgFailed(t *testing.T) {
 	requireMetricHasLabel(t, duration, "subject", expectedSubject)
 }
 
+func TestNatsRegisterer_IncNatsDisconnects(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	nr := NewNatsRegisterer(registerer)
+	nr.IncNatsDisconnects("nats")
+
+	disconnectsTotal := getMetric(t, registerer, "nats_disconnects_total")
+	require.NotNil(t, disconnectsTotal)
+	require.Equal(t, 1.0, disconnectsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, disconnectsTotal, "connection", "nats")
+}
+
+func TestNatsRegisterer_IncNatsReconnects(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	nr := NewNatsRegisterer(registerer)
+	nr.IncNatsReconnects("nats")
+	nr.IncNatsReconnects("nats")
+
+	reconnectsTotal := getMetric(t, registerer, "nats_reconnects_total")
+	require.NotNil(t, reconnectsTotal)
+	require.Equal(t, 2.0, reconnectsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, reconnectsTotal, "connection", "nats")
+}
+
+func TestNatsRegisterer_IncNatsCredsRotations(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	nr := NewNatsRegisterer(registerer)
+	nr.IncNatsCredsRotations("nats-acme")
+
+	rotationsTotal := getMetric(t, registerer, "nats_creds_rotations_total")
+	require.NotNil(t, rotationsTotal)
+	require.Equal(t, 1.0, rotationsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, rotationsTotal, "connection", "nats-acme")
+}
+
+func TestNatsRegisterer_IncNatsBackpressure(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	nr := NewNatsRegisterer(registerer)
+	nr.IncNatsBackpressure("coll1.insert")
+
+	backpressureTotal := getMetric(t, registerer, "nats_publish_backpressure_total")
+	require.NotNil(t, backpressureTotal)
+	require.Equal(t, 1.0, backpressureTotal.Counter.GetValue())
+	requireMetricHasLabel(t, backpressureTotal, "subject", "coll1.insert")
+}
+
+func TestNatsRegisterer_IncNatsPublishErrors(t *testing.T) {
+	registerer := prometheus.NewPedanticRegistry()
+
+	nr := NewNatsRegisterer(registerer)
+	nr.IncNatsNacks("coll1.insert")
+	nr.IncNatsAckTimeouts("coll1.insert")
+	nr.IncNatsAckTimeouts("coll1.insert")
+	nr.IncNatsConnErrors("coll1.update")
+	nr.IncNatsDuplicates("coll1.delete")
+
+	for _, tt := range []struct {
+		name  string
+		value float64
+		subj  string
+	}{
+		{name: "nats_publish_nacks_total", value: 1, subj: "coll1.insert"},
+		{name: "nats_publish_ack_timeouts_total", value: 2, subj: "coll1.insert"},
+		{name: "nats_publish_connection_errors_total", value: 1, subj: "coll1.update"},
+		{name: "nats_publish_duplicates_total", value: 1, subj: "coll1.delete"},
+	} {
+		total := getMetric(t, registerer, tt.name)
+		require.NotNil(t, total, tt.name)
+		require.Equal(t, tt.value, total.Counter.GetValue(), tt.name)
+		requireMetricHasLabel(t, total, "subject", tt.subj)
+	}
+}
+
 func TestDefaultRegisterer(t *testing.T) {
 	registerer := DefaultRegisterer()
 
 	require.Equal(t, prometheus.DefaultRegisterer, registerer)
 }
 
+func TestWithConstLabels(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registerer := WithConstLabels(registry, map[string]string{"instance_id": "connector-0", "env": "prod"})
+
+	nr := NewNatsRegisterer(registerer)
+	nr.ObserveNatsMsgPublished("coll1.insert", time.Second)
+
+	publishedTotal := getMetric(t, registry, "nats_messages_published_total")
+	require.NotNil(t, publishedTotal)
+	requireMetricHasLabel(t, publishedTotal, "instance_id", "connector-0")
+	requireMetricHasLabel(t, publishedTotal, "env", "prod")
+	requireMetricHasLabel(t, publishedTotal, "subject", "coll1.insert")
+}
+
 func TestHTTPHandler(t *testing.T) {
 	var (
 		rec = httptest.NewRecorder()
@@ -137,6 +467,26 @@ func TestHTTPHandler(t *testing.T) {
 	res, err := io.ReadAll(rec.Body)
 	require.NoError(t, err)
 	require.NotEmpty(t, res)
+	require.Contains(t, string(res), "go_goroutines")
+	require.Contains(t, string(res), "go_gc_duration_seconds")
+	require.Contains(t, string(res), "go_memstats_heap_inuse_bytes")
+}
+
+func TestTrackingRegisterer_UnregisterAll(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registerer := NewTrackingRegisterer(registry)
+
+	mr := NewMongoRegisterer(registerer)
+	mr.IncMongoReconnects()
+	require.NotNil(t, getMetric(t, registry, "mongodb_reconnects_total"))
+
+	registerer.UnregisterAll()
+
+	require.Nil(t, getMetric(t, registry, "mongodb_reconnects_total"))
+	require.NotPanics(t, func() {
+		NewMongoRegisterer(registerer).IncMongoReconnects()
+	})
+	require.NotNil(t, getMetric(t, registry, "mongodb_reconnects_total"))
 }
 
 func getMetric(t *testing.T, gatherer prometheus.Gatherer, metricFamilyName string) *dto.Metric {
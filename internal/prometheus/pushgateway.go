@@ -0,0 +1,42 @@
+package prometheus
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushgatewayExporter pushes the metrics of the default registry to a Prometheus Pushgateway on demand, e.g. once a
+// short-lived command completes, since it may never be scraped. The metrics pushed replace the ones previously pushed
+// with the same job and grouping labels.
+type PushgatewayExporter struct {
+	url      string
+	job      string
+	grouping map[string]string
+	gatherer prometheus.Gatherer
+}
+
+// NewPushgatewayExporter creates a new PushgatewayExporter, pushing the metrics to the Pushgateway at the given URL,
+// under the given job, grouped by the given labels, e.g. the instance, so that the runs of several instances do not
+// replace the metrics of each other. The metrics must not hold the grouping labels themselves.
+func NewPushgatewayExporter(url, job string, grouping map[string]string) *PushgatewayExporter {
+	return &PushgatewayExporter{
+		url:      url,
+		job:      job,
+		grouping: grouping,
+		gatherer: prometheus.DefaultGatherer,
+	}
+}
+
+// Export pushes the current metrics to the Pushgateway.
+func (e *PushgatewayExporter) Export() error {
+	pusher := push.New(e.url, e.job).Gatherer(e.gatherer)
+	for name, value := range e.grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if err := pusher.Push(); err != nil {
+		return fmt.Errorf("could not push metrics to pushgateway %v: %v", e.url, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package prometheus
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushgatewayExporter_Export(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewPedanticRegistry()
+	backfilled := prometheus.NewCounter(prometheus.CounterOpts{Name: "documents_backfilled_total", Help: "Docs."})
+	registry.MustRegister(backfilled)
+	backfilled.Add(42)
+
+	t.Run("should push the metrics with the grouping labels", func(t *testing.T) {
+		exporter := NewPushgatewayExporter(server.URL, "connector",
+			map[string]string{"instance": "connector-0", "command": "backfill"})
+		exporter.gatherer = registry
+
+		require.NoError(t, exporter.Export())
+		require.Equal(t, http.MethodPut, method)
+		require.True(t, strings.HasPrefix(path, "/metrics/job/connector/"))
+		require.Contains(t, path, "/instance/connector-0")
+		require.Contains(t, path, "/command/backfill")
+		require.Contains(t, body, "documents_backfilled_total")
+	})
+	t.Run("should return error if the metrics hold a grouping label", func(t *testing.T) {
+		exporter := NewPushgatewayExporter(server.URL, "connector", map[string]string{"instance": "connector-0"})
+		labeled := prometheus.NewPedanticRegistry()
+		labeled.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "streams_open", Help: "Streams.",
+			ConstLabels: prometheus.Labels{"instance": "connector-1"}}))
+		exporter.gatherer = labeled
+
+		require.ErrorContains(t, exporter.Export(), "already contains grouping label instance")
+	})
+	t.Run("should return error if the pushgateway rejects the metrics", func(t *testing.T) {
+		rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer rejecting.Close()
+		exporter := NewPushgatewayExporter(rejecting.URL, "connector", nil)
+		exporter.gatherer = registry
+
+		require.ErrorContains(t, exporter.Export(), "could not push metrics to pushgateway")
+	})
+}
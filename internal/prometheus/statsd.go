@@ -0,0 +1,178 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// maxStatsdPacketSize is the maximum size of the datagrams sent to the StatsD server, so that they are not fragmented
+// on common networks.
+const maxStatsdPacketSize = 1432
+
+// statsdTagReplacer replaces the characters that cannot appear in DogStatsD tags.
+var statsdTagReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+
+// StatsdExporter pushes the metrics of the default registry to a StatsD server, e.g. the Datadog agent, on demand, with
+// their labels as DogStatsD tags. Counters are pushed as the increments since the previous push, gauges as their
+// current value, and histograms and summaries as the increments of their count and sum.
+type StatsdExporter struct {
+	prefix   string
+	labels   map[string]string
+	gatherer prometheus.Gatherer
+	conn     net.Conn
+
+	// counters holds the value of each counter series at the previous push.
+	counters map[string]float64
+}
+
+// NewStatsdExporter creates a new StatsdExporter, pushing the metrics to the StatsD server listening on the given UDP
+// address, with their names prefixed by the given prefix. If labels are given, the series with a different value for
+// any of them are not pushed, e.g. the ones of other connectors hosted by the same process.
+func NewStatsdExporter(addr, prefix string, labels map[string]string) (*StatsdExporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to statsd server %v: %v", addr, err)
+	}
+	return &StatsdExporter{
+		prefix:   prefix,
+		labels:   labels,
+		gatherer: prometheus.DefaultGatherer,
+		conn:     conn,
+		counters: make(map[string]float64),
+	}, nil
+}
+
+// Export pushes the current metrics to the StatsD server.
+func (e *StatsdExporter) Export() error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("could not gather metrics: %v", err)
+	}
+	var lines []string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if !e.exported(metric) {
+				continue
+			}
+			lines = append(lines, e.lines(family, metric)...)
+		}
+	}
+	return e.write(lines)
+}
+
+func (e *StatsdExporter) Close() error {
+	return e.conn.Close()
+}
+
+// exported returns true unless the labels of the given series conflict with the labels of the exporter.
+func (e *StatsdExporter) exported(metric *dto.Metric) bool {
+	for _, label := range metric.GetLabel() {
+		if value, ok := e.labels[label.GetName()]; ok && value != label.GetValue() {
+			return false
+		}
+	}
+	return true
+}
+
+// lines returns the StatsD lines of the given series of the given family.
+func (e *StatsdExporter) lines(family *dto.MetricFamily, metric *dto.Metric) []string {
+	name, tags := family.GetName(), statsdTags(metric)
+	var lines []string
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		lines = e.appendCount(lines, name, tags, metric.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		lines = e.appendGauge(lines, name, tags, metric.GetGauge().GetValue())
+	case dto.MetricType_UNTYPED:
+		lines = e.appendGauge(lines, name, tags, metric.GetUntyped().GetValue())
+	case dto.MetricType_HISTOGRAM:
+		h := metric.GetHistogram()
+		lines = e.appendCount(lines, name+"_count", tags, float64(h.GetSampleCount()))
+		lines = e.appendCount(lines, name+"_sum", tags, h.GetSampleSum())
+	case dto.MetricType_SUMMARY:
+		s := metric.GetSummary()
+		lines = e.appendCount(lines, name+"_count", tags, float64(s.GetSampleCount()))
+		lines = e.appendCount(lines, name+"_sum", tags, s.GetSampleSum())
+	}
+	return lines
+}
+
+// appendCount appends the increment of the given counter since the previous push, unless it did not increase. A counter
+// that decreased was reset, e.g. once the connector exposing it was restarted, so its whole value is the increment.
+func (e *StatsdExporter) appendCount(lines []string, name, tags string, value float64) []string {
+	key := name + "|" + tags
+	previous := e.counters[key]
+	e.counters[key] = value
+	if value < previous {
+		previous = 0
+	}
+	delta := value - previous
+	if delta == 0 || !isFinite(delta) {
+		return lines
+	}
+	return append(lines, e.line(name, delta, "c", tags))
+}
+
+func (e *StatsdExporter) appendGauge(lines []string, name, tags string, value float64) []string {
+	if !isFinite(value) {
+		return lines
+	}
+	return append(lines, e.line(name, value, "g", tags))
+}
+
+func (e *StatsdExporter) line(name string, value float64, metricType, tags string) string {
+	line := e.prefix + name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + metricType
+	if tags != "" {
+		line += "|#" + tags
+	}
+	return line
+}
+
+// write sends the given lines to the StatsD server, as few datagrams as possible.
+func (e *StatsdExporter) write(lines []string) error {
+	var packet strings.Builder
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := e.conn.Write([]byte(packet.String()))
+		packet.Reset()
+		if err != nil {
+			return fmt.Errorf("could not push metrics to statsd server: %v", err)
+		}
+		return nil
+	}
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsdPacketSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return flush()
+}
+
+// statsdTags returns the labels of the given series as DogStatsD tags, sorted by name, e.g. database:shop,op:insert.
+func statsdTags(metric *dto.Metric) string {
+	tags := make([]string, 0, len(metric.GetLabel()))
+	for _, label := range metric.GetLabel() {
+		tags = append(tags, statsdTagReplacer.Replace(label.GetName()+":"+label.GetValue()))
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
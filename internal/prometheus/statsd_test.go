@@ -0,0 +1,90 @@
+package prometheus
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdExporter_Export(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+	receive := func(t *testing.T) []string {
+		var lines []string
+		buf := make([]byte, 64*1024)
+		for {
+			require.NoError(t, server.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+			n, _, err := server.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+		sort.Strings(lines)
+		return lines
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	published := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "events_total", Help: "Events."},
+		[]string{"instance_id", "op"})
+	streams := prometheus.NewGauge(prometheus.GaugeOpts{Name: "streams_open", Help: "Streams."})
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "duration_seconds", Help: "Duration."})
+	registry.MustRegister(published, streams, duration)
+
+	exporter, err := NewStatsdExporter(server.LocalAddr().String(), "connector.",
+		map[string]string{"instance_id": "connector-0"})
+	require.NoError(t, err)
+	defer exporter.Close()
+	exporter.gatherer = registry
+
+	published.WithLabelValues("connector-0", "insert").Add(3)
+	published.WithLabelValues("connector-1", "insert").Add(5) // another connector hosted by the same process
+	streams.Set(2)
+	duration.Observe(0.5)
+
+	require.NoError(t, exporter.Export())
+	require.Equal(t, []string{
+		"connector.duration_seconds_count:1|c",
+		"connector.duration_seconds_sum:0.5|c",
+		"connector.events_total:3|c|#instance_id:connector-0,op:insert",
+		"connector.streams_open:2|g",
+	}, receive(t))
+
+	published.WithLabelValues("connector-0", "insert").Add(2)
+	require.NoError(t, exporter.Export())
+	require.Equal(t, []string{
+		"connector.events_total:2|c|#instance_id:connector-0,op:insert",
+		"connector.streams_open:2|g",
+	}, receive(t), "counters are pushed as their increments since the previous push")
+}
+
+func TestStatsdExporter_write(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+	exporter, err := NewStatsdExporter(server.LocalAddr().String(), "", nil)
+	require.NoError(t, err)
+	defer exporter.Close()
+
+	line := strings.Repeat("a", 500) + ":1|c"
+	require.NoError(t, exporter.write([]string{line, line, line, line}))
+
+	buf := make([]byte, 64*1024)
+	var packets []int
+	for {
+		require.NoError(t, server.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		require.LessOrEqual(t, n, maxStatsdPacketSize)
+		packets = append(packets, strings.Count(string(buf[:n]), "\n")+1)
+	}
+	require.Equal(t, []int{2, 2}, packets, "lines are packed in datagrams up to the maximum packet size")
+}
@@ -0,0 +1,211 @@
+package retry
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Jitter represents how the waits between retries are randomized, so that the watchers retrying at the same time, e.g.
+// once a shared dependency recovers, do not retry in lockstep.
+type Jitter string
+
+const (
+	// NoJitter waits for the whole interval.
+	NoJitter Jitter = "none"
+
+	// FullJitter waits for a random duration between zero and the interval.
+	FullJitter Jitter = "full"
+
+	// EqualJitter waits for half of the interval, plus a random duration up to the other half.
+	EqualJitter Jitter = "equal"
+
+	// DecorrelatedJitter waits for a random duration between the initial interval and the previous wait times the
+	// multiplier, capped by the maximum interval.
+	DecorrelatedJitter Jitter = "decorrelated"
+)
+
+var Jitters = []Jitter{
+	NoJitter,
+	FullJitter,
+	EqualJitter,
+	DecorrelatedJitter,
+}
+
+// Class represents a class of errors that can be retried.
+type Class string
+
+const (
+	// BackpressureClass is the class of the messages rejected by a stream because of its limits, or by a stream not
+	// responding.
+	BackpressureClass Class = "backpressure"
+
+	// AckTimeoutClass is the class of the messages not acknowledged by a stream in time.
+	AckTimeoutClass Class = "ackTimeout"
+
+	// TransientClass is the class of the transient mongodb errors, e.g. network errors or timeouts, of resume token
+	// saves.
+	TransientClass Class = "transient"
+
+	// UnreachableClass is the class of the errors meaning that mongodb cannot be reached, or cannot authenticate the
+	// connector, after which the watchers resume.
+	UnreachableClass Class = "unreachable"
+)
+
+var Classes = []Class{
+	BackpressureClass,
+	AckTimeoutClass,
+	TransientClass,
+	UnreachableClass,
+}
+
+var ErrInvalidPolicy = errors.New("a retry policy must have positive intervals and budget, a multiplier of at least 1, a jitter among `none`, `full`, `equal`, `decorrelated`, and retry errors among `backpressure`, `ackTimeout`, `transient`, `unreachable`")
+
+// Policy tells how failed operations are retried, with an exponential backoff.
+type Policy struct {
+	// InitialInterval is the wait before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between retries.
+	MaxInterval time.Duration
+	// Multiplier is the factor applied to the wait after each retry.
+	Multiplier float64
+	// MaxElapsedTime is the time after which the operation is not retried anymore, since its first failure. If zero,
+	// it is retried without time limit.
+	MaxElapsedTime time.Duration
+	// MaxAttempts is the number of attempts after which the operation is not retried anymore, including the first
+	// one. If zero, it is retried without attempt limit.
+	MaxAttempts int
+	// Jitter is how the waits are randomized. If empty, they are not.
+	Jitter Jitter
+	// RetryOn are the classes of the errors that are retried. If empty, all of them are.
+	RetryOn []Class
+}
+
+// Validate returns an error if a setting of the policy is not supported.
+func (p *Policy) Validate() error {
+	if p.InitialInterval < 0 || p.MaxInterval < 0 || p.MaxElapsedTime < 0 || p.MaxAttempts < 0 ||
+		(p.Multiplier != 0 && p.Multiplier < 1) {
+		return ErrInvalidPolicy
+	}
+	if p.InitialInterval > 0 && p.MaxInterval > 0 && p.MaxInterval < p.InitialInterval {
+		return ErrInvalidPolicy
+	}
+	if p.Jitter != "" && !slices.Contains(Jitters, p.Jitter) {
+		return ErrInvalidPolicy
+	}
+	for _, class := range p.RetryOn {
+		if !slices.Contains(Classes, class) {
+			return ErrInvalidPolicy
+		}
+	}
+	return nil
+}
+
+// With returns the policy with the settings of the given overrides which are set, if any, e.g. to apply the policy
+// configured by the operator on top of the default policy of each operation.
+func (p Policy) With(overrides *Policy) Policy {
+	if overrides == nil {
+		return p
+	}
+	if overrides.InitialInterval > 0 {
+		p.InitialInterval = overrides.InitialInterval
+	}
+	if overrides.MaxInterval > 0 {
+		p.MaxInterval = overrides.MaxInterval
+	}
+	if overrides.Multiplier > 0 {
+		p.Multiplier = overrides.Multiplier
+	}
+	if overrides.MaxElapsedTime > 0 {
+		p.MaxElapsedTime = overrides.MaxElapsedTime
+	}
+	if overrides.MaxAttempts > 0 {
+		p.MaxAttempts = overrides.MaxAttempts
+	}
+	if overrides.Jitter != "" {
+		p.Jitter = overrides.Jitter
+	}
+	if len(overrides.RetryOn) > 0 {
+		p.RetryOn = overrides.RetryOn
+	}
+	p.MaxInterval = max(p.MaxInterval, p.InitialInterval)
+	return p
+}
+
+// Retries reports whether the errors of the given class are retried.
+func (p *Policy) Retries(class Class) bool {
+	return len(p.RetryOn) == 0 || slices.Contains(p.RetryOn, class)
+}
+
+// NewBackoff returns a backoff computing the waits between the retries of an operation with the policy.
+func (p Policy) NewBackoff() *Backoff {
+	return &Backoff{policy: p}
+}
+
+// Backoff computes the waits between the retries of an operation, until its retry budget is exhausted.
+type Backoff struct {
+	policy Policy
+	// start is the time of the first failure of the operation.
+	start   time.Time
+	attempt int
+	// interval is the wait before the next retry, before jitter.
+	interval time.Duration
+	// wait is the previous wait, after jitter.
+	wait time.Duration
+}
+
+// Next returns the wait before retrying the operation once it failed, or false if its retry budget is exhausted.
+func (b *Backoff) Next() (time.Duration, bool) {
+	p := b.policy
+	b.attempt++
+	if b.attempt == 1 {
+		b.start = time.Now()
+	}
+	if p.MaxAttempts > 0 && b.attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	if b.interval == 0 {
+		b.interval = p.InitialInterval
+	} else {
+		b.interval = min(time.Duration(float64(b.interval)*max(1, p.Multiplier)), p.MaxInterval)
+	}
+
+	wait := b.interval
+	switch p.Jitter {
+	case FullJitter:
+		wait = randBetween(0, b.interval)
+	case EqualJitter:
+		wait = b.interval/2 + randBetween(0, b.interval/2)
+	case DecorrelatedJitter:
+		upper := p.InitialInterval
+		if b.wait > 0 {
+			upper = time.Duration(float64(b.wait) * max(1, p.Multiplier))
+		}
+		wait = min(randBetween(p.InitialInterval, upper), p.MaxInterval)
+	}
+	if p.MaxElapsedTime > 0 && time.Since(b.start)+wait > p.MaxElapsedTime {
+		return 0, false
+	}
+	b.wait = wait
+	return wait, true
+}
+
+// Retries reports whether the errors of the given class are retried by the policy of the backoff.
+func (b *Backoff) Retries(class Class) bool {
+	return b.policy.Retries(class)
+}
+
+// Reset resets the backoff once the operation succeeded, so that its next failure is retried with the whole budget.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+	b.interval = 0
+	b.wait = 0
+}
+
+func randBetween(lower, upper time.Duration) time.Duration {
+	if upper <= lower {
+		return lower
+	}
+	return lower + rand.N(upper-lower+1)
+}
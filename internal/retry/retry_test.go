@@ -0,0 +1,119 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{name: "should accept an empty policy", policy: Policy{}},
+		{name: "should accept a complete policy", policy: Policy{InitialInterval: time.Second, MaxInterval: time.Minute,
+			Multiplier: 1.5, MaxElapsedTime: time.Hour, MaxAttempts: 10, Jitter: FullJitter,
+			RetryOn: []Class{BackpressureClass, UnreachableClass}}},
+		{name: "should reject a negative interval", policy: Policy{InitialInterval: -time.Second}, wantErr: true},
+		{name: "should reject a maximum interval below the initial one",
+			policy: Policy{InitialInterval: time.Minute, MaxInterval: time.Second}, wantErr: true},
+		{name: "should reject a multiplier below 1", policy: Policy{Multiplier: 0.5}, wantErr: true},
+		{name: "should reject an unknown jitter", policy: Policy{Jitter: "random"}, wantErr: true},
+		{name: "should reject an unknown error class", policy: Policy{RetryOn: []Class{"timeout"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidPolicy)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_With(t *testing.T) {
+	defaults := Policy{InitialInterval: 100 * time.Millisecond, MaxInterval: 5 * time.Second, Multiplier: 2,
+		MaxAttempts: 5}
+
+	t.Run("should keep the defaults without overrides", func(t *testing.T) {
+		require.Equal(t, defaults, defaults.With(nil))
+	})
+	t.Run("should override the settings which are set", func(t *testing.T) {
+		got := defaults.With(&Policy{InitialInterval: 10 * time.Second, Jitter: EqualJitter,
+			RetryOn: []Class{TransientClass}})
+
+		require.Equal(t, Policy{InitialInterval: 10 * time.Second, MaxInterval: 10 * time.Second, Multiplier: 2,
+			MaxAttempts: 5, Jitter: EqualJitter, RetryOn: []Class{TransientClass}}, got)
+	})
+}
+
+func TestPolicy_Retries(t *testing.T) {
+	require.True(t, (&Policy{}).Retries(AckTimeoutClass), "all the errors are retried by default")
+	require.True(t, (&Policy{RetryOn: []Class{AckTimeoutClass}}).Retries(AckTimeoutClass))
+	require.False(t, (&Policy{RetryOn: []Class{AckTimeoutClass}}).Retries(BackpressureClass))
+}
+
+func TestBackoff_Next(t *testing.T) {
+	t.Run("should multiply the wait up to the maximum interval", func(t *testing.T) {
+		b := Policy{InitialInterval: time.Second, MaxInterval: 5 * time.Second, Multiplier: 2}.NewBackoff()
+
+		var waits []time.Duration
+		for range 5 {
+			wait, ok := b.Next()
+			require.True(t, ok)
+			waits = append(waits, wait)
+		}
+
+		require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second,
+			5 * time.Second}, waits)
+	})
+	t.Run("should stop once the attempts are exhausted, until reset", func(t *testing.T) {
+		b := Policy{InitialInterval: time.Second, MaxInterval: time.Second, Multiplier: 2, MaxAttempts: 3}.NewBackoff()
+
+		_, ok := b.Next()
+		require.True(t, ok)
+		_, ok = b.Next()
+		require.True(t, ok)
+		_, ok = b.Next()
+		require.False(t, ok, "the third attempt is the last one")
+
+		b.Reset()
+		wait, ok := b.Next()
+		require.True(t, ok)
+		require.Equal(t, time.Second, wait)
+	})
+	t.Run("should stop once the elapsed time is exhausted", func(t *testing.T) {
+		b := Policy{InitialInterval: time.Minute, MaxElapsedTime: time.Second}.NewBackoff()
+
+		_, ok := b.Next()
+
+		require.False(t, ok)
+	})
+	t.Run("should randomize the waits within the interval", func(t *testing.T) {
+		for _, tt := range []struct {
+			jitter Jitter
+			lower  time.Duration
+			upper  time.Duration
+		}{
+			{jitter: FullJitter, lower: 0, upper: time.Second},
+			{jitter: EqualJitter, lower: 500 * time.Millisecond, upper: time.Second},
+			{jitter: DecorrelatedJitter, lower: time.Second, upper: time.Second},
+		} {
+			b := Policy{InitialInterval: time.Second, MaxInterval: time.Second, Multiplier: 3,
+				Jitter: tt.jitter}.NewBackoff()
+
+			for range 20 {
+				wait, ok := b.Next()
+				require.True(t, ok)
+				require.GreaterOrEqual(t, wait, tt.lower, tt.jitter)
+				require.LessOrEqual(t, wait, tt.upper, tt.jitter)
+			}
+		}
+	})
+}
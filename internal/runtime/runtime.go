@@ -0,0 +1,601 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/config"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/prometheus"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
+)
+
+const (
+	defaultBucket = "connectors"
+
+	// connectorLabel is the label added to the metrics and headers of each hosted connector, holding its name.
+	connectorLabel = "connector"
+)
+
+// connectorNameRegexp matches the names of the hosted connectors, which are used as keys of the key-value bucket.
+var connectorNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+var (
+	ErrCollectionsMissing = errors.New("at least one collection is required")
+	ErrLabelsNotAllowed   = errors.New("instance labels cannot be set, the labels of the runtime are used")
+
+	errStoppedUnexpectedly = errors.New("connector stopped unexpectedly")
+)
+
+var _ server.Connectors = &Runtime{}
+
+// hostedConnector is a connector hosted by the Runtime.
+type hostedConnector interface {
+	Run() error
+	EffectiveConfig() any
+	Status() map[string]server.CollectionStatus
+}
+
+// connectorFactory creates the named connector with the given configuration, which runs until the given context is
+// cancelled.
+type connectorFactory func(ctx context.Context, name string, cfg *config.Connector) (hostedConnector, error)
+
+// definition is the definition of a hosted connector, as persisted in the key-value bucket.
+type definition struct {
+	Paused    bool              `yaml:"paused,omitempty"`
+	Connector *config.Connector `yaml:"connector"`
+}
+
+// The Runtime type represents a process hosting many independently configured connectors, which are created, updated,
+// deleted, paused and resumed at runtime via its api. Their definitions are persisted in a NATS key-value bucket, so
+// that they are restored once the Runtime restarts.
+type Runtime struct {
+	ctx  context.Context
+	stop context.CancelFunc
+
+	// base is the configuration of the Runtime, whose instance, log, mongo and nats settings are inherited by the
+	// hosted connectors that do not override them.
+	base   *config.Connector
+	bucket string
+	logger *slog.Logger
+
+	natsClient   *nats.DefaultClient
+	kv           nats.KeyValue
+	newConnector connectorFactory
+	server       *server.Server
+
+	// opsMu serializes the operations changing the hosted connectors, which may wait for a connector to shut down.
+	opsMu sync.Mutex
+
+	mu         sync.RWMutex
+	connectors map[string]*hosted
+}
+
+// hosted holds the definition and the state of a hosted connector.
+type hosted struct {
+	name string
+
+	mu     sync.Mutex
+	def    *definition
+	state  string
+	err    error
+	conn   hostedConnector
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a new Runtime configured by the given base configuration.
+// The given options will override its default configuration.
+func New(base *config.Connector, opts ...Option) (*Runtime, error) {
+	r := &Runtime{
+		ctx:          context.Background(),
+		base:         base,
+		bucket:       defaultBucket,
+		newConnector: newConnector,
+		connectors:   make(map[string]*hosted),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	var level slog.Level // unknown levels default to info, as for the connectors
+	_ = level.UnmarshalText([]byte(base.Log.Level))
+	r.logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	if base.Instance.Id != "" {
+		r.logger = r.logger.With("instanceId", base.Instance.Id)
+	}
+
+	authenticators, err := serverAuthenticators(base.Server.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	monitors := []server.NamedMonitor{}
+	if r.kv == nil {
+		natsClient, err := nats.NewDefaultClient(
+			nats.WithNatsUrl(base.Nats.Url),
+			nats.WithCredsFile(base.Nats.CredsFile),
+			nats.WithTlsFiles(base.Nats.CertFile, base.Nats.KeyFile, base.Nats.CaFile),
+			nats.WithLogger(r.logger),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if r.kv, err = natsClient.KeyValue(r.bucket); err != nil {
+			_ = natsClient.Close()
+			return nil, err
+		}
+		r.natsClient = natsClient
+		monitors = append(monitors, natsClient)
+	}
+
+	r.ctx, r.stop = signal.NotifyContext(r.ctx, syscall.SIGINT, syscall.SIGTERM)
+
+	r.server = server.New(
+		server.WithAddr(base.Server.Addr),
+		server.WithAdminAddr(base.Server.AdminAddr),
+		server.WithTimeouts(base.Server.ReadTimeout, base.Server.WriteTimeout, base.Server.IdleTimeout),
+		server.WithMaxHeaderBytes(base.Server.MaxHeaderBytes),
+		server.WithShutdownTimeout(base.Server.ShutdownTimeout),
+		server.WithContext(r.ctx),
+		server.WithNamedMonitors(monitors...),
+		server.WithAuthenticators(authenticators...),
+		server.WithLogger(r.logger),
+		server.WithMetricsHandler(prometheus.HTTPHandler()),
+		server.WithConnectors(r),
+	)
+
+	return r, nil
+}
+
+// serverAuthenticators returns the authenticators of the requests to the api of the Runtime, as configured by the given
+// server auth section of its base configuration, if any, as the connectors do.
+func serverAuthenticators(auth *config.ServerAuth) ([]server.Authenticator, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	var authenticators []server.Authenticator
+	if auth.BearerTokensFile != "" {
+		authenticator, err := server.NewBearerTokenAuthenticator(auth.BearerTokensFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+	if auth.Oidc != nil {
+		authenticators = append(authenticators, server.NewOidcAuthenticator(auth.Oidc.Issuer, auth.Oidc.Audience))
+	}
+	return authenticators, nil
+}
+
+// newConnector creates the named connector with the given configuration, without its own HTTP server, since the
+// Runtime serves all the hosted connectors.
+func newConnector(ctx context.Context, _ string, cfg *config.Connector) (hostedConnector, error) {
+	return connector.New(append(cfg.Options(), connector.WithContext(ctx), connector.WithServerDisabled())...)
+}
+
+// Run runs the Runtime.
+// It starts the hosted connectors persisted in the key-value bucket, except the paused ones, then serves its api
+// until its context is cancelled. Once it is, all the hosted connectors are shut down.
+func (r *Runtime) Run() error {
+	defer r.cleanup()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	group, groupCtx := errgroup.WithContext(r.ctx)
+
+	group.Go(func() error {
+		return r.server.Run()
+	})
+
+	group.Go(func() error {
+		<-groupCtx.Done()
+		r.stopAll()
+		return r.server.Close()
+	})
+
+	return group.Wait()
+}
+
+// load starts the hosted connectors persisted in the key-value bucket, except the paused ones.
+// A connector whose definition cannot be decoded, or which cannot be created, is failed, and the others are started
+// anyway.
+func (r *Runtime) load() error {
+	names, err := r.kv.Keys()
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+	r.opsMu.Lock()
+	defer r.opsMu.Unlock()
+	for _, name := range names {
+		h := &hosted{name: name}
+		r.mu.Lock()
+		r.connectors[name] = h
+		r.mu.Unlock()
+
+		def, err := r.definition(name)
+		if err != nil {
+			r.logger.Error("could not load connector definition", "connector", name, "err", err)
+			h.setState(server.ConnectorStateFailed, err)
+			continue
+		}
+		h.def = def
+		if def.Paused {
+			h.setState(server.ConnectorStatePaused, nil)
+			continue
+		}
+		r.start(h)
+	}
+	return nil
+}
+
+func (r *Runtime) cleanup() {
+	if r.natsClient != nil {
+		if err := r.natsClient.Close(); err != nil {
+			r.logger.Error("could not close client", "err", err)
+		}
+	}
+	r.stop()
+}
+
+// List returns the state of all the hosted connectors, sorted by name.
+func (r *Runtime) List() []server.ConnectorInfo {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.connectors))
+	for name := range r.connectors {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	slices.Sort(names)
+	infos := make([]server.ConnectorInfo, 0, len(names))
+	for _, name := range names {
+		if info, err := r.Get(name); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// Get returns the state of the named connector, or server.ErrConnectorNotFound if it does not exist.
+func (r *Runtime) Get(name string) (server.ConnectorInfo, error) {
+	h, ok := r.hosted(name)
+	if !ok {
+		return server.ConnectorInfo{}, fmt.Errorf("%w: %s", server.ErrConnectorNotFound, name)
+	}
+	return h.info(), nil
+}
+
+// Put creates the named connector with the given definition, or updates the definition of the existing one, then
+// persists it and (re)starts the connector, unless it is paused.
+// The definition is the content of the connector section of a config file, in yaml or json.
+func (r *Runtime) Put(ctx context.Context, name string, data []byte) (bool, error) {
+	if !connectorNameRegexp.MatchString(name) {
+		return false, fmt.Errorf("%w: %q", server.ErrInvalidConnectorName, name)
+	}
+	cfg, err := config.ParseConnector(data)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", server.ErrInvalidConnectorDef, err)
+	}
+	if len(cfg.Collections) == 0 {
+		return false, fmt.Errorf("%w: %v", server.ErrInvalidConnectorDef, ErrCollectionsMissing)
+	}
+	if len(cfg.Instance.Labels) > 0 {
+		// the metrics of all the hosted connectors are registered together, so they must have the same label names
+		return false, fmt.Errorf("%w: %v", server.ErrInvalidConnectorDef, ErrLabelsNotAllowed)
+	}
+
+	r.opsMu.Lock()
+	defer r.opsMu.Unlock()
+
+	def := &definition{Connector: cfg}
+	h, exists := r.hosted(name)
+	if exists && h.definition() != nil {
+		def.Paused = h.definition().Paused
+	}
+	if err = r.save(name, def); err != nil {
+		return false, err
+	}
+	if exists {
+		r.stopConnector(h)
+	} else {
+		h = &hosted{name: name}
+		r.mu.Lock()
+		r.connectors[name] = h
+		r.mu.Unlock()
+	}
+	h.setDefinition(def)
+	if def.Paused {
+		h.setState(server.ConnectorStatePaused, nil)
+	} else {
+		r.start(h)
+	}
+	r.logger.Info("connector definition saved", "connector", name, "created", !exists,
+		"requestId", server.RequestId(ctx))
+	return !exists, nil
+}
+
+// Delete shuts down the named connector, and deletes its definition.
+func (r *Runtime) Delete(ctx context.Context, name string) error {
+	r.opsMu.Lock()
+	defer r.opsMu.Unlock()
+
+	h, ok := r.hosted(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", server.ErrConnectorNotFound, name)
+	}
+	if err := r.kv.Delete(name); err != nil {
+		return err
+	}
+	r.stopConnector(h)
+	r.mu.Lock()
+	delete(r.connectors, name)
+	r.mu.Unlock()
+	r.logger.Info("connector deleted", "connector", name, "requestId", server.RequestId(ctx))
+	return nil
+}
+
+// Pause shuts down the named connector, and persists it as paused, so that it is not started once the Runtime
+// restarts. Its resume tokens are kept, so that it resumes where it left off.
+func (r *Runtime) Pause(ctx context.Context, name string) error {
+	r.opsMu.Lock()
+	defer r.opsMu.Unlock()
+
+	h, ok := r.hosted(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", server.ErrConnectorNotFound, name)
+	}
+	def := h.definition()
+	if def == nil || def.Paused {
+		return nil
+	}
+	if err := r.save(name, &definition{Paused: true, Connector: def.Connector}); err != nil {
+		return err
+	}
+	r.stopConnector(h)
+	h.setDefinition(&definition{Paused: true, Connector: def.Connector})
+	h.setState(server.ConnectorStatePaused, nil)
+	r.logger.Info("connector paused", "connector", name, "requestId", server.RequestId(ctx))
+	return nil
+}
+
+// Resume starts the named connector if it is paused, or restarts it if it failed.
+func (r *Runtime) Resume(ctx context.Context, name string) error {
+	r.opsMu.Lock()
+	defer r.opsMu.Unlock()
+
+	h, ok := r.hosted(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", server.ErrConnectorNotFound, name)
+	}
+	def := h.definition()
+	if def == nil {
+		return fmt.Errorf("%w: %s has no valid definition", server.ErrInvalidConnectorDef, name)
+	}
+	if !def.Paused && h.info().State == server.ConnectorStateRunning {
+		return nil
+	}
+	if def.Paused {
+		def = &definition{Connector: def.Connector}
+		if err := r.save(name, def); err != nil {
+			return err
+		}
+		h.setDefinition(def)
+	}
+	r.stopConnector(h)
+	r.start(h)
+	r.logger.Info("connector resumed", "connector", name, "requestId", server.RequestId(ctx))
+	return nil
+}
+
+func (r *Runtime) hosted(name string) (*hosted, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.connectors[name]
+	return h, ok
+}
+
+func (r *Runtime) definition(name string) (*definition, error) {
+	data, err := r.kv.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	def := &definition{}
+	if err = yaml.Unmarshal(data, def); err != nil {
+		return nil, fmt.Errorf("could not unmarshal connector definition: %v", err)
+	}
+	if def.Connector == nil {
+		return nil, fmt.Errorf("%w: %s has no connector config", server.ErrInvalidConnectorDef, name)
+	}
+	return def, nil
+}
+
+func (r *Runtime) save(name string, def *definition) error {
+	data, err := yaml.Marshal(def)
+	if err != nil {
+		return fmt.Errorf("could not marshal connector definition: %v", err)
+	}
+	return r.kv.Put(name, data)
+}
+
+// start creates the given connector from its definition and runs it in its own goroutine.
+// If it cannot be created, or it stops before being shut down by the Runtime, it is failed, and the other connectors
+// are not affected.
+func (r *Runtime) start(h *hosted) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	conn, err := r.newConnector(ctx, h.name, r.inherit(h.name, h.definition().Connector))
+	if err != nil {
+		cancel()
+		r.logger.Error("could not create connector", "connector", h.name, "err", err)
+		h.mu.Lock()
+		h.conn, h.state, h.err = nil, server.ConnectorStateFailed, err
+		h.mu.Unlock()
+		return
+	}
+
+	done := make(chan struct{})
+	h.mu.Lock()
+	h.conn, h.cancel, h.done = conn, cancel, done
+	h.state, h.err = server.ConnectorStateRunning, nil
+	h.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		err := conn.Run() // blocking call
+		if ctx.Err() != nil {
+			if err != nil {
+				r.logger.Warn("connector was not shut down cleanly", "connector", h.name, "err", err)
+			}
+			return // shut down by the Runtime, which sets its state
+		}
+		if err == nil {
+			err = errStoppedUnexpectedly
+		}
+		r.logger.Error("connector failed", "connector", h.name, "err", err)
+		h.setState(server.ConnectorStateFailed, err)
+	}()
+}
+
+// stopConnector shuts down the given connector, if it is running, and waits for it to stop.
+func (r *Runtime) stopConnector(h *hosted) {
+	h.mu.Lock()
+	cancel, done := h.cancel, h.done
+	h.cancel, h.done = nil, nil
+	h.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// stopAll shuts down all the hosted connectors concurrently, and waits for them to stop.
+func (r *Runtime) stopAll() {
+	r.opsMu.Lock()
+	defer r.opsMu.Unlock()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var wg sync.WaitGroup
+	for _, h := range r.connectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.stopConnector(h)
+		}()
+	}
+	wg.Wait()
+}
+
+// inherit returns a copy of the given connector configuration, which inherits the log, mongo and nats settings of the
+// Runtime it does not override. Its instance id defaults to the one of the Runtime suffixed with its name, and its
+// metrics and headers have the labels of the Runtime, along with its name.
+func (r *Runtime) inherit(name string, cfg *config.Connector) *config.Connector {
+	inherited := *cfg
+	if inherited.Instance.Id == "" {
+		inherited.Instance.Id = name
+		if r.base.Instance.Id != "" {
+			inherited.Instance.Id = r.base.Instance.Id + "-" + name
+		}
+	}
+	labels := make(map[string]string, len(r.base.Instance.Labels)+1)
+	maps.Copy(labels, r.base.Instance.Labels)
+	labels[connectorLabel] = name
+	inherited.Instance.Labels = labels
+	if inherited.Log.Level == "" {
+		inherited.Log = r.base.Log
+	}
+	if inherited.Mongo.Uri == "" {
+		inherited.Mongo = r.base.Mongo
+	}
+	if inherited.Nats.Url == "" {
+		inherited.Nats = r.base.Nats
+	}
+	return &inherited
+}
+
+func (h *hosted) definition() *definition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.def
+}
+
+func (h *hosted) setDefinition(def *definition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.def = def
+}
+
+func (h *hosted) setState(state string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state, h.err = state, err
+}
+
+func (h *hosted) info() server.ConnectorInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	info := server.ConnectorInfo{Name: h.name, State: h.state}
+	if h.err != nil {
+		info.Error = h.err.Error()
+	}
+	if h.conn != nil {
+		info.Config = h.conn.EffectiveConfig()
+		if h.state == server.ConnectorStateRunning {
+			info.Collections = h.conn.Status()
+		}
+	}
+	return info
+}
+
+type Option func(*Runtime)
+
+// WithBucket sets the NATS key-value bucket where the definitions of the hosted connectors are persisted.
+func WithBucket(bucket string) Option {
+	return func(r *Runtime) {
+		if bucket != "" {
+			r.bucket = bucket
+		}
+	}
+}
+
+// WithContext sets the Runtime's context.
+func WithContext(ctx context.Context) Option {
+	return func(r *Runtime) {
+		if ctx != nil {
+			r.ctx = ctx
+		}
+	}
+}
+
+// withKeyValue sets the key-value bucket where the definitions are persisted, instead of connecting to NATS.
+func withKeyValue(kv nats.KeyValue) Option {
+	return func(r *Runtime) {
+		if kv != nil {
+			r.kv = kv
+		}
+	}
+}
+
+// withConnectorFactory sets how the hosted connectors are created.
+func withConnectorFactory(newConnector connectorFactory) Option {
+	return func(r *Runtime) {
+		if newConnector != nil {
+			r.newConnector = newConnector
+		}
+	}
+}
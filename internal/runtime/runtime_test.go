@@ -0,0 +1,407 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/config"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+)
+
+const testDefinition = `
+collections:
+  - dbName: test-db
+    collName: test-coll
+`
+
+type testKeyValue struct {
+	mu       sync.Mutex
+	values   map[string][]byte
+	watchers map[string][]chan []byte
+}
+
+func newTestKeyValue() *testKeyValue {
+	return &testKeyValue{values: make(map[string][]byte), watchers: make(map[string][]chan []byte)}
+}
+
+func (kv *testKeyValue) Get(key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	value, ok := kv.values[key]
+	if !ok {
+		return nil, nats.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (kv *testKeyValue) Put(key string, value []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.values[key] = value
+	kv.notify(key, value)
+	return nil
+}
+
+// GetRevision and Update are not used by the runtime, hence revisions are not tracked.
+func (kv *testKeyValue) GetRevision(key string) ([]byte, uint64, error) {
+	value, err := kv.Get(key)
+	return value, 0, err
+}
+
+func (kv *testKeyValue) Update(key string, value []byte, _ uint64) (uint64, error) {
+	return 0, kv.Put(key, value)
+}
+
+func (kv *testKeyValue) Delete(key string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	delete(kv.values, key)
+	kv.notify(key, nil)
+	return nil
+}
+
+func (kv *testKeyValue) Keys() ([]string, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	keys := make([]string, 0, len(kv.values))
+	for key := range kv.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (kv *testKeyValue) Watch(_ context.Context, key string) (<-chan []byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	values := make(chan []byte, 16)
+	if value, ok := kv.values[key]; ok {
+		values <- value
+	}
+	kv.watchers[key] = append(kv.watchers[key], values)
+	return values, nil
+}
+
+func (kv *testKeyValue) notify(key string, value []byte) {
+	for _, values := range kv.watchers[key] {
+		values <- value
+	}
+}
+
+type testConnector struct {
+	ctx  context.Context
+	cfg  *config.Connector
+	fail chan error
+}
+
+func (c *testConnector) Run() error {
+	select {
+	case <-c.ctx.Done():
+		return nil
+	case err := <-c.fail:
+		return err
+	}
+}
+
+func (c *testConnector) EffectiveConfig() any {
+	return c.cfg
+}
+
+func (c *testConnector) Status() map[string]server.CollectionStatus {
+	return map[string]server.CollectionStatus{"test-db.test-coll": {State: server.CollectionStateRunning}}
+}
+
+// testFactory creates test connectors, keeping track of the last one created for each name.
+type testFactory struct {
+	mu         sync.Mutex
+	connectors map[string]*testConnector
+	err        error
+	// rejectedStream fails the creation of the connectors with a collection published to this stream.
+	rejectedStream string
+}
+
+func (f *testFactory) newConnector(ctx context.Context, name string, cfg *config.Connector) (hostedConnector, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, coll := range cfg.Collections {
+		if f.rejectedStream != "" && coll.StreamName == f.rejectedStream {
+			return nil, errors.New("stream rejected")
+		}
+	}
+	conn := &testConnector{ctx: ctx, cfg: cfg, fail: make(chan error, 1)}
+	f.connectors[name] = conn
+	return conn, nil
+}
+
+func (f *testFactory) connector(name string) *testConnector {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connectors[name]
+}
+
+func newTestRuntime(t *testing.T, kv nats.KeyValue) (*Runtime, *testFactory) {
+	t.Helper()
+	factory := &testFactory{connectors: make(map[string]*testConnector)}
+	base := &config.Connector{
+		Instance: config.Instance{Id: "runtime", Labels: map[string]string{"env": "test"}},
+		Mongo:    config.Mongo{Uri: "mongodb://localhost:27017"},
+		Nats:     config.Nats{Url: "nats://localhost:4222"},
+		Server:   config.Server{Addr: "127.0.0.1:0"},
+	}
+	r, err := New(base, withKeyValue(kv), withConnectorFactory(factory.newConnector))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		r.stopAll()
+		r.stop()
+	})
+	return r, factory
+}
+
+func requireState(t *testing.T, r *Runtime, name, state string) server.ConnectorInfo {
+	t.Helper()
+	var info server.ConnectorInfo
+	require.Eventually(t, func() bool {
+		var err error
+		info, err = r.Get(name)
+		return err == nil && info.State == state
+	}, 5*time.Second, 10*time.Millisecond)
+	return info
+}
+
+func TestRuntime_Put(t *testing.T) {
+	t.Run("should create then update a connector", func(t *testing.T) {
+		kv := newTestKeyValue()
+		r, factory := newTestRuntime(t, kv)
+
+		created, err := r.Put(context.Background(), "orders", []byte(testDefinition))
+		require.NoError(t, err)
+		require.True(t, created)
+		info := requireState(t, r, "orders", server.ConnectorStateRunning)
+		require.Contains(t, info.Collections, "test-db.test-coll")
+		first := factory.connector("orders")
+
+		created, err = r.Put(context.Background(), "orders", []byte(testDefinition))
+		require.NoError(t, err)
+		require.False(t, created)
+		requireState(t, r, "orders", server.ConnectorStateRunning)
+		require.NotSame(t, first, factory.connector("orders"))
+		require.Error(t, first.ctx.Err(), "the previous connector should be shut down")
+
+		def, err := r.definition("orders")
+		require.NoError(t, err)
+		require.False(t, def.Paused)
+		require.Equal(t, "test-coll", def.Connector.Collections[0].CollName)
+	})
+
+	t.Run("should keep an updated connector paused", func(t *testing.T) {
+		r, factory := newTestRuntime(t, newTestKeyValue())
+
+		_, err := r.Put(context.Background(), "orders", []byte(testDefinition))
+		require.NoError(t, err)
+		require.NoError(t, r.Pause(context.Background(), "orders"))
+		first := factory.connector("orders")
+
+		_, err = r.Put(context.Background(), "orders", []byte(testDefinition))
+		require.NoError(t, err)
+		requireState(t, r, "orders", server.ConnectorStatePaused)
+		require.Same(t, first, factory.connector("orders"))
+	})
+
+	t.Run("should fail the connector if it cannot be created", func(t *testing.T) {
+		r, factory := newTestRuntime(t, newTestKeyValue())
+		factory.err = errors.New("could not connect to mongo")
+
+		created, err := r.Put(context.Background(), "orders", []byte(testDefinition))
+		require.NoError(t, err)
+		require.True(t, created)
+		info := requireState(t, r, "orders", server.ConnectorStateFailed)
+		require.Equal(t, "could not connect to mongo", info.Error)
+	})
+
+	tests := []struct {
+		name       string
+		connector  string
+		definition string
+		wantErr    error
+	}{
+		{
+			name:       "should return error if the name is invalid",
+			connector:  "orders.v1",
+			definition: testDefinition,
+			wantErr:    server.ErrInvalidConnectorName,
+		},
+		{
+			name:       "should return error if the definition is invalid",
+			connector:  "orders",
+			definition: "unknown: true",
+			wantErr:    server.ErrInvalidConnectorDef,
+		},
+		{
+			name:       "should return error if the definition has no collections",
+			connector:  "orders",
+			definition: "log:\n  level: debug",
+			wantErr:    ErrCollectionsMissing,
+		},
+		{
+			name:       "should return error if the definition has instance labels",
+			connector:  "orders",
+			definition: testDefinition + "instance:\n  labels:\n    team: sales",
+			wantErr:    ErrLabelsNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv := newTestKeyValue()
+			r, _ := newTestRuntime(t, kv)
+
+			_, err := r.Put(context.Background(), tt.connector, []byte(tt.definition))
+			require.ErrorContains(t, err, tt.wantErr.Error())
+			require.Empty(t, r.List())
+			require.Empty(t, kv.values)
+		})
+	}
+}
+
+func TestRuntime_PauseResume(t *testing.T) {
+	kv := newTestKeyValue()
+	r, factory := newTestRuntime(t, kv)
+
+	_, err := r.Put(context.Background(), "orders", []byte(testDefinition))
+	require.NoError(t, err)
+	first := factory.connector("orders")
+
+	require.NoError(t, r.Pause(context.Background(), "orders"))
+	requireState(t, r, "orders", server.ConnectorStatePaused)
+	require.Error(t, first.ctx.Err(), "the connector should be shut down")
+	def, err := r.definition("orders")
+	require.NoError(t, err)
+	require.True(t, def.Paused)
+
+	require.NoError(t, r.Resume(context.Background(), "orders"))
+	requireState(t, r, "orders", server.ConnectorStateRunning)
+	require.NotSame(t, first, factory.connector("orders"))
+	def, err = r.definition("orders")
+	require.NoError(t, err)
+	require.False(t, def.Paused)
+
+	require.ErrorIs(t, r.Pause(context.Background(), "unknown"), server.ErrConnectorNotFound)
+	require.ErrorIs(t, r.Resume(context.Background(), "unknown"), server.ErrConnectorNotFound)
+}
+
+func TestRuntime_Delete(t *testing.T) {
+	kv := newTestKeyValue()
+	r, factory := newTestRuntime(t, kv)
+
+	_, err := r.Put(context.Background(), "orders", []byte(testDefinition))
+	require.NoError(t, err)
+
+	require.NoError(t, r.Delete(context.Background(), "orders"))
+	require.Empty(t, r.List())
+	require.Empty(t, kv.values)
+	require.Error(t, factory.connector("orders").ctx.Err(), "the connector should be shut down")
+
+	require.ErrorIs(t, r.Delete(context.Background(), "orders"), server.ErrConnectorNotFound)
+}
+
+func TestRuntime_failure(t *testing.T) {
+	r, factory := newTestRuntime(t, newTestKeyValue())
+
+	for _, name := range []string{"orders", "payments"} {
+		_, err := r.Put(context.Background(), name, []byte(testDefinition))
+		require.NoError(t, err)
+	}
+
+	factory.connector("orders").fail <- errors.New("change stream closed")
+	info := requireState(t, r, "orders", server.ConnectorStateFailed)
+	require.Equal(t, "change stream closed", info.Error)
+	requireState(t, r, "payments", server.ConnectorStateRunning)
+
+	require.NoError(t, r.Resume(context.Background(), "orders"))
+	requireState(t, r, "orders", server.ConnectorStateRunning)
+}
+
+func TestRuntime_load(t *testing.T) {
+	kv := newTestKeyValue()
+	require.NoError(t, kv.Put("orders", []byte("connector:"+indent(testDefinition))))
+	require.NoError(t, kv.Put("payments", []byte("paused: true\nconnector:"+indent(testDefinition))))
+	require.NoError(t, kv.Put("invalid", []byte("paused: [")))
+	r, factory := newTestRuntime(t, kv)
+
+	require.NoError(t, r.load())
+
+	names := make([]string, 0, 3)
+	for _, info := range r.List() {
+		names = append(names, info.Name)
+	}
+	require.Equal(t, []string{"invalid", "orders", "payments"}, names)
+	requireState(t, r, "orders", server.ConnectorStateRunning)
+	requireState(t, r, "payments", server.ConnectorStatePaused)
+	requireState(t, r, "invalid", server.ConnectorStateFailed)
+	require.Nil(t, factory.connector("payments"))
+
+	// a failed definition can be replaced
+	created, err := r.Put(context.Background(), "invalid", []byte(testDefinition))
+	require.NoError(t, err)
+	require.False(t, created)
+	requireState(t, r, "invalid", server.ConnectorStateRunning)
+}
+
+func TestRuntime_inherit(t *testing.T) {
+	r, _ := newTestRuntime(t, newTestKeyValue())
+
+	cfg := r.inherit("orders", &config.Connector{})
+	require.Equal(t, "runtime-orders", cfg.Instance.Id)
+	require.Equal(t, map[string]string{"env": "test", "connector": "orders"}, cfg.Instance.Labels)
+	require.Equal(t, "mongodb://localhost:27017", cfg.Mongo.Uri)
+	require.Equal(t, "nats://localhost:4222", cfg.Nats.Url)
+
+	cfg = r.inherit("orders", &config.Connector{
+		Instance: config.Instance{Id: "orders-1"},
+		Mongo:    config.Mongo{Uri: "mongodb://orders:27017"},
+	})
+	require.Equal(t, "orders-1", cfg.Instance.Id)
+	require.Equal(t, "mongodb://orders:27017", cfg.Mongo.Uri)
+	require.Equal(t, "nats://localhost:4222", cfg.Nats.Url)
+}
+
+// indent nests the given yaml document under a key.
+func indent(doc string) string {
+	return strings.ReplaceAll(doc, "\n", "\n  ")
+}
+
+func Test_serverAuthenticators(t *testing.T) {
+	t.Run("should return the authenticators of the server auth", func(t *testing.T) {
+		tokensFile := filepath.Join(t.TempDir(), "tokens")
+		require.NoError(t, os.WriteFile(tokensFile, []byte("s3cr3t\n"), 0o600))
+
+		authenticators, err := serverAuthenticators(&config.ServerAuth{BearerTokensFile: tokensFile,
+			Oidc: &config.Oidc{Issuer: "https://sso.example.com", Audience: "connector"}})
+
+		require.NoError(t, err)
+		require.Len(t, authenticators, 2)
+		require.IsType(t, &server.BearerTokenAuthenticator{}, authenticators[0])
+		require.IsType(t, &server.OidcAuthenticator{}, authenticators[1])
+	})
+	t.Run("should return no authenticators without server auth", func(t *testing.T) {
+		authenticators, err := serverAuthenticators(nil)
+
+		require.NoError(t, err)
+		require.Empty(t, authenticators)
+	})
+	t.Run("should return error if the bearer tokens file cannot be read", func(t *testing.T) {
+		_, err := serverAuthenticators(&config.ServerAuth{BearerTokensFile: filepath.Join(t.TempDir(), "missing")})
+
+		require.Error(t, err)
+	})
+}
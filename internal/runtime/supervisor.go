@@ -0,0 +1,267 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/config"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
+)
+
+const defaultConfigKey = "connector"
+
+var (
+	ErrConfigBucketMissing = errors.New("the key-value bucket holding the configuration is required")
+
+	errWatchStopped = errors.New("configuration watch stopped unexpectedly")
+)
+
+// The Supervisor type represents a process running a single connector, whose configuration is loaded from a key of a
+// NATS key-value bucket instead of a config file. The key is watched, and each time the configuration changes, the
+// connector is restarted with it, so that NATS is the control plane of the connector.
+// Invalid or deleted configurations are rejected, and the connector keeps running with its current configuration.
+type Supervisor struct {
+	ctx  context.Context
+	stop context.CancelFunc
+
+	// bootstrap holds the settings used to connect to NATS, and to log.
+	bootstrap *config.Connector
+	bucket    string
+	key       string
+	override  func(*config.Connector)
+	logger    *slog.Logger
+
+	natsClient   *nats.DefaultClient
+	kv           nats.KeyValue
+	newConnector connectorFactory
+}
+
+// NewSupervisor creates a new Supervisor, connecting to NATS with the given bootstrap configuration.
+// The given options will override its default configuration.
+func NewSupervisor(bootstrap *config.Connector, opts ...SupervisorOption) (*Supervisor, error) {
+	s := &Supervisor{
+		ctx:          context.Background(),
+		bootstrap:    bootstrap,
+		key:          defaultConfigKey,
+		override:     func(*config.Connector) {},
+		newConnector: newSupervisedConnector,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.bucket == "" && s.kv == nil {
+		return nil, ErrConfigBucketMissing
+	}
+
+	var level slog.Level // unknown levels default to info, as for the connectors
+	_ = level.UnmarshalText([]byte(bootstrap.Log.Level))
+	s.logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	if bootstrap.Instance.Id != "" {
+		s.logger = s.logger.With("instanceId", bootstrap.Instance.Id)
+	}
+
+	if s.kv == nil {
+		natsClient, err := nats.NewDefaultClient(
+			nats.WithNatsUrl(bootstrap.Nats.Url),
+			nats.WithCredsFile(bootstrap.Nats.CredsFile),
+			nats.WithTlsFiles(bootstrap.Nats.CertFile, bootstrap.Nats.KeyFile, bootstrap.Nats.CaFile),
+			nats.WithLogger(s.logger),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if s.kv, err = natsClient.KeyValue(s.bucket); err != nil {
+			_ = natsClient.Close()
+			return nil, err
+		}
+		s.natsClient = natsClient
+	}
+
+	s.ctx, s.stop = signal.NotifyContext(s.ctx, syscall.SIGINT, syscall.SIGTERM)
+
+	return s, nil
+}
+
+// newSupervisedConnector creates the connector with the given configuration, serving its own HTTP server.
+func newSupervisedConnector(ctx context.Context, _ string, cfg *config.Connector) (hostedConnector, error) {
+	return connector.New(append(cfg.Options(), connector.WithContext(ctx))...)
+}
+
+// Run runs the Supervisor.
+// It watches the configuration key, and runs the connector with its latest valid configuration until its context is
+// cancelled, then shuts it down. It returns an error if the connector fails, or cannot be restarted.
+func (s *Supervisor) Run() error {
+	defer s.cleanup()
+
+	values, err := s.kv.Watch(s.ctx, s.key)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("waiting for configuration", "bucket", s.bucket, "key", s.key)
+
+	var (
+		current *config.Connector
+		cancel  context.CancelFunc
+		done    chan error // nil while no connector is running
+	)
+	stopConnector := func() {
+		if cancel == nil {
+			return
+		}
+		cancel()
+		if err := <-done; err != nil {
+			s.logger.Warn("connector was not shut down cleanly", "err", err)
+		}
+		cancel, done = nil, nil
+	}
+	startConnector := func(cfg *config.Connector) error {
+		ctx, c := context.WithCancel(s.ctx)
+		conn, err := s.newConnector(ctx, s.key, cfg)
+		if err != nil {
+			c()
+			return err
+		}
+		cancel, done = c, make(chan error, 1)
+		go func(done chan<- error) {
+			done <- conn.Run() // blocking call
+		}(done)
+		return nil
+	}
+	defer stopConnector()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case err := <-done:
+			cancel()
+			cancel, done = nil, nil
+			if err == nil {
+				err = errStoppedUnexpectedly
+			}
+			return err
+		case data, ok := <-values:
+			if !ok {
+				if s.ctx.Err() != nil {
+					return nil
+				}
+				return errWatchStopped
+			}
+			cfg, err := s.parse(data)
+			if err != nil {
+				s.logger.Error("configuration rejected, the connector keeps its current configuration", "err", err)
+				continue
+			}
+			if current != nil {
+				changes := config.Diff(current, cfg)
+				if len(changes) == 0 {
+					s.logger.Debug("configuration unchanged")
+					continue
+				}
+				s.logger.Info("applying configuration changes", "changes", changes)
+			}
+			stopConnector()
+			if err = startConnector(cfg); err != nil {
+				if current == nil {
+					s.logger.Error("could not create connector, waiting for another configuration", "err", err)
+					continue
+				}
+				s.logger.Error("could not create connector, restoring the previous configuration", "err", err)
+				if err = startConnector(current); err != nil {
+					return fmt.Errorf("could not restore the previous configuration: %w", err)
+				}
+				continue
+			}
+			current = cfg
+			s.logger.Info("connector started", "collections", len(cfg.Collections))
+		}
+	}
+}
+
+// parse returns the connector configuration held by the given value, i.e. the content of the connector section of a
+// config file, in yaml or json, with the overrides applied.
+func (s *Supervisor) parse(data []byte) (*config.Connector, error) {
+	if data == nil {
+		return nil, errors.New("configuration deleted")
+	}
+	cfg, err := config.ParseConnector(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Collections) == 0 {
+		return nil, ErrCollectionsMissing
+	}
+	s.override(cfg)
+	return cfg, nil
+}
+
+func (s *Supervisor) cleanup() {
+	if s.natsClient != nil {
+		if err := s.natsClient.Close(); err != nil {
+			s.logger.Error("could not close client", "err", err)
+		}
+	}
+	s.stop()
+}
+
+type SupervisorOption func(*Supervisor)
+
+// WithConfigBucket sets the NATS key-value bucket holding the configuration of the connector.
+func WithConfigBucket(bucket string) SupervisorOption {
+	return func(s *Supervisor) {
+		if bucket != "" {
+			s.bucket = bucket
+		}
+	}
+}
+
+// WithConfigKey sets the key holding the configuration of the connector, "connector" by default.
+func WithConfigKey(key string) SupervisorOption {
+	return func(s *Supervisor) {
+		if key != "" {
+			s.key = key
+		}
+	}
+}
+
+// WithOverride sets a function applied to each configuration loaded, e.g. to override it with environment variables.
+func WithOverride(override func(*config.Connector)) SupervisorOption {
+	return func(s *Supervisor) {
+		if override != nil {
+			s.override = override
+		}
+	}
+}
+
+// WithSupervisorContext sets the Supervisor's context.
+func WithSupervisorContext(ctx context.Context) SupervisorOption {
+	return func(s *Supervisor) {
+		if ctx != nil {
+			s.ctx = ctx
+		}
+	}
+}
+
+// withSupervisorKeyValue sets the key-value bucket holding the configuration, instead of connecting to NATS.
+func withSupervisorKeyValue(kv nats.KeyValue) SupervisorOption {
+	return func(s *Supervisor) {
+		if kv != nil {
+			s.kv = kv
+		}
+	}
+}
+
+// withSupervisorConnectorFactory sets how the connector is created.
+func withSupervisorConnectorFactory(newConnector connectorFactory) SupervisorOption {
+	return func(s *Supervisor) {
+		if newConnector != nil {
+			s.newConnector = newConnector
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/config"
+)
+
+func newTestSupervisor(t *testing.T, kv *testKeyValue) (*Supervisor, *testFactory, chan error) {
+	t.Helper()
+	factory := &testFactory{connectors: make(map[string]*testConnector)}
+	s, err := NewSupervisor(&config.Connector{},
+		withSupervisorKeyValue(kv),
+		withSupervisorConnectorFactory(factory.newConnector),
+		WithOverride(func(cfg *config.Connector) { cfg.Mongo.Uri = "mongodb://override:27017" }),
+	)
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run()
+	}()
+	t.Cleanup(func() {
+		s.stop()
+		<-done
+	})
+	return s, factory, done
+}
+
+func requireConnector(t *testing.T, factory *testFactory, previous *testConnector) *testConnector {
+	t.Helper()
+	var conn *testConnector
+	require.Eventually(t, func() bool {
+		conn = factory.connector(defaultConfigKey)
+		return conn != nil && conn != previous
+	}, 5*time.Second, 10*time.Millisecond)
+	return conn
+}
+
+func TestSupervisor_Run(t *testing.T) {
+	t.Run("should start the connector with the configuration, then restart it once it changes", func(t *testing.T) {
+		kv := newTestKeyValue()
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition))
+		_, factory, _ := newTestSupervisor(t, kv)
+
+		first := requireConnector(t, factory, nil)
+		require.Equal(t, "test-coll", first.cfg.Collections[0].CollName)
+		require.Equal(t, "mongodb://override:27017", first.cfg.Mongo.Uri)
+
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition+"    streamName: OTHER\n"))
+
+		second := requireConnector(t, factory, first)
+		require.Error(t, first.ctx.Err(), "the previous connector should be shut down")
+		require.Equal(t, "OTHER", second.cfg.Collections[0].StreamName)
+	})
+
+	t.Run("should wait for the configuration if the key does not exist", func(t *testing.T) {
+		kv := newTestKeyValue()
+		_, factory, _ := newTestSupervisor(t, kv)
+
+		time.Sleep(50 * time.Millisecond)
+		require.Nil(t, factory.connector(defaultConfigKey))
+
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition))
+		requireConnector(t, factory, nil)
+	})
+
+	t.Run("should keep the connector running if the configuration is unchanged, invalid or deleted", func(t *testing.T) {
+		kv := newTestKeyValue()
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition))
+		_, factory, _ := newTestSupervisor(t, kv)
+		first := requireConnector(t, factory, nil)
+
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition))
+		_ = kv.Put(defaultConfigKey, []byte("collections: ["))
+		_ = kv.Put(defaultConfigKey, []byte("collections: []"))
+		_ = kv.Delete(defaultConfigKey)
+
+		time.Sleep(50 * time.Millisecond)
+		require.Same(t, first, factory.connector(defaultConfigKey))
+		require.NoError(t, first.ctx.Err())
+	})
+
+	t.Run("should restore the previous configuration if the connector cannot be created", func(t *testing.T) {
+		kv := newTestKeyValue()
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition))
+		_, factory, _ := newTestSupervisor(t, kv)
+		first := requireConnector(t, factory, nil)
+
+		factory.mu.Lock()
+		factory.rejectedStream = "OTHER"
+		factory.mu.Unlock()
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition+"    streamName: OTHER\n"))
+
+		restored := requireConnector(t, factory, first)
+		require.Error(t, first.ctx.Err(), "the previous connector should be shut down")
+		require.Empty(t, restored.cfg.Collections[0].StreamName)
+	})
+
+	t.Run("should return error if the connector fails", func(t *testing.T) {
+		kv := newTestKeyValue()
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition))
+		_, factory, done := newTestSupervisor(t, kv)
+		conn := requireConnector(t, factory, nil)
+
+		conn.fail <- errors.New("change stream failed")
+
+		select {
+		case err := <-done:
+			require.EqualError(t, err, "change stream failed")
+			done <- err
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "supervisor did not stop")
+		}
+	})
+
+	t.Run("should shut down the connector once cancelled", func(t *testing.T) {
+		kv := newTestKeyValue()
+		_ = kv.Put(defaultConfigKey, []byte(testDefinition))
+		s, factory, done := newTestSupervisor(t, kv)
+		conn := requireConnector(t, factory, nil)
+
+		s.stop()
+
+		require.NoError(t, <-done)
+		require.Error(t, conn.ctx.Err())
+		done <- nil
+	})
+}
+
+func TestNewSupervisor(t *testing.T) {
+	_, err := NewSupervisor(&config.Connector{}, WithSupervisorContext(context.Background()))
+
+	require.ErrorIs(t, err, ErrConfigBucketMissing)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNoBearer     = errors.New("request holds no bearer token")
+)
+
+// Authenticator authenticates the requests served by the server, e.g. by validating their bearer token, so that the
+// admin endpoints can be exposed without a reverse proxy.
+type Authenticator interface {
+	// Authenticate returns nil if the given request is authenticated, or the reason why it is not otherwise.
+	Authenticate(r *http.Request) error
+}
+
+// authenticate serves the requests authenticated by any of the given authenticators, and responds with 401 to the other
+// ones. The health checks and the metrics are served without authentication, so that probes and scrapers need no
+// credentials, along with the files of the dashboard, which hold no data.
+func authenticate(logger *slog.Logger, authenticators []Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticated(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var errs []error
+		for _, authenticator := range authenticators {
+			err := authenticator.Authenticate(r)
+			if err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			errs = append(errs, err)
+		}
+		logger.Debug("could not authenticate request", "requestId", RequestId(r.Context()), "method", r.Method,
+			"path", r.URL.Path, "err", errors.Join(errs...))
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJsonError(w, http.StatusUnauthorized, ErrUnauthorized)
+	})
+}
+
+// unauthenticated returns true if the given path is served without authentication.
+func unauthenticated(p string) bool {
+	p = path.Clean(p)
+	return p == "/healthz" || p == "/metrics" || p == "/ui" || strings.HasPrefix(p, "/ui/")
+}
+
+// bearerToken returns the bearer token of the Authorization header of the given request.
+func bearerToken(r *http.Request) (string, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrNoBearer
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// BearerTokenAuthenticator authenticates the requests holding one of the given static bearer tokens, e.g. the ones of
+// the automation operating the connector.
+type BearerTokenAuthenticator struct {
+	tokens [][]byte
+}
+
+// NewBearerTokenAuthenticator creates a new BearerTokenAuthenticator accepting the tokens of the given file, one per
+// line, where the empty lines and the ones starting with # are ignored.
+func NewBearerTokenAuthenticator(tokensFile string) (*BearerTokenAuthenticator, error) {
+	data, err := os.ReadFile(tokensFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bearer tokens file: %v", err)
+	}
+	a := &BearerTokenAuthenticator{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if token := strings.TrimSpace(line); token != "" && !strings.HasPrefix(token, "#") {
+			a.tokens = append(a.tokens, []byte(token))
+		}
+	}
+	if len(a.tokens) == 0 {
+		return nil, fmt.Errorf("bearer tokens file %v holds no token", tokensFile)
+	}
+	return a, nil
+}
+
+// Authenticate checks that the bearer token of the given request is one of the accepted tokens, in constant time, so
+// that they cannot be guessed by timing the responses.
+func (a *BearerTokenAuthenticator) Authenticate(r *http.Request) error {
+	token, err := bearerToken(r)
+	if err != nil {
+		return err
+	}
+	accepted := 0
+	for _, t := range a.tokens {
+		accepted |= subtle.ConstantTimeCompare([]byte(token), t)
+	}
+	if accepted == 0 {
+		return errors.New("bearer token is not accepted")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authenticate(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := authenticate(slog.Default(), []Authenticator{&testAuthenticator{token: "first"},
+		&testAuthenticator{token: "second"}}, next)
+
+	t.Run("should serve the requests authenticated by any authenticator", func(t *testing.T) {
+		for _, token := range []string{"first", "second"} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/status", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusNoContent, rec.Code)
+		}
+	})
+	t.Run("should respond with 401 to the requests not authenticated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/collections/db.coll1/disable", nil)
+		req.Header.Set("Authorization", "Bearer third")
+
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+		require.JSONEq(t, `{"error":{"code":401,"message":"unauthorized"}}`, rec.Body.String())
+	})
+	t.Run("should serve the health checks, the metrics and the dashboard without authentication", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/metrics", "/ui", "/ui/dashboard.js"} {
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			require.Equal(t, http.StatusNoContent, rec.Code)
+		}
+	})
+}
+
+func Test_unauthenticated(t *testing.T) {
+	require.True(t, unauthenticated("/ui/"))
+	require.True(t, unauthenticated("/healthz/"))
+	require.False(t, unauthenticated("/ui/../admin/config"))
+	require.False(t, unauthenticated("/uinfo"))
+}
+
+func TestBearerTokenAuthenticator_Authenticate(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokensFile, []byte("# automation\ns3cr3t\n\n  0th3r  \n"), 0o600))
+	authenticator, err := NewBearerTokenAuthenticator(tokensFile)
+	require.NoError(t, err)
+
+	t.Run("should authenticate the requests holding an accepted token", func(t *testing.T) {
+		for _, authorization := range []string{"Bearer s3cr3t", "bearer 0th3r"} {
+			req := httptest.NewRequest(http.MethodGet, "/status", nil)
+			req.Header.Set("Authorization", authorization)
+
+			require.NoError(t, authenticator.Authenticate(req))
+		}
+	})
+	t.Run("should return error if the token is not accepted", func(t *testing.T) {
+		for _, authorization := range []string{"Bearer s3cr3", "Bearer # automation", "Basic s3cr3t"} {
+			req := httptest.NewRequest(http.MethodGet, "/status", nil)
+			req.Header.Set("Authorization", authorization)
+
+			require.Error(t, authenticator.Authenticate(req))
+		}
+	})
+	t.Run("should return error if the request holds no token", func(t *testing.T) {
+		require.ErrorIs(t, authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/status", nil)), ErrNoBearer)
+	})
+	t.Run("should return error if the tokens file holds no token", func(t *testing.T) {
+		emptyFile := filepath.Join(t.TempDir(), "tokens")
+		require.NoError(t, os.WriteFile(emptyFile, []byte("# none yet\n"), 0o600))
+
+		_, err := NewBearerTokenAuthenticator(emptyFile)
+
+		require.ErrorContains(t, err, "holds no token")
+	})
+	t.Run("should return error if the tokens file cannot be read", func(t *testing.T) {
+		_, err := NewBearerTokenAuthenticator(filepath.Join(t.TempDir(), "missing"))
+
+		require.ErrorContains(t, err, "could not read bearer tokens file")
+	})
+}
+
+type testAuthenticator struct {
+	token string
+}
+
+func (a *testAuthenticator) Authenticate(r *http.Request) error {
+	if token, err := bearerToken(r); err != nil || token != a.token {
+		return errors.New("not authenticated")
+	}
+	return nil
+}
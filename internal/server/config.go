@@ -0,0 +1,9 @@
+package server
+
+import "net/http"
+
+func effectiveConfig(config any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, config)
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_effectiveConfig(t *testing.T) {
+	config := struct {
+		LogLevel string `json:"logLevel"`
+		MongoUri string `json:"mongoUri"`
+	}{LogLevel: "info", MongoUri: "mongodb://REDACTED@localhost:27017"}
+
+	rec := httptest.NewRecorder()
+	effectiveConfig(config)(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"logLevel":"info","mongoUri":"mongodb://REDACTED@localhost:27017"}`, rec.Body.String())
+}
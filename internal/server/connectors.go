@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+const (
+	ConnectorStateRunning = "running"
+	ConnectorStatePaused  = "paused"
+	ConnectorStateFailed  = "failed"
+)
+
+// maxDefinitionSize is the maximum size of the connector definitions accepted by the connectors api.
+const maxDefinitionSize = 1 << 20
+
+var (
+	ErrConnectorNotFound    = errors.New("connector not found")
+	ErrInvalidConnectorName = errors.New("invalid connector name")
+	ErrInvalidConnectorDef  = errors.New("invalid connector definition")
+	ErrConnectorDefTooLarge = errors.New("connector definition too large")
+)
+
+// Connectors manages the connectors hosted by a runtime, each with its own definition.
+type Connectors interface {
+	List() []ConnectorInfo
+	Get(name string) (ConnectorInfo, error)
+	// Put creates the named connector, or updates its definition and restarts it. The returned flag is true if the
+	// connector was created.
+	Put(ctx context.Context, name string, definition []byte) (created bool, err error)
+	Delete(ctx context.Context, name string) error
+	Pause(ctx context.Context, name string) error
+	Resume(ctx context.Context, name string) error
+}
+
+// ConnectorInfo holds the state of a connector hosted by a runtime.
+type ConnectorInfo struct {
+	Name        string                      `json:"name"`
+	State       string                      `json:"state"`
+	Error       string                      `json:"error,omitempty"`
+	Config      any                         `json:"config,omitempty"`
+	Collections map[string]CollectionStatus `json:"collections,omitempty"`
+}
+
+func listConnectors(c Connectors) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, &connectorsResponse{Connectors: c.List()})
+	}
+}
+
+func getConnector(c Connectors) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := c.Get(r.PathValue("name"))
+		if err != nil {
+			writeConnectorError(w, err)
+			return
+		}
+		writeJson(w, http.StatusOK, &info)
+	}
+}
+
+func putConnector(c Connectors) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		definition, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDefinitionSize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeJsonError(w, http.StatusRequestEntityTooLarge, ErrConnectorDefTooLarge)
+				return
+			}
+			writeJsonError(w, http.StatusBadRequest, err)
+			return
+		}
+		name := r.PathValue("name")
+		created, err := c.Put(r.Context(), name, definition)
+		if err != nil {
+			writeConnectorError(w, err)
+			return
+		}
+		code := http.StatusOK
+		if created {
+			code = http.StatusCreated
+		}
+		writeConnector(w, c, name, code)
+	}
+}
+
+func deleteConnector(c Connectors) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Delete(r.Context(), r.PathValue("name")); err != nil {
+			writeConnectorError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func pauseConnector(c Connectors) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if err := c.Pause(r.Context(), name); err != nil {
+			writeConnectorError(w, err)
+			return
+		}
+		writeConnector(w, c, name, http.StatusOK)
+	}
+}
+
+func resumeConnector(c Connectors) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if err := c.Resume(r.Context(), name); err != nil {
+			writeConnectorError(w, err)
+			return
+		}
+		writeConnector(w, c, name, http.StatusOK)
+	}
+}
+
+func writeConnector(w http.ResponseWriter, c Connectors, name string, code int) {
+	info, err := c.Get(name)
+	if err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+	writeJson(w, code, &info)
+}
+
+func writeConnectorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrConnectorNotFound):
+		writeJsonError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvalidConnectorName), errors.Is(err, ErrInvalidConnectorDef):
+		writeJsonError(w, http.StatusBadRequest, err)
+	default:
+		writeJsonError(w, http.StatusInternalServerError, err)
+	}
+}
+
+type connectorsResponse struct {
+	Connectors []ConnectorInfo `json:"connectors"`
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConnectors struct {
+	connectors map[string]*ConnectorInfo
+}
+
+func (c *testConnectors) List() []ConnectorInfo {
+	list := make([]ConnectorInfo, 0, len(c.connectors))
+	for _, info := range c.connectors {
+		list = append(list, *info)
+	}
+	return list
+}
+
+func (c *testConnectors) Get(name string) (ConnectorInfo, error) {
+	info, ok := c.connectors[name]
+	if !ok {
+		return ConnectorInfo{}, ErrConnectorNotFound
+	}
+	return *info, nil
+}
+
+func (c *testConnectors) Put(_ context.Context, name string, definition []byte) (bool, error) {
+	if string(definition) == "invalid" {
+		return false, fmt.Errorf("%w: unknown field", ErrInvalidConnectorDef)
+	}
+	_, ok := c.connectors[name]
+	c.connectors[name] = &ConnectorInfo{Name: name, State: ConnectorStateRunning}
+	return !ok, nil
+}
+
+func (c *testConnectors) Delete(_ context.Context, name string) error {
+	if _, ok := c.connectors[name]; !ok {
+		return ErrConnectorNotFound
+	}
+	delete(c.connectors, name)
+	return nil
+}
+
+func (c *testConnectors) Pause(_ context.Context, name string) error {
+	return c.setState(name, ConnectorStatePaused)
+}
+
+func (c *testConnectors) Resume(_ context.Context, name string) error {
+	return c.setState(name, ConnectorStateRunning)
+}
+
+func (c *testConnectors) setState(name, state string) error {
+	info, ok := c.connectors[name]
+	if !ok {
+		return ErrConnectorNotFound
+	}
+	info.State = state
+	return nil
+}
+
+func Test_connectors(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "should list the connectors",
+			method:   http.MethodGet,
+			url:      "/connectors",
+			wantCode: http.StatusOK,
+			wantBody: `{"connectors":[{"name":"orders","state":"running"}]}`,
+		},
+		{
+			name:     "should get the given connector",
+			method:   http.MethodGet,
+			url:      "/connectors/orders",
+			wantCode: http.StatusOK,
+			wantBody: `{"name":"orders","state":"running"}`,
+		},
+		{
+			name:     "should return not found if the connector is unknown",
+			method:   http.MethodGet,
+			url:      "/connectors/unknown",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":{"code":404,"message":"connector not found","requestId":"req-1"}}`,
+		},
+		{
+			name:     "should create the given connector",
+			method:   http.MethodPut,
+			url:      "/connectors/users",
+			body:     "collections: []",
+			wantCode: http.StatusCreated,
+			wantBody: `{"name":"users","state":"running"}`,
+		},
+		{
+			name:     "should update the given connector",
+			method:   http.MethodPut,
+			url:      "/connectors/orders",
+			body:     "collections: []",
+			wantCode: http.StatusOK,
+			wantBody: `{"name":"orders","state":"running"}`,
+		},
+		{
+			name:     "should return bad request if the definition is invalid",
+			method:   http.MethodPut,
+			url:      "/connectors/orders",
+			body:     "invalid",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":{"code":400,"message":"invalid connector definition: unknown field","requestId":"req-1"}}`,
+		},
+		{
+			name:     "should return request too large if the definition is too large",
+			method:   http.MethodPut,
+			url:      "/connectors/orders",
+			body:     strings.Repeat("a", maxDefinitionSize+1),
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `{"error":{"code":413,"message":"connector definition too large","requestId":"req-1"}}`,
+		},
+		{
+			name:     "should pause the given connector",
+			method:   http.MethodPost,
+			url:      "/connectors/orders/pause",
+			wantCode: http.StatusOK,
+			wantBody: `{"name":"orders","state":"paused"}`,
+		},
+		{
+			name:     "should resume the given connector",
+			method:   http.MethodPost,
+			url:      "/connectors/orders/resume",
+			wantCode: http.StatusOK,
+			wantBody: `{"name":"orders","state":"running"}`,
+		},
+		{
+			name:     "should delete the given connector",
+			method:   http.MethodDelete,
+			url:      "/connectors/orders",
+			wantCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connectors := &testConnectors{connectors: map[string]*ConnectorInfo{
+				"orders": {Name: "orders", State: ConnectorStateRunning},
+			}}
+			srv := New(WithConnectors(connectors))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("X-Request-Id", "req-1")
+			srv.http.Handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantBody == "" {
+				require.Empty(t, rec.Body.String())
+				return
+			}
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			require.True(t, json.Valid(rec.Body.Bytes()))
+			require.JSONEq(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// cutoverTimeout is the maximum amount of time to wait for a cutover to be applied.
+const cutoverTimeout = time.Minute
+
+var (
+	ErrCollectionNotFound   = errors.New("collection not found")
+	ErrInvalidSchemaVersion = errors.New("invalid schema version: it must be greater than 0")
+	ErrCutoverInProgress    = errors.New("a cutover to another schema version is in progress")
+)
+
+// Cutovers switches the schema version prefixing the subjects of the change events of the watched collections.
+type Cutovers interface {
+	// Cutover switches the schema version of the given collection, once its pending change events are published with
+	// the current one, and returns once the new one is applied.
+	Cutover(ctx context.Context, namespace string, version int) error
+}
+
+type cutoverRequest struct {
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+type cutoverResponse struct {
+	Collection    string `json:"collection"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+func cutover(c Cutovers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req cutoverRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDefinitionSize)).Decode(&req); err != nil {
+			writeJsonError(w, http.StatusBadRequest, fmt.Errorf("invalid cutover request: %v", err))
+			return
+		}
+		if req.SchemaVersion < 1 {
+			writeJsonError(w, http.StatusBadRequest, ErrInvalidSchemaVersion)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cutoverTimeout)
+		defer cancel()
+		namespace := r.PathValue("namespace")
+		switch err := c.Cutover(ctx, namespace, req.SchemaVersion); {
+		case err == nil:
+			writeJson(w, http.StatusOK, &cutoverResponse{Collection: namespace, SchemaVersion: req.SchemaVersion})
+		case errors.Is(err, ErrCollectionNotFound):
+			writeJsonError(w, http.StatusNotFound, err)
+		case errors.Is(err, ErrCutoverInProgress):
+			writeJsonError(w, http.StatusConflict, err)
+		case errors.Is(err, context.DeadlineExceeded):
+			writeJsonError(w, http.StatusGatewayTimeout,
+				fmt.Errorf("cutover cancelled: the pending change events were not published in time: %v", err))
+		default:
+			writeJsonError(w, http.StatusInternalServerError, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCutovers struct {
+	versions map[string]int
+	err      error
+}
+
+func (c *testCutovers) Cutover(_ context.Context, namespace string, version int) error {
+	if c.err != nil {
+		return c.err
+	}
+	if _, ok := c.versions[namespace]; !ok {
+		return ErrCollectionNotFound
+	}
+	c.versions[namespace] = version
+	return nil
+}
+
+func Test_cutover(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		body     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "should switch the schema version of the given collection",
+			url:      "/admin/collections/shop.orders/cutover",
+			body:     `{"schemaVersion":2}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"collection":"shop.orders","schemaVersion":2}`,
+		},
+		{
+			name:     "should return not found if the collection is not watched",
+			url:      "/admin/collections/shop.users/cutover",
+			body:     `{"schemaVersion":2}`,
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":{"code":404,"message":"collection not found","requestId":"req-1"}}`,
+		},
+		{
+			name:     "should return bad request if the schema version is invalid",
+			url:      "/admin/collections/shop.orders/cutover",
+			body:     `{"schemaVersion":0}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":{"code":400,"message":"invalid schema version: it must be greater than 0","requestId":"req-1"}}`,
+		},
+		{
+			name:     "should return conflict if another cutover is in progress",
+			url:      "/admin/collections/shop.orders/cutover",
+			body:     `{"schemaVersion":3}`,
+			err:      ErrCutoverInProgress,
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":{"code":409,"message":"a cutover to another schema version is in progress","requestId":"req-1"}}`,
+		},
+		{
+			name:     "should return gateway timeout if the cutover is not applied in time",
+			url:      "/admin/collections/shop.orders/cutover",
+			body:     `{"schemaVersion":2}`,
+			err:      context.DeadlineExceeded,
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":{"code":504,"message":"cutover cancelled: the pending change events were not published in time: context deadline exceeded","requestId":"req-1"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cutovers := &testCutovers{versions: map[string]int{"shop.orders": 1}, err: tt.err}
+			srv := New(WithCutovers(cutovers))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("X-Request-Id", "req-1")
+			srv.http.Handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			require.True(t, json.Valid(rec.Body.Bytes()))
+			require.JSONEq(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// dashboardFiles are the files of the HTML dashboard, served under /ui/.
+//
+//go:embed ui
+var dashboardFiles embed.FS
+
+// dashboard serves the embedded HTML dashboard, whose page polls GET /status, GET /metrics and GET /admin/journal, if
+// served, to show the state, the lag, the throughput and the recent errors of each watched collection, for operators
+// without Grafana access. Its files hold no data, so that they are served without authentication, and the page asks
+// for a bearer token once the endpoints it polls require one.
+func dashboard() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "ui")
+	if err != nil {
+		panic(err) // the ui directory is embedded
+	}
+	fileServer := http.StripPrefix("/ui/", http.FileServerFS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	})
+}
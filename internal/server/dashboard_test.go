@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_dashboard(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /ui/", dashboard())
+
+	t.Run("should serve the page of the dashboard", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		require.Equal(t, "default-src 'self'; frame-ancestors 'none'", rec.Header().Get("Content-Security-Policy"))
+		require.Contains(t, rec.Body.String(), `<script src="dashboard.js" defer></script>`)
+	})
+	t.Run("should serve the assets of the dashboard", func(t *testing.T) {
+		for path, contentType := range map[string]string{
+			"/ui/dashboard.js":  "text/javascript; charset=utf-8",
+			"/ui/dashboard.css": "text/css; charset=utf-8",
+		} {
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, contentType, rec.Header().Get("Content-Type"))
+		}
+	})
+	t.Run("should redirect to the page of the dashboard", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+
+		require.Contains(t, []int{http.StatusMovedPermanently, http.StatusTemporaryRedirect}, rec.Code)
+		require.Equal(t, "/ui/", rec.Header().Get("Location"))
+	})
+	t.Run("should respond with 404 to the files not embedded", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/missing.js", nil))
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	connectorv1 "github.com/context-labs/mongodb-nats-connector/pkg/api/connector/v1"
+)
+
+// requestIdMd is the metadata holding the id of each gRPC request, as requestIdHdr does for the HTTP requests.
+const requestIdMd = "x-request-id"
+
+var ErrUnavailable = errors.New("not available on this connector")
+
+// newGrpcServer creates the gRPC server serving the admin api of the server, i.e. the operations of its HTTP admin api,
+// along with the same request ids, access logs and authentication.
+func (s *Server) newGrpcServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcRequestId,
+		grpcAccessLog(s.logger),
+		grpcRecoverer,
+		grpcAuthenticate(s.logger, s.authenticators),
+	))
+	connectorv1.RegisterAdminServiceServer(srv, &adminService{s: s})
+	return srv
+}
+
+// adminService implements the gRPC admin api with the components of the server.
+type adminService struct {
+	connectorv1.UnimplementedAdminServiceServer
+	s *Server
+}
+
+func (a *adminService) GetHealth(ctx context.Context, _ *connectorv1.GetHealthRequest) (
+	*connectorv1.GetHealthResponse, error) {
+	return &connectorv1.GetHealthResponse{
+		Status:     string(UP),
+		Instance:   grpcInstance(a.s.instance),
+		Components: grpcComponents(monitorComponents(ctx, a.s.monitors...)),
+	}, nil
+}
+
+func (a *adminService) GetStatus(ctx context.Context, _ *connectorv1.GetStatusRequest) (
+	*connectorv1.GetStatusResponse, error) {
+	report := a.s.StatusReport(ctx)
+	if report == nil {
+		return nil, grpcstatus.Error(codes.Unimplemented, ErrUnavailable.Error())
+	}
+	collections := make(map[string]*connectorv1.CollectionStatus, len(report.Collections))
+	for namespace, cs := range report.Collections {
+		collection := &connectorv1.CollectionStatus{State: cs.State, EventsPublished: cs.EventsPublished, Lag: cs.Lag,
+			Error: cs.Error}
+		if cs.LastEventTime != nil {
+			collection.LastEventTime = timestamppb.New(*cs.LastEventTime)
+		}
+		collections[namespace] = collection
+	}
+	return &connectorv1.GetStatusResponse{
+		Status:      string(report.Status),
+		Instance:    grpcInstance(report.Instance),
+		StartedAt:   timestamppb.New(report.StartedAt),
+		Uptime:      report.Uptime,
+		Components:  grpcComponents(report.Components),
+		Collections: collections,
+	}, nil
+}
+
+func (a *adminService) ListMappings(_ context.Context, _ *connectorv1.ListMappingsRequest) (
+	*connectorv1.ListMappingsResponse, error) {
+	if a.s.mappings == nil {
+		return nil, grpcstatus.Error(codes.Unimplemented, ErrUnavailable.Error())
+	}
+	res := &connectorv1.ListMappingsResponse{}
+	for _, m := range a.s.mappings.Mappings() {
+		mapping := &connectorv1.Mapping{Namespace: m.Namespace, Stream: m.Stream, SubjectFilter: m.SubjectFilter,
+			Subjects: m.Subjects, Encoder: m.Encoder, PublishMode: m.PublishMode, Transforms: m.Transforms}
+		for _, r := range m.Routes {
+			mapping.Routes = append(mapping.Routes, &connectorv1.MappingRoute{When: r.When, Stream: r.Stream,
+				SubjectFilter: r.SubjectFilter, Subjects: r.Subjects})
+		}
+		res.Mappings = append(res.Mappings, mapping)
+	}
+	return res, nil
+}
+
+func (a *adminService) GetConfig(_ context.Context, _ *connectorv1.GetConfigRequest) (
+	*connectorv1.GetConfigResponse, error) {
+	if a.s.config == nil {
+		return nil, grpcstatus.Error(codes.Unimplemented, ErrUnavailable.Error())
+	}
+	data, err := json.Marshal(a.s.config)
+	if err != nil {
+		return nil, grpcstatus.Error(codes.Internal, err.Error())
+	}
+	return &connectorv1.GetConfigResponse{ConfigJson: string(data)}, nil
+}
+
+func (a *adminService) DisableCollection(ctx context.Context, req *connectorv1.DisableCollectionRequest) (
+	*connectorv1.DisableCollectionResponse, error) {
+	if a.s.watchers == nil {
+		return nil, grpcstatus.Error(codes.Unimplemented, ErrUnavailable.Error())
+	}
+	if err := a.s.watchers.DisableWatcher(ctx, req.GetNamespace()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &connectorv1.DisableCollectionResponse{Collection: req.GetNamespace(), State: CollectionStateDisabled}, nil
+}
+
+func (a *adminService) EnableCollection(ctx context.Context, req *connectorv1.EnableCollectionRequest) (
+	*connectorv1.EnableCollectionResponse, error) {
+	if a.s.watchers == nil {
+		return nil, grpcstatus.Error(codes.Unimplemented, ErrUnavailable.Error())
+	}
+	if err := a.s.watchers.EnableWatcher(ctx, req.GetNamespace()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &connectorv1.EnableCollectionResponse{Collection: req.GetNamespace(), State: CollectionStateRunning}, nil
+}
+
+func (a *adminService) Cutover(ctx context.Context, req *connectorv1.CutoverRequest) (
+	*connectorv1.CutoverResponse, error) {
+	if a.s.cutovers == nil {
+		return nil, grpcstatus.Error(codes.Unimplemented, ErrUnavailable.Error())
+	}
+	if req.GetSchemaVersion() < 1 {
+		return nil, grpcstatus.Error(codes.InvalidArgument, ErrInvalidSchemaVersion.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, cutoverTimeout)
+	defer cancel()
+	if err := a.s.cutovers.Cutover(ctx, req.GetNamespace(), int(req.GetSchemaVersion())); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, grpcstatus.Errorf(codes.DeadlineExceeded,
+				"cutover cancelled: the pending change events were not published in time: %v", err)
+		}
+		return nil, grpcError(err)
+	}
+	return &connectorv1.CutoverResponse{Collection: req.GetNamespace(), SchemaVersion: req.GetSchemaVersion()}, nil
+}
+
+// grpcError returns the status of the given error of an admin operation, as the HTTP admin api maps them to status
+// codes.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrCollectionNotFound):
+		return grpcstatus.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrCutoverInProgress):
+		return grpcstatus.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return grpcstatus.Error(codes.Internal, err.Error())
+	}
+}
+
+func grpcInstance(instance *Instance) *connectorv1.Instance {
+	if instance == nil {
+		return nil
+	}
+	return &connectorv1.Instance{Id: instance.Id, Labels: instance.Labels}
+}
+
+// grpcComponents returns the given monitored components, whose details are formatted as strings.
+func grpcComponents(components map[string]monitoredComponents) map[string]*connectorv1.Component {
+	converted := make(map[string]*connectorv1.Component, len(components))
+	for name, component := range components {
+		c := &connectorv1.Component{Status: string(component.Status)}
+		for key, value := range component.Details {
+			if c.Details == nil {
+				c.Details = make(map[string]string, len(component.Details))
+			}
+			c.Details[key] = fmt.Sprint(value)
+		}
+		converted[name] = c
+	}
+	return converted
+}
+
+// grpcRequestId assigns an id to each request, unless its metadata already holds a valid one, adds it to its context,
+// and returns it in the header of its response.
+func grpcRequestId(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIdMd); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if !validRequestId(id) {
+		id = newRequestId()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIdMd, id))
+	return handler(context.WithValue(ctx, requestIdKey{}, id), req)
+}
+
+// grpcAccessLog logs each request once served, at info level, or at warn level if it failed with an internal error.
+// The health checks are logged at debug level, so that they do not flood the logs.
+func grpcAccessLog(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		code := grpcstatus.Code(err)
+		level := slog.LevelInfo
+		switch {
+		case code == codes.Internal || code == codes.Unknown:
+			level = slog.LevelWarn
+		case info.FullMethod == connectorv1.AdminService_GetHealth_FullMethodName:
+			level = slog.LevelDebug
+		}
+		logger.Log(ctx, level, "grpc request served", "requestId", RequestId(ctx), "method", info.FullMethod,
+			"code", code.String(), "duration", time.Since(start))
+		return res, err
+	}
+}
+
+func grpcRecoverer(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (
+	res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, grpcstatus.Error(codes.Internal, ErrInternal.Error())
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// grpcAuthenticate serves the requests whose authorization metadata is authenticated by any of the given
+// authenticators, if any, as authenticate does for the HTTP requests, and fails the other ones as unauthenticated.
+// The health checks are served without authentication.
+func grpcAuthenticate(logger *slog.Logger, authenticators []Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if len(authenticators) == 0 || info.FullMethod == connectorv1.AdminService_GetHealth_FullMethodName {
+			return handler(ctx, req)
+		}
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, info.FullMethod, nil)
+		if err != nil {
+			return nil, grpcstatus.Error(codes.Internal, err.Error())
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			for _, authorization := range md.Get("authorization") {
+				r.Header.Add("Authorization", authorization)
+			}
+		}
+		var errs []error
+		for _, authenticator := range authenticators {
+			err := authenticator.Authenticate(r)
+			if err == nil {
+				return handler(ctx, req)
+			}
+			errs = append(errs, err)
+		}
+		logger.Debug("could not authenticate request", "requestId", RequestId(ctx), "method", info.FullMethod,
+			"err", errors.Join(errs...))
+		return nil, grpcstatus.Error(codes.Unauthenticated, ErrUnauthorized.Error())
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+
+	connectorv1 "github.com/context-labs/mongodb-nats-connector/pkg/api/connector/v1"
+)
+
+func TestServer_grpc(t *testing.T) {
+	var (
+		status   = NewStatus("db.coll1")
+		watchers = &testWatchers{disabled: map[string]bool{"db.coll1": false}}
+		cutovers = &testCutovers{versions: map[string]int{"db.coll1": 1}}
+		mappings = testMappings{{Namespace: "db.coll1", Stream: "COLL1", SubjectFilter: "COLL1.>",
+			Subjects: map[string]string{"insert": "COLL1.insert"}, Encoder: "json", PublishMode: "jetstream"}}
+	)
+	srv := New(
+		WithAddr("127.0.0.1:8094"),
+		WithGrpcAddr("127.0.0.1:8095"),
+		WithNamedMonitors(&testDetailedComponent{testComponent: testComponent{name: "nats"},
+			details: map[string]any{"reconnects": 2}}),
+		WithInstance(&Instance{Id: "connector-0"}),
+		WithStatus(status),
+		WithConfig(map[string]string{"logLevel": "info"}),
+		WithMappings(mappings),
+		WithWatchers(watchers),
+		WithCutovers(cutovers),
+	)
+	go func() {
+		_ = srv.Run()
+	}()
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+	conn, err := grpc.Dial("127.0.0.1:8095", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := connectorv1.NewAdminServiceClient(conn)
+	require.Eventually(t, func() bool {
+		_, err := client.GetHealth(context.Background(), &connectorv1.GetHealthRequest{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	t.Run("should return the health of the components", func(t *testing.T) {
+		var header metadata.MD
+		res, err := client.GetHealth(context.Background(), &connectorv1.GetHealthRequest{}, grpc.Header(&header))
+
+		require.NoError(t, err)
+		require.Equal(t, "UP", res.GetStatus())
+		require.Equal(t, "connector-0", res.GetInstance().GetId())
+		require.Equal(t, "UP", res.GetComponents()["nats"].GetStatus())
+		require.Equal(t, map[string]string{"reconnects": "2"}, res.GetComponents()["nats"].GetDetails())
+		require.Len(t, header.Get("x-request-id"), 1)
+	})
+	t.Run("should return the status of the collections", func(t *testing.T) {
+		status.RecordPublished("db.coll1", time.Now())
+
+		res, err := client.GetStatus(context.Background(), &connectorv1.GetStatusRequest{})
+
+		require.NoError(t, err)
+		require.Equal(t, "UP", res.GetStatus())
+		require.Equal(t, CollectionStateRunning, res.GetCollections()["db.coll1"].GetState())
+		require.Equal(t, int64(1), res.GetCollections()["db.coll1"].GetEventsPublished())
+		require.NotNil(t, res.GetCollections()["db.coll1"].GetLastEventTime())
+	})
+	t.Run("should list the mappings and return the config", func(t *testing.T) {
+		mappingsRes, err := client.ListMappings(context.Background(), &connectorv1.ListMappingsRequest{})
+		require.NoError(t, err)
+		require.Len(t, mappingsRes.GetMappings(), 1)
+		require.Equal(t, "COLL1.>", mappingsRes.GetMappings()[0].GetSubjectFilter())
+		require.Equal(t, map[string]string{"insert": "COLL1.insert"}, mappingsRes.GetMappings()[0].GetSubjects())
+
+		configRes, err := client.GetConfig(context.Background(), &connectorv1.GetConfigRequest{})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"logLevel":"info"}`, configRes.GetConfigJson())
+	})
+	t.Run("should disable and enable the watcher of a collection", func(t *testing.T) {
+		disabled, err := client.DisableCollection(context.Background(),
+			&connectorv1.DisableCollectionRequest{Namespace: "db.coll1"})
+		require.NoError(t, err)
+		require.Equal(t, CollectionStateDisabled, disabled.GetState())
+		require.True(t, watchers.disabled["db.coll1"])
+
+		enabled, err := client.EnableCollection(context.Background(),
+			&connectorv1.EnableCollectionRequest{Namespace: "db.coll1"})
+		require.NoError(t, err)
+		require.Equal(t, CollectionStateRunning, enabled.GetState())
+		require.False(t, watchers.disabled["db.coll1"])
+	})
+	t.Run("should cut over the schema version of a collection", func(t *testing.T) {
+		res, err := client.Cutover(context.Background(), &connectorv1.CutoverRequest{Namespace: "db.coll1",
+			SchemaVersion: 2})
+
+		require.NoError(t, err)
+		require.Equal(t, int32(2), res.GetSchemaVersion())
+		require.Equal(t, 2, cutovers.versions["db.coll1"])
+	})
+	t.Run("should return the status codes of the errors", func(t *testing.T) {
+		_, err := client.DisableCollection(context.Background(),
+			&connectorv1.DisableCollectionRequest{Namespace: "db.unknown"})
+		require.Equal(t, codes.NotFound, grpcstatus.Code(err))
+
+		_, err = client.Cutover(context.Background(), &connectorv1.CutoverRequest{Namespace: "db.coll1"})
+		require.Equal(t, codes.InvalidArgument, grpcstatus.Code(err))
+
+		cutovers.err = ErrCutoverInProgress
+		defer func() { cutovers.err = nil }()
+		_, err = client.Cutover(context.Background(), &connectorv1.CutoverRequest{Namespace: "db.coll1",
+			SchemaVersion: 3})
+		require.Equal(t, codes.FailedPrecondition, grpcstatus.Code(err))
+	})
+}
+
+func TestServer_grpcAuthentication(t *testing.T) {
+	srv := New(
+		WithAddr("127.0.0.1:8096"),
+		WithGrpcAddr("127.0.0.1:8097"),
+		WithConfig(map[string]string{"logLevel": "info"}),
+		WithAuthenticators(&testAuthenticator{token: "s3cr3t"}),
+	)
+	go func() {
+		_ = srv.Run()
+	}()
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+	conn, err := grpc.Dial("127.0.0.1:8097", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := connectorv1.NewAdminServiceClient(conn)
+	require.Eventually(t, func() bool {
+		_, err := client.GetHealth(context.Background(), &connectorv1.GetHealthRequest{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	t.Run("should fail the requests not authenticated", func(t *testing.T) {
+		_, err := client.GetConfig(context.Background(), &connectorv1.GetConfigRequest{})
+
+		require.Equal(t, codes.Unauthenticated, grpcstatus.Code(err))
+	})
+	t.Run("should serve the requests authenticated", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cr3t")
+
+		_, err := client.GetConfig(ctx, &connectorv1.GetConfigRequest{})
+
+		require.NoError(t, err)
+	})
+	t.Run("should fail the operations unavailable on the connector", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cr3t")
+
+		_, err := client.GetStatus(ctx, &connectorv1.GetStatusRequest{})
+
+		require.Equal(t, codes.Unimplemented, grpcstatus.Code(err))
+	})
+}
+
+func Test_grpcRecoverer(t *testing.T) {
+	_, err := grpcRecoverer(context.Background(), nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, req any) (any, error) {
+			panic(errors.New("boom"))
+		})
+
+	require.Equal(t, codes.Internal, grpcstatus.Code(err))
+}
@@ -2,34 +2,110 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 )
 
+// ErrDegraded is returned by the monitors whose component responded, but slower than its degraded latency.
+var ErrDegraded = errors.New("component is degraded")
+
 type NamedMonitor interface {
 	Name() string
 	Monitor(ctx context.Context) error
 }
 
-func healthCheck(monitors ...NamedMonitor) http.HandlerFunc {
+// DetailedMonitor is a NamedMonitor that also reports details about the state of its component, e.g. the state of a
+// connection and its round-trip time.
+type DetailedMonitor interface {
+	NamedMonitor
+	Details() map[string]any
+}
+
+// Deadline bounds the time the ping of a monitored component is waited for, and the latency above which the component
+// is deemed degraded rather than up. A zero Timeout waits for the ping as long as the request is served, and a zero
+// DegradedLatency never deems the component degraded.
+type Deadline struct {
+	Timeout         time.Duration
+	DegradedLatency time.Duration
+}
+
+// WithDeadline returns a DetailedMonitor monitoring the given component within the given deadline, or the monitor
+// itself if the deadline is zero. The component is down if its ping times out, and degraded if it succeeds but exceeds
+// the degraded latency.
+func WithDeadline(monitor NamedMonitor, deadline Deadline) NamedMonitor {
+	if deadline == (Deadline{}) {
+		return monitor
+	}
+	return &deadlineMonitor{NamedMonitor: monitor, deadline: deadline}
+}
+
+type deadlineMonitor struct {
+	NamedMonitor
+	deadline Deadline
+}
+
+func (m *deadlineMonitor) Monitor(ctx context.Context) error {
+	if m.deadline.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.deadline.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	if err := m.NamedMonitor.Monitor(ctx); err != nil {
+		return err
+	}
+	if latency := time.Since(start); m.deadline.DegradedLatency > 0 && latency > m.deadline.DegradedLatency {
+		return fmt.Errorf("%w: responded in %v", ErrDegraded, latency)
+	}
+	return nil
+}
+
+func (m *deadlineMonitor) Details() map[string]any {
+	if detailed, ok := m.NamedMonitor.(DetailedMonitor); ok {
+		return detailed.Details()
+	}
+	return nil
+}
+
+// Instance identifies the instance of the connector running the server.
+type Instance struct {
+	Id     string            `json:"id"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func healthCheck(instance *Instance, monitors ...NamedMonitor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		components := make(map[string]monitoredComponents, 0)
-		for _, monitor := range monitors {
-			if err := monitor.Monitor(r.Context()); err == nil {
-				components[monitor.Name()] = monitoredComponents{Status: UP}
-			} else {
-				components[monitor.Name()] = monitoredComponents{Status: DOWN}
-			}
-		}
 		response := &healthResponse{
 			Status:     UP,
-			Components: components,
+			Instance:   instance,
+			Components: monitorComponents(r.Context(), monitors...),
 		}
 		writeJson(w, http.StatusOK, response)
 	}
 }
 
+func monitorComponents(ctx context.Context, monitors ...NamedMonitor) map[string]monitoredComponents {
+	components := make(map[string]monitoredComponents, 0)
+	for _, monitor := range monitors {
+		component := monitoredComponents{Status: UP}
+		if err := monitor.Monitor(ctx); errors.Is(err, ErrDegraded) {
+			component.Status = DEGRADED
+		} else if err != nil {
+			component.Status = DOWN
+		}
+		if detailed, ok := monitor.(DetailedMonitor); ok {
+			component.Details = detailed.Details()
+		}
+		components[monitor.Name()] = component
+	}
+	return components
+}
+
 type healthResponse struct {
 	Status     health                         `json:"status"`
+	Instance   *Instance                      `json:"instance,omitempty"`
 	Components map[string]monitoredComponents `json:"components"`
 }
 
@@ -38,8 +114,11 @@ type health string
 const (
 	UP   health = "UP"
 	DOWN health = "DOWN"
+	// DEGRADED is the status of the components which respond, but slower than their degraded latency.
+	DEGRADED health = "DEGRADED"
 )
 
 type monitoredComponents struct {
-	Status health `json:"status"`
+	Status  health         `json:"status"`
+	Details map[string]any `json:"details,omitempty"`
 }
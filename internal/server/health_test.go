@@ -7,12 +7,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
 func Test_healthCheck(t *testing.T) {
 	type fields struct {
+		instance *Instance
 		monitors []NamedMonitor
 	}
 	type args struct {
@@ -60,10 +62,49 @@ func Test_healthCheck(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "should write a json response with the connector instance, if it was configured",
+			fields: fields{
+				instance: &Instance{Id: "connector-0", Labels: map[string]string{"env": "prod"}},
+				monitors: []NamedMonitor{&testComponent{name: "test", err: nil}},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/healthz", nil),
+			},
+			wantCode:        200,
+			wantContentType: "application/json",
+			wantBody: healthResponse{
+				Status:   UP,
+				Instance: &Instance{Id: "connector-0", Labels: map[string]string{"env": "prod"}},
+				Components: map[string]monitoredComponents{
+					"test": {Status: UP},
+				},
+			},
+		},
+		{
+			name: "should write a json response with component details, if it reports them",
+			fields: fields{monitors: []NamedMonitor{&testDetailedComponent{
+				testComponent: testComponent{name: "test", err: nil},
+				details:       map[string]any{"state": "CONNECTED", "reconnects": 2.0},
+			}}},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/healthz", nil),
+			},
+			wantCode:        200,
+			wantContentType: "application/json",
+			wantBody: healthResponse{
+				Status: UP,
+				Components: map[string]monitoredComponents{
+					"test": {Status: UP, Details: map[string]any{"state": "CONNECTED", "reconnects": 2.0}},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			healthCheck := healthCheck(tt.fields.monitors...)
+			healthCheck := healthCheck(tt.fields.instance, tt.fields.monitors...)
 			healthCheck(tt.args.w, tt.args.r)
 			rec := tt.args.w.(*httptest.ResponseRecorder)
 			require.Equal(t, tt.wantCode, rec.Code)
@@ -75,15 +116,71 @@ func Test_healthCheck(t *testing.T) {
 	}
 }
 
+func TestWithDeadline(t *testing.T) {
+	t.Run("should report the component up, if it responds within its degraded latency", func(t *testing.T) {
+		monitor := WithDeadline(&testComponent{name: "test"},
+			Deadline{Timeout: time.Second, DegradedLatency: time.Second})
+
+		require.Equal(t, map[string]monitoredComponents{"test": {Status: UP}},
+			monitorComponents(context.Background(), monitor))
+	})
+	t.Run("should report the component degraded, if it responds slower than its degraded latency", func(t *testing.T) {
+		monitor := WithDeadline(&testComponent{name: "test", delay: 20 * time.Millisecond},
+			Deadline{DegradedLatency: time.Millisecond})
+
+		require.ErrorIs(t, monitor.Monitor(context.Background()), ErrDegraded)
+		require.Equal(t, map[string]monitoredComponents{"test": {Status: DEGRADED}},
+			monitorComponents(context.Background(), monitor))
+	})
+	t.Run("should report the component down, if its ping times out", func(t *testing.T) {
+		monitor := WithDeadline(&testComponent{name: "test", delay: time.Minute},
+			Deadline{Timeout: 10 * time.Millisecond, DegradedLatency: time.Millisecond})
+
+		require.ErrorIs(t, monitor.Monitor(context.Background()), context.DeadlineExceeded)
+		require.Equal(t, map[string]monitoredComponents{"test": {Status: DOWN}},
+			monitorComponents(context.Background(), monitor))
+	})
+	t.Run("should keep reporting the component details", func(t *testing.T) {
+		monitor := WithDeadline(&testDetailedComponent{testComponent: testComponent{name: "test"},
+			details: map[string]any{"state": "CONNECTED"}}, Deadline{Timeout: time.Second})
+
+		require.Equal(t, map[string]monitoredComponents{
+			"test": {Status: UP, Details: map[string]any{"state": "CONNECTED"}},
+		}, monitorComponents(context.Background(), monitor))
+	})
+	t.Run("should return the monitor itself, if the deadline is zero", func(t *testing.T) {
+		component := &testComponent{name: "test"}
+
+		require.Same(t, component, WithDeadline(component, Deadline{}))
+	})
+}
+
 type testComponent struct {
-	name string
-	err  error
+	name  string
+	err   error
+	delay time.Duration
 }
 
 func (t *testComponent) Name() string {
 	return t.name
 }
 
-func (t *testComponent) Monitor(_ context.Context) error {
+func (t *testComponent) Monitor(ctx context.Context) error {
+	if t.delay > 0 {
+		select {
+		case <-time.After(t.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 	return t.err
 }
+
+type testDetailedComponent struct {
+	testComponent
+	details map[string]any
+}
+
+func (c *testDetailedComponent) Details() map[string]any {
+	return c.details
+}
@@ -11,8 +11,10 @@ func writeJson(w http.ResponseWriter, code int, b any) {
 	_ = json.NewEncoder(w).Encode(b)
 }
 
+// writeJsonError writes the given error, along with the id of the request it responds to, if any.
 func writeJsonError(w http.ResponseWriter, code int, err error) {
-	response := errorResponse{Error: errorDetails{Code: code, Message: err.Error()}}
+	response := errorResponse{Error: errorDetails{Code: code, Message: err.Error(),
+		RequestId: w.Header().Get(requestIdHdr)}}
 	writeJson(w, code, response)
 }
 
@@ -21,6 +23,7 @@ type errorResponse struct {
 }
 
 type errorDetails struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestId string `json:"requestId,omitempty"`
 }
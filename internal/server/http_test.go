@@ -36,4 +36,13 @@ func Test_writeJsonError(t *testing.T) {
 		require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotBody))
 		require.Equal(t, errorResponse{Error: errorDetails{Code: 500, Message: err.Error()}}, gotBody)
 	})
+	t.Run("should add the request id to the json error response", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("X-Request-Id", "abc123")
+		writeJsonError(rec, 404, errors.New("not found"))
+		gotBody := errorResponse{}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotBody))
+		require.Equal(t, errorResponse{Error: errorDetails{Code: 404, Message: "not found", RequestId: "abc123"}},
+			gotBody)
+	})
 }
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultJournalSize = 100
+
+const (
+	JournalResultPublished = "published"
+	JournalResultFailed    = "failed"
+)
+
+// JournalEntry holds the metadata of a change event that the connector attempted to publish.
+type JournalEntry struct {
+	Time    time.Time `json:"time"`
+	Subj    string    `json:"subject"`
+	MsgId   string    `json:"msgId"`
+	Size    int       `json:"size"`
+	Latency string    `json:"latency"`
+	Result  string    `json:"result"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Journal keeps, for each collection, a bounded ring buffer with the most recent journal entries.
+type Journal struct {
+	size int
+
+	mu      sync.RWMutex
+	buffers map[string]*ringBuffer
+}
+
+func NewJournal(size int) *Journal {
+	if size <= 0 {
+		size = defaultJournalSize
+	}
+	return &Journal{
+		size:    size,
+		buffers: make(map[string]*ringBuffer),
+	}
+}
+
+// Record adds the given entry to the collection's ring buffer, overwriting the oldest entry once the buffer is full.
+func (j *Journal) Record(coll string, entry JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	buf, ok := j.buffers[coll]
+	if !ok {
+		buf = &ringBuffer{entries: make([]JournalEntry, j.size)}
+		j.buffers[coll] = buf
+	}
+	buf.add(entry)
+}
+
+// Entries returns the journal entries of each collection, from the newest to the oldest.
+func (j *Journal) Entries() map[string][]JournalEntry {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	entries := make(map[string][]JournalEntry, len(j.buffers))
+	for coll, buf := range j.buffers {
+		entries[coll] = buf.list()
+	}
+	return entries
+}
+
+type ringBuffer struct {
+	entries []JournalEntry
+	next    int
+	full    bool
+}
+
+func (b *ringBuffer) add(entry JournalEntry) {
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+func (b *ringBuffer) list() []JournalEntry {
+	n := b.next
+	if b.full {
+		n = len(b.entries)
+	}
+	list := make([]JournalEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		list = append(list, b.entries[(b.next-i+len(b.entries))%len(b.entries)])
+	}
+	return list
+}
+
+func journal(j *Journal) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries := j.Entries()
+		if coll := r.URL.Query().Get("coll"); coll != "" {
+			list, ok := entries[coll]
+			if !ok {
+				list = []JournalEntry{}
+			}
+			entries = map[string][]JournalEntry{coll: list}
+		}
+		writeJson(w, http.StatusOK, &journalResponse{Collections: entries})
+	}
+}
+
+type journalResponse struct {
+	Collections map[string][]JournalEntry `json:"collections"`
+}
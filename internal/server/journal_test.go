@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewJournal(t *testing.T) {
+	t.Run("should create journal with the given size", func(t *testing.T) {
+		j := NewJournal(10)
+
+		require.Equal(t, 10, j.size)
+		require.Empty(t, j.Entries())
+	})
+	t.Run("should create journal with default size if given size is not positive", func(t *testing.T) {
+		j := NewJournal(0)
+
+		require.Equal(t, defaultJournalSize, j.size)
+	})
+}
+
+func TestJournal_Record(t *testing.T) {
+	t.Run("should return entries from the newest to the oldest", func(t *testing.T) {
+		j := NewJournal(3)
+
+		j.Record("db.coll1", JournalEntry{MsgId: "1"})
+		j.Record("db.coll1", JournalEntry{MsgId: "2"})
+		j.Record("db.coll2", JournalEntry{MsgId: "3"})
+
+		entries := j.Entries()
+		require.Equal(t, []JournalEntry{{MsgId: "2"}, {MsgId: "1"}}, entries["db.coll1"])
+		require.Equal(t, []JournalEntry{{MsgId: "3"}}, entries["db.coll2"])
+	})
+	t.Run("should overwrite the oldest entries once the journal is full", func(t *testing.T) {
+		j := NewJournal(3)
+
+		for i := 1; i <= 5; i++ {
+			j.Record("db.coll1", JournalEntry{MsgId: fmt.Sprint(i)})
+		}
+
+		require.Equal(t, []JournalEntry{{MsgId: "5"}, {MsgId: "4"}, {MsgId: "3"}}, j.Entries()["db.coll1"])
+	})
+}
+
+func Test_journal(t *testing.T) {
+	j := NewJournal(10)
+	j.Record("db.coll1", JournalEntry{Subj: "COLL1.insert", MsgId: "1", Size: 10, Result: JournalResultPublished})
+	j.Record("db.coll2", JournalEntry{Subj: "COLL2.insert", MsgId: "2", Size: 20, Result: JournalResultFailed,
+		Error: "timeout"})
+
+	tests := []struct {
+		name     string
+		url      string
+		wantBody journalResponse
+	}{
+		{
+			name: "should write a json response with the entries of all collections",
+			url:  "/admin/journal",
+			wantBody: journalResponse{Collections: map[string][]JournalEntry{
+				"db.coll1": {{Subj: "COLL1.insert", MsgId: "1", Size: 10, Result: JournalResultPublished}},
+				"db.coll2": {{Subj: "COLL2.insert", MsgId: "2", Size: 20, Result: JournalResultFailed,
+					Error: "timeout"}},
+			}},
+		},
+		{
+			name: "should write a json response with the entries of the given collection",
+			url:  "/admin/journal?coll=db.coll1",
+			wantBody: journalResponse{Collections: map[string][]JournalEntry{
+				"db.coll1": {{Subj: "COLL1.insert", MsgId: "1", Size: 10, Result: JournalResultPublished}},
+			}},
+		},
+		{
+			name: "should write a json response with no entries if the given collection is unknown",
+			url:  "/admin/journal?coll=db.unknown",
+			wantBody: journalResponse{Collections: map[string][]JournalEntry{
+				"db.unknown": {},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			journal(j)(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			gotBody := journalResponse{}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotBody))
+			require.Equal(t, tt.wantBody, gotBody)
+		})
+	}
+}
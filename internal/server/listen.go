@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixAddrPrefix prefixes the addresses of Unix domain sockets, e.g. unix:/run/connector.sock.
+const unixAddrPrefix = "unix:"
+
+// ValidAddr reports whether the given address, or comma-separated list of addresses, is valid, i.e. each address is
+// either a TCP address of the form <host>:<port>, where the host can be empty to listen on all interfaces, or the path
+// of a Unix domain socket prefixed with unix:.
+func ValidAddr(addr string) bool {
+	addrs := splitAddrs(addr)
+	if len(addrs) == 0 {
+		return false
+	}
+	for _, a := range addrs {
+		if path, ok := unixSocketPath(a); ok {
+			if path == "" {
+				return false
+			}
+			continue
+		}
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// splitAddrs returns the addresses of the given comma-separated list of addresses.
+func splitAddrs(addr string) []string {
+	var addrs []string
+	for _, a := range strings.Split(addr, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// unixSocketPath returns the path of the Unix domain socket of the given address, and false if it is a TCP address.
+// Both unix:/run/connector.sock and unix:///run/connector.sock are accepted.
+func unixSocketPath(addr string) (string, bool) {
+	path, ok := strings.CutPrefix(addr, unixAddrPrefix)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimPrefix(path, "//"), true
+}
+
+// listen listens on the given address. The Unix domain socket left by a process that did not shut down cleanly is
+// replaced, unless a process still listens on it.
+func listen(addr string) (net.Listener, error) {
+	path, ok := unixSocketPath(addr)
+	if !ok {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("could not listen on %v: %v", addr, err)
+		}
+		return l, nil
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("could not listen on %v: another process listens on it", addr)
+		}
+		_ = os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %v: %v", addr, err)
+	}
+	return l, nil
+}
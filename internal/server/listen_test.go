@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want bool
+	}{
+		{name: "should accept a tcp address", addr: "127.0.0.1:8080", want: true},
+		{name: "should accept a tcp address on all interfaces", addr: ":8080", want: true},
+		{name: "should accept an ipv6 address", addr: "[::1]:8080", want: true},
+		{name: "should accept a unix socket", addr: "unix:/run/connector.sock", want: true},
+		{name: "should accept a unix socket url", addr: "unix:///run/connector.sock", want: true},
+		{name: "should accept many addresses", addr: "127.0.0.1:8080, unix:/run/connector.sock", want: true},
+		{name: "should reject an address without port", addr: "127.0.0.1", want: false},
+		{name: "should reject a unix socket without path", addr: "unix:", want: false},
+		{name: "should reject an empty address", addr: " , ", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, ValidAddr(tt.addr))
+		})
+	}
+}
+
+func Test_listen(t *testing.T) {
+	t.Run("should listen on a unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "connector.sock")
+
+		l, err := listen("unix:" + path)
+
+		require.NoError(t, err)
+		defer l.Close()
+		require.Equal(t, "unix", l.Addr().Network())
+		require.Equal(t, path, l.Addr().String())
+	})
+	t.Run("should replace the unix socket no process listens on", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "connector.sock")
+		stale, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false) // simulates a process that did not shut down cleanly
+		_ = stale.Close()
+
+		l, err := listen("unix://" + path)
+
+		require.NoError(t, err)
+		_ = l.Close()
+	})
+	t.Run("should return error cause a process listens on the unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "connector.sock")
+		other, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		defer other.Close()
+
+		l, err := listen("unix:" + path)
+
+		require.Nil(t, l)
+		require.ErrorContains(t, err, "another process listens on it")
+	})
+	t.Run("should return error cause the tcp address is in use", func(t *testing.T) {
+		other, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer other.Close()
+
+		l, err := listen(other.Addr().String())
+
+		require.Nil(t, l)
+		require.ErrorContains(t, err, "could not listen on "+other.Addr().String())
+	})
+}
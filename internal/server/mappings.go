@@ -0,0 +1,44 @@
+package server
+
+import "net/http"
+
+// Mappings lists how the change events of the watched collections are routed to NATS.
+type Mappings interface {
+	Mappings() []Mapping
+}
+
+// Mapping tells how the change events of a watched collection are published, i.e. the stream and subjects they are
+// published to, how they are encoded, and the transforms applied to them beforehand.
+type Mapping struct {
+	Namespace string `json:"namespace"`
+	Stream    string `json:"stream"`
+	// SubjectFilter is the subject filter the stream is bound to.
+	SubjectFilter string `json:"subjectFilter"`
+	// Subjects hold the layout of the subjects of the change events of documents, by operation type, e.g.
+	// ORDERS.insert.<partition>, whose placeholders are computed from each change event.
+	Subjects    map[string]string `json:"subjects"`
+	Encoder     string            `json:"encoder"`
+	PublishMode string            `json:"publishMode"`
+	// Transforms are the transforms applied to the change events before they are encoded, in order.
+	Transforms []string `json:"transforms"`
+	// Routes route the change events whose document matches their conditions to other streams, evaluated in order.
+	Routes []MappingRoute `json:"routes,omitempty"`
+}
+
+// MappingRoute tells where the change events matching the conditions of a route are published.
+type MappingRoute struct {
+	When          map[string]string `json:"when"`
+	Stream        string            `json:"stream"`
+	SubjectFilter string            `json:"subjectFilter"`
+	Subjects      map[string]string `json:"subjects"`
+}
+
+type mappingsResponse struct {
+	Mappings []Mapping `json:"mappings"`
+}
+
+func mappings(m Mappings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, &mappingsResponse{Mappings: m.Mappings()})
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMappings []Mapping
+
+func (m testMappings) Mappings() []Mapping {
+	return m
+}
+
+func Test_mappings(t *testing.T) {
+	m := testMappings{{
+		Namespace:     "shop.orders",
+		Stream:        "ORDERS",
+		SubjectFilter: "ORDERS.*",
+		Subjects:      map[string]string{"insert": "ORDERS.insert", "delete": "ORDERS.order.removed"},
+		Encoder:       "json",
+		PublishMode:   "jetstream",
+		Transforms:    []string{"filter", "reshape"},
+		Routes: []MappingRoute{{When: map[string]string{"region": "eu"}, Stream: "ORDERS_EU",
+			SubjectFilter: "ORDERS_EU.*", Subjects: map[string]string{"insert": "ORDERS_EU.insert"}}},
+	}}
+
+	rec := httptest.NewRecorder()
+	mappings(m)(rec, httptest.NewRequest(http.MethodGet, "/admin/mappings", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"mappings":[{"namespace":"shop.orders","stream":"ORDERS","subjectFilter":"ORDERS.*",
+		"subjects":{"insert":"ORDERS.insert","delete":"ORDERS.order.removed"},"encoder":"json",
+		"publishMode":"jetstream","transforms":["filter","reshape"],"routes":[{"when":{"region":"eu"},
+		"stream":"ORDERS_EU","subjectFilter":"ORDERS_EU.*","subjects":{"insert":"ORDERS_EU.insert"}}]}]}`,
+		rec.Body.String())
+}
@@ -1,12 +1,33 @@
 package server
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
+// requestIdHdr is the header holding the id of each request, taken from the request, e.g. one assigned by a proxy, or
+// generated otherwise, and returned in the response.
+const requestIdHdr = "X-Request-Id"
+
+// maxRequestIdLen is the maximum length of the request ids taken from requests, longer ones are replaced.
+const maxRequestIdLen = 128
+
 var ErrInternal = errors.New("internal server error")
 
+type requestIdKey struct{}
+
+// RequestId returns the id of the request the given context belongs to, or an empty string if it does not belong to
+// any, so that the operations it triggers can be traced back to it.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
 func recoverer(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -20,3 +41,84 @@ func recoverer(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requestId assigns an id to each request, unless it already holds a valid one, and adds it to its context and to the
+// headers of its response.
+func requestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHdr)
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+		w.Header().Set(requestIdHdr, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey{}, id)))
+	})
+}
+
+// accessLog logs each request once served. The requests of probes and scrapers are logged at debug level, so that they
+// do not flood the logs, and the ones that failed with a server error at warn level.
+func accessLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, code: http.StatusOK}
+		defer func() {
+			level := slog.LevelInfo
+			switch {
+			case rec.code >= http.StatusInternalServerError:
+				level = slog.LevelWarn
+			case r.URL.Path == "/healthz" || r.URL.Path == "/metrics":
+				level = slog.LevelDebug
+			}
+			logger.Log(r.Context(), level, "http request served", "requestId", RequestId(r.Context()),
+				"method", r.Method, "path", r.URL.Path, "code", rec.code, "size", rec.size,
+				"duration", time.Since(start), "remoteAddr", r.RemoteAddr, "userAgent", r.UserAgent())
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// responseRecorder records the status code and the size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	code        int
+	size        int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+// Unwrap returns the recorded response writer, so that http.ResponseController can reach it, e.g. to flush it.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' { // printable ascii, so that it cannot forge log records nor headers
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
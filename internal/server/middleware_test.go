@@ -1,10 +1,13 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -58,6 +61,108 @@ func Test_recoverer(t *testing.T) {
 	}
 }
 
+func Test_requestId(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestId string
+		wantKept  bool
+	}{
+		{
+			name:      "should keep the request id of the request",
+			requestId: "0f8fad5b-d9cb-469f-a165-70867728950e",
+			wantKept:  true,
+		},
+		{
+			name:      "should generate a request id if the request has none",
+			requestId: "",
+		},
+		{
+			name:      "should generate a request id if the one of the request is too long",
+			requestId: strings.Repeat("a", 129),
+		},
+		{
+			name:      "should generate a request id if the one of the request is not printable",
+			requestId: "abc\n{\"level\":\"ERROR\"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCtxId string
+			h := requestId(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotCtxId = RequestId(r.Context())
+				writeJsonError(w, http.StatusNotFound, ErrConnectorNotFound)
+			}))
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/connectors/orders", nil)
+			req.Header.Set("X-Request-Id", tt.requestId)
+
+			h.ServeHTTP(rec, req)
+
+			gotId := rec.Header().Get("X-Request-Id")
+			if tt.wantKept {
+				require.Equal(t, tt.requestId, gotId)
+			} else {
+				require.Len(t, gotId, 32)
+			}
+			require.Equal(t, gotId, gotCtxId)
+			gotBody := errorResponse{}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotBody))
+			require.Equal(t, gotId, gotBody.Error.RequestId)
+		})
+	}
+}
+
+func Test_accessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		code      int
+		wantLevel string
+	}{
+		{
+			name:      "should log the requests at info level",
+			path:      "/connectors/orders",
+			code:      http.StatusNotFound,
+			wantLevel: "INFO",
+		},
+		{
+			name:      "should log the requests that failed with a server error at warn level",
+			path:      "/connectors/orders",
+			code:      http.StatusInternalServerError,
+			wantLevel: "WARN",
+		},
+		{
+			name:      "should log the requests of probes at debug level",
+			path:      "/healthz",
+			code:      http.StatusOK,
+			wantLevel: "DEBUG",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &bytes.Buffer{}
+			logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			h := requestId(accessLog(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeJson(w, tt.code, map[string]string{})
+			})))
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-Request-Id", "abc123")
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+			require.Equal(t, tt.wantLevel, entry["level"])
+			require.Equal(t, "http request served", entry["msg"])
+			require.Equal(t, "abc123", entry["requestId"])
+			require.Equal(t, http.MethodGet, entry["method"])
+			require.Equal(t, tt.path, entry["path"])
+			require.Equal(t, float64(tt.code), entry["code"])
+			require.Equal(t, float64(3), entry["size"]) // {}\n
+		})
+	}
+}
+
 type panickingHttpHandler struct {
 	err error
 }
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// oidcDiscoveryPath is the path of the discovery document of an OpenID Connect issuer, relative to the issuer.
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+	// oidcRequestTimeout bounds the requests fetching the discovery document and the signing keys of the issuer.
+	oidcRequestTimeout = 10 * time.Second
+
+	// oidcMinRefreshInterval is the minimum amount of time between two fetches of the signing keys, so that tokens
+	// signed by unknown keys cannot make the connector flood the issuer.
+	oidcMinRefreshInterval = time.Minute
+
+	// oidcLeeway is the clock skew tolerated when checking the expiry and the start of validity of the tokens.
+	oidcLeeway = time.Minute
+)
+
+var ErrInvalidJwt = errors.New("invalid jwt")
+
+// OidcAuthenticator authenticates the requests holding a JWT bearer token issued by an OpenID Connect provider, e.g.
+// the one of a corporate SSO, for a given audience. The tokens must be signed with RS256 or ES256, by one of the keys
+// the issuer publishes, which are fetched once needed and refetched once a token is signed by an unknown one, e.g. once
+// its keys are rotated.
+type OidcAuthenticator struct {
+	issuer   string
+	audience string
+	client   *http.Client
+	now      func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	refreshedAt time.Time
+}
+
+// NewOidcAuthenticator creates a new OidcAuthenticator, accepting the tokens of the given issuer whose audience holds
+// the given one.
+func NewOidcAuthenticator(issuer, audience string) *OidcAuthenticator {
+	return &OidcAuthenticator{
+		issuer:   issuer,
+		audience: audience,
+		client:   &http.Client{Timeout: oidcRequestTimeout},
+		now:      time.Now,
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *int64      `json:"exp"`
+	NotBefore *int64      `json:"nbf"`
+}
+
+// jwtAudience is the audience of a JWT, which is either a single string or an array of strings.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var audience string
+	if err := json.Unmarshal(data, &audience); err == nil {
+		*a = jwtAudience{audience}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// Authenticate checks that the bearer token of the given request is a JWT signed by the issuer, which was issued by it
+// for the audience, and which has not expired.
+func (a *OidcAuthenticator) Authenticate(r *http.Request) error {
+	token, err := bearerToken(r)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: must be made of a header, a payload and a signature", ErrInvalidJwt)
+	}
+	var header jwtHeader
+	if err := decodeJwtPart(parts[0], &header); err != nil {
+		return err
+	}
+	key, err := a.key(r.Context(), header.Kid)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: signature is not base64url encoded", ErrInvalidJwt)
+	}
+	if err := verifyJwtSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return err
+	}
+	var claims jwtClaims
+	if err := decodeJwtPart(parts[1], &claims); err != nil {
+		return err
+	}
+	return a.validate(&claims)
+}
+
+// validate checks the registered claims of a token whose signature is valid.
+func (a *OidcAuthenticator) validate(claims *jwtClaims) error {
+	now := a.now()
+	switch {
+	case strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(a.issuer, "/"):
+		return fmt.Errorf("%w: issuer %v is not accepted", ErrInvalidJwt, claims.Issuer)
+	case !slices.Contains(claims.Audience, a.audience):
+		return fmt.Errorf("%w: audience does not hold %v", ErrInvalidJwt, a.audience)
+	case claims.ExpiresAt == nil || now.After(time.Unix(*claims.ExpiresAt, 0).Add(oidcLeeway)):
+		return fmt.Errorf("%w: token expired", ErrInvalidJwt)
+	case claims.NotBefore != nil && now.Before(time.Unix(*claims.NotBefore, 0).Add(-oidcLeeway)):
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidJwt)
+	}
+	return nil
+}
+
+func decodeJwtPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: part is not base64url encoded", ErrInvalidJwt)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: part is not a json object", ErrInvalidJwt)
+	}
+	return nil
+}
+
+// verifyJwtSignature verifies the given signature of the given signing input, i.e. the encoded header and payload.
+func verifyJwtSignature(alg string, key crypto.PublicKey, input string, signature []byte) error {
+	digest := sha256.Sum256([]byte(input))
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key is not an rsa key", ErrInvalidJwt)
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: signature is not valid", ErrInvalidJwt)
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return fmt.Errorf("%w: key is not a P-256 key, or signature is not 64 bytes long", ErrInvalidJwt)
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return fmt.Errorf("%w: signature is not valid", ErrInvalidJwt)
+		}
+	default:
+		return fmt.Errorf("%w: algorithm %v is not supported, must be one of RS256, ES256", ErrInvalidJwt, alg)
+	}
+	return nil
+}
+
+// key returns the signing key of the issuer with the given id, refetching the keys of the issuer if it is unknown.
+func (a *OidcAuthenticator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	if !a.refreshedAt.IsZero() && a.now().Sub(a.refreshedAt) < oidcMinRefreshInterval {
+		return nil, fmt.Errorf("%w: signing key %v is unknown", ErrInvalidJwt, kid)
+	}
+	a.refreshedAt = a.now() // even if the fetch fails, so that an unreachable issuer is not retried on each request
+	keys, err := a.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.keys = keys
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: signing key %v is unknown", ErrInvalidJwt, kid)
+}
+
+type oidcDiscovery struct {
+	JwksUri string `json:"jwks_uri"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys fetches the signing keys of the issuer, from the key set its discovery document refers to. The keys which
+// are not used for signatures, or which are not RSA nor P-256 keys, are ignored.
+func (a *OidcAuthenticator) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var discovery oidcDiscovery
+	if err := a.getJson(ctx, strings.TrimSuffix(a.issuer, "/")+oidcDiscoveryPath, &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.JwksUri == "" {
+		return nil, fmt.Errorf("discovery document of issuer %v has no jwks_uri", a.issuer)
+	}
+	var set jwks
+	if err := a.getJson(ctx, discovery.JwksUri, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (a *OidcAuthenticator) getJson(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("could not fetch %v: %v", url, err)
+	}
+	res, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not fetch %v: %v", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not fetch %v: status code %v", url, res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode %v: %v", url, err)
+	}
+	return nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("curve %v is not supported", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("coordinates must be 32 bytes long")
+		}
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err // the point is not on the curve
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("key type %v is not supported", k.Kty)
+}
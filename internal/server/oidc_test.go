@@ -0,0 +1,144 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOidcAuthenticator_Authenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	var jwksFetches atomic.Int32
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		jwksFetches.Add(1)
+		writeJson(w, http.StatusOK, map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()),
+				"e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	issuer = srv.URL
+	now := time.Now()
+	claims := map[string]any{"iss": issuer, "aud": "connector", "exp": now.Add(time.Hour).Unix()}
+	authenticate := func(a *OidcAuthenticator, token string) error {
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return a.Authenticate(req)
+	}
+
+	t.Run("should authenticate the tokens signed by the keys of the issuer", func(t *testing.T) {
+		a := NewOidcAuthenticator(issuer+"/", "connector")
+
+		require.NoError(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", claims)))
+		require.NoError(t, authenticate(a, signEs256(t, ecKey, "ec-1", map[string]any{"iss": issuer,
+			"aud": []string{"other", "connector"}, "exp": now.Add(time.Hour).Unix(), "nbf": now.Unix()})))
+	})
+	t.Run("should return error if the token was not issued for the audience, or by the issuer", func(t *testing.T) {
+		a := NewOidcAuthenticator(issuer, "connector")
+
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", map[string]any{"iss": issuer,
+			"aud": "other", "exp": now.Add(time.Hour).Unix()})), ErrInvalidJwt)
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", map[string]any{"iss": "https://evil.example",
+			"aud": "connector", "exp": now.Add(time.Hour).Unix()})), ErrInvalidJwt)
+	})
+	t.Run("should return error if the token expired or is not valid yet", func(t *testing.T) {
+		a := NewOidcAuthenticator(issuer, "connector")
+
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", map[string]any{"iss": issuer,
+			"aud": "connector", "exp": now.Add(-time.Hour).Unix()})), ErrInvalidJwt)
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", map[string]any{"iss": issuer,
+			"aud": "connector"})), ErrInvalidJwt)
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", map[string]any{"iss": issuer,
+			"aud": "connector", "exp": now.Add(2 * time.Hour).Unix(), "nbf": now.Add(time.Hour).Unix()})),
+			ErrInvalidJwt)
+	})
+	t.Run("should return error if the token is not signed by a signing key of the issuer", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		a := NewOidcAuthenticator(issuer, "connector")
+
+		require.ErrorIs(t, authenticate(a, signRs256(t, otherKey, "rsa-1", claims)), ErrInvalidJwt)
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "enc-1", claims)), ErrInvalidJwt)
+		require.ErrorIs(t, authenticate(a, signEs256(t, ecKey, "rsa-1", claims)), ErrInvalidJwt)
+	})
+	t.Run("should return error if the token is not a signed jwt", func(t *testing.T) {
+		a := NewOidcAuthenticator(issuer, "connector")
+		unsigned := b64([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." + b64(mustJson(t, claims)) + "."
+
+		require.ErrorIs(t, authenticate(a, unsigned), ErrInvalidJwt)
+		require.ErrorIs(t, authenticate(a, "not-a-jwt"), ErrInvalidJwt)
+	})
+	t.Run("should not refetch the keys of the issuer more than once a minute", func(t *testing.T) {
+		a := NewOidcAuthenticator(issuer, "connector")
+		fetches := jwksFetches.Load()
+
+		require.NoError(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", claims)))
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-2", claims)), ErrInvalidJwt)
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-3", claims)), ErrInvalidJwt)
+		require.Equal(t, fetches+1, jwksFetches.Load())
+
+		a.now = func() time.Time { return now.Add(2 * time.Minute) }
+		require.ErrorIs(t, authenticate(a, signRs256(t, rsaKey, "rsa-2", claims)), ErrInvalidJwt)
+		require.Equal(t, fetches+2, jwksFetches.Load())
+	})
+	t.Run("should return error if the issuer is unreachable", func(t *testing.T) {
+		a := NewOidcAuthenticator("http://127.0.0.1:1", "connector")
+
+		require.ErrorContains(t, authenticate(a, signRs256(t, rsaKey, "rsa-1", claims)), "could not fetch")
+	})
+}
+
+func signRs256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	input := jwtInput(t, "RS256", kid, claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return input + "." + b64(signature)
+}
+
+func signEs256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	input := jwtInput(t, "ES256", kid, claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	return input + "." + b64(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+}
+
+func jwtInput(t *testing.T, alg, kid string, claims map[string]any) string {
+	return b64(mustJson(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})) + "." + b64(mustJson(t, claims))
+}
+
+func mustJson(t *testing.T, v any) []byte {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -2,68 +2,317 @@ package server
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net"
 	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
 )
 
-const defaultAddr = "127.0.0.1:8080"
+const (
+	defaultAddr            = "127.0.0.1:8080"
+	defaultReadTimeout     = 10 * time.Second
+	defaultWriteTimeout    = cutoverTimeout + 10*time.Second // the responses of cutovers are written once applied
+	defaultIdleTimeout     = 2 * time.Minute
+	defaultMaxHeaderBytes  = 64 << 10
+	defaultShutdownTimeout = 5 * time.Second
+)
 
 type Server struct {
-	addr           string
+	// addrs are the addresses the server listens on. If adminAddrs are set, only the health checks are served on addrs,
+	// and all the endpoints on adminAddrs, e.g. so that probes cannot reach the admin api. grpcAddrs are the addresses
+	// the gRPC admin api is served on, if any.
+	addrs          []string
+	adminAddrs     []string
+	grpcAddrs      []string
 	ctx            context.Context
 	monitors       []NamedMonitor
+	instance       *Instance
 	logger         *slog.Logger
 	metricsHandler http.Handler
+	journal        *Journal
+	status         *Status
+	config         any
+	connectors     Connectors
+	cutovers       Cutovers
+	watchers       Watchers
+	mappings       Mappings
+	// authenticators authenticate the requests to all the endpoints but the health checks and the metrics, if any.
+	authenticators []Authenticator
 
-	http *http.Server
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	maxHeaderBytes  int
+	shutdownTimeout time.Duration
+
+	http  *http.Server
+	admin *http.Server
+	grpc  *grpc.Server
 }
 
 func New(opts ...Option) *Server {
 	s := &Server{
-		addr:     defaultAddr,
-		ctx:      context.Background(),
-		monitors: []NamedMonitor{},
-		logger:   slog.Default(),
+		addrs:           []string{defaultAddr},
+		ctx:             context.Background(),
+		monitors:        []NamedMonitor{},
+		logger:          slog.Default(),
+		readTimeout:     defaultReadTimeout,
+		writeTimeout:    defaultWriteTimeout,
+		idleTimeout:     defaultIdleTimeout,
+		maxHeaderBytes:  defaultMaxHeaderBytes,
+		shutdownTimeout: defaultShutdownTimeout,
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	probes := http.NewServeMux()
+	probes.HandleFunc("GET /healthz", healthCheck(s.instance, s.monitors...))
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /healthz", healthCheck(s.monitors...))
+	mux.HandleFunc("GET /healthz", healthCheck(s.instance, s.monitors...))
 	if s.metricsHandler != nil {
 		mux.Handle("GET /metrics", s.metricsHandler)
 	}
+	if s.journal != nil {
+		mux.HandleFunc("GET /admin/journal", journal(s.journal))
+	}
+	if s.status != nil {
+		mux.HandleFunc("GET /status", status(s.status, s.instance, s.monitors...))
+		mux.Handle("GET /ui/", dashboard())
+	}
+	if s.config != nil {
+		mux.HandleFunc("GET /admin/config", effectiveConfig(s.config))
+	}
+	if s.mappings != nil {
+		mux.HandleFunc("GET /admin/mappings", mappings(s.mappings))
+	}
+	if s.cutovers != nil {
+		mux.HandleFunc("POST /admin/collections/{namespace}/cutover", cutover(s.cutovers))
+	}
+	if s.watchers != nil {
+		mux.HandleFunc("POST /admin/collections/{namespace}/disable", disableWatcher(s.watchers))
+		mux.HandleFunc("POST /admin/collections/{namespace}/enable", enableWatcher(s.watchers))
+	}
+	if s.connectors != nil {
+		mux.HandleFunc("GET /connectors", listConnectors(s.connectors))
+		mux.HandleFunc("GET /connectors/{name}", getConnector(s.connectors))
+		mux.HandleFunc("PUT /connectors/{name}", putConnector(s.connectors))
+		mux.HandleFunc("DELETE /connectors/{name}", deleteConnector(s.connectors))
+		mux.HandleFunc("POST /connectors/{name}/pause", pauseConnector(s.connectors))
+		mux.HandleFunc("POST /connectors/{name}/resume", resumeConnector(s.connectors))
+	}
 
-	s.http = &http.Server{
-		Addr:    s.addr,
-		Handler: recoverer(mux),
+	var handler http.Handler = mux
+	if len(s.authenticators) > 0 {
+		handler = authenticate(s.logger, s.authenticators, mux)
+	}
+	if len(s.adminAddrs) > 0 {
+		s.http = s.newHttpServer(probes)
+		s.admin = s.newHttpServer(handler)
+	} else {
+		s.http = s.newHttpServer(handler)
+	}
+	if len(s.grpcAddrs) > 0 {
+		s.grpc = s.newGrpcServer()
+	}
+
+	return s
+}
+
+func (s *Server) newHttpServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:        requestId(accessLog(s.logger, recoverer(handler))),
+		ReadTimeout:    s.readTimeout,
+		WriteTimeout:   s.writeTimeout,
+		IdleTimeout:    s.idleTimeout,
+		MaxHeaderBytes: s.maxHeaderBytes,
 		BaseContext: func(l net.Listener) context.Context {
 			return s.ctx
 		},
 	}
-
-	return s
 }
 
+// Run listens on the addresses of the server, and serves them until the server is closed. If serving any of them
+// fails, the others are closed too.
 func (s *Server) Run() error {
-	s.logger.Info("server started", "addr", s.addr)
-	return s.http.ListenAndServe()
+	type listener struct {
+		net.Listener
+		serve func(net.Listener) error
+	}
+	var listeners []listener
+	addListeners := func(addrs []string, serve func(net.Listener) error) error {
+		for _, addr := range addrs {
+			l, err := listen(addr)
+			if err != nil {
+				for _, l := range listeners {
+					_ = l.Close()
+				}
+				return err
+			}
+			listeners = append(listeners, listener{Listener: l, serve: serve})
+		}
+		return nil
+	}
+	for _, srv := range s.servers() {
+		addrs := s.addrs
+		if srv == s.admin {
+			addrs = s.adminAddrs
+		}
+		if err := addListeners(addrs, srv.Serve); err != nil {
+			return err
+		}
+	}
+	if s.grpc != nil {
+		if err := addListeners(s.grpcAddrs, s.grpc.Serve); err != nil {
+			return err
+		}
+	}
+	attrs := []any{"addr", strings.Join(s.addrs, ",")}
+	if len(s.adminAddrs) > 0 {
+		attrs = append(attrs, "adminAddr", strings.Join(s.adminAddrs, ","))
+	}
+	if s.grpc != nil {
+		attrs = append(attrs, "grpcAddr", strings.Join(s.grpcAddrs, ","))
+	}
+	s.logger.Info("server started", attrs...)
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			errs <- l.serve(l)
+		}()
+	}
+	var runErr error
+	for range listeners {
+		// the gRPC server returns nil once stopped, and the http servers ErrServerClosed once closed
+		if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) && runErr == nil {
+			runErr = err
+			_ = s.closeNow()
+		}
+	}
+	return runErr
 }
 
+// Close shuts down the server gracefully, waiting for the requests being served, at most for the shutdown timeout. Once
+// it is exceeded, the connections still open are closed.
 func (s *Server) Close() error {
-	s.logger.Info("server gracefully shutting down", "addr", s.addr)
-	return s.http.Shutdown(context.Background())
+	s.logger.Info("server gracefully shutting down", "addr", strings.Join(s.addrs, ","))
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	var errs []error
+	for _, srv := range s.servers() {
+		errs = append(errs, srv.Shutdown(ctx))
+	}
+	if s.grpc != nil {
+		stopped := make(chan struct{})
+		go func() {
+			s.grpc.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+		}
+	}
+	err := errors.Join(errs...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		s.logger.Warn("could not shut down server gracefully in time, closing its connections",
+			"addr", strings.Join(s.addrs, ","), "timeout", s.shutdownTimeout)
+		return s.closeNow()
+	}
+	return err
+}
+
+// closeNow closes the listeners and the connections of the server immediately.
+func (s *Server) closeNow() error {
+	var errs []error
+	for _, srv := range s.servers() {
+		errs = append(errs, srv.Close())
+	}
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+	return errors.Join(errs...)
+}
+
+// servers returns the http servers serving the addresses, and the admin addresses if any.
+func (s *Server) servers() []*http.Server {
+	if s.admin == nil {
+		return []*http.Server{s.http}
+	}
+	return []*http.Server{s.http, s.admin}
 }
 
 type Option func(*Server)
 
+// WithAddr sets the addresses the server listens on, as a comma-separated list of addresses, each of them either a TCP
+// address, e.g. 127.0.0.1:8080, or the path of a Unix domain socket prefixed with unix:, e.g. unix:/run/connector.sock.
 func WithAddr(addr string) Option {
 	return func(s *Server) {
-		if addr != "" {
-			s.addr = addr
+		if addrs := splitAddrs(addr); len(addrs) > 0 {
+			s.addrs = addrs
+		}
+	}
+}
+
+// WithAdminAddr sets the addresses all the endpoints are served on, as a comma-separated list of addresses like the
+// ones of WithAddr, while only the health checks are served on the addresses of the server.
+func WithAdminAddr(adminAddr string) Option {
+	return func(s *Server) {
+		if adminAddrs := splitAddrs(adminAddr); len(adminAddrs) > 0 {
+			s.adminAddrs = adminAddrs
+		}
+	}
+}
+
+// WithGrpcAddr sets the addresses the gRPC admin api is served on, as a comma-separated list of addresses like the ones
+// of WithAddr. The gRPC admin api is not served unless they are set.
+func WithGrpcAddr(grpcAddr string) Option {
+	return func(s *Server) {
+		if grpcAddrs := splitAddrs(grpcAddr); len(grpcAddrs) > 0 {
+			s.grpcAddrs = grpcAddrs
+		}
+	}
+}
+
+// WithTimeouts sets the maximum durations for reading the requests, 10 seconds by default, for writing the responses,
+// once their requests are read, 70 seconds by default, so that cutovers can be applied, and for keeping idle
+// connections open, 2 minutes by default.
+func WithTimeouts(readTimeout, writeTimeout, idleTimeout time.Duration) Option {
+	return func(s *Server) {
+		if readTimeout > 0 {
+			s.readTimeout = readTimeout
+		}
+		if writeTimeout > 0 {
+			s.writeTimeout = writeTimeout
+		}
+		if idleTimeout > 0 {
+			s.idleTimeout = idleTimeout
+		}
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum size of the headers of the requests, 64 KiB by default.
+func WithMaxHeaderBytes(maxHeaderBytes int) Option {
+	return func(s *Server) {
+		if maxHeaderBytes > 0 {
+			s.maxHeaderBytes = maxHeaderBytes
+		}
+	}
+}
+
+// WithShutdownTimeout sets the maximum amount of time the server waits for the requests being served once it is
+// closed, 5 seconds by default.
+func WithShutdownTimeout(shutdownTimeout time.Duration) Option {
+	return func(s *Server) {
+		if shutdownTimeout > 0 {
+			s.shutdownTimeout = shutdownTimeout
 		}
 	}
 }
@@ -84,6 +333,14 @@ func WithNamedMonitors(monitors ...NamedMonitor) Option {
 	}
 }
 
+func WithInstance(instance *Instance) Option {
+	return func(s *Server) {
+		if instance != nil {
+			s.instance = instance
+		}
+	}
+}
+
 func WithLogger(logger *slog.Logger) Option {
 	return func(s *Server) {
 		if logger != nil {
@@ -99,3 +356,74 @@ func WithMetricsHandler(metricsHandler http.Handler) Option {
 		}
 	}
 }
+
+func WithJournal(journal *Journal) Option {
+	return func(s *Server) {
+		if journal != nil {
+			s.journal = journal
+		}
+	}
+}
+
+func WithStatus(status *Status) Option {
+	return func(s *Server) {
+		if status != nil {
+			s.status = status
+		}
+	}
+}
+
+// WithConfig exposes the given configuration, which must not hold any credentials, as json.
+func WithConfig(config any) Option {
+	return func(s *Server) {
+		if config != nil {
+			s.config = config
+		}
+	}
+}
+
+// WithAuthenticators requires the requests to all the endpoints but the health checks and the metrics to be
+// authenticated by any of the given authenticators.
+func WithAuthenticators(authenticators ...Authenticator) Option {
+	return func(s *Server) {
+		if len(authenticators) > 0 {
+			s.authenticators = authenticators
+		}
+	}
+}
+
+// WithConnectors exposes the api managing the connectors hosted by a runtime.
+func WithConnectors(connectors Connectors) Option {
+	return func(s *Server) {
+		if connectors != nil {
+			s.connectors = connectors
+		}
+	}
+}
+
+// WithMappings exposes how the change events of the watched collections are routed to NATS.
+func WithMappings(mappings Mappings) Option {
+	return func(s *Server) {
+		if mappings != nil {
+			s.mappings = mappings
+		}
+	}
+}
+
+// WithCutovers exposes the api switching the schema versions of the watched collections.
+func WithCutovers(cutovers Cutovers) Option {
+	return func(s *Server) {
+		if cutovers != nil {
+			s.cutovers = cutovers
+		}
+	}
+}
+
+// WithWatchers exposes the api disabling and enabling the watchers of the watched collections.
+func WithWatchers(watchers Watchers) Option {
+	return func(s *Server) {
+		if watchers != nil {
+			s.watchers = watchers
+		}
+	}
+}
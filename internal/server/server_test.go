@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -19,10 +21,15 @@ func TestNew(t *testing.T) {
 	t.Run("should create server with defaults", func(t *testing.T) {
 		srv := New()
 
-		require.Equal(t, "127.0.0.1:8080", srv.addr)
+		require.Equal(t, []string{"127.0.0.1:8080"}, srv.addrs)
 		require.Equal(t, context.Background(), srv.ctx)
 		require.Empty(t, srv.monitors)
 		require.Equal(t, slog.Default(), srv.logger)
+		require.Equal(t, 10*time.Second, srv.http.ReadTimeout)
+		require.Equal(t, 70*time.Second, srv.http.WriteTimeout)
+		require.Equal(t, 2*time.Minute, srv.http.IdleTimeout)
+		require.Equal(t, 64<<10, srv.http.MaxHeaderBytes)
+		require.Equal(t, 5*time.Second, srv.shutdownTimeout)
 	})
 
 	t.Run("should create server with the configured options", func(t *testing.T) {
@@ -33,6 +40,11 @@ func TestNew(t *testing.T) {
 			cmpDown        = &testComponent{name: "cmp_down", err: errors.New("not reachable")}
 			logger         = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 			metricsHandler = &testMetricsHandler{}
+			journal        = NewJournal(10)
+			status         = NewStatus("db.coll1")
+			instance       = &Instance{Id: "connector-0"}
+			config         = map[string]string{"logLevel": "info"}
+			connectors     = &testConnectors{}
 		)
 
 		srv := New(
@@ -41,14 +53,32 @@ func TestNew(t *testing.T) {
 			WithNamedMonitors(cmpUp, cmpDown),
 			WithLogger(logger),
 			WithMetricsHandler(metricsHandler),
+			WithJournal(journal),
+			WithStatus(status),
+			WithConfig(config),
+			WithConnectors(connectors),
+			WithInstance(instance),
+			WithTimeouts(5*time.Second, 0, time.Minute),
+			WithMaxHeaderBytes(8<<10),
+			WithShutdownTimeout(20*time.Second),
 		)
 
-		require.Equal(t, addr, srv.addr)
+		require.Equal(t, []string{addr}, srv.addrs)
 		require.Equal(t, ctx, srv.ctx)
 		require.Contains(t, srv.monitors, cmpUp)
 		require.Contains(t, srv.monitors, cmpDown)
 		require.Equal(t, logger, srv.logger)
 		require.Equal(t, metricsHandler, srv.metricsHandler)
+		require.Equal(t, journal, srv.journal)
+		require.Equal(t, status, srv.status)
+		require.Equal(t, config, srv.config)
+		require.Equal(t, connectors, srv.connectors)
+		require.Equal(t, instance, srv.instance)
+		require.Equal(t, 5*time.Second, srv.http.ReadTimeout)
+		require.Equal(t, 70*time.Second, srv.http.WriteTimeout)
+		require.Equal(t, time.Minute, srv.http.IdleTimeout)
+		require.Equal(t, 8<<10, srv.http.MaxHeaderBytes)
+		require.Equal(t, 20*time.Second, srv.shutdownTimeout)
 	})
 }
 
@@ -57,11 +87,17 @@ func TestServer_Run(t *testing.T) {
 		cmpUp          = &testComponent{name: "cmp_up", err: nil}
 		cmpDown        = &testComponent{name: "cmp_down", err: errors.New("not reachable")}
 		metricsHandler = &testMetricsHandler{}
+		journal        = NewJournal(10)
+		status         = NewStatus("db.coll1")
 	)
+	journal.Record("db.coll1", JournalEntry{MsgId: "1"})
 
 	srv := New(
 		WithNamedMonitors(cmpUp, cmpDown),
 		WithMetricsHandler(metricsHandler),
+		WithJournal(journal),
+		WithStatus(status),
+		WithConfig(map[string]string{"logLevel": "info"}),
 	)
 
 	go func() {
@@ -86,6 +122,7 @@ func TestServer_Run(t *testing.T) {
 		gotBody := healthResponse{}
 		require.Equal(t, http.StatusOK, res.StatusCode)
 		require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		require.Len(t, res.Header.Get("X-Request-Id"), 32)
 		require.NoError(t, json.NewDecoder(res.Body).Decode(&gotBody))
 		require.Equal(t, healthResponse{
 			Status: UP,
@@ -99,17 +136,169 @@ func TestServer_Run(t *testing.T) {
 	t.Run("should successfully call metrics endpoint", func(t *testing.T) {
 		waitForHealthyServer()
 
-		res, err := http.Get(fmt.Sprintf("http://%s/metrics", srv.addr))
+		res, err := http.Get(fmt.Sprintf("http://%s/metrics", srv.addrs[0]))
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, res.StatusCode)
 		body, err := io.ReadAll(res.Body)
 		require.NoError(t, err)
 		require.Equal(t, []byte("test metrics"), body)
 	})
+
+	t.Run("should successfully call journal endpoint", func(t *testing.T) {
+		waitForHealthyServer()
+
+		res, err := http.Get(fmt.Sprintf("http://%s/admin/journal", srv.addrs[0]))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		gotBody := journalResponse{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&gotBody))
+		require.Equal(t, []JournalEntry{{MsgId: "1"}}, gotBody.Collections["db.coll1"])
+	})
+
+	t.Run("should successfully call status endpoint", func(t *testing.T) {
+		waitForHealthyServer()
+
+		res, err := http.Get(fmt.Sprintf("http://%s/status", srv.addrs[0]))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		gotBody := StatusReport{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&gotBody))
+		require.Equal(t, DOWN, gotBody.Status)
+		require.Equal(t, CollectionStatus{State: CollectionStateRunning}, gotBody.Collections["db.coll1"])
+	})
+
+	t.Run("should successfully call config endpoint", func(t *testing.T) {
+		waitForHealthyServer()
+
+		res, err := http.Get(fmt.Sprintf("http://%s/admin/config", srv.addrs[0]))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"logLevel":"info"}`, string(body))
+	})
+}
+
+func TestServer_Close(t *testing.T) {
+	t.Run("should close the connections still open once the shutdown timeout is exceeded", func(t *testing.T) {
+		served := make(chan struct{})
+		srv := New(
+			WithAddr("127.0.0.1:8086"),
+			WithMetricsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(served)
+				time.Sleep(5 * time.Second)
+			})),
+			WithShutdownTimeout(50*time.Millisecond),
+		)
+		go func() {
+			_ = srv.Run()
+		}()
+		require.Eventually(t, func() bool {
+			_, err := healthcheck(srv)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		go func() {
+			_, _ = http.Get(fmt.Sprintf("http://%s/metrics", srv.addrs[0]))
+		}()
+		<-served
+
+		start := time.Now()
+		err := srv.Close()
+
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestServer_Run_addrs(t *testing.T) {
+	t.Run("should serve all the addresses, including unix sockets", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "server") // the paths of unix sockets are limited to about 100 bytes
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		socket := filepath.Join(dir, "connector.sock")
+		srv := New(WithAddr("127.0.0.1:8088, unix:"+socket), WithConfig(map[string]string{"logLevel": "info"}))
+		go func() {
+			_ = srv.Run()
+		}()
+		defer srv.Close()
+		unixClient := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}}
+
+		require.Eventually(t, func() bool {
+			_, err := healthcheck(srv)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		res, err := unixClient.Get("http://connector/admin/config")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		_ = res.Body.Close()
+	})
+	t.Run("should serve only the health checks if admin addresses are set", func(t *testing.T) {
+		srv := New(WithAddr("127.0.0.1:8089"), WithAdminAddr("127.0.0.1:8090"),
+			WithConfig(map[string]string{"logLevel": "info"}))
+		go func() {
+			_ = srv.Run()
+		}()
+		defer srv.Close()
+		require.Eventually(t, func() bool {
+			_, err := healthcheck(srv)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		res, err := http.Get("http://127.0.0.1:8089/admin/config")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+		_ = res.Body.Close()
+		for _, path := range []string{"/healthz", "/admin/config"} {
+			res, err = http.Get("http://127.0.0.1:8090" + path)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			_ = res.Body.Close()
+		}
+	})
+	t.Run("should authenticate the requests to all the endpoints but the health checks", func(t *testing.T) {
+		srv := New(WithAddr("127.0.0.1:8092"), WithConfig(map[string]string{"logLevel": "info"}),
+			WithAuthenticators(&testAuthenticator{token: "s3cr3t"}))
+		go func() {
+			_ = srv.Run()
+		}()
+		defer srv.Close()
+		require.Eventually(t, func() bool {
+			_, err := healthcheck(srv)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		res, err := http.Get("http://127.0.0.1:8092/admin/config")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		_ = res.Body.Close()
+		req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8092/admin/config", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+		res, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		_ = res.Body.Close()
+	})
+	t.Run("should return error cause an address cannot be listened on", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+		srv := New(WithAddr("127.0.0.1:8091"), WithAdminAddr(l.Addr().String()))
+
+		err = srv.Run()
+
+		require.ErrorContains(t, err, "could not listen on "+l.Addr().String())
+		_, err = healthcheck(srv)
+		require.Error(t, err)
+	})
 }
 
 func healthcheck(srv *Server) (*http.Response, error) {
-	return http.Get(fmt.Sprintf("http://%s/healthz", srv.addr))
+	return http.Get(fmt.Sprintf("http://%s/healthz", srv.addrs[0]))
 }
 
 type testMetricsHandler struct{}
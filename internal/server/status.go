@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	CollectionStateRunning = "running"
+	CollectionStatePaused  = "paused"
+	CollectionStateError   = "error"
+	CollectionStateFailed  = "failed"
+
+	// CollectionStateDisabled is the state of a collection whose watcher was disabled via the admin api.
+	CollectionStateDisabled = "disabled"
+)
+
+// CollectionStatus holds the operational state of a watched collection.
+type CollectionStatus struct {
+	State           string     `json:"state"`
+	LastEventTime   *time.Time `json:"lastEventTime,omitempty"`
+	EventsPublished int64      `json:"eventsPublished"`
+	Lag             string     `json:"lag,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// Status keeps track of the operational state of each watched collection since the connector started.
+type Status struct {
+	startedAt time.Time
+
+	mu          sync.RWMutex
+	collections map[string]*CollectionStatus
+}
+
+// NewStatus creates a new Status, where each of the given collections is running.
+func NewStatus(colls ...string) *Status {
+	s := &Status{
+		startedAt:   time.Now(),
+		collections: make(map[string]*CollectionStatus, len(colls)),
+	}
+	for _, coll := range colls {
+		s.collections[coll] = &CollectionStatus{State: CollectionStateRunning}
+	}
+	return s
+}
+
+// SetState sets the state of the given collection, along with the error that caused it, if any.
+func (s *Status) SetState(coll, state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs := s.collection(coll)
+	cs.State = state
+	cs.Error = ""
+	if err != nil {
+		cs.Error = err.Error()
+	}
+}
+
+// RecordPublished records that a change event of the given collection, which occurred at the given time, was
+// published. The collection is running again, and its lag is the time elapsed since the change event occurred.
+func (s *Status) RecordPublished(coll string, eventTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs := s.collection(coll)
+	cs.State = CollectionStateRunning
+	cs.Error = ""
+	cs.EventsPublished++
+	if !eventTime.IsZero() {
+		cs.LastEventTime = &eventTime
+		cs.Lag = time.Since(eventTime).String()
+	}
+}
+
+// Collections returns a snapshot of the state of each collection.
+func (s *Status) Collections() map[string]CollectionStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collections := make(map[string]CollectionStatus, len(s.collections))
+	for coll, cs := range s.collections {
+		collections[coll] = *cs
+	}
+	return collections
+}
+
+func (s *Status) collection(coll string) *CollectionStatus {
+	cs, ok := s.collections[coll]
+	if !ok {
+		cs = &CollectionStatus{}
+		s.collections[coll] = cs
+	}
+	return cs
+}
+
+func status(s *Status, instance *Instance, monitors ...NamedMonitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, statusReport(r.Context(), s, instance, monitors...))
+	}
+}
+
+// StatusReport returns the status report served by GET /status, or nil if the server has no status.
+func (s *Server) StatusReport(ctx context.Context) *StatusReport {
+	if s.status == nil {
+		return nil
+	}
+	return statusReport(ctx, s.status, s.instance, s.monitors...)
+}
+
+func statusReport(ctx context.Context, s *Status, instance *Instance, monitors ...NamedMonitor) *StatusReport {
+	report := &StatusReport{
+		Status:      UP,
+		Instance:    instance,
+		StartedAt:   s.startedAt,
+		Uptime:      time.Since(s.startedAt).Round(time.Second).String(),
+		Components:  monitorComponents(ctx, monitors...),
+		Collections: s.Collections(),
+	}
+	for _, component := range report.Components {
+		if component.Status == DOWN {
+			report.Status = DOWN
+		} else if component.Status == DEGRADED && report.Status == UP {
+			report.Status = DEGRADED
+		}
+	}
+	for _, cs := range report.Collections {
+		if cs.State == CollectionStateError || cs.State == CollectionStateFailed {
+			report.Status = DOWN
+		}
+	}
+	return report
+}
+
+// StatusReport holds the status of the connector, i.e. the one of its monitored components and watched collections.
+type StatusReport struct {
+	Status      health                         `json:"status"`
+	Instance    *Instance                      `json:"instance,omitempty"`
+	StartedAt   time.Time                      `json:"startedAt"`
+	Uptime      string                         `json:"uptime"`
+	Components  map[string]monitoredComponents `json:"components"`
+	Collections map[string]CollectionStatus    `json:"collections"`
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatus(t *testing.T) {
+	s := NewStatus("db.coll1", "db.coll2")
+
+	require.Equal(t, map[string]CollectionStatus{
+		"db.coll1": {State: CollectionStateRunning},
+		"db.coll2": {State: CollectionStateRunning},
+	}, s.Collections())
+}
+
+func TestStatus_SetState(t *testing.T) {
+	t.Run("should set the state and the error of the collection", func(t *testing.T) {
+		s := NewStatus("db.coll1")
+
+		s.SetState("db.coll1", CollectionStateError, errors.New("timeout"))
+
+		require.Equal(t, CollectionStatus{State: CollectionStateError, Error: "timeout"}, s.Collections()["db.coll1"])
+	})
+	t.Run("should clear the error once the collection is no longer failing", func(t *testing.T) {
+		s := NewStatus("db.coll1")
+
+		s.SetState("db.coll1", CollectionStateError, errors.New("timeout"))
+		s.SetState("db.coll1", CollectionStatePaused, nil)
+
+		require.Equal(t, CollectionStatus{State: CollectionStatePaused}, s.Collections()["db.coll1"])
+	})
+}
+
+func TestStatus_RecordPublished(t *testing.T) {
+	t.Run("should count published change events and track the last event time", func(t *testing.T) {
+		s := NewStatus("db.coll1")
+		eventTime := time.Now().Add(-time.Minute)
+
+		s.SetState("db.coll1", CollectionStatePaused, nil)
+		s.RecordPublished("db.coll1", eventTime.Add(-time.Second))
+		s.RecordPublished("db.coll1", eventTime)
+
+		got := s.Collections()["db.coll1"]
+		require.Equal(t, CollectionStateRunning, got.State)
+		require.Equal(t, int64(2), got.EventsPublished)
+		require.Equal(t, eventTime, *got.LastEventTime)
+		lag, err := time.ParseDuration(got.Lag)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, lag, time.Minute)
+	})
+	t.Run("should not track the last event time if unknown", func(t *testing.T) {
+		s := NewStatus("db.coll1")
+
+		s.RecordPublished("db.coll1", time.Time{})
+
+		require.Equal(t, CollectionStatus{State: CollectionStateRunning, EventsPublished: 1}, s.Collections()["db.coll1"])
+	})
+}
+
+func Test_status(t *testing.T) {
+	tests := []struct {
+		name       string
+		monitors   []NamedMonitor
+		setup      func(s *Status)
+		wantStatus health
+	}{
+		{
+			name:       "should be up if all components are up and all collections are running",
+			monitors:   []NamedMonitor{&testComponent{name: "cmp_up"}},
+			setup:      func(s *Status) {},
+			wantStatus: UP,
+		},
+		{
+			name:       "should be down if a component is down",
+			monitors:   []NamedMonitor{&testComponent{name: "cmp_down", err: errors.New("not reachable")}},
+			setup:      func(s *Status) {},
+			wantStatus: DOWN,
+		},
+		{
+			name: "should be degraded if a component is degraded and none is down",
+			monitors: []NamedMonitor{&testComponent{name: "cmp_up"},
+				&testComponent{name: "cmp_degraded", err: ErrDegraded}},
+			setup:      func(s *Status) {},
+			wantStatus: DEGRADED,
+		},
+		{
+			name: "should be down if a component is down and another one is degraded",
+			monitors: []NamedMonitor{&testComponent{name: "cmp_degraded", err: ErrDegraded},
+				&testComponent{name: "cmp_down", err: errors.New("not reachable")}},
+			setup:      func(s *Status) {},
+			wantStatus: DOWN,
+		},
+		{
+			name:     "should be down if a collection failed",
+			monitors: []NamedMonitor{&testComponent{name: "cmp_up"}},
+			setup: func(s *Status) {
+				s.SetState("db.coll1", CollectionStateError, errors.New("timeout"))
+			},
+			wantStatus: DOWN,
+		},
+		{
+			name:     "should be down if a collection failed in isolation",
+			monitors: []NamedMonitor{&testComponent{name: "cmp_up"}},
+			setup: func(s *Status) {
+				s.SetState("db.coll1", CollectionStateFailed, errors.New("collection dropped"))
+			},
+			wantStatus: DOWN,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStatus("db.coll1")
+			tt.setup(s)
+			instance := &Instance{Id: "connector-0"}
+
+			rec := httptest.NewRecorder()
+			status(s, instance, tt.monitors...)(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			gotBody := StatusReport{}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotBody))
+			require.Equal(t, tt.wantStatus, gotBody.Status)
+			require.Equal(t, instance, gotBody.Instance)
+			require.Len(t, gotBody.Components, len(tt.monitors))
+			require.Equal(t, s.Collections(), gotBody.Collections)
+			require.NotEmpty(t, gotBody.Uptime)
+		})
+	}
+}
+
+func TestServer_StatusReport(t *testing.T) {
+	t.Run("should return the status report", func(t *testing.T) {
+		s := NewStatus("db.coll1")
+		s.SetState("db.coll1", CollectionStatePaused, nil)
+		srv := New(WithStatus(s), WithInstance(&Instance{Id: "connector-0"}),
+			WithNamedMonitors(&testComponent{name: "cmp_down", err: errors.New("not reachable")}))
+
+		report := srv.StatusReport(context.Background())
+
+		require.Equal(t, DOWN, report.Status)
+		require.Equal(t, "connector-0", report.Instance.Id)
+		require.Equal(t, map[string]monitoredComponents{"cmp_down": {Status: DOWN}}, report.Components)
+		require.Equal(t, s.Collections(), report.Collections)
+	})
+	t.Run("should return nil without status", func(t *testing.T) {
+		require.Nil(t, New().StatusReport(context.Background()))
+	})
+}
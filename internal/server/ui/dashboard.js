@@ -0,0 +1,213 @@
+// The dashboard polls the endpoints of the connector, relative to /ui/ so that it works behind a reverse proxy too,
+// and keeps the throughput of each collection over the last samples to draw its sparkline.
+"use strict";
+
+const pollInterval = 5000;
+const maxSamples = 60;
+const maxErrors = 50;
+const tokenKey = "mongodb-nats-connector.token";
+
+const samples = new Map(); // collection => [{time, published}]
+const recentErrors = new Map(); // key => {time, collection, source, error}
+
+async function get(path) {
+  const headers = {};
+  const token = sessionStorage.getItem(tokenKey);
+  if (token) {
+    headers["Authorization"] = "Bearer " + token;
+  }
+  const res = await fetch("../" + path, {headers, cache: "no-store"});
+  if (res.status === 401) {
+    throw new Unauthorized();
+  }
+  if (res.status === 404) {
+    return null; // not served by this connector, e.g. without Prometheus or journal
+  }
+  if (!res.ok) {
+    throw new Error(`GET /${path} responded with ${res.status}`);
+  }
+  return res;
+}
+
+class Unauthorized extends Error {
+}
+
+// publishedByCollection sums mongodb_change_events_published_total by collection, over its operations.
+function publishedByCollection(metrics) {
+  const published = new Map();
+  for (const line of metrics.split("\n")) {
+    const match = line.match(/^mongodb_change_events_published_total\{([^}]*)}\s+(\S+)/);
+    if (!match) {
+      continue;
+    }
+    const labels = {};
+    for (const [, name, value] of match[1].matchAll(/(\w+)="((?:[^"\\]|\\.)*)"/g)) {
+      labels[name] = value;
+    }
+    const coll = labels.database + "." + labels.collection;
+    published.set(coll, (published.get(coll) || 0) + Number(match[2]));
+  }
+  return published;
+}
+
+function recordSample(coll, time, published) {
+  let list = samples.get(coll);
+  if (!list) {
+    list = [];
+    samples.set(coll, list);
+  }
+  list.push({time, published});
+  if (list.length > maxSamples + 1) {
+    list.shift();
+  }
+}
+
+// rates returns the throughput of the given collection between each of its samples, in events per second.
+function rates(coll) {
+  const list = samples.get(coll) || [];
+  const rates = [];
+  for (let i = 1; i < list.length; i++) {
+    const seconds = (list[i].time - list[i - 1].time) / 1000;
+    const delta = list[i].published - list[i - 1].published;
+    rates.push(seconds > 0 && delta >= 0 ? delta / seconds : 0);
+  }
+  return rates;
+}
+
+function recordError(key, time, collection, source, error) {
+  if (!recentErrors.has(key)) {
+    recentErrors.set(key, {time, collection, source, error});
+  }
+  if (recentErrors.size > maxErrors) {
+    const oldest = [...recentErrors.entries()].sort((a, b) => a[1].time - b[1].time)[0];
+    recentErrors.delete(oldest[0]);
+  }
+}
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [name, value] of Object.entries(attrs || {})) {
+    node.setAttribute(name, value);
+  }
+  node.append(...children);
+  return node;
+}
+
+function badge(state) {
+  return el("span", {class: "badge " + state}, state);
+}
+
+function sparkline(values) {
+  const ns = "http://www.w3.org/2000/svg";
+  const svg = document.createElementNS(ns, "svg");
+  svg.setAttribute("class", "sparkline");
+  svg.setAttribute("viewBox", `0 0 ${maxSamples} 28`);
+  svg.setAttribute("preserveAspectRatio", "none");
+  if (values.length > 1) {
+    const max = Math.max(...values, 1);
+    const offset = maxSamples - values.length + 1;
+    const points = values.map((v, i) => `${offset + i},${(27 - (v / max) * 26).toFixed(2)}`);
+    const line = document.createElementNS(ns, "polyline");
+    line.setAttribute("points", points.join(" "));
+    svg.append(line);
+  }
+  return svg;
+}
+
+function emptyRow(columns, text) {
+  return el("tr", {}, el("td", {colspan: columns, class: "empty"}, text));
+}
+
+function renderComponents(components) {
+  const rows = Object.entries(components || {}).sort().map(([name, c]) => el("tr", {},
+    el("td", {}, name),
+    el("td", {}, badge(c.status)),
+    el("td", {}, Object.entries(c.details || {}).map(([k, v]) => `${k}: ${JSON.stringify(v)}`).join(", ")),
+  ));
+  document.getElementById("components").replaceChildren(...(rows.length ? rows : [emptyRow(3, "No component")]));
+}
+
+function renderCollections(collections) {
+  const rows = Object.entries(collections).sort().map(([coll, cs]) => {
+    const r = rates(coll);
+    return el("tr", {},
+      el("td", {}, coll),
+      el("td", {}, badge(cs.state)),
+      el("td", {}, cs.lag || "–"),
+      el("td", {}, cs.lastEventTime ? new Date(cs.lastEventTime).toLocaleString() : "–"),
+      el("td", {}, String(cs.eventsPublished)),
+      el("td", {}, sparkline(r), el("span", {class: "rate"}, r.length ? r[r.length - 1].toFixed(1) : "–")),
+      el("td", {class: "error"}, cs.error || ""),
+    );
+  });
+  document.getElementById("collections").replaceChildren(...(rows.length ? rows : [emptyRow(7, "No collection")]));
+}
+
+function renderErrors() {
+  const rows = [...recentErrors.values()].sort((a, b) => b.time - a.time).map(e => el("tr", {},
+    el("td", {}, e.time.toLocaleString()),
+    el("td", {}, e.collection),
+    el("td", {}, e.source),
+    el("td", {class: "error"}, e.error),
+  ));
+  document.getElementById("errors").replaceChildren(...(rows.length ? rows : [emptyRow(4, "No recent error")]));
+}
+
+async function poll() {
+  const now = new Date();
+  const report = await (await get("status")).json();
+  const metrics = await get("metrics");
+  const journal = await get("admin/journal");
+
+  const published = metrics ? publishedByCollection(await metrics.text()) : new Map();
+  for (const [coll, cs] of Object.entries(report.collections)) {
+    // the events published since the connector started, if its metrics are not scraped by Prometheus
+    recordSample(coll, now.getTime(), published.has(coll) ? published.get(coll) : cs.eventsPublished);
+    if (cs.error) {
+      recordError(`status:${coll}:${cs.error}`, now, coll, cs.state, cs.error);
+    }
+  }
+  if (journal) {
+    for (const [coll, entries] of Object.entries((await journal.json()).collections)) {
+      for (const entry of entries.filter(e => e.result === "failed")) {
+        recordError(`journal:${coll}:${entry.msgId}:${entry.time}`, new Date(entry.time), coll,
+          "publish " + entry.subject, entry.error || "failed");
+      }
+    }
+  }
+
+  const status = document.getElementById("status");
+  status.className = "badge " + report.status;
+  status.textContent = report.status;
+  document.getElementById("instance").textContent = report.instance ? report.instance.id : "";
+  document.getElementById("uptime").textContent = "up " + report.uptime;
+  document.getElementById("updated").textContent = "updated " + now.toLocaleTimeString();
+  renderComponents(report.components);
+  renderCollections(report.collections);
+  renderErrors();
+}
+
+async function refresh() {
+  const failure = document.getElementById("failure");
+  try {
+    await poll();
+    failure.hidden = true;
+  } catch (err) {
+    if (err instanceof Unauthorized) {
+      document.getElementById("auth").hidden = false;
+      return; // polled again once a token is entered
+    }
+    failure.textContent = "Could not refresh the dashboard: " + err.message;
+    failure.hidden = false;
+  }
+  setTimeout(refresh, pollInterval);
+}
+
+document.getElementById("auth").addEventListener("submit", event => {
+  event.preventDefault();
+  sessionStorage.setItem(tokenKey, document.getElementById("token").value.trim());
+  document.getElementById("auth").hidden = true;
+  refresh();
+});
+
+refresh();
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Watchers disables and enables the watchers of the watched collections, e.g. to stop publishing the change events of
+// a collection during an incident.
+type Watchers interface {
+	// DisableWatcher stops the watcher of the given collection, once its in-flight change events are published, until
+	// it is enabled again.
+	DisableWatcher(ctx context.Context, namespace string) error
+	// EnableWatcher starts the watcher of the given collection again, resuming its change stream after the last
+	// published change event.
+	EnableWatcher(ctx context.Context, namespace string) error
+}
+
+type watcherResponse struct {
+	Collection string `json:"collection"`
+	State      string `json:"state"`
+}
+
+func disableWatcher(w Watchers) http.HandlerFunc {
+	return toggleWatcher(w.DisableWatcher, CollectionStateDisabled)
+}
+
+func enableWatcher(w Watchers) http.HandlerFunc {
+	return toggleWatcher(w.EnableWatcher, CollectionStateRunning)
+}
+
+func toggleWatcher(toggle func(ctx context.Context, namespace string) error, state string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := r.PathValue("namespace")
+		switch err := toggle(r.Context(), namespace); {
+		case err == nil:
+			writeJson(w, http.StatusOK, &watcherResponse{Collection: namespace, State: state})
+		case errors.Is(err, ErrCollectionNotFound):
+			writeJsonError(w, http.StatusNotFound, err)
+		default:
+			writeJsonError(w, http.StatusInternalServerError, err)
+		}
+	}
+}
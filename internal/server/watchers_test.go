@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testWatchers struct {
+	disabled map[string]bool
+	err      error
+}
+
+func (w *testWatchers) DisableWatcher(_ context.Context, namespace string) error {
+	return w.toggle(namespace, true)
+}
+
+func (w *testWatchers) EnableWatcher(_ context.Context, namespace string) error {
+	return w.toggle(namespace, false)
+}
+
+func (w *testWatchers) toggle(namespace string, disabled bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	if _, ok := w.disabled[namespace]; !ok {
+		return ErrCollectionNotFound
+	}
+	w.disabled[namespace] = disabled
+	return nil
+}
+
+func Test_toggleWatcher(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		err          error
+		wantCode     int
+		wantBody     string
+		wantDisabled bool
+	}{
+		{
+			name:         "should disable the watcher of the given collection",
+			url:          "/admin/collections/shop.orders/disable",
+			wantCode:     http.StatusOK,
+			wantBody:     `{"collection":"shop.orders","state":"disabled"}`,
+			wantDisabled: true,
+		},
+		{
+			name:     "should enable the watcher of the given collection",
+			url:      "/admin/collections/shop.orders/enable",
+			wantCode: http.StatusOK,
+			wantBody: `{"collection":"shop.orders","state":"running"}`,
+		},
+		{
+			name:     "should return not found if the collection is not watched",
+			url:      "/admin/collections/shop.users/disable",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":{"code":404,"message":"collection not found","requestId":"req-1"}}`,
+		},
+		{
+			name:     "should return internal server error if the state cannot be persisted",
+			url:      "/admin/collections/shop.orders/disable",
+			err:      errors.New("could not persist watcher state"),
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"error":{"code":500,"message":"could not persist watcher state","requestId":"req-1"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchers := &testWatchers{disabled: map[string]bool{"shop.orders": !tt.wantDisabled}, err: tt.err}
+			srv := New(WithWatchers(watchers))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.url, nil)
+			req.Header.Set("X-Request-Id", "req-1")
+			srv.http.Handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			require.True(t, json.Valid(rec.Body.Bytes()))
+			require.JSONEq(t, tt.wantBody, rec.Body.String())
+			if tt.err == nil && tt.wantCode == http.StatusOK {
+				require.Equal(t, tt.wantDisabled, watchers.disabled["shop.orders"])
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// ReadyState tells the service manager that the service finished starting up.
+	ReadyState = "READY=1"
+
+	// StoppingState tells the service manager that the service is shutting down.
+	StoppingState = "STOPPING=1"
+
+	// WatchdogState pets the watchdog of the service manager, which restarts the service if it is not pet in time.
+	WatchdogState = "WATCHDOG=1"
+)
+
+// Notify sends the given state to the service manager over the socket named by NOTIFY_SOCKET, as sd_notify does. It
+// returns false, without error, if the process is not expected to send notifications, e.g. when it is not run by a
+// systemd service of type notify.
+func Notify(state string) (bool, error) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return false, nil
+	}
+	if name[0] == '@' { // abstract socket
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("could not connect to notify socket: %v", err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("could not notify %v: %v", state, err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval within which the watchdog must be pet, as set by WATCHDOG_USEC, or zero if the
+// watchdog is not enabled for the process, i.e. if it is not set or WATCHDOG_PID is the pid of another process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC: %v", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
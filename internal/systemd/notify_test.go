@@ -0,0 +1,79 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	t.Run("should send the state to the notify socket", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "systemd")
+		require.Nil(t, err)
+		t.Cleanup(func() { _ = os.RemoveAll(dir) })
+		name := filepath.Join(dir, "notify.sock")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+		require.Nil(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		t.Setenv("NOTIFY_SOCKET", name)
+
+		sent, err := Notify(ReadyState)
+
+		require.Nil(t, err)
+		require.True(t, sent)
+		buf := make([]byte, 64)
+		require.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := conn.Read(buf)
+		require.Nil(t, err)
+		require.Equal(t, ReadyState, string(buf[:n]))
+	})
+	t.Run("should not send the state if there is no notify socket", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+
+		sent, err := Notify(ReadyState)
+
+		require.Nil(t, err)
+		require.False(t, sent)
+	})
+	t.Run("should return error if the notify socket cannot be connected to", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "/nonexistent/notify.sock")
+
+		sent, err := Notify(ReadyState)
+
+		require.NotNil(t, err)
+		require.False(t, sent)
+	})
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		usec     string
+		pid      string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{name: "disabled", usec: "", interval: 0},
+		{name: "enabled", usec: "30000000", interval: 30 * time.Second},
+		{name: "enabled for the process", usec: "500000", pid: strconv.Itoa(os.Getpid()), interval: 500 * time.Millisecond},
+		{name: "enabled for another process", usec: "500000", pid: "1", interval: 0},
+		{name: "invalid", usec: "soon", wantErr: true},
+		{name: "not positive", usec: "0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			interval, err := WatchdogInterval()
+
+			require.Equal(t, tt.wantErr, err != nil)
+			require.Equal(t, tt.interval, interval)
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+var errTokenBackupNotFound = errors.New("token backup not found")
+
+// tokenBackup is a backup of the last stored resume token of each watched collection, along with the sequence of the
+// last message acknowledged by each stream when it was taken, so that consumers can be lined up with the tokens.
+type tokenBackup struct {
+	InstanceId      string            `json:"instanceId"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	Collections     []backedUpToken   `json:"collections"`
+	StreamSequences map[string]uint64 `json:"streamSequences,omitempty"`
+}
+
+// backedUpToken is the last stored resume token of a watched collection, as backed up.
+type backedUpToken struct {
+	DbName      string `json:"dbName"`
+	CollName    string `json:"collName"`
+	StreamName  string `json:"streamName,omitempty"`
+	ResumeToken string `json:"resumeToken"`
+}
+
+// token returns the resume token of the given collection in the backup, or an empty string if it has none.
+func (b *tokenBackup) token(coll *collection) string {
+	if b == nil {
+		return ""
+	}
+	for _, t := range b.Collections {
+		if t.DbName == coll.dbName && t.CollName == coll.collName {
+			return t.ResumeToken
+		}
+	}
+	return ""
+}
+
+// restorePolicy tells which stored resume tokens are overwritten by the backed up ones once they are restored.
+type restorePolicy int
+
+const (
+	restoreMissing restorePolicy = iota // only the collections without resume token are restored
+	restoreOlder                        // the resume tokens older than the backed up ones are overwritten as well
+	restoreAll                          // all the resume tokens are overwritten
+)
+
+func (c *Connector) tokenBackupEnabled() bool {
+	return c.options.tokenBackupBucket != "" || c.options.tokenBackupPath != ""
+}
+
+func (c *Connector) tokenBackupReplicated() bool {
+	return c.options.tokenBackupReplicaUrl != "" || c.options.tokenBackupReplica != nil
+}
+
+// recordStreamSequence records the sequence of the last message acknowledged by the given stream.
+func (c *Connector) recordStreamSequence(stream string, seq uint64) {
+	c.streamSequencesMu.Lock()
+	defer c.streamSequencesMu.Unlock()
+	if c.streamSequences == nil {
+		c.streamSequences = make(map[string]uint64)
+	}
+	c.streamSequences[stream] = max(c.streamSequences[stream], seq)
+}
+
+// backupTokens backs the resume tokens up once started, then every backup interval, until the given context is
+// cancelled. Failures are logged, and the resume tokens are backed up again on the next interval.
+func (c *Connector) backupTokens(ctx context.Context) {
+	ticker := time.NewTicker(c.options.tokenBackupInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.backupTokensOnce(ctx); err != nil && ctx.Err() == nil {
+			c.logger.Warn("could not back resume tokens up", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backupTokensOnce backs the last stored resume token of each watched collection up. The collections without resume
+// token keep the one of the previous backup, if any, so that losing the resume tokens database does not erase it.
+func (c *Connector) backupTokensOnce(ctx context.Context) error {
+	previous, err := c.readTokenBackup(ctx, c.options.instanceId)
+	if err != nil && !errors.Is(err, errTokenBackupNotFound) {
+		c.logger.Warn("could not read previous resume tokens backup, overwriting it", "err", err)
+	}
+
+	c.streamSequencesMu.Lock()
+	backup := &tokenBackup{
+		InstanceId:      c.options.instanceId,
+		CreatedAt:       time.Now().UTC(),
+		Collections:     make([]backedUpToken, 0, len(c.options.collections)),
+		StreamSequences: maps.Clone(c.streamSequences),
+	}
+	c.streamSequencesMu.Unlock()
+	for _, coll := range c.options.collections {
+		token, err := c.options.mongoClient.LastResumeToken(ctx, resumeTokensOptions(coll))
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			token = previous.token(coll)
+		}
+		backup.Collections = append(backup.Collections, backedUpToken{DbName: coll.dbName, CollName: coll.collName,
+			StreamName: coll.streamName, ResumeToken: token})
+	}
+
+	data, err := json.Marshal(backup)
+	if err != nil {
+		return fmt.Errorf("could not encode resume tokens backup: %v", err)
+	}
+	if c.options.tokenBackupBucket == "" {
+		return writeFileAtomically(c.options.tokenBackupPath, data)
+	}
+	name := tokenBackupName(c.options.instanceId)
+	err = c.options.natsClient.PutObject(ctx, c.options.tokenBackupBucket, name, data)
+	if c.options.tokenBackupReplica != nil {
+		if replicaErr := c.options.tokenBackupReplica.PutObject(ctx, c.options.tokenBackupBucket, name,
+			data); replicaErr != nil {
+			err = errors.Join(err, fmt.Errorf("could not replicate resume tokens backup: %w", replicaErr))
+		}
+	}
+	return err
+}
+
+// tokenBackupName returns the name of the object the resume tokens of the Connector with the given instance id are
+// backed up to.
+func tokenBackupName(instanceId string) string {
+	return instanceId + ".json"
+}
+
+// readTokenBackup reads the latest backup of the resume tokens of the Connector with the given instance id, or returns
+// errTokenBackupNotFound if there is none.
+func (c *Connector) readTokenBackup(ctx context.Context, instanceId string) (*tokenBackup, error) {
+	if c.options.tokenBackupBucket == "" {
+		return readTokenBackupFile(c.options.tokenBackupPath)
+	}
+	data, err := c.options.natsClient.GetObject(ctx, c.options.tokenBackupBucket, tokenBackupName(instanceId))
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil, fmt.Errorf("%w: %v", errTokenBackupNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeTokenBackup(data)
+}
+
+func readTokenBackupFile(path string) (*tokenBackup, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", errTokenBackupNotFound, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read resume tokens backup: %v", err)
+	}
+	return decodeTokenBackup(data)
+}
+
+func decodeTokenBackup(data []byte) (*tokenBackup, error) {
+	backup := &tokenBackup{}
+	if err := json.Unmarshal(data, backup); err != nil {
+		return nil, fmt.Errorf("could not decode resume tokens backup: %v", err)
+	}
+	return backup, nil
+}
+
+// writeFileAtomically writes the given data to the given file, through a temporary file renamed once written, so that
+// the file is never left half written.
+func writeFileAtomically(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("could not write resume tokens backup: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not write resume tokens backup: %v", err)
+	}
+	return nil
+}
+
+// RestoreTokens restores the resume tokens of the watched collections from the given backup file, or from the latest
+// backup of the Connector if it is empty, so that their change streams are resumed after them, e.g. once the resume
+// tokens database was lost. The collections which already have a resume token are skipped, unless force is true.
+// RestoreTokens is an alternative to Run: once it returns, the Connector is closed. It returns the number of resume
+// tokens that were restored.
+func (c *Connector) RestoreTokens(path string, force bool) (int, error) {
+	defer c.cleanup()
+	defer c.pushMetrics("restore-tokens")
+
+	backup, err := c.loadTokenBackup(path, c.options.instanceId)
+	if err != nil {
+		return 0, fmt.Errorf("could not restore resume tokens: %w", err)
+	}
+	policy := restoreMissing
+	if force {
+		policy = restoreAll
+	}
+	return c.restoreTokens(backup, policy)
+}
+
+// Promote activates the watchers of a passive Connector, i.e. the one of the passive region of an active/passive
+// deployment, from the resume tokens replicated from the active Connector with the given instance id, or with the
+// instance id of the Connector if it is empty: they are restored from the given backup file, or from the latest
+// replicated backup if it is empty, overwriting the stored resume tokens only if they are older, then the Connector is
+// run.
+// Promote is an alternative to Run.
+func (c *Connector) Promote(path, instanceId string) error {
+	if instanceId == "" {
+		instanceId = c.options.instanceId
+	}
+	backup, err := c.loadTokenBackup(path, instanceId)
+	if err == nil {
+		_, err = c.restoreTokens(backup, restoreOlder)
+	}
+	if err != nil {
+		c.cleanup()
+		return fmt.Errorf("%w: could not promote connector: %w", ErrPreflightFailed, err)
+	}
+	c.logger.Info("connector promoted", "from", backup.InstanceId, "backedUpAt", backup.CreatedAt)
+	return c.Run()
+}
+
+// loadTokenBackup reads the backup of the resume tokens from the given file, or the latest backup of the Connector
+// with the given instance id if it is empty.
+func (c *Connector) loadTokenBackup(path, instanceId string) (*tokenBackup, error) {
+	switch {
+	case path != "":
+		return readTokenBackupFile(path)
+	case c.tokenBackupEnabled():
+		return c.readTokenBackup(c.options.ctx, instanceId)
+	default:
+		return nil, errors.New("token backup is not configured, a backup file must be given")
+	}
+}
+
+// restoreTokens restores the resume tokens of the watched collections from the given backup, overwriting the stored
+// ones as told by the given policy. It returns the number of resume tokens that were restored.
+func (c *Connector) restoreTokens(backup *tokenBackup, policy restorePolicy) (int, error) {
+	ctx := c.options.ctx
+	restored := 0
+	for _, coll := range c.options.collections {
+		token := backup.token(coll)
+		if token == "" {
+			c.logger.Warn("no resume token backed up, skipping collection", "dbName", coll.dbName,
+				"collName", coll.collName)
+			continue
+		}
+		opts := resumeTokensOptions(coll)
+		if policy != restoreAll {
+			current, err := c.options.mongoClient.LastResumeToken(ctx, opts)
+			if err != nil {
+				return restored, err
+			}
+			if current == token || (current != "" && (policy == restoreMissing || !mongo.ResumeTokenAfter(token,
+				current))) {
+				c.logger.Info("resume token already stored, skipping collection", "dbName", coll.dbName,
+					"collName", coll.collName)
+				continue
+			}
+		}
+		createResumeTokensCollOpts := &mongo.CreateCollectionOptions{
+			DbName:      coll.tokensDbName,
+			CollName:    coll.tokensCollName,
+			Capped:      coll.tokensCollCapped,
+			SizeInBytes: coll.tokensCollSizeInBytes,
+			Expiring:    coll.tokensCollTtl > 0,
+		}
+		if err := c.options.mongoClient.CreateCollection(ctx, createResumeTokensCollOpts); err != nil {
+			return restored, err
+		}
+		if err := c.options.mongoClient.RestoreResumeToken(ctx, opts, token); err != nil {
+			return restored, err
+		}
+		c.logger.Info("resume token restored", "dbName", coll.dbName, "collName", coll.collName,
+			"backedUpAt", backup.CreatedAt)
+		restored++
+	}
+	return restored, nil
+}
+
+// resumeTokensOptions returns the collection where the resume tokens of the given collection are stored.
+func resumeTokensOptions(coll *collection) *mongo.ResumeTokensOptions {
+	return &mongo.ResumeTokensOptions{
+		DbName:   coll.tokensDbName,
+		CollName: coll.tokensCollName,
+		Capped:   coll.tokensCollCapped,
+	}
+}
@@ -0,0 +1,215 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnector_backupTokensOnce(t *testing.T) {
+	newConnector := func(mongoClient *mockMongoClient, natsClient *mockNatsClient, bucket, path string) *Connector {
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(natsClient),
+			WithServerDisabled(),
+			WithInstanceId("connector-0"),
+			WithTokenBackup(bucket, path, 0),
+			WithCollection("shop", "orders", WithStreamName("ORDERS")),
+			WithCollection("shop", "invoices", WithStreamName("INVOICES")),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+
+	t.Run("should back the resume tokens and stream sequences up to the object store", func(t *testing.T) {
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{"resume-tokens.orders": "token-1"}}
+		natsClient := &mockNatsClient{}
+		conn := newConnector(mongoClient, natsClient, "resume-token-backups", "")
+		conn.recordStreamSequence("ORDERS", 42)
+		conn.recordStreamSequence("ORDERS", 41) // acknowledged out of order
+
+		err := conn.backupTokensOnce(context.Background())
+
+		require.NoError(t, err)
+		data, err := natsClient.GetObject(context.Background(), "resume-token-backups", "connector-0.json")
+		require.NoError(t, err)
+		backup, err := decodeTokenBackup(data)
+		require.NoError(t, err)
+		require.Equal(t, "connector-0", backup.InstanceId)
+		require.WithinDuration(t, time.Now(), backup.CreatedAt, time.Minute)
+		require.Equal(t, []backedUpToken{
+			{DbName: "shop", CollName: "orders", StreamName: "ORDERS", ResumeToken: "token-1"},
+			{DbName: "shop", CollName: "invoices", StreamName: "INVOICES"},
+		}, backup.Collections)
+		require.Equal(t, map[string]uint64{"ORDERS": 42}, backup.StreamSequences)
+	})
+	t.Run("should back the resume tokens up to the replica as well", func(t *testing.T) {
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{"resume-tokens.orders": "token-1"}}
+		natsClient, replica := &mockNatsClient{}, &mockNatsClient{}
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(natsClient),
+			withTokenBackupReplicaNatsClient(replica),
+			WithServerDisabled(),
+			WithInstanceId("connector-0"),
+			WithTokenBackup("resume-token-backups", "", 0),
+			WithCollection("shop", "orders"),
+		)
+		require.NoError(t, err)
+
+		err = conn.backupTokensOnce(context.Background())
+
+		require.NoError(t, err)
+		data, err := natsClient.GetObject(context.Background(), "resume-token-backups", "connector-0.json")
+		require.NoError(t, err)
+		replicated, err := replica.GetObject(context.Background(), "resume-token-backups", "connector-0.json")
+		require.NoError(t, err)
+		require.Equal(t, data, replicated)
+	})
+	t.Run("should keep the backed up resume tokens of the collections without resume token", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tokens.json")
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{
+			"resume-tokens.orders":   "token-1",
+			"resume-tokens.invoices": "token-2",
+		}}
+		conn := newConnector(mongoClient, &mockNatsClient{}, "", path)
+		require.NoError(t, conn.backupTokensOnce(context.Background()))
+
+		mongoClient.resumeTokens = map[string]string{"resume-tokens.orders": "token-3"} // resume tokens lost
+		err := conn.backupTokensOnce(context.Background())
+
+		require.NoError(t, err)
+		backup, err := readTokenBackupFile(path)
+		require.NoError(t, err)
+		require.Equal(t, []backedUpToken{
+			{DbName: "shop", CollName: "orders", StreamName: "ORDERS", ResumeToken: "token-3"},
+			{DbName: "shop", CollName: "invoices", StreamName: "INVOICES", ResumeToken: "token-2"},
+		}, backup.Collections)
+		_, err = os.Stat(path + ".tmp")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestConnector_RestoreTokens(t *testing.T) {
+	newConnector := func(mongoClient *mockMongoClient, natsClient *mockNatsClient, bucket string) *Connector {
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(natsClient),
+			WithServerDisabled(),
+			WithInstanceId("connector-0"),
+			WithTokenBackup(bucket, "", 0),
+			WithCollection("shop", "orders", WithStreamName("ORDERS")),
+			WithCollection("shop", "invoices", WithStreamName("INVOICES")),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+	backup := []byte(`{"instanceId":"connector-0","createdAt":"2026-10-14T10:00:00Z","collections":[` +
+		`{"dbName":"shop","collName":"orders","resumeToken":"token-1"},` +
+		`{"dbName":"shop","collName":"invoices","resumeToken":"token-2"}]}`)
+
+	t.Run("should restore the resume tokens of the collections without resume token", func(t *testing.T) {
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{"resume-tokens.invoices": "token-3"}}
+		natsClient := &mockNatsClient{}
+		require.NoError(t, natsClient.PutObject(context.Background(), "resume-token-backups", "connector-0.json",
+			backup))
+		conn := newConnector(mongoClient, natsClient, "resume-token-backups")
+
+		restored, err := conn.RestoreTokens("", false)
+
+		require.NoError(t, err)
+		require.Equal(t, 1, restored)
+		require.Equal(t, map[string]string{"resume-tokens.orders": "token-1", "resume-tokens.invoices": "token-3"},
+			mongoClient.resumeTokens)
+		require.True(t, mongoClient.closed)
+		require.True(t, natsClient.closed)
+	})
+	t.Run("should overwrite the resume tokens from the given file if forced", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tokens.json")
+		require.NoError(t, os.WriteFile(path, backup, 0o600))
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{"resume-tokens.invoices": "token-3"}}
+		conn := newConnector(mongoClient, &mockNatsClient{}, "")
+
+		restored, err := conn.RestoreTokens(path, true)
+
+		require.NoError(t, err)
+		require.Equal(t, 2, restored)
+		require.Equal(t, map[string]string{"resume-tokens.orders": "token-1", "resume-tokens.invoices": "token-2"},
+			mongoClient.resumeTokens)
+	})
+	t.Run("should return error if there is no backup", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}, &mockNatsClient{}, "resume-token-backups").RestoreTokens("", false)
+
+		require.ErrorIs(t, err, errTokenBackupNotFound)
+	})
+	t.Run("should return error if the token backup is not configured and no file is given", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}, &mockNatsClient{}, "").RestoreTokens("", false)
+
+		require.Error(t, err)
+	})
+}
+
+func TestConnector_Promote(t *testing.T) {
+	const (
+		older = "82645A43BA000000012B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004"
+		newer = "82645A43BA000000022B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004"
+	)
+	newConnector := func(ctx context.Context, mongoClient *mockMongoClient, natsClient *mockNatsClient) *Connector {
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(natsClient),
+			WithServerDisabled(),
+			WithContext(ctx),
+			WithInstanceId("connector-dr-0"),
+			WithTokenBackup("resume-token-backups", "", 0),
+			WithCollection("shop", "orders", WithStreamName("ORDERS")),
+			WithCollection("shop", "invoices", WithStreamName("INVOICES")),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+
+	t.Run("should restore the replicated resume tokens unless newer ones are stored, then run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mongoClient := &mockMongoClient{watchUntilCancelled: true, resumeTokens: map[string]string{
+			"resume-tokens.orders":   older,
+			"resume-tokens.invoices": newer,
+		}}
+		natsClient := &mockNatsClient{}
+		backup := []byte(`{"instanceId":"connector-0","createdAt":"2026-10-14T10:00:00Z","collections":[` +
+			`{"dbName":"shop","collName":"orders","resumeToken":"` + newer + `"},` +
+			`{"dbName":"shop","collName":"invoices","resumeToken":"` + older + `"}]}`)
+		require.NoError(t, natsClient.PutObject(ctx, "resume-token-backups", "connector-0.json", backup))
+		conn := newConnector(ctx, mongoClient, natsClient)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Promote("", "connector-0")
+		}()
+
+		require.Eventually(t, func() bool { return mongoClient.WatchCollectionCalls() == 2 }, time.Second,
+			10*time.Millisecond)
+		mongoClient.mut.Lock()
+		require.Equal(t, map[string]string{"resume-tokens.orders": newer, "resume-tokens.invoices": newer},
+			mongoClient.resumeTokens)
+		mongoClient.mut.Unlock()
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+	t.Run("should return a preflight error if there is no replicated backup", func(t *testing.T) {
+		mongoClient, natsClient := &mockMongoClient{}, &mockNatsClient{}
+
+		err := newConnector(context.Background(), mongoClient, natsClient).Promote("", "connector-0")
+
+		require.ErrorIs(t, err, ErrPreflightFailed)
+		require.ErrorIs(t, err, errTokenBackupNotFound)
+		require.Zero(t, mongoClient.WatchCollectionCalls())
+		require.True(t, mongoClient.closed)
+		require.True(t, natsClient.closed)
+	})
+}
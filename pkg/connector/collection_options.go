@@ -0,0 +1,1128 @@
+package connector
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/cron"
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+// WithCollection configures a collection to be watched by the Connector, with the given options.
+func WithCollection(dbName, collName string, opts ...CollectionOption) Option {
+	return func(o *Options) error {
+		if dbName == "" {
+			return ErrDbNameMissing
+		}
+		if collName == "" {
+			return ErrCollNameMissing
+		}
+		coll := &collection{
+			dbName:                       dbName,
+			collName:                     collName,
+			changeStreamPreAndPostImages: defaultChangeStreamPreAndPostImages,
+			tokensDbName:                 defaultTokensDbName,
+			tokensCollName:               collName,
+			tokensCollCapped:             defaultTokensCollCapped,
+			tokensCollSizeInBytes:        defaultTokensCollSizeInBytes,
+			streamName:                   strings.ToUpper(collName),
+			stallTimeout:                 defaultStallTimeout,
+			msgIdStrategy:                defaultMsgIdStrategy,
+			oversizedPolicy:              defaultOversizedPolicy,
+			payloadMode:                  defaultPayloadMode,
+			jsonFlavor:                   defaultJsonFlavor,
+			decodeErrorPolicy:            defaultDecodeErrorPolicy,
+			publishMode:                  defaultPublishMode,
+			nackPolicy:                   defaultNackPolicy,
+			failureMode:                  defaultFailureMode,
+		}
+		for _, opt := range opts {
+			if err := opt(coll); err != nil {
+				return err
+			}
+		}
+		coll.tokensCollName = strings.NewReplacer("{db}", coll.dbName, "{coll}", coll.collName).
+			Replace(coll.tokensCollName)
+		if coll.tokensCollCapped && coll.tokensCollTtl > 0 {
+			return ErrInvalidTokensCollTtl
+		}
+		if strings.EqualFold(coll.dbName, coll.tokensDbName) &&
+			strings.EqualFold(coll.collName, coll.tokensCollName) {
+			return ErrInvalidDbAndCollNames
+		}
+		if coll.msgIdStrategy == mongo.DocumentFieldMsgIdStrategy && coll.msgIdField == "" {
+			return ErrMsgIdFieldMissing
+		}
+		if coll.tenantField != "" && coll.tenantDbName {
+			return ErrInvalidTenantRouting
+		}
+		if slices.ContainsFunc(coll.excludeFields, func(field string) bool {
+			return field == "" || excludes(field, coll.tenantField) || excludes(field, coll.msgIdField)
+		}) {
+			return ErrInvalidExcludeFields
+		}
+		if coll.oversizedPolicy == mongo.DlqOversizedPolicy && coll.dlqSubject == "" {
+			return ErrDlqSubjectMissing
+		}
+		if coll.oversizedPolicy == mongo.OffloadOversizedPolicy && coll.offloadBucket == "" {
+			return ErrOffloadBucketMissing
+		}
+		if action, ok := coll.errorPolicies[mongo.DecodeErrorClass]; ok {
+			coll.decodeErrorPolicy = mongo.DecodeErrorPolicy(action)
+		}
+		if coll.decodeErrorPolicy == mongo.DlqDecodeErrorPolicy && coll.dlqSubject == "" {
+			return ErrDecodeDlqSubjectMissing
+		}
+		if coll.missingImages == mongo.DlqMissingImagesPolicy && coll.dlqSubject == "" {
+			return ErrImagesDlqSubjectMissing
+		}
+		if coll.nackPolicy == nats.DlqNackPolicy && coll.dlqSubject == "" {
+			return ErrNackDlqSubjectMissing
+		}
+		if coll.errorPolicies.Dlq() && coll.dlqSubject == "" {
+			return ErrErrorDlqSubjectMissing
+		}
+		if coll.quarantineCollName != "" && (strings.EqualFold(coll.quarantineCollName, coll.tokensCollName) ||
+			strings.EqualFold(coll.dbName, coll.tokensDbName) && strings.EqualFold(coll.quarantineCollName,
+				coll.collName)) {
+			return ErrInvalidQuarantine
+		}
+		templated := coll.subjectTemplate != nil || slices.ContainsFunc(coll.routes, func(r mongo.Route) bool {
+			return r.SubjectTemplate != nil
+		})
+		if templated && (coll.namespaceSubjects || coll.partitions > 0 || coll.timeBucket != "") {
+			return ErrSubjectTemplateConflict
+		}
+		if coll.envelope != nil && coll.patch != "" {
+			return ErrEnvelopeConflict
+		}
+		if coll.jsonFlavor == mongo.CanonicalJsonFlavor && coll.typeConversions != nil {
+			return ErrTypeConversionsConflict
+		}
+		if coll.idempotentResume && (coll.publishMode != nats.JetStreamPublishMode || coll.tenantField != "" ||
+			coll.tenantDbName) {
+			return ErrIdempotentResumeConflict
+		}
+		if _, ok := mongo.GridFSBucket(coll.collName); coll.gridFs && !ok {
+			return ErrInvalidGridFS
+		}
+		o.collections = append(o.collections, coll)
+		return nil
+	}
+}
+
+type collection struct {
+	dbName                       string
+	collName                     string
+	changeStreamPreAndPostImages bool
+	tokensDbName                 string
+	tokensCollName               string
+	tokensCollCapped             bool
+	tokensCollSizeInBytes        int64
+	tokensCollTtl                time.Duration
+	streamName                   string
+	namespaceSubjects            bool
+	partitions                   int
+	timeBucket                   mongo.TimeBucket
+	subjectTemplate              *mongo.Template
+	headerTemplates              map[string]*mongo.Template
+	routes                       []mongo.Route
+	filter                       *mongo.Filter
+	eventTypes                   mongo.EventTypes
+	patch                        mongo.PatchFormat
+	envelope                     *mongo.Envelope
+	missingImages                mongo.MissingImagesPolicy
+	payloadMode                  mongo.PayloadMode
+	reshape                      *mongo.Reshape
+	flatten                      *mongo.Flatten
+	typeConversions              *mongo.Conversions
+	jsonFlavor                   mongo.JsonFlavor
+	enrichment                   *mongo.Enrichment
+	canary                       *mongo.Canary
+	snapshot                     *snapshot
+	transactions                 mongo.TransactionMode
+	schemaVersion                int
+	tenantField                  string
+	tenantDbName                 bool
+	excludeFields                []string
+	collation                    *mongo.Collation
+	splitLargeEvents             bool
+	encryptedPassthrough         bool
+	gridFs                       bool
+	gridFsObjectBucket           string
+	followRenames                bool
+	stallTimeout                 time.Duration
+	msgIdStrategy                mongo.MsgIdStrategy
+	msgIdField                   string
+	oversizedPolicy              mongo.OversizedPolicy
+	dlqSubject                   string
+	offloadBucket                string
+	decodeErrorPolicy            mongo.DecodeErrorPolicy
+	nackPolicy                   nats.NackPolicy
+	errorPolicies                mongo.ErrorPolicies
+	quarantineCollName           string
+	maxEventAge                  time.Duration
+	duplicatesWindow             time.Duration
+	streamLimits                 *nats.StreamLimits
+	publishMode                  nats.PublishMode
+	expectStream                 bool
+	strictOrdering               bool
+	idempotentResume             bool
+	ackTimeout                   time.Duration
+	retryAttempts                int
+	retryWait                    time.Duration
+	msgTtl                       time.Duration
+	failureMode                  failureMode
+	pipeline                     pipeline
+}
+
+// failureMode represents what is done once the watcher of a collection fails, e.g. because the collection was dropped.
+type failureMode string
+
+const (
+	// stopAllFailureMode shuts down the Connector, so that the watchers of all the collections stop.
+	stopAllFailureMode failureMode = "stopAll"
+
+	// isolateFailureMode only fails the collection, while the other collections are still watched.
+	isolateFailureMode failureMode = "isolate"
+)
+
+var failureModes = []failureMode{stopAllFailureMode, isolateFailureMode}
+
+func (c *collection) namespace() string {
+	return fmt.Sprintf("%s.%s", c.dbName, c.collName)
+}
+
+// excludes returns true if excluding the given field also removes the given document field, i.e. if they are the same,
+// or if the document field is nested in the excluded one.
+func excludes(excludeField, field string) bool {
+	return field != "" && (field == excludeField || strings.HasPrefix(field, excludeField+"."))
+}
+
+// CollectionOption is used to configure a MongoDB collection to be watched.
+type CollectionOption func(*collection) error
+
+// WithChangeStreamPreAndPostImages enables MongoDB's changeStreamPreAndPostImages configuration.
+//
+// Deprecated: will be removed in future versions. Set this configuration directly on MongoDB instead.
+func WithChangeStreamPreAndPostImages() CollectionOption {
+	return func(c *collection) error {
+		c.changeStreamPreAndPostImages = true
+		return nil
+	}
+}
+
+// WithTokensDbName sets the name of the MongoDB database that will store the resume tokens collection for the
+// collection to be watched.
+func WithTokensDbName(tokensDbName string) CollectionOption {
+	return func(c *collection) error {
+		if tokensDbName != "" {
+			c.tokensDbName = tokensDbName
+		}
+		return nil
+	}
+}
+
+// WithTokensCollName sets the name of the MongoDB collection that will store the resume tokens for the collection to
+// be watched, where {db} and {coll} are replaced by the names of its database and collection, e.g. {db}.{coll}.tokens.
+func WithTokensCollName(tokensCollName string) CollectionOption {
+	return func(c *collection) error {
+		if tokensCollName != "" {
+			c.tokensCollName = tokensCollName
+		}
+		return nil
+	}
+}
+
+// WithTokensCollCapped sets the MongoDB collection that will store the resume tokens for the collection to be watched
+// as capped, with the given size.
+func WithTokensCollCapped(collSizeInBytes int64) CollectionOption {
+	return func(c *collection) error {
+		if collSizeInBytes <= 0 {
+			return ErrInvalidCollSizeInBytes
+		}
+		c.tokensCollCapped = true
+		c.tokensCollSizeInBytes = collSizeInBytes
+		return nil
+	}
+}
+
+// WithTokensCollTtl makes the MongoDB collection that will store the resume tokens for the collection to be watched
+// expiring instead of capped: its superseded resume tokens are deleted by a TTL index once the given ttl elapsed,
+// while the last one is kept however long the collection is idle. If the collection exists and is capped, it is
+// migrated once the Connector starts, keeping its last resume token.
+func WithTokensCollTtl(ttl time.Duration) CollectionOption {
+	return func(c *collection) error {
+		if ttl <= 0 {
+			return ErrInvalidTokensCollTtl
+		}
+		c.tokensCollTtl = ttl
+		return nil
+	}
+}
+
+// WithStreamName sets the NATS stream name, where the MongoDB change events will be published for the collection to be
+// watched.
+func WithStreamName(streamName string) CollectionOption {
+	return func(c *collection) error {
+		if streamName != "" {
+			c.streamName = streamName
+		}
+		return nil
+	}
+}
+
+// WithNamespaceSubjects makes the change events of the collection to be watched to be published to subjects with
+// database and collection tokens, i.e. <stream>.<db>.<coll>.<op>, so that several collections can publish into the
+// same stream by setting the same stream name.
+func WithNamespaceSubjects() CollectionOption {
+	return func(c *collection) error {
+		c.namespaceSubjects = true
+		return nil
+	}
+}
+
+// WithPartitions sets the number of partitions of the subjects of the collection to be watched.
+// Each subject gains a partition token computed from the hash of the document key, i.e. <stream>.<op>.<partition>, so
+// that downstream consumers can process partitions in parallel while preserving the ordering of each document.
+func WithPartitions(partitions int) CollectionOption {
+	return func(c *collection) error {
+		if partitions < 0 {
+			return ErrInvalidPartitions
+		}
+		c.partitions = partitions
+		return nil
+	}
+}
+
+// WithTimeBucket appends a time bucket, computed from the cluster time of each change event, to the subjects of the
+// collection to be watched, e.g. <stream>.<op>.2024-06.
+// Can be set to 'year', 'month' or 'day'.
+func WithTimeBucket(timeBucket string) CollectionOption {
+	return func(c *collection) error {
+		if timeBucket == "" {
+			return nil
+		}
+		bucket := mongo.TimeBucket(timeBucket)
+		if !slices.Contains(mongo.TimeBuckets, bucket) {
+			return ErrInvalidTimeBucket
+		}
+		c.timeBucket = bucket
+		return nil
+	}
+}
+
+// WithSubjectTemplate sets the template computing the subjects of the change events of the collection to be watched,
+// after the stream name, e.g. '{{ .Collection | lower }}.{{ .OperationType }}'. The template is executed with the
+// Database, Collection, OperationType, ClusterTime and DocumentId of each change event, and can call the lower, upper,
+// replace, trimPrefix, sha256, base64 and timeFormat functions.
+func WithSubjectTemplate(subjectTemplate string) CollectionOption {
+	return func(c *collection) error {
+		if subjectTemplate == "" {
+			return nil
+		}
+		tmpl, err := mongo.NewTemplate(subjectTemplate)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSubjectTemplate, err)
+		}
+		c.subjectTemplate = tmpl
+		return nil
+	}
+}
+
+// WithHeaderTemplates adds headers to the change events of the collection to be watched, whose values are computed by
+// the given templates, by header name. The templates are executed with the same data, and can call the same
+// functions, as the subject template.
+func WithHeaderTemplates(headerTemplates map[string]string) CollectionOption {
+	return func(c *collection) error {
+		for name, text := range headerTemplates {
+			if !headerNameRegexp.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "nats-") ||
+				strings.HasPrefix(strings.ToLower(name), "connector-") {
+				return ErrInvalidHeaderTemplate
+			}
+			tmpl, err := mongo.NewTemplate(text)
+			if err != nil {
+				return fmt.Errorf("%w: header %s: %v", ErrInvalidHeaderTemplate, name, err)
+			}
+			if c.headerTemplates == nil {
+				c.headerTemplates = make(map[string]*mongo.Template, len(headerTemplates))
+			}
+			c.headerTemplates[name] = tmpl
+		}
+		return nil
+	}
+}
+
+// WithRoute routes the change events of the collection to be watched whose document has all the given field values,
+// e.g. 'region: eu', to the given stream, with the given subject template, if any, instead of the stream of the
+// collection. Nested fields can be specified by using the dot notation. Routes are evaluated in the order they are
+// added, and change events matching none of them are published to the stream of the collection.
+func WithRoute(streamName string, when map[string]string, subjectTemplate string) CollectionOption {
+	return func(c *collection) error {
+		if _, ok := when[""]; streamName == "" || len(when) == 0 || ok {
+			return ErrInvalidRoute
+		}
+		route := mongo.Route{When: when, StreamName: streamName}
+		if subjectTemplate != "" {
+			tmpl, err := mongo.NewTemplate(subjectTemplate)
+			if err != nil {
+				return fmt.Errorf("%w: route to %s: %v", ErrInvalidSubjectTemplate, streamName, err)
+			}
+			route.SubjectTemplate = tmpl
+		}
+		c.routes = append(c.routes, route)
+		return nil
+	}
+}
+
+// Filter is a condition on the fields of change events, e.g. 'fullDocumentBeforeChange.status ne archived', or a
+// group of filters which must all match (And), or at least one of them (Or). Fields are paths in the change event,
+// using the dot notation, and are compared numerically with numbers, chronologically with RFC 3339 dates, and
+// lexicographically with strings.
+type Filter struct {
+	Field string
+	// Operator is one of eq, ne, gt, gte, lt, lte, in, nin, exists.
+	Operator string
+	Value    string
+	// Values are the values compared by the in and nin operators.
+	Values []string
+	And    []Filter
+	Or     []Filter
+}
+
+func (f *Filter) mongo() mongo.Filter {
+	filter := mongo.Filter{
+		Field:    f.Field,
+		Operator: mongo.FilterOperator(f.Operator),
+		Value:    f.Value,
+		Values:   f.Values,
+	}
+	for i := range f.And {
+		filter.And = append(filter.And, f.And[i].mongo())
+	}
+	for i := range f.Or {
+		filter.Or = append(filter.Or, f.Or[i].mongo())
+	}
+	return filter
+}
+
+// WithFilter drops the insert, update, replace and delete change events of the collection to be watched which do not
+// match the given filter, for conditions the pipeline cannot express, e.g. on fields of the document before the
+// change. Dropped change events are never published.
+func WithFilter(filter *Filter) CollectionOption {
+	return func(c *collection) error {
+		if filter == nil {
+			return nil
+		}
+		f := filter.mongo()
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		c.filter = &f
+		return nil
+	}
+}
+
+// WithEventTypes maps the given operation types of the change events of the collection to be watched, among `insert`,
+// `update`, `replace` and `delete`, to domain event types, e.g. `delete` to `order.removed`. The event types are used
+// in place of the operation types in the subjects, and in the `EventType` field of the templates, and are published in
+// the `eventType` field of the change events and in their Connector-Event-Type header. The operation types which are
+// not mapped are used as event types. If empty, operation types are not mapped.
+func WithEventTypes(eventTypes map[string]string) CollectionOption {
+	return func(c *collection) error {
+		if len(eventTypes) == 0 {
+			return nil
+		}
+		e := mongo.EventTypes(maps.Clone(eventTypes))
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEventTypes, err)
+		}
+		c.eventTypes = e
+		return nil
+	}
+}
+
+// WithPatch publishes the update and replace change events of the collection to be watched as the patch turning the
+// pre-image of their document into its post-image, in their `patch` field, instead of their `fullDocument`,
+// `fullDocumentBeforeChange` and `updateDescription` fields. Can be set to 'json', which publishes an RFC 6902 JSON
+// Patch, or 'merge', which publishes an RFC 7386 JSON Merge Patch. The collection must have changeStreamPreAndPostImages
+// enabled, otherwise its change events are published as is. By default, no patch is published.
+func WithPatch(format string) CollectionOption {
+	return func(c *collection) error {
+		if format == "" {
+			return nil
+		}
+		patchFormat := mongo.PatchFormat(format)
+		if !slices.Contains(mongo.PatchFormats, patchFormat) {
+			return ErrInvalidPatch
+		}
+		c.patch = patchFormat
+		return nil
+	}
+}
+
+// Envelope tells what happens to the update and replace change events published in an envelope whose pre-image is
+// unavailable.
+type Envelope struct {
+	// MissingBefore is one of skip, which skips the change events, publishWithout, which publishes them without their
+	// before field, or fail, which fails the watcher. If empty, they are published without their before field.
+	MissingBefore string
+}
+
+// WithEnvelope publishes the update and replace change events of the collection to be watched with both the pre-image
+// and the post-image of their document, in their `before` and `after` fields, instead of their
+// `fullDocumentBeforeChange` and `fullDocument` fields, so that consumers get both of them in a single message. The
+// collection must have changeStreamPreAndPostImages enabled, and the given envelope tells what happens to the change
+// events whose pre-image is unavailable nonetheless, e.g. since it expired.
+func WithEnvelope(envelope *Envelope) CollectionOption {
+	return func(c *collection) error {
+		if envelope == nil {
+			return nil
+		}
+		e := &mongo.Envelope{MissingBefore: mongo.MissingBeforePolicy(envelope.MissingBefore)}
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+		}
+		c.envelope = e
+		return nil
+	}
+}
+
+// WithPayloadMode sets which fields of the change events of the collection to be watched are published in their
+// payload. Can be set to 'full', which publishes all of them, or 'slim', which removes the bookkeeping fields of the
+// change stream, i.e. `_id` (the resume token), `ns`, `clusterTime`, `wallTime` and `documentKey`, and publishes them in
+// the Connector-Resume-Token, Connector-Namespace, Connector-Cluster-Time, Connector-Wall-Time and
+// Connector-Document-Key headers instead. The default is 'full'.
+func WithPayloadMode(mode string) CollectionOption {
+	return func(c *collection) error {
+		if mode == "" {
+			return nil
+		}
+		payloadMode := mongo.PayloadMode(mode)
+		if !slices.Contains(mongo.PayloadModes, payloadMode) {
+			return ErrInvalidPayloadMode
+		}
+		c.payloadMode = payloadMode
+		return nil
+	}
+}
+
+// WithReshape renames and moves the fields of the published change events of the collection to be watched with the
+// given rules, applied in order, each of the form `<field> -> <field>` using the dot notation, e.g. `operationType -> op`,
+// or `<field> -> .` to lift the fields of a document to the root, e.g. `fullDocument -> .`, replacing the root fields
+// with the same name. Only the payload of the change events is reshaped: their subjects, headers, routes and filters
+// use the fields of MongoDB. If empty, change events are published with the layout of MongoDB.
+func WithReshape(rules ...string) CollectionOption {
+	return func(c *collection) error {
+		if len(rules) == 0 {
+			return nil
+		}
+		reshape, err := mongo.ParseReshape(rules)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidReshape, err)
+		}
+		c.reshape = reshape
+		return nil
+	}
+}
+
+// WithEnrichment enriches the full documents of the change events of the collection to be watched with the given
+// aggregation stages, each a `$lookup`, `$addFields`, `$set`, `$unset` or `$project` stage in extended json, run by
+// MongoDB before they are routed and published, e.g. to join the customer of an order with `$lookup`. Backfilled and
+// snapshotted documents are enriched as well. Requires MongoDB 5.1 or later. If empty, documents are not enriched.
+func WithEnrichment(stages ...string) CollectionOption {
+	return func(c *collection) error {
+		if len(stages) == 0 {
+			return nil
+		}
+		enrichment, err := mongo.ParseEnrichment(stages)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEnrichment, err)
+		}
+		c.enrichment = enrichment
+		return nil
+	}
+}
+
+// WithSchemaVersion prefixes the subjects of the change events of the collection to be watched with the given schema
+// version, e.g. v2.ORDERS.insert. It can be switched at runtime with a cutover, so that consumers can migrate from a
+// version to another. If zero, subjects are not prefixed.
+func WithSchemaVersion(version int) CollectionOption {
+	return func(c *collection) error {
+		if version < 0 {
+			return ErrInvalidSchemaVersion
+		}
+		c.schemaVersion = version
+		return nil
+	}
+}
+
+// Flatten tells how the nested documents of the change events are flattened into keys joining the keys of their fields.
+type Flatten struct {
+	// Separator joins the keys of the flattened fields, e.g. _ for the sinks whose columns cannot contain dots. If
+	// empty, they are joined with dots.
+	Separator string
+	// Arrays is one of keep, which keeps the arrays as values, or index, which flattens them into one key per element,
+	// suffixed by its index. If empty, they are kept.
+	Arrays string
+}
+
+// WithFlatten flattens the nested documents of the published change events of the collection to be watched with the
+// given flatten, e.g. {"fullDocument":{"total":42}} into {"fullDocument.total":42}, for the sinks loading them into
+// tables, such as ClickHouse or BigQuery loaders. The change events are flattened once reshaped, so only their payload
+// is affected.
+func WithFlatten(flatten *Flatten) CollectionOption {
+	return func(c *collection) error {
+		if flatten == nil {
+			return nil
+		}
+		f := &mongo.Flatten{Separator: flatten.Separator, Arrays: mongo.ArrayFlattening(flatten.Arrays)}
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidFlatten, err)
+		}
+		c.flatten = f
+		return nil
+	}
+}
+
+// TypeConversions tell how the BSON types without JSON equivalent are rendered in the change events encoded as JSON,
+// instead of their extended JSON wrappers. The types whose conversion is empty are rendered as relaxed extended JSON.
+type TypeConversions struct {
+	// ObjectId is one of extjson, e.g. {"$oid":"664f1c2e9b1e8a3d4c5b6a79"}, or hex, e.g. "664f1c2e9b1e8a3d4c5b6a79".
+	ObjectId string
+	// Date is one of extjson, e.g. {"$date":"2024-05-01T12:00:00.123Z"}, or rfc3339, e.g. "2024-05-01T12:00:00.123Z".
+	Date string
+	// Decimal is one of extjson, e.g. {"$numberDecimal":"12.50"}, string, e.g. "12.50", or number, e.g. 12.5, which may
+	// lose precision.
+	Decimal string
+	// Long is one of number, e.g. 9007199254740993, or string, e.g. "9007199254740993", for the consumers whose numbers
+	// are doubles.
+	Long string
+}
+
+// WithTypeConversions renders the ObjectIds, dates, decimals and 64-bit integers of the change events of the collection
+// to be watched with the given conversions, e.g. ObjectIds as plain hex strings, so that consumers unaware of extended
+// JSON do not have to unwrap them. Conversions only apply to the change events encoded as JSON, once reshaped.
+func WithTypeConversions(conversions *TypeConversions) CollectionOption {
+	return func(c *collection) error {
+		if conversions == nil {
+			return nil
+		}
+		typeConversions := &mongo.Conversions{
+			ObjectId: mongo.Conversion(conversions.ObjectId),
+			Date:     mongo.Conversion(conversions.Date),
+			Decimal:  mongo.Conversion(conversions.Decimal),
+			Long:     mongo.Conversion(conversions.Long),
+		}
+		if err := typeConversions.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTypeConversions, err)
+		}
+		c.typeConversions = typeConversions
+		return nil
+	}
+}
+
+// WithJsonFlavor sets the flavor of extended JSON the change events of the collection to be watched are encoded with,
+// if they are encoded as JSON. Can be set to 'relaxed', which renders numbers and dates natively when JSON can
+// represent them, 'canonical', which preserves the type of every value, e.g. for replication consumers decoding them
+// back to BSON, or 'simplified', which renders ObjectIds as hex strings, dates as RFC 3339 strings and decimals as
+// strings, e.g. for analytics consumers unaware of extended JSON. The type conversions of the collection take
+// precedence over the ones of the simplified flavor. The default is 'relaxed'.
+func WithJsonFlavor(flavor string) CollectionOption {
+	return func(c *collection) error {
+		if flavor == "" {
+			return nil
+		}
+		jsonFlavor := mongo.JsonFlavor(flavor)
+		if !slices.Contains(mongo.JsonFlavors, jsonFlavor) {
+			return ErrInvalidJsonFlavor
+		}
+		c.jsonFlavor = jsonFlavor
+		return nil
+	}
+}
+
+// Canary is a configuration whose output is published to a shadow subject for a percentage of the change events,
+// so that it can be validated against live traffic before cutover.
+type Canary struct {
+	// Percent is the percentage of the change events shadowed, sampled by document.
+	Percent float64
+	// Subject prefixes the subjects of the shadow change events, e.g. SHADOW.
+	Subject string
+	// Encoder encodes the shadow change events. If empty, the encoder of the collection is used.
+	Encoder string
+	// ExcludeFields are removed from the documents of the shadow change events, in addition to the excluded fields of
+	// the collection.
+	ExcludeFields []string
+}
+
+// WithCanary publishes, for the given percentage of the change events of the collection to be watched, their
+// encoding with the given configuration to the shadow subject, i.e. the canary subject followed by their subject, in
+// addition to publishing them as usual. Shadow change events are published to core NATS on a best-effort basis.
+func WithCanary(canary *Canary) CollectionOption {
+	return func(c *collection) error {
+		if canary == nil {
+			return nil
+		}
+		if canary.Percent < 0 || canary.Percent > 100 || mongo.ValidSubject(canary.Subject) != nil {
+			return ErrInvalidCanary
+		}
+		encoder := mongo.Encoder(canary.Encoder)
+		if encoder != "" && !slices.Contains(mongo.Encoders, encoder) {
+			return ErrInvalidCanary
+		}
+		if slices.Contains(canary.ExcludeFields, "") {
+			return ErrInvalidCanary
+		}
+		c.canary = &mongo.Canary{
+			Percent:       canary.Percent,
+			Subject:       canary.Subject,
+			Encoder:       encoder,
+			ExcludeFields: canary.ExcludeFields,
+		}
+		return nil
+	}
+}
+
+// schedule computes the activations of a snapshot schedule.
+type schedule interface {
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// snapshot holds the schedule a collection is re-snapshotted on, and the filter selecting the snapshotted documents.
+type snapshot struct {
+	schedule schedule
+	filter   string
+}
+
+// WithSnapshot re-snapshots the collection to be watched on the given cron schedule, evaluated in UTC, e.g. `0 3 * * *`,
+// for downstream systems that want periodic full refreshes in addition to change data capture. Each snapshot publishes
+// the documents matching the given filter, a query document in extended JSON, or all of them if it is empty, as
+// synthetic replace change events with the Connector-Backfill header, throttled by the rate limit of the collection.
+func WithSnapshot(schedule, filter string) CollectionOption {
+	return func(c *collection) error {
+		if schedule == "" && filter == "" {
+			return nil
+		}
+		s, err := cron.Parse(schedule)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if _, err = mongo.ParseBackfillFilter(filter); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		c.snapshot = &snapshot{schedule: s, filter: filter}
+		return nil
+	}
+}
+
+// WithTransactions sets how the change events of the multi-document transactions of the collection to be watched are
+// published, so that consumers can apply transactions atomically. Can be set to 'tag', which publishes them one by one
+// with the Connector-Txn-Id, Connector-Txn-Index and Connector-Txn-Total headers, or 'batch', which publishes them as a
+// single message with the Connector-Txn-Id and Connector-Txn-Total headers.
+func WithTransactions(mode string) CollectionOption {
+	return func(c *collection) error {
+		if mode == "" {
+			return nil
+		}
+		transactionMode := mongo.TransactionMode(mode)
+		if !slices.Contains(mongo.TransactionModes, transactionMode) {
+			return ErrInvalidTransactions
+		}
+		c.transactions = transactionMode
+		return nil
+	}
+}
+
+// WithExcludeFields removes the given fields from the documents of the change events of the collection to be watched.
+// The fields are removed by MongoDB, in the change stream pipeline, so they are never sent to the Connector.
+// Nested fields can be specified by using the dot notation.
+func WithExcludeFields(excludeFields ...string) CollectionOption {
+	return func(c *collection) error {
+		c.excludeFields = append(c.excludeFields, excludeFields...)
+		return nil
+	}
+}
+
+// WithCollation sets the collation of the change stream of the collection to be watched, so that strings are compared
+// according to the rules of the given locale (e.g. 'fr'). The given strength sets the level of comparison, from 1 to 5;
+// if zero, the MongoDB default is used.
+func WithCollation(locale string, strength int) CollectionOption {
+	return func(c *collection) error {
+		if locale == "" {
+			return ErrCollationLocaleMissing
+		}
+		if strength < 0 || strength > 5 {
+			return ErrInvalidCollation
+		}
+		c.collation = &mongo.Collation{Locale: locale, Strength: strength}
+		return nil
+	}
+}
+
+// WithSplitLargeEvents makes MongoDB split the change events of the collection to be watched exceeding the maximum
+// size of BSON documents, e.g. updates of large documents with their pre and post-images, into fragments, which are
+// reassembled before being published, instead of failing the change stream. It requires MongoDB 7.0 or later.
+func WithSplitLargeEvents() CollectionOption {
+	return func(c *collection) error {
+		c.splitLargeEvents = true
+		return nil
+	}
+}
+
+// WithGridFS makes the Connector publish file-level events, i.e. created, updated or deleted files with their
+// metadata, in place of the change events of the collection to be watched, which must be the files collection of a
+// GridFS bucket, e.g. fs.files, so that the chunks collection does not need to be watched. If the given object store
+// bucket is not empty, the content of the created files is copied to it, under the id of the file, and deleted along
+// with the file.
+func WithGridFS(objectBucket string) CollectionOption {
+	return func(c *collection) error {
+		c.gridFs = true
+		c.gridFsObjectBucket = objectBucket
+		return nil
+	}
+}
+
+// WithEncryptedPassthrough makes the Connector publish the change events of the collection to be watched holding
+// encrypted fields it does not decrypt, i.e. if auto-encryption is not configured, with their ciphertext as is, and
+// the Connector-Encrypted header, so that consumers can tell them apart.
+func WithEncryptedPassthrough() CollectionOption {
+	return func(c *collection) error {
+		c.encryptedPassthrough = true
+		return nil
+	}
+}
+
+// WithFollowRenames makes the Connector continue watching the collection to be watched under its new name once it is
+// renamed, instead of stopping its watcher.
+func WithFollowRenames() CollectionOption {
+	return func(c *collection) error {
+		c.followRenames = true
+		return nil
+	}
+}
+
+// WithStallTimeout sets the heartbeat window of the change stream of the collection to be watched.
+// If the change stream does not respond within this window, not even with an empty batch, it is considered stalled, and
+// it is recreated by resuming after the last stored resume token.
+func WithStallTimeout(stallTimeout time.Duration) CollectionOption {
+	return func(c *collection) error {
+		if stallTimeout > 0 {
+			c.stallTimeout = stallTimeout
+		}
+		return nil
+	}
+}
+
+// WithMaxEventAge sets the maximum age of the change events of the collection to be watched, based on their cluster
+// time. Older change events, e.g. replayed while catching up after a long outage, are skipped, and a gap marker is
+// published in their place.
+func WithMaxEventAge(maxEventAge time.Duration) CollectionOption {
+	return func(c *collection) error {
+		if maxEventAge > 0 {
+			c.maxEventAge = maxEventAge
+		}
+		return nil
+	}
+}
+
+// WithMsgIdStrategy sets the strategy used to compute the NATS message id of the change events of the collection to be
+// watched. Can be set to 'resumeToken', 'eventHash', or 'documentField'.
+func WithMsgIdStrategy(msgIdStrategy string) CollectionOption {
+	return func(c *collection) error {
+		if msgIdStrategy == "" {
+			return nil
+		}
+		strategy := mongo.MsgIdStrategy(msgIdStrategy)
+		if !slices.Contains(mongo.MsgIdStrategies, strategy) {
+			return ErrInvalidMsgIdStrategy
+		}
+		c.msgIdStrategy = strategy
+		return nil
+	}
+}
+
+// WithMsgIdField sets the field of the full document used as NATS message id, if the message id strategy is
+// 'documentField'. Nested fields can be specified by using the dot notation.
+func WithMsgIdField(msgIdField string) CollectionOption {
+	return func(c *collection) error {
+		if msgIdField != "" {
+			c.msgIdField = msgIdField
+		}
+		return nil
+	}
+}
+
+// WithOversizedPolicy sets what is done with the change events of the collection to be watched whose encoded payload
+// exceeds the maximum payload of NATS. Can be set to 'fail', 'truncate', 'drop', 'dlq', or 'offload'.
+func WithOversizedPolicy(oversizedPolicy string) CollectionOption {
+	return func(c *collection) error {
+		if oversizedPolicy == "" {
+			return nil
+		}
+		policy := mongo.OversizedPolicy(oversizedPolicy)
+		if !slices.Contains(mongo.OversizedPolicies, policy) {
+			return ErrInvalidOversizedPolicy
+		}
+		c.oversizedPolicy = policy
+		return nil
+	}
+}
+
+// WithDecodeErrorPolicy sets what is done with the change events of the collection to be watched that cannot be
+// encoded. Can be set to 'halt', 'skip', or 'dlq'.
+func WithDecodeErrorPolicy(decodeErrorPolicy string) CollectionOption {
+	return func(c *collection) error {
+		if decodeErrorPolicy == "" {
+			return nil
+		}
+		policy := mongo.DecodeErrorPolicy(decodeErrorPolicy)
+		if !slices.Contains(mongo.DecodeErrorPolicies, policy) {
+			return ErrInvalidDecodeErrorPolicy
+		}
+		c.decodeErrorPolicy = policy
+		return nil
+	}
+}
+
+// WithMissingImages requires the pre-image and the post-image of the updates and replacements, and the pre-image of
+// the deletes, of the collection to be watched, which must have changeStreamPreAndPostImages enabled, and sets what is
+// done with the change events whose images the server cannot supply, e.g. since they expired. Can be set to 'fail',
+// which requires them from the server, failing the watcher, 'partial', which publishes the change events without them,
+// with the Connector-Partial header, or 'dlq', which publishes their raw BSON to the dead letter subject. By default,
+// the images are not required, and the post-image of updates is looked up.
+func WithMissingImages(missingImages string) CollectionOption {
+	return func(c *collection) error {
+		if missingImages == "" {
+			return nil
+		}
+		policy := mongo.MissingImagesPolicy(missingImages)
+		if !slices.Contains(mongo.MissingImagesPolicies, policy) {
+			return ErrInvalidMissingImages
+		}
+		c.missingImages = policy
+		return nil
+	}
+}
+
+// WithNackPolicy sets what is done with the change events of the collection to be watched that are rejected by
+// JetStream with a negative ack. Can be set to 'fail', or 'dlq'.
+func WithNackPolicy(nackPolicy string) CollectionOption {
+	return func(c *collection) error {
+		if nackPolicy == "" {
+			return nil
+		}
+		policy := nats.NackPolicy(nackPolicy)
+		if !slices.Contains(nats.NackPolicies, policy) {
+			return ErrInvalidNackPolicy
+		}
+		c.nackPolicy = policy
+		return nil
+	}
+}
+
+// WithErrorPolicies maps the classes of errors of the collection to be watched to the actions taken once they occur,
+// e.g. so that a collection of payments halts on any error, while a collection of telemetry skips the change events
+// that fail. The classes are 'decode', 'transform', 'publishTimeout', 'tokenSave' and 'unknownTenant', and the actions
+// 'retry', 'skip', 'dlq' and 'halt', not all of them being supported by every class. The decode action overrides the decode error
+// policy, while the transform errors which are not mapped are handled as decode errors.
+func WithErrorPolicies(policies map[string]string) CollectionOption {
+	return func(c *collection) error {
+		if len(policies) == 0 {
+			return nil
+		}
+		p := make(mongo.ErrorPolicies, len(policies))
+		for class, action := range policies {
+			p[mongo.ErrorClass(class)] = mongo.ErrorAction(action)
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidErrorPolicies, err)
+		}
+		c.errorPolicies = p
+		return nil
+	}
+}
+
+// WithFailureMode sets what is done once the watcher of the collection to be watched fails. Can be set to 'stopAll',
+// which shuts down the Connector, or 'isolate', which only fails the collection.
+func WithFailureMode(mode string) CollectionOption {
+	return func(c *collection) error {
+		if mode == "" {
+			return nil
+		}
+		if !slices.Contains(failureModes, failureMode(mode)) {
+			return ErrInvalidFailureMode
+		}
+		c.failureMode = failureMode(mode)
+		return nil
+	}
+}
+
+// WithDlqSubject sets the subject where a reference to each oversized change event is published, if the oversized
+// policy is 'dlq', and where the raw BSON of each change event that cannot be encoded is published, if the decode
+// error policy is 'dlq'.
+func WithDlqSubject(dlqSubject string) CollectionOption {
+	return func(c *collection) error {
+		if dlqSubject != "" {
+			c.dlqSubject = dlqSubject
+		}
+		return nil
+	}
+}
+
+// WithOffloadBucket sets the NATS object store bucket where oversized change events are stored, if the oversized
+// policy is 'offload'.
+func WithOffloadBucket(offloadBucket string) CollectionOption {
+	return func(c *collection) error {
+		if offloadBucket != "" {
+			c.offloadBucket = offloadBucket
+		}
+		return nil
+	}
+}
+
+// WithQuarantine sets the collection of the resume tokens database where the change events of the collection to be
+// watched which cannot be published, even after being retried, are stored along with their error and position, instead
+// of stopping its watcher, so that they can be redriven once the underlying issue is fixed.
+func WithQuarantine(quarantineCollName string) CollectionOption {
+	return func(c *collection) error {
+		if quarantineCollName != "" {
+			c.quarantineCollName = quarantineCollName
+		}
+		return nil
+	}
+}
+
+// WithDuplicatesWindow sets the window used by the NATS stream of the collection to be watched to discard duplicate
+// messages. If not set, the NATS server default is used.
+func WithDuplicatesWindow(duplicatesWindow time.Duration) CollectionOption {
+	return func(c *collection) error {
+		if duplicatesWindow > 0 {
+			c.duplicatesWindow = duplicatesWindow
+		}
+		return nil
+	}
+}
+
+// StreamLimits bound the messages kept by the NATS streams of a collection, the oldest ones being discarded once a
+// limit is reached. Limits left to zero are not set, so that the server defaults apply.
+type StreamLimits struct {
+	// MaxMsgsPerSubject is the number of messages kept per subject, e.g. 1 for the subjects holding the id of their
+	// document, so that the stream behaves like a compacted log keeping the last change event of each document.
+	MaxMsgsPerSubject int64
+	// MaxMsgs is the number of messages kept by the stream.
+	MaxMsgs int64
+	// MaxBytes is the size of the messages kept by the stream.
+	MaxBytes int64
+	// MaxAge is the age of the oldest message kept by the stream.
+	MaxAge time.Duration
+}
+
+// WithStreamLimits sets the given limits on the NATS streams of the collection to be watched, i.e. its stream and the
+// streams its change events are routed to, when they are added, and updates the existing streams whose limits differ.
+func WithStreamLimits(limits *StreamLimits) CollectionOption {
+	return func(c *collection) error {
+		if limits == nil {
+			return nil
+		}
+		if limits.MaxMsgsPerSubject < 0 || limits.MaxMsgs < 0 || limits.MaxBytes < 0 || limits.MaxAge < 0 {
+			return ErrInvalidStreamLimits
+		}
+		c.streamLimits = &nats.StreamLimits{
+			MaxMsgsPerSubject: limits.MaxMsgsPerSubject,
+			MaxMsgs:           limits.MaxMsgs,
+			MaxBytes:          limits.MaxBytes,
+			MaxAge:            limits.MaxAge,
+		}
+		return nil
+	}
+}
+
+// WithPublishMode sets how the change events of the collection to be watched are published to NATS.
+// Can be set to 'jetstream', or 'core' for fire-and-forget publishing to plain NATS subjects, where no stream is
+// created and messages may be lost, but latency is lower.
+func WithPublishMode(publishMode string) CollectionOption {
+	return func(c *collection) error {
+		if publishMode == "" {
+			return nil
+		}
+		mode := nats.PublishMode(publishMode)
+		if !slices.Contains(nats.PublishModes, mode) {
+			return ErrInvalidPublishMode
+		}
+		c.publishMode = mode
+		return nil
+	}
+}
+
+// WithExpectStream makes the JetStream publishes of the change events of the collection to be watched fail if their
+// subjects are not bound to the configured stream.
+func WithExpectStream() CollectionOption {
+	return func(c *collection) error {
+		c.expectStream = true
+		return nil
+	}
+}
+
+// WithStrictOrdering makes the watcher of the collection to be watched fail once a change event is observed or
+// published before a change event with a later cluster time, e.g. because of concurrent publish workers, instead of
+// only counting it.
+func WithStrictOrdering() CollectionOption {
+	return func(c *collection) error {
+		c.strictOrdering = true
+		return nil
+	}
+}
+
+// WithIdempotentResume makes the collection to be watched skip, once it resumes, the change events already published
+// after its last stored resume token, e.g. before a crash, even if they fall outside the duplicate window of their
+// stream. The last message published to each subject is persisted along with the resume tokens, so that the messages
+// stored after them can be fetched from their stream, and their message ids compared with the ones of the resumed
+// change events.
+func WithIdempotentResume() CollectionOption {
+	return func(c *collection) error {
+		c.idempotentResume = true
+		return nil
+	}
+}
+
+// WithAckTimeout sets the maximum amount of time to wait for the JetStream ack of each change event published for the
+// collection to be watched.
+func WithAckTimeout(ackTimeout time.Duration) CollectionOption {
+	return func(c *collection) error {
+		if ackTimeout > 0 {
+			c.ackTimeout = ackTimeout
+		}
+		return nil
+	}
+}
+
+// WithRetries sets the number of retries, and the amount of time between retries, performed when no JetStream stream
+// is available to acknowledge the change events published for the collection to be watched.
+func WithRetries(retryAttempts int, retryWait time.Duration) CollectionOption {
+	return func(c *collection) error {
+		if retryAttempts > 0 {
+			c.retryAttempts = retryAttempts
+		}
+		if retryWait > 0 {
+			c.retryWait = retryWait
+		}
+		return nil
+	}
+}
+
+// WithMsgTtl sets the time to live of each change event published for the collection to be watched.
+// Per-message TTLs are allowed on the streams of the collection, which requires a NATS server supporting them.
+func WithMsgTtl(msgTtl time.Duration) CollectionOption {
+	return func(c *collection) error {
+		if msgTtl > 0 {
+			c.msgTtl = msgTtl
+		}
+		return nil
+	}
+}
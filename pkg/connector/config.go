@@ -0,0 +1,645 @@
+package connector
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveParamRegexp matches the names of the URI query parameters holding credentials.
+var sensitiveParamRegexp = regexp.MustCompile(`(?i)password|secret|token|credential|authMechanismProperties`)
+
+// effectiveConfig is the configuration the Connector is running with, once defaults and inherited settings are
+// applied, where credentials are redacted.
+type effectiveConfig struct {
+	LogLevel             string                             `json:"logLevel"`
+	LogSampling          *effectiveLogSampling              `json:"logSampling,omitempty"`
+	LogMetadataOnly      bool                               `json:"logMetadataOnly,omitempty"`
+	MongoUri             string                             `json:"mongoUri,omitempty"`
+	MongoAutoEncryption  *effectiveEncryption               `json:"mongoAutoEncryption,omitempty"`
+	NatsUrl              string                             `json:"natsUrl,omitempty"`
+	NatsCredsFile        string                             `json:"natsCredsFile,omitempty"`
+	NatsCertFile         string                             `json:"natsCertFile,omitempty"`
+	NatsKeyFile          string                             `json:"natsKeyFile,omitempty"`
+	NatsCaFile           string                             `json:"natsCaFile,omitempty"`
+	Instance             effectiveInstance                  `json:"instance"`
+	Tenants              []effectiveTenant                  `json:"tenants,omitempty"`
+	ServerAddr           string                             `json:"serverAddr,omitempty"`
+	ServerAdminAddr      string                             `json:"serverAdminAddr,omitempty"`
+	ServerGrpcAddr       string                             `json:"serverGrpcAddr,omitempty"`
+	ServerLimits         *effectiveServerLimits             `json:"serverLimits,omitempty"`
+	ServerAuth           *effectiveServerAuth               `json:"serverAuth,omitempty"`
+	HealthDeadlines      map[string]effectiveHealthDeadline `json:"healthDeadlines,omitempty"`
+	ShutdownTimeout      string                             `json:"shutdownTimeout"`
+	MaxRestartTime       string                             `json:"maxRestartTime"`
+	JournalSize          int                                `json:"journalSize"`
+	SchemaChangesStream  string                             `json:"schemaChangesStream,omitempty"`
+	SchemaVersionsBucket string                             `json:"schemaVersionsBucket,omitempty"`
+	WatcherStatesBucket  string                             `json:"watcherStatesBucket,omitempty"`
+	Watermark            *effectiveWatermark                `json:"watermark,omitempty"`
+	Events               *effectiveEvents                   `json:"events,omitempty"`
+	Control              *effectiveControl                  `json:"control,omitempty"`
+	Metrics              effectiveMetrics                   `json:"metrics"`
+	Status               *effectiveStatus                   `json:"status,omitempty"`
+	TokenBackup          *effectiveTokenBackup              `json:"tokenBackup,omitempty"`
+	PublisherGuard       *effectivePublisherGuard           `json:"publisherGuard,omitempty"`
+	OplogWarning         effectiveOplogWarning              `json:"oplogWarning"`
+	Retry                *effectiveRetry                    `json:"retry,omitempty"`
+	Webhooks             []effectiveWebhook                 `json:"webhooks,omitempty"`
+	Pipeline             effectivePipeline                  `json:"pipeline"`
+	Collections          []effectiveCollection              `json:"collections"`
+}
+
+// effectiveServerLimits holds the limits of the HTTP server that are set, the other ones are the defaults of the server.
+type effectiveServerLimits struct {
+	ReadTimeout     string `json:"readTimeout,omitempty"`
+	WriteTimeout    string `json:"writeTimeout,omitempty"`
+	IdleTimeout     string `json:"idleTimeout,omitempty"`
+	MaxHeaderBytes  int    `json:"maxHeaderBytes,omitempty"`
+	ShutdownTimeout string `json:"shutdownTimeout,omitempty"`
+}
+
+// effectiveServerAuth holds the authentication of the requests to the HTTP server, without the bearer tokens.
+type effectiveServerAuth struct {
+	BearerTokensFile string `json:"bearerTokensFile,omitempty"`
+	OidcIssuer       string `json:"oidcIssuer,omitempty"`
+	OidcAudience     string `json:"oidcAudience,omitempty"`
+}
+
+// effectiveHealthDeadline holds the bounds of the pings of a kind of monitored component that are set.
+type effectiveHealthDeadline struct {
+	Timeout         string `json:"timeout,omitempty"`
+	DegradedLatency string `json:"degradedLatency,omitempty"`
+}
+
+type effectiveLogSampling struct {
+	First      int    `json:"first"`
+	Thereafter int    `json:"thereafter"`
+	Interval   string `json:"interval"`
+}
+
+type effectiveWatermark struct {
+	Subject  string `json:"subject"`
+	Interval string `json:"interval"`
+}
+
+type effectiveEvents struct {
+	SubjectPrefix string `json:"subjectPrefix"`
+	TokenInterval string `json:"tokenInterval"`
+}
+
+type effectiveControl struct {
+	Subject string `json:"subject"`
+	// Commands are the names of the commands handled, the ones other than pause and resume being handled by the host.
+	Commands             []string `json:"commands"`
+	AllowUnauthenticated bool     `json:"allowUnauthenticated"`
+}
+
+type effectiveMetrics struct {
+	Exporter    string                `json:"exporter"`
+	Statsd      *effectiveStatsd      `json:"statsd,omitempty"`
+	Pushgateway *effectivePushgateway `json:"pushgateway,omitempty"`
+}
+
+type effectiveStatsd struct {
+	Addr     string `json:"addr"`
+	Prefix   string `json:"prefix,omitempty"`
+	Interval string `json:"interval"`
+}
+
+type effectivePushgateway struct {
+	Url string `json:"url"`
+	Job string `json:"job"`
+}
+
+type effectiveTokenBackup struct {
+	Bucket         string `json:"bucket,omitempty"`
+	Path           string `json:"path,omitempty"`
+	Interval       string `json:"interval"`
+	ReplicaNatsUrl string `json:"replicaNatsUrl,omitempty"`
+}
+
+type effectivePublisherGuard struct {
+	LeaseBucket string `json:"leaseBucket"`
+	Interval    string `json:"interval"`
+}
+
+type effectiveStatus struct {
+	Bucket   string `json:"bucket"`
+	Interval string `json:"interval"`
+}
+
+type effectiveOplogWarning struct {
+	Headroom   string `json:"headroom"`
+	WebhookUrl string `json:"webhookUrl,omitempty"`
+}
+
+// effectiveRetry holds the retry settings that are set, the other ones are the defaults of each retried operation.
+type effectiveRetry struct {
+	InitialInterval string   `json:"initialInterval,omitempty"`
+	MaxInterval     string   `json:"maxInterval,omitempty"`
+	Multiplier      float64  `json:"multiplier,omitempty"`
+	MaxElapsedTime  string   `json:"maxElapsedTime,omitempty"`
+	MaxAttempts     int      `json:"maxAttempts,omitempty"`
+	Jitter          string   `json:"jitter,omitempty"`
+	RetryOn         []string `json:"retryOn,omitempty"`
+}
+
+type effectiveWebhook struct {
+	Url    string   `json:"url"`
+	Format string   `json:"format"`
+	Alerts []string `json:"alerts,omitempty"`
+}
+
+// effectiveEncryption holds the names of the KMS providers, but not their credentials.
+type effectiveEncryption struct {
+	KeyVaultNamespace string   `json:"keyVaultNamespace"`
+	KmsProviders      []string `json:"kmsProviders"`
+}
+
+type effectiveInstance struct {
+	Id     string            `json:"id"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type effectiveTenant struct {
+	Name    string `json:"name"`
+	NatsUrl string `json:"natsUrl"`
+}
+
+type effectiveCollection struct {
+	DbName                       string                    `json:"dbName"`
+	CollName                     string                    `json:"collName"`
+	ChangeStreamPreAndPostImages bool                      `json:"changeStreamPreAndPostImages"`
+	TokensDbName                 string                    `json:"tokensDbName"`
+	TokensCollName               string                    `json:"tokensCollName"`
+	TokensCollCapped             bool                      `json:"tokensCollCapped"`
+	TokensCollSizeInBytes        int64                     `json:"tokensCollSizeInBytes,omitempty"`
+	TokensCollTtl                string                    `json:"tokensCollTtl,omitempty"`
+	StreamName                   string                    `json:"streamName"`
+	NamespaceSubjects            bool                      `json:"namespaceSubjects"`
+	Partitions                   int                       `json:"partitions,omitempty"`
+	TimeBucket                   string                    `json:"timeBucket,omitempty"`
+	SubjectTemplate              string                    `json:"subjectTemplate,omitempty"`
+	HeaderTemplates              map[string]string         `json:"headerTemplates,omitempty"`
+	Routes                       []effectiveRoute          `json:"routes,omitempty"`
+	Filter                       *effectiveFilter          `json:"filter,omitempty"`
+	EventTypes                   map[string]string         `json:"eventTypes,omitempty"`
+	Enrich                       []string                  `json:"enrich,omitempty"`
+	Patch                        string                    `json:"patch,omitempty"`
+	Envelope                     *effectiveEnvelope        `json:"envelope,omitempty"`
+	PayloadMode                  string                    `json:"payloadMode"`
+	Reshape                      []string                  `json:"reshape,omitempty"`
+	JsonFlavor                   string                    `json:"jsonFlavor"`
+	Flatten                      *effectiveFlatten         `json:"flatten,omitempty"`
+	TypeConversions              *effectiveTypeConversions `json:"typeConversions,omitempty"`
+	Canary                       *effectiveCanary          `json:"canary,omitempty"`
+	Snapshot                     *effectiveSnapshot        `json:"snapshot,omitempty"`
+	Transactions                 string                    `json:"transactions,omitempty"`
+	SchemaVersion                int                       `json:"schemaVersion,omitempty"`
+	TenantField                  string                    `json:"tenantField,omitempty"`
+	TenantDbName                 bool                      `json:"tenantDbName"`
+	ExcludeFields                []string                  `json:"excludeFields,omitempty"`
+	Collation                    *effectiveCollation       `json:"collation,omitempty"`
+	SplitLargeEvents             bool                      `json:"splitLargeEvents"`
+	EncryptedPassthrough         bool                      `json:"encryptedPassthrough"`
+	GridFS                       bool                      `json:"gridFs"`
+	GridFSObjectBucket           string                    `json:"gridFsObjectBucket,omitempty"`
+	FollowRenames                bool                      `json:"followRenames"`
+	StallTimeout                 string                    `json:"stallTimeout"`
+	MaxEventAge                  string                    `json:"maxEventAge,omitempty"`
+	MsgIdStrategy                string                    `json:"msgIdStrategy"`
+	MsgIdField                   string                    `json:"msgIdField,omitempty"`
+	OversizedPolicy              string                    `json:"oversizedPolicy"`
+	DlqSubject                   string                    `json:"dlqSubject,omitempty"`
+	OffloadBucket                string                    `json:"offloadBucket,omitempty"`
+	QuarantineCollName           string                    `json:"quarantineCollName,omitempty"`
+	DecodeErrorPolicy            string                    `json:"decodeErrorPolicy"`
+	MissingImages                string                    `json:"missingImages,omitempty"`
+	NackPolicy                   string                    `json:"nackPolicy"`
+	ErrorPolicies                map[string]string         `json:"errorPolicies,omitempty"`
+	FailureMode                  string                    `json:"failureMode"`
+	DuplicatesWindow             string                    `json:"duplicatesWindow,omitempty"`
+	StreamLimits                 *effectiveStreamLimits    `json:"streamLimits,omitempty"`
+	PublishMode                  string                    `json:"publishMode"`
+	ExpectStream                 bool                      `json:"expectStream"`
+	StrictOrdering               bool                      `json:"strictOrdering"`
+	IdempotentResume             bool                      `json:"idempotentResume"`
+	AckTimeout                   string                    `json:"ackTimeout,omitempty"`
+	RetryAttempts                int                       `json:"retryAttempts,omitempty"`
+	RetryWait                    string                    `json:"retryWait,omitempty"`
+	MsgTtl                       string                    `json:"msgTtl,omitempty"`
+	Pipeline                     effectivePipeline         `json:"pipeline"`
+}
+
+type effectiveRoute struct {
+	When            map[string]string `json:"when"`
+	StreamName      string            `json:"streamName"`
+	SubjectTemplate string            `json:"subjectTemplate,omitempty"`
+}
+
+type effectiveFilter struct {
+	Field    string            `json:"field,omitempty"`
+	Operator string            `json:"op,omitempty"`
+	Value    string            `json:"value,omitempty"`
+	Values   []string          `json:"values,omitempty"`
+	And      []effectiveFilter `json:"and,omitempty"`
+	Or       []effectiveFilter `json:"or,omitempty"`
+}
+
+func newEffectiveFilter(f *mongo.Filter) *effectiveFilter {
+	filter := &effectiveFilter{Field: f.Field, Operator: string(f.Operator), Value: f.Value, Values: f.Values}
+	for i := range f.And {
+		filter.And = append(filter.And, *newEffectiveFilter(&f.And[i]))
+	}
+	for i := range f.Or {
+		filter.Or = append(filter.Or, *newEffectiveFilter(&f.Or[i]))
+	}
+	return filter
+}
+
+type effectiveEnvelope struct {
+	MissingBefore string `json:"missingBefore,omitempty"`
+}
+
+type effectiveFlatten struct {
+	Separator string `json:"separator,omitempty"`
+	Arrays    string `json:"arrays,omitempty"`
+}
+
+type effectiveStreamLimits struct {
+	MaxMsgsPerSubject int64  `json:"maxMsgsPerSubject,omitempty"`
+	MaxMsgs           int64  `json:"maxMsgs,omitempty"`
+	MaxBytes          int64  `json:"maxBytes,omitempty"`
+	MaxAge            string `json:"maxAge,omitempty"`
+}
+
+type effectiveTypeConversions struct {
+	ObjectId string `json:"objectId,omitempty"`
+	Date     string `json:"date,omitempty"`
+	Decimal  string `json:"decimal,omitempty"`
+	Long     string `json:"long,omitempty"`
+}
+
+type effectiveCanary struct {
+	Percent       float64  `json:"percent"`
+	Subject       string   `json:"subject"`
+	Encoder       string   `json:"encoder,omitempty"`
+	ExcludeFields []string `json:"excludeFields,omitempty"`
+}
+
+type effectiveSnapshot struct {
+	Schedule string `json:"schedule"`
+	Filter   string `json:"filter,omitempty"`
+}
+
+type effectiveCollation struct {
+	Locale   string `json:"locale"`
+	Strength int    `json:"strength,omitempty"`
+}
+
+type effectivePipeline struct {
+	PublishWorkers int     `json:"publishWorkers"`
+	BatchSize      int32   `json:"batchSize,omitempty"`
+	RateLimit      float64 `json:"rateLimit,omitempty"`
+	Encoder        string  `json:"encoder"`
+}
+
+// effectiveConfig returns the configuration the Connector is running with, where credentials are redacted.
+func (c *Connector) effectiveConfig() *effectiveConfig {
+	cfg := &effectiveConfig{
+		LogLevel:             strings.ToLower(c.options.logLevel.String()),
+		LogMetadataOnly:      c.options.logMetadataOnly,
+		MongoUri:             redactUri(c.options.mongoUri),
+		NatsUrl:              redactUri(c.options.natsUrl),
+		NatsCredsFile:        c.options.natsCredsFile,
+		NatsCertFile:         c.options.natsCertFile,
+		NatsKeyFile:          c.options.natsKeyFile,
+		NatsCaFile:           c.options.natsCaFile,
+		Instance:             effectiveInstance{Id: c.options.instanceId, Labels: c.options.labels},
+		ServerAddr:           c.options.serverAddr,
+		ServerAdminAddr:      c.options.serverAdminAddr,
+		ServerGrpcAddr:       c.options.serverGrpcAddr,
+		ShutdownTimeout:      c.options.shutdownTimeout.String(),
+		MaxRestartTime:       c.options.maxRestartTime.String(),
+		JournalSize:          c.options.journalSize,
+		SchemaChangesStream:  c.options.schemaChangesStream,
+		SchemaVersionsBucket: c.options.schemaVersionsBucket,
+		WatcherStatesBucket:  c.options.watcherStatesBucket,
+		OplogWarning: effectiveOplogWarning{Headroom: c.options.oplogWarningHeadroom.String(),
+			WebhookUrl: redactWebhookUrl(c.options.oplogWarningWebhook)},
+		Pipeline:    c.options.pipeline.effective(),
+		Collections: make([]effectiveCollection, 0, len(c.options.collections)),
+	}
+	if c.options.mongoKeyVaultNamespace != "" {
+		providers := make([]string, 0, len(c.options.mongoKmsProviders))
+		for name := range c.options.mongoKmsProviders {
+			providers = append(providers, name)
+		}
+		slices.Sort(providers)
+		cfg.MongoAutoEncryption = &effectiveEncryption{KeyVaultNamespace: c.options.mongoKeyVaultNamespace,
+			KmsProviders: providers}
+	}
+	limits := effectiveServerLimits{MaxHeaderBytes: c.options.serverMaxHeaderBytes}
+	if c.options.serverReadTimeout > 0 {
+		limits.ReadTimeout = c.options.serverReadTimeout.String()
+	}
+	if c.options.serverWriteTimeout > 0 {
+		limits.WriteTimeout = c.options.serverWriteTimeout.String()
+	}
+	if c.options.serverIdleTimeout > 0 {
+		limits.IdleTimeout = c.options.serverIdleTimeout.String()
+	}
+	if c.options.serverShutdownTimeout > 0 {
+		limits.ShutdownTimeout = c.options.serverShutdownTimeout.String()
+	}
+	if limits != (effectiveServerLimits{}) {
+		cfg.ServerLimits = &limits
+	}
+	if len(c.options.serverAuthenticators) > 0 {
+		cfg.ServerAuth = &effectiveServerAuth{BearerTokensFile: c.options.serverBearerTokensFile,
+			OidcIssuer: c.options.serverOidcIssuer, OidcAudience: c.options.serverOidcAudience}
+	}
+	for component, deadline := range c.options.healthDeadlines {
+		if cfg.HealthDeadlines == nil {
+			cfg.HealthDeadlines = make(map[string]effectiveHealthDeadline, len(c.options.healthDeadlines))
+		}
+		var effective effectiveHealthDeadline
+		if deadline.Timeout > 0 {
+			effective.Timeout = deadline.Timeout.String()
+		}
+		if deadline.DegradedLatency > 0 {
+			effective.DegradedLatency = deadline.DegradedLatency.String()
+		}
+		cfg.HealthDeadlines[component] = effective
+	}
+	if p := c.options.retryPolicy; p != nil {
+		cfg.Retry = &effectiveRetry{Multiplier: p.Multiplier, MaxAttempts: p.MaxAttempts, Jitter: string(p.Jitter)}
+		if p.InitialInterval > 0 {
+			cfg.Retry.InitialInterval = p.InitialInterval.String()
+		}
+		if p.MaxInterval > 0 {
+			cfg.Retry.MaxInterval = p.MaxInterval.String()
+		}
+		if p.MaxElapsedTime > 0 {
+			cfg.Retry.MaxElapsedTime = p.MaxElapsedTime.String()
+		}
+		for _, class := range p.RetryOn {
+			cfg.Retry.RetryOn = append(cfg.Retry.RetryOn, string(class))
+		}
+	}
+	if c.options.logSampling {
+		cfg.LogSampling = &effectiveLogSampling{First: c.options.logSamplingFirst,
+			Thereafter: c.options.logSamplingThereafter, Interval: c.options.logSamplingInterval.String()}
+	}
+	if c.options.watermarkSubject != "" {
+		cfg.Watermark = &effectiveWatermark{Subject: c.options.watermarkSubject,
+			Interval: c.options.watermarkInterval.String()}
+	}
+	if c.options.eventsSubjectPrefix != "" {
+		cfg.Events = &effectiveEvents{SubjectPrefix: c.options.eventsSubjectPrefix,
+			TokenInterval: c.options.eventsTokenInterval.String()}
+	}
+	if c.options.controlSubject != "" {
+		handled := make([]string, 0, len(c.options.controlHandlers))
+		for name := range c.options.controlHandlers {
+			handled = append(handled, name)
+		}
+		slices.Sort(handled)
+		commands := append([]string{PauseCommand, ResumeCommand}, handled...)
+		cfg.Control = &effectiveControl{Subject: c.options.controlSubject, Commands: commands,
+			AllowUnauthenticated: c.options.controlUnauthenticated}
+	}
+	cfg.Metrics = effectiveMetrics{Exporter: string(c.options.metricsExporter)}
+	if c.options.metricsExporter == statsdMetricsExporter {
+		cfg.Metrics.Statsd = &effectiveStatsd{Addr: c.options.statsdAddr, Prefix: c.options.statsdPrefix,
+			Interval: c.options.statsdInterval.String()}
+	}
+	if c.options.pushgatewayUrl != "" {
+		cfg.Metrics.Pushgateway = &effectivePushgateway{Url: redactUri(c.options.pushgatewayUrl),
+			Job: c.options.pushgatewayJob}
+	}
+	if c.options.statusBucket != "" {
+		cfg.Status = &effectiveStatus{Bucket: c.options.statusBucket, Interval: c.options.statusInterval.String()}
+	}
+	if c.tokenBackupEnabled() {
+		cfg.TokenBackup = &effectiveTokenBackup{Bucket: c.options.tokenBackupBucket, Path: c.options.tokenBackupPath,
+			Interval: c.options.tokenBackupInterval.String(), ReplicaNatsUrl: redactUri(c.options.tokenBackupReplicaUrl)}
+	}
+	if c.options.publisherLeaseBucket != "" {
+		cfg.PublisherGuard = &effectivePublisherGuard{LeaseBucket: c.options.publisherLeaseBucket,
+			Interval: c.options.publisherGuardInterval.String()}
+	}
+	for _, w := range c.options.webhooks {
+		webhook := effectiveWebhook{Url: redactWebhookUrl(w.Url), Format: string(w.Format)}
+		for _, alert := range w.Alerts {
+			webhook.Alerts = append(webhook.Alerts, string(alert))
+		}
+		cfg.Webhooks = append(cfg.Webhooks, webhook)
+	}
+	for _, name := range c.tenantNames() {
+		cfg.Tenants = append(cfg.Tenants, effectiveTenant{Name: name,
+			NatsUrl: redactUri(c.options.tenants[name].natsUrl)})
+	}
+	for _, coll := range c.options.collections {
+		cfg.Collections = append(cfg.Collections, coll.effective())
+	}
+	return cfg
+}
+
+func (c *collection) effective() effectiveCollection {
+	coll := effectiveCollection{
+		DbName:                       c.dbName,
+		CollName:                     c.collName,
+		ChangeStreamPreAndPostImages: c.changeStreamPreAndPostImages,
+		TokensDbName:                 c.tokensDbName,
+		TokensCollName:               c.tokensCollName,
+		TokensCollCapped:             c.tokensCollCapped,
+		TokensCollSizeInBytes:        c.tokensCollSizeInBytes,
+		StreamName:                   c.streamName,
+		NamespaceSubjects:            c.namespaceSubjects,
+		Partitions:                   c.partitions,
+		TimeBucket:                   string(c.timeBucket),
+		TenantField:                  c.tenantField,
+		TenantDbName:                 c.tenantDbName,
+		ExcludeFields:                c.excludeFields,
+		SplitLargeEvents:             c.splitLargeEvents,
+		EncryptedPassthrough:         c.encryptedPassthrough,
+		SchemaVersion:                c.schemaVersion,
+		Transactions:                 string(c.transactions),
+		GridFS:                       c.gridFs,
+		GridFSObjectBucket:           c.gridFsObjectBucket,
+		FollowRenames:                c.followRenames,
+		StallTimeout:                 c.stallTimeout.String(),
+		MsgIdStrategy:                string(c.msgIdStrategy),
+		MsgIdField:                   c.msgIdField,
+		OversizedPolicy:              string(c.oversizedPolicy),
+		Patch:                        string(c.patch),
+		PayloadMode:                  string(c.payloadMode),
+		JsonFlavor:                   string(c.jsonFlavor),
+		DlqSubject:                   c.dlqSubject,
+		OffloadBucket:                c.offloadBucket,
+		QuarantineCollName:           c.quarantineCollName,
+		DecodeErrorPolicy:            string(c.decodeErrorPolicy),
+		MissingImages:                string(c.missingImages),
+		NackPolicy:                   string(c.nackPolicy),
+		FailureMode:                  string(c.failureMode),
+		PublishMode:                  string(c.publishMode),
+		ExpectStream:                 c.expectStream,
+		StrictOrdering:               c.strictOrdering,
+		IdempotentResume:             c.idempotentResume,
+		RetryAttempts:                c.retryAttempts,
+		Pipeline:                     c.pipeline.effective(),
+	}
+	if c.subjectTemplate != nil {
+		coll.SubjectTemplate = c.subjectTemplate.String()
+	}
+	for name, tmpl := range c.headerTemplates {
+		if coll.HeaderTemplates == nil {
+			coll.HeaderTemplates = make(map[string]string, len(c.headerTemplates))
+		}
+		coll.HeaderTemplates[name] = tmpl.String()
+	}
+	for _, route := range c.routes {
+		r := effectiveRoute{When: route.When, StreamName: route.StreamName}
+		if route.SubjectTemplate != nil {
+			r.SubjectTemplate = route.SubjectTemplate.String()
+		}
+		coll.Routes = append(coll.Routes, r)
+	}
+	if c.filter != nil {
+		coll.Filter = newEffectiveFilter(c.filter)
+	}
+	if len(c.eventTypes) > 0 {
+		coll.EventTypes = c.eventTypes
+	}
+	if len(c.errorPolicies) > 0 {
+		coll.ErrorPolicies = make(map[string]string, len(c.errorPolicies))
+		for class, action := range c.errorPolicies {
+			coll.ErrorPolicies[string(class)] = string(action)
+		}
+	}
+	if c.enrichment != nil {
+		coll.Enrich = c.enrichment.Stages
+	}
+	if c.reshape != nil {
+		coll.Reshape = c.reshape.Rules
+	}
+	if c.envelope != nil {
+		coll.Envelope = &effectiveEnvelope{MissingBefore: string(c.envelope.MissingBefore)}
+	}
+	if c.flatten != nil {
+		coll.Flatten = &effectiveFlatten{Separator: c.flatten.Separator, Arrays: string(c.flatten.Arrays)}
+	}
+	if c.typeConversions != nil {
+		coll.TypeConversions = &effectiveTypeConversions{
+			ObjectId: string(c.typeConversions.ObjectId),
+			Date:     string(c.typeConversions.Date),
+			Decimal:  string(c.typeConversions.Decimal),
+			Long:     string(c.typeConversions.Long),
+		}
+	}
+	if c.canary != nil {
+		coll.Canary = &effectiveCanary{
+			Percent:       c.canary.Percent,
+			Subject:       c.canary.Subject,
+			Encoder:       string(c.canary.Encoder),
+			ExcludeFields: c.canary.ExcludeFields,
+		}
+	}
+	if c.snapshot != nil {
+		coll.Snapshot = &effectiveSnapshot{Schedule: c.snapshot.schedule.String(), Filter: c.snapshot.filter}
+	}
+	if c.collation != nil {
+		coll.Collation = &effectiveCollation{Locale: c.collation.Locale, Strength: c.collation.Strength}
+	}
+	if c.tokensCollTtl > 0 {
+		coll.TokensCollTtl = c.tokensCollTtl.String()
+	}
+	if c.maxEventAge > 0 {
+		coll.MaxEventAge = c.maxEventAge.String()
+	}
+	if c.duplicatesWindow > 0 {
+		coll.DuplicatesWindow = c.duplicatesWindow.String()
+	}
+	if c.streamLimits != nil {
+		coll.StreamLimits = &effectiveStreamLimits{
+			MaxMsgsPerSubject: c.streamLimits.MaxMsgsPerSubject,
+			MaxMsgs:           c.streamLimits.MaxMsgs,
+			MaxBytes:          c.streamLimits.MaxBytes,
+		}
+		if c.streamLimits.MaxAge > 0 {
+			coll.StreamLimits.MaxAge = c.streamLimits.MaxAge.String()
+		}
+	}
+	if c.ackTimeout > 0 {
+		coll.AckTimeout = c.ackTimeout.String()
+	}
+	if c.retryWait > 0 {
+		coll.RetryWait = c.retryWait.String()
+	}
+	if c.msgTtl > 0 {
+		coll.MsgTtl = c.msgTtl.String()
+	}
+	return coll
+}
+
+func (p pipeline) effective() effectivePipeline {
+	return effectivePipeline{
+		PublishWorkers: p.publishWorkers,
+		BatchSize:      p.batchSize,
+		RateLimit:      p.rateLimit,
+		Encoder:        string(p.encoder),
+	}
+}
+
+// redactUri returns the given MongoDB or NATS URI without credentials, i.e. the user info of its hosts, and the values
+// of its query parameters holding credentials, are redacted. Comma separated hosts, or URIs, are supported.
+func redactUri(uri string) string {
+	hosts, query, hasQuery := strings.Cut(uri, "?")
+	parts := strings.Split(hosts, ",")
+	for i, part := range parts {
+		scheme, authority := "", part
+		if j := strings.Index(part, "://"); j >= 0 {
+			scheme, authority = part[:j+3], part[j+3:]
+		}
+		path := ""
+		if j := strings.Index(authority, "/"); j >= 0 {
+			authority, path = authority[:j], authority[j:]
+		}
+		if j := strings.LastIndex(authority, "@"); j >= 0 {
+			authority = redacted + authority[j:]
+		}
+		parts[i] = scheme + authority + path
+	}
+	redactedUri := strings.Join(parts, ",")
+	if !hasQuery {
+		return redactedUri
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		if name, _, ok := strings.Cut(param, "="); ok && sensitiveParamRegexp.MatchString(name) {
+			params[i] = name + "=" + redacted
+		}
+	}
+	return redactedUri + "?" + strings.Join(params, "&")
+}
+
+// redactWebhookUrl returns the given webhook URL without its user info, path and query, since webhooks are often
+// authenticated by secrets embedded in their path, e.g. the ones of Slack.
+func redactWebhookUrl(webhookUrl string) string {
+	u, err := url.Parse(webhookUrl)
+	if err != nil || webhookUrl == "" {
+		return ""
+	}
+	redactedUrl := u.Scheme + "://" + u.Host
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		redactedUrl += "/" + redacted
+	}
+	return redactedUrl
+}
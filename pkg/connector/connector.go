@@ -1,36 +1,192 @@
 package connector
 
 import (
-	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
-	"golang.org/x/sync/errgroup"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/context-labs/mongodb-nats-connector/internal/logging"
 	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
 	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/notify"
 	"github.com/context-labs/mongodb-nats-connector/internal/prometheus"
 	"github.com/context-labs/mongodb-nats-connector/internal/server"
 )
 
 const (
 	defaultLogLevel                     = slog.LevelInfo
+	defaultLogSamplingInterval          = 1 * time.Second
 	defaultChangeStreamPreAndPostImages = false
 	defaultTokensDbName                 = "resume-tokens"
 	defaultTokensCollCapped             = false
 	defaultTokensCollSizeInBytes        = 0
+	defaultStallTimeout                 = 1 * time.Minute
+	defaultMsgIdStrategy                = mongo.ResumeTokenMsgIdStrategy
+	defaultOversizedPolicy              = mongo.FailOversizedPolicy
+	defaultPayloadMode                  = mongo.FullPayloadMode
+	defaultJsonFlavor                   = mongo.RelaxedJsonFlavor
+	defaultDecodeErrorPolicy            = mongo.HaltDecodeErrorPolicy
+	defaultPublishMode                  = nats.JetStreamPublishMode
+	defaultNackPolicy                   = nats.FailNackPolicy
+	defaultFailureMode                  = stopAllFailureMode
+	defaultPublishWorkers               = 1
+	defaultEncoder                      = mongo.JsonEncoder
+	defaultJournalSize                  = 100
+	defaultShutdownTimeout              = 10 * time.Second
+	defaultMaxRestartTime               = 1 * time.Minute
+	defaultInstanceId                   = "mongodb-nats-connector"
+	backfillIdLayout                    = "20060102T150405Z"
+	defaultWatermarkInterval            = 10 * time.Second
+	defaultEventsSubjectPrefix          = "$CONNECTOR.events"
+	defaultEventsTokenInterval          = 10 * time.Second
+	defaultControlSubject               = "$CONNECTOR.control"
+	defaultStatusInterval               = 10 * time.Second
+	defaultOplogWarningHeadroom         = 1 * time.Hour
+	defaultMetricsExporter              = prometheusMetricsExporter
+	defaultStatsdAddr                   = "127.0.0.1:8125"
+	defaultStatsdInterval               = 10 * time.Second
+	defaultPushgatewayJob               = "mongodb-nats-connector"
+	defaultTokenBackupInterval          = 5 * time.Minute
+	defaultPublisherGuardInterval       = 10 * time.Second
+	// idempotentResumeScanLimit is the maximum number of messages fetched from each stream when a collection resumes
+	// idempotently, to find the change events published after its last stored resume token.
+	idempotentResumeScanLimit = 10000
 )
 
+// The grouping labels of the metrics pushed to the Pushgateway, which the metrics themselves must not hold.
+const (
+	instanceGrouping = "instance"
+	commandGrouping  = "command"
+)
+
+// The kinds of monitored components whose health deadlines can be set.
+const (
+	mongoHealthComponent = "mongo"
+	natsHealthComponent  = "nats"
+)
+
+var healthComponents = []string{mongoHealthComponent, natsHealthComponent}
+
+const (
+	// renameOperationType is the operation type of the change events published once a watched collection is renamed.
+	renameOperationType = "rename"
+
+	// gapOperationType is the operation type of the markers published in place of the change events older than the
+	// maximum event age.
+	gapOperationType = "gap"
+)
+
+// labelKeyRegexp matches the label keys that are valid prometheus label names.
+var labelKeyRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// headerNameRegexp matches the valid names of the headers added by templates.
+var headerNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// bucketNameRegexp matches the valid names of NATS key-value buckets.
+var bucketNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
 var (
-	ErrDbNameMissing          = errors.New("invalid option: `dbName` is missing")
-	ErrCollNameMissing        = errors.New("invalid option: `collName` is missing")
-	ErrInvalidCollSizeInBytes = errors.New("invalid option: `collSizeInBytes` must be greater than 0")
-	ErrInvalidDbAndCollNames  = errors.New("invalid option: `dbName` and `tokensDbName` cannot be the same if `collName` and `tokensCollName` are the same")
+	ErrDbNameMissing            = errors.New("invalid option: `dbName` is missing")
+	ErrCollNameMissing          = errors.New("invalid option: `collName` is missing")
+	ErrInvalidCollSizeInBytes   = errors.New("invalid option: `collSizeInBytes` must be greater than 0")
+	ErrInvalidTokensCollTtl     = errors.New("invalid option: `tokensCollTtl` must be greater than 0, and the resume tokens collection cannot be both capped and expiring")
+	ErrInvalidDbAndCollNames    = errors.New("invalid option: `dbName` and `tokensDbName` cannot be the same if `collName` and `tokensCollName` are the same")
+	ErrInvalidLogSampling       = errors.New("invalid option: log sampling `first`, `thereafter` and `interval` must not be negative")
+	ErrInvalidServerAddr        = errors.New("invalid option: server addresses must be comma-separated `<host>:<port>` or `unix:<path>` addresses")
+	ErrInvalidServerLimits      = errors.New("invalid option: server timeouts and `maxHeaderBytes` must not be negative")
+	ErrInvalidServerAuth        = errors.New("invalid option: server auth `bearerTokensFile` must hold at least one token, and oidc `issuer` must be an http or https url, along with an `audience`")
+	ErrInvalidHealthDeadline    = errors.New("invalid option: health deadlines must be set for `mongo` or `nats`, and their `timeout` and `degradedLatency` must not be negative")
+	ErrInvalidJournalSize       = errors.New("invalid option: `journalSize` must be greater than 0")
+	ErrInvalidMsgIdStrategy     = errors.New("invalid option: `msgIdStrategy` must be one of `resumeToken`, `eventHash`, `documentField`")
+	ErrMsgIdFieldMissing        = errors.New("invalid option: `msgIdField` is required if `msgIdStrategy` is `documentField`")
+	ErrInvalidPublishMode       = errors.New("invalid option: `publishMode` must be one of `jetstream`, `core`")
+	ErrInvalidPublishWorkers    = errors.New("invalid option: `publishWorkers` must be greater than 0")
+	ErrInvalidBatchSize         = errors.New("invalid option: `batchSize` must be greater than 0")
+	ErrInvalidRateLimit         = errors.New("invalid option: `rateLimit` must be greater than 0")
+	ErrInvalidEncoder           = errors.New("invalid option: `encoder` must be one of `json`, `bson`")
+	ErrInvalidPartitions        = errors.New("invalid option: `partitions` must not be negative")
+	ErrInvalidTimeBucket        = errors.New("invalid option: `timeBucket` must be one of `year`, `month`, `day`")
+	ErrInvalidAutoEncryption    = errors.New("invalid option: mongo `autoEncryption` must have a `keyVaultNamespace` of the form `<db>.<collection>`, and at least one KMS provider")
+	ErrInvalidNatsTlsFiles      = errors.New("invalid option: nats `certFile` and `keyFile` must be set together")
+	ErrInvalidTenant            = errors.New("invalid option: tenant `name` and `natsUrl` are required")
+	ErrInvalidTenantRouting     = errors.New("invalid option: `tenantField` and `tenantDbName` cannot be both set")
+	ErrUnknownTenant            = errors.New("unknown tenant: no nats account is configured for the tenant")
+	ErrInvalidLabel             = errors.New("invalid option: label keys must match `^[a-zA-Z_][a-zA-Z0-9_]*$`, and cannot be `instance_id`")
+	ErrCollationLocaleMissing   = errors.New("invalid option: collation `locale` is missing")
+	ErrInvalidCollation         = errors.New("invalid option: collation `strength` must be between 1 and 5")
+	ErrInvalidExcludeFields     = errors.New("invalid option: `excludeFields` cannot contain empty fields, nor the `tenantField` or `msgIdField`")
+	ErrInvalidOversizedPolicy   = errors.New("invalid option: `oversizedPolicy` must be one of `fail`, `truncate`, `drop`, `dlq`, `offload`")
+	ErrDlqSubjectMissing        = errors.New("invalid option: `dlqSubject` is required if `oversizedPolicy` is `dlq`")
+	ErrInvalidQuarantine        = errors.New("invalid option: `quarantineCollName` cannot be the same as `tokensCollName`, nor as `collName` if `dbName` and `tokensDbName` are the same")
+	ErrQuarantineMissing        = errors.New("the collection has no quarantine, `quarantineCollName` is not set")
+	ErrOffloadBucketMissing     = errors.New("invalid option: `offloadBucket` is required if `oversizedPolicy` is `offload`")
+	ErrInvalidDecodeErrorPolicy = errors.New("invalid option: `decodeErrorPolicy` must be one of `halt`, `skip`, `dlq`")
+	ErrDecodeDlqSubjectMissing  = errors.New("invalid option: `dlqSubject` is required if `decodeErrorPolicy` is `dlq`")
+	ErrInvalidMissingImages     = errors.New("invalid option: `missingImages` must be one of `fail`, `partial`, `dlq`")
+	ErrImagesDlqSubjectMissing  = errors.New("invalid option: `dlqSubject` is required if `missingImages` is `dlq`")
+	ErrInvalidNackPolicy        = errors.New("invalid option: `nackPolicy` must be one of `fail`, `dlq`")
+	ErrNackDlqSubjectMissing    = errors.New("invalid option: `dlqSubject` is required if `nackPolicy` is `dlq`")
+	ErrInvalidErrorPolicies     = errors.New("invalid option: `errorPolicies` must map `decode` and `transform` to one of `halt`, `skip`, `dlq`, `publishTimeout` to one of `retry`, `skip`, `dlq`, `halt`, `tokenSave` to one of `retry`, `skip`, `halt`, and `unknownTenant` to one of `skip`, `dlq`, `halt`")
+	ErrErrorDlqSubjectMissing   = errors.New("invalid option: `dlqSubject` is required if an error class of `errorPolicies` is mapped to `dlq`")
+	ErrInvalidSubjectTemplate   = errors.New("invalid option: `subjectTemplate` is not a valid template")
+	ErrSubjectTemplateConflict  = errors.New("invalid option: `subjectTemplate` cannot be combined with `namespaceSubjects`, `partitions` or `timeBucket`")
+	ErrIdempotentResumeConflict = errors.New("invalid option: `idempotentResume` requires the `jetstream` publish mode, and cannot be combined with `tenantField` or `tenantDbName`")
+	ErrInvalidHeaderTemplate    = errors.New("invalid option: header names must be valid and not start with `Nats-` or `Connector-`, and their templates must be valid")
+	ErrInvalidRoute             = errors.New("invalid option: routes must have a `streamName`, and conditions on non-empty fields")
+	ErrInvalidFilter            = errors.New("invalid option: filters must have either a `field` with an `op` among `eq`, `ne`, `gt`, `gte`, `lt`, `lte`, `in`, `nin`, `exists`, or `and` / `or` groups")
+	ErrInvalidEventTypes        = errors.New("invalid option: `eventTypes` must map the `insert`, `update`, `replace` or `delete` operation types to valid subjects")
+	ErrInvalidPatch             = errors.New("invalid option: `patch` must be one of `json`, `merge`")
+	ErrInvalidEnvelope          = errors.New("invalid option: envelope `missingBefore` must be one of `skip`, `publishWithout`, `fail`")
+	ErrEnvelopeConflict         = errors.New("invalid option: `envelope` cannot be combined with `patch`")
+	ErrInvalidPayloadMode       = errors.New("invalid option: `payloadMode` must be one of `full`, `slim`")
+	ErrInvalidReshape           = errors.New("invalid option: `reshape` rules must each be of the form `<field> -> <field>`, using the dot notation, or `<field> -> .`")
+	ErrInvalidTypeConversions   = errors.New("invalid option: type conversion `objectId` must be one of `extjson`, `hex`, `date` one of `extjson`, `rfc3339`, `decimal` one of `extjson`, `string`, `number`, and `long` one of `number`, `string`")
+	ErrInvalidFlatten           = errors.New("invalid option: flatten `separator` must not contain `$` nor whitespaces, and its `arrays` must be one of `keep`, `index`")
+	ErrInvalidJsonFlavor        = errors.New("invalid option: `jsonFlavor` must be one of `relaxed`, `canonical`, `simplified`")
+	ErrTypeConversionsConflict  = errors.New("invalid option: `typeConversions` cannot be combined with the `canonical` json flavor")
+	ErrInvalidEnrichment        = errors.New("invalid option: `enrich` stages must each be a single `$lookup`, `$addFields`, `$set`, `$unset` or `$project` stage in extended json")
+	ErrInvalidSchemaVersion     = errors.New("invalid option: `schemaVersion` must not be negative")
+	ErrInvalidCanary            = errors.New("invalid option: canary `percent` must be between 0 and 100, its `subject` must be a valid subject, and its `encoder` one of `json`, `bson`")
+	ErrInvalidSnapshot          = errors.New("invalid option: snapshot `schedule` must be a cron expression, and its `filter` a query document in extended json")
+	ErrInvalidTransactions      = errors.New("invalid option: `transactions` must be one of `tag`, `batch`")
+	ErrInvalidOplogWarning      = errors.New("invalid option: oplog warning `headroom` must not be negative, and its `webhookUrl` must be an http or https url")
+	ErrInvalidWebhook           = errors.New("invalid option: webhook `url` must be an http or https url, its `format` one of `json`, `slack`, and its `alerts` among `watcherFailed`, `tokenExpired`, `circuitOpen`, `oplogHeadroomLow`, `dualPublisher`")
+	ErrInvalidWatcherStates     = errors.New("invalid option: `watcherStatesBucket` must be a valid key-value bucket name")
+	ErrInvalidTokenBackup       = errors.New("invalid option: token backup must have either an object store `bucket` or a file `path`, and its `interval` must not be negative")
+	ErrInvalidTokenReplica      = errors.New("invalid option: token backup `replicaNatsUrl` requires the token backup to have an object store `bucket`")
+	ErrInvalidPublisherGuard    = errors.New("invalid option: publisher guard `leaseBucket` must be a valid key-value bucket name, and its `interval` must not be negative")
+	ErrInvalidStatusBucket      = errors.New("invalid option: status `bucket` must be a valid key-value bucket name, and its `interval` must not be negative")
+	ErrInvalidMetricsExporter   = errors.New("invalid option: metrics `exporter` must be one of `prometheus`, `statsd`")
+	ErrInvalidStatsd            = errors.New("invalid option: statsd `addr` must be of the form `<host>:<port>`, and its `interval` must not be negative")
+	ErrInvalidPushgateway       = errors.New("invalid option: pushgateway `url` must be a valid http(s) url")
+	ErrInvalidRetryPolicy       = errors.New("invalid option: retry `initialInterval`, `maxInterval`, `maxElapsedTime` and `maxAttempts` must not be negative, `maxInterval` must not be below `initialInterval`, `multiplier` must be at least 1, `jitter` one of `none`, `full`, `equal`, `decorrelated`, and `retryOn` among `backpressure`, `ackTimeout`, `transient`, `unreachable`")
+	ErrInvalidDevGen            = errors.New("invalid option: devgen requires a positive `rate`, `ops` weighting `insert`, `update`, `replace` and `delete`, and a document `template` in extended json")
+	ErrInvalidWatermark         = errors.New("invalid option: watermark `subject` must be a valid subject, and its `interval` must not be negative")
+	ErrInvalidEvents            = errors.New("invalid option: events `subjectPrefix` must be a valid subject, and its `tokenInterval` must not be negative")
+	ErrInvalidControl           = errors.New("invalid option: control `subject` must be a valid subject, and the commands registered must be named, other than `pause` and `resume`")
+	ErrUnauthenticatedControl   = errors.New("invalid option: control commands must be authenticated, by authenticating the requests to the server, unless `allowUnauthenticated` is set")
+	ErrInvalidGridFS            = errors.New("invalid option: `gridFs` requires the collection to be the files collection of a GridFS bucket, e.g. `fs.files`")
+	ErrInvalidFailureMode       = errors.New("invalid option: `failureMode` must be one of `stopAll`, `isolate`")
+	ErrInvalidStreamLimits      = errors.New("invalid option: stream limits `maxMsgsPerSubject`, `maxMsgs`, `maxBytes` and `maxAge` must not be negative")
+	ErrAllCollectionsFailed     = errors.New("all collections failed")
+	ErrOversizedPayload         = errors.New("oversized payload: change event exceeds the maximum payload of nats")
+	ErrForcedShutdown           = errors.New("forced shutdown: in-flight change events could not be drained in time")
+	ErrPreflightFailed          = errors.New("preflight failed")
+	ErrDualPublisher            = errors.New("dual publisher: another connector is publishing to the stream")
+	ErrUnboundSubjects          = errors.New("unbound subjects: change events would not be stored by exactly their stream")
+	ErrMongoFailed              = errors.New("fatal mongodb error")
+	ErrNatsFailed               = errors.New("fatal nats error")
 )
 
 // The Connector type represents a connector between MongoDB and NATS.
@@ -44,13 +200,58 @@ type Connector struct {
 
 	// server represents the HTTP server used by the Connector.
 	server *server.Server
+
+	// journal represents the in-memory journal of the most recently published change events.
+	journal *server.Journal
+
+	// status represents the operational state of each watched collection.
+	status *server.Status
+
+	// notifier represents the notifier posting the Connector's alerts to the configured webhooks.
+	notifier *notify.Notifier
+
+	// headers represents the headers added to each published change event, identifying the Connector's instance.
+	headers map[string]string
+
+	// registerer represents the registerer of the Connector's metrics, which are unregistered once it is closed.
+	registerer *prometheus.TrackingRegisterer
+
+	// mongoRegisterer represents the registerer of the metrics of the Connector's MongoDB client, if it created it.
+	mongoRegisterer *prometheus.MongoRegisterer
+
+	// watchOpts represents the options of the watched collections, by namespace, once they are watched.
+	watchOptsMu sync.RWMutex
+	watchOpts   map[string]*mongo.WatchCollectionOptions
+
+	// watermarks represents the cluster time up to which all the change events of each watched collection are
+	// processed, by namespace, once it is reported.
+	watermarksMu sync.Mutex
+	watermarks   map[string]primitive.Timestamp
+
+	// storedTokens represents the last stored resume token of each watched collection, by namespace, whose oplog
+	// headroom is checked against the oldest oplog entry.
+	storedTokensMu sync.Mutex
+	storedTokens   map[string]*storedToken
+
+	// watcherToggles represents whether the watcher of each watched collection is disabled, by namespace, once it was
+	// disabled or enabled.
+	watcherTogglesMu sync.Mutex
+	watcherToggles   map[string]*watcherToggle
+
+	// streamSequences represents the sequence of the last message acknowledged by each stream, by stream name, which is
+	// backed up along with the resume tokens.
+	streamSequencesMu sync.Mutex
+	streamSequences   map[string]uint64
 }
 
 // New creates a new Connector.
 // The given options will override its default configuration.
-func New(opts ...Option) (*Connector, error) {
+func New(opts ...Option) (_ *Connector, err error) {
 	c := &Connector{
-		options: getDefaultOptions(),
+		options:      getDefaultOptions(),
+		watchOpts:    make(map[string]*mongo.WatchCollectionOptions),
+		watermarks:   make(map[string]primitive.Timestamp),
+		storedTokens: make(map[string]*storedToken),
 	}
 
 	for _, opt := range opts {
@@ -59,133 +260,250 @@ func New(opts ...Option) (*Connector, error) {
 		}
 	}
 
+	for _, coll := range c.options.collections {
+		coll.pipeline = coll.pipeline.inherit(c.options.pipeline)
+		if coll.tenantDbName {
+			if _, ok := c.options.tenants[coll.dbName]; !ok {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownTenant, coll.dbName)
+			}
+		}
+	}
+	if c.tokenBackupReplicated() && c.options.tokenBackupBucket == "" {
+		return nil, ErrInvalidTokenReplica
+	}
+	if c.options.controlSubject != "" && len(c.options.serverAuthenticators) == 0 &&
+		!c.options.controlUnauthenticated {
+		return nil, ErrUnauthenticatedControl
+	}
+
 	loggerOpts := &slog.HandlerOptions{Level: c.options.logLevel}
-	c.logger = slog.New(slog.NewJSONHandler(os.Stdout, loggerOpts))
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, loggerOpts)
+	if c.options.logSampling {
+		handler = logging.NewSamplingHandler(handler, c.options.logSamplingFirst, c.options.logSamplingThereafter,
+			c.options.logSamplingInterval)
+	}
+	c.logger = slog.New(handler).With("instanceId", c.options.instanceId)
+	c.notifier = notify.New(c.logger, c.options.webhooks...)
+
+	for _, coll := range c.options.collections {
+		if mongo.IsGridFSChunks(coll.collName) {
+			c.logger.Warn("watching the chunks collection of a GridFS bucket, consider watching its files collection "+
+				"with gridFs instead", "dbName", coll.dbName, "collName", coll.collName)
+		}
+	}
 
-	registerer := prometheus.DefaultRegisterer()
+	c.headers = map[string]string{instanceIdHdr: c.options.instanceId}
+	constLabels := map[string]string{instanceIdMetric: c.options.instanceId}
+	for key, value := range c.options.labels {
+		c.headers[labelHdrPrefix+key] = value
+		constLabels[key] = value
+	}
+	c.registerer = prometheus.NewTrackingRegisterer(
+		prometheus.WithConstLabels(prometheus.DefaultRegisterer(), constLabels))
+	registerer := c.registerer
+	defer func() {
+		if err != nil { // another Connector with the same labels can be created later, e.g. once its config is fixed
+			c.registerer.UnregisterAll()
+		}
+	}()
 
 	if c.options.mongoClient == nil {
 		mongoRegisterer := prometheus.NewMongoRegisterer(registerer)
+		c.mongoRegisterer = mongoRegisterer
 		mongoClient, err := mongo.NewDefaultClient(
 			mongo.WithMongoUri(c.options.mongoUri),
+			mongo.WithAutoEncryption(c.options.mongoKeyVaultNamespace, c.options.mongoKmsProviders),
 			mongo.WithLogger(c.logger),
+			mongo.WithMetadataLogging(c.options.logMetadataOnly),
+			mongo.WithRetryPolicy(c.options.retryPolicy),
 			mongo.WithEventListeners(
 				mongo.OnCmdStartedEvent(mongoRegisterer.IncMongoCmdStarted),
 				mongo.OnCmdSucceededEvent(mongoRegisterer.ObserveMongoCmdSucceeded),
 				mongo.OnCmdFailedEvent(mongoRegisterer.ObserveMongoCmdFailed),
+				mongo.OnTokenSavedEvent(func(dbName, collName string, tokenTime time.Time) {
+					mongoRegisterer.ObserveMongoTokenSaved(dbName, collName, tokenTime)
+					c.recordStoredToken(dbName, collName, tokenTime)
+				}),
+				mongo.OnTokenSaveRetriedEvent(mongoRegisterer.IncMongoTokenSaveRetries),
+				mongo.OnTokenSaveFailedEvent(mongoRegisterer.IncMongoTokenSaveFailures),
+				mongo.OnOplogWindowEvent(func(oldest time.Time) {
+					mongoRegisterer.ObserveMongoOplogWindow(oldest)
+					c.checkOplogHeadroom(oldest)
+				}),
+				mongo.OnChangeEventPublishedEvent(mongoRegisterer.ObserveMongoChangeEventPublished),
+				mongo.OnChangeEventOversizedEvent(mongoRegisterer.IncMongoChangeEventsOversized),
+				mongo.OnChangeEventMalformedEvent(mongoRegisterer.IncMongoChangeEventsMalformed),
+				mongo.OnChangeEventQuarantinedEvent(mongoRegisterer.IncMongoChangeEventsQuarantined),
+				mongo.OnChangeEventsSkippedEvent(mongoRegisterer.AddMongoChangeEventsSkipped),
+				mongo.OnChangeEventFilteredEvent(mongoRegisterer.IncMongoChangeEventsFiltered),
+				mongo.OnChangeEventOutOfOrderEvent(mongoRegisterer.IncMongoChangeEventsOutOfOrder),
+				mongo.OnConnOpenedEvent(mongoRegisterer.IncMongoConnsOpen),
+				mongo.OnConnClosedEvent(mongoRegisterer.DecMongoConnsOpen),
+				mongo.OnReconnectEvent(mongoRegisterer.IncMongoReconnects),
+				mongo.OnChangeStreamOpenedEvent(mongoRegisterer.IncMongoChangeStreamsOpen),
+				mongo.OnChangeStreamClosedEvent(mongoRegisterer.DecMongoChangeStreamsOpen),
 			),
 		)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %w", ErrPreflightFailed, err)
 		}
 		c.options.mongoClient = mongoClient
 	}
 
-	if c.options.natsClient == nil {
-		natsRegisterer := prometheus.NewNatsRegisterer(registerer)
-		natsClient, err := nats.NewDefaultClient(
-			nats.WithNatsUrl(c.options.natsUrl),
+	var natsRegisterer *prometheus.NatsRegisterer
+	newNatsClient := func(opts ...nats.ClientOption) (nats.Client, error) {
+		if natsRegisterer == nil { // nats clients share the same metrics
+			natsRegisterer = prometheus.NewNatsRegisterer(registerer)
+		}
+		return nats.NewDefaultClient(append(opts,
 			nats.WithLogger(c.logger),
+			nats.WithMetadataLogging(c.options.logMetadataOnly),
 			nats.WithEventListeners(
 				nats.OnMsgPublishedEvent(natsRegisterer.ObserveNatsMsgPublished),
 				nats.OnMsgFailedEvent(natsRegisterer.ObserveNatsMsgFailed),
+				nats.OnMsgAckedEvent(c.recordStreamSequence),
+				nats.OnMsgDuplicateEvent(natsRegisterer.IncNatsDuplicates),
+				nats.OnDisconnectEvent(natsRegisterer.IncNatsDisconnects),
+				nats.OnReconnectEvent(natsRegisterer.IncNatsReconnects),
+				nats.OnBackpressureEvent(natsRegisterer.IncNatsBackpressure),
+				nats.OnNackEvent(natsRegisterer.IncNatsNacks),
+				nats.OnAckTimeoutEvent(natsRegisterer.IncNatsAckTimeouts),
+				nats.OnConnErrorEvent(natsRegisterer.IncNatsConnErrors),
+				nats.OnCredsRotatedEvent(natsRegisterer.IncNatsCredsRotations),
 			),
+		)...)
+	}
+
+	if c.options.natsClient == nil {
+		natsClient, err := newNatsClient(
+			nats.WithNatsUrl(c.options.natsUrl),
+			nats.WithCredsFile(c.options.natsCredsFile),
+			nats.WithTlsFiles(c.options.natsCertFile, c.options.natsKeyFile, c.options.natsCaFile),
 		)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %w", ErrPreflightFailed, err)
 		}
 		c.options.natsClient = natsClient
 	}
 
+	monitors := []server.NamedMonitor{
+		server.WithDeadline(c.options.mongoClient, c.options.healthDeadlines[mongoHealthComponent]),
+		server.WithDeadline(c.options.natsClient, c.options.healthDeadlines[natsHealthComponent]),
+	}
+	for _, name := range c.tenantNames() {
+		t := c.options.tenants[name]
+		if t.natsClient == nil {
+			natsClient, err := newNatsClient(nats.WithNatsUrl(t.natsUrl), nats.WithName("nats-"+name))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+			}
+			t.natsClient = natsClient
+		}
+		monitors = append(monitors, server.WithDeadline(t.natsClient, c.options.healthDeadlines[natsHealthComponent]))
+	}
+
+	// the replica is not monitored, so that the connector is not deemed unhealthy once the passive region is unreachable
+	if c.tokenBackupReplicated() && c.options.tokenBackupReplica == nil {
+		natsClient, err := newNatsClient(nats.WithNatsUrl(c.options.tokenBackupReplicaUrl), nats.WithName("nats-replica"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+		c.options.tokenBackupReplica = natsClient
+	}
+
 	c.options.ctx, c.options.stop = signal.NotifyContext(c.options.ctx, syscall.SIGINT, syscall.SIGTERM)
 
+	c.journal = server.NewJournal(c.options.journalSize)
+
+	namespaces := make([]string, 0, len(c.options.collections))
+	for _, coll := range c.options.collections {
+		namespaces = append(namespaces, coll.namespace())
+	}
+	c.status = server.NewStatus(namespaces...)
+
 	c.server = server.New(
 		server.WithAddr(c.options.serverAddr),
+		server.WithAdminAddr(c.options.serverAdminAddr),
+		server.WithGrpcAddr(c.options.serverGrpcAddr),
+		server.WithTimeouts(c.options.serverReadTimeout, c.options.serverWriteTimeout, c.options.serverIdleTimeout),
+		server.WithMaxHeaderBytes(c.options.serverMaxHeaderBytes),
+		server.WithShutdownTimeout(c.options.serverShutdownTimeout),
 		server.WithContext(c.options.ctx),
-		server.WithNamedMonitors(c.options.mongoClient, c.options.natsClient),
+		server.WithNamedMonitors(monitors...),
+		server.WithAuthenticators(c.options.serverAuthenticators...),
 		server.WithLogger(c.logger),
-		server.WithMetricsHandler(prometheus.HTTPHandler()),
+		server.WithMetricsHandler(c.metricsHandler()),
+		server.WithJournal(c.journal),
+		server.WithStatus(c.status),
+		server.WithConfig(c.effectiveConfig()),
+		server.WithMappings(c),
+		server.WithCutovers(c),
+		server.WithWatchers(c),
+		server.WithInstance(&server.Instance{Id: c.options.instanceId, Labels: c.options.labels}),
 	)
 
 	return c, nil
 }
 
-// Run runs the Connector.
-// It performs the following operations:
-//
-//	For each configured collection to be watched:
-//		- It creates the given collection on MongoDB, if it does not already exist
-//		- It creates the resume tokens collection for the given collection on MongoDB, if it does not already exist
-//		- It creates the given stream on NATS, if it does not already exist
-//		- Spins up a goroutine to watch the given collection
-//	It runs an HTTP server in its own goroutine.
-//	It runs another goroutine that will perform graceful shutdown once the Connector's context is cancelled.
-func (c *Connector) Run() error {
-	defer c.cleanup()
-
-	group, groupCtx := errgroup.WithContext(c.options.ctx)
+// EffectiveConfig returns the configuration the Connector is running with, once defaults and inherited settings are
+// applied, where credentials are redacted. It is meant to be encoded as json.
+func (c *Connector) EffectiveConfig() any {
+	return c.effectiveConfig()
+}
 
-	for _, coll := range c.options.collections {
-		createWatchedCollOpts := &mongo.CreateCollectionOptions{
-			DbName:                       coll.dbName,
-			CollName:                     coll.collName,
-			ChangeStreamPreAndPostImages: coll.changeStreamPreAndPostImages,
-		}
-		if err := c.options.mongoClient.CreateCollection(groupCtx, createWatchedCollOpts); err != nil {
-			return err
-		}
+// Status returns the operational state of each watched collection, by namespace.
+func (c *Connector) Status() map[string]server.CollectionStatus {
+	return c.status.Collections()
+}
 
-		createResumeTokensCollOpts := &mongo.CreateCollectionOptions{
-			DbName:      coll.tokensDbName,
-			CollName:    coll.tokensCollName,
-			Capped:      coll.tokensCollCapped,
-			SizeInBytes: coll.tokensCollSizeInBytes,
+// Mappings returns how the change events of the watched collections are routed to NATS, sorted by namespace, i.e. the
+// streams and subjects they are published to, with the current schema version of their collection, their encoder, and
+// the transforms applied to them. The collections not watched yet are not listed.
+func (c *Connector) Mappings() []server.Mapping {
+	c.watchOptsMu.RLock()
+	defer c.watchOptsMu.RUnlock()
+	mappings := make([]server.Mapping, 0, len(c.watchOpts))
+	for namespace, opts := range c.watchOpts {
+		collMapping := mongo.MappingOf(opts)
+		mapping := server.Mapping{
+			Namespace:     namespace,
+			Stream:        collMapping.StreamName,
+			SubjectFilter: collMapping.SubjectFilter,
+			Subjects:      collMapping.Subjects,
+			Encoder:       string(opts.Encoder),
+			Transforms:    mongo.Transforms(opts),
 		}
-		if err := c.options.mongoClient.CreateCollection(groupCtx, createResumeTokensCollOpts); err != nil {
-			return err
+		if coll := c.collection(namespace); coll != nil {
+			mapping.PublishMode = string(coll.publishMode)
 		}
-
-		addStreamOpts := &nats.AddStreamOptions{StreamName: coll.streamName}
-		if err := c.options.natsClient.AddStream(groupCtx, addStreamOpts); err != nil {
-			return err
+		for i, routeOpts := range mongo.RouteOptions(opts) {
+			routeMapping := mongo.MappingOf(routeOpts)
+			mapping.Routes = append(mapping.Routes, server.MappingRoute{
+				When:          opts.Routes[i].When,
+				Stream:        routeMapping.StreamName,
+				SubjectFilter: routeMapping.SubjectFilter,
+				Subjects:      routeMapping.Subjects,
+			})
 		}
-
-		group.Go(func() error {
-			watchCollOpts := &mongo.WatchCollectionOptions{
-				WatchedDbName:          coll.dbName,
-				WatchedCollName:        coll.collName,
-				ResumeTokensDbName:     coll.tokensDbName,
-				ResumeTokensCollName:   coll.tokensCollName,
-				ResumeTokensCollCapped: coll.tokensCollCapped,
-				StreamName:             coll.streamName,
-				ChangeEventHandler: func(ctx context.Context, subj, msgId string, data []byte) error {
-					publishOpts := &nats.PublishOptions{
-						Subj:  subj,
-						MsgId: msgId,
-						Data:  data,
-					}
-					return c.options.natsClient.Publish(ctx, publishOpts)
-				},
-			}
-			return c.options.mongoClient.WatchCollection(groupCtx, watchCollOpts) // blocking call
-		})
+		mappings = append(mappings, mapping)
 	}
-
-	group.Go(func() error {
-		return c.server.Run()
+	slices.SortFunc(mappings, func(a, b server.Mapping) int {
+		return strings.Compare(a.Namespace, b.Namespace)
 	})
-
-	group.Go(func() error {
-		<-groupCtx.Done()
-		return c.server.Close()
-	})
-
-	return group.Wait()
+	return mappings
 }
 
 func (c *Connector) cleanup() {
 	c.closeClient(c.options.mongoClient)
 	c.closeClient(c.options.natsClient)
+	for _, t := range c.options.tenants {
+		c.closeClient(t.natsClient)
+	}
+	if c.options.tokenBackupReplica != nil {
+		c.closeClient(c.options.tokenBackupReplica)
+	}
+	c.registerer.UnregisterAll()
+	c.notifier.Wait()
 	c.options.stop()
 }
 
@@ -194,226 +512,3 @@ func (c *Connector) closeClient(closer io.Closer) {
 		c.logger.Error("could not close client", "err", err)
 	}
 }
-
-// Options represents the possible options to be applied to a Connector.
-type Options struct {
-
-	// logLevel represents the Connector's log level.
-	// Can be set to 'info', 'debug', 'warn', or 'error'.
-	logLevel slog.Level
-
-	// mongoUri represents the Connector's MongoDB URI.
-	mongoUri string
-
-	// mongoClient represents the MongoDB client used by the Connector to connect to MongoDB.
-	mongoClient mongo.Client
-
-	// natsUrl represents the Connector's NATS URL.
-	natsUrl string
-
-	// natsClient represents the NATS client used by the Connector to connect to NATS.
-	natsClient nats.Client
-
-	// ctx represents the Connector's context.
-	ctx  context.Context
-	stop context.CancelFunc
-
-	// serverAddr represents the Connector's HTTP server address.
-	serverAddr string
-
-	// collections represents a slice containing the collections to be watched, with their own configuration.
-	collections []*collection
-}
-
-func getDefaultOptions() Options {
-	return Options{
-		logLevel:    defaultLogLevel,
-		ctx:         context.Background(),
-		collections: make([]*collection, 0),
-	}
-}
-
-// Option is used to configure the Connector.
-type Option func(*Options) error
-
-// WithLogLevel sets the Connector's log level.
-func WithLogLevel(logLevel string) Option {
-	return func(o *Options) error {
-		switch strings.ToLower(logLevel) {
-		case "debug":
-			o.logLevel = slog.LevelDebug
-		case "warn":
-			o.logLevel = slog.LevelWarn
-		case "error":
-			o.logLevel = slog.LevelError
-		case "info":
-			o.logLevel = slog.LevelInfo
-		}
-		return nil
-	}
-}
-
-// WithMongoUri sets the Connector's MongoDB URI.
-func WithMongoUri(mongoUri string) Option {
-	return func(o *Options) error {
-		if mongoUri != "" {
-			o.mongoUri = mongoUri
-		}
-		return nil
-	}
-}
-
-// withMongoClient sets the Connector's MongoDB client implementation.
-// Used for testing.
-func withMongoClient(mongoClient mongo.Client) Option {
-	return func(o *Options) error {
-		if mongoClient != nil {
-			o.mongoClient = mongoClient
-		}
-		return nil
-	}
-}
-
-// WithNatsUrl sets the Connector's NATS URL.
-func WithNatsUrl(natsUrl string) Option {
-	return func(o *Options) error {
-		if natsUrl != "" {
-			o.natsUrl = natsUrl
-		}
-		return nil
-	}
-}
-
-// withNatsClient sets the Connector's NATS client implementation.
-// Used for testing.
-func withNatsClient(natsClient nats.Client) Option {
-	return func(o *Options) error {
-		if natsClient != nil {
-			o.natsClient = natsClient
-		}
-		return nil
-	}
-}
-
-// WithContext sets the Connector's context.
-func WithContext(ctx context.Context) Option {
-	return func(o *Options) error {
-		if ctx != nil {
-			o.ctx = ctx
-		}
-		return nil
-	}
-}
-
-// WithServerAddr sets the Connector's HTTP server address.
-func WithServerAddr(serverAddr string) Option {
-	return func(o *Options) error {
-		if serverAddr != "" {
-			o.serverAddr = serverAddr
-		}
-		return nil
-	}
-}
-
-// WithCollection configures a collection to be watched by the Connector, with the given options.
-func WithCollection(dbName, collName string, opts ...CollectionOption) Option {
-	return func(o *Options) error {
-		if dbName == "" {
-			return ErrDbNameMissing
-		}
-		if collName == "" {
-			return ErrCollNameMissing
-		}
-		coll := &collection{
-			dbName:                       dbName,
-			collName:                     collName,
-			changeStreamPreAndPostImages: defaultChangeStreamPreAndPostImages,
-			tokensDbName:                 defaultTokensDbName,
-			tokensCollName:               collName,
-			tokensCollCapped:             defaultTokensCollCapped,
-			tokensCollSizeInBytes:        defaultTokensCollSizeInBytes,
-			streamName:                   strings.ToUpper(collName),
-		}
-		for _, opt := range opts {
-			if err := opt(coll); err != nil {
-				return err
-			}
-		}
-		if strings.EqualFold(coll.dbName, coll.tokensDbName) &&
-			strings.EqualFold(coll.collName, coll.tokensCollName) {
-			return ErrInvalidDbAndCollNames
-		}
-		o.collections = append(o.collections, coll)
-		return nil
-	}
-}
-
-type collection struct {
-	dbName                       string
-	collName                     string
-	changeStreamPreAndPostImages bool
-	tokensDbName                 string
-	tokensCollName               string
-	tokensCollCapped             bool
-	tokensCollSizeInBytes        int64
-	streamName                   string
-}
-
-// CollectionOption is used to configure a MongoDB collection to be watched.
-type CollectionOption func(*collection) error
-
-// WithChangeStreamPreAndPostImages enables MongoDB's changeStreamPreAndPostImages configuration.
-//
-// Deprecated: will be removed in future versions. Set this configuration directly on MongoDB instead.
-func WithChangeStreamPreAndPostImages() CollectionOption {
-	return func(c *collection) error {
-		c.changeStreamPreAndPostImages = true
-		return nil
-	}
-}
-
-// WithTokensDbName sets the name of the MongoDB database that will store the resume tokens collection for the
-// collection to be watched.
-func WithTokensDbName(tokensDbName string) CollectionOption {
-	return func(c *collection) error {
-		if tokensDbName != "" {
-			c.tokensDbName = tokensDbName
-		}
-		return nil
-	}
-}
-
-// WithTokensCollName sets the name of the MongoDB collection that will store the resume tokens for the collection to
-// be watched.
-func WithTokensCollName(tokensCollName string) CollectionOption {
-	return func(c *collection) error {
-		if tokensCollName != "" {
-			c.tokensCollName = tokensCollName
-		}
-		return nil
-	}
-}
-
-// WithTokensCollCapped sets the MongoDB collection that will store the resume tokens for the collection to be watched
-// as capped, with the given size.
-func WithTokensCollCapped(collSizeInBytes int64) CollectionOption {
-	return func(c *collection) error {
-		if collSizeInBytes <= 0 {
-			return ErrInvalidCollSizeInBytes
-		}
-		c.tokensCollCapped = true
-		c.tokensCollSizeInBytes = collSizeInBytes
-		return nil
-	}
-}
-
-// WithStreamName sets the NATS stream name, where the MongoDB change events will be published for the collection to be
-// watched.
-func WithStreamName(streamName string) CollectionOption {
-	return func(c *collection) error {
-		if streamName != "" {
-			c.streamName = streamName
-		}
-		return nil
-	}
-}
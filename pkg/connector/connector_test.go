@@ -3,18 +3,34 @@ package connector
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	natsgo "github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/context-labs/mongodb-nats-connector/internal/cron"
+	"github.com/context-labs/mongodb-nats-connector/internal/logging"
 	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
 	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/notify"
+	"github.com/context-labs/mongodb-nats-connector/internal/prometheus"
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
 )
 
 func TestNew(t *testing.T) {
@@ -31,16 +47,28 @@ func TestNew(t *testing.T) {
 
 		require.NoError(t, err)
 		require.Equal(t, slog.LevelInfo, conn.options.logLevel)
+		require.False(t, conn.options.logSampling)
+		require.False(t, conn.options.logMetadataOnly)
+		require.False(t, conn.options.systemdNotify)
 		require.Empty(t, conn.options.mongoUri)
 		require.Equal(t, mongoClient, conn.options.mongoClient)
 		require.Empty(t, conn.options.natsUrl)
 		require.Equal(t, natsClient, conn.options.natsClient)
 		require.NotNil(t, conn.options.ctx)
 		require.NotNil(t, conn.options.stop)
+		require.Equal(t, 10*time.Second, conn.options.shutdownTimeout)
+		require.Equal(t, 1*time.Minute, conn.options.maxRestartTime)
 		require.Empty(t, conn.options.serverAddr)
+		require.Equal(t, 100, conn.options.journalSize)
 		require.NotNil(t, conn.logger)
 		require.NotNil(t, conn.server)
+		require.NotNil(t, conn.journal)
+		require.NotNil(t, conn.status)
 		require.Empty(t, conn.options.collections)
+		hostname, _ := os.Hostname()
+		require.Equal(t, hostname, conn.options.instanceId)
+		require.Empty(t, conn.options.labels)
+		require.Equal(t, map[string]string{"Connector-Instance-Id": hostname}, conn.headers)
 	})
 	t.Run("should create connector with all supported log levels", func(t *testing.T) {
 		var (
@@ -74,27 +102,131 @@ func TestNew(t *testing.T) {
 			natsUrl     = "localhost:4222"
 			natsClient  = &mockNatsClient{}
 			serverAddr  = ":8080"
+			journalSize = 10
+			timeout     = 5 * time.Second
+			restartTime = 2 * time.Minute
 		)
 
 		conn, err := New(
 			WithLogLevel(logLevel),
+			WithLogSampling(10, 100, 5*time.Second),
+			WithLogMetadataOnly(),
 			WithMongoUri(mongoUri),
 			withMongoClient(mongoClient),
 			WithNatsUrl(natsUrl),
 			withNatsClient(natsClient),
+			WithMongoAutoEncryption("encryption.__keyVault", map[string]map[string]string{"local": {"key": "a2V5"}}),
+			WithNatsCredsFile("/etc/nats/user.creds"),
+			WithNatsTlsFiles("/etc/nats/tls.crt", "/etc/nats/tls.key", "/etc/nats/ca.crt"),
 			WithContext(context.TODO()),
 			WithServerAddr(serverAddr),
+			WithServerAdminAddr("unix:/run/connector.sock"),
+			WithServerGrpcAddr("127.0.0.1:9090"),
+			WithServerTimeouts(5*time.Second, time.Minute, 0),
+			WithServerMaxHeaderBytes(8<<10),
+			WithServerShutdownTimeout(20*time.Second),
+			WithHealthDeadline("mongo", 2*time.Second, 500*time.Millisecond),
+			WithServerOidc("https://sso.example.com", "connector"),
+			WithServerDisabled(),
+			WithSystemdNotify(),
+			WithJournalSize(journalSize),
+			WithShutdownTimeout(timeout),
+			WithMaxRestartTime(restartTime),
+			WithInstanceId("connector-0"),
+			WithLabels(map[string]string{"env": "prod", "region": "eu"}),
+			WithSchemaChangesStream("SCHEMA_CHANGES"),
+			WithSchemaVersionsBucket("schema-versions"),
+			WithWatcherStatesBucket("watcher-states"),
+			WithWatermark("WATERMARKS", 30*time.Second),
+			WithEvents("CONNECTOR.events", time.Minute),
+			WithControlSubject("CONNECTOR.control"),
+			WithStatusBucket("CONNECTOR_STATUS", 5*time.Second),
+			WithTokenBackup("resume-token-backups", "", 10*time.Minute),
+			WithPublisherGuard("publisher-leases", 5*time.Second),
+			WithMetricsExporter("statsd"),
+			WithStatsd("datadog-agent:8125", "connector.", 15*time.Second),
+			WithPushgateway("http://pushgateway:9091", "connector-batch"),
+			WithOplogWarning(2*time.Hour, "https://alerts.example.com/oplog"),
+			WithRetryPolicy(&RetryPolicy{MaxElapsedTime: 5 * time.Minute, Jitter: "full",
+				RetryOn: []string{"backpressure", "unreachable"}}),
+			WithWebhook("https://hooks.slack.com/services/T000/B000/XXX", "slack", "watcherFailed", "tokenExpired"),
+			WithWebhook("https://alerts.example.com/connector", ""),
 		)
 
 		require.NoError(t, err)
 		require.Equal(t, slog.LevelDebug, conn.options.logLevel)
+		require.True(t, conn.options.logSampling)
+		require.Equal(t, 10, conn.options.logSamplingFirst)
+		require.Equal(t, 100, conn.options.logSamplingThereafter)
+		require.Equal(t, 5*time.Second, conn.options.logSamplingInterval)
+		require.True(t, conn.options.logMetadataOnly)
+		require.IsType(t, &logging.SamplingHandler{}, conn.logger.Handler())
 		require.Equal(t, mongoUri, conn.options.mongoUri)
 		require.Equal(t, mongoClient, conn.options.mongoClient)
 		require.Equal(t, natsUrl, conn.options.natsUrl)
 		require.Equal(t, natsClient, conn.options.natsClient)
+		require.Equal(t, "encryption.__keyVault", conn.options.mongoKeyVaultNamespace)
+		require.Equal(t, map[string]map[string]string{"local": {"key": "a2V5"}}, conn.options.mongoKmsProviders)
+		require.Equal(t, "/etc/nats/user.creds", conn.options.natsCredsFile)
+		require.Equal(t, "/etc/nats/tls.crt", conn.options.natsCertFile)
+		require.Equal(t, "/etc/nats/tls.key", conn.options.natsKeyFile)
+		require.Equal(t, "/etc/nats/ca.crt", conn.options.natsCaFile)
 		require.NotNil(t, conn.options.ctx)
 		require.NotNil(t, conn.options.stop)
 		require.Equal(t, serverAddr, conn.options.serverAddr)
+		require.Equal(t, "unix:/run/connector.sock", conn.options.serverAdminAddr)
+		require.Equal(t, "127.0.0.1:9090", conn.options.serverGrpcAddr)
+		require.Equal(t, 5*time.Second, conn.options.serverReadTimeout)
+		require.Equal(t, time.Minute, conn.options.serverWriteTimeout)
+		require.Zero(t, conn.options.serverIdleTimeout)
+		require.Equal(t, 8<<10, conn.options.serverMaxHeaderBytes)
+		require.Equal(t, 20*time.Second, conn.options.serverShutdownTimeout)
+		require.Equal(t, map[string]server.Deadline{"mongo": {Timeout: 2 * time.Second,
+			DegradedLatency: 500 * time.Millisecond}}, conn.options.healthDeadlines)
+		require.Equal(t, "https://sso.example.com", conn.options.serverOidcIssuer)
+		require.Equal(t, "connector", conn.options.serverOidcAudience)
+		require.Len(t, conn.options.serverAuthenticators, 1)
+		require.True(t, conn.options.serverDisabled)
+		require.True(t, conn.options.systemdNotify)
+		require.Equal(t, journalSize, conn.options.journalSize)
+		require.Equal(t, timeout, conn.options.shutdownTimeout)
+		require.Equal(t, restartTime, conn.options.maxRestartTime)
+		require.Equal(t, "connector-0", conn.options.instanceId)
+		require.Equal(t, map[string]string{"env": "prod", "region": "eu"}, conn.options.labels)
+		require.Equal(t, "SCHEMA_CHANGES", conn.options.schemaChangesStream)
+		require.Equal(t, "schema-versions", conn.options.schemaVersionsBucket)
+		require.Equal(t, "watcher-states", conn.options.watcherStatesBucket)
+		require.Equal(t, "WATERMARKS", conn.options.watermarkSubject)
+		require.Equal(t, 30*time.Second, conn.options.watermarkInterval)
+		require.Equal(t, "CONNECTOR.events", conn.options.eventsSubjectPrefix)
+		require.Equal(t, time.Minute, conn.options.eventsTokenInterval)
+		require.Equal(t, "CONNECTOR.control", conn.options.controlSubject)
+		require.Equal(t, "CONNECTOR_STATUS", conn.options.statusBucket)
+		require.Equal(t, "resume-token-backups", conn.options.tokenBackupBucket)
+		require.Equal(t, 10*time.Minute, conn.options.tokenBackupInterval)
+		require.Equal(t, "publisher-leases", conn.options.publisherLeaseBucket)
+		require.Equal(t, 5*time.Second, conn.options.publisherGuardInterval)
+		require.Equal(t, statsdMetricsExporter, conn.options.metricsExporter)
+		require.Equal(t, "datadog-agent:8125", conn.options.statsdAddr)
+		require.Equal(t, "connector.", conn.options.statsdPrefix)
+		require.Equal(t, 15*time.Second, conn.options.statsdInterval)
+		require.Equal(t, "http://pushgateway:9091", conn.options.pushgatewayUrl)
+		require.Equal(t, "connector-batch", conn.options.pushgatewayJob)
+		require.Equal(t, 5*time.Second, conn.options.statusInterval)
+		require.Equal(t, 2*time.Hour, conn.options.oplogWarningHeadroom)
+		require.Equal(t, "https://alerts.example.com/oplog", conn.options.oplogWarningWebhook)
+		require.Equal(t, &retry.Policy{MaxElapsedTime: 5 * time.Minute, Jitter: retry.FullJitter,
+			RetryOn: []retry.Class{retry.BackpressureClass, retry.UnreachableClass}}, conn.options.retryPolicy)
+		require.Equal(t, []*notify.Webhook{
+			{Url: "https://hooks.slack.com/services/T000/B000/XXX", Format: notify.SlackFormat,
+				Alerts: []notify.Kind{notify.WatcherFailedAlert, notify.TokenExpiredAlert}},
+			{Url: "https://alerts.example.com/connector", Format: notify.JsonFormat},
+		}, conn.options.webhooks)
+		require.Equal(t, map[string]string{
+			"Connector-Instance-Id":  "connector-0",
+			"Connector-Label-env":    "prod",
+			"Connector-Label-region": "eu",
+		}, conn.headers)
 		require.NotNil(t, conn.logger)
 		require.NotNil(t, conn.server)
 		require.Empty(t, conn.options.collections)
@@ -123,6 +255,16 @@ func TestNew(t *testing.T) {
 			tokensCollCapped:             false,
 			tokensCollSizeInBytes:        0,
 			streamName:                   strings.ToUpper(collName),
+			stallTimeout:                 1 * time.Minute,
+			msgIdStrategy:                mongo.ResumeTokenMsgIdStrategy,
+			oversizedPolicy:              mongo.FailOversizedPolicy,
+			payloadMode:                  mongo.FullPayloadMode,
+			jsonFlavor:                   mongo.RelaxedJsonFlavor,
+			decodeErrorPolicy:            mongo.HaltDecodeErrorPolicy,
+			nackPolicy:                   nats.FailNackPolicy,
+			publishMode:                  nats.JetStreamPublishMode,
+			failureMode:                  stopAllFailureMode,
+			pipeline:                     pipeline{publishWorkers: 1, encoder: mongo.JsonEncoder},
 		})
 	})
 	t.Run("should create connector with given collection options", func(t *testing.T) {
@@ -135,17 +277,71 @@ func TestNew(t *testing.T) {
 			tokensCollName  = "coll1-tokens"
 			collSizeInBytes = int64(2048)
 			streamName      = "coll1-stream"
+			stallTimeout    = 30 * time.Second
 		)
+		snapshotSchedule, err := cron.Parse("0 3 * * *")
+		require.NoError(t, err)
+		lookup := `{"$lookup":{"from":"customers","localField":"customerId","foreignField":"_id","as":"customer"}}`
+		enrichment, err := mongo.ParseEnrichment([]string{lookup})
+		require.NoError(t, err)
+		reshape, err := mongo.ParseReshape([]string{"operationType -> op"})
+		require.NoError(t, err)
 
 		conn, err := New(
 			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
 			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithPipeline(WithPublishWorkers(2), WithBatchSize(100), WithRateLimit(10)),
 			WithCollection(dbName, collName,
 				WithChangeStreamPreAndPostImages(),
 				WithTokensDbName(tokensDbName),
 				WithTokensCollName(tokensCollName),
 				WithTokensCollCapped(collSizeInBytes),
 				WithStreamName(streamName),
+				WithNamespaceSubjects(),
+				WithPartitions(8),
+				WithTimeBucket("month"),
+				WithTenantField("org.tenant"),
+				WithExcludeFields("password", "profile.avatar"),
+				WithCollation("fr", 2),
+				WithSplitLargeEvents(),
+				WithEncryptedPassthrough(),
+				WithFollowRenames(),
+				WithStallTimeout(stallTimeout),
+				WithMaxEventAge(time.Hour),
+				WithMsgIdStrategy("documentField"),
+				WithMsgIdField("code"),
+				WithOversizedPolicy("offload"),
+				WithOffloadBucket("coll1-offload"),
+				WithQuarantine("coll1-quarantine"),
+				WithDecodeErrorPolicy("skip"),
+				WithMissingImages("partial"),
+				WithNackPolicy("dlq"),
+				WithErrorPolicies(map[string]string{"transform": "dlq", "tokenSave": "halt"}),
+				WithDlqSubject("COLL1_DLQ.nacked"),
+				WithDuplicatesWindow(time.Hour),
+				WithStreamLimits(&StreamLimits{MaxMsgsPerSubject: 1, MaxAge: 24 * time.Hour}),
+				WithPublishMode("core"),
+				WithPatch("json"),
+				WithPayloadMode("slim"),
+				WithJsonFlavor("simplified"),
+				WithExpectStream(),
+				WithStrictOrdering(),
+				WithAckTimeout(5*time.Second),
+				WithRetries(3, time.Second),
+				WithMsgTtl(24*time.Hour),
+				WithFailureMode("isolate"),
+				WithRoute("COLL1_EU", map[string]string{"region": "eu"}, ""),
+				WithFilter(&Filter{Or: []Filter{{Field: "fullDocument.status", Operator: "ne", Value: "draft"}}}),
+				WithEventTypes(map[string]string{"delete": "order.removed"}),
+				WithEnrichment(lookup),
+				WithReshape("operationType -> op"),
+				WithFlatten(&Flatten{Separator: "_", Arrays: "index"}),
+				WithTypeConversions(&TypeConversions{ObjectId: "hex", Date: "rfc3339", Decimal: "string"}),
+				WithCanary(&Canary{Percent: 5, Subject: "SHADOW", Encoder: "json", ExcludeFields: []string{"email"}}),
+				WithSchemaVersion(2),
+				WithSnapshot("0 3 * * *", `{"status":"active"}`),
+				WithTransactions("tag"),
+				WithCollectionPipeline(WithPublishWorkers(8), WithEncoder("bson")),
 			),
 		)
 
@@ -159,6 +355,57 @@ func TestNew(t *testing.T) {
 			tokensCollCapped:             true,
 			tokensCollSizeInBytes:        collSizeInBytes,
 			streamName:                   streamName,
+			namespaceSubjects:            true,
+			partitions:                   8,
+			timeBucket:                   mongo.MonthTimeBucket,
+			tenantField:                  "org.tenant",
+			excludeFields:                []string{"password", "profile.avatar"},
+			collation:                    &mongo.Collation{Locale: "fr", Strength: 2},
+			splitLargeEvents:             true,
+			encryptedPassthrough:         true,
+			followRenames:                true,
+			stallTimeout:                 stallTimeout,
+			maxEventAge:                  time.Hour,
+			msgIdStrategy:                mongo.DocumentFieldMsgIdStrategy,
+			msgIdField:                   "code",
+			oversizedPolicy:              mongo.OffloadOversizedPolicy,
+			patch:                        mongo.JsonPatchFormat,
+			payloadMode:                  mongo.SlimPayloadMode,
+			jsonFlavor:                   mongo.SimplifiedJsonFlavor,
+			offloadBucket:                "coll1-offload",
+			quarantineCollName:           "coll1-quarantine",
+			decodeErrorPolicy:            mongo.SkipDecodeErrorPolicy,
+			missingImages:                mongo.PartialMissingImagesPolicy,
+			nackPolicy:                   nats.DlqNackPolicy,
+			errorPolicies: mongo.ErrorPolicies{mongo.TransformErrorClass: mongo.DlqErrorAction,
+				mongo.TokenSaveErrorClass: mongo.HaltErrorAction},
+			dlqSubject:       "COLL1_DLQ.nacked",
+			duplicatesWindow: time.Hour,
+			streamLimits:     &nats.StreamLimits{MaxMsgsPerSubject: 1, MaxAge: 24 * time.Hour},
+			publishMode:      nats.CorePublishMode,
+			expectStream:     true,
+			strictOrdering:   true,
+			ackTimeout:       5 * time.Second,
+			retryAttempts:    3,
+			retryWait:        time.Second,
+			msgTtl:           24 * time.Hour,
+			failureMode:      isolateFailureMode,
+			routes:           []mongo.Route{{When: map[string]string{"region": "eu"}, StreamName: "COLL1_EU"}},
+			filter: &mongo.Filter{Or: []mongo.Filter{
+				{Field: "fullDocument.status", Operator: mongo.NeFilterOperator, Value: "draft"},
+			}},
+			eventTypes: mongo.EventTypes{"delete": "order.removed"},
+			enrichment: enrichment,
+			reshape:    reshape,
+			flatten:    &mongo.Flatten{Separator: "_", Arrays: mongo.IndexArrayFlattening},
+			typeConversions: &mongo.Conversions{ObjectId: mongo.HexConversion, Date: mongo.Rfc3339Conversion,
+				Decimal: mongo.StringConversion},
+			canary: &mongo.Canary{Percent: 5, Subject: "SHADOW", Encoder: mongo.JsonEncoder,
+				ExcludeFields: []string{"email"}},
+			schemaVersion: 2,
+			snapshot:      &snapshot{schedule: snapshotSchedule, filter: `{"status":"active"}`},
+			transactions:  mongo.TagTransactionMode,
+			pipeline:      pipeline{publishWorkers: 8, batchSize: 100, rateLimit: 10, encoder: mongo.BsonEncoder},
 		})
 	})
 	t.Run("should return error cause dbName is missing", func(t *testing.T) {
@@ -193,319 +440,3332 @@ func TestNew(t *testing.T) {
 		require.Nil(t, conn)
 		require.EqualError(t, err, ErrInvalidCollSizeInBytes.Error())
 	})
-	t.Run("should return error cause tokens cannot be stored in the collection to be watched", func(t *testing.T) {
-		var (
-			dbName   = "test-db"
-			collName = "test-coll"
+	t.Run("should return error cause msgIdStrategy is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithMsgIdStrategy("unknown")),
 		)
 
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidMsgIdStrategy.Error())
+	})
+	t.Run("should return error cause msgIdField is missing", func(t *testing.T) {
 		conn, err := New(
-			WithCollection(dbName, collName, WithTokensDbName(dbName), WithTokensCollName(collName)),
+			WithCollection("test-db", "test-coll", WithMsgIdStrategy("documentField")),
 		)
 
 		require.Nil(t, conn)
-		require.EqualError(t, err, ErrInvalidDbAndCollNames.Error())
+		require.EqualError(t, err, ErrMsgIdFieldMissing.Error())
 	})
-}
+	t.Run("should return error cause oversizedPolicy is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithOversizedPolicy("unknown")),
+		)
 
-func TestConnector_Run(t *testing.T) {
-	t.Run("should run connector and ", func(t *testing.T) {
-		var (
-			mongoClient     = &mockMongoClient{}
-			natsClient      = &mockNatsClient{}
-			ctx, cancel     = context.WithCancel(context.Background())
-			dbName          = "connector-db"
-			collName        = "coll1"
-			tokensDbName    = "tokens-db"
-			tokensCollName  = "coll1-tokens"
-			collSizeInBytes = int64(2048)
-			streamName      = "coll1-stream"
-			subj            = "subj"
-			msgId           = "msgId"
-			data            = []byte("event")
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidOversizedPolicy.Error())
+	})
+	t.Run("should return error cause dlqSubject is missing", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithOversizedPolicy("dlq")),
 		)
-		defer cancel()
 
-		conn, _ := New(
-			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
-			withNatsClient(natsClient),   // avoid connecting to a real nats instance
-			WithServerAddr(":0"),
-			WithContext(ctx),
-			WithCollection(dbName, collName,
-				WithChangeStreamPreAndPostImages(),
-				WithTokensDbName(tokensDbName),
-				WithTokensCollName(tokensCollName),
-				WithTokensCollCapped(collSizeInBytes),
-				WithStreamName(streamName),
-			),
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrDlqSubjectMissing.Error())
+	})
+	t.Run("should return error cause offloadBucket is missing", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithOversizedPolicy("offload")),
 		)
 
-		errCh := make(chan error)
-		go func() {
-			errCh <- conn.Run()
-		}()
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrOffloadBucketMissing.Error())
+	})
+	t.Run("should return error cause the quarantine is the resume tokens collection", func(t *testing.T) {
+		for _, opts := range [][]CollectionOption{
+			{WithQuarantine("test-coll")},
+			{WithTokensDbName("test-db"), WithTokensCollName("test-tokens"), WithQuarantine("test-tokens")},
+			{WithTokensDbName("test-db"), WithTokensCollName("test-tokens"), WithQuarantine("test-coll")},
+		} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", opts...),
+			)
 
-		t.Run("create watchable collections", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
-					DbName:                       dbName,
-					CollName:                     collName,
-					Capped:                       false,
-					SizeInBytes:                  0,
-					ChangeStreamPreAndPostImages: true,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+			require.Nil(t, conn)
+			require.EqualError(t, err, ErrInvalidQuarantine.Error())
+		}
+	})
+	t.Run("should return error cause decodeErrorPolicy is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithDecodeErrorPolicy("unknown")),
+		)
 
-		t.Run("create resume tokens collections", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
-					DbName:                       tokensDbName,
-					CollName:                     tokensCollName,
-					Capped:                       true,
-					SizeInBytes:                  collSizeInBytes,
-					ChangeStreamPreAndPostImages: false,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidDecodeErrorPolicy.Error())
+	})
+	t.Run("should create connector with subject and header templates", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("test-db", "test-coll",
+				WithSubjectTemplate(`{{ .Collection | lower }}.{{ .OperationType }}`),
+				WithHeaderTemplates(map[string]string{"Document-Id": `{{ .DocumentId }}`}),
+			),
+		)
 
-		t.Run("add nats streams", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return natsClient.StreamWasAdded(nats.AddStreamOptions{
-					StreamName: streamName,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		require.NoError(t, err)
+		coll := conn.options.collections[0]
+		require.Equal(t, `{{ .Collection | lower }}.{{ .OperationType }}`, coll.subjectTemplate.String())
+		require.Len(t, coll.headerTemplates, 1)
+		require.Equal(t, `{{ .DocumentId }}`, coll.headerTemplates["Document-Id"].String())
+	})
+	t.Run("should return error cause subjectTemplate is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithSubjectTemplate(`{{ .Namespace }}`)),
+		)
 
-		t.Run("watch collections", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
-					WatchedDbName:          dbName,
-					WatchedCollName:        collName,
-					ResumeTokensDbName:     tokensDbName,
-					ResumeTokensCollName:   tokensCollName,
-					ResumeTokensCollCapped: true,
-					StreamName:             streamName,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidSubjectTemplate)
+	})
+	t.Run("should return error cause subjectTemplate is combined with subject tokens", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithSubjectTemplate(`{{ .Collection }}`), WithPartitions(4)),
+		)
 
-		t.Run("publish change event messages", func(t *testing.T) {
-			mongoClient.SimulateChangeEvents(subj, msgId, data)
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrSubjectTemplateConflict.Error())
+	})
+	t.Run("should return error cause a header template is invalid", func(t *testing.T) {
+		for _, headerTemplates := range []map[string]string{
+			{"Nats-Msg-Id": `{{ .DocumentId }}`},
+			{"Connector-Instance-Id": `{{ .DocumentId }}`},
+			{"Document Id": `{{ .DocumentId }}`},
+			{"Document-Id": `{{ .DocumentId `},
+		} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithHeaderTemplates(headerTemplates)),
+			)
 
-			require.Eventually(t, func() bool {
-				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: msgId, Data: data})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidHeaderTemplate)
+		}
+	})
+	t.Run("should return error cause a route is invalid", func(t *testing.T) {
+		for _, opt := range []CollectionOption{
+			WithRoute("", map[string]string{"region": "eu"}, ""),
+			WithRoute("COLL_EU", nil, ""),
+			WithRoute("COLL_EU", map[string]string{"": "eu"}, ""),
+		} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", opt),
+			)
 
-		t.Run("shut down and close clients when context is cancelled", func(t *testing.T) {
-			cancel() // stop the connector by canceling context
-			err := <-errCh
-			require.NotNil(t, err)
-			require.True(t, mongoClient.closed)
-			require.True(t, natsClient.closed)
-		})
+			require.Nil(t, conn)
+			require.EqualError(t, err, ErrInvalidRoute.Error())
+		}
 	})
-	t.Run("should stop connector and return error if collection creation fails", func(t *testing.T) {
-		var (
-			createCollErr = errors.New("create collection error")
-			mongoClient   = &mockMongoClient{
-				createCollectionErr: createCollErr,
-			}
-			natsClient      = &mockNatsClient{}
-			ctx, cancel     = context.WithCancel(context.Background())
-			dbName          = "connector-db"
-			collName        = "coll1"
-			tokensDbName    = "tokens-db"
-			tokensCollName  = "coll1-tokens"
-			collSizeInBytes = int64(2048)
-			streamName      = "coll1-stream"
+	t.Run("should return error cause the subject template of a route is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll",
+				WithRoute("COLL_EU", map[string]string{"region": "eu"}, `{{ .Namespace }}`)),
 		)
-		defer cancel()
 
-		conn, _ := New(
-			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
-			withNatsClient(natsClient),   // avoid connecting to a real nats instance
-			WithContext(ctx),
-			WithCollection(dbName, collName,
-				WithChangeStreamPreAndPostImages(),
-				WithTokensDbName(tokensDbName),
-				WithTokensCollName(tokensCollName),
-				WithTokensCollCapped(collSizeInBytes),
-				WithStreamName(streamName),
-			),
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidSubjectTemplate)
+	})
+	t.Run("should return error cause the filter is invalid", func(t *testing.T) {
+		for _, filter := range []*Filter{
+			{},
+			{Field: "fullDocument.status", Operator: "regex"},
+			{And: []Filter{{Field: "fullDocument.status", Operator: "exists", Value: "yes"}}},
+		} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithFilter(filter)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidFilter)
+		}
+	})
+	t.Run("should return error cause the event types are invalid", func(t *testing.T) {
+		for _, eventTypes := range []map[string]string{{"drop": "order.dropped"}, {"delete": "order.>"}} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithEventTypes(eventTypes)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidEventTypes)
+		}
+	})
+	t.Run("should return error cause the json flavor is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithJsonFlavor("strict")),
 		)
 
-		err := conn.Run()
-		require.ErrorIs(t, err, createCollErr)
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidJsonFlavor)
 	})
-	t.Run("should stop connector and return error if stream add fails", func(t *testing.T) {
-		var (
-			addStreamErr = errors.New("add stream error")
-			mongoClient  = &mockMongoClient{}
-			natsClient   = &mockNatsClient{
-				addStreamErr: addStreamErr,
-			}
-			ctx, cancel     = context.WithCancel(context.Background())
-			dbName          = "connector-db"
-			collName        = "coll1"
-			tokensDbName    = "tokens-db"
-			tokensCollName  = "coll1-tokens"
-			collSizeInBytes = int64(2048)
-			streamName      = "coll1-stream"
+	t.Run("should return error cause type conversions are combined with the canonical json flavor", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithJsonFlavor("canonical"),
+				WithTypeConversions(&TypeConversions{ObjectId: "hex"})),
 		)
-		defer cancel()
 
-		conn, _ := New(
-			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
-			withNatsClient(natsClient),   // avoid connecting to a real nats instance
-			WithContext(ctx),
-			WithCollection(dbName, collName,
-				WithChangeStreamPreAndPostImages(),
-				WithTokensDbName(tokensDbName),
-				WithTokensCollName(tokensCollName),
-				WithTokensCollCapped(collSizeInBytes),
-				WithStreamName(streamName),
-			),
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrTypeConversionsConflict.Error())
+	})
+	t.Run("should return error cause idempotent resume is not published to jetstream", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithPublishMode("core"), WithIdempotentResume()),
 		)
 
-		err := conn.Run()
-		require.ErrorIs(t, err, addStreamErr)
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrIdempotentResumeConflict.Error())
 	})
-}
+	t.Run("should return error cause the patch format is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithPatch("strategic")),
+		)
 
-type mockMongoClient struct {
-	closed     bool
-	name       string
-	monitorErr error
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidPatch)
+	})
+	t.Run("should return error cause the payload mode is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithPayloadMode("compact")),
+		)
 
-	muc                  sync.Mutex
-	createCollectionOpts []mongo.CreateCollectionOptions
-	createCollectionErr  error
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidPayloadMode)
+	})
+	t.Run("should return error cause the reshape is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithReshape("operationType")),
+		)
 
-	muw                 sync.Mutex
-	watchCollectionOpts []mongo.WatchCollectionOptions
-	watchCollectionErr  error
-}
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidReshape)
+	})
+	t.Run("should return error cause the flatten is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithFlatten(&Flatten{Arrays: "explode"})),
+		)
 
-func (m *mockMongoClient) Close() error {
-	m.closed = true
-	return nil
-}
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidFlatten)
+	})
+	t.Run("should return error cause the envelope is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithEnvelope(&Envelope{MissingBefore: "dlq"})),
+		)
 
-func (m *mockMongoClient) Name() string {
-	return m.name
-}
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidEnvelope)
+	})
+	t.Run("should return error cause the envelope is combined with a patch", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithEnvelope(&Envelope{}), WithPatch("json")),
+		)
 
-func (m *mockMongoClient) Monitor(_ context.Context) error {
-	return m.monitorErr
-}
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrEnvelopeConflict.Error())
+	})
+	t.Run("should return error cause the type conversions are invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithTypeConversions(&TypeConversions{Long: "hex"})),
+		)
 
-func (m *mockMongoClient) CreateCollection(_ context.Context, opts *mongo.CreateCollectionOptions) error {
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidTypeConversions)
+	})
+	t.Run("should return error cause the enrichment is invalid", func(t *testing.T) {
+		for _, stage := range []string{`{"$match":{"status":"paid"}}`, `{"$lookup"`} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithEnrichment(stage)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidEnrichment)
+		}
+	})
+	t.Run("should return error cause the schema version is negative", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithSchemaVersion(-1)),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidSchemaVersion)
+	})
+	t.Run("should return error cause the canary is invalid", func(t *testing.T) {
+		for _, canary := range []*Canary{
+			{Percent: 101, Subject: "SHADOW"},
+			{Percent: 5},
+			{Percent: 5, Subject: "SHADOW.>"},
+			{Percent: 5, Subject: "SHADOW", Encoder: "avro"},
+			{Percent: 5, Subject: "SHADOW", ExcludeFields: []string{""}},
+		} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithCanary(canary)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidCanary)
+		}
+	})
+	t.Run("should return error cause the snapshot is invalid", func(t *testing.T) {
+		for _, s := range []struct{ schedule, filter string }{
+			{schedule: "", filter: `{"status":"active"}`},
+			{schedule: "0 3 * *"},
+			{schedule: "@daily", filter: `{"status"`},
+		} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithSnapshot(s.schedule, s.filter)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidSnapshot)
+		}
+	})
+	t.Run("should return error cause the watermark is invalid", func(t *testing.T) {
+		for _, w := range []struct {
+			subject  string
+			interval time.Duration
+		}{
+			{interval: time.Second},
+			{subject: "WATERMARKS.*"},
+			{subject: "WATERMARKS", interval: -time.Second},
+		} {
+			conn, err := New(WithWatermark(w.subject, w.interval))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidWatermark)
+		}
+	})
+	t.Run("should return error cause the events are invalid", func(t *testing.T) {
+		for _, e := range []struct {
+			subjectPrefix string
+			tokenInterval time.Duration
+		}{
+			{subjectPrefix: "EVENTS.>"},
+			{subjectPrefix: "EVENTS..connector"},
+			{subjectPrefix: "EVENTS", tokenInterval: -time.Second},
+		} {
+			conn, err := New(WithEvents(e.subjectPrefix, e.tokenInterval))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidEvents)
+		}
+	})
+	t.Run("should return error cause the control subject is invalid", func(t *testing.T) {
+		for _, subject := range []string{"CONTROL.>", "CONTROL..connector"} {
+			conn, err := New(WithControlSubject(subject), WithControlUnauthenticated())
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidControl)
+		}
+	})
+	t.Run("should return error cause the control commands are not authenticated", func(t *testing.T) {
+		conn, err := New(WithControlSubject(""))
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrUnauthenticatedControl)
+	})
+	t.Run("should return error cause the oplog warning is invalid", func(t *testing.T) {
+		for _, w := range []struct {
+			headroom   time.Duration
+			webhookUrl string
+		}{
+			{headroom: -time.Hour},
+			{webhookUrl: "alerts.example.com/oplog"},
+			{webhookUrl: "ftp://alerts.example.com/oplog"},
+		} {
+			conn, err := New(WithOplogWarning(w.headroom, w.webhookUrl))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidOplogWarning)
+		}
+	})
+	t.Run("should return error cause the retry policy is invalid", func(t *testing.T) {
+		for _, policy := range []*RetryPolicy{
+			{MaxElapsedTime: -time.Minute},
+			{InitialInterval: time.Minute, MaxInterval: time.Second},
+			{Multiplier: 0.5},
+			{Jitter: "random"},
+			{RetryOn: []string{"timeout"}},
+		} {
+			conn, err := New(WithRetryPolicy(policy))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidRetryPolicy)
+		}
+	})
+	t.Run("should return error cause devgen is invalid", func(t *testing.T) {
+		for _, devGen := range []*DevGen{
+			{},
+			{Rate: 10, Ops: map[string]int{"drop": 1}},
+			{Rate: 10, Template: `{"amount": "{{ amount }}"}`},
+		} {
+			conn, err := New(WithDevGen(devGen))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidDevGen)
+		}
+	})
+	t.Run("should return error cause the metrics exporter is invalid", func(t *testing.T) {
+		conn, err := New(WithMetricsExporter("graphite"))
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidMetricsExporter)
+	})
+	t.Run("should return error cause server addresses are invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithServerAddr("localhost"),
+			WithServerAddr("127.0.0.1:8080,unix:"),
+			WithServerAdminAddr(" , "),
+			WithServerGrpcAddr("grpc"),
+		} {
+			conn, err := New(opt)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidServerAddr)
+		}
+	})
+	t.Run("should return error cause server limits are invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithServerTimeouts(-time.Second, 0, 0),
+			WithServerTimeouts(0, -time.Second, 0),
+			WithServerTimeouts(0, 0, -time.Second),
+			WithServerMaxHeaderBytes(-1),
+			WithServerShutdownTimeout(-time.Second),
+		} {
+			conn, err := New(opt)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidServerLimits)
+		}
+	})
+	t.Run("should return error cause server auth is invalid", func(t *testing.T) {
+		emptyFile := filepath.Join(t.TempDir(), "tokens")
+		require.NoError(t, os.WriteFile(emptyFile, []byte("# none yet\n"), 0o600))
+		for _, opt := range []Option{
+			WithServerBearerTokensFile(emptyFile),
+			WithServerBearerTokensFile(filepath.Join(t.TempDir(), "missing")),
+			WithServerOidc("sso.example.com", "connector"),
+			WithServerOidc("https://sso.example.com", ""),
+		} {
+			conn, err := New(opt)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidServerAuth)
+		}
+	})
+	t.Run("should return error cause health deadline is invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithHealthDeadline("redis", time.Second, 0),
+			WithHealthDeadline("nats", -time.Second, 0),
+			WithHealthDeadline("nats", 0, -time.Second),
+		} {
+			conn, err := New(opt)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidHealthDeadline)
+		}
+	})
+	t.Run("should return error cause log sampling is invalid", func(t *testing.T) {
+		for _, s := range []struct {
+			first, thereafter int
+			interval          time.Duration
+		}{
+			{first: -1},
+			{thereafter: -1},
+			{interval: -time.Second},
+		} {
+			conn, err := New(WithLogSampling(s.first, s.thereafter, s.interval))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidLogSampling)
+		}
+	})
+	t.Run("should return error cause statsd is invalid", func(t *testing.T) {
+		for _, s := range []struct {
+			addr     string
+			interval time.Duration
+		}{
+			{addr: "datadog-agent"},
+			{interval: -time.Second},
+		} {
+			conn, err := New(WithStatsd(s.addr, "", s.interval))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidStatsd)
+		}
+	})
+	t.Run("should return error cause the pushgateway is invalid", func(t *testing.T) {
+		conn, err := New(WithPushgateway("pushgateway:9091", ""))
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidPushgateway)
+	})
+	t.Run("should return error cause the status bucket is invalid", func(t *testing.T) {
+		for _, s := range []struct {
+			bucket   string
+			interval time.Duration
+		}{
+			{interval: time.Second},
+			{bucket: "CONNECTOR.STATUS"},
+			{bucket: "CONNECTOR_STATUS", interval: -time.Second},
+		} {
+			conn, err := New(WithStatusBucket(s.bucket, s.interval))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidStatusBucket)
+		}
+	})
+	t.Run("should return error cause the watcher states bucket is invalid", func(t *testing.T) {
+		conn, err := New(WithWatcherStatesBucket("WATCHER.STATES"))
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidWatcherStates)
+	})
+	t.Run("should return error cause the token backup is invalid", func(t *testing.T) {
+		for _, b := range []struct {
+			bucket   string
+			path     string
+			interval time.Duration
+		}{
+			{interval: time.Minute},
+			{bucket: "resume-token-backups", path: "/var/backups/tokens.json"},
+			{bucket: "RESUME.TOKENS"},
+			{path: "/var/backups/tokens.json", interval: -time.Minute},
+		} {
+			conn, err := New(WithTokenBackup(b.bucket, b.path, b.interval))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidTokenBackup)
+		}
+	})
+	t.Run("should return error cause the publisher guard is invalid", func(t *testing.T) {
+		for _, g := range []struct {
+			bucket   string
+			interval time.Duration
+		}{
+			{interval: time.Second},
+			{bucket: "PUBLISHER.LEASES"},
+			{bucket: "publisher-leases", interval: -time.Second},
+		} {
+			conn, err := New(WithPublisherGuard(g.bucket, g.interval))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidPublisherGuard)
+		}
+	})
+	t.Run("should return error cause the token backup replica has no bucket", func(t *testing.T) {
+		conn, err := New(WithTokenBackup("", "/var/backups/tokens.json", 0),
+			WithTokenBackupReplica("nats://nats.dr.example.com:4222"))
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidTokenReplica)
+	})
+	t.Run("should return error cause a webhook is invalid", func(t *testing.T) {
+		for _, w := range []struct {
+			url    string
+			format string
+			alerts []string
+		}{
+			{url: ""},
+			{url: "ftp://alerts.example.com/connector"},
+			{url: "https://alerts.example.com/connector", format: "xml"},
+			{url: "https://alerts.example.com/connector", alerts: []string{"watcherStopped"}},
+		} {
+			conn, err := New(WithWebhook(w.url, w.format, w.alerts...))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidWebhook)
+		}
+	})
+	t.Run("should return error cause transactions is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithTransactions("group")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidTransactions.Error())
+	})
+	t.Run("should create a connector watching the files of a GridFS bucket", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("test-db", "fs.files", WithGridFS("FS")),
+		)
+
+		require.NoError(t, err)
+		require.True(t, conn.options.collections[0].gridFs)
+		require.Equal(t, "FS", conn.options.collections[0].gridFsObjectBucket)
+	})
+	t.Run("should return error cause gridFs is set on another collection than a files collection", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "fs.chunks", WithGridFS("")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidGridFS.Error())
+	})
+	t.Run("should return error cause failureMode is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithFailureMode("unknown")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidFailureMode.Error())
+	})
+	t.Run("should return error cause stream limits are negative", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithStreamLimits(&StreamLimits{MaxMsgsPerSubject: -1})),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidStreamLimits.Error())
+	})
+	t.Run("should return error cause nackPolicy is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithNackPolicy("retry")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidNackPolicy.Error())
+	})
+	t.Run("should return error cause dlqSubject is missing for nacked change events", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithNackPolicy("dlq")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrNackDlqSubjectMissing.Error())
+	})
+	t.Run("should return error cause errorPolicies is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithErrorPolicies(map[string]string{"decode": "retry"})),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidErrorPolicies)
+	})
+	t.Run("should return error cause dlqSubject is missing for the errors published to it", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithErrorPolicies(map[string]string{"publishTimeout": "dlq"})),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrErrorDlqSubjectMissing.Error())
+	})
+	t.Run("should override the decode error policy with the decode error action", func(t *testing.T) {
+		opts := getDefaultOptions()
+
+		err := WithCollection("test-db", "test-coll", WithErrorPolicies(map[string]string{"decode": "skip"}),
+			WithDecodeErrorPolicy("halt"))(&opts)
+
+		require.NoError(t, err)
+		require.Equal(t, mongo.SkipDecodeErrorPolicy, opts.collections[0].decodeErrorPolicy)
+	})
+	t.Run("should return error cause dlqSubject is missing for decode errors", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithDecodeErrorPolicy("dlq")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrDecodeDlqSubjectMissing.Error())
+	})
+	t.Run("should return error cause missingImages is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithMissingImages("skip")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidMissingImages.Error())
+	})
+	t.Run("should return error cause dlqSubject is missing for missing images", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithMissingImages("dlq")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrImagesDlqSubjectMissing.Error())
+	})
+	t.Run("should return error cause publishMode is invalid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithPublishMode("unknown")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidPublishMode.Error())
+	})
+	t.Run("should return error cause pipeline options are invalid", func(t *testing.T) {
+		invalidOpts := map[error]PipelineOption{
+			ErrInvalidPublishWorkers: WithPublishWorkers(0),
+			ErrInvalidBatchSize:      WithBatchSize(-1),
+			ErrInvalidRateLimit:      WithRateLimit(0),
+			ErrInvalidEncoder:        WithEncoder("xml"),
+		}
+
+		for wantErr, opt := range invalidOpts {
+			conn, err := New(WithPipeline(opt))
+			require.Nil(t, conn)
+			require.EqualError(t, err, wantErr.Error())
+
+			conn, err = New(WithCollection("test-db", "test-coll", WithCollectionPipeline(opt)))
+			require.Nil(t, conn)
+			require.EqualError(t, err, wantErr.Error())
+		}
+	})
+	t.Run("should return error cause partitions are negative", func(t *testing.T) {
+		conn, err := New(WithCollection("test-db", "test-coll", WithPartitions(-1)))
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidPartitions.Error())
+	})
+	t.Run("should return error cause timeBucket is invalid", func(t *testing.T) {
+		conn, err := New(WithCollection("test-db", "test-coll", WithTimeBucket("week")))
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidTimeBucket.Error())
+	})
+	t.Run("should return error cause nats tls key file is missing", func(t *testing.T) {
+		conn, err := New(WithNatsTlsFiles("/etc/nats/tls.crt", "", ""))
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidNatsTlsFiles.Error())
+	})
+	t.Run("should return error cause mongo auto-encryption is invalid", func(t *testing.T) {
+		kmsProviders := map[string]map[string]string{"local": {"key": "a2V5"}}
+		for _, opt := range []Option{
+			WithMongoAutoEncryption("", kmsProviders),
+			WithMongoAutoEncryption("__keyVault", kmsProviders),
+			WithMongoAutoEncryption("encryption.__keyVault", nil),
+		} {
+			conn, err := New(opt)
+
+			require.Nil(t, conn)
+			require.EqualError(t, err, ErrInvalidAutoEncryption.Error())
+		}
+	})
+	t.Run("should return error cause tenant is invalid", func(t *testing.T) {
+		conn, err := New(WithTenant("acme", ""))
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidTenant.Error())
+	})
+	t.Run("should return error cause tenant routing is ambiguous", func(t *testing.T) {
+		conn, err := New(WithCollection("test-db", "test-coll", WithTenantField("tenant"), WithTenantDbName()))
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidTenantRouting.Error())
+	})
+	t.Run("should return error cause collation locale is missing", func(t *testing.T) {
+		conn, err := New(WithCollection("test-db", "test-coll", WithCollation("", 2)))
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrCollationLocaleMissing.Error())
+	})
+	t.Run("should return error cause collation strength is invalid", func(t *testing.T) {
+		conn, err := New(WithCollection("test-db", "test-coll", WithCollation("fr", 6)))
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidCollation.Error())
+	})
+	t.Run("should return error cause excluded fields are invalid", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []CollectionOption
+		}{
+			{name: "empty field", opts: []CollectionOption{WithExcludeFields("password", "")}},
+			{name: "tenant field", opts: []CollectionOption{WithTenantField("org.tenant"), WithExcludeFields("org")}},
+			{name: "msg id field", opts: []CollectionOption{WithMsgIdStrategy("documentField"), WithMsgIdField("code"),
+				WithExcludeFields("code")}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				conn, err := New(WithCollection("test-db", "test-coll", tt.opts...))
+
+				require.Nil(t, conn)
+				require.EqualError(t, err, ErrInvalidExcludeFields.Error())
+			})
+		}
+	})
+	t.Run("should return error cause tenant of the database is not configured", func(t *testing.T) {
+		conn, err := New(
+			withTenantNatsClient("acme", &mockNatsClient{}),
+			WithCollection("globex", "test-coll", WithTenantDbName()),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrUnknownTenant)
+	})
+	t.Run("should return error cause label keys are invalid", func(t *testing.T) {
+		for _, key := range []string{"", "1env", "env-name", "instance_id"} {
+			conn, err := New(WithLabels(map[string]string{key: "value"}))
+
+			require.Nil(t, conn)
+			require.EqualError(t, err, ErrInvalidLabel.Error())
+		}
+	})
+	t.Run("should return error cause journalSize is not positive", func(t *testing.T) {
+		conn, err := New(
+			WithJournalSize(0),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidJournalSize.Error())
+	})
+	t.Run("should return error cause tokens cannot be stored in the collection to be watched", func(t *testing.T) {
+		var (
+			dbName   = "test-db"
+			collName = "test-coll"
+		)
+
+		conn, err := New(
+			WithCollection(dbName, collName, WithTokensDbName(dbName), WithTokensCollName(collName)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidDbAndCollNames.Error())
+	})
+	t.Run("should name the resume tokens collection after the collection to be watched", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("shop", "orders", WithTokensCollName("{db}.{coll}.tokens")),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, "shop.orders.tokens", conn.options.collections[0].tokensCollName)
+	})
+	t.Run("should return error cause the resume tokens collection expiry is invalid", func(t *testing.T) {
+		for _, opts := range [][]CollectionOption{
+			{WithTokensCollTtl(0)},
+			{WithTokensCollTtl(-time.Hour)},
+			{WithTokensCollCapped(4096), WithTokensCollTtl(time.Hour)},
+		} {
+			conn, err := New(WithCollection("test-db", "test-coll", opts...))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidTokensCollTtl)
+		}
+	})
+}
+
+func TestConnector_Run(t *testing.T) {
+	t.Run("should run connector and ", func(t *testing.T) {
+		var (
+			mongoClient     = &mockMongoClient{}
+			natsClient      = &mockNatsClient{}
+			ctx, cancel     = context.WithCancel(context.Background())
+			dbName          = "connector-db"
+			collName        = "coll1"
+			tokensDbName    = "tokens-db"
+			tokensCollName  = "coll1-tokens"
+			collSizeInBytes = int64(2048)
+			streamName      = "coll1-stream"
+			subj            = "subj"
+			msgId           = "msgId"
+			data            = []byte("event")
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, collName,
+				WithChangeStreamPreAndPostImages(),
+				WithTokensDbName(tokensDbName),
+				WithTokensCollName(tokensCollName),
+				WithTokensCollCapped(collSizeInBytes),
+				WithStreamName(streamName),
+				WithDuplicatesWindow(10*time.Minute),
+			),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		t.Run("create watchable collections", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+					DbName:                       dbName,
+					CollName:                     collName,
+					Capped:                       false,
+					SizeInBytes:                  0,
+					ChangeStreamPreAndPostImages: true,
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("create resume tokens collections", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+					DbName:                       tokensDbName,
+					CollName:                     tokensCollName,
+					Capped:                       true,
+					SizeInBytes:                  collSizeInBytes,
+					ChangeStreamPreAndPostImages: false,
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("add nats streams", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return natsClient.StreamWasAdded(nats.AddStreamOptions{
+					StreamName: streamName,
+					Subject:    streamName + ".*",
+					Duplicates: 10 * time.Minute,
+					ReplaySpan: 10*time.Second + 1*time.Minute,
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("watch collections", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+					WatchedDbName:          dbName,
+					WatchedCollName:        collName,
+					ResumeTokensDbName:     tokensDbName,
+					ResumeTokensCollName:   tokensCollName,
+					ResumeTokensCollCapped: true,
+					StreamName:             streamName,
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish change event messages", func(t *testing.T) {
+			mongoClient.SimulateChangeEvents(subj, msgId, data)
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: msgId, Data: data,
+					Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("retry publishing change event messages once nats is reconnected", func(t *testing.T) {
+			natsClient.mup.Lock()
+			natsClient.reconnectingPublishes = 2
+			waitConnectedCalls := natsClient.waitConnectedCalls
+			natsClient.mup.Unlock()
+
+			mongoClient.SimulateChangeEvents(subj, "msgId2", data)
+
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgId2", Data: data})
+			}, 1*time.Second, 100*time.Millisecond)
+			natsClient.mup.Lock()
+			defer natsClient.mup.Unlock()
+			require.Equal(t, waitConnectedCalls+3, natsClient.waitConnectedCalls)
+		})
+
+		t.Run("retry publishing change event messages once the stream accepts them again", func(t *testing.T) {
+			natsClient.mup.Lock()
+			natsClient.backpressuredPublishes = 2
+			natsClient.mup.Unlock()
+
+			mongoClient.SimulateChangeEvents(subj, "msgId3", data)
+
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgId3", Data: data})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("retry publishing change event messages once the stream acknowledges them in time", func(t *testing.T) {
+			natsClient.mup.Lock()
+			natsClient.ackTimeoutPublishes = 2
+			natsClient.mup.Unlock()
+
+			mongoClient.SimulateChangeEvents(subj, "msgId4", data)
+
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgId4", Data: data})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("record published change events in the journal", func(t *testing.T) {
+			entries := conn.journal.Entries()[dbName+"."+collName]
+			require.NotEmpty(t, entries)
+			require.Equal(t, subj, entries[0].Subj)
+			require.Equal(t, "msgId4", entries[0].MsgId)
+			require.Equal(t, len(data), entries[0].Size)
+			require.Equal(t, server.JournalResultPublished, entries[0].Result)
+		})
+
+		t.Run("report the status of the watched collections", func(t *testing.T) {
+			status := conn.status.Collections()[dbName+"."+collName]
+			require.Equal(t, server.CollectionStateRunning, status.State)
+			require.Equal(t, int64(4), status.EventsPublished)
+		})
+
+		t.Run("publish change event messages with their headers", func(t *testing.T) {
+			instanceHeaders := maps.Clone(conn.headers)
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdHdr", Data: data,
+				Headers: map[string]string{"Document-Id": "order-1"}})
+
+			wantHeaders := maps.Clone(instanceHeaders)
+			wantHeaders["Document-Id"] = "order-1"
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdHdr", Data: data,
+					Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+			require.Equal(t, instanceHeaders, conn.headers)
+		})
+
+		t.Run("publish encrypted change event messages with the encrypted header", func(t *testing.T) {
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdEnc", Data: data,
+				Encrypted: true})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[encryptedHdr] = "true"
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdEnc", Data: data,
+					Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+			require.NotContains(t, conn.headers, encryptedHdr)
+		})
+
+		t.Run("publish partial change event messages with the partial header", func(t *testing.T) {
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdPartial", Data: data,
+				Partial: true})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[partialHdr] = "true"
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdPartial",
+					Data: data, Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish change event messages with the schema version of their subject", func(t *testing.T) {
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "v2." + subj, MsgId: "msgIdV2", Data: data,
+				SchemaVersion: 2})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "2"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: "v2." + subj, MsgId: "msgIdV2",
+					Data: data, Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish change event messages with the transaction they belong to", func(t *testing.T) {
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdTxn", Data: data,
+				Transaction: &mongo.Transaction{Id: "abcd:7", Index: 2, Total: 3}})
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdTxnBatch", Data: data,
+				Transaction: &mongo.Transaction{Id: "abcd:8", Total: 3}})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			wantHeaders[txnIdHdr] = "abcd:7"
+			wantHeaders[txnIndexHdr] = "2"
+			wantHeaders[txnTotalHdr] = "3"
+			wantBatchHeaders := maps.Clone(wantHeaders)
+			wantBatchHeaders[txnIdHdr] = "abcd:8"
+			delete(wantBatchHeaders, txnIndexHdr)
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdTxn", Data: data,
+					Headers: wantHeaders}) &&
+					natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdTxnBatch", Data: data,
+						Headers: wantBatchHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish change event messages with their document key", func(t *testing.T) {
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdDocumentKey", Data: data,
+				DocumentKey: `{"_id":"order-1","region":"eu"}`})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			wantHeaders[documentKeyHdr] = `{"_id":"order-1","region":"eu"}`
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdDocumentKey",
+					Data: data, Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish change event messages with their event type", func(t *testing.T) {
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdEventType", Data: data,
+				EventType: "order.removed"})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			wantHeaders[eventTypeHdr] = "order.removed"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdEventType",
+					Data: data, Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish slim change event messages with their bookkeeping fields", func(t *testing.T) {
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdSlim", Data: data,
+				Bookkeeping: &mongo.Bookkeeping{Namespace: "shop.orders",
+					ClusterTime: primitive.Timestamp{T: 1714564800, I: 3},
+					WallTime:    time.Date(2024, 5, 1, 12, 0, 0, 5, time.UTC), ResumeToken: "8264"}})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			wantHeaders[namespaceHdr] = "shop.orders"
+			wantHeaders[clusterTimeHdr] = "1714564800:3"
+			wantHeaders[wallTimeHdr] = "2024-05-01T12:00:00.000000005Z"
+			wantHeaders[resumeTokenHdr] = "8264"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdSlim", Data: data,
+					Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish change event messages with the session they originate from", func(t *testing.T) {
+			txnNumber := int64(7)
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdSession", Data: data,
+				Session: &mongo.Session{Lsid: "abcd", TxnNumber: &txnNumber,
+					ClusterTime: primitive.Timestamp{T: 1714564800, I: 2}}})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[contentTypeHdr] = "application/json"
+			wantHeaders[schemaVersionHdr] = "1"
+			wantHeaders[lsidHdr] = "abcd"
+			wantHeaders[txnNumberHdr] = "7"
+			wantHeaders[clusterTimeHdr] = "1714564800:2"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdSession", Data: data,
+					Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish the shadow of change event messages to core nats", func(t *testing.T) {
+			shadowData := []byte(`{"shadow":true}`)
+			_ = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: "msgIdCanary", Data: data,
+				Shadow: &mongo.Shadow{Subj: "SHADOW." + subj, Data: shadowData, ContentType: "application/bson"}})
+
+			wantHeaders := maps.Clone(conn.headers)
+			wantHeaders[shadowOfHdr] = "msgIdCanary"
+			wantHeaders[contentTypeHdr] = "application/bson"
+			wantHeaders[schemaVersionHdr] = "1"
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: "msgIdCanary", Data: data}) &&
+					natsClient.MessageWasPublished(nats.PublishOptions{Subj: "SHADOW." + subj, Data: shadowData,
+						Mode: nats.CorePublishMode, Headers: wantHeaders})
+			}, 1*time.Second, 100*time.Millisecond)
+			require.NotContains(t, conn.headers, shadowOfHdr)
+		})
+
+		t.Run("bind the streams to the subjects of the new schema version before a cutover", func(t *testing.T) {
+			cutoverCtx, cancelCutover := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancelCutover()
+
+			// the mock watcher never applies the cutover, so it is cancelled once the context is done
+			err := conn.Cutover(cutoverCtx, dbName+"."+collName, 2)
+
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			require.True(t, natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: streamName,
+				Subject:    "v2." + streamName + ".*",
+				Duplicates: 10 * time.Minute,
+				ReplaySpan: 10*time.Second + 1*time.Minute,
+			}))
+			require.ErrorIs(t, conn.Cutover(context.Background(), "test-db.unknown", 2), server.ErrCollectionNotFound)
+			require.ErrorIs(t, conn.Cutover(context.Background(), dbName+"."+collName, 0), server.ErrInvalidSchemaVersion)
+		})
+
+		t.Run("shut down cleanly and close clients when context is cancelled", func(t *testing.T) {
+			cancel() // stop the connector by canceling context
+			err := <-errCh
+			require.NoError(t, err)
+			require.True(t, mongoClient.closed)
+			require.True(t, natsClient.closed)
+		})
+	})
+	t.Run("should provision an expiring resume tokens collection", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{watchUntilCancelled: true}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithTokensCollTtl(24*time.Hour)),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.WatchCollectionCalls() == 1
+		}, 1*time.Second, 10*time.Millisecond)
+
+		require.True(t, mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+			DbName:   defaultTokensDbName,
+			CollName: "coll1",
+			Expiring: true,
+		}))
+		mongoClient.muw.Lock()
+		require.Equal(t, 24*time.Hour, mongoClient.watchCollectionOpts[0].ResumeTokensCollTtl)
+		mongoClient.muw.Unlock()
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+	t.Run("should force shutdown if in-flight change events are not drained in time", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{watchCollectionBlock: make(chan struct{})}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+		defer close(mongoClient.watchCollectionBlock)
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithShutdownTimeout(100*time.Millisecond),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:        "connector-db",
+				WatchedCollName:      "coll1",
+				ResumeTokensDbName:   "resume-tokens",
+				ResumeTokensCollName: "coll1",
+				StreamName:           "COLL1",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		err := <-errCh
+		require.ErrorIs(t, err, ErrForcedShutdown)
+		require.True(t, mongoClient.closed)
+		require.True(t, natsClient.closed)
+	})
+	t.Run("should keep serving until the in-flight change events are drained", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{watchCollectionBlock: make(chan struct{})}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			healthz     = "http://127.0.0.1:8087/healthz"
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr("127.0.0.1:8087"),
+			WithServerShutdownTimeout(time.Second),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			res, err := http.Get(healthz)
+			if err == nil {
+				_ = res.Body.Close()
+			}
+			return err == nil
+		}, 1*time.Second, 10*time.Millisecond)
+
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+		res, err := http.Get(healthz)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+
+		close(mongoClient.watchCollectionBlock)
+		require.NoError(t, <-errCh)
+		_, err = http.Get(healthz)
+		require.Error(t, err)
+	})
+	t.Run("should notify systemd once the watchers are started and when stopping", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "connector")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		socket, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"})
+		require.NoError(t, err)
+		defer socket.Close()
+		t.Setenv("NOTIFY_SOCKET", socket.LocalAddr().String())
+		t.Setenv("WATCHDOG_USEC", "20000")
+		t.Setenv("WATCHDOG_PID", "")
+		next := func() string {
+			buf := make([]byte, 64)
+			_ = socket.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := socket.Read(buf)
+			require.NoError(t, err)
+			return string(buf[:n])
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		conn, _ := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithSystemdNotify(),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+		)
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Equal(t, "READY=1", next())
+		require.Equal(t, "WATCHDOG=1", next())
+		cancel()
+		require.NoError(t, <-errCh)
+		state := next()
+		for state == "WATCHDOG=1" {
+			state = next()
+		}
+		require.Equal(t, "STOPPING=1", state)
+	})
+	t.Run("should return error cause the server cannot listen", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+
+		conn, _ := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithServerAddr(l.Addr().String()),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		err = conn.Run()
+
+		require.ErrorContains(t, err, "address already in use")
+	})
+	t.Run("should not add nats streams for collections published to core nats", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithPublishMode("core")),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:        "connector-db",
+				WatchedCollName:      "coll1",
+				ResumeTokensDbName:   "resume-tokens",
+				ResumeTokensCollName: "coll1",
+				StreamName:           "COLL1",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		mongoClient.SimulateChangeEvents("COLL1.insert", "msgId", []byte("event"))
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{Subj: "COLL1.insert", MsgId: "msgId",
+			Data: []byte("event"), Mode: nats.CorePublishMode}))
+		require.Empty(t, natsClient.addStreamOpts)
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+	t.Run("should publish schema changes to the schema changes stream", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithSchemaChangesStream("SCHEMA_CHANGES"),
+			WithCollection("connector-db", "coll1"),
+			WithCollection("connector-db", "coll2"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:        "connector-db",
+				WatchedCollName:      "coll2",
+				ResumeTokensDbName:   "resume-tokens",
+				ResumeTokensCollName: "coll2",
+				StreamName:           "COLL2",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		schemaChangesStreamOpts := nats.AddStreamOptions{StreamName: "SCHEMA_CHANGES", Subject: "SCHEMA_CHANGES.*.*.*",
+			ReplaySpan: conn.replaySpan()}
+		require.True(t, natsClient.StreamWasAdded(schemaChangesStreamOpts))
+		natsClient.mua.Lock()
+		require.Len(t, natsClient.addStreamOpts, 3) // the schema changes stream is added once
+		natsClient.mua.Unlock()
+
+		err := mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "SCHEMA_CHANGES.connector-db.coll1.drop",
+			MsgId: "msgId", Data: []byte("drop"), OperationType: "drop", SchemaChange: true})
+		require.NoError(t, err)
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{
+			Subj: "SCHEMA_CHANGES.connector-db.coll1.drop", MsgId: "msgId", Data: []byte("drop")}))
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+	t.Run("should publish change events with the nats client of their tenant", func(t *testing.T) {
+		var (
+			mongoClient  = &mockMongoClient{}
+			natsClient   = &mockNatsClient{}
+			acmeClient   = &mockNatsClient{name: "nats-acme"}
+			globexClient = &mockNatsClient{name: "nats-globex"}
+			ctx, cancel  = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, err := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			withTenantNatsClient("acme", acmeClient),
+			withTenantNatsClient("globex", globexClient),
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithTenantField("tenant")),
+		)
+		require.NoError(t, err)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:        "connector-db",
+				WatchedCollName:      "coll1",
+				ResumeTokensDbName:   "resume-tokens",
+				ResumeTokensCollName: "coll1",
+				StreamName:           "COLL1",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		addStreamOpts := nats.AddStreamOptions{StreamName: "COLL1", Subject: "COLL1.*", ReplaySpan: conn.replaySpan()}
+		require.True(t, acmeClient.StreamWasAdded(addStreamOpts))
+		require.True(t, globexClient.StreamWasAdded(addStreamOpts))
+		require.Empty(t, natsClient.addStreamOpts)
+
+		err = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "COLL1.insert", MsgId: "msgId",
+			Data: []byte("event"), Tenant: "acme"})
+		require.NoError(t, err)
+		require.True(t, acmeClient.MessageWasPublished(nats.PublishOptions{Subj: "COLL1.insert", MsgId: "msgId",
+			Data: []byte("event")}))
+		require.Empty(t, globexClient.publishOpts)
+		require.Empty(t, natsClient.publishOpts)
+
+		err = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "COLL1.insert", MsgId: "msgId2",
+			Data: []byte("event"), Tenant: "initech"})
+		require.NoError(t, err, "the change events of unknown tenants are skipped by default")
+		require.Empty(t, natsClient.publishOpts)
+
+		// e.g. a deletion without pre-image
+		err = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "COLL1.delete", MsgId: "msgId4",
+			Data: []byte("event"), OperationType: "delete"})
+		require.NoError(t, err)
+		require.Empty(t, natsClient.publishOpts)
+		require.Len(t, acmeClient.publishOpts, 1)
+		require.Empty(t, globexClient.publishOpts)
+
+		err = mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "COLL1.rename", MsgId: "msgId3",
+			Data: []byte("rename"), OperationType: "rename"})
+		require.NoError(t, err)
+		renameOpts := nats.PublishOptions{Subj: "COLL1.rename", MsgId: "msgId3", Data: []byte("rename")}
+		require.True(t, acmeClient.MessageWasPublished(renameOpts))
+		require.True(t, globexClient.MessageWasPublished(renameOpts))
+		require.Empty(t, natsClient.publishOpts)
+
+		cancel()
+		require.NoError(t, <-errCh)
+		require.True(t, acmeClient.closed)
+		require.True(t, globexClient.closed)
+	})
+	t.Run("should stop connector and return error if collection creation fails", func(t *testing.T) {
+		var (
+			createCollErr = errors.New("create collection error")
+			mongoClient   = &mockMongoClient{
+				createCollectionErr: createCollErr,
+			}
+			natsClient      = &mockNatsClient{}
+			ctx, cancel     = context.WithCancel(context.Background())
+			dbName          = "connector-db"
+			collName        = "coll1"
+			tokensDbName    = "tokens-db"
+			tokensCollName  = "coll1-tokens"
+			collSizeInBytes = int64(2048)
+			streamName      = "coll1-stream"
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithContext(ctx),
+			WithCollection(dbName, collName,
+				WithChangeStreamPreAndPostImages(),
+				WithTokensDbName(tokensDbName),
+				WithTokensCollName(tokensCollName),
+				WithTokensCollCapped(collSizeInBytes),
+				WithStreamName(streamName),
+			),
+		)
+
+		err := conn.Run()
+		require.ErrorIs(t, err, createCollErr)
+		require.ErrorIs(t, err, ErrPreflightFailed)
+	})
+	t.Run("should stop connector and return error if stream add fails", func(t *testing.T) {
+		var (
+			addStreamErr = errors.New("add stream error")
+			mongoClient  = &mockMongoClient{}
+			natsClient   = &mockNatsClient{
+				addStreamErr: addStreamErr,
+			}
+			ctx, cancel     = context.WithCancel(context.Background())
+			dbName          = "connector-db"
+			collName        = "coll1"
+			tokensDbName    = "tokens-db"
+			tokensCollName  = "coll1-tokens"
+			collSizeInBytes = int64(2048)
+			streamName      = "coll1-stream"
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithContext(ctx),
+			WithCollection(dbName, collName,
+				WithChangeStreamPreAndPostImages(),
+				WithTokensDbName(tokensDbName),
+				WithTokensCollName(tokensCollName),
+				WithTokensCollCapped(collSizeInBytes),
+				WithStreamName(streamName),
+			),
+		)
+
+		err := conn.Run()
+		require.ErrorIs(t, err, addStreamErr)
+		require.ErrorIs(t, err, ErrPreflightFailed)
+	})
+	t.Run("should not watch collections whose subjects are not bound to exactly their stream", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{streamSubjects: map[string][]string{"AUDIT": {"*.delete"}}}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithStreamName("COLL1")),
+			WithCollection("connector-db", "coll2", WithStreamName("COLL2")),
+		)
+
+		err := conn.Run()
+		require.ErrorIs(t, err, ErrPreflightFailed)
+		require.ErrorIs(t, err, ErrUnboundSubjects)
+		require.ErrorContains(t, err, "connector-db.coll1: subject COLL1.delete overlaps several streams: AUDIT, COLL1")
+		require.ErrorContains(t, err, "connector-db.coll2: subject COLL2.delete overlaps several streams: AUDIT, COLL2")
+		require.Empty(t, mongoClient.watchCollectionOpts, "no collection should be watched")
+	})
+
+	t.Run("should publish routed change events to the streams of their routes", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1",
+				WithExpectStream(),
+				WithRoute("COLL1_EU", map[string]string{"region": "eu"}, ""),
+				WithRoute("COLL1_EU", map[string]string{"region": "ch"}, ""),
+			),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: "COLL1_EU",
+				Subject:    "COLL1_EU.*",
+				ReplaySpan: 10*time.Second + 1*time.Minute,
+			})
+		}, 1*time.Second, 10*time.Millisecond)
+		natsClient.mua.Lock()
+		require.Len(t, natsClient.addStreamOpts, 2, "the stream of the routes should be added once")
+		natsClient.mua.Unlock()
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:        "connector-db",
+				WatchedCollName:      "coll1",
+				ResumeTokensDbName:   "resume-tokens",
+				ResumeTokensCollName: "coll1",
+				StreamName:           "COLL1",
+			})
+		}, 1*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "COLL1_EU.insert", MsgId: "msgId",
+			Data: []byte("event"), StreamName: "COLL1_EU"}))
+		natsClient.mup.Lock()
+		require.True(t, slices.ContainsFunc(natsClient.publishOpts, func(po nats.PublishOptions) bool {
+			return po.Subj == "COLL1_EU.insert" && po.ExpectedStream == "COLL1_EU"
+		}))
+		natsClient.mup.Unlock()
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("should only fail the collection whose watcher failed in isolation", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("collection dropped")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr}}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithFailureMode("isolate")),
+			WithCollection("connector-db", "coll2", WithFailureMode("isolate")),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return conn.status.Collections()["connector-db.coll1"].State == server.CollectionStateFailed
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Equal(t, server.CollectionStatus{State: server.CollectionStateFailed, Error: watchErr.Error()},
+			conn.status.Collections()["connector-db.coll1"])
+		require.Equal(t, server.CollectionStateRunning, conn.status.Collections()["connector-db.coll2"].State)
+		require.Never(t, func() bool {
+			return len(errCh) > 0
+		}, 200*time.Millisecond, 10*time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("should stop once all the collections failed in isolation", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("collection dropped")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr, "coll2": watchErr}}
+			natsClient  = &mockNatsClient{}
+		)
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithCollection("connector-db", "coll1", WithFailureMode("isolate")),
+			WithCollection("connector-db", "coll2", WithFailureMode("isolate")),
+		)
+
+		err := conn.Run()
+		require.ErrorIs(t, err, ErrAllCollectionsFailed)
+		require.ErrorIs(t, err, ErrMongoFailed)
+		require.ErrorContains(t, err, watchErr.Error())
+	})
+
+	t.Run("should stop all the collections once a watcher failed", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("collection dropped")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr}}
+			natsClient  = &mockNatsClient{}
+		)
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithCollection("connector-db", "coll1"),
+			WithCollection("connector-db", "coll2", WithFailureMode("isolate")),
+		)
+
+		err := conn.Run()
+		require.ErrorIs(t, err, watchErr)
+		require.ErrorIs(t, err, ErrMongoFailed)
+		require.Equal(t, server.CollectionStateError, conn.status.Collections()["connector-db.coll1"].State)
+	})
+
+	t.Run("should return a fatal nats error if a change event cannot be published", func(t *testing.T) {
+		var (
+			publishErr  = errors.New("stream not found")
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{publishErr: publishErr}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			mongoClient.muw.Lock()
+			defer mongoClient.muw.Unlock()
+			return len(mongoClient.watchCollectionOpts) == 1
+		}, 1*time.Second, 10*time.Millisecond)
+
+		err := mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "COLL1.insert", MsgId: "msgId"})
+		require.ErrorIs(t, err, publishErr)
+		require.ErrorIs(t, err, ErrNatsFailed)
+		require.ErrorIs(t, fatalError(err), ErrNatsFailed)
+		require.NotErrorIs(t, fatalError(err), ErrMongoFailed)
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("should fail the watcher if still no stream responds once the retry budget is exhausted", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{watchUntilCancelled: true}
+			natsClient  = &mockNatsClient{noStreamPublishes: 3}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithContext(ctx),
+			WithRetryPolicy(&RetryPolicy{InitialInterval: time.Millisecond, MaxAttempts: 3}),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			mongoClient.muw.Lock()
+			defer mongoClient.muw.Unlock()
+			return len(mongoClient.watchCollectionOpts) == 1
+		}, 1*time.Second, 10*time.Millisecond)
+
+		err := mongoClient.SimulateChangeEvent(&mongo.ChangeEvent{Subj: "COLL1.insert", MsgId: "msgId"})
+		require.ErrorIs(t, err, nats.ErrNoStream)
+		require.ErrorIs(t, err, mongo.ErrUnpublishable)
+		require.Zero(t, natsClient.noStreamPublishes, "the change event is retried within the retry budget")
+		require.Equal(t, server.CollectionStateError, conn.status.Collections()["connector-db.coll1"].State)
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("should stop the watcher of a disabled collection until it is enabled", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{watchUntilCancelled: true}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithWatcherStatesBucket("watcher-states"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.WatchCollectionCalls() == 1
+		}, 1*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, conn.DisableWatcher(context.Background(), "connector-db.coll1"))
+		require.Eventually(t, func() bool {
+			return conn.status.Collections()["connector-db.coll1"].State == server.CollectionStateDisabled
+		}, 1*time.Second, 10*time.Millisecond)
+		kv, _ := natsClient.KeyValue("watcher-states")
+		value, err := kv.Get("connector-db.coll1")
+		require.NoError(t, err)
+		require.Contains(t, string(value), `"disabled":true`)
+
+		require.NoError(t, conn.EnableWatcher(context.Background(), "connector-db.coll1"))
+		require.Eventually(t, func() bool {
+			return mongoClient.WatchCollectionCalls() == 2
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Equal(t, server.CollectionStateRunning, conn.status.Collections()["connector-db.coll1"].State)
+		_, err = kv.Get("connector-db.coll1")
+		require.ErrorIs(t, err, nats.ErrKeyNotFound)
+
+		require.ErrorIs(t, conn.DisableWatcher(context.Background(), "connector-db.coll2"), server.ErrCollectionNotFound)
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("should pause and resume the watchers on the commands of the control subject", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{watchUntilCancelled: true}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithControlSubject(""),
+			WithControlUnauthenticated(),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.WatchCollectionCalls() == 1
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Equal(t, []string{"$CONNECTOR.control"}, natsClient.Subscribed())
+
+		natsClient.Deliver(&nats.Msg{Subj: "$CONNECTOR.control", Data: []byte(`{"command":"pause"}`)})
+		require.Eventually(t, func() bool {
+			return conn.status.Collections()["connector-db.coll1"].State == server.CollectionStateDisabled
+		}, 1*time.Second, 10*time.Millisecond)
+
+		natsClient.Deliver(&nats.Msg{Subj: "$CONNECTOR.control", Data: []byte(`{"command":"resume"}`)})
+		require.Eventually(t, func() bool {
+			return mongoClient.WatchCollectionCalls() == 2
+		}, 1*time.Second, 10*time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("should publish the lifecycle events of the watchers", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("collection dropped")
+			mongoClient = &mockMongoClient{watchUntilCancelled: true,
+				watchCollectionErrs: map[string]error{"coll2": watchErr}}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithEvents("", 0),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithFailureMode("isolate")),
+			WithCollection("connector-db", "coll2", WithFailureMode("isolate")),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return mongoClient.WatchCollectionCalls() == 1
+		}, 1*time.Second, 10*time.Millisecond)
+		require.NoError(t, conn.DisableWatcher(context.Background(), "connector-db.coll1"))
+		require.NoError(t, conn.EnableWatcher(context.Background(), "connector-db.coll1"))
+		require.Eventually(t, func() bool {
+			return mongoClient.WatchCollectionCalls() == 2
+		}, 1*time.Second, 10*time.Millisecond)
+		cancel()
+		require.NoError(t, <-errCh)
+
+		require.Equal(t, []string{
+			"$CONNECTOR.events.watcher.started.connector-db.coll1 ",
+			"$CONNECTOR.events.watcher.stopped.connector-db.coll1 disabled",
+			"$CONNECTOR.events.watcher.started.connector-db.coll1 ",
+			"$CONNECTOR.events.watcher.stopped.connector-db.coll1 shutdown",
+		}, publishedEvents(t, natsClient, "connector-db.coll1"))
+		require.Equal(t, []string{
+			"$CONNECTOR.events.watcher.started.connector-db.coll2 ",
+			"$CONNECTOR.events.watcher.failed.connector-db.coll2 collection dropped",
+		}, publishedEvents(t, natsClient, "connector-db.coll2"))
+	})
+
+	t.Run("should not start the watchers disabled before the connector restarted", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{watchUntilCancelled: true}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+		kv, _ := natsClient.KeyValue("watcher-states")
+		require.NoError(t, kv.Put("connector-db.coll1", []byte(`{"disabled":true,"disabledAt":"2024-01-01T00:00:00Z"}`)))
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithWatcherStatesBucket("watcher-states"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+			WithCollection("connector-db", "coll2"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+		require.Eventually(t, func() bool {
+			return conn.status.Collections()["connector-db.coll1"].State == server.CollectionStateDisabled
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:        "connector-db",
+				WatchedCollName:      "coll2",
+				ResumeTokensDbName:   "resume-tokens",
+				ResumeTokensCollName: "coll2",
+				StreamName:           "COLL2",
+			})
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Equal(t, 1, mongoClient.WatchCollectionCalls())
+
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("should post an alert to the webhooks once a watcher failed", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("collection dropped")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr}}
+			natsClient  = &mockNatsClient{}
+			alerts      = make(chan notify.Alert, 1)
+		)
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var alert notify.Alert
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+			alerts <- alert
+		}))
+		defer webhook.Close()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithInstanceId("connector-0"),
+			WithWebhook(webhook.URL, "json", "watcherFailed"),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		require.ErrorIs(t, conn.Run(), watchErr)
+		alert := <-alerts // alerts being posted are awaited before Run returns
+		require.Equal(t, notify.WatcherFailedAlert, alert.Kind)
+		require.Equal(t, "connector-0", alert.InstanceId)
+		require.Equal(t, "connector-db", alert.Database)
+		require.Equal(t, "coll1", alert.Collection)
+		require.Equal(t, watchErr.Error(), alert.Error)
+	})
+}
+
+type mockMongoClient struct {
+	closed     bool
+	name       string
+	monitorErr error
+
+	muc                  sync.Mutex
+	createCollectionOpts []mongo.CreateCollectionOptions
+	createCollectionErr  error
+
+	muw                  sync.Mutex
+	watchCollectionOpts  []mongo.WatchCollectionOptions
+	watchCollectionErr   error
+	watchCollectionErrs  map[string]error // by watched collection name
+	watchCollectionBlock chan struct{}
+	// watchUntilCancelled makes the watchers block until their context is cancelled, like the ones of a real client.
+	watchUntilCancelled bool
+
+	backfillOpts   []mongo.BackfillOptions
+	backfillEvents []*mongo.ChangeEvent
+
+	redriveOpts   []mongo.WatchCollectionOptions
+	redriveEvents []*mongo.ChangeEvent
+
+	mut          sync.Mutex
+	resumeTokens map[string]string // by resume tokens collection namespace
+}
+
+func (m *mockMongoClient) Close() error {
+	m.closed = true
+	return nil
+}
+
+func (m *mockMongoClient) Name() string {
+	return m.name
+}
+
+func (m *mockMongoClient) Monitor(_ context.Context) error {
+	return m.monitorErr
+}
+
+func (m *mockMongoClient) CreateCollection(_ context.Context, opts *mongo.CreateCollectionOptions) error {
 	if m.createCollectionErr != nil {
 		return m.createCollectionErr
 	}
-	m.muc.Lock()
-	defer m.muc.Unlock()
-	m.createCollectionOpts = append(m.createCollectionOpts, *opts)
-	return nil
+	m.muc.Lock()
+	defer m.muc.Unlock()
+	m.createCollectionOpts = append(m.createCollectionOpts, *opts)
+	return nil
+}
+
+func (m *mockMongoClient) CollectionWasCreated(opts mongo.CreateCollectionOptions) bool {
+	m.muc.Lock()
+	defer m.muc.Unlock()
+	return slices.Contains(m.createCollectionOpts, opts)
+}
+
+func (m *mockMongoClient) WatchCollection(ctx context.Context, opts *mongo.WatchCollectionOptions) error {
+	if m.watchCollectionErr != nil {
+		return m.watchCollectionErr
+	}
+	if err, ok := m.watchCollectionErrs[opts.WatchedCollName]; ok {
+		return err
+	}
+	m.muw.Lock()
+	m.watchCollectionOpts = append(m.watchCollectionOpts, *opts)
+	m.muw.Unlock()
+	if m.watchCollectionBlock != nil {
+		<-m.watchCollectionBlock // simulates a watcher that cannot be drained
+	}
+	if m.watchUntilCancelled {
+		<-ctx.Done()
+	}
+	return nil
+}
+
+func (m *mockMongoClient) WatchCollectionCalls() int {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return len(m.watchCollectionOpts)
+}
+
+func (m *mockMongoClient) Backfill(ctx context.Context, opts *mongo.BackfillOptions) (int, error) {
+	m.muw.Lock()
+	m.backfillOpts = append(m.backfillOpts, *opts)
+	m.muw.Unlock()
+	for i, event := range m.backfillEvents {
+		if err := opts.Collection.ChangeEventHandler(ctx, event); err != nil {
+			return i, err
+		}
+	}
+	return len(m.backfillEvents), nil
+}
+
+func (m *mockMongoClient) Redrive(ctx context.Context, opts *mongo.WatchCollectionOptions) (int, error) {
+	m.muw.Lock()
+	m.redriveOpts = append(m.redriveOpts, *opts)
+	m.muw.Unlock()
+	for i, event := range m.redriveEvents {
+		if err := opts.ChangeEventHandler(ctx, event); err != nil {
+			return i, err
+		}
+	}
+	return len(m.redriveEvents), nil
+}
+
+func (m *mockMongoClient) LastResumeToken(_ context.Context, opts *mongo.ResumeTokensOptions) (string, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.resumeTokens[opts.DbName+"."+opts.CollName], nil
+}
+
+func (m *mockMongoClient) RestoreResumeToken(_ context.Context, opts *mongo.ResumeTokensOptions, token string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if m.resumeTokens == nil {
+		m.resumeTokens = make(map[string]string)
+	}
+	m.resumeTokens[opts.DbName+"."+opts.CollName] = token
+	return nil
+}
+
+func (m *mockMongoClient) InspectResumeToken(_ context.Context, opts *mongo.ResumeTokensOptions) (
+	*mongo.ResumeTokenInspection, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	token, ok := m.resumeTokens[opts.DbName+"."+opts.CollName]
+	if !ok {
+		return &mongo.ResumeTokenInspection{Status: mongo.MissingTokenStatus}, nil
+	}
+	inspection := mongo.InspectToken(token, time.Time{})
+	if inspection.Status == mongo.CorruptTokenStatus {
+		inspection.Corrupt = 1
+	}
+	return inspection, nil
+}
+
+func (m *mockMongoClient) RepairResumeTokens(_ context.Context, opts *mongo.ResumeTokensOptions, token string) (int,
+	error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	tokensNamespace := opts.DbName + "." + opts.CollName
+	stored, ok := m.resumeTokens[tokensNamespace]
+	if !ok || mongo.InspectToken(stored, time.Time{}).Status != mongo.CorruptTokenStatus {
+		return 0, nil
+	}
+	if token == "" {
+		delete(m.resumeTokens, tokensNamespace)
+	} else {
+		m.resumeTokens[tokensNamespace] = token
+	}
+	return 1, nil
+}
+
+func (m *mockMongoClient) CollectionWasWatched(opts mongo.WatchCollectionOptions) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == opts.WatchedDbName &&
+			o.WatchedCollName == opts.WatchedCollName &&
+			o.ResumeTokensDbName == opts.ResumeTokensDbName &&
+			o.ResumeTokensCollName == opts.ResumeTokensCollName &&
+			o.ResumeTokensCollCapped == opts.ResumeTokensCollCapped &&
+			o.StreamName == opts.StreamName &&
+			o.ChangeEventHandler != nil
+	})
+}
+
+func (m *mockMongoClient) SimulateChangeEvents(subj, msgId string, data []byte) {
+	_ = m.SimulateChangeEvent(&mongo.ChangeEvent{Subj: subj, MsgId: msgId, Data: data})
+}
+
+func (m *mockMongoClient) SimulateChangeEvent(event *mongo.ChangeEvent) error {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	var errs []error
+	for _, opt := range m.watchCollectionOpts {
+		errs = append(errs, opt.ChangeEventHandler(context.Background(), event))
+	}
+	return errors.Join(errs...)
+}
+
+type mockNatsClient struct {
+	closed     bool
+	name       string
+	monitorErr error
+
+	mua           sync.Mutex
+	addStreamOpts []nats.AddStreamOptions
+	addStreamErr  error
+	// streamSubjects are the subjects of the streams which exist besides the added ones, by stream name.
+	streamSubjects map[string][]string
+
+	mup         sync.Mutex
+	publishOpts []nats.PublishOptions
+	publishErr  error
+
+	// reconnectingPublishes is the number of publishes that will fail because the client is reconnecting.
+	reconnectingPublishes int
+	// backpressuredPublishes is the number of publishes that will fail because the stream rejects the messages.
+	backpressuredPublishes int
+	// noStreamPublishes is the number of publishes that will fail because no stream responds.
+	noStreamPublishes int
+	// ackTimeoutPublishes is the number of publishes that will fail because the stream does not acknowledge the
+	// messages in time.
+	ackTimeoutPublishes int
+	// nackedSubj is the subject whose messages are rejected by the stream with a negative ack.
+	nackedSubj         string
+	waitConnectedCalls int
+	reconnecting       bool
+	maxPayload         int64
+
+	muo     sync.Mutex
+	objects map[string][]byte
+
+	muk       sync.Mutex
+	keyValues map[string]*mockKeyValue
+
+	mul      sync.Mutex
+	lastMsgs map[string]mockLastMsg // by stream name
+
+	mus          sync.Mutex
+	subscribed   []string
+	received     chan *nats.Msg // delivered to all the subscriptions
+	subscribeErr error
+}
+
+// mockLastMsg is the last message of a stream, as returned by LastMsgHeader.
+type mockLastMsg struct {
+	seq        uint64
+	instanceId string
+}
+
+func (m *mockNatsClient) Close() error {
+	m.closed = true
+	return nil
+}
+
+func (m *mockNatsClient) Name() string {
+	return m.name
+}
+
+func (m *mockNatsClient) Monitor(_ context.Context) error {
+	return m.monitorErr
+}
+
+func (m *mockNatsClient) AddStream(_ context.Context, opts *nats.AddStreamOptions) error {
+	if m.addStreamErr != nil {
+		return m.addStreamErr
+	}
+	m.mua.Lock()
+	defer m.mua.Unlock()
+	m.addStreamOpts = append(m.addStreamOpts, *opts)
+	return nil
+}
+
+func (m *mockNatsClient) StreamWasAdded(opt nats.AddStreamOptions) bool {
+	m.mua.Lock()
+	defer m.mua.Unlock()
+	return slices.Contains(m.addStreamOpts, opt)
+}
+
+func (m *mockNatsClient) Publish(_ context.Context, opts *nats.PublishOptions) error {
+	if m.publishErr != nil {
+		return m.publishErr
+	}
+	m.mup.Lock()
+	defer m.mup.Unlock()
+	if m.reconnectingPublishes > 0 {
+		m.reconnectingPublishes--
+		return nats.ErrClientReconnecting
+	}
+	if m.backpressuredPublishes > 0 {
+		m.backpressuredPublishes--
+		return nats.ErrBackpressure
+	}
+	if m.noStreamPublishes > 0 {
+		m.noStreamPublishes--
+		return nats.ErrNoStream
+	}
+	if m.ackTimeoutPublishes > 0 {
+		m.ackTimeoutPublishes--
+		return nats.ErrAckTimeout
+	}
+	if m.nackedSubj != "" && opts.Subj == m.nackedSubj {
+		return nats.ErrNack
+	}
+	if opts.Mode != nats.CorePublishMode {
+		stream, _, _ := strings.Cut(opts.Subj, ".")
+		opts.Ack = &natsgo.PubAck{Stream: stream, Sequence: uint64(len(m.publishOpts) + 1)}
+	}
+	m.publishOpts = append(m.publishOpts, *opts)
+	return nil
+}
+
+func (m *mockNatsClient) MessageWasPublished(opt nats.PublishOptions) bool {
+	m.mup.Lock()
+	defer m.mup.Unlock()
+	return slices.ContainsFunc(m.publishOpts, func(po nats.PublishOptions) bool {
+		return po.Subj == opt.Subj && po.MsgId == opt.MsgId && bytes.Equal(po.Data, opt.Data) &&
+			(opt.Mode == "" || po.Mode == opt.Mode) &&
+			(opt.Headers == nil || maps.Equal(po.Headers, opt.Headers))
+	})
+}
+
+func (m *mockNatsClient) WaitConnected(_ context.Context) error {
+	m.mup.Lock()
+	defer m.mup.Unlock()
+	m.waitConnectedCalls++
+	return nil
+}
+
+func (m *mockNatsClient) Reconnecting() bool {
+	return m.reconnecting
+}
+
+func (m *mockNatsClient) MaxPayload() int64 {
+	return m.maxPayload
+}
+
+func (m *mockNatsClient) PutObject(_ context.Context, bucket, name string, data []byte) error {
+	m.muo.Lock()
+	defer m.muo.Unlock()
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+	}
+	m.objects[bucket+"/"+name] = data
+	return nil
+}
+
+func (m *mockNatsClient) GetObject(_ context.Context, bucket, name string) ([]byte, error) {
+	m.muo.Lock()
+	defer m.muo.Unlock()
+	data, ok := m.objects[bucket+"/"+name]
+	if !ok {
+		return nil, nats.ErrObjectNotFound
+	}
+	return data, nil
+}
+
+func (m *mockNatsClient) PutObjectStream(ctx context.Context, bucket, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return m.PutObject(ctx, bucket, name, data)
+}
+
+func (m *mockNatsClient) DeleteObject(_ context.Context, bucket, name string) error {
+	m.muo.Lock()
+	defer m.muo.Unlock()
+	delete(m.objects, bucket+"/"+name)
+	return nil
+}
+
+func (m *mockNatsClient) LastMsgHeader(_ context.Context, stream, _ string) (uint64, string, error) {
+	m.mul.Lock()
+	defer m.mul.Unlock()
+	msg := m.lastMsgs[stream]
+	return msg.seq, msg.instanceId, nil
+}
+
+func (m *mockNatsClient) MsgIdsAfter(_ context.Context, stream string, subjects []string, seq uint64,
+	limit int) ([]string, uint64, error) {
+	m.mup.Lock()
+	defer m.mup.Unlock()
+	var (
+		msgIds  []string
+		lastSeq uint64
+	)
+	for _, opts := range m.publishOpts {
+		if opts.Ack == nil || opts.Ack.Stream != stream {
+			continue
+		}
+		lastSeq = max(lastSeq, opts.Ack.Sequence)
+		if len(subjects) > 0 && !nats.NewSubjectBinding(opts.Subj, map[string][]string{stream: subjects}).Covered {
+			continue
+		}
+		if opts.Ack.Sequence > seq && len(msgIds) < limit {
+			msgIds = append(msgIds, opts.MsgId)
+		}
+	}
+	return msgIds, lastSeq, nil
+}
+
+func (m *mockNatsClient) SubjectBinding(_ context.Context, subject string) (*nats.SubjectBinding, error) {
+	m.mua.Lock()
+	defer m.mua.Unlock()
+	streamSubjects := maps.Clone(m.streamSubjects)
+	if streamSubjects == nil {
+		streamSubjects = make(map[string][]string)
+	}
+	for _, opts := range m.addStreamOpts {
+		streamSubjects[opts.StreamName] = append(streamSubjects[opts.StreamName], opts.Subject)
+	}
+	return nats.NewSubjectBinding(subject, streamSubjects), nil
+}
+
+func (m *mockNatsClient) Subscribe(ctx context.Context, subj string) (<-chan *nats.Msg, error) {
+	if m.subscribeErr != nil {
+		return nil, m.subscribeErr
+	}
+	received := m.subscription(subj)
+	msgs := make(chan *nats.Msg)
+	go func() {
+		defer close(msgs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-received:
+				select {
+				case <-ctx.Done():
+					return
+				case msgs <- msg:
+				}
+			}
+		}
+	}()
+	return msgs, nil
+}
+
+func (m *mockNatsClient) subscription(subj string) chan *nats.Msg {
+	m.mus.Lock()
+	defer m.mus.Unlock()
+	m.subscribed = append(m.subscribed, subj)
+	if m.received == nil {
+		m.received = make(chan *nats.Msg)
+	}
+	return m.received
+}
+
+// Deliver delivers the given message to the subscriptions, once subscribed.
+func (m *mockNatsClient) Deliver(msg *nats.Msg) {
+	m.mus.Lock()
+	received := m.received
+	m.mus.Unlock()
+	received <- msg
+}
+
+// Subscribed returns the subjects subscribed to.
+func (m *mockNatsClient) Subscribed() []string {
+	m.mus.Lock()
+	defer m.mus.Unlock()
+	return slices.Clone(m.subscribed)
+}
+
+func (m *mockNatsClient) SimulateLastMsg(stream string, seq uint64, instanceId string) {
+	m.mul.Lock()
+	defer m.mul.Unlock()
+	if m.lastMsgs == nil {
+		m.lastMsgs = make(map[string]mockLastMsg)
+	}
+	m.lastMsgs[stream] = mockLastMsg{seq: seq, instanceId: instanceId}
+}
+
+func (m *mockNatsClient) KeyValue(bucket string) (nats.KeyValue, error) {
+	m.muk.Lock()
+	defer m.muk.Unlock()
+	if m.keyValues == nil {
+		m.keyValues = make(map[string]*mockKeyValue)
+	}
+	if m.keyValues[bucket] == nil {
+		m.keyValues[bucket] = &mockKeyValue{values: make(map[string][]byte)}
+	}
+	return m.keyValues[bucket], nil
+}
+
+type mockKeyValue struct {
+	mu        sync.Mutex
+	values    map[string][]byte
+	revisions map[string]uint64
+	revision  uint64 // the last revision of the bucket
+}
+
+func (kv *mockKeyValue) Get(key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	value, ok := kv.values[key]
+	if !ok {
+		return nil, nats.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (kv *mockKeyValue) GetRevision(key string) ([]byte, uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	value, ok := kv.values[key]
+	if !ok {
+		return nil, 0, nats.ErrKeyNotFound
+	}
+	return value, kv.revisions[key], nil
+}
+
+func (kv *mockKeyValue) Put(key string, value []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.put(key, value)
+	return nil
+}
+
+func (kv *mockKeyValue) Update(key string, value []byte, revision uint64) (uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if _, ok := kv.values[key]; (ok || revision != 0) && kv.revisions[key] != revision {
+		return 0, nats.ErrRevisionMismatch
+	}
+	return kv.put(key, value), nil
+}
+
+func (kv *mockKeyValue) put(key string, value []byte) uint64 {
+	if kv.revisions == nil {
+		kv.revisions = make(map[string]uint64)
+	}
+	kv.revision++
+	kv.values[key] = value
+	kv.revisions[key] = kv.revision
+	return kv.revision
+}
+
+func (kv *mockKeyValue) Delete(key string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	delete(kv.values, key)
+	return nil
+}
+
+func (kv *mockKeyValue) Keys() ([]string, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	keys := make([]string, 0, len(kv.values))
+	for key := range kv.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (kv *mockKeyValue) Watch(ctx context.Context, _ string) (<-chan []byte, error) {
+	values := make(chan []byte)
+	go func() {
+		<-ctx.Done()
+		close(values)
+	}()
+	return values, nil
+}
+
+func TestConnector_Backfill(t *testing.T) {
+	newConnector := func(mongoClient *mockMongoClient, natsClient *mockNatsClient) *Connector {
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(natsClient),
+			WithServerDisabled(),
+			WithCollection("shop", "orders", WithStreamName("ORDERS")),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+
+	t.Run("should publish the backfilled documents with the backfill header", func(t *testing.T) {
+		data := []byte(`{"operationType":"replace"}`)
+		mongoClient := &mockMongoClient{backfillEvents: []*mongo.ChangeEvent{
+			{Subj: "ORDERS.replace", MsgId: "backfill-1-order-1", Data: data, Backfill: "1"},
+		}}
+		natsClient := &mockNatsClient{}
+		conn := newConnector(mongoClient, natsClient)
+
+		backfilled, err := conn.Backfill("shop.orders", `{"status":"active"}`, 10)
+
+		require.NoError(t, err)
+		require.Equal(t, 1, backfilled)
+		require.Len(t, mongoClient.backfillOpts, 1)
+		require.Equal(t, "active", mongoClient.backfillOpts[0].Filter.Lookup("status").StringValue())
+		require.Equal(t, 10.0, mongoClient.backfillOpts[0].RateLimit)
+		require.True(t, natsClient.StreamWasAdded(nats.AddStreamOptions{StreamName: "ORDERS", Subject: "ORDERS.*",
+			ReplaySpan: defaultShutdownTimeout + defaultMaxRestartTime}))
+		wantHeaders := maps.Clone(conn.headers)
+		wantHeaders[contentTypeHdr] = "application/json"
+		wantHeaders[schemaVersionHdr] = "1"
+		wantHeaders[backfillHdr] = "1"
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{Subj: "ORDERS.replace",
+			MsgId: "backfill-1-order-1", Data: data, Headers: wantHeaders}))
+		require.True(t, mongoClient.closed)
+		require.True(t, natsClient.closed)
+	})
+	t.Run("should return error if the collection is not configured", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}, &mockNatsClient{}).Backfill("shop.invoices", "", 0)
+
+		require.ErrorIs(t, err, server.ErrCollectionNotFound)
+	})
+	t.Run("should return error if the filter is invalid", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}, &mockNatsClient{}).Backfill("shop.orders", `{"status"`, 0)
+
+		require.ErrorIs(t, err, mongo.ErrInvalidBackfillFilter)
+	})
+}
+
+func TestConnector_devGen(t *testing.T) {
+	t.Run("should publish the generated change events of the collections", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		natsClient := &mockNatsClient{}
+		conn, err := New(
+			WithContext(ctx),
+			WithDevGen(&DevGen{Rate: 1000, Ops: map[string]int{"insert": 1}}),
+			withNatsClient(natsClient),
+			WithServerDisabled(),
+			WithCollection("shop", "orders", WithStreamName("ORDERS")),
+		)
+		require.NoError(t, err)
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			natsClient.mup.Lock()
+			defer natsClient.mup.Unlock()
+			return len(natsClient.publishOpts) >= 10
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		require.NoError(t, <-runErr)
+		for _, opts := range natsClient.publishOpts {
+			require.Equal(t, "ORDERS.insert", opts.Subj)
+			require.Contains(t, string(opts.Data), `"fullDocument"`)
+		}
+	})
+}
+
+func TestConnector_Redrive(t *testing.T) {
+	newConnector := func(mongoClient *mockMongoClient, natsClient *mockNatsClient) *Connector {
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(natsClient),
+			WithServerDisabled(),
+			WithCollection("shop", "orders", WithStreamName("ORDERS"), WithQuarantine("orders-quarantine")),
+			WithCollection("shop", "invoices"),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+
+	t.Run("should publish the quarantined change events", func(t *testing.T) {
+		data := []byte(`{"operationType":"insert"}`)
+		mongoClient := &mockMongoClient{redriveEvents: []*mongo.ChangeEvent{
+			{Subj: "ORDERS.insert", MsgId: "msg-1", Data: data},
+		}}
+		natsClient := &mockNatsClient{}
+		conn := newConnector(mongoClient, natsClient)
+
+		redriven, err := conn.Redrive("shop.orders")
+
+		require.NoError(t, err)
+		require.Equal(t, 1, redriven)
+		require.Len(t, mongoClient.redriveOpts, 1)
+		require.Equal(t, "orders-quarantine", mongoClient.redriveOpts[0].QuarantineCollName)
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{Subj: "ORDERS.insert", MsgId: "msg-1",
+			Data: data}))
+		require.True(t, mongoClient.closed)
+		require.True(t, natsClient.closed)
+	})
+	t.Run("should return error if the collection is not configured", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}, &mockNatsClient{}).Redrive("shop.payments")
+
+		require.ErrorIs(t, err, server.ErrCollectionNotFound)
+	})
+	t.Run("should return error if the collection has no quarantine", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}, &mockNatsClient{}).Redrive("shop.invoices")
+
+		require.ErrorIs(t, err, ErrQuarantineMissing)
+	})
 }
 
-func (m *mockMongoClient) CollectionWasCreated(opts mongo.CreateCollectionOptions) bool {
-	m.muc.Lock()
-	defer m.muc.Unlock()
-	return slices.Contains(m.createCollectionOpts, opts)
+// everySchedule is a snapshot schedule activated periodically, at most max times.
+type everySchedule struct {
+	mu       sync.Mutex
+	interval time.Duration
+	max      int
 }
 
-func (m *mockMongoClient) WatchCollection(_ context.Context, opts *mongo.WatchCollectionOptions) error {
-	if m.watchCollectionErr != nil {
-		return m.watchCollectionErr
+func (s *everySchedule) Next(after time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.max == 0 {
+		return time.Time{}
 	}
-	m.muw.Lock()
-	defer m.muw.Unlock()
-	m.watchCollectionOpts = append(m.watchCollectionOpts, *opts)
-	return nil
+	s.max--
+	return after.Add(s.interval)
 }
 
-func (m *mockMongoClient) CollectionWasWatched(opts mongo.WatchCollectionOptions) bool {
-	m.muw.Lock()
-	defer m.muw.Unlock()
-	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
-		return o.WatchedDbName == opts.WatchedDbName &&
-			o.WatchedCollName == opts.WatchedCollName &&
-			o.ResumeTokensDbName == opts.ResumeTokensDbName &&
-			o.ResumeTokensCollName == opts.ResumeTokensCollName &&
-			o.ResumeTokensCollCapped == opts.ResumeTokensCollCapped &&
-			o.StreamName == opts.StreamName &&
-			o.ChangeEventHandler != nil
+func (s *everySchedule) String() string {
+	return "every " + s.interval.String()
+}
+
+func TestConnector_runSnapshots(t *testing.T) {
+	t.Run("should snapshot the collection on each activation", func(t *testing.T) {
+		mongoClient := &mockMongoClient{}
+		c := &Connector{logger: slog.Default(), options: Options{mongoClient: mongoClient}}
+		coll := &collection{dbName: "shop", collName: "orders", pipeline: pipeline{rateLimit: 50},
+			snapshot: &snapshot{schedule: &everySchedule{interval: 10 * time.Millisecond, max: 2},
+				filter: `{"status":"active"}`}}
+		watchCollOpts := &mongo.WatchCollectionOptions{WatchedDbName: "shop", WatchedCollName: "orders"}
+
+		c.runSnapshots(context.Background(), coll, watchCollOpts)
+
+		require.Len(t, mongoClient.backfillOpts, 2)
+		for _, opts := range mongoClient.backfillOpts {
+			require.True(t, strings.HasPrefix(opts.Id, "snapshot-"))
+			require.Equal(t, "active", opts.Filter.Lookup("status").StringValue())
+			require.Equal(t, 50.0, opts.RateLimit)
+			require.Equal(t, watchCollOpts, opts.Collection)
+		}
+	})
+	t.Run("should stop once the context is cancelled", func(t *testing.T) {
+		mongoClient := &mockMongoClient{}
+		c := &Connector{logger: slog.Default(), options: Options{mongoClient: mongoClient}}
+		coll := &collection{dbName: "shop", collName: "orders",
+			snapshot: &snapshot{schedule: &everySchedule{interval: time.Hour, max: 1}}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c.runSnapshots(ctx, coll, &mongo.WatchCollectionOptions{})
+
+		require.Empty(t, mongoClient.backfillOpts)
 	})
 }
 
-func (m *mockMongoClient) SimulateChangeEvents(subj, msgId string, data []byte) {
-	m.muw.Lock()
-	defer m.muw.Unlock()
-	for _, opt := range m.watchCollectionOpts {
-		_ = opt.ChangeEventHandler(context.Background(), subj, msgId, data)
+func TestConnector_publishWatermarks(t *testing.T) {
+	orders := &collection{dbName: "shop", collName: "orders"}
+	users := &collection{dbName: "shop", collName: "users"}
+	natsClient := &mockNatsClient{}
+	c := &Connector{
+		logger:     slog.Default(),
+		headers:    map[string]string{instanceIdHdr: "connector-0"},
+		watermarks: make(map[string]primitive.Timestamp),
+		options: Options{natsClient: natsClient, watermarkSubject: "WATERMARKS",
+			watermarkInterval: 10 * time.Millisecond, collections: []*collection{orders, users}},
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.publishWatermarks(ctx)
+
+	c.watermarkHandler(orders)(primitive.Timestamp{T: 1714564900, I: 1})
+	time.Sleep(50 * time.Millisecond)
+	natsClient.mup.Lock()
+	published := len(natsClient.publishOpts)
+	natsClient.mup.Unlock()
+	require.Zero(t, published, "no watermark until all the collections reported theirs")
+
+	c.watermarkHandler(users)(primitive.Timestamp{T: 1714564800, I: 3})
+	c.watermarkHandler(orders)(primitive.Timestamp{T: 1714564700, I: 1}) // watermarks never go backwards
+
+	data := []byte(`{"clusterTime":"2024-05-01T12:00:00Z","timestamp":{"t":1714564800,"i":3},` +
+		`"collections":{"shop.orders":{"t":1714564900,"i":1},"shop.users":{"t":1714564800,"i":3}}}`)
+	require.Eventually(t, func() bool {
+		return natsClient.MessageWasPublished(nats.PublishOptions{Subj: "WATERMARKS", Data: data,
+			Mode:    nats.CorePublishMode,
+			Headers: map[string]string{instanceIdHdr: "connector-0", contentTypeHdr: "application/json"}})
+	}, 1*time.Second, 10*time.Millisecond)
 }
 
-type mockNatsClient struct {
-	closed     bool
-	name       string
-	monitorErr error
+func TestConnector_addStreams(t *testing.T) {
+	natsClient := &mockNatsClient{}
+	c := &Connector{logger: slog.Default(), options: Options{natsClient: natsClient}}
+	orders := &collection{dbName: "shop", collName: "orders", msgTtl: 24 * time.Hour}
 
-	mua           sync.Mutex
-	addStreamOpts []nats.AddStreamOptions
-	addStreamErr  error
+	err := c.addStreams(context.Background(), natsClient, orders, &mongo.WatchCollectionOptions{StreamName: "ORDERS"}, 0)
 
-	mup         sync.Mutex
-	publishOpts []nats.PublishOptions
-	publishErr  error
+	require.NoError(t, err)
+	require.True(t, natsClient.StreamWasAdded(nats.AddStreamOptions{StreamName: "ORDERS", Subject: "ORDERS.*",
+		AllowMsgTtl: true}), "the streams of the collections with a msg ttl allow per-message ttls")
 }
 
-func (m *mockNatsClient) Close() error {
-	m.closed = true
-	return nil
+func TestConnector_idempotentResume(t *testing.T) {
+	natsClient := &mockNatsClient{}
+	c := &Connector{logger: slog.Default(), journal: server.NewJournal(0), options: Options{natsClient: natsClient}}
+	orders := &collection{dbName: "shop", collName: "orders", publishMode: nats.JetStreamPublishMode,
+		idempotentResume: true}
+
+	t.Run("should record the ack of the published change events", func(t *testing.T) {
+		for _, msgId := range []string{"msg-1", "msg-2", "msg-3"} {
+			event := &mongo.ChangeEvent{Subj: "ORDERS.insert", MsgId: msgId, Data: []byte("{}")}
+
+			require.NoError(t, c.handle(context.Background(), context.Background(), orders, natsClient, event))
+			require.Equal(t, "ORDERS", event.Ack.Stream)
+		}
+	})
+	t.Run("should fetch the message ids published after the given sequence", func(t *testing.T) {
+		handler := c.publishedMsgIdsHandler(orders)
+
+		msgIds, lastSeq, err := handler(context.Background(), "ORDERS", []string{"ORDERS.*"}, 1)
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"msg-2", "msg-3"}, msgIds)
+		require.Equal(t, uint64(3), lastSeq)
+	})
+	t.Run("should not record nor fetch anything by default", func(t *testing.T) {
+		users := &collection{dbName: "shop", collName: "users", publishMode: nats.JetStreamPublishMode}
+		event := &mongo.ChangeEvent{Subj: "USERS.insert", MsgId: "msg-4", Data: []byte("{}")}
+
+		require.NoError(t, c.handle(context.Background(), context.Background(), users, natsClient, event))
+		require.Nil(t, event.Ack)
+		require.Nil(t, c.publishedMsgIdsHandler(users))
+	})
 }
 
-func (m *mockNatsClient) Name() string {
-	return m.name
+func TestConnector_checkOplogHeadroom(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		warnings []oplogWarning
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var warning oplogWarning
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&warning))
+		mu.Lock()
+		warnings = append(warnings, warning)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+	posted := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(warnings)
+	}
+
+	tokenTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := &Connector{
+		logger:       slog.Default(),
+		notifier:     notify.New(slog.Default()),
+		storedTokens: make(map[string]*storedToken),
+		options: Options{instanceId: "connector-0", oplogWarningHeadroom: time.Hour,
+			oplogWarningWebhook: webhook.URL},
+	}
+	c.recordStoredToken("shop", "orders", tokenTime)
+
+	c.checkOplogHeadroom(tokenTime.Add(-2 * time.Hour))
+	time.Sleep(50 * time.Millisecond)
+	require.Zero(t, posted(), "no warning while the headroom is above the threshold")
+
+	c.checkOplogHeadroom(tokenTime.Add(-30 * time.Minute))
+	c.checkOplogHeadroom(tokenTime.Add(-20 * time.Minute))
+	require.Eventually(t, func() bool { return posted() == 1 }, 1*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 1, posted(), "the warning is posted once per drop below the threshold")
+	mu.Lock()
+	require.Equal(t, oplogWarning{Alert: "oplogHeadroomLow", InstanceId: "connector-0", Database: "shop",
+		Collection: "orders", TokenTime: tokenTime, OplogOldest: tokenTime.Add(-30 * time.Minute),
+		Headroom: "30m0s", Threshold: "1h0m0s"}, warnings[0])
+	mu.Unlock()
+
+	c.recordStoredToken("shop", "orders", tokenTime.Add(2*time.Hour))
+	c.checkOplogHeadroom(tokenTime.Add(-20 * time.Minute))
+	require.False(t, c.storedTokens["shop.orders"].low)
+
+	c.checkOplogHeadroom(tokenTime.Add(2 * time.Hour))
+	require.Eventually(t, func() bool { return posted() == 2 }, 1*time.Second, 10*time.Millisecond)
 }
 
-func (m *mockNatsClient) Monitor(_ context.Context) error {
-	return m.monitorErr
+func TestConnector_putStatus(t *testing.T) {
+	natsClient := &mockNatsClient{}
+	status := server.NewStatus("shop.orders")
+	c := &Connector{
+		logger: slog.Default(),
+		server: server.New(server.WithStatus(status), server.WithInstance(&server.Instance{Id: "connector-0"})),
+		options: Options{natsClient: natsClient, instanceId: "connector-0", statusBucket: "CONNECTOR_STATUS",
+			statusInterval: 10 * time.Millisecond},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.putStatus(ctx)
+	}()
+
+	kv, _ := natsClient.KeyValue("CONNECTOR_STATUS")
+	entry := func() map[string]any {
+		value, err := kv.Get("connector-0")
+		if err != nil {
+			return nil
+		}
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(value, &entry))
+		return entry
+	}
+	require.Eventually(t, func() bool { return entry() != nil }, 1*time.Second, 10*time.Millisecond)
+	require.Equal(t, "UP", entry()["status"])
+	require.Equal(t, map[string]any{"id": "connector-0"}, entry()["instance"])
+	require.NotEmpty(t, entry()["updatedAt"])
+
+	status.SetState("shop.orders", server.CollectionStateFailed, errors.New("collection dropped"))
+	require.Eventually(t, func() bool { return entry()["status"] == "DOWN" }, 1*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	require.Nil(t, entry(), "the status is deleted once the connector shuts down")
 }
 
-func (m *mockNatsClient) AddStream(_ context.Context, opts *nats.AddStreamOptions) error {
-	if m.addStreamErr != nil {
-		return m.addStreamErr
+func TestConnector_exportMetrics(t *testing.T) {
+	statsd, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer statsd.Close()
+	c := &Connector{logger: slog.Default(), options: Options{metricsExporter: statsdMetricsExporter,
+		statsdAddr: statsd.LocalAddr().String(), statsdInterval: time.Hour}}
+	exporter, err := prometheus.NewStatsdExporter(c.options.statsdAddr, "connector.", nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c.exportMetrics(ctx, exporter)
+
+	require.NoError(t, statsd.SetReadDeadline(time.Now().Add(1*time.Second)))
+	buf := make([]byte, 64*1024)
+	n, _, err := statsd.ReadFrom(buf)
+	require.NoError(t, err, "the metrics are pushed a last time once the context is cancelled")
+	require.True(t, strings.HasPrefix(string(buf[:n]), "connector."))
+	require.Nil(t, c.metricsHandler(), "the metrics are not scraped if they are pushed")
+}
+
+func TestConnector_pushMetrics(t *testing.T) {
+	paths := make(chan string, 1)
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer pushgateway.Close()
+
+	t.Run("should push the metrics grouped by instance id and command", func(t *testing.T) {
+		c := &Connector{logger: slog.Default(), options: Options{instanceId: "connector-0",
+			pushgatewayUrl: pushgateway.URL, pushgatewayJob: defaultPushgatewayJob}}
+
+		c.pushMetrics("backfill")
+
+		path := <-paths
+		require.True(t, strings.HasPrefix(path, "/metrics/job/mongodb-nats-connector/"))
+		require.Contains(t, path, "/instance/connector-0")
+		require.Contains(t, path, "/command/backfill")
+	})
+	t.Run("should not push the metrics without pushgateway", func(t *testing.T) {
+		c := &Connector{logger: slog.Default(), options: Options{instanceId: "connector-0"}}
+
+		c.pushMetrics("backfill")
+
+		require.Empty(t, paths)
+	})
+}
+
+func TestConnector_envelopeVersion(t *testing.T) {
+	newCollection := func(encoder mongo.Encoder, excludeFields ...string) *collection {
+		return &collection{dbName: "shop", collName: "orders", pipeline: pipeline{encoder: encoder},
+			excludeFields: excludeFields}
 	}
-	m.mua.Lock()
-	defer m.mua.Unlock()
-	m.addStreamOpts = append(m.addStreamOpts, *opts)
-	return nil
+
+	t.Run("should return 1 without schema versions bucket", func(t *testing.T) {
+		c := &Connector{options: Options{natsClient: &mockNatsClient{}}}
+
+		version, err := c.envelopeVersion(newCollection(mongo.JsonEncoder))
+
+		require.NoError(t, err)
+		require.Equal(t, 1, version)
+	})
+	t.Run("should bump the version once the envelope changes", func(t *testing.T) {
+		c := &Connector{logger: slog.Default(),
+			options: Options{natsClient: &mockNatsClient{}, schemaVersionsBucket: "schema-versions"}}
+
+		for _, tt := range []struct {
+			coll *collection
+			want int
+		}{
+			{coll: newCollection(mongo.JsonEncoder, "email", "ssn"), want: 1},
+			{coll: newCollection(mongo.JsonEncoder, "ssn", "email"), want: 1},
+			{coll: newCollection(mongo.BsonEncoder, "ssn", "email"), want: 2},
+			{coll: newCollection(mongo.BsonEncoder), want: 3},
+			{coll: newCollection(mongo.BsonEncoder), want: 3},
+		} {
+			version, err := c.envelopeVersion(tt.coll)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, version)
+		}
+	})
 }
 
-func (m *mockNatsClient) StreamWasAdded(opt nats.AddStreamOptions) bool {
-	m.mua.Lock()
-	defer m.mua.Unlock()
-	return slices.Contains(m.addStreamOpts, opt)
+func TestConnector_copyGridFSFile(t *testing.T) {
+	coll := &collection{dbName: "shop", collName: "fs.files", gridFs: true, gridFsObjectBucket: "FS"}
+	open := func(context.Context) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("file content")), nil
+	}
+
+	t.Run("should copy the content of created files, and delete it with the file", func(t *testing.T) {
+		c := &Connector{logger: slog.Default()}
+		natsClient := &mockNatsClient{}
+
+		err := c.copyGridFSFile(context.Background(), coll, natsClient,
+			&mongo.GridFSFile{Event: mongo.CreatedGridFSEvent, Name: "file-1", Open: open})
+		require.NoError(t, err)
+		require.Equal(t, []byte("file content"), natsClient.objects["FS/file-1"])
+
+		err = c.copyGridFSFile(context.Background(), coll, natsClient,
+			&mongo.GridFSFile{Event: mongo.DeletedGridFSEvent, Name: "file-1"})
+		require.NoError(t, err)
+		require.NotContains(t, natsClient.objects, "FS/file-1")
+	})
+	t.Run("should skip files deleted before their content is copied", func(t *testing.T) {
+		c := &Connector{logger: slog.Default()}
+		natsClient := &mockNatsClient{}
+
+		err := c.copyGridFSFile(context.Background(), coll, natsClient,
+			&mongo.GridFSFile{Event: mongo.CreatedGridFSEvent, Name: "file-1",
+				Open: func(context.Context) (io.ReadCloser, error) { return nil, mongo.ErrGridFSFileNotFound }})
+		require.NoError(t, err)
+		require.Empty(t, natsClient.objects)
+	})
+	t.Run("should return error if the content cannot be read", func(t *testing.T) {
+		c := &Connector{logger: slog.Default()}
+
+		err := c.copyGridFSFile(context.Background(), coll, &mockNatsClient{},
+			&mongo.GridFSFile{Event: mongo.CreatedGridFSEvent, Name: "file-1",
+				Open: func(context.Context) (io.ReadCloser, error) { return nil, errors.New("connection reset") }})
+		require.ErrorContains(t, err, "connection reset")
+	})
 }
 
-func (m *mockNatsClient) Publish(_ context.Context, opts *nats.PublishOptions) error {
-	if m.publishErr != nil {
-		return m.publishErr
+func TestConnector_oversizedPublishOpts(t *testing.T) {
+	event := &mongo.ChangeEvent{Subj: "COLL1.insert", MsgId: "msg-1", Data: []byte("large payload"),
+		OperationType: "insert", Oversized: true}
+	opts := &nats.PublishOptions{Subj: event.Subj, MsgId: event.MsgId, Data: event.Data,
+		Headers: map[string]string{instanceIdHdr: "connector-0"}, ExpectedStream: "COLL1"}
+
+	t.Run("should publish a reference to the dead letter subject", func(t *testing.T) {
+		c := &Connector{}
+		coll := &collection{dbName: "test-db", collName: "coll1", oversizedPolicy: mongo.DlqOversizedPolicy,
+			dlqSubject: "COLL1_DLQ.oversized"}
+
+		got, err := c.oversizedPublishOpts(context.Background(), coll, &mockNatsClient{}, event, opts)
+
+		require.NoError(t, err)
+		require.Equal(t, "COLL1_DLQ.oversized", got.Subj)
+		require.Equal(t, "msg-1", got.MsgId)
+		require.Empty(t, got.ExpectedStream)
+		require.Equal(t, map[string]string{instanceIdHdr: "connector-0", contentTypeHdr: "application/json"},
+			got.Headers)
+		require.JSONEq(t, `{"subject":"COLL1.insert","msgId":"msg-1","size":13,"operationType":"insert",
+			"database":"test-db","collection":"coll1"}`, string(got.Data))
+	})
+	t.Run("should offload the change event and publish a reference to it", func(t *testing.T) {
+		c := &Connector{}
+		natsClient := &mockNatsClient{}
+		coll := &collection{dbName: "test-db", collName: "coll1", oversizedPolicy: mongo.OffloadOversizedPolicy,
+			offloadBucket: "offload"}
+
+		got, err := c.oversizedPublishOpts(context.Background(), coll, natsClient, event, opts)
+
+		require.NoError(t, err)
+		require.Equal(t, []byte("large payload"), natsClient.objects["offload/msg-1"])
+		require.Equal(t, "COLL1.insert", got.Subj)
+		require.Equal(t, map[string]string{instanceIdHdr: "connector-0", offloadBucketHdr: "offload",
+			offloadObjectHdr: "msg-1", contentTypeHdr: "application/json"}, got.Headers)
+		require.Equal(t, map[string]string{instanceIdHdr: "connector-0"}, opts.Headers)
+		require.JSONEq(t, `{"subject":"COLL1.insert","msgId":"msg-1","size":13,"operationType":"insert",
+			"database":"test-db","collection":"coll1","bucket":"offload","object":"msg-1"}`, string(got.Data))
+	})
+	t.Run("should return error if the change event cannot be published", func(t *testing.T) {
+		for _, policy := range []mongo.OversizedPolicy{mongo.FailOversizedPolicy, mongo.TruncateOversizedPolicy} {
+			c := &Connector{}
+			coll := &collection{dbName: "test-db", collName: "coll1", oversizedPolicy: policy}
+
+			got, err := c.oversizedPublishOpts(context.Background(), coll, &mockNatsClient{}, event, opts)
+
+			require.Nil(t, got)
+			require.ErrorIs(t, err, ErrOversizedPayload)
+		}
+	})
+}
+
+func Test_decodeErrorPublishOpts(t *testing.T) {
+	event := &mongo.ChangeEvent{Subj: "COLL1.insert", MsgId: "msg-1", Data: []byte("raw bson"),
+		OperationType: "insert", DecodeError: errors.New("unsupported value")}
+	opts := &nats.PublishOptions{Subj: event.Subj, MsgId: event.MsgId, Data: event.Data,
+		Headers: map[string]string{instanceIdHdr: "connector-0"}, ExpectedStream: "COLL1"}
+	coll := &collection{dbName: "test-db", collName: "coll1", decodeErrorPolicy: mongo.DlqDecodeErrorPolicy,
+		dlqSubject: "COLL1_DLQ.malformed"}
+
+	got := decodeErrorPublishOpts(coll, event, opts)
+
+	require.Equal(t, "COLL1_DLQ.malformed", got.Subj)
+	require.Equal(t, "msg-1", got.MsgId)
+	require.Equal(t, []byte("raw bson"), got.Data)
+	require.Empty(t, got.ExpectedStream)
+	require.Equal(t, map[string]string{instanceIdHdr: "connector-0", decodeErrorHdr: "unsupported value",
+		contentTypeHdr: "application/bson"}, got.Headers)
+	require.Equal(t, map[string]string{instanceIdHdr: "connector-0"}, opts.Headers)
+}
+
+func TestConnector_handleNack(t *testing.T) {
+	newConnector := func(natsClient *mockNatsClient) *Connector {
+		return &Connector{logger: slog.Default(), journal: server.NewJournal(0),
+			status: server.NewStatus("shop.orders"), headers: map[string]string{instanceIdHdr: "connector-0"},
+			options: Options{natsClient: natsClient}}
 	}
-	m.mup.Lock()
-	defer m.mup.Unlock()
-	m.publishOpts = append(m.publishOpts, *opts)
-	return nil
+	event := &mongo.ChangeEvent{Subj: "ORDERS.insert", MsgId: "msg-1", Data: []byte("{}")}
+
+	t.Run("should publish the nacked change event to the dead letter subject", func(t *testing.T) {
+		natsClient := &mockNatsClient{nackedSubj: "ORDERS.insert"}
+		coll := &collection{dbName: "shop", collName: "orders", publishMode: nats.JetStreamPublishMode,
+			nackPolicy: nats.DlqNackPolicy, dlqSubject: "ORDERS_DLQ.nacked",
+			pipeline: pipeline{encoder: mongo.JsonEncoder}}
+
+		require.NoError(t, newConnector(natsClient).handle(context.Background(), context.Background(), coll,
+			natsClient, event))
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{Subj: "ORDERS_DLQ.nacked", MsgId: "msg-1",
+			Data: []byte("{}"), Headers: map[string]string{instanceIdHdr: "connector-0",
+				contentTypeHdr: "application/json", schemaVersionHdr: "0", nackErrorHdr: nats.ErrNack.Error()}}))
+	})
+	t.Run("should return error if the nack policy is fail", func(t *testing.T) {
+		natsClient := &mockNatsClient{nackedSubj: "ORDERS.insert"}
+		coll := &collection{dbName: "shop", collName: "orders", publishMode: nats.JetStreamPublishMode,
+			nackPolicy: nats.FailNackPolicy, pipeline: pipeline{encoder: mongo.JsonEncoder}}
+
+		err := newConnector(natsClient).handle(context.Background(), context.Background(), coll, natsClient, event)
+
+		require.ErrorIs(t, err, nats.ErrNack)
+		require.Empty(t, natsClient.publishOpts)
+	})
 }
 
-func (m *mockNatsClient) MessageWasPublished(opt nats.PublishOptions) bool {
-	m.mup.Lock()
-	defer m.mup.Unlock()
-	return slices.ContainsFunc(m.publishOpts, func(po nats.PublishOptions) bool {
-		return po.Subj == opt.Subj && po.MsgId == opt.MsgId && bytes.Equal(po.Data, opt.Data)
+func TestConnector_publish(t *testing.T) {
+	newConnector := func(policy *retry.Policy) *Connector {
+		return &Connector{logger: slog.Default(), journal: server.NewJournal(0), notifier: notify.New(slog.Default()),
+			status: server.NewStatus("shop.orders"), options: Options{retryPolicy: policy}}
+	}
+	coll := &collection{dbName: "shop", collName: "orders"}
+	opts := &nats.PublishOptions{Subj: "ORDERS.insert", MsgId: "msg-1", Data: []byte("{}")}
+
+	t.Run("should retry the paused publishes within the retry budget", func(t *testing.T) {
+		natsClient := &mockNatsClient{backpressuredPublishes: 2}
+		c := newConnector(&retry.Policy{InitialInterval: time.Millisecond, MaxAttempts: 3})
+
+		require.NoError(t, c.publish(context.Background(), context.Background(), coll, natsClient, opts))
+		require.True(t, natsClient.MessageWasPublished(*opts))
+	})
+	t.Run("should return error once the retry budget is exhausted", func(t *testing.T) {
+		natsClient := &mockNatsClient{backpressuredPublishes: 3}
+		c := newConnector(&retry.Policy{InitialInterval: time.Millisecond, MaxAttempts: 3})
+
+		err := c.publish(context.Background(), context.Background(), coll, natsClient, opts)
+
+		require.ErrorIs(t, err, nats.ErrBackpressure)
+		require.Empty(t, natsClient.publishOpts)
+	})
+	t.Run("should retry the publishes while no stream responds within the retry budget", func(t *testing.T) {
+		natsClient := &mockNatsClient{noStreamPublishes: 2}
+		c := newConnector(&retry.Policy{InitialInterval: time.Millisecond, MaxAttempts: 3})
+
+		require.NoError(t, c.publish(context.Background(), context.Background(), coll, natsClient, opts))
+		require.True(t, natsClient.MessageWasPublished(*opts))
+	})
+	t.Run("should return error without retrying the error classes which are not retried", func(t *testing.T) {
+		natsClient := &mockNatsClient{ackTimeoutPublishes: 1}
+		c := newConnector(&retry.Policy{RetryOn: []retry.Class{retry.BackpressureClass}})
+
+		err := c.publish(context.Background(), context.Background(), coll, natsClient, opts)
+
+		require.ErrorIs(t, err, nats.ErrAckTimeout)
+		require.Empty(t, natsClient.publishOpts)
+	})
+}
+
+func TestConnector_handleUnknownTenant(t *testing.T) {
+	newConnector := func(natsClient *mockNatsClient) *Connector {
+		return &Connector{logger: slog.Default(), journal: server.NewJournal(0),
+			status: server.NewStatus("shop.orders"), headers: map[string]string{instanceIdHdr: "connector-0"},
+			options: Options{natsClient: natsClient}}
+	}
+	event := &mongo.ChangeEvent{Subj: "ORDERS.insert", MsgId: "msg-1", Data: []byte("{}"), Tenant: "initech"}
+	newColl := func(action mongo.ErrorAction) *collection {
+		return &collection{dbName: "shop", collName: "orders", publishMode: nats.JetStreamPublishMode,
+			tenantField: "tenant", errorPolicies: mongo.ErrorPolicies{mongo.UnknownTenantErrorClass: action},
+			dlqSubject: "ORDERS_DLQ.tenant", pipeline: pipeline{encoder: mongo.JsonEncoder}}
+	}
+
+	t.Run("should skip the change event of an unknown tenant", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		coll := newColl(mongo.SkipErrorAction)
+		_, err := newConnector(natsClient).natsClientsFor(coll, event)
+
+		require.NoError(t, newConnector(natsClient).handleUnknownTenant(context.Background(), context.Background(),
+			coll, event, err))
+		require.Empty(t, natsClient.publishOpts)
+	})
+	t.Run("should publish the change event of an unknown tenant to the dead letter subject", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		coll := newColl(mongo.DlqErrorAction)
+		_, err := newConnector(natsClient).natsClientsFor(coll, event)
+
+		require.NoError(t, newConnector(natsClient).handleUnknownTenant(context.Background(), context.Background(),
+			coll, event, err))
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{Subj: "ORDERS_DLQ.tenant",
+			MsgId: "msg-1", Data: []byte("{}"), Headers: map[string]string{instanceIdHdr: "connector-0",
+				contentTypeHdr: "application/json", schemaVersionHdr: "0", tenantErrorHdr: err.Error()}}))
+	})
+	t.Run("should return error without retrying if the action is halt", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		coll := newColl(mongo.HaltErrorAction)
+		_, err := newConnector(natsClient).natsClientsFor(coll, event)
+
+		err = newConnector(natsClient).handleUnknownTenant(context.Background(), context.Background(), coll, event, err)
+
+		require.ErrorIs(t, err, ErrUnknownTenant)
+		require.ErrorIs(t, err, mongo.ErrUnpublishable)
+		require.Empty(t, natsClient.publishOpts)
+	})
+}
+
+func TestConnector_handlePublishTimeout(t *testing.T) {
+	newConnector := func(natsClient *mockNatsClient) *Connector {
+		return &Connector{logger: slog.Default(), journal: server.NewJournal(0),
+			status: server.NewStatus("shop.orders"), headers: map[string]string{instanceIdHdr: "connector-0"},
+			options: Options{natsClient: natsClient}}
+	}
+	event := &mongo.ChangeEvent{Subj: "ORDERS.insert", MsgId: "msg-1", Data: []byte("{}")}
+	newColl := func(action mongo.ErrorAction) *collection {
+		return &collection{dbName: "shop", collName: "orders", publishMode: nats.JetStreamPublishMode,
+			errorPolicies: mongo.ErrorPolicies{mongo.PublishTimeoutErrorClass: action},
+			dlqSubject:    "ORDERS_DLQ.timedout", pipeline: pipeline{encoder: mongo.JsonEncoder}}
+	}
+
+	t.Run("should skip the change event not acknowledged in time", func(t *testing.T) {
+		natsClient := &mockNatsClient{ackTimeoutPublishes: 1}
+
+		require.NoError(t, newConnector(natsClient).handle(context.Background(), context.Background(),
+			newColl(mongo.SkipErrorAction), natsClient, event))
+		require.Empty(t, natsClient.publishOpts)
+	})
+	t.Run("should publish the change event not acknowledged in time to the dead letter subject", func(t *testing.T) {
+		natsClient := &mockNatsClient{ackTimeoutPublishes: 1}
+
+		require.NoError(t, newConnector(natsClient).handle(context.Background(), context.Background(),
+			newColl(mongo.DlqErrorAction), natsClient, event))
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{Subj: "ORDERS_DLQ.timedout",
+			MsgId: "msg-1", Data: []byte("{}"), Headers: map[string]string{instanceIdHdr: "connector-0",
+				contentTypeHdr: "application/json", schemaVersionHdr: "0", ackTimeoutHdr: nats.ErrAckTimeout.Error()}}))
+	})
+	t.Run("should return error without retrying if the action is halt", func(t *testing.T) {
+		natsClient := &mockNatsClient{ackTimeoutPublishes: 1}
+
+		err := newConnector(natsClient).handle(context.Background(), context.Background(),
+			newColl(mongo.HaltErrorAction), natsClient, event)
+
+		require.ErrorIs(t, err, nats.ErrAckTimeout)
+		require.Empty(t, natsClient.publishOpts)
+	})
+}
+
+func TestConnector_maxPayload(t *testing.T) {
+	c := &Connector{options: Options{
+		natsClient: &mockNatsClient{maxPayload: 1024},
+		tenants: map[string]*tenant{
+			"acme":   {natsClient: &mockNatsClient{maxPayload: 512}},
+			"globex": {natsClient: &mockNatsClient{maxPayload: 2048}},
+		},
+	}}
+
+	require.Equal(t, int64(1024), c.maxPayload(&collection{}))
+	require.Equal(t, int64(512), c.maxPayload(&collection{tenantField: "tenant"}))
+}
+
+func Test_pipeline_inherit(t *testing.T) {
+	defaults := pipeline{publishWorkers: 1, batchSize: 100, rateLimit: 10, encoder: mongo.JsonEncoder}
+
+	t.Run("should inherit all default settings", func(t *testing.T) {
+		require.Equal(t, defaults, pipeline{}.inherit(defaults))
+	})
+	t.Run("should only inherit the settings that are not overridden", func(t *testing.T) {
+		p := pipeline{publishWorkers: 4, encoder: mongo.BsonEncoder}
+
+		require.Equal(t, pipeline{publishWorkers: 4, batchSize: 100, rateLimit: 10, encoder: mongo.BsonEncoder},
+			p.inherit(defaults))
+	})
+}
+
+func TestConnector_Mappings(t *testing.T) {
+	t.Run("should list the mappings of the watched collections", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		conn, err := New(
+			WithContext(ctx),
+			withMongoClient(&mockMongoClient{}),
+			withNatsClient(&mockNatsClient{}),
+			WithServerDisabled(),
+			WithCollection("shop", "orders", WithStreamName("ORDERS"), WithPartitions(4),
+				WithEventTypes(map[string]string{"delete": "removed"}), WithPayloadMode("slim"),
+				WithRoute("ORDERS_EU", map[string]string{"region": "eu"}, "")),
+			WithCollection("shop", "invoices", WithStreamName("INVOICES"), WithPublishMode("core"),
+				WithCollectionPipeline(WithEncoder("bson"))),
+		)
+		require.NoError(t, err)
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return len(conn.Mappings()) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		mappings := conn.Mappings()
+		cancel()
+		require.NoError(t, <-runErr)
+
+		require.Equal(t, "shop.invoices", mappings[0].Namespace)
+		require.Equal(t, "bson", mappings[0].Encoder)
+		require.Equal(t, "core", mappings[0].PublishMode)
+		require.Empty(t, mappings[0].Transforms)
+		require.Equal(t, server.Mapping{
+			Namespace:     "shop.orders",
+			Stream:        "ORDERS",
+			SubjectFilter: "ORDERS.*.*",
+			Subjects: map[string]string{"insert": "ORDERS.insert.<partition>",
+				"update": "ORDERS.update.<partition>", "replace": "ORDERS.replace.<partition>",
+				"delete": "ORDERS.removed.<partition>"},
+			Encoder:     "json",
+			PublishMode: "jetstream",
+			Transforms:  []string{"eventTypes", "slim"},
+			Routes: []server.MappingRoute{{When: map[string]string{"region": "eu"}, Stream: "ORDERS_EU",
+				SubjectFilter: "ORDERS_EU.*.*", Subjects: map[string]string{"insert": "ORDERS_EU.insert.<partition>",
+					"update": "ORDERS_EU.update.<partition>", "replace": "ORDERS_EU.replace.<partition>",
+					"delete": "ORDERS_EU.removed.<partition>"}}},
+		}, mappings[1])
 	})
 }
@@ -0,0 +1,168 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+// The commands which can be broadcast to the control subject. Pausing and resuming the watchers is handled by the
+// Connector, the other commands by the handlers registered by its host with WithControlCommand, if any.
+const (
+	PauseCommand        = "pause"
+	ResumeCommand       = "resume"
+	RotateLogsCommand   = "rotateLogs"
+	ReloadConfigCommand = "reloadConfig"
+)
+
+var (
+	errControlUnauthorized = errors.New("unauthorized")
+	errControlUnsupported  = errors.New("unsupported command")
+)
+
+// ControlHandler handles a command broadcast to the control subject, e.g. reloading the configuration of the process
+// running the Connector. The error returned, if any, is replied to the publisher of the command.
+type ControlHandler func(ctx context.Context) error
+
+// controlCommand is a command broadcast to the control subject, to all the connectors subscribed to it, or only to
+// the one with the given instance id.
+type controlCommand struct {
+	Command string `json:"command"`
+	// Collection is the namespace of the collection the command applies to, e.g. shop.orders. If empty, it applies
+	// to all the watched collections.
+	Collection string `json:"collection,omitempty"`
+	InstanceId string `json:"instanceId,omitempty"`
+}
+
+// controlReply is the reply of a Connector to a command, once handled, if it was published with a reply subject.
+type controlReply struct {
+	InstanceId  string   `json:"instanceId"`
+	Command     string   `json:"command"`
+	Collections []string `json:"collections,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// serveControl handles the commands received from the control subject until the given context is cancelled.
+func (c *Connector) serveControl(ctx context.Context, msgs <-chan *nats.Msg) {
+	for msg := range msgs {
+		reply := c.handleControl(ctx, msg)
+		if reply == nil || msg.Reply == "" {
+			continue
+		}
+		data, err := json.Marshal(reply)
+		if err != nil {
+			c.logger.Warn("could not encode control reply", "command", reply.Command, "err", err)
+			continue
+		}
+		opts := &nats.PublishOptions{
+			Subj:    msg.Reply,
+			Data:    data,
+			Mode:    nats.CorePublishMode,
+			Headers: map[string]string{contentTypeHdr: mongo.JsonEncoder.ContentType()},
+		}
+		// the commands stopping the Connector, e.g. reloading its configuration, are replied to too
+		if err := c.options.natsClient.Publish(context.WithoutCancel(ctx), opts); err != nil {
+			c.logger.Warn("could not reply to control command", "command", reply.Command, "err", err)
+		}
+	}
+}
+
+// handleControl handles the given control message, and returns its reply, or nil if the command targets another
+// instance.
+func (c *Connector) handleControl(ctx context.Context, msg *nats.Msg) *controlReply {
+	var cmd controlCommand
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		c.logger.Warn("invalid control command", "subj", msg.Subj, "err", err)
+		return &controlReply{InstanceId: c.options.instanceId, Error: fmt.Sprintf("invalid command: %v", err)}
+	}
+	if cmd.InstanceId != "" && cmd.InstanceId != c.options.instanceId {
+		return nil
+	}
+	reply := &controlReply{InstanceId: c.options.instanceId, Command: cmd.Command}
+	if err := c.authenticateControl(msg); err != nil {
+		c.logger.Warn("control command rejected", "command", cmd.Command, "err", err)
+		reply.Error = errControlUnauthorized.Error()
+		return reply
+	}
+
+	var err error
+	switch cmd.Command {
+	case PauseCommand, ResumeCommand:
+		reply.Collections, err = c.toggleWatchers(ctx, cmd.Collection, cmd.Command == PauseCommand)
+	default:
+		handler, ok := c.options.controlHandlers[cmd.Command]
+		if !ok {
+			err = fmt.Errorf("%w: %q", errControlUnsupported, cmd.Command)
+			break
+		}
+		err = handler(ctx)
+	}
+	if err != nil {
+		c.logger.Error("control command failed", "command", cmd.Command, "collection", cmd.Collection, "err", err)
+		reply.Error = err.Error()
+		return reply
+	}
+	c.logger.Info("control command handled", "command", cmd.Command, "collection", cmd.Collection)
+	return reply
+}
+
+// authenticateControl authenticates the given control message with the bearer token of its Authorization header, as
+// the requests to the HTTP server are, unless unauthenticated commands are allowed.
+func (c *Connector) authenticateControl(msg *nats.Msg) error {
+	if c.options.controlUnauthenticated {
+		return nil
+	}
+	if len(c.options.serverAuthenticators) == 0 {
+		return errors.New("no authenticator configured")
+	}
+	r, err := http.NewRequest(http.MethodPost, "/", nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range msg.Headers {
+		if strings.EqualFold(k, "Authorization") { // nats headers are case-sensitive
+			r.Header.Set("Authorization", v)
+		}
+	}
+	var errs []error
+	for _, authenticator := range c.options.serverAuthenticators {
+		err := authenticator.Authenticate(r)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// toggleWatchers disables or enables the watcher of the given collection, or the ones of all the watched collections
+// if it is empty, and returns their namespaces.
+func (c *Connector) toggleWatchers(ctx context.Context, namespace string, disabled bool) ([]string, error) {
+	namespaces := []string{namespace}
+	if namespace == "" {
+		namespaces = make([]string, 0, len(c.options.collections))
+		for _, coll := range c.options.collections {
+			namespaces = append(namespaces, coll.namespace())
+		}
+		slices.Sort(namespaces)
+	}
+	var errs []error
+	for _, ns := range namespaces {
+		if err := c.toggleWatcher(ctx, ns, disabled); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return namespaces, errors.Join(errs...)
+}
+
+// validControlCommand returns true if the given name can be registered as the name of a control command.
+func validControlCommand(name string) bool {
+	return name != "" && name != PauseCommand && name != ResumeCommand && !strings.ContainsAny(name, " \t\r\n")
+}
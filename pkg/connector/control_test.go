@@ -0,0 +1,185 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+func TestConnector_handleControl(t *testing.T) {
+	newAuthenticatedConnector := func(t *testing.T, opts ...Option) *Connector {
+		conn, err := New(append([]Option{
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithServerDisabled(),
+			WithInstanceId("connector-0"),
+			WithControlSubject(""),
+			WithCollection("shop", "orders"),
+			WithCollection("shop", "customers"),
+		}, opts...)...)
+		require.NoError(t, err)
+		return conn
+	}
+	newConnector := func(t *testing.T, opts ...Option) *Connector {
+		return newAuthenticatedConnector(t, append([]Option{WithControlUnauthenticated()}, opts...)...)
+	}
+	disabled := func(conn *Connector) []string {
+		var namespaces []string
+		for _, namespace := range []string{"shop.customers", "shop.orders"} {
+			if conn.watcherToggle(namespace).disabled {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		return namespaces
+	}
+
+	t.Run("should pause and resume the watchers of all the collections", func(t *testing.T) {
+		conn := newConnector(t)
+
+		reply := conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"pause"}`)})
+
+		require.Equal(t, &controlReply{InstanceId: "connector-0", Command: PauseCommand,
+			Collections: []string{"shop.customers", "shop.orders"}}, reply)
+		require.Equal(t, []string{"shop.customers", "shop.orders"}, disabled(conn))
+
+		reply = conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"resume"}`)})
+
+		require.Empty(t, reply.Error)
+		require.Empty(t, disabled(conn))
+	})
+	t.Run("should resume the watcher of the given collection only", func(t *testing.T) {
+		conn := newConnector(t)
+		conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"pause"}`)})
+
+		reply := conn.handleControl(context.Background(),
+			&nats.Msg{Data: []byte(`{"command":"resume","collection":"shop.orders"}`)})
+
+		require.Equal(t, &controlReply{InstanceId: "connector-0", Command: ResumeCommand,
+			Collections: []string{"shop.orders"}}, reply)
+		require.Equal(t, []string{"shop.customers"}, disabled(conn))
+	})
+	t.Run("should reply with error if the collection is not watched", func(t *testing.T) {
+		conn := newConnector(t)
+
+		reply := conn.handleControl(context.Background(),
+			&nats.Msg{Data: []byte(`{"command":"pause","collection":"shop.unknown"}`)})
+
+		require.Contains(t, reply.Error, "shop.unknown")
+		require.Empty(t, disabled(conn))
+	})
+	t.Run("should ignore the commands targeting another instance", func(t *testing.T) {
+		conn := newConnector(t)
+
+		require.Nil(t, conn.handleControl(context.Background(),
+			&nats.Msg{Data: []byte(`{"command":"pause","instanceId":"connector-1"}`)}))
+		require.Empty(t, disabled(conn))
+
+		reply := conn.handleControl(context.Background(),
+			&nats.Msg{Data: []byte(`{"command":"pause","instanceId":"connector-0"}`)})
+		require.Empty(t, reply.Error)
+		require.Len(t, disabled(conn), 2)
+	})
+	t.Run("should handle the commands with their registered handler", func(t *testing.T) {
+		reloaded := 0
+		conn := newConnector(t,
+			WithControlCommand(ReloadConfigCommand, func(context.Context) error {
+				reloaded++
+				return nil
+			}),
+			WithControlCommand(RotateLogsCommand, func(context.Context) error {
+				return errors.New("could not rotate logs")
+			}),
+		)
+
+		reply := conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"reloadConfig"}`)})
+		require.Equal(t, &controlReply{InstanceId: "connector-0", Command: ReloadConfigCommand}, reply)
+		require.Equal(t, 1, reloaded)
+
+		reply = conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"rotateLogs"}`)})
+		require.Equal(t, "could not rotate logs", reply.Error)
+	})
+	t.Run("should reply with error if the command is unsupported or invalid", func(t *testing.T) {
+		conn := newConnector(t)
+
+		reply := conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"reloadConfig"}`)})
+		require.Equal(t, `unsupported command: "reloadConfig"`, reply.Error)
+
+		reply = conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`pause`)})
+		require.Contains(t, reply.Error, "invalid command")
+	})
+	t.Run("should reject the commands if no authenticator is configured", func(t *testing.T) {
+		conn := newConnector(t)
+		conn.options.controlUnauthenticated = false // bypassing the check of New
+
+		reply := conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"pause"}`)})
+
+		require.Equal(t, "unauthorized", reply.Error)
+		require.Empty(t, disabled(conn))
+	})
+	t.Run("should authenticate the commands as the requests to the server", func(t *testing.T) {
+		tokensFile := filepath.Join(t.TempDir(), "tokens")
+		require.NoError(t, os.WriteFile(tokensFile, []byte("s3cr3t\n"), 0o600))
+		conn := newAuthenticatedConnector(t, WithServerBearerTokensFile(tokensFile))
+
+		reply := conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"pause"}`)})
+		require.Equal(t, "unauthorized", reply.Error)
+		require.Empty(t, disabled(conn))
+
+		reply = conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"pause"}`),
+			Headers: map[string]string{"Authorization": "Bearer wrong"}})
+		require.Equal(t, "unauthorized", reply.Error)
+		require.Empty(t, disabled(conn))
+
+		reply = conn.handleControl(context.Background(), &nats.Msg{Data: []byte(`{"command":"pause"}`),
+			Headers: map[string]string{"authorization": "Bearer s3cr3t"}})
+		require.Empty(t, reply.Error)
+		require.Len(t, disabled(conn), 2)
+	})
+}
+
+func TestConnector_serveControl(t *testing.T) {
+	natsClient := &mockNatsClient{}
+	conn, err := New(
+		withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+		withNatsClient(natsClient),          // avoid connecting to a real nats instance
+		WithServerDisabled(),
+		WithInstanceId("connector-0"),
+		WithControlUnauthenticated(),
+		WithCollection("shop", "orders"),
+	)
+	require.NoError(t, err)
+	msgs := make(chan *nats.Msg, 2)
+	msgs <- &nats.Msg{Data: []byte(`{"command":"pause"}`)}
+	msgs <- &nats.Msg{Data: []byte(`{"command":"resume"}`), Reply: "_INBOX.reply"}
+	close(msgs)
+
+	conn.serveControl(context.Background(), msgs)
+
+	require.Len(t, natsClient.publishOpts, 1, "only the commands published with a reply subject are replied to")
+	opts := natsClient.publishOpts[0]
+	require.Equal(t, "_INBOX.reply", opts.Subj)
+	require.Equal(t, nats.CorePublishMode, opts.Mode)
+	var reply controlReply
+	require.NoError(t, json.Unmarshal(opts.Data, &reply))
+	require.Equal(t, controlReply{InstanceId: "connector-0", Command: ResumeCommand,
+		Collections: []string{"shop.orders"}}, reply)
+	require.False(t, conn.watcherToggle("shop.orders").disabled)
+}
+
+func TestWithControlCommand(t *testing.T) {
+	handler := func(context.Context) error { return nil }
+	for _, name := range []string{"", PauseCommand, ResumeCommand, "reload config"} {
+		require.ErrorIs(t, WithControlCommand(name, handler)(&Options{}), ErrInvalidControl, name)
+	}
+	require.ErrorIs(t, WithControlCommand(ReloadConfigCommand, nil)(&Options{}), ErrInvalidControl)
+	o := &Options{}
+	require.NoError(t, WithControlCommand(ReloadConfigCommand, handler)(o))
+	require.Contains(t, o.controlHandlers, ReloadConfigCommand)
+}
@@ -0,0 +1,152 @@
+package connector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+// eventKind represents the kind of a lifecycle or progress event, which makes up the subject it is published to along
+// with the subject prefix and its collection, e.g. $CONNECTOR.events.watcher.started.<db>.<coll>.
+type eventKind string
+
+const (
+	watcherStartedEvent    eventKind = "watcher.started"
+	watcherStoppedEvent    eventKind = "watcher.stopped"
+	watcherFailedEvent     eventKind = "watcher.failed"
+	tokenAdvancedEvent     eventKind = "token.advanced"
+	snapshotCompletedEvent eventKind = "snapshot.completed"
+	snapshotFailedEvent    eventKind = "snapshot.failed"
+)
+
+// eventTypePrefix prefixes the type of the events, as the one of JetStream advisories is, e.g.
+// io.nats.jetstream.advisory.v1.consumer_action.
+const eventTypePrefix = "io.mongodb-nats-connector.event.v1."
+
+const (
+	// stoppedDisabled is the reason of a watcher stopped once disabled via the admin api.
+	stoppedDisabled = "disabled"
+
+	// stoppedShutdown is the reason of a watcher stopped once the Connector shuts down.
+	stoppedShutdown = "shutdown"
+)
+
+// event is a lifecycle or progress event of a watched collection, e.g. once its watcher starts, published so that other
+// systems can react to it.
+type event struct {
+	Type       string    `json:"type"`
+	Id         string    `json:"id"`
+	Time       time.Time `json:"timestamp"`
+	InstanceId string    `json:"instanceId"`
+	Database   string    `json:"database"`
+	Collection string    `json:"collection"`
+
+	// Reason is the reason why a watcher stopped, i.e. disabled or shutdown.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// TokenTime is the cluster time of the resume token advanced to.
+	TokenTime *time.Time `json:"tokenTime,omitempty"`
+
+	// Snapshot is the id of a snapshot, and Documents the number of documents it published once completed.
+	Snapshot  string `json:"snapshot,omitempty"`
+	Documents *int   `json:"documents,omitempty"`
+}
+
+// publishEvent publishes the given event of the given kind about the given collection to its subject, if events are
+// published. Events are best-effort: if one cannot be published, the failure is logged.
+func (c *Connector) publishEvent(ctx context.Context, kind eventKind, dbName, collName string, e *event) {
+	if c.options.eventsSubjectPrefix == "" {
+		return
+	}
+	e.Type = eventTypePrefix + strings.ReplaceAll(string(kind), ".", "_")
+	e.Id = newEventId()
+	e.Time = time.Now().UTC()
+	e.InstanceId = c.options.instanceId
+	e.Database = dbName
+	e.Collection = collName
+	data, err := json.Marshal(e)
+	if err != nil {
+		c.logger.Warn("could not encode event", "event", kind, "err", err)
+		return
+	}
+	headers := maps.Clone(c.headers)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	headers[contentTypeHdr] = mongo.JsonEncoder.ContentType()
+	opts := &nats.PublishOptions{
+		Subj:    c.eventSubject(kind, dbName, collName),
+		Data:    data,
+		MsgId:   e.Id,
+		Mode:    nats.CorePublishMode,
+		Headers: headers,
+	}
+	// the events of the watchers stopped once the Connector shuts down are published too
+	if err := c.options.natsClient.Publish(context.WithoutCancel(ctx), opts); err != nil {
+		c.logger.Warn("could not publish event", "subj", opts.Subj, "err", err)
+	}
+}
+
+// eventSubject returns the subject of the events of the given kind about the given collection, whose names are made
+// single subject tokens, e.g. the dots of collection names being replaced by underscores.
+func (c *Connector) eventSubject(kind eventKind, dbName, collName string) string {
+	return fmt.Sprintf("%v.%v.%v.%v", c.options.eventsSubjectPrefix, kind, subjectToken(dbName),
+		subjectToken(collName))
+}
+
+func subjectToken(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(". \t\r\n*>", r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func newEventId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// publishTokenEvents publishes a token advanced event for each collection whose stored resume token advanced since its
+// previous one, on each token interval, until the given context is cancelled, so that the progress of the watchers can
+// be followed without an event for each change event.
+func (c *Connector) publishTokenEvents(ctx context.Context) {
+	ticker := time.NewTicker(c.options.eventsTokenInterval)
+	defer ticker.Stop()
+	published := make(map[string]time.Time)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, token := range c.advancedTokens(published) {
+			c.publishEvent(ctx, tokenAdvancedEvent, token.dbName, token.collName, &event{TokenTime: &token.time})
+		}
+	}
+}
+
+// advancedTokens returns the stored resume tokens which advanced since the given published ones, which are updated.
+func (c *Connector) advancedTokens(published map[string]time.Time) []storedToken {
+	c.storedTokensMu.Lock()
+	defer c.storedTokensMu.Unlock()
+	var advanced []storedToken
+	for namespace, token := range c.storedTokens {
+		if token.time.After(published[namespace]) {
+			published[namespace] = token.time
+			advanced = append(advanced, *token)
+		}
+	}
+	return advanced
+}
@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+func TestConnector_publishEvent(t *testing.T) {
+	t.Run("should publish the event to the subject of its kind and collection", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		c := &Connector{
+			logger:  slog.Default(),
+			headers: map[string]string{instanceIdHdr: "connector-0"},
+			options: Options{natsClient: natsClient, instanceId: "connector-0",
+				eventsSubjectPrefix: "$CONNECTOR.events"},
+		}
+		documents := 42
+
+		c.publishEvent(context.Background(), snapshotCompletedEvent, "shop", "orders.archive",
+			&event{Snapshot: "snapshot-20240501T120000Z", Documents: &documents})
+
+		require.Len(t, natsClient.publishOpts, 1)
+		opts := natsClient.publishOpts[0]
+		require.Equal(t, "$CONNECTOR.events.snapshot.completed.shop.orders_archive", opts.Subj)
+		require.Equal(t, nats.CorePublishMode, opts.Mode)
+		require.Equal(t, map[string]string{instanceIdHdr: "connector-0", contentTypeHdr: "application/json"},
+			opts.Headers)
+		var e map[string]any
+		require.NoError(t, json.Unmarshal(opts.Data, &e))
+		require.Equal(t, opts.MsgId, e["id"])
+		require.NotEmpty(t, e["timestamp"])
+		delete(e, "id")
+		delete(e, "timestamp")
+		require.Equal(t, map[string]any{
+			"type":       "io.mongodb-nats-connector.event.v1.snapshot_completed",
+			"instanceId": "connector-0",
+			"database":   "shop",
+			"collection": "orders.archive",
+			"snapshot":   "snapshot-20240501T120000Z",
+			"documents":  42.0,
+		}, e)
+	})
+	t.Run("should not publish events if they are not enabled", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		c := &Connector{logger: slog.Default(), options: Options{natsClient: natsClient}}
+
+		c.publishEvent(context.Background(), watcherStartedEvent, "shop", "orders", &event{})
+
+		require.Empty(t, natsClient.publishOpts)
+	})
+	t.Run("should publish the event once the context is cancelled", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		c := &Connector{logger: slog.Default(),
+			options: Options{natsClient: natsClient, eventsSubjectPrefix: "$CONNECTOR.events"}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c.publishEvent(ctx, watcherStoppedEvent, "shop", "orders", &event{Reason: stoppedShutdown})
+
+		require.Len(t, natsClient.publishOpts, 1)
+	})
+}
+
+func TestConnector_publishTokenEvents(t *testing.T) {
+	natsClient := &mockNatsClient{}
+	c := &Connector{
+		logger:       slog.Default(),
+		storedTokens: make(map[string]*storedToken),
+		options: Options{natsClient: natsClient, eventsSubjectPrefix: "$CONNECTOR.events",
+			eventsTokenInterval: 10 * time.Millisecond},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.publishTokenEvents(ctx)
+	tokenEvents := func() []string {
+		natsClient.mup.Lock()
+		defer natsClient.mup.Unlock()
+		var events []string
+		for _, opts := range natsClient.publishOpts {
+			var e event
+			require.NoError(t, json.Unmarshal(opts.Data, &e))
+			events = append(events, opts.Subj+" "+e.TokenTime.UTC().Format(time.RFC3339))
+		}
+		return events
+	}
+
+	c.recordStoredToken("shop", "orders", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	c.recordStoredToken("shop", "orders", time.Date(2024, 5, 1, 12, 0, 1, 0, time.UTC))
+	require.Eventually(t, func() bool { return len(tokenEvents()) == 1 }, 1*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, []string{"$CONNECTOR.events.token.advanced.shop.orders 2024-05-01T12:00:01Z"}, tokenEvents(),
+		"one event per interval, and none once the token did not advance")
+
+	c.recordStoredToken("shop", "orders", time.Date(2024, 5, 1, 12, 0, 2, 0, time.UTC))
+	require.Eventually(t, func() bool { return len(tokenEvents()) == 2 }, 1*time.Second, 10*time.Millisecond)
+	require.Equal(t, "$CONNECTOR.events.token.advanced.shop.orders 2024-05-01T12:00:02Z", tokenEvents()[1])
+}
+
+func Test_subjectToken(t *testing.T) {
+	require.Equal(t, "orders", subjectToken("orders"))
+	require.Equal(t, "orders_archive_2024", subjectToken("orders.archive 2024"))
+	require.Equal(t, "a_b_", subjectToken("a*b>"))
+}
+
+// publishedEvents returns the events published about the given collection, as their subject followed by the reason
+// why their watcher stopped or by their error.
+func publishedEvents(t *testing.T, natsClient *mockNatsClient, namespace string) []string {
+	natsClient.mup.Lock()
+	defer natsClient.mup.Unlock()
+	var events []string
+	for _, opts := range natsClient.publishOpts {
+		if !strings.HasPrefix(opts.Subj, defaultEventsSubjectPrefix+".") {
+			continue
+		}
+		var e event
+		require.NoError(t, json.Unmarshal(opts.Data, &e))
+		if e.Database+"."+e.Collection == namespace {
+			events = append(events, opts.Subj+" "+e.Reason+e.Error)
+		}
+	}
+	return events
+}
@@ -0,0 +1,208 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/notify"
+)
+
+// publisherLeaseTtlFactor is the number of guard intervals a publisher lease expires after, if it is not renewed.
+const publisherLeaseTtlFactor = 3
+
+// publisherLease is the lease held on a stream by the Connector publishing to it.
+type publisherLease struct {
+	InstanceId string    `json:"instanceId"`
+	RenewedAt  time.Time `json:"renewedAt"`
+}
+
+// publisherGuard ensures that the Connector is the only one publishing to the streams of its collections: it holds a
+// lease on each of them, renewed every guard interval, and checks that their last messages were not published by
+// another Connector since the leases were acquired.
+type publisherGuard struct {
+	c       *Connector
+	kv      nats.KeyValue
+	streams []string
+	// revisions holds the revision of the lease last put on each stream.
+	revisions map[string]uint64
+	// baselines holds the sequence of the last message of each stream before the Connector published to it.
+	baselines map[string]uint64
+}
+
+// guardedStreams returns the streams the Connector publishes the change events of its collections to with its own NATS
+// client, i.e. the streams of the collections published to JetStream, and not routed to tenants.
+func (c *Connector) guardedStreams() []string {
+	var streams []string
+	for _, coll := range c.options.collections {
+		if c.guarded(coll) && !slices.Contains(streams, coll.streamName) {
+			streams = append(streams, coll.streamName)
+		}
+	}
+	return streams
+}
+
+func (c *Connector) guarded(coll *collection) bool {
+	return coll.publishMode == nats.JetStreamPublishMode && coll.tenantField == "" && !coll.tenantDbName
+}
+
+// acquirePublisherLeases acquires the lease of each guarded stream, waiting for the leases held by another Connector to
+// expire. It returns a nil publisherGuard if the given context is cancelled meanwhile.
+func (c *Connector) acquirePublisherLeases(ctx context.Context) (*publisherGuard, error) {
+	kv, err := c.options.natsClient.KeyValue(c.options.publisherLeaseBucket)
+	if err != nil {
+		return nil, err
+	}
+	g := &publisherGuard{
+		c:         c,
+		kv:        kv,
+		streams:   c.guardedStreams(),
+		revisions: make(map[string]uint64),
+		baselines: make(map[string]uint64),
+	}
+	for _, stream := range g.streams {
+		for {
+			heldBy, err := g.renew(stream, true)
+			if err != nil {
+				return nil, err
+			}
+			if heldBy == "" {
+				break
+			}
+			c.logger.Warn("waiting for the publisher lease of the stream held by another connector", "stream", stream,
+				"heldBy", heldBy)
+			select {
+			case <-ctx.Done():
+				return nil, nil
+			case <-time.After(c.options.publisherGuardInterval):
+			}
+		}
+	}
+	return g, nil
+}
+
+// renew puts the lease of the given stream, and returns an empty string, unless it is held by another Connector, in
+// which case it returns its instance id. If acquiring is true, the leases which expired are taken over; otherwise, the
+// lease must still be the one last put by the Connector.
+func (g *publisherGuard) renew(stream string, acquiring bool) (string, error) {
+	value, revision, err := g.kv.GetRevision(stream)
+	if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return "", err
+	}
+	if err == nil {
+		lease := &publisherLease{}
+		if err = json.Unmarshal(value, lease); err != nil {
+			return "", fmt.Errorf("could not decode publisher lease of stream %v: %v", stream, err)
+		}
+		ttl := publisherLeaseTtlFactor * g.c.options.publisherGuardInterval
+		switch {
+		case acquiring && lease.InstanceId != g.c.options.instanceId && time.Since(lease.RenewedAt) < ttl:
+			return lease.InstanceId, nil
+		case !acquiring && (lease.InstanceId != g.c.options.instanceId || revision != g.revisions[stream]):
+			// a lease renewed by another Connector with the same instance id is detected by its revision
+			return lease.InstanceId, nil
+		}
+	} else if !acquiring {
+		return "unknown", nil
+	}
+
+	data, err := json.Marshal(&publisherLease{InstanceId: g.c.options.instanceId, RenewedAt: time.Now().UTC()})
+	if err != nil {
+		return "", err
+	}
+	revision, err = g.kv.Update(stream, data, revision)
+	if errors.Is(err, nats.ErrRevisionMismatch) { // put by another Connector meanwhile
+		return "unknown", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	g.revisions[stream] = revision
+	return "", nil
+}
+
+// baseline records the sequence of the last message of the stream of the given collection, if it is guarded and not
+// recorded yet, before the Connector publishes to it.
+func (g *publisherGuard) baseline(ctx context.Context, coll *collection) error {
+	if !g.c.guarded(coll) {
+		return nil
+	}
+	if _, ok := g.baselines[coll.streamName]; ok {
+		return nil
+	}
+	seq, _, err := g.c.options.natsClient.LastMsgHeader(ctx, coll.streamName, instanceIdHdr)
+	if err != nil {
+		return err
+	}
+	g.baselines[coll.streamName] = seq
+	return nil
+}
+
+// guardPublisher renews the publisher leases and checks the last messages of the guarded streams every guard interval,
+// until the given context is cancelled, then releases the leases. It returns ErrDualPublisher, and alerts, once another
+// Connector is found publishing to them.
+func (c *Connector) guardPublisher(ctx context.Context, g *publisherGuard) error {
+	defer g.release()
+	ticker := time.NewTicker(c.options.publisherGuardInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		for _, stream := range g.streams {
+			publisher, err := g.check(ctx, stream)
+			if err != nil {
+				if ctx.Err() == nil {
+					c.logger.Warn("could not check the publisher of the stream", "stream", stream, "err", err)
+				}
+				continue
+			}
+			if publisher != "" {
+				err = fmt.Errorf("%w: stream %v is published to by %v", ErrDualPublisher, stream, publisher)
+				c.logger.Error("another connector is publishing to the stream, stopping", "stream", stream,
+					"publisher", publisher)
+				c.notify(notify.DualPublisherAlert, "", "", "another connector is publishing to the stream, stopping",
+					err)
+				return err
+			}
+		}
+	}
+}
+
+// check renews the lease of the given stream, then checks its last message. It returns the instance id of the other
+// Connector publishing to it, if any.
+func (g *publisherGuard) check(ctx context.Context, stream string) (string, error) {
+	heldBy, err := g.renew(stream, false)
+	if err != nil || heldBy != "" {
+		return heldBy, err
+	}
+	seq, origin, err := g.c.options.natsClient.LastMsgHeader(ctx, stream, instanceIdHdr)
+	if err != nil {
+		return "", err
+	}
+	g.c.streamSequencesMu.Lock()
+	acked := g.c.streamSequences[stream]
+	g.c.streamSequencesMu.Unlock()
+	if origin != "" && origin != g.c.options.instanceId && seq > g.baselines[stream] && seq > acked {
+		return origin, nil
+	}
+	return "", nil
+}
+
+// release deletes the leases still held by the Connector, so that another Connector can acquire them right away.
+func (g *publisherGuard) release() {
+	for _, stream := range g.streams {
+		if _, revision, err := g.kv.GetRevision(stream); err != nil || revision != g.revisions[stream] {
+			continue
+		}
+		if err := g.kv.Delete(stream); err != nil {
+			g.c.logger.Warn("could not release the publisher lease of the stream", "stream", stream, "err", err)
+		}
+	}
+}
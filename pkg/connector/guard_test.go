@@ -0,0 +1,108 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnector_guardPublisher(t *testing.T) {
+	newConnector := func(natsClient *mockNatsClient) *Connector {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}),
+			withNatsClient(natsClient),
+			WithServerDisabled(),
+			WithInstanceId("connector-0"),
+			WithPublisherGuard("publisher-leases", 10*time.Millisecond),
+			WithCollection("shop", "orders", WithStreamName("ORDERS")),
+			WithCollection("shop", "refunds", WithStreamName("ORDERS")),
+			WithCollection("shop", "events", WithStreamName("EVENTS"), WithPublishMode("core")),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+	putLease := func(natsClient *mockNatsClient, instanceId string, renewedAt time.Time) {
+		kv, _ := natsClient.KeyValue("publisher-leases")
+		data, _ := json.Marshal(&publisherLease{InstanceId: instanceId, RenewedAt: renewedAt})
+		require.NoError(t, kv.Put("ORDERS", data))
+	}
+	acquire := func(conn *Connector) *publisherGuard {
+		guard, err := conn.acquirePublisherLeases(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, guard)
+		for _, coll := range conn.options.collections {
+			require.NoError(t, guard.baseline(context.Background(), coll))
+		}
+		return guard
+	}
+
+	t.Run("should hold the leases until stopped, then release them", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		natsClient.SimulateLastMsg("ORDERS", 5, "connector-1") // published by the previous connector
+		conn := newConnector(natsClient)
+		guard := acquire(conn)
+		require.Equal(t, []string{"ORDERS"}, guard.streams)
+		require.Equal(t, map[string]uint64{"ORDERS": 5}, guard.baselines)
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.guardPublisher(ctx, guard)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		natsClient.SimulateLastMsg("ORDERS", 6, "connector-0")
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		require.NoError(t, <-errCh)
+		kv, _ := natsClient.KeyValue("publisher-leases")
+		_, err := kv.Get("ORDERS")
+		require.ErrorIs(t, err, nats.ErrKeyNotFound)
+	})
+	t.Run("should stop once another connector published to the stream", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		conn := newConnector(natsClient)
+		guard := acquire(conn)
+
+		natsClient.SimulateLastMsg("ORDERS", 1, "connector-1")
+		err := conn.guardPublisher(context.Background(), guard)
+
+		require.ErrorIs(t, err, ErrDualPublisher)
+		require.ErrorContains(t, err, "connector-1")
+	})
+	t.Run("should stop once another connector took the lease over", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		conn := newConnector(natsClient)
+		guard := acquire(conn)
+
+		putLease(natsClient, "connector-0", time.Now()) // e.g. another connector with the same instance id
+		err := conn.guardPublisher(context.Background(), guard)
+
+		require.ErrorIs(t, err, ErrDualPublisher)
+	})
+	t.Run("should take the expired leases over", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		putLease(natsClient, "connector-1", time.Now().Add(-time.Second))
+		conn := newConnector(natsClient)
+
+		guard := acquire(conn)
+
+		require.NotZero(t, guard.revisions["ORDERS"])
+	})
+	t.Run("should wait for the leases held by another connector", func(t *testing.T) {
+		natsClient := &mockNatsClient{}
+		putLease(natsClient, "connector-1", time.Now().Add(time.Hour))
+		conn := newConnector(natsClient)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		guard, err := conn.acquirePublisherLeases(ctx)
+
+		require.NoError(t, err)
+		require.Nil(t, guard)
+	})
+}
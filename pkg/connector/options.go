@@ -0,0 +1,1015 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/connectorhook"
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/notify"
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+)
+
+// Options represents the possible options to be applied to a Connector.
+type Options struct {
+
+	// logLevel represents the Connector's log level.
+	// Can be set to 'info', 'debug', 'warn', or 'error'.
+	logLevel slog.Level
+
+	// logSampling represents whether the Connector's debug logs are sampled. If so, within each logSamplingInterval, the
+	// first logSamplingFirst debug logs of each message are logged, then one out of every logSamplingThereafter.
+	logSampling           bool
+	logSamplingFirst      int
+	logSamplingThereafter int
+	logSamplingInterval   time.Duration
+
+	// logMetadataOnly represents whether only the metadata of the change events and messages are logged at debug level,
+	// instead of their whole content.
+	logMetadataOnly bool
+
+	// mongoUri represents the Connector's MongoDB URI.
+	mongoUri string
+
+	// mongoKeyVaultNamespace and mongoKmsProviders represent the key vault and the KMS providers the Connector's MongoDB
+	// client decrypts encrypted fields with. If the namespace is empty, encrypted fields are not decrypted.
+	mongoKeyVaultNamespace string
+	mongoKmsProviders      map[string]map[string]string
+
+	// mongoClient represents the MongoDB client used by the Connector to connect to MongoDB.
+	mongoClient mongo.Client
+
+	// natsUrl represents the Connector's NATS URL.
+	natsUrl string
+
+	// natsClient represents the NATS client used by the Connector to connect to NATS.
+	natsClient nats.Client
+
+	// natsCredsFile represents the file holding the credentials of the Connector's NATS connection.
+	natsCredsFile string
+
+	// natsCertFile, natsKeyFile and natsCaFile represent the TLS files of the Connector's NATS connection.
+	natsCertFile string
+	natsKeyFile  string
+	natsCaFile   string
+
+	// instanceId represents the stable identifier of the Connector's instance.
+	instanceId string
+
+	// labels represents the user-defined labels of the Connector's instance, e.g. its environment or region.
+	labels map[string]string
+
+	// tenants represents the tenants whose change events are published to their own NATS account, by tenant name.
+	tenants map[string]*tenant
+
+	// ctx represents the Connector's context.
+	ctx  context.Context
+	stop context.CancelFunc
+
+	// shutdownTimeout represents the maximum amount of time the Connector waits for in-flight change events to be
+	// published, and their resume tokens persisted, before forcing the shutdown.
+	shutdownTimeout time.Duration
+
+	// maxRestartTime represents the expected worst-case amount of time it takes to restart the Connector.
+	// It is used to validate that the duplicates window of each stream covers the change events replayed on restart.
+	maxRestartTime time.Duration
+
+	// serverAddr represents the Connector's HTTP server addresses. If serverAdminAddr is set, only the health checks
+	// are served on serverAddr, and all the endpoints on serverAdminAddr.
+	serverAddr      string
+	serverAdminAddr string
+
+	// serverGrpcAddr represents the addresses the gRPC admin api of the Connector is served on, if any.
+	serverGrpcAddr string
+
+	// serverReadTimeout, serverWriteTimeout, serverIdleTimeout and serverMaxHeaderBytes represent the limits of the
+	// Connector's HTTP server, and serverShutdownTimeout the maximum amount of time it waits for the requests being
+	// served once the watchers are drained. If zero, the defaults of the server apply.
+	serverReadTimeout     time.Duration
+	serverWriteTimeout    time.Duration
+	serverIdleTimeout     time.Duration
+	serverMaxHeaderBytes  int
+	serverShutdownTimeout time.Duration
+
+	// serverAuthenticators authenticate the requests to all the endpoints of the Connector's HTTP server but the health
+	// checks and the metrics, if any, with the bearer tokens of serverBearerTokensFile, or the JWTs issued by
+	// serverOidcIssuer for serverOidcAudience.
+	serverAuthenticators   []server.Authenticator
+	serverBearerTokensFile string
+	serverOidcIssuer       string
+	serverOidcAudience     string
+
+	// healthDeadlines represent the deadlines of the pings of the monitored components, by kind of component, i.e.
+	// mongo, or nats for the NATS connections of the Connector and of its tenants.
+	healthDeadlines map[string]server.Deadline
+
+	// systemdNotify represents whether systemd is notified once the Connector is ready and when it stops, and whether its
+	// watchdog is pet.
+	systemdNotify bool
+
+	// serverDisabled is true if the Connector does not run its own HTTP server, e.g. when it is hosted by a runtime.
+	serverDisabled bool
+
+	// journalSize represents the maximum number of recently published change events kept in memory for each
+	// collection, exposed by the HTTP server for debugging purposes.
+	journalSize int
+
+	// pipeline represents the default pipeline settings, inherited by each collection that does not override them.
+	pipeline pipeline
+
+	// schemaChangesStream represents the stream where the schema changes of all the watched collections are published.
+	// If empty, schema changes are not published.
+	schemaChangesStream string
+
+	// watcherStatesBucket represents the NATS key-value bucket where the watchers disabled via the admin api are
+	// persisted, so that they are not started once the Connector restarts.
+	watcherStatesBucket string
+
+	// schemaVersionsBucket represents the NATS key-value bucket where the schema version of each collection without
+	// explicit schema version is persisted, and bumped once the options affecting the envelope of its change events
+	// change. If empty, their schema version is always 1.
+	schemaVersionsBucket string
+
+	// watermarkSubject represents the subject where the cluster time up to which all the change events of all the
+	// watched collections are processed is published, every watermarkInterval. If empty, watermarks are not published.
+	watermarkSubject  string
+	watermarkInterval time.Duration
+
+	// eventsSubjectPrefix represents the subject space where the lifecycle and progress events of the Connector are
+	// published, e.g. once a watcher starts, the resume tokens advanced being published every eventsTokenInterval. If
+	// empty, events are not published.
+	eventsSubjectPrefix string
+	eventsTokenInterval time.Duration
+
+	// controlSubject represents the subject the Connector subscribes to, on core NATS, for the commands broadcast to
+	// all the connectors at once, e.g. to pause all their watchers, the commands other than pause and resume being
+	// handled by controlHandlers, by command name. If empty, no command is received. Unless controlUnauthenticated
+	// is set, commands are authenticated as the requests to the HTTP server are, which must be.
+	controlSubject         string
+	controlHandlers        map[string]ControlHandler
+	controlUnauthenticated bool
+
+	// statusBucket represents the NATS key-value bucket the status of the Connector is put into, under its instance id,
+	// every statusInterval. If empty, the status is only served by the HTTP server.
+	statusBucket   string
+	statusInterval time.Duration
+
+	// tokenBackupBucket and tokenBackupPath represent the NATS object store bucket, or the file, where the resume
+	// tokens of all the watched collections are backed up every tokenBackupInterval. If both are empty, they are not.
+	tokenBackupBucket   string
+	tokenBackupPath     string
+	tokenBackupInterval time.Duration
+
+	// publisherLeaseBucket represents the NATS key-value bucket where a lease is held on each stream the Connector
+	// publishes to, renewed every publisherGuardInterval, along with the check of the last message of the stream, so
+	// that the Connector stops instead of interleaving its messages with another one's. If empty, they are not.
+	publisherLeaseBucket   string
+	publisherGuardInterval time.Duration
+
+	// tokenBackupReplicaUrl represents the NATS server of the passive region of an active/passive deployment, whose
+	// tokenBackupBucket the resume tokens are backed up into as well, through tokenBackupReplica.
+	tokenBackupReplicaUrl string
+	tokenBackupReplica    nats.Client
+
+	// metricsExporter represents how the Connector's metrics are exported, i.e. scraped by Prometheus via GET /metrics,
+	// or pushed to the StatsD server listening on statsdAddr every statsdInterval, with their names prefixed by
+	// statsdPrefix.
+	metricsExporter metricsExporter
+	statsdAddr      string
+	statsdPrefix    string
+	statsdInterval  time.Duration
+
+	// pushgatewayUrl represents the Prometheus Pushgateway the Connector's metrics are pushed to under pushgatewayJob,
+	// once a short-lived command completes, e.g. a backfill, if it is set.
+	pushgatewayUrl string
+	pushgatewayJob string
+
+	// oplogWarningHeadroom represents the oplog headroom of the stored resume tokens below which a warning is logged,
+	// and posted to oplogWarningWebhook if it is set.
+	oplogWarningHeadroom time.Duration
+	oplogWarningWebhook  string
+
+	// webhooks represents the webhooks the Connector's alerts are posted to.
+	webhooks []*notify.Webhook
+
+	// retryPolicy represents the retry policy overriding the default ones of the paused publishes, the resume token
+	// saves, and the reconnections to MongoDB, if set.
+	retryPolicy *retry.Policy
+
+	// collections represents a slice containing the collections to be watched, with their own configuration.
+	collections []*collection
+}
+
+func getDefaultOptions() Options {
+	return Options{
+		logLevel:               defaultLogLevel,
+		logSamplingInterval:    defaultLogSamplingInterval,
+		ctx:                    context.Background(),
+		shutdownTimeout:        defaultShutdownTimeout,
+		maxRestartTime:         defaultMaxRestartTime,
+		journalSize:            defaultJournalSize,
+		watermarkInterval:      defaultWatermarkInterval,
+		eventsTokenInterval:    defaultEventsTokenInterval,
+		statusInterval:         defaultStatusInterval,
+		tokenBackupInterval:    defaultTokenBackupInterval,
+		publisherGuardInterval: defaultPublisherGuardInterval,
+		oplogWarningHeadroom:   defaultOplogWarningHeadroom,
+		metricsExporter:        defaultMetricsExporter,
+		statsdAddr:             defaultStatsdAddr,
+		statsdInterval:         defaultStatsdInterval,
+		pushgatewayJob:         defaultPushgatewayJob,
+		pipeline: pipeline{
+			publishWorkers: defaultPublishWorkers,
+			encoder:        defaultEncoder,
+		},
+		collections: make([]*collection, 0),
+		tenants:     make(map[string]*tenant),
+		instanceId:  getDefaultInstanceId(),
+	}
+}
+
+// metricsExporter represents how the Connector's metrics are exported.
+type metricsExporter string
+
+const (
+	// prometheusMetricsExporter exposes the metrics via GET /metrics, to be scraped by Prometheus.
+	prometheusMetricsExporter metricsExporter = "prometheus"
+
+	// statsdMetricsExporter pushes the metrics to a StatsD server, e.g. the Datadog agent, with their labels as
+	// DogStatsD tags.
+	statsdMetricsExporter metricsExporter = "statsd"
+)
+
+var metricsExporters = []metricsExporter{prometheusMetricsExporter, statsdMetricsExporter}
+
+// getDefaultInstanceId returns the hostname, which is stable across restarts in most deployments, e.g. for pods of a
+// Kubernetes StatefulSet.
+func getDefaultInstanceId() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return defaultInstanceId
+}
+
+// Option is used to configure the Connector.
+type Option func(*Options) error
+
+// WithLogLevel sets the Connector's log level.
+func WithLogLevel(logLevel string) Option {
+	return func(o *Options) error {
+		switch strings.ToLower(logLevel) {
+		case "debug":
+			o.logLevel = slog.LevelDebug
+		case "warn":
+			o.logLevel = slog.LevelWarn
+		case "error":
+			o.logLevel = slog.LevelError
+		case "info":
+			o.logLevel = slog.LevelInfo
+		}
+		return nil
+	}
+}
+
+// WithLogSampling samples the Connector's debug logs, e.g. the ones logged for each change event, so that debug logging
+// remains usable at high throughput. Within each interval, 1 second by default, the first debug logs of each message
+// are logged, then one out of every thereafter, or none if it is 0. Info, warn and error logs are never sampled.
+func WithLogSampling(first, thereafter int, interval time.Duration) Option {
+	return func(o *Options) error {
+		if first < 0 || thereafter < 0 || interval < 0 {
+			return ErrInvalidLogSampling
+		}
+		o.logSampling = true
+		o.logSamplingFirst = first
+		o.logSamplingThereafter = thereafter
+		if interval > 0 {
+			o.logSamplingInterval = interval
+		}
+		return nil
+	}
+}
+
+// WithLogMetadataOnly logs only the metadata of the change events received and of the messages published at debug
+// level, e.g. their subject, size and message id, instead of their whole content.
+func WithLogMetadataOnly() Option {
+	return func(o *Options) error {
+		o.logMetadataOnly = true
+		return nil
+	}
+}
+
+// WithMongoUri sets the Connector's MongoDB URI.
+func WithMongoUri(mongoUri string) Option {
+	return func(o *Options) error {
+		if mongoUri != "" {
+			o.mongoUri = mongoUri
+		}
+		return nil
+	}
+}
+
+// WithMongoAutoEncryption makes the Connector's MongoDB client decrypt the fields encrypted with client-side field
+// level encryption, or queryable encryption, with the data keys of the given key vault namespace, e.g.
+// 'encryption.__keyVault', and the given KMS providers, by provider name, e.g. 'local: {key: <base64 master key>}'.
+// It requires the Connector to be built with the cse tag, and libmongocrypt.
+func WithMongoAutoEncryption(keyVaultNamespace string, kmsProviders map[string]map[string]string) Option {
+	return func(o *Options) error {
+		if keyVaultNamespace == "" && len(kmsProviders) == 0 {
+			return nil
+		}
+		db, coll, ok := strings.Cut(keyVaultNamespace, ".")
+		if !ok || db == "" || coll == "" || len(kmsProviders) == 0 {
+			return ErrInvalidAutoEncryption
+		}
+		o.mongoKeyVaultNamespace, o.mongoKmsProviders = keyVaultNamespace, kmsProviders
+		return nil
+	}
+}
+
+// withMongoClient sets the Connector's MongoDB client implementation.
+// Used for testing.
+func withMongoClient(mongoClient mongo.Client) Option {
+	return func(o *Options) error {
+		if mongoClient != nil {
+			o.mongoClient = mongoClient
+		}
+		return nil
+	}
+}
+
+// WithNatsUrl sets the Connector's NATS URL.
+func WithNatsUrl(natsUrl string) Option {
+	return func(o *Options) error {
+		if natsUrl != "" {
+			o.natsUrl = natsUrl
+		}
+		return nil
+	}
+}
+
+// WithNatsCredsFile sets the file holding the user JWT and nkey seed used to authenticate the Connector's NATS
+// connection. The connection is re-established each time the file changes, once in-flight publishes complete, so that
+// rotated credentials are used without restarting the Connector.
+func WithNatsCredsFile(credsFile string) Option {
+	return func(o *Options) error {
+		if credsFile != "" {
+			o.natsCredsFile = credsFile
+		}
+		return nil
+	}
+}
+
+// WithNatsTlsFiles sets the client certificate and key files, and the certificate authorities file, of the
+// Connector's NATS connection. As for the credentials file, the connection is re-established each time they change.
+func WithNatsTlsFiles(certFile, keyFile, caFile string) Option {
+	return func(o *Options) error {
+		if (certFile == "") != (keyFile == "") {
+			return ErrInvalidNatsTlsFiles
+		}
+		o.natsCertFile, o.natsKeyFile = certFile, keyFile
+		if caFile != "" {
+			o.natsCaFile = caFile
+		}
+		return nil
+	}
+}
+
+// withNatsClient sets the Connector's NATS client implementation.
+// Used for testing.
+func withNatsClient(natsClient nats.Client) Option {
+	return func(o *Options) error {
+		if natsClient != nil {
+			o.natsClient = natsClient
+		}
+		return nil
+	}
+}
+
+// init lets the connectortest package set the client implementations, with its fakes.
+func init() {
+	connectorhook.MongoClient = func(mongoClient mongo.Client) any { return withMongoClient(mongoClient) }
+	connectorhook.NatsClient = func(natsClient nats.Client) any { return withNatsClient(natsClient) }
+}
+
+// WithInstanceId sets the stable identifier of the Connector's instance, added to the headers of published change
+// events, to metrics and to the health check output. Defaults to the hostname.
+func WithInstanceId(instanceId string) Option {
+	return func(o *Options) error {
+		if instanceId != "" {
+			o.instanceId = instanceId
+		}
+		return nil
+	}
+}
+
+// WithLabels sets the user-defined labels of the Connector's instance (e.g. env, region), added to the headers of
+// published change events, to metrics and to the health check output.
+func WithLabels(labels map[string]string) Option {
+	return func(o *Options) error {
+		for key := range labels {
+			if !labelKeyRegexp.MatchString(key) || key == instanceIdMetric {
+				return ErrInvalidLabel
+			}
+		}
+		if len(labels) > 0 {
+			o.labels = labels
+		}
+		return nil
+	}
+}
+
+// WithContext sets the Connector's context.
+func WithContext(ctx context.Context) Option {
+	return func(o *Options) error {
+		if ctx != nil {
+			o.ctx = ctx
+		}
+		return nil
+	}
+}
+
+// WithShutdownTimeout sets the maximum amount of time the Connector waits for in-flight change events to be drained
+// during shutdown.
+func WithShutdownTimeout(shutdownTimeout time.Duration) Option {
+	return func(o *Options) error {
+		if shutdownTimeout > 0 {
+			o.shutdownTimeout = shutdownTimeout
+		}
+		return nil
+	}
+}
+
+// WithMaxRestartTime sets the expected worst-case amount of time it takes to restart the Connector.
+func WithMaxRestartTime(maxRestartTime time.Duration) Option {
+	return func(o *Options) error {
+		if maxRestartTime > 0 {
+			o.maxRestartTime = maxRestartTime
+		}
+		return nil
+	}
+}
+
+// WithServerAddr sets the Connector's HTTP server addresses, as a comma-separated list of addresses, each of them either
+// a TCP address, e.g. 127.0.0.1:8080 or :8080 to listen on all interfaces, or the path of a Unix domain socket prefixed
+// with unix:, e.g. unix:/run/connector.sock.
+func WithServerAddr(serverAddr string) Option {
+	return func(o *Options) error {
+		if serverAddr != "" {
+			if !server.ValidAddr(serverAddr) {
+				return ErrInvalidServerAddr
+			}
+			o.serverAddr = serverAddr
+		}
+		return nil
+	}
+}
+
+// WithServerAdminAddr sets the addresses the endpoints of the Connector's HTTP server are served on, e.g. the metrics
+// and the admin api, in the same format as WithServerAddr. Once set, only the health checks are served on the server
+// addresses, so that probes can be exposed without exposing the admin api.
+func WithServerAdminAddr(serverAdminAddr string) Option {
+	return func(o *Options) error {
+		if serverAdminAddr != "" {
+			if !server.ValidAddr(serverAdminAddr) {
+				return ErrInvalidServerAddr
+			}
+			o.serverAdminAddr = serverAdminAddr
+		}
+		return nil
+	}
+}
+
+// WithServerGrpcAddr sets the addresses the gRPC admin api of the Connector is served on, in the same format as
+// WithServerAddr, i.e. the operations of its HTTP admin api, as defined by the connector.v1 proto package, along with
+// the same authentication. The gRPC admin api is not served unless they are set.
+func WithServerGrpcAddr(serverGrpcAddr string) Option {
+	return func(o *Options) error {
+		if serverGrpcAddr != "" {
+			if !server.ValidAddr(serverGrpcAddr) {
+				return ErrInvalidServerAddr
+			}
+			o.serverGrpcAddr = serverGrpcAddr
+		}
+		return nil
+	}
+}
+
+// WithServerTimeouts sets the maximum durations for reading the requests of the Connector's HTTP server, 10 seconds by
+// default, for writing its responses, 70 seconds by default, and for keeping its idle connections open, 2 minutes by
+// default.
+func WithServerTimeouts(readTimeout, writeTimeout, idleTimeout time.Duration) Option {
+	return func(o *Options) error {
+		if readTimeout < 0 || writeTimeout < 0 || idleTimeout < 0 {
+			return ErrInvalidServerLimits
+		}
+		o.serverReadTimeout = readTimeout
+		o.serverWriteTimeout = writeTimeout
+		o.serverIdleTimeout = idleTimeout
+		return nil
+	}
+}
+
+// WithServerMaxHeaderBytes sets the maximum size of the headers of the requests of the Connector's HTTP server, 64 KiB
+// by default.
+func WithServerMaxHeaderBytes(maxHeaderBytes int) Option {
+	return func(o *Options) error {
+		if maxHeaderBytes < 0 {
+			return ErrInvalidServerLimits
+		}
+		o.serverMaxHeaderBytes = maxHeaderBytes
+		return nil
+	}
+}
+
+// WithServerShutdownTimeout sets the maximum amount of time the Connector's HTTP server waits for the requests being
+// served once the Connector is shut down, 5 seconds by default. The server is shut down once the watchers are
+// drained, so that it keeps reporting the health of the Connector meanwhile.
+func WithServerShutdownTimeout(shutdownTimeout time.Duration) Option {
+	return func(o *Options) error {
+		if shutdownTimeout < 0 {
+			return ErrInvalidServerLimits
+		}
+		o.serverShutdownTimeout = shutdownTimeout
+		return nil
+	}
+}
+
+// WithServerBearerTokensFile requires the requests to the endpoints of the Connector's HTTP server, but the health
+// checks and the metrics, to hold one of the bearer tokens of the given file, one per line, e.g. the ones of the
+// automation operating the Connector. The file is read once the Connector is created.
+func WithServerBearerTokensFile(tokensFile string) Option {
+	return func(o *Options) error {
+		if tokensFile == "" {
+			return nil
+		}
+		authenticator, err := server.NewBearerTokenAuthenticator(tokensFile)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidServerAuth, err)
+		}
+		o.serverAuthenticators = append(o.serverAuthenticators, authenticator)
+		o.serverBearerTokensFile = tokensFile
+		return nil
+	}
+}
+
+// WithServerOidc requires the requests to the endpoints of the Connector's HTTP server, but the health checks and the
+// metrics, to hold a JWT bearer token issued by the given OpenID Connect issuer for the given audience, e.g. so that
+// they can be authenticated by a corporate SSO without a reverse proxy. If bearer tokens are accepted too, the requests
+// holding either of them are authenticated.
+func WithServerOidc(issuer, audience string) Option {
+	return func(o *Options) error {
+		if issuer == "" && audience == "" {
+			return nil
+		}
+		if !notify.ValidUrl(issuer) || audience == "" {
+			return ErrInvalidServerAuth
+		}
+		o.serverAuthenticators = append(o.serverAuthenticators, server.NewOidcAuthenticator(issuer, audience))
+		o.serverOidcIssuer = issuer
+		o.serverOidcAudience = audience
+		return nil
+	}
+}
+
+// WithHealthDeadline bounds the time the health checks wait for the ping of the given kind of component, `mongo` or
+// `nats`, the latter applying to the NATS connections of the tenants too. The component is reported down if its ping
+// exceeds the timeout, and degraded if it succeeds but exceeds the degraded latency. Zero values disable the bounds.
+func WithHealthDeadline(component string, timeout, degradedLatency time.Duration) Option {
+	return func(o *Options) error {
+		if !slices.Contains(healthComponents, component) || timeout < 0 || degradedLatency < 0 {
+			return ErrInvalidHealthDeadline
+		}
+		if o.healthDeadlines == nil {
+			o.healthDeadlines = make(map[string]server.Deadline)
+		}
+		o.healthDeadlines[component] = server.Deadline{Timeout: timeout, DegradedLatency: degradedLatency}
+		return nil
+	}
+}
+
+// WithServerDisabled makes the Connector not run its own HTTP server, e.g. when it is hosted by a runtime serving many
+// connectors with a single server.
+func WithServerDisabled() Option {
+	return func(o *Options) error {
+		o.serverDisabled = true
+		return nil
+	}
+}
+
+// WithSystemdNotify notifies systemd, as sd_notify does, once the watchers of the Connector are started and when it
+// stops, and pets its watchdog while the Connector runs, if enabled for the service. It is a no-op unless the Connector
+// is run by a systemd service of type notify.
+func WithSystemdNotify() Option {
+	return func(o *Options) error {
+		o.systemdNotify = true
+		return nil
+	}
+}
+
+// WithJournalSize sets the maximum number of recently published change events kept in memory for each collection.
+func WithJournalSize(journalSize int) Option {
+	return func(o *Options) error {
+		if journalSize <= 0 {
+			return ErrInvalidJournalSize
+		}
+		o.journalSize = journalSize
+		return nil
+	}
+}
+
+// WithSchemaChangesStream publishes the schema changes of all the watched collections, e.g. index creations or
+// collection drops, to the given stream, with a normalized payload.
+// It requires MongoDB 6.0 or later, since most schema changes are only returned by change streams showing expanded
+// events.
+func WithSchemaChangesStream(streamName string) Option {
+	return func(o *Options) error {
+		if streamName != "" {
+			o.schemaChangesStream = streamName
+		}
+		return nil
+	}
+}
+
+// WithSchemaVersionsBucket persists the schema version of each collection without explicit schema version in the
+// given NATS key-value bucket, and bumps it once the options affecting the envelope of its change events, i.e. the
+// encoder, the excluded fields or GridFS, change between runs.
+func WithSchemaVersionsBucket(bucket string) Option {
+	return func(o *Options) error {
+		if bucket != "" {
+			o.schemaVersionsBucket = bucket
+		}
+		return nil
+	}
+}
+
+// WithTokenBackup backs the resume tokens of all the watched collections up every given interval, or every 5 minutes if
+// it is zero, either into the given NATS object store bucket, under the instance id of the Connector, or into the given
+// file, so that they can be restored if the resume tokens database is lost.
+func WithTokenBackup(bucket, path string, interval time.Duration) Option {
+	return func(o *Options) error {
+		if bucket == "" && path == "" && interval == 0 {
+			return nil
+		}
+		if (bucket == "") == (path == "") || (bucket != "" && !bucketNameRegexp.MatchString(bucket)) || interval < 0 {
+			return ErrInvalidTokenBackup
+		}
+		o.tokenBackupBucket = bucket
+		o.tokenBackupPath = path
+		if interval > 0 {
+			o.tokenBackupInterval = interval
+		}
+		return nil
+	}
+}
+
+// WithTokenBackupReplica backs the resume tokens up into the token backup bucket of the NATS server at the given url as
+// well, i.e. the one of the passive region of an active/passive deployment, so that its connector can be promoted from
+// them if the active region is lost. It requires WithTokenBackup to be given a bucket.
+func WithTokenBackupReplica(natsUrl string) Option {
+	return func(o *Options) error {
+		if natsUrl != "" {
+			o.tokenBackupReplicaUrl = natsUrl
+		}
+		return nil
+	}
+}
+
+// withTokenBackupReplicaNatsClient backs the resume tokens up with the given NATS client implementation as well.
+// Used for testing.
+func withTokenBackupReplicaNatsClient(natsClient nats.Client) Option {
+	return func(o *Options) error {
+		o.tokenBackupReplica = natsClient
+		return nil
+	}
+}
+
+// WithPublisherGuard guards the Connector against another one publishing to the same streams, e.g. once a network
+// partition outlived a failover: every given interval, or every 10 seconds if it is zero, it renews a lease held on
+// each stream in the given NATS key-value bucket, and checks that the last message of the stream was not published by
+// another Connector. Otherwise, the Connector stops and alerts. Once started, the Connector waits for the leases held
+// by another one to expire, after three intervals without being renewed.
+func WithPublisherGuard(leaseBucket string, interval time.Duration) Option {
+	return func(o *Options) error {
+		if leaseBucket == "" && interval == 0 {
+			return nil
+		}
+		if !bucketNameRegexp.MatchString(leaseBucket) || interval < 0 {
+			return ErrInvalidPublisherGuard
+		}
+		o.publisherLeaseBucket = leaseBucket
+		if interval > 0 {
+			o.publisherGuardInterval = interval
+		}
+		return nil
+	}
+}
+
+// WithWatcherStatesBucket persists the watchers disabled via the admin api in the given NATS key-value bucket, by
+// namespace, so that a restart does not resume the collections that were intentionally disabled.
+func WithWatcherStatesBucket(bucket string) Option {
+	return func(o *Options) error {
+		if bucket == "" {
+			return nil
+		}
+		if !bucketNameRegexp.MatchString(bucket) {
+			return ErrInvalidWatcherStates
+		}
+		o.watcherStatesBucket = bucket
+		return nil
+	}
+}
+
+// WithWatermark periodically publishes a watermark to the given subject, on core NATS, every given interval, or every 10
+// seconds if it is zero. A watermark holds the highest cluster time up to which all the change events of all the
+// watched collections are processed, so that downstream jobs can implement "process everything up to T" semantics.
+func WithWatermark(subject string, interval time.Duration) Option {
+	return func(o *Options) error {
+		if subject == "" && interval == 0 {
+			return nil
+		}
+		if mongo.ValidSubject(subject) != nil || interval < 0 {
+			return ErrInvalidWatermark
+		}
+		o.watermarkSubject = subject
+		if interval > 0 {
+			o.watermarkInterval = interval
+		}
+		return nil
+	}
+}
+
+// WithEvents publishes the lifecycle and progress events of the Connector, on core NATS, to subjects prefixed by the
+// given subject prefix, or by $CONNECTOR.events if it is empty, so that other systems can react to them, e.g. once a
+// watcher fails or a snapshot completes. The resume tokens advanced are published every given token interval, or
+// every 10 seconds if it is zero, rather than once per change event.
+func WithEvents(subjectPrefix string, tokenInterval time.Duration) Option {
+	return func(o *Options) error {
+		if subjectPrefix == "" {
+			subjectPrefix = defaultEventsSubjectPrefix
+		}
+		if mongo.ValidSubject(subjectPrefix) != nil || tokenInterval < 0 {
+			return ErrInvalidEvents
+		}
+		o.eventsSubjectPrefix = subjectPrefix
+		if tokenInterval > 0 {
+			o.eventsTokenInterval = tokenInterval
+		}
+		return nil
+	}
+}
+
+// WithControlSubject subscribes the Connector, on core NATS, to the given subject, or to $CONNECTOR.control if it is
+// empty, where commands can be broadcast to all the connectors subscribed to it at once, e.g. to pause the watchers of
+// all the collections during an incident. The commands are authenticated as the requests to the HTTP server are, with
+// the bearer token of their Authorization header, so these must be authenticated too, unless
+// WithControlUnauthenticated is set.
+func WithControlSubject(subject string) Option {
+	return func(o *Options) error {
+		if subject == "" {
+			subject = defaultControlSubject
+		}
+		if mongo.ValidSubject(subject) != nil {
+			return ErrInvalidControl
+		}
+		o.controlSubject = subject
+		return nil
+	}
+}
+
+// WithControlUnauthenticated accepts the commands broadcast to the control subject from anyone allowed to publish to
+// it, e.g. if its publish permission is restricted by NATS authorization, without authenticating them.
+func WithControlUnauthenticated() Option {
+	return func(o *Options) error {
+		o.controlUnauthenticated = true
+		return nil
+	}
+}
+
+// WithControlCommand handles the commands of the given name broadcast to the control subject with the given handler,
+// e.g. ReloadConfigCommand by the process running the Connector. The commands without handler are replied to as
+// unsupported.
+func WithControlCommand(name string, handler ControlHandler) Option {
+	return func(o *Options) error {
+		if !validControlCommand(name) || handler == nil {
+			return ErrInvalidControl
+		}
+		if o.controlHandlers == nil {
+			o.controlHandlers = make(map[string]ControlHandler)
+		}
+		o.controlHandlers[name] = handler
+		return nil
+	}
+}
+
+// WithStatusBucket periodically puts the status of the Connector, as served by GET /status, into the given NATS key-value
+// bucket, under its instance id, every given interval, or every 10 seconds if it is zero, so that its health can be
+// observed over NATS. The status is deleted once the Connector shuts down.
+func WithStatusBucket(bucket string, interval time.Duration) Option {
+	return func(o *Options) error {
+		if bucket == "" && interval == 0 {
+			return nil
+		}
+		if !bucketNameRegexp.MatchString(bucket) || interval < 0 {
+			return ErrInvalidStatusBucket
+		}
+		o.statusBucket = bucket
+		if interval > 0 {
+			o.statusInterval = interval
+		}
+		return nil
+	}
+}
+
+// WithMetricsExporter sets how the Connector's metrics are exported: `prometheus`, the default, exposes them via
+// GET /metrics, to be scraped by Prometheus, while `statsd` pushes them to a StatsD server instead (see WithStatsd).
+func WithMetricsExporter(exporter string) Option {
+	return func(o *Options) error {
+		if exporter == "" {
+			return nil
+		}
+		if !slices.Contains(metricsExporters, metricsExporter(exporter)) {
+			return ErrInvalidMetricsExporter
+		}
+		o.metricsExporter = metricsExporter(exporter)
+		return nil
+	}
+}
+
+// WithStatsd sets the UDP address of the StatsD server the metrics are pushed to, if the metrics exporter is `statsd`,
+// 127.0.0.1:8125 by default, i.e. the Datadog agent running on the same host, the prefix of their names, and the
+// interval they are pushed at, 10 seconds by default.
+func WithStatsd(addr, prefix string, interval time.Duration) Option {
+	return func(o *Options) error {
+		if interval < 0 {
+			return ErrInvalidStatsd
+		}
+		if addr != "" {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return ErrInvalidStatsd
+			}
+			o.statsdAddr = addr
+		}
+		if interval > 0 {
+			o.statsdInterval = interval
+		}
+		o.statsdPrefix = prefix
+		return nil
+	}
+}
+
+// WithPushgateway sets the URL of the Prometheus Pushgateway the Connector's metrics are pushed to once the short-lived
+// commands complete, i.e. backfills, redrives and resume token restores, since they may never be scraped, and the job
+// they are pushed under, mongodb-nats-connector by default. The metrics are grouped by instance id and command, so
+// that each run replaces the metrics of the previous run of the same command by the same instance.
+func WithPushgateway(url, job string) Option {
+	return func(o *Options) error {
+		if url == "" {
+			return nil
+		}
+		if !notify.ValidUrl(url) {
+			return ErrInvalidPushgateway
+		}
+		o.pushgatewayUrl = url
+		if job != "" {
+			o.pushgatewayJob = job
+		}
+		return nil
+	}
+}
+
+// WithOplogWarning sets the oplog headroom of the stored resume tokens, i.e. the time between the oldest oplog entry and
+// the cluster time of a resume token, below which a warning is logged, 1 hour by default, so that a resume token
+// about to fall off the oplog is noticed well before the Connector restarts. Once the headroom of a collection drops
+// below it, an alert is posted to the given webhook URL as well, if it is set.
+func WithOplogWarning(headroom time.Duration, webhookUrl string) Option {
+	return func(o *Options) error {
+		if headroom < 0 {
+			return ErrInvalidOplogWarning
+		}
+		if headroom > 0 {
+			o.oplogWarningHeadroom = headroom
+		}
+		if webhookUrl != "" {
+			if !notify.ValidUrl(webhookUrl) {
+				return ErrInvalidOplogWarning
+			}
+			o.oplogWarningWebhook = webhookUrl
+		}
+		return nil
+	}
+}
+
+// WithWebhook posts the Connector's alerts to the given webhook URL, e.g. once a watcher fails, in the given format,
+// json by default, or slack, for Slack incoming webhooks and compatible services. If alert kinds are given, only these
+// alerts are posted to it. It can be set multiple times, to post alerts to several webhooks.
+func WithWebhook(webhookUrl, format string, alerts ...string) Option {
+	return func(o *Options) error {
+		if !notify.ValidUrl(webhookUrl) {
+			return ErrInvalidWebhook
+		}
+		webhook := &notify.Webhook{Url: webhookUrl, Format: notify.JsonFormat}
+		if format != "" {
+			webhook.Format = notify.Format(format)
+			if !slices.Contains(notify.Formats, webhook.Format) {
+				return ErrInvalidWebhook
+			}
+		}
+		for _, alert := range alerts {
+			kind := notify.Kind(alert)
+			if !slices.Contains(notify.Kinds, kind) {
+				return ErrInvalidWebhook
+			}
+			webhook.Alerts = append(webhook.Alerts, kind)
+		}
+		o.webhooks = append(o.webhooks, webhook)
+		return nil
+	}
+}
+
+// RetryPolicy tells how the Connector retries the publishes paused by the streams, the resume token saves failing with
+// transient errors, and the reconnections to MongoDB, with an exponential backoff. The settings which are not set keep
+// the default of each operation.
+type RetryPolicy struct {
+	// InitialInterval is the wait before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between retries.
+	MaxInterval time.Duration
+	// Multiplier is the factor applied to the wait after each retry, at least 1.
+	Multiplier float64
+	// MaxElapsedTime is the time after which an operation is not retried anymore, since its first failure.
+	MaxElapsedTime time.Duration
+	// MaxAttempts is the number of attempts after which an operation is not retried anymore, including the first one.
+	MaxAttempts int
+	// Jitter is one of none, full, equal, or decorrelated.
+	Jitter string
+	// RetryOn are the classes of the errors that are retried, among backpressure, ackTimeout, transient, and
+	// unreachable. If empty, all of them are.
+	RetryOn []string
+}
+
+// WithRetryPolicy sets the retry policy shared by the publishes paused by the streams, the resume token saves, and the
+// reconnections to MongoDB, so that they are all tuned consistently.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(o *Options) error {
+		if policy == nil {
+			return nil
+		}
+		retryPolicy := &retry.Policy{
+			InitialInterval: policy.InitialInterval,
+			MaxInterval:     policy.MaxInterval,
+			Multiplier:      policy.Multiplier,
+			MaxElapsedTime:  policy.MaxElapsedTime,
+			MaxAttempts:     policy.MaxAttempts,
+			Jitter:          retry.Jitter(policy.Jitter),
+		}
+		for _, class := range policy.RetryOn {
+			retryPolicy.RetryOn = append(retryPolicy.RetryOn, retry.Class(class))
+		}
+		if err := retryPolicy.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRetryPolicy, err)
+		}
+		o.retryPolicy = retryPolicy
+		return nil
+	}
+}
+
+// DevGen tells how the Connector fabricates the change events of its collections, instead of watching MongoDB.
+type DevGen struct {
+	// Rate is the number of change events generated per second, for each collection.
+	Rate float64
+	// Template is the document template, in extended JSON, whose string values can be templates generating values,
+	// e.g. {"amount": "{{ float 5 500 }}"}. If empty, a template of orders is used.
+	Template string
+	// Ops weights the operation types of the change events, among insert, update, replace and delete. If empty, 60% of
+	// them are inserts, 30% updates and 10% deletes.
+	Ops map[string]int
+}
+
+// WithDevGen makes the Connector generate the change events of its collections from a document template, and publish
+// them through their pipeline, instead of watching MongoDB, e.g. for consumer developers to work without a replica
+// set. Their resume tokens are kept in memory.
+func WithDevGen(devGen *DevGen) Option {
+	return func(o *Options) error {
+		if devGen == nil {
+			return nil
+		}
+		mongoClient, err := mongo.NewGeneratorClient(&mongo.GeneratorOptions{
+			Rate:     devGen.Rate,
+			Template: devGen.Template,
+			Ops:      devGen.Ops,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidDevGen, err)
+		}
+		o.mongoClient = mongoClient
+		return nil
+	}
+}
@@ -0,0 +1,111 @@
+package connector
+
+import (
+	"slices"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+)
+
+// WithPipeline sets the default pipeline settings, inherited by each collection that does not override them.
+func WithPipeline(opts ...PipelineOption) Option {
+	return func(o *Options) error {
+		for _, opt := range opts {
+			if err := opt(&o.pipeline); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithCollectionPipeline overrides the default pipeline settings for the collection to be watched.
+func WithCollectionPipeline(opts ...PipelineOption) CollectionOption {
+	return func(c *collection) error {
+		for _, opt := range opts {
+			if err := opt(&c.pipeline); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// pipeline represents the settings of the pipeline that publishes the change events of a collection.
+// Zero values are inherited from the default pipeline settings.
+type pipeline struct {
+	publishWorkers int
+	batchSize      int32
+	rateLimit      float64
+	encoder        mongo.Encoder
+}
+
+// inherit returns the pipeline settings, where the missing ones are taken from the given defaults.
+func (p pipeline) inherit(defaults pipeline) pipeline {
+	if p.publishWorkers == 0 {
+		p.publishWorkers = defaults.publishWorkers
+	}
+	if p.batchSize == 0 {
+		p.batchSize = defaults.batchSize
+	}
+	if p.rateLimit == 0 {
+		p.rateLimit = defaults.rateLimit
+	}
+	if p.encoder == "" {
+		p.encoder = defaults.encoder
+	}
+	return p
+}
+
+// PipelineOption is used to configure the pipeline that publishes the change events of a collection.
+type PipelineOption func(*pipeline) error
+
+// WithPublishWorkers sets the number of change events published concurrently.
+// Resume tokens are persisted once all the change events published concurrently have been acked, meaning that change
+// events might be published and received out of order if it is greater than 1.
+func WithPublishWorkers(publishWorkers int) PipelineOption {
+	return func(p *pipeline) error {
+		if publishWorkers <= 0 {
+			return ErrInvalidPublishWorkers
+		}
+		p.publishWorkers = publishWorkers
+		return nil
+	}
+}
+
+// WithBatchSize sets the maximum number of change events returned by each batch of the change stream.
+func WithBatchSize(batchSize int32) PipelineOption {
+	return func(p *pipeline) error {
+		if batchSize <= 0 {
+			return ErrInvalidBatchSize
+		}
+		p.batchSize = batchSize
+		return nil
+	}
+}
+
+// WithRateLimit sets the maximum number of change events published per second.
+func WithRateLimit(rateLimit float64) PipelineOption {
+	return func(p *pipeline) error {
+		if rateLimit <= 0 {
+			return ErrInvalidRateLimit
+		}
+		p.rateLimit = rateLimit
+		return nil
+	}
+}
+
+// WithEncoder sets the format used to encode change events before they are published.
+// Can be set to 'json', for relaxed extended JSON, or 'bson'.
+func WithEncoder(encoder string) PipelineOption {
+	return func(p *pipeline) error {
+		if encoder == "" {
+			return nil
+		}
+		enc := mongo.Encoder(encoder)
+		if !slices.Contains(mongo.Encoders, enc) {
+			return ErrInvalidEncoder
+		}
+		p.encoder = enc
+		return nil
+	}
+}
@@ -0,0 +1,410 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/notify"
+	"github.com/context-labs/mongodb-nats-connector/internal/retry"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+)
+
+// publishPolicy is the default retry policy of the change events whose publishing is paused, since their stream applies
+// backpressure or does not acknowledge them in time.
+var publishPolicy = retry.Policy{
+	InitialInterval: 100 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	Multiplier:      2,
+}
+
+const (
+	instanceIdHdr    = "Connector-Instance-Id"
+	labelHdrPrefix   = "Connector-Label-"
+	offloadBucketHdr = "Connector-Offload-Bucket"
+	offloadObjectHdr = "Connector-Offload-Object"
+	decodeErrorHdr   = "Connector-Decode-Error"
+	nackErrorHdr     = "Connector-Nack-Error"
+	ackTimeoutHdr    = "Connector-Ack-Timeout-Error"
+	tenantErrorHdr   = "Connector-Tenant-Error"
+	encryptedHdr     = "Connector-Encrypted"
+	partialHdr       = "Connector-Partial"
+	shadowOfHdr      = "Connector-Shadow-Of"
+	backfillHdr      = "Connector-Backfill"
+	documentKeyHdr   = "Connector-Document-Key"
+	eventTypeHdr     = "Connector-Event-Type"
+	namespaceHdr     = "Connector-Namespace"
+	wallTimeHdr      = "Connector-Wall-Time"
+	resumeTokenHdr   = "Connector-Resume-Token"
+	lsidHdr          = "Connector-Lsid"
+	txnNumberHdr     = "Connector-Txn-Number"
+	clusterTimeHdr   = "Connector-Cluster-Time"
+	txnIdHdr         = "Connector-Txn-Id"
+	txnIndexHdr      = "Connector-Txn-Index"
+	txnTotalHdr      = "Connector-Txn-Total"
+	schemaVersionHdr = "X-Schema-Version"
+	contentTypeHdr   = "Content-Type"
+	instanceIdMetric = "instance_id"
+)
+
+// publishOptsOf returns the options publishing the given change event of the given collection.
+func (c *Connector) publishOptsOf(coll *collection, event *mongo.ChangeEvent) *nats.PublishOptions {
+	publishOpts := &nats.PublishOptions{
+		Subj:          event.Subj,
+		MsgId:         event.MsgId,
+		Data:          event.Data,
+		Mode:          coll.publishMode,
+		Headers:       make(map[string]string, len(c.headers)+len(event.Headers)+5),
+		AckTimeout:    coll.ackTimeout,
+		RetryAttempts: coll.retryAttempts,
+		RetryWait:     coll.retryWait,
+		MsgTtl:        coll.msgTtl,
+	}
+	maps.Copy(publishOpts.Headers, c.headers)
+	maps.Copy(publishOpts.Headers, event.Headers)
+	publishOpts.Headers[contentTypeHdr] = coll.pipeline.encoder.ContentType()
+	publishOpts.Headers[schemaVersionHdr] = strconv.Itoa(event.SchemaVersion)
+	if event.DocumentKey != "" {
+		publishOpts.Headers[documentKeyHdr] = event.DocumentKey
+	}
+	if event.EventType != "" {
+		publishOpts.Headers[eventTypeHdr] = event.EventType
+	}
+	if b := event.Bookkeeping; b != nil {
+		if b.Namespace != "" {
+			publishOpts.Headers[namespaceHdr] = b.Namespace
+		}
+		if !b.ClusterTime.IsZero() {
+			publishOpts.Headers[clusterTimeHdr] = fmt.Sprintf("%d:%d", b.ClusterTime.T, b.ClusterTime.I)
+		}
+		if !b.WallTime.IsZero() {
+			publishOpts.Headers[wallTimeHdr] = b.WallTime.Format(time.RFC3339Nano)
+		}
+		if b.ResumeToken != "" {
+			publishOpts.Headers[resumeTokenHdr] = b.ResumeToken
+		}
+	}
+	if event.Encrypted {
+		publishOpts.Headers[encryptedHdr] = "true"
+	}
+	if event.Partial {
+		publishOpts.Headers[partialHdr] = "true"
+	}
+	if event.Backfill != "" {
+		publishOpts.Headers[backfillHdr] = event.Backfill
+	}
+	if event.Session != nil {
+		publishOpts.Headers[lsidHdr] = event.Session.Lsid
+		if event.Session.TxnNumber != nil {
+			publishOpts.Headers[txnNumberHdr] = strconv.FormatInt(*event.Session.TxnNumber, 10)
+		}
+		publishOpts.Headers[clusterTimeHdr] = fmt.Sprintf("%d:%d", event.Session.ClusterTime.T,
+			event.Session.ClusterTime.I)
+	}
+	if event.Transaction != nil {
+		publishOpts.Headers[txnIdHdr] = event.Transaction.Id
+		if event.Transaction.Index > 0 {
+			publishOpts.Headers[txnIndexHdr] = strconv.Itoa(event.Transaction.Index)
+		}
+		publishOpts.Headers[txnTotalHdr] = strconv.Itoa(event.Transaction.Total)
+	}
+	if coll.expectStream {
+		publishOpts.ExpectedStream = coll.streamName
+		if event.StreamName != "" {
+			publishOpts.ExpectedStream = event.StreamName
+		}
+		if event.SchemaChange {
+			publishOpts.ExpectedStream = c.options.schemaChangesStream
+		}
+	}
+	return publishOpts
+}
+
+// handle publishes the given change event with the given NATS client, applying the decode error or oversized policy of
+// its collection if needed.
+func (c *Connector) handle(runCtx, ctx context.Context, coll *collection, natsClient nats.Client,
+	event *mongo.ChangeEvent) error {
+	publishOpts := c.publishOptsOf(coll, event)
+	if event.DecodeError == nil && event.GridFSFile != nil && coll.gridFsObjectBucket != "" {
+		if err := c.copyGridFSFile(ctx, coll, natsClient, event.GridFSFile); err != nil {
+			return err
+		}
+	}
+	if event.DecodeError != nil {
+		publishOpts = decodeErrorPublishOpts(coll, event, publishOpts)
+	} else if event.Oversized {
+		var err error
+		if publishOpts, err = c.oversizedPublishOpts(ctx, coll, natsClient, event, publishOpts); err != nil {
+			return err
+		}
+	}
+	err := c.publish(runCtx, ctx, coll, natsClient, publishOpts)
+	if errors.Is(err, nats.ErrNack) && coll.nackPolicy == nats.DlqNackPolicy {
+		c.logger.Warn("change event rejected by the nats stream, publishing it to the dead letter subject",
+			"subj", publishOpts.Subj, "dlqSubject", coll.dlqSubject, "err", err)
+		publishOpts = dlqPublishOpts(coll, publishOpts, nackErrorHdr, err)
+		err = c.publish(runCtx, ctx, coll, natsClient, publishOpts)
+	}
+	if errors.Is(err, nats.ErrAckTimeout) {
+		switch coll.errorPolicies.Action(mongo.PublishTimeoutErrorClass) {
+		case mongo.SkipErrorAction:
+			c.logger.Warn("change event not acknowledged by the nats stream in time, skipping it",
+				"subj", publishOpts.Subj, "msgId", publishOpts.MsgId, "err", err)
+			return nil
+		case mongo.DlqErrorAction:
+			c.logger.Warn("change event not acknowledged by the nats stream in time, publishing it to the dead "+
+				"letter subject", "subj", publishOpts.Subj, "dlqSubject", coll.dlqSubject, "err", err)
+			publishOpts = dlqPublishOpts(coll, publishOpts, ackTimeoutHdr, err)
+			err = c.publish(runCtx, ctx, coll, natsClient, publishOpts)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNatsFailed, err)
+	}
+	if coll.idempotentResume && publishOpts.Ack != nil {
+		event.Ack = &mongo.Ack{Stream: publishOpts.Ack.Stream, Seq: publishOpts.Ack.Sequence,
+			Duplicate: publishOpts.Ack.Duplicate}
+	}
+	if event.Shadow != nil {
+		c.publishShadow(ctx, coll, natsClient, event)
+	}
+	return nil
+}
+
+// publishShadow publishes the shadow of the given change event to core NATS, once the change event is published, with
+// a header holding the message id of the change event, so that they can be compared. Shadows are best-effort: if one
+// cannot be published, the failure is logged, and the change event is not affected.
+func (c *Connector) publishShadow(ctx context.Context, coll *collection, natsClient nats.Client,
+	event *mongo.ChangeEvent) {
+	headers := make(map[string]string, len(c.headers)+len(event.Headers)+3)
+	maps.Copy(headers, c.headers)
+	maps.Copy(headers, event.Headers)
+	headers[contentTypeHdr] = event.Shadow.ContentType
+	headers[schemaVersionHdr] = strconv.Itoa(event.SchemaVersion)
+	headers[shadowOfHdr] = event.MsgId
+	opts := &nats.PublishOptions{
+		Subj:    event.Shadow.Subj,
+		Data:    event.Shadow.Data,
+		Mode:    nats.CorePublishMode,
+		Headers: headers,
+	}
+	if err := natsClient.Publish(ctx, opts); err != nil {
+		c.logger.Warn("could not publish shadow change event", "dbName", coll.dbName, "collName", coll.collName,
+			"subj", event.Shadow.Subj, "err", err)
+	}
+}
+
+// copyGridFSFile copies the content of the given GridFS file to the object store bucket of its collection once it is
+// created, so that it is available as soon as its file-level event is published, and deletes it once it is deleted.
+func (c *Connector) copyGridFSFile(ctx context.Context, coll *collection, natsClient nats.Client,
+	file *mongo.GridFSFile) error {
+	switch file.Event {
+	case mongo.CreatedGridFSEvent:
+		content, err := file.Open(ctx)
+		if errors.Is(err, mongo.ErrGridFSFileNotFound) {
+			c.logger.Warn("GridFS file deleted before its content could be copied", "dbName", coll.dbName,
+				"collName", coll.collName, "file", file.Name)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not open GridFS file %v: %v", file.Name, err)
+		}
+		defer content.Close()
+		return natsClient.PutObjectStream(ctx, coll.gridFsObjectBucket, file.Name, content)
+	case mongo.DeletedGridFSEvent:
+		return natsClient.DeleteObject(ctx, coll.gridFsObjectBucket, file.Name)
+	}
+	return nil
+}
+
+// maxPayload returns the maximum payload of the change events of the given collection, i.e. the smallest maximum
+// payload of the NATS clients they can be published with.
+func (c *Connector) maxPayload(coll *collection) int64 {
+	var maxPayload int64
+	for _, natsClient := range c.natsClientsOf(coll) {
+		if p := natsClient.MaxPayload(); p > 0 && (maxPayload == 0 || p < maxPayload) {
+			maxPayload = p
+		}
+	}
+	return maxPayload
+}
+
+// oversizedRef is published in place of an oversized change event, referencing it.
+type oversizedRef struct {
+	Subj          string `json:"subject"`
+	MsgId         string `json:"msgId"`
+	Size          int    `json:"size"`
+	OperationType string `json:"operationType"`
+	Database      string `json:"database"`
+	Collection    string `json:"collection"`
+	Bucket        string `json:"bucket,omitempty"`
+	Object        string `json:"object,omitempty"`
+}
+
+// oversizedPublishOpts returns the options used to publish the given oversized change event, according to the
+// oversized policy of its collection:
+//
+//   - dlq: a reference to the change event is published to the dead letter subject
+//   - offload: the change event is stored in the offload bucket, and a reference to it is published to its subject,
+//     with headers naming the bucket and the object
+//
+// Otherwise, i.e. if the policy is fail, or the change event is still oversized once truncated, ErrOversizedPayload is
+// returned.
+func (c *Connector) oversizedPublishOpts(ctx context.Context, coll *collection, natsClient nats.Client,
+	event *mongo.ChangeEvent, opts *nats.PublishOptions) (*nats.PublishOptions, error) {
+	ref := oversizedRef{
+		Subj:          event.Subj,
+		MsgId:         event.MsgId,
+		Size:          len(event.Data),
+		OperationType: event.OperationType,
+		Database:      coll.dbName,
+		Collection:    coll.collName,
+	}
+	refOpts := *opts
+	refOpts.Headers = make(map[string]string, len(opts.Headers)+3)
+	maps.Copy(refOpts.Headers, opts.Headers)
+	refOpts.Headers[contentTypeHdr] = mongo.JsonEncoder.ContentType()
+	switch coll.oversizedPolicy {
+	case mongo.DlqOversizedPolicy:
+		refOpts.Subj = coll.dlqSubject
+		refOpts.ExpectedStream = ""
+	case mongo.OffloadOversizedPolicy:
+		if err := natsClient.PutObject(ctx, coll.offloadBucket, event.MsgId, event.Data); err != nil {
+			return nil, err
+		}
+		ref.Bucket, ref.Object = coll.offloadBucket, event.MsgId
+		refOpts.Headers[offloadBucketHdr] = coll.offloadBucket
+		refOpts.Headers[offloadObjectHdr] = event.MsgId
+	default:
+		return nil, fmt.Errorf("%w: msgId %s, %d bytes", ErrOversizedPayload, event.MsgId, len(event.Data))
+	}
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return nil, err
+	}
+	refOpts.Data = data
+	return &refOpts, nil
+}
+
+// decodeErrorPublishOpts returns the options used to publish the raw BSON of the given change event, which could not be
+// encoded, to the dead letter subject of its collection, with a header holding the encoding error.
+func decodeErrorPublishOpts(coll *collection, event *mongo.ChangeEvent, opts *nats.PublishOptions) *nats.PublishOptions {
+	dlqOpts := *opts
+	dlqOpts.Subj = coll.dlqSubject
+	dlqOpts.ExpectedStream = ""
+	dlqOpts.Headers = make(map[string]string, len(opts.Headers)+2)
+	maps.Copy(dlqOpts.Headers, opts.Headers)
+	dlqOpts.Headers[contentTypeHdr] = mongo.BsonEncoder.ContentType()
+	dlqOpts.Headers[decodeErrorHdr] = event.DecodeError.Error()
+	return &dlqOpts
+}
+
+// dlqPublishOpts returns the options publishing the given change event, which could not be published with the given
+// error, e.g. a negative ack of JetStream, to the dead letter subject of its collection, with the given header holding
+// the error.
+func dlqPublishOpts(coll *collection, opts *nats.PublishOptions, hdr string, err error) *nats.PublishOptions {
+	dlqOpts := *opts
+	dlqOpts.Subj = coll.dlqSubject
+	dlqOpts.ExpectedStream = ""
+	dlqOpts.Headers = make(map[string]string, len(opts.Headers)+1)
+	maps.Copy(dlqOpts.Headers, opts.Headers)
+	dlqOpts.Headers[hdr] = err.Error()
+	return &dlqOpts
+}
+
+// publish publishes the given change event to NATS with the given client.
+// While NATS is reconnecting the watcher is paused, waiting in the handler without advancing its change stream, until
+// the connection is re-established or the Connector's context is cancelled.
+// The watcher is paused as well while the stream rejects the change event because its limits are exceeded, or it is
+// not responding, or it does not acknowledge the change event in time, retrying with the retry policy of the
+// Connector, until its retry budget is exhausted. If still no stream responds then, the change event cannot be
+// published, and the watcher fails.
+func (c *Connector) publish(runCtx, ctx context.Context, coll *collection, natsClient nats.Client,
+	opts *nats.PublishOptions) error {
+	backoff := publishPolicy.With(c.options.retryPolicy).NewBackoff()
+	paused := false
+	for {
+		if natsClient.Reconnecting() {
+			c.status.SetState(coll.namespace(), server.CollectionStatePaused, nil)
+		}
+		if err := natsClient.WaitConnected(runCtx); err != nil {
+			return err
+		}
+		start := time.Now()
+		err := natsClient.Publish(ctx, opts)
+		if errors.Is(err, nats.ErrClientReconnecting) || errors.Is(err, nats.ErrClientDisconnected) {
+			continue
+		}
+		if class, msg, ok := pausingError(err); ok && backoff.Retries(class) && (class != retry.AckTimeoutClass ||
+			coll.errorPolicies.Action(mongo.PublishTimeoutErrorClass) == mongo.RetryErrorAction) {
+			if wait, ok := backoff.Next(); ok {
+				c.status.SetState(coll.namespace(), server.CollectionStatePaused, err)
+				if !paused {
+					c.notify(notify.CircuitOpenAlert, coll.dbName, coll.collName, msg, err)
+					paused = true
+				}
+				c.logger.Warn(msg, "subj", opts.Subj, "retryIn", wait, "err", err)
+				if err = sleep(runCtx, wait); err != nil {
+					return err
+				}
+				continue
+			}
+			c.logger.Error("could not publish change event within the retry budget", "subj", opts.Subj, "err", err)
+		}
+		c.recordJournalEntry(coll, opts, time.Since(start), err)
+		if err != nil {
+			c.status.SetState(coll.namespace(), server.CollectionStateError, err)
+		}
+		if errors.Is(err, nats.ErrNoStream) { // e.g. the stream was deleted
+			return fmt.Errorf("%w: %w", mongo.ErrUnpublishable, err)
+		}
+		return err
+	}
+}
+
+// pausingError returns the retry class of the given publish error, and the reason why publishing is paused, if it
+// pauses publishing, i.e. if the stream applies backpressure or does not acknowledge the change events in time.
+func pausingError(err error) (retry.Class, string, bool) {
+	switch {
+	case errors.Is(err, nats.ErrBackpressure):
+		return retry.BackpressureClass, "nats stream is applying backpressure, publishing is paused", true
+	case errors.Is(err, nats.ErrAckTimeout):
+		return retry.AckTimeoutClass, "nats stream did not acknowledge the change event in time, publishing is paused",
+			true
+	}
+	return "", "", false
+}
+
+// sleep waits for the given duration, or until the given context is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Connector) recordJournalEntry(coll *collection, opts *nats.PublishOptions, latency time.Duration, err error) {
+	entry := server.JournalEntry{
+		Time:    time.Now(),
+		Subj:    opts.Subj,
+		MsgId:   opts.MsgId,
+		Size:    len(opts.Data),
+		Latency: latency.String(),
+		Result:  server.JournalResultPublished,
+	}
+	if err != nil {
+		entry.Result = server.JournalResultFailed
+		entry.Error = err.Error()
+	}
+	c.journal.Record(coll.namespace(), entry)
+}
@@ -0,0 +1,1198 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/internal/notify"
+	"github.com/context-labs/mongodb-nats-connector/internal/prometheus"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+	"github.com/context-labs/mongodb-nats-connector/internal/systemd"
+)
+
+// Run runs the Connector.
+// It performs the following operations:
+//
+//	For each configured collection to be watched:
+//		- It creates the given collection on MongoDB, if it does not already exist
+//		- It creates the resume tokens collection for the given collection on MongoDB, if it does not already exist
+//		- It creates the given stream on NATS, if it does not already exist
+//		- Spins up a goroutine to watch the given collection
+//	It runs an HTTP server in its own goroutine.
+//
+// Once the Connector's context is cancelled, the watchers stop iterating their change streams, the in-flight change
+// events are published and their resume tokens persisted, then the HTTP server is shut down, and the MongoDB and NATS
+// clients are closed, in this order.
+// It returns nil if the Connector was shut down cleanly, ErrForcedShutdown if the shutdown timeout was exceeded, or an
+// error wrapping ErrPreflightFailed if the collections or streams could not be set up, ErrMongoFailed if a watcher
+// failed, or ErrNatsFailed if change events could not be published.
+func (c *Connector) Run() error {
+	defer c.cleanup()
+
+	// the run is cancelled once the Connector's context is, or once its server fails
+	runCtx, cancel := context.WithCancelCause(c.options.ctx)
+	defer cancel(nil)
+	group, groupCtx := errgroup.WithContext(runCtx)
+
+	// schemaChangesStreams holds the NATS clients the schema changes stream was already added with
+	schemaChangesStreams := make(map[nats.Client]bool)
+
+	// watching is the number of collections still watched, i.e. that did not fail in isolation
+	var watching atomic.Int32
+	watching.Store(int32(len(c.options.collections)))
+
+	var guard *publisherGuard
+	if c.options.publisherLeaseBucket != "" {
+		var err error
+		if guard, err = c.acquirePublisherLeases(groupCtx); err != nil {
+			return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+		if guard == nil { // stopped while waiting for the leases
+			return nil
+		}
+		group.Go(func() error {
+			return c.guardPublisher(groupCtx, guard)
+		})
+	}
+
+	// watched holds the collections set up to be watched, along with the options of their watchers
+	watched := make([]watchedCollection, 0, len(c.options.collections))
+	for _, coll := range c.options.collections {
+		createWatchedCollOpts := &mongo.CreateCollectionOptions{
+			DbName:                       coll.dbName,
+			CollName:                     coll.collName,
+			ChangeStreamPreAndPostImages: coll.changeStreamPreAndPostImages,
+		}
+		if err := c.options.mongoClient.CreateCollection(groupCtx, createWatchedCollOpts); err != nil {
+			return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+
+		createResumeTokensCollOpts := &mongo.CreateCollectionOptions{
+			DbName:      coll.tokensDbName,
+			CollName:    coll.tokensCollName,
+			Capped:      coll.tokensCollCapped,
+			SizeInBytes: coll.tokensCollSizeInBytes,
+			Expiring:    coll.tokensCollTtl > 0,
+		}
+		if err := c.options.mongoClient.CreateCollection(groupCtx, createResumeTokensCollOpts); err != nil {
+			return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+
+		envelopeVersion, err := c.envelopeVersion(coll)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+
+		watchCollOpts := c.watchCollectionOptions(groupCtx, coll, envelopeVersion)
+
+		c.watchOptsMu.Lock()
+		c.watchOpts[coll.namespace()] = watchCollOpts
+		c.watchOptsMu.Unlock()
+
+		// streams are not needed when publishing to core nats
+		if coll.publishMode == nats.JetStreamPublishMode {
+			for _, natsClient := range c.natsClientsOf(coll) {
+				err := c.addStreams(groupCtx, natsClient, coll, watchCollOpts, watchCollOpts.SchemaVersion.Current())
+				if err != nil {
+					return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+				}
+				if err := c.addSchemaChangesStream(groupCtx, natsClient, schemaChangesStreams); err != nil {
+					return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+				}
+			}
+		}
+
+		if guard != nil {
+			if err := guard.baseline(groupCtx, coll); err != nil {
+				return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+			}
+		}
+
+		if err := c.loadWatcherState(coll); err != nil {
+			return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+		watched = append(watched, watchedCollection{coll: coll, opts: watchCollOpts})
+	}
+
+	// no watcher is started until the subjects of all the collections are known to be stored by their streams
+	if err := c.validateSubjects(groupCtx, watched); err != nil {
+		return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+	}
+
+	for _, w := range watched {
+		coll, watchCollOpts := w.coll, w.opts
+		group.Go(func() error {
+			err := c.watch(groupCtx, coll, watchCollOpts) // blocking call
+			if err != nil && groupCtx.Err() == nil {
+				kind, msg := notify.WatcherFailedAlert, "collection watcher failed"
+				if mongo.IsTokenExpiredError(err) {
+					kind, msg = notify.TokenExpiredAlert, "resume token fell off the oplog, the change stream cannot be resumed"
+				}
+				c.notify(kind, coll.dbName, coll.collName, msg, err)
+				c.publishEvent(groupCtx, watcherFailedEvent, coll.dbName, coll.collName, &event{Error: err.Error()})
+			}
+			if err != nil && coll.failureMode == isolateFailureMode && groupCtx.Err() == nil {
+				c.status.SetState(coll.namespace(), server.CollectionStateFailed, err)
+				c.logger.Error("collection failed, the other collections are still watched", "db", coll.dbName,
+					"coll", coll.collName, "err", err)
+				if watching.Add(-1) > 0 {
+					return nil
+				}
+				return fmt.Errorf("%w: %w", ErrAllCollectionsFailed, fatalError(err))
+			}
+			if err != nil {
+				c.status.SetState(coll.namespace(), server.CollectionStateError, err)
+				return fatalError(err)
+			}
+			return nil
+		})
+
+		if coll.snapshot != nil {
+			group.Go(func() error {
+				c.runSnapshots(groupCtx, coll, watchCollOpts)
+				return nil
+			})
+		}
+	}
+
+	if c.options.watermarkSubject != "" {
+		group.Go(func() error {
+			c.publishWatermarks(groupCtx)
+			return nil
+		})
+	}
+
+	if c.options.eventsSubjectPrefix != "" {
+		group.Go(func() error {
+			c.publishTokenEvents(groupCtx)
+			return nil
+		})
+	}
+
+	if c.options.controlSubject != "" {
+		msgs, err := c.options.natsClient.Subscribe(groupCtx, c.options.controlSubject)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+		group.Go(func() error {
+			c.serveControl(groupCtx, msgs)
+			return nil
+		})
+	}
+
+	if c.options.statusBucket != "" {
+		group.Go(func() error {
+			c.putStatus(groupCtx)
+			return nil
+		})
+	}
+
+	if c.tokenBackupEnabled() {
+		group.Go(func() error {
+			c.backupTokens(groupCtx)
+			return nil
+		})
+	}
+
+	if c.options.metricsExporter == statsdMetricsExporter {
+		exporter, err := prometheus.NewStatsdExporter(c.options.statsdAddr, c.options.statsdPrefix,
+			map[string]string{instanceIdMetric: c.options.instanceId})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPreflightFailed, err)
+		}
+		group.Go(func() error {
+			c.exportMetrics(groupCtx, exporter)
+			return nil
+		})
+	}
+
+	if c.options.systemdNotify {
+		group.Go(func() error {
+			c.notifySystemd(groupCtx)
+			return nil
+		})
+	}
+
+	if c.options.serverDisabled {
+		return c.wait(group, groupCtx)
+	}
+
+	// the server is shut down once the watchers are drained, so that it keeps serving meanwhile, e.g. the health checks,
+	// and it keeps the Connector running until its context is cancelled, even if no collection is watched anymore
+	group.Go(func() error {
+		<-groupCtx.Done()
+		return nil
+	})
+	serverErr := make(chan error, 1)
+	go func() {
+		err := c.server.Run()
+		if err != nil {
+			cancel(err)
+		}
+		serverErr <- err
+	}()
+	err := c.wait(group, groupCtx)
+	if closeErr := c.server.Close(); closeErr != nil {
+		c.logger.Error("could not close server", "err", closeErr)
+	}
+	if runErr := <-serverErr; runErr != nil {
+		return runErr
+	}
+	return err
+}
+
+// watch watches the given collection until the given context is cancelled or its watcher fails. While the watcher is
+// disabled, it is stopped, then started again once it is enabled, resuming its change stream after the last stored
+// resume token.
+func (c *Connector) watch(ctx context.Context, coll *collection, opts *mongo.WatchCollectionOptions) error {
+	toggle := c.watcherToggle(coll.namespace())
+	for {
+		watchCtx, enabled := toggle.start(ctx)
+		if watchCtx == nil {
+			c.status.SetState(coll.namespace(), server.CollectionStateDisabled, nil)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-enabled:
+				c.status.SetState(coll.namespace(), server.CollectionStateRunning, nil)
+				continue
+			}
+		}
+		c.publishEvent(ctx, watcherStartedEvent, coll.dbName, coll.collName, &event{})
+		err := c.options.mongoClient.WatchCollection(watchCtx, opts)
+		disabled := watchCtx.Err() != nil && ctx.Err() == nil
+		toggle.stop()
+		if disabled {
+			c.publishEvent(ctx, watcherStoppedEvent, coll.dbName, coll.collName, &event{Reason: stoppedDisabled})
+			continue
+		}
+		if err == nil || ctx.Err() != nil {
+			c.publishEvent(ctx, watcherStoppedEvent, coll.dbName, coll.collName, &event{Reason: stoppedShutdown})
+		}
+		return err
+	}
+}
+
+// watcherToggle returns the toggle of the watcher of the given collection.
+func (c *Connector) watcherToggle(namespace string) *watcherToggle {
+	c.watcherTogglesMu.Lock()
+	defer c.watcherTogglesMu.Unlock()
+	if c.watcherToggles == nil {
+		c.watcherToggles = make(map[string]*watcherToggle)
+	}
+	toggle, ok := c.watcherToggles[namespace]
+	if !ok {
+		toggle = &watcherToggle{}
+		c.watcherToggles[namespace] = toggle
+	}
+	return toggle
+}
+
+// DisableWatcher stops the watcher of the given collection, once its in-flight change events are published, until it
+// is enabled again. If a watcher states bucket is set, the state is persisted in it beforehand, so that the watcher is
+// not started once the Connector restarts either.
+func (c *Connector) DisableWatcher(ctx context.Context, namespace string) error {
+	return c.toggleWatcher(ctx, namespace, true)
+}
+
+// EnableWatcher starts the watcher of the given collection again, resuming its change stream after the last stored
+// resume token.
+func (c *Connector) EnableWatcher(ctx context.Context, namespace string) error {
+	return c.toggleWatcher(ctx, namespace, false)
+}
+
+func (c *Connector) toggleWatcher(ctx context.Context, namespace string, disabled bool) error {
+	coll := c.collection(namespace)
+	if coll == nil {
+		return fmt.Errorf("%w: %s", server.ErrCollectionNotFound, namespace)
+	}
+	if err := c.persistWatcherState(namespace, disabled); err != nil {
+		return err
+	}
+	if !c.watcherToggle(namespace).set(disabled) {
+		return nil
+	}
+	msg := "collection watcher enabled"
+	if disabled {
+		msg = "collection watcher disabled"
+	}
+	c.logger.Info(msg, "dbName", coll.dbName, "collName", coll.collName, "requestId", server.RequestId(ctx))
+	return nil
+}
+
+// persistedWatcherState is the state of the watcher of a collection, as persisted in the watcher states bucket while
+// it is disabled.
+type persistedWatcherState struct {
+	Disabled   bool      `json:"disabled"`
+	DisabledAt time.Time `json:"disabledAt"`
+}
+
+// persistWatcherState persists whether the watcher of the given collection is disabled in the watcher states bucket,
+// if any. The state of an enabled watcher is deleted.
+func (c *Connector) persistWatcherState(namespace string, disabled bool) error {
+	if c.options.watcherStatesBucket == "" {
+		return nil
+	}
+	kv, err := c.options.natsClient.KeyValue(c.options.watcherStatesBucket)
+	if err != nil {
+		return fmt.Errorf("could not persist watcher state: %v", err)
+	}
+	if !disabled {
+		if err = kv.Delete(namespace); err != nil {
+			return fmt.Errorf("could not persist watcher state: %v", err)
+		}
+		return nil
+	}
+	value, err := json.Marshal(&persistedWatcherState{Disabled: true, DisabledAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err = kv.Put(namespace, value); err != nil {
+		return fmt.Errorf("could not persist watcher state: %v", err)
+	}
+	return nil
+}
+
+// loadWatcherState disables the watcher of the given collection if it is disabled in the watcher states bucket, e.g.
+// since it was disabled before the Connector restarted.
+func (c *Connector) loadWatcherState(coll *collection) error {
+	if c.options.watcherStatesBucket == "" {
+		return nil
+	}
+	kv, err := c.options.natsClient.KeyValue(c.options.watcherStatesBucket)
+	if err != nil {
+		return err
+	}
+	value, err := kv.Get(coll.namespace())
+	switch {
+	case errors.Is(err, nats.ErrKeyNotFound):
+		return nil
+	case err != nil:
+		return err
+	}
+	var state persistedWatcherState
+	if err = json.Unmarshal(value, &state); err != nil {
+		return fmt.Errorf("could not unmarshal watcher state of %v: %v", coll.namespace(), err)
+	}
+	if state.Disabled && c.watcherToggle(coll.namespace()).set(true) {
+		c.logger.Warn("collection watcher is disabled, it is not started until it is enabled", "dbName", coll.dbName,
+			"collName", coll.collName, "disabledAt", state.DisabledAt)
+	}
+	return nil
+}
+
+// watcherToggle holds whether the watcher of a collection is disabled, and stops it once it is.
+type watcherToggle struct {
+	mu       sync.Mutex
+	disabled bool
+	// enabled is closed once the watcher is enabled again, while it is disabled.
+	enabled chan struct{}
+	// cancel stops the watcher, while it runs.
+	cancel context.CancelFunc
+}
+
+// start returns the context the watcher runs with, cancelled once it is disabled, or nil if it is disabled, along
+// with a channel closed once it is enabled again.
+func (t *watcherToggle) start(ctx context.Context) (context.Context, <-chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.disabled {
+		return nil, t.enabled
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	t.cancel = cancel
+	return watchCtx, nil
+}
+
+// stop releases the context of the watcher, once it returned.
+func (t *watcherToggle) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+}
+
+// set disables or enables the watcher, stopping it if it is disabled, and returns false if it already was.
+func (t *watcherToggle) set(disabled bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.disabled == disabled {
+		return false
+	}
+	t.disabled = disabled
+	if !disabled {
+		close(t.enabled)
+		return true
+	}
+	t.enabled = make(chan struct{})
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return true
+}
+
+// fatalError returns the given error a watcher stopped with, marked as a fatal MongoDB error unless it is a fatal NATS
+// one, i.e. unless its change events could not be published.
+func fatalError(err error) error {
+	if errors.Is(err, ErrNatsFailed) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrMongoFailed, err)
+}
+
+// watchCollectionOptions returns the options the given collection is watched with, whose change events are published
+// until the given context is cancelled, stamped with the given schema version if the collection has none.
+func (c *Connector) watchCollectionOptions(runCtx context.Context, coll *collection,
+	envelopeVersion int) *mongo.WatchCollectionOptions {
+	return &mongo.WatchCollectionOptions{
+		WatchedDbName:           coll.dbName,
+		WatchedCollName:         coll.collName,
+		ResumeTokensDbName:      coll.tokensDbName,
+		ResumeTokensCollName:    coll.tokensCollName,
+		ResumeTokensCollCapped:  coll.tokensCollCapped,
+		ResumeTokensCollTtl:     coll.tokensCollTtl,
+		StreamName:              coll.streamName,
+		NamespaceSubjects:       coll.namespaceSubjects,
+		Partitions:              coll.partitions,
+		TimeBucket:              coll.timeBucket,
+		StallTimeout:            coll.stallTimeout,
+		MsgIdStrategy:           coll.msgIdStrategy,
+		MsgIdField:              coll.msgIdField,
+		PublishWorkers:          coll.pipeline.publishWorkers,
+		BatchSize:               coll.pipeline.batchSize,
+		RateLimit:               coll.pipeline.rateLimit,
+		Encoder:                 coll.pipeline.encoder,
+		TenantField:             coll.tenantField,
+		ExcludeFields:           coll.excludeFields,
+		Collation:               coll.collation,
+		SplitLargeEvents:        coll.splitLargeEvents,
+		EncryptedPassthrough:    coll.encryptedPassthrough,
+		GridFS:                  coll.gridFs,
+		GridFSObjectBucket:      coll.gridFsObjectBucket,
+		FollowRenames:           coll.followRenames,
+		SchemaChangesStreamName: c.options.schemaChangesStream,
+		MaxPayload:              c.maxPayload(coll),
+		OversizedPolicy:         coll.oversizedPolicy,
+		DecodeErrorPolicy:       coll.decodeErrorPolicy,
+		ErrorPolicies:           coll.errorPolicies,
+		QuarantineCollName:      coll.quarantineCollName,
+		MaxEventAge:             coll.maxEventAge,
+		StrictOrdering:          coll.strictOrdering,
+		SubjectTemplate:         coll.subjectTemplate,
+		HeaderTemplates:         coll.headerTemplates,
+		Routes:                  coll.routes,
+		SchemaVersion:           mongo.NewSchemaVersion(coll.schemaVersion),
+		Filter:                  coll.filter,
+		EventTypes:              coll.eventTypes,
+		Patch:                   coll.patch,
+		Envelope:                coll.envelope,
+		MissingImages:           coll.missingImages,
+		PayloadMode:             coll.payloadMode,
+		Reshape:                 coll.reshape,
+		Flatten:                 coll.flatten,
+		Conversions:             coll.typeConversions,
+		JsonFlavor:              coll.jsonFlavor,
+		Enrichment:              coll.enrichment,
+		Canary:                  coll.canary,
+		TransactionMode:         coll.transactions,
+		ChangeEventHandler: func(ctx context.Context, event *mongo.ChangeEvent) error {
+			if event.SchemaVersion == 0 {
+				event.SchemaVersion = envelopeVersion
+			}
+			natsClients, err := c.natsClientsFor(coll, event)
+			if errors.Is(err, ErrUnknownTenant) {
+				return c.handleUnknownTenant(runCtx, ctx, coll, event, err)
+			}
+			if err != nil {
+				return err
+			}
+			for _, natsClient := range natsClients {
+				if err = c.handle(runCtx, ctx, coll, natsClient, event); err != nil {
+					return err
+				}
+			}
+			c.status.RecordPublished(coll.namespace(), event.Time)
+			return nil
+		},
+		PublishedMsgIdsHandler: c.publishedMsgIdsHandler(coll),
+		WatermarkHandler:       c.watermarkHandler(coll),
+	}
+}
+
+// publishedMsgIdsHandler returns the handler fetching the message ids of the change events of the given collection
+// published after its last stored resume token, or nil if it does not resume idempotently.
+func (c *Connector) publishedMsgIdsHandler(coll *collection) mongo.PublishedMsgIdsHandler {
+	if !coll.idempotentResume {
+		return nil
+	}
+	return func(ctx context.Context, stream string, subjects []string, afterSeq uint64) ([]string, uint64, error) {
+		return c.options.natsClient.MsgIdsAfter(ctx, stream, subjects, afterSeq, idempotentResumeScanLimit)
+	}
+}
+
+// watermarkHandler returns the handler recording the watermark of the given collection, or nil if watermarks are not
+// published.
+func (c *Connector) watermarkHandler(coll *collection) mongo.WatermarkHandler {
+	if c.options.watermarkSubject == "" {
+		return nil
+	}
+	namespace := coll.namespace()
+	return func(clusterTime primitive.Timestamp) {
+		c.watermarksMu.Lock()
+		defer c.watermarksMu.Unlock()
+		if clusterTime.After(c.watermarks[namespace]) {
+			c.watermarks[namespace] = clusterTime
+		}
+	}
+}
+
+// storedToken is the last stored resume token of a watched collection.
+type storedToken struct {
+	dbName   string
+	collName string
+	time     time.Time
+
+	// low is true once its oplog headroom dropped below the warning threshold, until it recovers.
+	low bool
+}
+
+// recordStoredToken records the cluster time of the last stored resume token of the given collection.
+func (c *Connector) recordStoredToken(dbName, collName string, tokenTime time.Time) {
+	c.storedTokensMu.Lock()
+	defer c.storedTokensMu.Unlock()
+	namespace := dbName + "." + collName
+	if token, ok := c.storedTokens[namespace]; ok {
+		token.time = tokenTime
+		return
+	}
+	c.storedTokens[namespace] = &storedToken{dbName: dbName, collName: collName, time: tokenTime}
+}
+
+// oplogWarning is posted to the oplog warning webhook once the oplog headroom of the stored resume token of a
+// collection drops below the warning threshold.
+type oplogWarning struct {
+	Alert       string    `json:"alert"`
+	InstanceId  string    `json:"instanceId"`
+	Database    string    `json:"database"`
+	Collection  string    `json:"collection"`
+	TokenTime   time.Time `json:"tokenTime"`
+	OplogOldest time.Time `json:"oplogOldest"`
+	Headroom    string    `json:"headroom"`
+	Threshold   string    `json:"threshold"`
+}
+
+// checkOplogHeadroom logs a warning for each stored resume token whose oplog headroom, given the oldest oplog entry, is
+// below the warning threshold, and posts an alert to the oplog warning webhook once its headroom drops below it.
+func (c *Connector) checkOplogHeadroom(oldest time.Time) {
+	c.storedTokensMu.Lock()
+	defer c.storedTokensMu.Unlock()
+	for _, token := range c.storedTokens {
+		headroom := token.time.Sub(oldest)
+		if headroom >= c.options.oplogWarningHeadroom {
+			token.low = false
+			continue
+		}
+		msg := "resume token is about to fall off the oplog"
+		if headroom < 0 {
+			msg = "resume token fell off the oplog, the change stream cannot be resumed"
+		}
+		c.logger.Warn(msg, "dbName", token.dbName, "collName", token.collName, "headroom", headroom,
+			"threshold", c.options.oplogWarningHeadroom, "tokenTime", token.time, "oplogOldest", oldest)
+		if c.mongoRegisterer != nil {
+			c.mongoRegisterer.IncMongoOplogHeadroomWarnings(token.dbName, token.collName)
+		}
+		wasLow := token.low
+		token.low = true
+		if wasLow {
+			continue
+		}
+		c.notify(notify.OplogHeadroomLowAlert, token.dbName, token.collName, msg, nil)
+		if c.options.oplogWarningWebhook == "" {
+			continue
+		}
+		c.notifier.Post(c.options.oplogWarningWebhook, &oplogWarning{
+			Alert:       string(notify.OplogHeadroomLowAlert),
+			InstanceId:  c.options.instanceId,
+			Database:    token.dbName,
+			Collection:  token.collName,
+			TokenTime:   token.time,
+			OplogOldest: oldest,
+			Headroom:    headroom.String(),
+			Threshold:   c.options.oplogWarningHeadroom.String(),
+		})
+	}
+}
+
+// notify posts an alert of the given kind about the given collection, if any, to the webhooks accepting it.
+func (c *Connector) notify(kind notify.Kind, dbName, collName, msg string, err error) {
+	alert := &notify.Alert{
+		Kind:       kind,
+		InstanceId: c.options.instanceId,
+		Database:   dbName,
+		Collection: collName,
+		Message:    msg,
+		Time:       time.Now(),
+	}
+	if err != nil {
+		alert.Error = err.Error()
+	}
+	c.notifier.Notify(alert)
+}
+
+// watermarkTimestamp is the cluster time of a watermark, i.e. its seconds and increment.
+type watermarkTimestamp struct {
+	T uint32 `json:"t"`
+	I uint32 `json:"i"`
+}
+
+// watermarkMsg is published periodically to the watermark subject, holding the cluster time up to which all the change
+// events of all the watched collections are processed, i.e. published and their resume tokens persisted.
+type watermarkMsg struct {
+	ClusterTime time.Time                     `json:"clusterTime"`
+	Timestamp   watermarkTimestamp            `json:"timestamp"`
+	Collections map[string]watermarkTimestamp `json:"collections"`
+}
+
+// watermark returns the current watermark, i.e. the lowest watermark of the watched collections, or nil if some of
+// them did not report their watermark yet.
+func (c *Connector) watermark() *watermarkMsg {
+	c.watermarksMu.Lock()
+	defer c.watermarksMu.Unlock()
+	if len(c.watermarks) < len(c.options.collections) {
+		return nil
+	}
+	var lowest primitive.Timestamp
+	msg := &watermarkMsg{Collections: make(map[string]watermarkTimestamp, len(c.watermarks))}
+	for namespace, ts := range c.watermarks {
+		if lowest.IsZero() || ts.Before(lowest) {
+			lowest = ts
+		}
+		msg.Collections[namespace] = watermarkTimestamp{T: ts.T, I: ts.I}
+	}
+	msg.ClusterTime = time.Unix(int64(lowest.T), 0).UTC()
+	msg.Timestamp = watermarkTimestamp{T: lowest.T, I: lowest.I}
+	return msg
+}
+
+// publishWatermarks publishes the current watermark to core NATS on each watermark interval, until the given context
+// is cancelled, so that downstream jobs can process everything up to it. Watermarks are best-effort: if one cannot be
+// published, the failure is logged, and it is published again on the next interval.
+func (c *Connector) publishWatermarks(ctx context.Context) {
+	ticker := time.NewTicker(c.options.watermarkInterval)
+	defer ticker.Stop()
+	headers := maps.Clone(c.headers)
+	headers[contentTypeHdr] = mongo.JsonEncoder.ContentType()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		msg := c.watermark()
+		if msg == nil {
+			continue
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			c.logger.Warn("could not encode watermark", "err", err)
+			continue
+		}
+		opts := &nats.PublishOptions{
+			Subj:    c.options.watermarkSubject,
+			Data:    data,
+			Mode:    nats.CorePublishMode,
+			Headers: headers,
+		}
+		if err = c.options.natsClient.Publish(ctx, opts); err != nil && ctx.Err() == nil {
+			c.logger.Warn("could not publish watermark", "subj", c.options.watermarkSubject, "err", err)
+		}
+	}
+}
+
+// metricsHandler returns the handler of GET /metrics, or nil if the metrics are not scraped by Prometheus.
+func (c *Connector) metricsHandler() http.Handler {
+	if c.options.metricsExporter != prometheusMetricsExporter {
+		return nil
+	}
+	return prometheus.HTTPHandler()
+}
+
+// exportMetrics pushes the metrics of the Connector with the given exporter every statsd interval, until the given
+// context is cancelled, then pushes them a last time, so that the final increments are not lost.
+func (c *Connector) exportMetrics(ctx context.Context, exporter *prometheus.StatsdExporter) {
+	defer func() {
+		if err := exporter.Close(); err != nil {
+			c.logger.Warn("could not close statsd exporter", "err", err)
+		}
+	}()
+	ticker := time.NewTicker(c.options.statsdInterval)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+		}
+		if err := exporter.Export(); err != nil {
+			c.logger.Warn("could not export metrics", "addr", c.options.statsdAddr, "err", err)
+		}
+	}
+}
+
+// statusEntry is the status of the Connector put into the status bucket, along with the time it was put at.
+type statusEntry struct {
+	*server.StatusReport
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// putStatus puts the status of the Connector into the status bucket, under its instance id, right away then every
+// status interval, until the given context is cancelled. The status is then deleted, so that the bucket only holds the
+// status of the running instances. Failures are logged, and the status is put again on the next interval.
+func (c *Connector) putStatus(ctx context.Context) {
+	ticker := time.NewTicker(c.options.statusInterval)
+	defer ticker.Stop()
+	var kv nats.KeyValue
+	for {
+		var err error
+		if kv == nil {
+			kv, err = c.options.natsClient.KeyValue(c.options.statusBucket)
+		}
+		if err == nil {
+			err = c.putStatusEntry(ctx, kv)
+		}
+		if err != nil && ctx.Err() == nil {
+			c.logger.Warn("could not put status", "bucket", c.options.statusBucket, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if kv != nil {
+				if err = kv.Delete(c.options.instanceId); err != nil {
+					c.logger.Warn("could not delete status", "bucket", c.options.statusBucket, "err", err)
+				}
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// notifySystemd tells systemd that the Connector is ready, since its watchers are started, then pets its watchdog, if
+// enabled, twice per watchdog interval, until the given context is cancelled, and tells it that the Connector is stopping.
+// The watchdog is pet by the run loop of the Connector, so that the Connector is restarted if the loop is stuck.
+func (c *Connector) notifySystemd(ctx context.Context) {
+	if _, err := systemd.Notify(systemd.ReadyState); err != nil {
+		c.logger.Warn("could not notify systemd", "err", err)
+	}
+	defer func() {
+		if _, err := systemd.Notify(systemd.StoppingState); err != nil {
+			c.logger.Warn("could not notify systemd", "err", err)
+		}
+	}()
+
+	interval, err := systemd.WatchdogInterval()
+	if err != nil {
+		c.logger.Warn("systemd watchdog is not pet", "err", err)
+	}
+	if interval <= 0 {
+		<-ctx.Done()
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := systemd.Notify(systemd.WatchdogState); err != nil {
+				c.logger.Warn("could not pet systemd watchdog", "err", err)
+			}
+		}
+	}
+}
+
+func (c *Connector) putStatusEntry(ctx context.Context, kv nats.KeyValue) error {
+	data, err := json.Marshal(&statusEntry{StatusReport: c.server.StatusReport(ctx), UpdatedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("could not encode status: %v", err)
+	}
+	return kv.Put(c.options.instanceId, data)
+}
+
+// persistedSchemaVersion is the schema version of the change events of a collection without explicit schema version, as
+// persisted in the schema versions bucket, along with the fingerprint of the options it applies to.
+type persistedSchemaVersion struct {
+	Version     int    `json:"version"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// envelopeFingerprint returns the fingerprint of the options of the given collection affecting the envelope of its
+// change events, i.e. their encoding and the fields they hold.
+func envelopeFingerprint(coll *collection) string {
+	excludeFields := slices.Clone(coll.excludeFields)
+	slices.Sort(excludeFields)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("encoder=%v;excludeFields=%v;gridFs=%v", coll.pipeline.encoder,
+		strings.Join(excludeFields, ","), coll.gridFs)))
+	return hex.EncodeToString(sum[:])
+}
+
+// envelopeVersion returns the schema version stamped on the change events of the given collection if it has no
+// explicit schema version, i.e. 1 without schema versions bucket, otherwise the version persisted in the bucket, which
+// is bumped if the options affecting the envelope of its change events changed since the previous run.
+func (c *Connector) envelopeVersion(coll *collection) (int, error) {
+	if c.options.schemaVersionsBucket == "" {
+		return 1, nil
+	}
+	kv, err := c.options.natsClient.KeyValue(c.options.schemaVersionsBucket)
+	if err != nil {
+		return 0, err
+	}
+	fingerprint := envelopeFingerprint(coll)
+	version := persistedSchemaVersion{Version: 1, Fingerprint: fingerprint}
+	value, err := kv.Get(coll.namespace())
+	switch {
+	case errors.Is(err, nats.ErrKeyNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		if err = json.Unmarshal(value, &version); err != nil {
+			return 0, fmt.Errorf("could not unmarshal schema version of %v: %v", coll.namespace(), err)
+		}
+		if version.Fingerprint == fingerprint {
+			return version.Version, nil
+		}
+		version = persistedSchemaVersion{Version: version.Version + 1, Fingerprint: fingerprint}
+		c.logger.Info("envelope of the change events changed, schema version bumped", "dbName", coll.dbName,
+			"collName", coll.collName, "schemaVersion", version.Version)
+	}
+	if value, err = json.Marshal(version); err != nil {
+		return 0, err
+	}
+	if err = kv.Put(coll.namespace(), value); err != nil {
+		return 0, err
+	}
+	return version.Version, nil
+}
+
+// addStreams adds the stream of the given collection, and the streams its change events are routed to, with the given
+// NATS client, bound to the subjects of the given schema version.
+func (c *Connector) addStreams(ctx context.Context, natsClient nats.Client, coll *collection,
+	watchCollOpts *mongo.WatchCollectionOptions, version int) error {
+	added := map[string]bool{}
+	for _, opts := range append([]*mongo.WatchCollectionOptions{watchCollOpts}, mongo.RouteOptions(watchCollOpts)...) {
+		if added[opts.StreamName] {
+			continue
+		}
+		added[opts.StreamName] = true
+		addStreamOpts := &nats.AddStreamOptions{
+			StreamName:  opts.StreamName,
+			Subject:     mongo.VersionSubjectFilter(opts, version),
+			Duplicates:  coll.duplicatesWindow,
+			ReplaySpan:  c.replaySpan(),
+			Limits:      coll.streamLimits,
+			AllowMsgTtl: coll.msgTtl > 0,
+		}
+		if err := natsClient.AddStream(ctx, addStreamOpts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchedCollection is a collection set up to be watched, along with the options of its watcher.
+type watchedCollection struct {
+	coll *collection
+	opts *mongo.WatchCollectionOptions
+}
+
+// validateSubjects checks that the subjects of the change events of the given collections published to JetStream, and
+// the ones of their routes, are each bound to exactly one stream, the one they are published to, so that none of them
+// is lost because no stream matches its subject, or stored by another stream. It returns an error wrapping
+// ErrUnboundSubjects which lists all the subjects which are not.
+func (c *Connector) validateSubjects(ctx context.Context, watched []watchedCollection) error {
+	var unbound []error
+	for _, w := range watched {
+		if w.coll.publishMode != nats.JetStreamPublishMode {
+			continue
+		}
+		for _, natsClient := range c.natsClientsOf(w.coll) {
+			for _, opts := range append([]*mongo.WatchCollectionOptions{w.opts}, mongo.RouteOptions(w.opts)...) {
+				for _, subject := range mongo.SubjectPatterns(opts) {
+					binding, err := natsClient.SubjectBinding(ctx, subject)
+					if err != nil {
+						return err
+					}
+					if problem := bindingProblem(binding, opts.StreamName); problem != "" {
+						unbound = append(unbound, fmt.Errorf("%v: %v: subject %v %v", natsClient.Name(),
+							w.coll.namespace(), subject, problem))
+					}
+				}
+			}
+		}
+	}
+	if len(unbound) > 0 {
+		return fmt.Errorf("%w:\n%w", ErrUnboundSubjects, errors.Join(unbound...))
+	}
+	return nil
+}
+
+// bindingProblem returns why the given binding of a subject pattern does not bind it to exactly the given stream, or
+// an empty string if it does.
+func bindingProblem(binding *nats.SubjectBinding, stream string) string {
+	switch {
+	case len(binding.Streams) == 0:
+		return "is not bound to any stream"
+	case len(binding.Streams) > 1:
+		return "overlaps several streams: " + strings.Join(binding.Streams, ", ")
+	case binding.Streams[0] != stream:
+		return fmt.Sprintf("is bound to stream %v instead of %v", binding.Streams[0], stream)
+	case !binding.Covered:
+		return "is only partially bound to stream " + stream
+	}
+	return ""
+}
+
+// Cutover switches the schema version prefixing the subjects of the change events of the given collection, e.g. from
+// v1.ORDERS.insert to v2.ORDERS.insert, once its pending change events are published with the current one. The
+// streams of the collection are bound to the subjects of the new version beforehand, and keep the ones of the
+// previous version, so that its consumers can drain them.
+func (c *Connector) Cutover(ctx context.Context, namespace string, version int) error {
+	if version < 1 {
+		return server.ErrInvalidSchemaVersion
+	}
+	c.watchOptsMu.RLock()
+	watchCollOpts, ok := c.watchOpts[namespace]
+	c.watchOptsMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", server.ErrCollectionNotFound, namespace)
+	}
+	coll := c.collection(namespace)
+	if coll.publishMode == nats.JetStreamPublishMode {
+		for _, natsClient := range c.natsClientsOf(coll) {
+			if err := c.addStreams(ctx, natsClient, coll, watchCollOpts, version); err != nil {
+				return err
+			}
+		}
+	}
+	from := watchCollOpts.SchemaVersion.Current()
+	if err := watchCollOpts.SchemaVersion.Cutover(ctx, version); err != nil {
+		return err
+	}
+	c.logger.Info("schema version cutover applied", "dbName", coll.dbName, "collName", coll.collName, "from", from,
+		"to", version, "requestId", server.RequestId(ctx))
+	return nil
+}
+
+// Backfill publishes the documents of the given collection matching the given filter, a query document in extended
+// JSON, as synthetic replace change events, so that downstream state can be repaired for a subset of the data. They
+// are published like the change events of the collection, with a Connector-Backfill header holding the id of the
+// backfill, and at most rateLimit documents per second if it is positive, but their resume tokens are not persisted.
+// Backfill is an alternative to Run: once it returns, the Connector is closed. It returns the number of documents that
+// were backfilled.
+func (c *Connector) Backfill(namespace, filter string, rateLimit float64) (int, error) {
+	defer c.cleanup()
+	defer c.pushMetrics("backfill")
+
+	coll := c.collection(namespace)
+	if coll == nil {
+		return 0, fmt.Errorf("%w: %s", server.ErrCollectionNotFound, namespace)
+	}
+	query, err := mongo.ParseBackfillFilter(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := c.options.ctx
+	envelopeVersion, err := c.envelopeVersion(coll)
+	if err != nil {
+		return 0, err
+	}
+	watchCollOpts := c.watchCollectionOptions(ctx, coll, envelopeVersion)
+	if coll.publishMode == nats.JetStreamPublishMode {
+		for _, natsClient := range c.natsClientsOf(coll) {
+			if err = c.addStreams(ctx, natsClient, coll, watchCollOpts, watchCollOpts.SchemaVersion.Current()); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	id := "backfill-" + time.Now().UTC().Format(backfillIdLayout)
+	c.logger.Info("backfilling collection", "dbName", coll.dbName, "collName", coll.collName, "backfill", id,
+		"filter", filter, "rateLimit", rateLimit)
+	return c.options.mongoClient.Backfill(ctx, &mongo.BackfillOptions{
+		Id:         id,
+		Filter:     query,
+		RateLimit:  rateLimit,
+		Collection: watchCollOpts,
+	})
+}
+
+// Redrive re-processes the change events quarantined for the collection with the given namespace through its current
+// pipeline, and publishes them, e.g. once the issue which made them fail is fixed, then cleans up. It stops at the
+// first change event that fails again, which stays quarantined, and returns the number of change events redriven.
+func (c *Connector) Redrive(namespace string) (int, error) {
+	defer c.cleanup()
+	defer c.pushMetrics("redrive")
+
+	coll := c.collection(namespace)
+	if coll == nil {
+		return 0, fmt.Errorf("%w: %s", server.ErrCollectionNotFound, namespace)
+	}
+	if coll.quarantineCollName == "" {
+		return 0, fmt.Errorf("%w: %s", ErrQuarantineMissing, namespace)
+	}
+
+	ctx := c.options.ctx
+	envelopeVersion, err := c.envelopeVersion(coll)
+	if err != nil {
+		return 0, err
+	}
+	watchCollOpts := c.watchCollectionOptions(ctx, coll, envelopeVersion)
+	if coll.publishMode == nats.JetStreamPublishMode {
+		for _, natsClient := range c.natsClientsOf(coll) {
+			if err = c.addStreams(ctx, natsClient, coll, watchCollOpts, watchCollOpts.SchemaVersion.Current()); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	c.logger.Info("redriving quarantined change events", "dbName", coll.dbName, "collName", coll.collName,
+		"quarantine", coll.quarantineCollName)
+	return c.options.mongoClient.Redrive(ctx, watchCollOpts)
+}
+
+// pushMetrics pushes the Connector's metrics to the Pushgateway, if any, once the given short-lived command completes,
+// whether it succeeded or not, grouped by the instance id of the Connector and the command.
+func (c *Connector) pushMetrics(command string) {
+	if c.options.pushgatewayUrl == "" {
+		return
+	}
+	exporter := prometheus.NewPushgatewayExporter(c.options.pushgatewayUrl, c.options.pushgatewayJob,
+		map[string]string{instanceGrouping: c.options.instanceId, commandGrouping: command})
+	if err := exporter.Export(); err != nil {
+		c.logger.Warn("could not push metrics", "url", c.options.pushgatewayUrl, "command", command, "err", err)
+		return
+	}
+	c.logger.Info("metrics pushed", "url", c.options.pushgatewayUrl, "command", command)
+}
+
+// runSnapshots re-snapshots the given collection on its schedule, like Backfill does, until the given context is
+// cancelled. A snapshot that fails is logged, and the collection is snapshotted again on the next activation.
+func (c *Connector) runSnapshots(ctx context.Context, coll *collection, watchCollOpts *mongo.WatchCollectionOptions) {
+	query, _ := mongo.ParseBackfillFilter(coll.snapshot.filter) // validated by WithSnapshot
+	for {
+		next := coll.snapshot.schedule.Next(time.Now())
+		if next.IsZero() {
+			c.logger.Warn("snapshot schedule has no next activation, the collection is not snapshotted anymore",
+				"dbName", coll.dbName, "collName", coll.collName, "schedule", coll.snapshot.schedule.String())
+			return
+		}
+		if err := sleep(ctx, time.Until(next)); err != nil {
+			return
+		}
+
+		// the id is the one of the activation, so that the snapshot is published with the same message ids if it is
+		// taken again, e.g. by another instance
+		id := "snapshot-" + next.UTC().Format(backfillIdLayout)
+		c.logger.Info("snapshotting collection", "dbName", coll.dbName, "collName", coll.collName, "snapshot", id)
+		documents, err := c.options.mongoClient.Backfill(ctx, &mongo.BackfillOptions{
+			Id:         id,
+			Filter:     query,
+			RateLimit:  coll.pipeline.rateLimit,
+			Collection: watchCollOpts,
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.logger.Error("could not snapshot collection, it is snapshotted again on the next activation",
+				"dbName", coll.dbName, "collName", coll.collName, "snapshot", id, "err", err)
+			c.publishEvent(ctx, snapshotFailedEvent, coll.dbName, coll.collName, &event{Snapshot: id,
+				Error: err.Error()})
+			continue
+		}
+		c.publishEvent(ctx, snapshotCompletedEvent, coll.dbName, coll.collName, &event{Snapshot: id,
+			Documents: &documents})
+	}
+}
+
+// collection returns the watched collection of the given namespace, if any.
+func (c *Connector) collection(namespace string) *collection {
+	for _, coll := range c.options.collections {
+		if coll.namespace() == namespace {
+			return coll
+		}
+	}
+	return nil
+}
+
+// addSchemaChangesStream adds the schema changes stream with the given NATS client, if schema changes are published
+// and the stream was not already added with that client.
+func (c *Connector) addSchemaChangesStream(ctx context.Context, natsClient nats.Client, added map[nats.Client]bool) error {
+	if c.options.schemaChangesStream == "" || added[natsClient] {
+		return nil
+	}
+	addStreamOpts := &nats.AddStreamOptions{
+		StreamName: c.options.schemaChangesStream,
+		Subject:    mongo.SchemaChangesSubjectFilter(c.options.schemaChangesStream),
+		ReplaySpan: c.replaySpan(),
+	}
+	if err := natsClient.AddStream(ctx, addStreamOpts); err != nil {
+		return err
+	}
+	added[natsClient] = true
+	return nil
+}
+
+// wait waits for the given group to complete.
+// Once the group's context is cancelled, it waits at most for the configured shutdown timeout, giving the watchers
+// enough time to receive the acks of the in-flight publishes and persist the final resume tokens.
+// If the timeout is exceeded, ErrForcedShutdown is returned.
+func (c *Connector) wait(group *errgroup.Group, groupCtx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- group.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-groupCtx.Done():
+	}
+
+	timer := time.NewTimer(c.options.shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		c.logger.Error("could not drain in time, forcing shutdown", "timeout", c.options.shutdownTimeout)
+		return ErrForcedShutdown
+	}
+}
+
+// replaySpan returns the worst-case time span of change events that could be published again after a restart.
+// Since resume tokens are persisted right after each change event is published, it only depends on the time it takes
+// to shut down and restart the Connector.
+func (c *Connector) replaySpan() time.Duration {
+	return c.options.shutdownTimeout + c.options.maxRestartTime
+}
@@ -0,0 +1,126 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+)
+
+// tenantNames returns the names of the configured tenants, sorted alphabetically.
+func (c *Connector) tenantNames() []string {
+	names := make([]string, 0, len(c.options.tenants))
+	for name := range c.options.tenants {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// natsClientsOf returns the NATS clients the change events of the given collection can be published with, i.e. the
+// clients of all the tenants the collection is routed to, or the default client if tenant routing is disabled.
+func (c *Connector) natsClientsOf(coll *collection) []nats.Client {
+	switch {
+	case coll.tenantDbName:
+		return []nats.Client{c.options.tenants[coll.dbName].natsClient}
+	case coll.tenantField != "":
+		clients := make([]nats.Client, 0, len(c.options.tenants))
+		for _, name := range c.tenantNames() {
+			clients = append(clients, c.options.tenants[name].natsClient)
+		}
+		return clients
+	default:
+		return []nats.Client{c.options.natsClient}
+	}
+}
+
+// natsClientsFor returns the NATS clients the given change event must be published with.
+// Change events of tenants with no NATS account are never published with the default client, so that tenants are
+// strictly isolated. Rename change events and schema changes hold no document, hence no tenant, so they are published
+// with the clients of all the tenants the collection is routed to.
+func (c *Connector) natsClientsFor(coll *collection, event *mongo.ChangeEvent) ([]nats.Client, error) {
+	name := event.Tenant
+	switch {
+	case coll.tenantDbName:
+		name = coll.dbName
+	case coll.tenantField == "" || event.OperationType == renameOperationType ||
+		event.OperationType == gapOperationType || event.SchemaChange:
+		return c.natsClientsOf(coll), nil
+	}
+	t, ok := c.options.tenants[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, msgId %s", ErrUnknownTenant, name, event.MsgId)
+	}
+	return []nats.Client{t.natsClient}, nil
+}
+
+// handleUnknownTenant applies the unknown tenant policy of the given collection to the given change event, whose
+// tenant has no NATS account. Dead letters are published with the default NATS client. Halting is not retried, since
+// the change event would fail forever.
+func (c *Connector) handleUnknownTenant(runCtx, ctx context.Context, coll *collection, event *mongo.ChangeEvent,
+	err error) error {
+	switch coll.errorPolicies.Action(mongo.UnknownTenantErrorClass) {
+	case mongo.SkipErrorAction:
+		c.logger.Warn("change event of a tenant without nats account, skipping it", "subj", event.Subj,
+			"msgId", event.MsgId, "err", err)
+		return nil
+	case mongo.DlqErrorAction:
+		c.logger.Warn("change event of a tenant without nats account, publishing it to the dead letter subject",
+			"subj", event.Subj, "dlqSubject", coll.dlqSubject, "err", err)
+		opts := dlqPublishOpts(coll, c.publishOptsOf(coll, event), tenantErrorHdr, err)
+		if err = c.publish(runCtx, ctx, coll, c.options.natsClient, opts); err != nil {
+			return fmt.Errorf("%w: %w", ErrNatsFailed, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %w", mongo.ErrUnpublishable, err)
+}
+
+type tenant struct {
+	natsUrl    string
+	natsClient nats.Client
+}
+
+// WithTenant configures a tenant whose change events will be published to its own NATS account, i.e. with a dedicated
+// connection to the given NATS URL, which should hold the credentials of the account.
+func WithTenant(name, natsUrl string) Option {
+	return func(o *Options) error {
+		if name == "" || natsUrl == "" {
+			return ErrInvalidTenant
+		}
+		o.tenants[name] = &tenant{natsUrl: natsUrl}
+		return nil
+	}
+}
+
+// withTenantNatsClient configures a tenant with the given NATS client implementation.
+// Used for testing.
+func withTenantNatsClient(name string, natsClient nats.Client) Option {
+	return func(o *Options) error {
+		o.tenants[name] = &tenant{natsClient: natsClient}
+		return nil
+	}
+}
+
+// WithTenantField routes the change events of the collection to be watched to the NATS account of the tenant named
+// after the given field of the full document, or of the document before the change, e.g. for deletions.
+// Nested fields can be specified by using the dot notation.
+func WithTenantField(tenantField string) CollectionOption {
+	return func(c *collection) error {
+		if tenantField != "" {
+			c.tenantField = tenantField
+		}
+		return nil
+	}
+}
+
+// WithTenantDbName routes the change events of the collection to be watched to the NATS account of the tenant named
+// after the database of the collection.
+func WithTenantDbName() CollectionOption {
+	return func(c *collection) error {
+		c.tenantDbName = true
+		return nil
+	}
+}
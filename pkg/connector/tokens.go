@@ -0,0 +1,76 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+)
+
+// TokenInspection is the inspection of the last resume token stored for a watched collection: the cluster time it
+// decodes to, whether it is still in the oplog window, and the number of stored resume tokens which are corrupt.
+type TokenInspection struct {
+	Namespace string `json:"namespace"`
+	*mongo.ResumeTokenInspection
+}
+
+// InspectTokens inspects the last resume token stored for the watched collection with the given namespace, or for
+// every watched collection if it is empty, then cleans up.
+// InspectTokens is an alternative to Run.
+func (c *Connector) InspectTokens(namespace string) ([]TokenInspection, error) {
+	defer c.cleanup()
+
+	colls, err := c.tokenCollections(namespace)
+	if err != nil {
+		return nil, err
+	}
+	inspections := make([]TokenInspection, 0, len(colls))
+	for _, coll := range colls {
+		inspection, err := c.options.mongoClient.InspectResumeToken(c.options.ctx, resumeTokensOptions(coll))
+		if err != nil {
+			return inspections, fmt.Errorf("could not inspect resume token of %v: %w", coll.namespace(), err)
+		}
+		inspections = append(inspections, TokenInspection{Namespace: coll.namespace(),
+			ResumeTokenInspection: inspection})
+	}
+	return inspections, nil
+}
+
+// RepairTokens rewrites the corrupt resume tokens stored for the watched collection with the given namespace with the
+// given token, or deletes them if it is empty, then cleans up. Corrupt resume tokens are deleted for every watched
+// collection if the namespace is empty, in which case no token must be given.
+// RepairTokens is an alternative to Run. It returns the number of resume tokens that were repaired.
+func (c *Connector) RepairTokens(namespace, token string) (int, error) {
+	defer c.cleanup()
+
+	if namespace == "" && token != "" {
+		return 0, errors.New("could not repair resume tokens: a collection must be given to rewrite them")
+	}
+	colls, err := c.tokenCollections(namespace)
+	if err != nil {
+		return 0, err
+	}
+	repaired := 0
+	for _, coll := range colls {
+		n, err := c.options.mongoClient.RepairResumeTokens(c.options.ctx, resumeTokensOptions(coll), token)
+		repaired += n
+		if err != nil {
+			return repaired, fmt.Errorf("could not repair resume tokens of %v: %w", coll.namespace(), err)
+		}
+	}
+	return repaired, nil
+}
+
+// tokenCollections returns the watched collection with the given namespace, or every watched collection if it is
+// empty.
+func (c *Connector) tokenCollections(namespace string) ([]*collection, error) {
+	if namespace == "" {
+		return c.options.collections, nil
+	}
+	coll := c.collection(namespace)
+	if coll == nil {
+		return nil, fmt.Errorf("%w: %s", server.ErrCollectionNotFound, namespace)
+	}
+	return []*collection{coll}, nil
+}
@@ -0,0 +1,93 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/server"
+)
+
+func TestConnector_InspectTokens(t *testing.T) {
+	const token = "82645A43BA000000012B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004"
+	newConnector := func(mongoClient *mockMongoClient) *Connector {
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(&mockNatsClient{}),
+			WithServerDisabled(),
+			WithCollection("shop", "orders", WithTokensCollName("orders-tokens")),
+			WithCollection("shop", "invoices", WithTokensCollName("invoices-tokens")),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+
+	t.Run("should inspect the resume tokens of every collection", func(t *testing.T) {
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{
+			"resume-tokens.orders-tokens": token, "resume-tokens.invoices-tokens": "corrupt"}}
+
+		inspections, err := newConnector(mongoClient).InspectTokens("")
+
+		require.NoError(t, err)
+		require.Len(t, inspections, 2)
+		require.Equal(t, "shop.orders", inspections[0].Namespace)
+		require.Equal(t, mongo.ValidTokenStatus, inspections[0].Status)
+		require.Equal(t, "shop.invoices", inspections[1].Namespace)
+		require.Equal(t, mongo.CorruptTokenStatus, inspections[1].Status)
+		require.Equal(t, 1, inspections[1].Corrupt)
+		require.True(t, mongoClient.closed)
+	})
+	t.Run("should inspect the resume token of the given collection", func(t *testing.T) {
+		inspections, err := newConnector(&mockMongoClient{}).InspectTokens("shop.invoices")
+
+		require.NoError(t, err)
+		require.Len(t, inspections, 1)
+		require.Equal(t, mongo.MissingTokenStatus, inspections[0].Status)
+	})
+	t.Run("should return error if the collection is not watched", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}).InspectTokens("shop.customers")
+
+		require.ErrorIs(t, err, server.ErrCollectionNotFound)
+	})
+}
+
+func TestConnector_RepairTokens(t *testing.T) {
+	const token = "82645A43BA000000012B022C0100296E5A100441C14B603DF24D51BCD95A16D118E42F46645F69640064645A43BA84439E9C4F4144EB0004"
+	newConnector := func(mongoClient *mockMongoClient) *Connector {
+		conn, err := New(
+			withMongoClient(mongoClient),
+			withNatsClient(&mockNatsClient{}),
+			WithServerDisabled(),
+			WithCollection("shop", "orders", WithTokensCollName("orders-tokens")),
+			WithCollection("shop", "invoices", WithTokensCollName("invoices-tokens")),
+		)
+		require.NoError(t, err)
+		return conn
+	}
+
+	t.Run("should delete the corrupt resume tokens of every collection", func(t *testing.T) {
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{
+			"resume-tokens.orders-tokens": token, "resume-tokens.invoices-tokens": "corrupt"}}
+
+		repaired, err := newConnector(mongoClient).RepairTokens("", "")
+
+		require.NoError(t, err)
+		require.Equal(t, 1, repaired)
+		require.Equal(t, map[string]string{"resume-tokens.orders-tokens": token}, mongoClient.resumeTokens)
+	})
+	t.Run("should rewrite the corrupt resume tokens of the given collection", func(t *testing.T) {
+		mongoClient := &mockMongoClient{resumeTokens: map[string]string{"resume-tokens.invoices-tokens": "corrupt"}}
+
+		repaired, err := newConnector(mongoClient).RepairTokens("shop.invoices", token)
+
+		require.NoError(t, err)
+		require.Equal(t, 1, repaired)
+		require.Equal(t, map[string]string{"resume-tokens.invoices-tokens": token}, mongoClient.resumeTokens)
+	})
+	t.Run("should return error if a token is given without collection", func(t *testing.T) {
+		_, err := newConnector(&mockMongoClient{}).RepairTokens("", token)
+
+		require.Error(t, err)
+	})
+}
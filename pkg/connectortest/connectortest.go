@@ -0,0 +1,317 @@
+// Package connectortest runs the connector against a MongoDB replica set in a container and an embedded NATS server,
+// so that the consumers of its change events can be tested end to end.
+package connectortest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
+)
+
+const (
+	defaultMongoImage   = "mongo:7.0"
+	defaultStartTimeout = 30 * time.Second
+)
+
+// instances counts the connectors started by the harnesses of the test binary, so that each of them has its own
+// instance id, and its metrics can be told apart from the ones of the others.
+var instances atomic.Int64
+
+// Harness holds a MongoDB replica set and a NATS server with JetStream enabled, which the connector started with
+// StartConnector watches and publishes to, along with clients to both of them.
+type Harness struct {
+	t testing.TB
+
+	MongoUri    string
+	MongoClient *mongo.Client
+	NatsUrl     string
+	NatsConn    *nats.Conn
+	NatsJs      nats.JetStreamContext
+
+	startTimeout time.Duration
+}
+
+// Options holds the settings of a Harness.
+type Options struct {
+	mongoImage   string
+	mongoUri     string
+	startTimeout time.Duration
+}
+
+// Option is a functional option for a Harness.
+type Option func(*Options)
+
+func getDefaultOptions() Options {
+	return Options{
+		mongoImage:   defaultMongoImage,
+		startTimeout: defaultStartTimeout,
+	}
+}
+
+// WithMongoImage sets the image of the MongoDB container, e.g. to test with the MongoDB version used in production.
+func WithMongoImage(image string) Option {
+	return func(o *Options) {
+		if image != "" {
+			o.mongoImage = image
+		}
+	}
+}
+
+// WithMongoUri sets the URI of an existing MongoDB replica set to use instead of starting a container, e.g. a service
+// of the CI pipeline.
+func WithMongoUri(mongoUri string) Option {
+	return func(o *Options) {
+		o.mongoUri = mongoUri
+	}
+}
+
+// WithStartTimeout sets the maximum amount of time the harness waits for MongoDB, NATS and the connector to be ready.
+func WithStartTimeout(startTimeout time.Duration) Option {
+	return func(o *Options) {
+		if startTimeout > 0 {
+			o.startTimeout = startTimeout
+		}
+	}
+}
+
+// New starts a MongoDB replica set in a container, unless WithMongoUri is given, and an embedded NATS server, then
+// connects to both of them. They are all stopped once the test completes.
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+	o := getDefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), o.startTimeout)
+	defer cancel()
+
+	h := &Harness{t: t, MongoUri: o.mongoUri, startTimeout: o.startTimeout}
+	if h.MongoUri == "" {
+		h.MongoUri = startMongo(ctx, t, o.mongoImage)
+	}
+	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(h.MongoUri))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mongoClient.Disconnect(context.Background()))
+	})
+	require.NoError(t, mongoClient.Ping(ctx, nil), "could not reach mongodb")
+	h.MongoClient = mongoClient
+
+	h.NatsUrl = startNats(t, o.startTimeout)
+	natsConn, err := nats.Connect(h.NatsUrl)
+	require.NoError(t, err)
+	t.Cleanup(natsConn.Close)
+	h.NatsConn = natsConn
+	h.NatsJs, err = natsConn.JetStream()
+	require.NoError(t, err)
+
+	return h
+}
+
+// startMongo starts a single-node MongoDB replica set in a container, since change streams require one, and returns
+// its URI. It connects directly to the node, whose advertised host is only reachable from the container network.
+func startMongo(ctx context.Context, t testing.TB, image string) string {
+	container, err := mongodb.RunContainer(ctx, testcontainers.WithImage(image), mongodb.WithReplicaSet())
+	require.NoError(t, err, "could not start mongodb container")
+	t.Cleanup(func() {
+		assert.NoError(t, container.Terminate(context.Background()))
+	})
+	endpoint, err := container.ConnectionString(ctx)
+	require.NoError(t, err)
+	return endpoint + "/?directConnection=true"
+}
+
+// startNats starts an embedded NATS server with JetStream enabled, listening on a random port, and returns its URL.
+func startNats(t testing.TB, startTimeout time.Duration) string {
+	s, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      natsserver.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(func() {
+		s.Shutdown()
+		s.WaitForShutdown()
+	})
+	require.True(t, s.ReadyForConnections(startTimeout), "could not start nats server")
+	return s.ClientURL()
+}
+
+// StartConnector creates a connector publishing the change events of MongoDB to NATS with the given options, e.g. its
+// collections, runs it, then waits for the change streams of all its collections to be open, so that the documents
+// written from then on are published. The connector is stopped once the test completes.
+// Its HTTP server is disabled, and its instance id is set by the harness.
+func (h *Harness) StartConnector(opts ...connector.Option) *connector.Connector {
+	h.t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	instanceId := fmt.Sprintf("connectortest-%d", instances.Add(1))
+
+	opts = append([]connector.Option{connector.WithServerDisabled()}, opts...)
+	opts = append(opts,
+		connector.WithMongoUri(h.MongoUri),
+		connector.WithNatsUrl(h.NatsUrl),
+		connector.WithContext(ctx),
+		connector.WithInstanceId(instanceId),
+	)
+	conn, err := connector.New(opts...)
+	if err != nil {
+		cancel()
+		require.NoError(h.t, err, "could not create connector")
+	}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- conn.Run()
+	}()
+	h.t.Cleanup(func() {
+		cancel()
+		assert.NoError(h.t, <-runErr, "connector did not stop cleanly")
+	})
+
+	namespaces := make([]string, 0)
+	for namespace := range conn.Status() {
+		namespaces = append(namespaces, namespace)
+	}
+	waitCtx, waitCancel := context.WithTimeout(ctx, h.startTimeout)
+	defer waitCancel()
+	for !changeStreamsOpen(instanceId, namespaces) {
+		select {
+		case err := <-runErr:
+			runErr <- err
+			require.FailNow(h.t, "connector stopped before watching its collections", "err: %v", err)
+		case <-waitCtx.Done():
+			require.FailNow(h.t, "time exhausted: the change streams of the connector are not open")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return conn
+}
+
+// changeStreamsOpen reports whether the connector with the given instance id has a change stream open on each of the
+// given namespaces, according to its mongodb_change_streams_open metric.
+func changeStreamsOpen(instanceId string, namespaces []string) bool {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return false
+	}
+	open := make(map[string]bool)
+	for _, family := range families {
+		if family.GetName() != "mongodb_change_streams_open" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["instance_id"] == instanceId && metric.GetGauge().GetValue() > 0 {
+				open[labels["database"]+"."+labels["collection"]] = true
+			}
+		}
+	}
+	for _, namespace := range namespaces {
+		if !open[namespace] {
+			return false
+		}
+	}
+	return true
+}
+
+// MustInsertOne inserts the given document into the given collection, and returns its id.
+func (h *Harness) MustInsertOne(dbName, collName string, doc any) any {
+	h.t.Helper()
+	result, err := h.MongoClient.Database(dbName).Collection(collName).InsertOne(context.Background(), doc)
+	require.NoError(h.t, err)
+	return result.InsertedID
+}
+
+// MustUpdateOne updates the document of the given collection matching the given filter.
+func (h *Harness) MustUpdateOne(dbName, collName string, filter, update any) {
+	h.t.Helper()
+	result, err := h.MongoClient.Database(dbName).Collection(collName).UpdateOne(context.Background(), filter, update)
+	require.NoError(h.t, err)
+	require.Equal(h.t, int64(1), result.MatchedCount, "no document matches the filter")
+}
+
+// MustReplaceOne replaces the document of the given collection matching the given filter.
+func (h *Harness) MustReplaceOne(dbName, collName string, filter, replacement any) {
+	h.t.Helper()
+	coll := h.MongoClient.Database(dbName).Collection(collName)
+	result, err := coll.ReplaceOne(context.Background(), filter, replacement)
+	require.NoError(h.t, err)
+	require.Equal(h.t, int64(1), result.MatchedCount, "no document matches the filter")
+}
+
+// MustDeleteOne deletes the document of the given collection matching the given filter.
+func (h *Harness) MustDeleteOne(dbName, collName string, filter any) {
+	h.t.Helper()
+	result, err := h.MongoClient.Database(dbName).Collection(collName).DeleteOne(context.Background(), filter)
+	require.NoError(h.t, err)
+	require.Equal(h.t, int64(1), result.DeletedCount, "no document matches the filter")
+}
+
+// Subscription receives the messages stored by JetStream on a subject, in the order they were published.
+type Subscription struct {
+	t   testing.TB
+	sub *nats.Subscription
+}
+
+// MustSubscribe subscribes to the messages stored on the given subject, which can hold wildcards (e.g. ORDERS.>), from
+// the first one, so that the change events published before subscribing are received as well. The subject must be
+// bound to a stream, e.g. one created by the connector once started.
+func (h *Harness) MustSubscribe(subj string) *Subscription {
+	h.t.Helper()
+	sub, err := h.NatsJs.SubscribeSync(subj, nats.OrderedConsumer(), nats.DeliverAll())
+	require.NoError(h.t, err, "could not subscribe to %v", subj)
+	h.t.Cleanup(func() {
+		_ = sub.Unsubscribe()
+	})
+	return &Subscription{t: h.t, sub: sub}
+}
+
+// MustNextMsg returns the next message of the subscription, failing the test if none is received within the given
+// timeout.
+func (s *Subscription) MustNextMsg(timeout time.Duration) *nats.Msg {
+	s.t.Helper()
+	msg, err := s.sub.NextMsg(timeout)
+	require.NoError(s.t, err, "no message received on %v", s.sub.Subject)
+	return msg
+}
+
+// MustNextChangeEvent decodes the JSON payload of the next message of the subscription into the given value, e.g. a
+// map or the change event type of the consumer, and returns the message, e.g. to check its subject or headers.
+func (s *Subscription) MustNextChangeEvent(timeout time.Duration, v any) *nats.Msg {
+	s.t.Helper()
+	msg := s.MustNextMsg(timeout)
+	require.NoError(s.t, json.Unmarshal(msg.Data, v), "could not decode change event")
+	return msg
+}
+
+// MustNotReceiveMsg fails the test if a message is received by the subscription within the given timeout.
+func (s *Subscription) MustNotReceiveMsg(timeout time.Duration) {
+	s.t.Helper()
+	msg, err := s.sub.NextMsg(timeout)
+	if err == nil {
+		require.Failf(s.t, "unexpected message", "received on %v: %s", msg.Subject, msg.Data)
+	}
+	require.ErrorIs(s.t, err, nats.ErrTimeout)
+}
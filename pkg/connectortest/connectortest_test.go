@@ -0,0 +1,40 @@
+package connectortest
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_changeStreamsOpen(t *testing.T) {
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"instance_id": "connectortest-test"},
+		prometheus.DefaultRegisterer)
+	gauge := promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{Name: "mongodb_change_streams_open"},
+		[]string{"database", "collection"})
+	t.Cleanup(func() {
+		registerer.Unregister(gauge)
+	})
+	gauge.WithLabelValues("shop", "orders").Inc()
+	gauge.WithLabelValues("shop", "payments")
+
+	require.True(t, changeStreamsOpen("connectortest-test", []string{"shop.orders"}))
+	require.False(t, changeStreamsOpen("connectortest-test", []string{"shop.orders", "shop.payments"}),
+		"the change stream of shop.payments is not open")
+	require.False(t, changeStreamsOpen("connectortest-other", []string{"shop.orders"}),
+		"the change streams of other connectors are not taken into account")
+}
+
+func Test_startNats(t *testing.T) {
+	natsUrl := startNats(t, defaultStartTimeout)
+
+	natsConn, err := nats.Connect(natsUrl)
+	require.NoError(t, err)
+	defer natsConn.Close()
+	js, err := natsConn.JetStream()
+	require.NoError(t, err)
+	_, err = js.AccountInfo()
+	require.NoError(t, err, "jetstream should be enabled")
+}
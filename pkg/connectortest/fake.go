@@ -0,0 +1,537 @@
+package connectortest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/connectorhook"
+	"github.com/context-labs/mongodb-nats-connector/internal/mongo"
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
+)
+
+// the errors a Sink can be told to fail publishes with, which the connector handles like the ones of a NATS server.
+var (
+	ErrClientReconnecting = nats.ErrClientReconnecting
+	ErrBackpressure       = nats.ErrBackpressure
+	ErrNack               = nats.ErrNack
+	ErrNoStream           = nats.ErrNoStream
+	ErrAckTimeout         = nats.ErrAckTimeout
+)
+
+var (
+	errKeyValueUnsupported  = errors.New("key-value buckets are not supported by the sink")
+	errSubscribeUnsupported = errors.New("subscriptions are not supported by the sink")
+)
+
+const defaultMaxPayload = 1024 * 1024
+
+var _ nats.Client = &Sink{}
+
+// Msg is a message published to a Sink.
+type Msg struct {
+	Subj    string
+	MsgId   string
+	Data    []byte
+	Headers map[string]string
+	// Stream and Sequence are the stream the message is stored at, and its sequence there, unless it was published to
+	// core NATS.
+	Stream   string
+	Sequence uint64
+}
+
+// Sink is an in-memory NATS client recording the messages published by the connector, so that its collections can be
+// tested without a NATS server, see WithSink. It can fail publishes and add latency to them.
+// Key-value buckets and subscriptions are not supported.
+type Sink struct {
+	mu       sync.Mutex
+	msgs     []Msg
+	failures []error
+	latency  time.Duration
+	streams  []string
+	subjects map[string][]string // by stream name
+	objects  map[string][]byte
+}
+
+// NewSink returns an empty Sink.
+func NewSink() *Sink {
+	return &Sink{subjects: make(map[string][]string), objects: make(map[string][]byte)}
+}
+
+// WithSink sets the given Sink as the NATS client of the Connector.
+func WithSink(sink *Sink) connector.Option {
+	return connectorhook.NatsClient(sink).(connector.Option)
+}
+
+// FailNext makes the next publishes fail with the given errors, in order, e.g. ErrAckTimeout.
+func (s *Sink) FailNext(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, errs...)
+}
+
+// SetLatency sets the time each publish takes.
+func (s *Sink) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// Msgs returns the messages published so far, in order.
+func (s *Sink) Msgs() []Msg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.msgs)
+}
+
+// Streams returns the names of the streams added so far.
+func (s *Sink) Streams() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.streams)
+}
+
+func (s *Sink) Name() string {
+	return "nats"
+}
+
+func (s *Sink) Monitor(_ context.Context) error {
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return nil
+}
+
+func (s *Sink) AddStream(_ context.Context, opts *nats.AddStreamOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.streams, opts.StreamName) {
+		s.streams = append(s.streams, opts.StreamName)
+	}
+	if !slices.Contains(s.subjects[opts.StreamName], opts.Subject) {
+		s.subjects[opts.StreamName] = append(s.subjects[opts.StreamName], opts.Subject)
+	}
+	return nil
+}
+
+func (s *Sink) Publish(ctx context.Context, opts *nats.PublishOptions) error {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	if err := sleep(ctx, latency); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.failures) > 0 {
+		err := s.failures[0]
+		s.failures = s.failures[1:]
+		return err
+	}
+	msg := Msg{Subj: opts.Subj, MsgId: opts.MsgId, Data: opts.Data, Headers: opts.Headers}
+	if opts.Mode != nats.CorePublishMode {
+		msg.Stream = opts.ExpectedStream
+		if msg.Stream == "" {
+			msg.Stream, _, _ = strings.Cut(opts.Subj, ".")
+		}
+		msg.Sequence = uint64(len(s.msgsOf(msg.Stream)) + 1)
+		opts.Ack = &natsgo.PubAck{Stream: msg.Stream, Sequence: msg.Sequence}
+	}
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+// msgsOf returns the messages stored at the given stream. The mutex must be held.
+func (s *Sink) msgsOf(stream string) []Msg {
+	var msgs []Msg
+	for _, msg := range s.msgs {
+		if msg.Stream == stream {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+func (s *Sink) WaitConnected(_ context.Context) error {
+	return nil
+}
+
+func (s *Sink) Reconnecting() bool {
+	return false
+}
+
+func (s *Sink) MaxPayload() int64 {
+	return defaultMaxPayload
+}
+
+func (s *Sink) PutObject(_ context.Context, bucket, name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[bucket+"/"+name] = data
+	return nil
+}
+
+func (s *Sink) PutObjectStream(ctx context.Context, bucket, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.PutObject(ctx, bucket, name, data)
+}
+
+func (s *Sink) GetObject(_ context.Context, bucket, name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[bucket+"/"+name]
+	if !ok {
+		return nil, nats.ErrObjectNotFound
+	}
+	return data, nil
+}
+
+func (s *Sink) DeleteObject(_ context.Context, bucket, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, bucket+"/"+name)
+	return nil
+}
+
+func (s *Sink) KeyValue(_ string) (nats.KeyValue, error) {
+	return nil, errKeyValueUnsupported
+}
+
+func (s *Sink) Subscribe(_ context.Context, _ string) (<-chan *nats.Msg, error) {
+	return nil, errSubscribeUnsupported
+}
+
+func (s *Sink) LastMsgHeader(_ context.Context, stream, header string) (uint64, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msgs := s.msgsOf(stream)
+	if len(msgs) == 0 {
+		return 0, "", nil
+	}
+	last := msgs[len(msgs)-1]
+	return last.Sequence, last.Headers[header], nil
+}
+
+func (s *Sink) MsgIdsAfter(_ context.Context, stream string, subjects []string, seq uint64,
+	limit int) ([]string, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		msgIds  []string
+		lastSeq uint64
+	)
+	for _, msg := range s.msgsOf(stream) {
+		lastSeq = msg.Sequence
+		if len(subjects) > 0 && !nats.NewSubjectBinding(msg.Subj, map[string][]string{stream: subjects}).Covered {
+			continue
+		}
+		if msg.Sequence > seq && len(msgIds) < limit {
+			msgIds = append(msgIds, msg.MsgId)
+		}
+	}
+	return msgIds, lastSeq, nil
+}
+
+func (s *Sink) SubjectBinding(_ context.Context, subject string) (*nats.SubjectBinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return nats.NewSubjectBinding(subject, s.subjects), nil
+}
+
+var _ mongo.Client = &TokenStore{}
+
+// ChangeEvent is a change event emitted to the watcher of a collection by a TokenStore.
+type ChangeEvent struct {
+	OperationType string
+	// Data is the payload of the change event, published as is.
+	Data []byte
+	// Subj is the subject of the change event. If empty, it is <stream>.<operationType>.
+	Subj string
+	// ResumeToken is the resume token of the change event. If empty, a sequential one is generated.
+	ResumeToken string
+	// MsgId is the message id of the change event. If empty, it is its resume token.
+	MsgId string
+	// Time is the cluster time of the change event. If zero, it is the time it is emitted.
+	Time time.Time
+}
+
+// TokenStore is an in-memory MongoDB client whose change events are emitted by the test, and which stores the resume
+// token of each change event once published, so that the collections of the connector can be tested without MongoDB,
+// see WithTokenStore. It can fail resume token saves, which stop the watcher of the collection, and add latency to
+// them.
+// Change events are not shaped by the options of their collection, e.g. its subject template or payload mode, and
+// backfills and redrives are not supported.
+type TokenStore struct {
+	mu           sync.Mutex
+	collections  []string
+	watchers     map[string]*fakeWatcher // by watched namespace
+	watched      chan struct{}           // closed once a watcher starts
+	tokens       map[string][]string     // by resume tokens namespace
+	saveFailures []error
+	latency      time.Duration
+	seq          int
+}
+
+// fakeWatcher is the watcher of a collection of a TokenStore.
+type fakeWatcher struct {
+	ctx     context.Context
+	opts    *mongo.WatchCollectionOptions
+	stopped chan error
+}
+
+// NewTokenStore returns a TokenStore without resume tokens.
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		watchers: make(map[string]*fakeWatcher),
+		watched:  make(chan struct{}),
+		tokens:   make(map[string][]string),
+	}
+}
+
+// WithTokenStore sets the given TokenStore as the MongoDB client of the Connector.
+func WithTokenStore(store *TokenStore) connector.Option {
+	return connectorhook.MongoClient(store).(connector.Option)
+}
+
+// FailNextSaves makes the next resume token saves fail with the given errors, in order.
+func (s *TokenStore) FailNextSaves(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveFailures = append(s.saveFailures, errs...)
+}
+
+// SetLatency sets the time each resume token save takes.
+func (s *TokenStore) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// ResumeTokens returns the resume tokens stored so far in the given resume tokens collection, in order.
+func (s *TokenStore) ResumeTokens(dbName, collName string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.tokens[dbName+"."+collName])
+}
+
+// Collections returns the namespaces of the collections created so far.
+func (s *TokenStore) Collections() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.collections)
+}
+
+// Watching reports whether the given collection is watched.
+func (s *TokenStore) Watching(dbName, collName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watchers[dbName+"."+collName] != nil
+}
+
+// Emit hands the given change event to the watcher of the given collection, waiting for the collection to be watched
+// until the given context is done, then stores its resume token once it is published. It returns the error of the
+// change event handler, or of the resume token save, in which case the watcher stops with it.
+func (s *TokenStore) Emit(ctx context.Context, dbName, collName string, event ChangeEvent) error {
+	w, err := s.watcher(ctx, dbName+"."+collName)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if event.ResumeToken == "" {
+		s.seq++
+		event.ResumeToken = fmt.Sprintf("%08d", s.seq)
+	}
+	latency := s.latency
+	s.mu.Unlock()
+	if event.Subj == "" {
+		event.Subj = w.opts.StreamName + "." + event.OperationType
+	}
+	if event.MsgId == "" {
+		event.MsgId = event.ResumeToken
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	if err = w.opts.ChangeEventHandler(w.ctx, &mongo.ChangeEvent{
+		Subj:          event.Subj,
+		MsgId:         event.MsgId,
+		Data:          event.Data,
+		OperationType: event.OperationType,
+		Time:          event.Time,
+	}); err != nil {
+		return err
+	}
+
+	if err = sleep(w.ctx, latency); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.saveFailures) > 0 {
+		err = fmt.Errorf("could not insert resume token: %w", s.saveFailures[0])
+		s.saveFailures = s.saveFailures[1:]
+		select {
+		case w.stopped <- err:
+		default: // the watcher is already stopping
+		}
+		return err
+	}
+	tokensNamespace := w.opts.ResumeTokensDbName + "." + w.opts.ResumeTokensCollName
+	s.tokens[tokensNamespace] = append(s.tokens[tokensNamespace], event.ResumeToken)
+	return nil
+}
+
+// watcher returns the watcher of the given namespace, waiting for it to start until the given context is done.
+func (s *TokenStore) watcher(ctx context.Context, namespace string) (*fakeWatcher, error) {
+	for {
+		s.mu.Lock()
+		w, watched := s.watchers[namespace], s.watched
+		s.mu.Unlock()
+		if w != nil {
+			return w, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%v is not watched: %w", namespace, ctx.Err())
+		case <-watched:
+		}
+	}
+}
+
+func (s *TokenStore) Name() string {
+	return "mongo"
+}
+
+func (s *TokenStore) Monitor(_ context.Context) error {
+	return nil
+}
+
+func (s *TokenStore) Close() error {
+	return nil
+}
+
+func (s *TokenStore) CreateCollection(_ context.Context, opts *mongo.CreateCollectionOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	namespace := opts.DbName + "." + opts.CollName
+	if !slices.Contains(s.collections, namespace) {
+		s.collections = append(s.collections, namespace)
+	}
+	return nil
+}
+
+// WatchCollection watches the given collection until the given context is cancelled, or a resume token save fails.
+func (s *TokenStore) WatchCollection(ctx context.Context, opts *mongo.WatchCollectionOptions) error {
+	namespace := opts.WatchedDbName + "." + opts.WatchedCollName
+	w := &fakeWatcher{ctx: ctx, opts: opts, stopped: make(chan error, 1)}
+	s.mu.Lock()
+	s.watchers[namespace] = w
+	close(s.watched)
+	s.watched = make(chan struct{})
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, namespace)
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-w.stopped:
+		return err
+	}
+}
+
+func (s *TokenStore) Backfill(_ context.Context, _ *mongo.BackfillOptions) (int, error) {
+	return 0, errors.New("backfills are not supported by the token store")
+}
+
+func (s *TokenStore) Redrive(_ context.Context, _ *mongo.WatchCollectionOptions) (int, error) {
+	return 0, errors.New("redrives are not supported by the token store")
+}
+
+func (s *TokenStore) LastResumeToken(_ context.Context, opts *mongo.ResumeTokensOptions) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := s.tokens[opts.DbName+"."+opts.CollName]
+	if len(tokens) == 0 {
+		return "", nil
+	}
+	return tokens[len(tokens)-1], nil
+}
+
+func (s *TokenStore) RestoreResumeToken(_ context.Context, opts *mongo.ResumeTokensOptions, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokensNamespace := opts.DbName + "." + opts.CollName
+	s.tokens[tokensNamespace] = append(s.tokens[tokensNamespace], token)
+	return nil
+}
+
+func (s *TokenStore) InspectResumeToken(_ context.Context, opts *mongo.ResumeTokensOptions) (
+	*mongo.ResumeTokenInspection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := s.tokens[opts.DbName+"."+opts.CollName]
+	if len(tokens) == 0 {
+		return &mongo.ResumeTokenInspection{Status: mongo.MissingTokenStatus}, nil
+	}
+	inspection := mongo.InspectToken(tokens[len(tokens)-1], time.Time{})
+	for _, token := range tokens {
+		if mongo.InspectToken(token, time.Time{}).Status == mongo.CorruptTokenStatus {
+			inspection.Corrupt++
+		}
+	}
+	return inspection, nil
+}
+
+func (s *TokenStore) RepairResumeTokens(_ context.Context, opts *mongo.ResumeTokensOptions, token string) (int,
+	error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokensNamespace := opts.DbName + "." + opts.CollName
+	repaired := 0
+	tokens := make([]string, 0, len(s.tokens[tokensNamespace]))
+	for _, stored := range s.tokens[tokensNamespace] {
+		if mongo.InspectToken(stored, time.Time{}).Status != mongo.CorruptTokenStatus {
+			tokens = append(tokens, stored)
+			continue
+		}
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+		repaired++
+	}
+	s.tokens[tokensNamespace] = tokens
+	return repaired, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
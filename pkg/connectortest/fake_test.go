@@ -0,0 +1,131 @@
+package connectortest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/context-labs/mongodb-nats-connector/internal/nats"
+	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
+)
+
+func runFakeConnector(t *testing.T, store *TokenStore, sink *Sink) (context.CancelFunc, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := connector.New(
+		connector.WithContext(ctx),
+		connector.WithServerDisabled(),
+		connector.WithInstanceId("connectortest-fake"),
+		WithTokenStore(store),
+		WithSink(sink),
+		connector.WithCollection("shop", "orders", connector.WithStreamName("ORDERS")),
+	)
+	require.NoError(t, err)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- conn.Run()
+	}()
+	t.Cleanup(cancel)
+	return cancel, runErr
+}
+
+func TestFakes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("should publish the emitted change events and store their resume tokens", func(t *testing.T) {
+		store, sink := NewTokenStore(), NewSink()
+		stop, runErr := runFakeConnector(t, store, sink)
+
+		require.NoError(t, store.Emit(ctx, "shop", "orders", ChangeEvent{OperationType: "insert", Data: []byte(`{}`)}))
+		require.NoError(t, store.Emit(ctx, "shop", "orders", ChangeEvent{OperationType: "delete", Data: []byte(`{}`),
+			ResumeToken: "token-2"}))
+
+		msgs := sink.Msgs()
+		require.Len(t, msgs, 2)
+		require.Equal(t, "ORDERS.insert", msgs[0].Subj)
+		require.Equal(t, "00000001", msgs[0].MsgId)
+		require.Equal(t, "ORDERS", msgs[0].Stream)
+		require.Equal(t, uint64(2), msgs[1].Sequence)
+		require.Equal(t, []string{"00000001", "token-2"}, store.ResumeTokens("resume-tokens", "orders"))
+		require.Contains(t, sink.Streams(), "ORDERS")
+		require.Contains(t, store.Collections(), "shop.orders")
+
+		stop()
+		require.NoError(t, <-runErr)
+	})
+	t.Run("should retry the publishes failing with an ack timeout", func(t *testing.T) {
+		store, sink := NewTokenStore(), NewSink()
+		sink.FailNext(ErrAckTimeout)
+		_, _ = runFakeConnector(t, store, sink)
+
+		require.NoError(t, store.Emit(ctx, "shop", "orders", ChangeEvent{OperationType: "insert", Data: []byte(`{}`)}))
+
+		require.Len(t, sink.Msgs(), 1)
+	})
+	t.Run("should stop the connector once a resume token cannot be saved", func(t *testing.T) {
+		store, sink := NewTokenStore(), NewSink()
+		saveErr := errors.New("not primary")
+		store.FailNextSaves(saveErr)
+		_, runErr := runFakeConnector(t, store, sink)
+
+		err := store.Emit(ctx, "shop", "orders", ChangeEvent{OperationType: "insert", Data: []byte(`{}`)})
+
+		require.ErrorIs(t, err, saveErr)
+		require.ErrorIs(t, <-runErr, saveErr)
+		require.Empty(t, store.ResumeTokens("resume-tokens", "orders"))
+	})
+}
+
+func TestSink_Publish(t *testing.T) {
+	t.Run("should fail the publishes with the given errors, in order", func(t *testing.T) {
+		sink := NewSink()
+		sink.FailNext(ErrBackpressure, ErrNack)
+
+		require.ErrorIs(t, sink.Publish(context.Background(), &nats.PublishOptions{Subj: "ORDERS.insert"}),
+			ErrBackpressure)
+		require.ErrorIs(t, sink.Publish(context.Background(), &nats.PublishOptions{Subj: "ORDERS.insert"}), ErrNack)
+		require.NoError(t, sink.Publish(context.Background(), &nats.PublishOptions{Subj: "ORDERS.insert"}))
+		require.Len(t, sink.Msgs(), 1)
+	})
+	t.Run("should not publish once the context is done during its latency", func(t *testing.T) {
+		sink := NewSink()
+		sink.SetLatency(time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := sink.Publish(ctx, &nats.PublishOptions{Subj: "ORDERS.insert"})
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Empty(t, sink.Msgs())
+	})
+	t.Run("should not ack the messages published to core nats", func(t *testing.T) {
+		sink := NewSink()
+		opts := &nats.PublishOptions{Subj: "orders.insert", MsgId: "1", Mode: nats.CorePublishMode}
+
+		require.NoError(t, sink.Publish(context.Background(), opts))
+		require.Nil(t, opts.Ack)
+		require.Empty(t, sink.Msgs()[0].Stream)
+	})
+	t.Run("should return the message ids stored at a stream after a sequence", func(t *testing.T) {
+		sink := NewSink()
+		for _, msgId := range []string{"1", "2", "3"} {
+			require.NoError(t, sink.Publish(context.Background(), &nats.PublishOptions{Subj: "ORDERS.insert",
+				MsgId: msgId, Headers: map[string]string{"Connector-Instance-Id": "connector-0"}}))
+		}
+
+		msgIds, lastSeq, err := sink.MsgIdsAfter(context.Background(), "ORDERS", nil, 1, 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2", "3"}, msgIds)
+		require.Equal(t, uint64(3), lastSeq)
+		msgIds, _, err = sink.MsgIdsAfter(context.Background(), "ORDERS", []string{"ORDERS.update"}, 1, 10)
+		require.NoError(t, err)
+		require.Empty(t, msgIds)
+		seq, instanceId, err := sink.LastMsgHeader(context.Background(), "ORDERS", "Connector-Instance-Id")
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), seq)
+		require.Equal(t, "connector-0", instanceId)
+	})
+}
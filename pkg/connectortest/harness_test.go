@@ -0,0 +1,40 @@
+//go:build integration
+
+package connectortest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/context-labs/mongodb-nats-connector/pkg/connector"
+)
+
+func TestHarness(t *testing.T) {
+	h := New(t, WithMongoUri(os.Getenv("MONGO_URI")))
+	h.StartConnector(connector.WithCollection("shop", "orders", connector.WithStreamName("ORDERS")))
+	sub := h.MustSubscribe("ORDERS.>")
+
+	id := h.MustInsertOne("shop", "orders", bson.D{{Key: "status", Value: "pending"}})
+	h.MustUpdateOne("shop", "orders", bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "paid"}}}})
+
+	var inserted struct {
+		OperationType string `json:"operationType"`
+		FullDocument  struct {
+			Status string `json:"status"`
+		} `json:"fullDocument"`
+	}
+	msg := sub.MustNextChangeEvent(5*time.Second, &inserted)
+	require.Equal(t, "ORDERS.insert", msg.Subject)
+	require.NotEmpty(t, msg.Header.Get(nats.MsgIdHdr))
+	require.Equal(t, "insert", inserted.OperationType)
+	require.Equal(t, "pending", inserted.FullDocument.Status)
+
+	require.Equal(t, "ORDERS.update", sub.MustNextMsg(5*time.Second).Subject)
+	sub.MustNotReceiveMsg(500 * time.Millisecond)
+}